@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/agents"
+	"github.com/danshapiro/kilroy/internal/attractor/agents/tmux"
+	"github.com/danshapiro/kilroy/internal/attractor/runstate"
+)
+
+func attractorAttach(args []string) {
+	os.Exit(runAttractorAttach(args, tmux.NewManager(agents.SessionSocket), os.Stdout, os.Stderr))
+}
+
+// runAttractorAttach resolves the tmux session of a --tmux run's node and
+// attaches the terminal to it. Takeover (read-write) is the default; keys
+// typed there go straight to the agent.
+func runAttractorAttach(args []string, mgr *tmux.Manager, stdout io.Writer, stderr io.Writer) int {
+	var logsRoot, nodeID string
+	readOnly := false
+	printOnly := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--logs-root":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(stderr, "--logs-root requires a value")
+				return 1
+			}
+			logsRoot = args[i]
+		case "--node":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(stderr, "--node requires a value")
+				return 1
+			}
+			nodeID = args[i]
+		case "--read-only":
+			readOnly = true
+		case "--print":
+			printOnly = true
+		default:
+			fmt.Fprintf(stderr, "unknown arg: %s\n", args[i])
+			return 1
+		}
+	}
+
+	if logsRoot == "" {
+		fmt.Fprintln(stderr, "--logs-root is required")
+		return 1
+	}
+
+	snapshot, err := runstate.LoadSnapshot(logsRoot)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	runID := resolveExpectedRunID(snapshot.RunID, logsRoot)
+	if runID == "" {
+		fmt.Fprintf(stderr, "cannot determine run id for %s\n", logsRoot)
+		return 1
+	}
+	session, err := agents.ResolveSession(mgr, runID, nodeID, snapshot.CurrentNodeID)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	cmd := mgr.AttachCommand(session, readOnly)
+	if printOnly {
+		fmt.Fprintf(stdout, "session=%s\ncommand=%s\n", session, strings.Join(cmd.Args, " "))
+		return 0
+	}
+	if readOnly {
+		fmt.Fprintf(stderr, "attaching read-only to %s (detach: C-b d)\n", session)
+	} else {
+		fmt.Fprintf(stderr, "attaching to %s; input goes to the agent (detach: C-b d)\n", session)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(stderr, "tmux attach: %v\n", err)
+		return 1
+	}
+	return 0
+}
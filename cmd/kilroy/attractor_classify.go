@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/engine"
+)
+
+func attractorClassify(args []string) {
+	os.Exit(runAttractorClassify(args, os.Stdout, os.Stderr))
+}
+
+// runAttractorClassify replays a run's failed attempts through the current
+// failure_classification rules so rule edits can be checked against history.
+func runAttractorClassify(args []string, stdout io.Writer, stderr io.Writer) int {
+	var logsRoot, configPath string
+	asJSON := false
+	changedOnly := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--logs-root":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(stderr, "--logs-root requires a value")
+				return 1
+			}
+			logsRoot = args[i]
+		case "--config":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(stderr, "--config requires a value")
+				return 1
+			}
+			configPath = args[i]
+		case "--changed":
+			changedOnly = true
+		case "--json":
+			asJSON = true
+		default:
+			fmt.Fprintf(stderr, "unknown arg: %s\n", args[i])
+			return 1
+		}
+	}
+
+	if logsRoot == "" {
+		fmt.Fprintln(stderr, "--logs-root is required")
+		return 1
+	}
+
+	classifier, err := engine.LoadRunFailureClassifier(logsRoot, configPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	results, err := engine.ReclassifyRunFailures(logsRoot, classifier)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	changed := 0
+	shown := make([]engine.FailureReclassification, 0, len(results))
+	for _, r := range results {
+		if r.Changed {
+			changed++
+		}
+		if r.Changed || !changedOnly {
+			shown = append(shown, r)
+		}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(shown)
+		return 0
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(stdout, "no failed attempts in %s\n", logsRoot)
+		return 0
+	}
+	fmt.Fprintf(stdout, "%-24s  %-7s  %-18s  %-18s  %-16s  %s\n", "NODE", "ATTEMPT", "RECORDED", "CLASS", "RULE", "FAILURE REASON")
+	fmt.Fprintln(stdout, strings.Repeat("-", 110))
+	for _, r := range shown {
+		mark := " "
+		if r.Changed {
+			mark = "*"
+		}
+		rule := r.Rule
+		if rule == "" {
+			rule = "-"
+		}
+		reason := strings.Join(strings.Fields(r.FailureReason), " ")
+		if len(reason) > 60 {
+			reason = reason[:57] + "..."
+		}
+		fmt.Fprintf(stdout, "%-24s  %-7d  %-18s %s%-18s  %-16s  %s\n", r.NodeID, r.Attempt, r.RecordedClass, mark, r.Class, rule, reason)
+	}
+	fmt.Fprintf(stdout, "\n%d failed attempt(s), %d classified differently under current rules (%d rule(s))\n", len(results), changed, classifier.Len())
+	return 0
+}
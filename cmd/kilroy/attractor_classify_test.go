@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttractorClassify_ReplaysFailuresUnderConfigRules(t *testing.T) {
+	logs := t.TempDir()
+	progress := strings.Join([]string{
+		`{"event":"stage_attempt_end","node_id":"build","attempt":1,"status":"fail","failure_reason":"failed to fetch index.crates.io","failure_class":"deterministic"}`,
+		`{"event":"stage_attempt_end","node_id":"build","attempt":2,"status":"success"}`,
+		`{"event":"stage_attempt_end","node_id":"test","attempt":1,"status":"fail","failure_reason":"exit status 1","failure_class":"deterministic"}`,
+	}, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(logs, "progress.ndjson"), []byte(progress), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := filepath.Join(t.TempDir(), "run.yaml")
+	if err := os.WriteFile(cfg, []byte(`version: 1
+repo:
+  path: /tmp/repo
+failure_classification:
+  rules:
+    - name: crates
+      class: transient_infra
+      match: 'index\.crates\.io'
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runAttractorClassify([]string{"--logs-root", logs, "--config", cfg, "--changed", "--json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	var got []struct {
+		NodeID        string `json:"node_id"`
+		RecordedClass string `json:"recorded_class"`
+		Class         string `json:"class"`
+		Rule          string `json:"rule"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v\n%s", err, stdout.String())
+	}
+	if len(got) != 1 || got[0].NodeID != "build" || got[0].Class != "transient_infra" || got[0].Rule != "crates" {
+		t.Fatalf("unexpected changed failures: %+v", got)
+	}
+
+	stdout.Reset()
+	if code := runAttractorClassify([]string{"--logs-root", logs, "--config", cfg}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "2 failed attempt(s), 1 classified differently") {
+		t.Fatalf("unexpected table output:\n%s", stdout.String())
+	}
+
+	if code := runAttractorClassify([]string{"--config", cfg}, &stdout, &stderr); code != 1 {
+		t.Fatalf("missing --logs-root: exit %d, want 1", code)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/engine"
+	"github.com/danshapiro/kilroy/internal/attractor/estimate"
+	"github.com/danshapiro/kilroy/internal/attractor/modeldb"
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+)
+
+func attractorEstimate(args []string) {
+	os.Exit(runAttractorEstimate(args, os.Stdout, os.Stderr))
+}
+
+// runAttractorEstimate projects a graph's LLM spend before it runs: per-node
+// models and token ranges (from prior runs of the graph when the run database
+// has them), loop and retry multipliers, and a low/expected/high USD range.
+func runAttractorEstimate(args []string, stdout io.Writer, stderr io.Writer) int {
+	var graphPath, configPath string
+	historyLimit := 10
+	asJSON := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--graph":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(stderr, "--graph requires a value")
+				return 1
+			}
+			graphPath = args[i]
+		case "--config":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(stderr, "--config requires a value")
+				return 1
+			}
+			configPath = args[i]
+		case "--history":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(stderr, "--history requires a value")
+				return 1
+			}
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 0 {
+				fmt.Fprintf(stderr, "invalid --history value: %s\n", args[i])
+				return 1
+			}
+			historyLimit = n
+		case "--json":
+			asJSON = true
+		default:
+			fmt.Fprintf(stderr, "unknown arg: %s\n", args[i])
+			return 1
+		}
+	}
+
+	if graphPath == "" {
+		fmt.Fprintln(stderr, "--graph is required")
+		return 1
+	}
+	dotSource, err := os.ReadFile(graphPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	var cfg *engine.RunConfigFile
+	if configPath != "" {
+		cfg, err = engine.LoadRunConfigFile(configPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	// Price against the catalog the run would pin, else the embedded one.
+	var cat *modeldb.Catalog
+	if cfg != nil && strings.TrimSpace(cfg.ModelDB.OpenRouterModelInfoPath) != "" {
+		cat, err = modeldb.LoadCatalogFromOpenRouterJSON(cfg.ModelDB.OpenRouterModelInfoPath)
+	} else {
+		cat, err = modeldb.LoadEmbeddedCatalog()
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "WARNING: model catalog unavailable, costs will be $0: %v\n", err)
+		cat = nil
+	}
+
+	opts := engine.PrepareOptions{GraphDir: filepath.Dir(graphPath), Catalog: cat}
+	if cfg != nil {
+		opts.RepoPath = cfg.Repo.Path
+	}
+	g, diags, err := engine.PrepareWithOptions(dotSource, opts)
+	if err != nil {
+		for _, d := range diags {
+			fmt.Fprintf(stderr, "%s: %s (%s)\n", d.Severity, d.Message, d.Rule)
+		}
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	var history []estimate.History
+	if historyLimit > 0 {
+		// History is best-effort: a missing or locked run database just
+		// means heuristics.
+		if db, err := rundb.Open(rundb.DefaultPath()); err == nil {
+			history, err = estimate.LoadHistory(db, g.Name, historyLimit)
+			if err != nil {
+				fmt.Fprintf(stderr, "WARNING: run history unavailable: %v\n", err)
+			}
+			db.Close()
+		}
+	}
+
+	est := estimate.Build(g, estimate.Options{Catalog: cat, History: history})
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(est)
+		return 0
+	}
+	printEstimate(stdout, est)
+	return 0
+}
+
+func printEstimate(w io.Writer, est *estimate.RunEstimate) {
+	if len(est.Nodes) == 0 {
+		fmt.Fprintf(w, "no LLM nodes in %s\n", est.GraphName)
+		return
+	}
+	fmt.Fprintf(w, "%-22s  %-30s  %-10s  %-21s  %-15s  %-15s  %s\n", "NODE", "MODEL", "SOURCE", "TOKENS IN/OUT (EXP)", "EXECUTIONS", "BOUND", "COST USD (LOW/EXP/HIGH)")
+	fmt.Fprintln(w, strings.Repeat("-", 146))
+	for _, n := range est.Nodes {
+		modelName := n.Provider + "/" + n.Model
+		if n.Provider == "" || n.Model == "" {
+			modelName = "-"
+		}
+		if !n.Priced {
+			modelName += " (unpriced)"
+		}
+		tokens := formatTokenCount(n.InputTokens.Expected) + " / " + formatTokenCount(n.OutputTokens.Expected)
+		execs := fmt.Sprintf("%.3g/%.3g/%.0f", n.Executions.Low, n.Executions.Expected, n.Executions.High)
+		fmt.Fprintf(w, "%-22s  %-30s  %-10s  %-21s  %-15s  %-15s  %s\n",
+			n.NodeID, modelName, n.TokenSource, tokens, execs, n.Bound, formatUSDRange(n.CostUSD))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "total: %s\n", formatUSDRange(est.CostUSD))
+	if est.HistoryRuns > 0 {
+		fmt.Fprintf(w, "duration: %s / %s / %s (from %d prior run(s))\n",
+			formatEstimateDuration(est.DurationMS.Low), formatEstimateDuration(est.DurationMS.Expected), formatEstimateDuration(est.DurationMS.High), est.HistoryRuns)
+	} else {
+		fmt.Fprintln(w, "duration: unknown (no prior runs of this graph)")
+	}
+	for _, warning := range est.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+}
+
+func formatTokenCount(n float64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", n/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.0fk", n/1_000)
+	}
+	return fmt.Sprintf("%.0f", n)
+}
+
+func formatUSDRange(r estimate.Range) string {
+	return fmt.Sprintf("$%.2f / $%.2f / $%.2f", r.Low, r.Expected, r.High)
+}
+
+func formatEstimateDuration(ms float64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+}
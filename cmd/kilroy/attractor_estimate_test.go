@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttractorEstimate_JSONPricesLLMNodesFromCatalog(t *testing.T) {
+	graph := filepath.Join(t.TempDir(), "estimate.dot")
+	if err := os.WriteFile(graph, []byte(`digraph estimate_demo {
+  graph [goal="x", max_node_visits=4, model_stylesheet="* { llm_provider: anthropic; llm_model: claude-sonnet-4.5; }"]
+  start [shape=Mdiamond]
+  plan [shape=box, agent_mode=one_shot, max_retries=0, prompt="plan it"]
+  impl [shape=box, prompt="do it"]
+  check [shape=parallelogram, tool_command="true"]
+  exit [shape=Msquare]
+  start -> plan -> impl -> check
+  check -> exit [condition="outcome=success"]
+  check -> impl
+}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runAttractorEstimate([]string{"--graph", graph, "--history", "0", "--json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	var got struct {
+		GraphName string `json:"graph_name"`
+		Nodes     []struct {
+			NodeID     string             `json:"node_id"`
+			Priced     bool               `json:"priced"`
+			Executions map[string]float64 `json:"executions"`
+		} `json:"nodes"`
+		CostUSD map[string]float64 `json:"cost_usd"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v\n%s", err, stdout.String())
+	}
+	if got.GraphName != "estimate_demo" || len(got.Nodes) != 2 {
+		t.Fatalf("estimate = %s", stdout.String())
+	}
+	for _, n := range got.Nodes {
+		if !n.Priced {
+			t.Fatalf("node %s not priced: %s", n.NodeID, stdout.String())
+		}
+		if n.NodeID == "impl" && n.Executions["high"] <= n.Executions["expected"] {
+			t.Fatalf("impl executions = %v", n.Executions)
+		}
+	}
+	if !(got.CostUSD["low"] > 0 && got.CostUSD["low"] < got.CostUSD["expected"] && got.CostUSD["expected"] < got.CostUSD["high"]) {
+		t.Fatalf("cost = %v", got.CostUSD)
+	}
+}
+
+func TestAttractorEstimate_RequiresGraph(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runAttractorEstimate([]string{"--json"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit %d", code)
+	}
+	if !strings.Contains(stderr.String(), "--graph is required") {
+		t.Fatalf("stderr = %q", stderr.String())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/dot"
+)
+
+// attractorFmt rewrites graph files in canonical DOT layout. With --check it
+// leaves files untouched, prints a diff for each one that is not canonical,
+// and exits 1 if any were found, so it can gate CI.
+func attractorFmt(args []string) {
+	var files []string
+	var check bool
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--check":
+			check = true
+		default:
+			if strings.HasPrefix(args[i], "--") {
+				fmt.Fprintf(os.Stderr, "unknown arg: %s\n", args[i])
+				os.Exit(1)
+			}
+			files = append(files, args[i])
+		}
+	}
+
+	if len(files) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	failed := false
+	unformatted := false
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		out, err := dot.Format(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		if string(out) == string(src) {
+			continue
+		}
+		if check {
+			unformatted = true
+			fmt.Print(unifiedDiff("a/"+path, "b/"+path, src, out))
+			continue
+		}
+		mode := os.FileMode(0o644)
+		if st, err := os.Stat(path); err == nil {
+			mode = st.Mode().Perm()
+		}
+		if err := os.WriteFile(path, out, mode); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		fmt.Println(path)
+	}
+
+	if failed || unformatted {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
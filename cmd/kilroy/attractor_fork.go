@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/danshapiro/kilroy/internal/attractor/engine"
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+)
+
+// attractorFork starts a new run from a completed node of an earlier run.
+func attractorFork(args []string) {
+	var from string
+	var nodeID string
+	var graphPath string
+	var configPath string
+	var runID string
+	var logsRoot string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--from":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--from requires a value")
+				os.Exit(1)
+			}
+			from = args[i]
+		case "--at":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--at requires a value")
+				os.Exit(1)
+			}
+			nodeID = args[i]
+		case "--graph":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--graph requires a value")
+				os.Exit(1)
+			}
+			graphPath = args[i]
+		case "--config":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--config requires a value")
+				os.Exit(1)
+			}
+			configPath = args[i]
+		case "--run-id":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--run-id requires a value")
+				os.Exit(1)
+			}
+			runID = args[i]
+		case "--logs-root":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--logs-root requires a value")
+				os.Exit(1)
+			}
+			logsRoot = args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "unknown arg: %s\n", args[i])
+			os.Exit(1)
+		}
+	}
+	if from == "" || nodeID == "" {
+		usage()
+		os.Exit(1)
+	}
+
+	rdb := openRunDB()
+	if rdb != nil {
+		defer rdb.Close()
+	}
+	parentLogsRoot, err := resolveForkParent(from, rdb)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	opts := engine.ForkOptions{
+		ParentLogsRoot: parentLogsRoot,
+		NodeID:         nodeID,
+		RunID:          runID,
+		LogsRoot:       logsRoot,
+		Invocation:     os.Args,
+	}
+	if rdb != nil {
+		opts.RunDB = rdb
+	}
+	if graphPath != "" {
+		opts.DotSource, err = os.ReadFile(graphPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if configPath != "" {
+		opts.Config, err = engine.LoadRunConfigFile(configPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	ctx, cleanupSignalCtx := signalCancelContext()
+	res, err := engine.Fork(ctx, opts)
+	cleanupSignalCtx()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("run_id=%s\n", res.RunID)
+	fmt.Printf("logs_root=%s\n", res.LogsRoot)
+	fmt.Printf("worktree=%s\n", res.WorktreeDir)
+	fmt.Printf("run_branch=%s\n", res.RunBranch)
+	fmt.Printf("final_commit=%s\n", res.FinalCommitSHA)
+	if res.CXDBUIURL != "" {
+		fmt.Printf("cxdb_ui=%s\n", res.CXDBUIURL)
+	}
+
+	if string(res.FinalStatus) == "success" {
+		os.Exit(0)
+	}
+	os.Exit(1)
+}
+
+// resolveForkParent maps --from to a logs root. A directory containing a
+// manifest is used as-is; anything else is looked up as a run ID or prefix.
+func resolveForkParent(from string, db *rundb.DB) (string, error) {
+	if _, err := os.Stat(filepath.Join(from, "manifest.json")); err == nil {
+		return from, nil
+	}
+	if db == nil {
+		return "", fmt.Errorf("--from %q is not a logs root and the run database is unavailable", from)
+	}
+	run, err := db.GetRun(from)
+	if err != nil {
+		return "", fmt.Errorf("lookup run %q: %v", from, err)
+	}
+	if run == nil {
+		return "", fmt.Errorf("no run matching %q", from)
+	}
+	if run.LogsRoot == "" {
+		return "", fmt.Errorf("run %s has no recorded logs root", run.RunID)
+	}
+	return run.LogsRoot, nil
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/danshapiro/kilroy/internal/attractor/engine"
 	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+	"github.com/danshapiro/kilroy/internal/attractor/runstate"
 )
 
 func attractorRuns(args []string) {
@@ -36,7 +37,7 @@ func attractorRuns(args []string) {
 func runsUsage() {
 	fmt.Fprintln(os.Stderr, "usage:")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor runs list [--json] [--label KEY=VALUE] [--status STATUS] [--graph PATTERN] [--limit N]")
-	fmt.Fprintln(os.Stderr, "  kilroy attractor runs show (<id-or-prefix> | --latest [--label KEY=VALUE]) [--json] [--outputs] [--print <file>]")
+	fmt.Fprintln(os.Stderr, "  kilroy attractor runs show (<id-or-prefix> | --latest [--label KEY=VALUE]) [--json] [--outputs] [--timeline] [--print <file>]")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor runs wait (<id-or-prefix> | --latest [--label KEY=VALUE]) [--timeout <duration>] [--interval <duration>] [--json]")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor runs prune [--before YYYY-MM-DD] [--older-than <duration>] [--graph PATTERN] [--label KEY=VALUE] [--orphans] [--dry-run | --yes]")
 }
@@ -560,6 +561,8 @@ type runShowDetail struct {
 	RunBranch     string             `json:"run_branch,omitempty"`
 	FinalSHA      string             `json:"final_sha,omitempty"`
 	FailureReason string             `json:"failure_reason,omitempty"`
+	ParentRunID   string             `json:"parent_run_id,omitempty"`
+	ForkNodeID    string             `json:"fork_node_id,omitempty"`
 	Labels        map[string]string  `json:"labels,omitempty"`
 	Inputs        map[string]any     `json:"inputs,omitempty"`
 	Invocation    []string           `json:"invocation,omitempty"`
@@ -580,6 +583,7 @@ func attractorRunsShow(args []string) {
 	var asJSON bool
 	var printFile string
 	var listOutputs bool
+	var showTimeline bool
 	var latest bool
 	labelFilters := map[string]string{}
 
@@ -589,6 +593,8 @@ func attractorRunsShow(args []string) {
 			asJSON = true
 		case "--outputs":
 			listOutputs = true
+		case "--timeline":
+			showTimeline = true
 		case "--latest":
 			latest = true
 		case "--label":
@@ -684,6 +690,23 @@ func attractorRunsShow(args []string) {
 		return
 	}
 
+	if showTimeline {
+		execs, err := db.GetNodeExecutions(run.RunID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "query node executions: %v\n", err)
+			os.Exit(1)
+		}
+		tl := runstate.BuildTimeline(run, execs, db.GetDotSource(run.RunID), time.Now().UTC())
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(tl)
+			return
+		}
+		tl.WriteText(os.Stdout, 60)
+		return
+	}
+
 	outputs := gatherOutputRefs(run)
 
 	if listOutputs {
@@ -712,6 +735,8 @@ func attractorRunsShow(args []string) {
 			RunBranch:     run.RunBranch,
 			FinalSHA:      run.FinalSHA,
 			FailureReason: run.FailureReason,
+			ParentRunID:   run.ParentRunID,
+			ForkNodeID:    run.ForkNodeID,
 			Labels:        run.Labels,
 			Inputs:        run.Inputs,
 			Invocation:    run.Invocation,
@@ -740,6 +765,9 @@ func attractorRunsShow(args []string) {
 	if run.FailureReason != "" {
 		fmt.Printf("failure:      %s\n", run.FailureReason)
 	}
+	if run.ParentRunID != "" {
+		fmt.Printf("forked_from:  %s at %s\n", run.ParentRunID, run.ForkNodeID)
+	}
 	if len(run.Labels) > 0 {
 		fmt.Printf("labels:       %s\n", formatLabels(run.Labels))
 	}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/engine"
+)
+
+func attractorSimulate(args []string) {
+	var graphPath string
+	var scriptPath string
+	var inputPath string
+	var jsonOutput bool
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--graph":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--graph requires a value")
+				os.Exit(1)
+			}
+			graphPath = args[i]
+		case "--script":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--script requires a value")
+				os.Exit(1)
+			}
+			scriptPath = args[i]
+		case "--input":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--input requires a value")
+				os.Exit(1)
+			}
+			inputPath = args[i]
+		case "--json":
+			jsonOutput = true
+		default:
+			fmt.Fprintf(os.Stderr, "unknown arg: %s\n", args[i])
+			os.Exit(1)
+		}
+	}
+
+	if graphPath == "" {
+		usage()
+		os.Exit(1)
+	}
+	dotSource, err := os.ReadFile(graphPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	script := &engine.SimulationScript{}
+	if scriptPath != "" {
+		script, err = engine.LoadSimulationScript(scriptPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	var inputs map[string]any
+	if inputPath != "" {
+		if strings.HasPrefix(strings.TrimSpace(inputPath), "{") {
+			inputs, err = engine.LoadInputString(inputPath)
+		} else {
+			inputs, err = engine.LoadInputFile(inputPath)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading inputs: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	graphDir := ""
+	if abs, err := filepath.Abs(graphPath); err == nil {
+		graphDir = filepath.Dir(abs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	rep, err := engine.Simulate(ctx, dotSource, script, engine.SimulateOptions{
+		GraphDir: graphDir,
+		Inputs:   inputs,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			fmt.Fprintln(os.Stderr, "json encode:", err)
+			os.Exit(1)
+		}
+	} else {
+		printSimulationReport(rep)
+	}
+
+	// Exit codes: 0 = expectations held (or none declared), 1 = expectation
+	// failures. A simulated run that ends in fail is not itself an error —
+	// scripts assert on final_status when that matters.
+	if !rep.Passed() {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func printSimulationReport(rep *engine.SimulationReport) {
+	fmt.Printf("graph: %s\n", rep.Graph)
+	fmt.Printf("path: %s\n", strings.Join(rep.Path, " -> "))
+	if len(rep.Retries) > 0 {
+		fmt.Printf("retries: %s\n", formatCountMap(rep.Retries))
+	}
+	if len(rep.LoopIterations) > 0 {
+		fmt.Printf("loop iterations: %s\n", formatCountMap(rep.LoopIterations))
+	}
+	if rep.LoopRestarts > 0 {
+		fmt.Printf("loop restarts: %d\n", rep.LoopRestarts)
+	}
+	fmt.Printf("executions: %d\n", len(rep.Executions))
+	fmt.Printf("final_status: %s\n", rep.FinalStatus)
+	if rep.FailureReason != "" {
+		fmt.Printf("failure_reason: %s\n", rep.FailureReason)
+	}
+	if len(rep.ExpectationFailures) > 0 {
+		fmt.Println("expectations: FAIL")
+		for _, f := range rep.ExpectationFailures {
+			fmt.Printf("  - %s\n", f)
+		}
+	} else {
+		fmt.Println("expectations: ok")
+	}
+}
+
+func formatCountMap(m map[string]int) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, m[k]))
+	}
+	return strings.Join(parts, " ")
+}
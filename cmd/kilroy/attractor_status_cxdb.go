@@ -163,7 +163,7 @@ func formatCXDBTurn(turn cxdb.Turn) string {
 		return line
 
 	case "com.kilroy.attractor.ToolCall":
-		toolName := payloadStr(p, "tool_name")
+		toolName := subagentPrefix(p) + payloadStr(p, "tool_name")
 		callID := payloadStr(p, "call_id")
 		argsJSON := payloadStr(p, "arguments_json")
 		if len(argsJSON) > 120 {
@@ -175,7 +175,7 @@ func formatCXDBTurn(turn cxdb.Turn) string {
 		return fmt.Sprintf("%s | TOOL_CALL              | %s [%s]", ts, toolName, callID)
 
 	case "com.kilroy.attractor.ToolResult":
-		toolName := payloadStr(p, "tool_name")
+		toolName := subagentPrefix(p) + payloadStr(p, "tool_name")
 		callID := payloadStr(p, "call_id")
 		isErr := payloadStr(p, "is_error")
 		output := payloadStr(p, "output")
@@ -219,7 +219,7 @@ func formatCXDBTurn(turn cxdb.Turn) string {
 		return fmt.Sprintf("%s | RUN_COMPLETED          | %s (commit %s)", ts, status, sha)
 
 	case "com.kilroy.attractor.AssistantMessage":
-		model := payloadStr(p, "model")
+		model := subagentPrefix(p) + payloadStr(p, "model")
 		inTok := payloadStr(p, "input_tokens")
 		outTok := payloadStr(p, "output_tokens")
 		toolCount := payloadStr(p, "tool_use_count")
@@ -243,6 +243,16 @@ func formatCXDBTurn(turn cxdb.Turn) string {
 		}
 		return fmt.Sprintf("%s | PROMPT                 | %s\n%s%s", ts, nodeID, strings.Repeat(" ", 11), text)
 
+	case "com.kilroy.attractor.PlanUpdated":
+		line := fmt.Sprintf("%s | PLAN_UPDATED           | %s (%s/%s completed)", ts, nodeID, payloadStr(p, "completed"), payloadStr(p, "total"))
+		if expl := payloadStr(p, "explanation"); expl != "" {
+			if len(expl) > 120 {
+				expl = expl[:117] + "..."
+			}
+			line += "\n" + strings.Repeat(" ", 11) + expl
+		}
+		return line
+
 	case "com.kilroy.attractor.RunFailed":
 		reason := payloadStr(p, "reason")
 		return fmt.Sprintf("%s | RUN_FAILED             | %s | %s", ts, nodeID, reason)
@@ -275,6 +285,14 @@ func formatCXDBTimestamp(p map[string]any) string {
 	return "         "
 }
 
+// subagentPrefix marks turns made by a subagent of the stage's agent.
+func subagentPrefix(p map[string]any) string {
+	if id := payloadStr(p, "subagent_id"); id != "" {
+		return "[subagent " + id + "] "
+	}
+	return ""
+}
+
 func payloadStr(p map[string]any, key string) string {
 	v, ok := p[key]
 	if !ok || v == nil {
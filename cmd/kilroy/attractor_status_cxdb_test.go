@@ -303,6 +303,45 @@ func TestFormatCXDBTurn_AssistantMessage(t *testing.T) {
 	}
 }
 
+func TestFormatCXDBTurn_PlanUpdated(t *testing.T) {
+	turn := cxdb.Turn{
+		TypeID:      "com.kilroy.attractor.PlanUpdated",
+		TypeVersion: 1,
+		Depth:       10,
+		Payload: map[string]any{
+			"timestamp_ms": float64(1739163625000),
+			"node_id":      "implement",
+			"completed":    float64(1),
+			"total":        float64(3),
+			"explanation":  "parser done",
+		},
+	}
+	got := formatCXDBTurn(turn)
+	if !strings.Contains(got, "PLAN_UPDATED") || !strings.Contains(got, "implement (1/3 completed)") {
+		t.Fatalf("expected plan progress: %s", got)
+	}
+	if !strings.Contains(got, "parser done") {
+		t.Fatalf("expected explanation: %s", got)
+	}
+}
+
+func TestFormatCXDBTurn_SubagentToolCall(t *testing.T) {
+	turn := cxdb.Turn{
+		TypeID:      "com.kilroy.attractor.ToolCall",
+		TypeVersion: 1,
+		Depth:       11,
+		Payload: map[string]any{
+			"tool_name":   "write_file",
+			"call_id":     "c1",
+			"subagent_id": "01J0SUB",
+		},
+	}
+	got := formatCXDBTurn(turn)
+	if !strings.Contains(got, "TOOL_CALL") || !strings.Contains(got, "[subagent 01J0SUB] write_file [c1]") {
+		t.Fatalf("expected subagent attribution: %s", got)
+	}
+}
+
 func TestFormatCXDBTurn_AssistantMessageTextOnly(t *testing.T) {
 	turn := cxdb.Turn{
 		TypeID:      "com.kilroy.attractor.AssistantMessage",
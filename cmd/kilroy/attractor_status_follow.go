@@ -155,6 +155,11 @@ func formatProgressEvent(ev map[string]any) string {
 		}
 		return line
 
+	case "stage_cached":
+		return fmt.Sprintf("%s | %-24s | %s | cached %s from run %s",
+			ts, event, nodeID,
+			evStr(ev, "status"), evStr(ev, "source_run_id"))
+
 	case "edge_selected":
 		return fmt.Sprintf("%s | %-24s | %s -> %s",
 			ts, event,
@@ -145,6 +145,14 @@ func TestFormatProgressEvent_AllEventTypes(t *testing.T) {
 			},
 			contains: []string{"04:00:40", "stage_attempt_end", "pick_feature", "success"},
 		},
+		{
+			name: "stage_cached",
+			event: map[string]any{
+				"ts": "2026-02-10T04:00:41Z", "event": "stage_cached",
+				"node_id": "research", "status": "success", "source_run_id": "01PREV",
+			},
+			contains: []string{"04:00:41", "stage_cached", "research", "cached success from run 01PREV"},
+		},
 		{
 			name: "stage_attempt_end_fail",
 			event: map[string]any{
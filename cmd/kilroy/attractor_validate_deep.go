@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/validate"
+)
+
+// deepValidateResult is the --json shape for `validate --graph --deep`.
+type deepValidateResult struct {
+	File        string                 `json:"file"`
+	Diagnostics []validate.Diagnostic  `json:"diagnostics"`
+	Deep        *validate.DeepAnalysis `json:"deep"`
+}
+
+// attractorValidateDeep runs whole-graph path analysis on an already prepared
+// graph and reports it alongside the regular lint diagnostics. Exit codes
+// match the plain validate command: 0 = no errors, 1 = any error.
+func attractorValidateDeep(graphPath string, g *model.Graph, diags []validate.Diagnostic, jsonOutput bool) {
+	analysis := validate.AnalyzeDeep(g)
+	hasErrors := false
+	for _, d := range analysis.Diagnostics {
+		if d.Severity == validate.SeverityError {
+			hasErrors = true
+		}
+	}
+
+	if jsonOutput {
+		if diags == nil {
+			diags = []validate.Diagnostic{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(deepValidateResult{File: graphPath, Diagnostics: diags, Deep: analysis}); err != nil {
+			fmt.Fprintln(os.Stderr, "json encode:", err)
+			os.Exit(1)
+		}
+	} else {
+		status := "ok"
+		if hasErrors {
+			status = "FAIL"
+		}
+		fmt.Printf("%s: %s\n", status, filepath.Base(graphPath))
+		for _, d := range diags {
+			fmt.Printf("%s: %s (%s)\n", d.Severity, d.Message, d.Rule)
+		}
+		for _, d := range analysis.Diagnostics {
+			fmt.Printf("%s: %s%s (%s)\n", d.Severity, deepDiagnosticSubject(d), d.Message, d.Rule)
+		}
+		printVisitBounds(analysis.Visits)
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func deepDiagnosticSubject(d validate.Diagnostic) string {
+	switch {
+	case d.EdgeFrom != "" || d.EdgeTo != "":
+		return fmt.Sprintf("%s -> %s: ", d.EdgeFrom, d.EdgeTo)
+	case d.NodeID != "":
+		return d.NodeID + ": "
+	default:
+		return ""
+	}
+}
+
+func printVisitBounds(visits []validate.VisitBound) {
+	if len(visits) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Printf("%-30s  %8s  %8s  %10s  %s\n", "NODE", "VISITS", "ATTEMPTS", "EXECUTIONS", "BOUND")
+	fmt.Println(strings.Repeat("-", 78))
+	for _, v := range visits {
+		visitsCol := fmt.Sprintf("%d", v.Visits)
+		execCol := fmt.Sprintf("%d", v.Executions)
+		bound := v.Bound
+		if v.Unbounded {
+			visitsCol, execCol, bound = "∞", "∞", "unbounded"
+		}
+		fmt.Printf("%-30s  %8s  %8d  %10s  %s\n", v.NodeID, visitsCol, v.Attempts, execCol, bound)
+	}
+}
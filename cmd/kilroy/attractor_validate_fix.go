@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/danshapiro/kilroy/internal/attractor/engine"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/modeldb"
+	"github.com/danshapiro/kilroy/internal/attractor/validate"
+)
+
+// fixValidateResult is the --json shape for `validate --graph --fix`.
+type fixValidateResult struct {
+	File      string                `json:"file"`
+	Applied   []validate.AppliedFix `json:"applied"`
+	Skipped   []validate.Diagnostic `json:"skipped,omitempty"`
+	Remaining []validate.Diagnostic `json:"remaining"`
+	Diff      string                `json:"diff"`
+	Written   bool                  `json:"written"`
+}
+
+// attractorValidateFix applies the automatic fixes for diags to the graph
+// source, prints a unified diff, and writes the result back when write is set.
+// Exit codes: 0 = no errors remain after fixing, 1 = errors remain.
+func attractorValidateFix(graphPath string, dotSource []byte, g *model.Graph, diags []validate.Diagnostic, cat *modeldb.Catalog, write bool, jsonOutput bool) {
+	res, err := validate.ApplyFixes(dotSource, g, diags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	diff := unifiedDiff("a/"+graphPath, "b/"+graphPath, dotSource, res.Source)
+
+	_, remaining, prepErr := engine.PrepareWithOptions(res.Source, engine.PrepareOptions{Catalog: cat})
+	if remaining == nil {
+		remaining = []validate.Diagnostic{}
+	}
+	// A fixer can decline because another fix already made its diagnostic
+	// moot (e.g. an exit node that gained shape=Msquare no longer needs a
+	// provider). Only report the ones that survive re-validation.
+	var skipped []validate.Diagnostic
+	for _, d := range res.Skipped {
+		if containsDiagnostic(remaining, d) {
+			skipped = append(skipped, d)
+		}
+	}
+
+	written := false
+	if write && diff != "" {
+		mode := os.FileMode(0o644)
+		if st, err := os.Stat(graphPath); err == nil {
+			mode = st.Mode().Perm()
+		}
+		if err := os.WriteFile(graphPath, res.Source, mode); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		written = true
+	}
+
+	if jsonOutput {
+		out := fixValidateResult{
+			File:      graphPath,
+			Applied:   res.Applied,
+			Skipped:   skipped,
+			Remaining: remaining,
+			Diff:      diff,
+			Written:   written,
+		}
+		if out.Applied == nil {
+			out.Applied = []validate.AppliedFix{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintln(os.Stderr, "json encode:", err)
+			os.Exit(1)
+		}
+	} else {
+		fmt.Print(diff)
+		for _, a := range res.Applied {
+			fmt.Fprintf(os.Stderr, "fixed: %s (%s)\n", a.Description, a.Diagnostic.Rule)
+		}
+		for _, d := range skipped {
+			fmt.Fprintf(os.Stderr, "not fixed: %s%s (%s)\n", deepDiagnosticSubject(d), d.Message, d.Rule)
+		}
+		for _, d := range remaining {
+			if d.Severity == validate.SeverityError {
+				fmt.Fprintf(os.Stderr, "%s: %s%s (%s)\n", d.Severity, deepDiagnosticSubject(d), d.Message, d.Rule)
+			}
+		}
+		switch {
+		case diff == "":
+			fmt.Fprintf(os.Stderr, "no automatic fixes for %s\n", graphPath)
+		case written:
+			fmt.Fprintf(os.Stderr, "wrote %s (%d fixes)\n", graphPath, len(res.Applied))
+		default:
+			fmt.Fprintln(os.Stderr, "re-run with --write to apply")
+		}
+	}
+
+	if prepErr != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func containsDiagnostic(diags []validate.Diagnostic, want validate.Diagnostic) bool {
+	for _, d := range diags {
+		if d.Rule == want.Rule && d.NodeID == want.NodeID && d.EdgeFrom == want.EdgeFrom && d.EdgeTo == want.EdgeTo {
+			return true
+		}
+	}
+	return false
+}
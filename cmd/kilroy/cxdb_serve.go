@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/danshapiro/kilroy/internal/attractor/engine"
+	"github.com/danshapiro/kilroy/internal/cxdb"
+)
+
+func cxdbCmd(args []string) {
+	if len(args) < 1 {
+		usage()
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "serve":
+		cxdbServe(args[1:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+// cxdbServe runs the built-in CXDB-compatible store. Address defaults come
+// from the variables cxdb.autostart exports, so `kilroy cxdb serve` can be
+// used directly as cxdb.autostart.command.
+func cxdbServe(args []string) {
+	dataDir := engine.DefaultCXDBDataDir()
+	httpAddr := "127.0.0.1:9010"
+	binaryAddr := "127.0.0.1:9009"
+	if v := strings.TrimSpace(os.Getenv("KILROY_CXDB_HTTP_BASE_URL")); v != "" {
+		if u, err := url.Parse(v); err == nil && u.Host != "" {
+			httpAddr = u.Host
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("KILROY_CXDB_BINARY_ADDR")); v != "" {
+		binaryAddr = v
+	}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--data-dir":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--data-dir requires a value")
+				os.Exit(1)
+			}
+			dataDir = args[i]
+		case "--http-addr":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--http-addr requires a value")
+				os.Exit(1)
+			}
+			httpAddr = args[i]
+		case "--binary-addr":
+			i++
+			if i >= len(args) {
+				fmt.Fprintln(os.Stderr, "--binary-addr requires a value")
+				os.Exit(1)
+			}
+			binaryAddr = args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "unknown arg: %s\n", args[i])
+			os.Exit(1)
+		}
+	}
+
+	ls, err := cxdb.StartLocal(dataDir, httpAddr, binaryAddr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("cxdb serving %s (http=%s binary=%s)\n", dataDir, ls.HTTPBaseURL, ls.BinaryAddr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	if err := ls.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
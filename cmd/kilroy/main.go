@@ -89,6 +89,8 @@ func main() {
 		os.Exit(0)
 	case "attractor":
 		attractor(args[1:])
+	case "cxdb":
+		cxdbCmd(args[1:])
 	default:
 		usage()
 		os.Exit(1)
@@ -167,18 +169,25 @@ func usage() {
 	fmt.Fprintln(os.Stderr, "  kilroy attractor resume --logs-root <dir>")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor resume --cxdb <http_base_url> --context-id <id>")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor resume --run-branch <attractor/run/...> [--repo <path>]")
+	fmt.Fprintln(os.Stderr, "  kilroy attractor fork --from <run-id|logs-root> --at <node-id> [--graph <file.dot>] [--config <run.yaml>] [--run-id <id>] [--logs-root <dir>]")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor status [--logs-root <dir> | --latest] [--json] [-v|--verbose] [--follow|-f] [--cxdb] [--raw] [--watch] [--interval <sec>]")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor stop --logs-root <dir> [--grace-ms <ms>] [--force]")
-	fmt.Fprintln(os.Stderr, "  kilroy attractor validate --graph <file.dot>")
-	fmt.Fprintln(os.Stderr, "  kilroy attractor validate --batch <file.dot> [<file.dot> ...] [--json]")
+	fmt.Fprintln(os.Stderr, "  kilroy attractor attach --logs-root <dir> [--node <id>] [--read-only] [--print]")
+	fmt.Fprintln(os.Stderr, "  kilroy attractor classify --logs-root <dir> [--config <run.yaml>] [--changed] [--json]")
+	fmt.Fprintln(os.Stderr, "  kilroy attractor estimate --graph <file.dot> [--config <run.yaml>] [--history <n>] [--json]")
+	fmt.Fprintln(os.Stderr, "  kilroy attractor validate --graph <file.dot> [--deep] [--fix [--write]] [--json]")
+	fmt.Fprintln(os.Stderr, "  kilroy attractor validate --batch <file.dot> [<file.dot> ...] [--deep] [--json]")
+	fmt.Fprintln(os.Stderr, "  kilroy attractor simulate --graph <file.dot> [--script <outcomes.yaml>] [--input <path|json>] [--json]")
+	fmt.Fprintln(os.Stderr, "  kilroy attractor fmt [--check] <file.dot> [<file.dot> ...]")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor ingest [--output <file.dot>] [--model <model>] [--skill <skill.md>] [--repo <path>] [--max-turns <n>] <requirements>")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor serve [--addr <host:port>]")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor modeldb suggest [--refresh] [--ttl <duration>] [--provider <name>]")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor review --graph <file.dot> [--output <file>] [--json] [--max-turns <n>]")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor runs list [--json] [--label KEY=VALUE] [--status STATUS] [--graph PATTERN] [--limit N]")
-	fmt.Fprintln(os.Stderr, "  kilroy attractor runs show (<id-or-prefix> | --latest [--label KEY=VALUE]) [--json] [--outputs] [--print <file>]")
+	fmt.Fprintln(os.Stderr, "  kilroy attractor runs show (<id-or-prefix> | --latest [--label KEY=VALUE]) [--json] [--outputs] [--timeline] [--print <file>]")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor runs wait (<id-or-prefix> | --latest [--label KEY=VALUE]) [--timeout <duration>] [--interval <duration>] [--json]")
 	fmt.Fprintln(os.Stderr, "  kilroy attractor runs prune [--before YYYY-MM-DD] [--older-than <duration>] [--graph PATTERN] [--label KEY=VALUE] [--orphans] [--dry-run | --yes]")
+	fmt.Fprintln(os.Stderr, "  kilroy cxdb serve [--data-dir <dir>] [--http-addr <host:port>] [--binary-addr <host:port>]")
 }
 
 func attractor(args []string) {
@@ -195,8 +204,20 @@ func attractor(args []string) {
 		attractorStatus(args[1:])
 	case "stop":
 		attractorStop(args[1:])
+	case "attach":
+		attractorAttach(args[1:])
+	case "classify":
+		attractorClassify(args[1:])
+	case "estimate":
+		attractorEstimate(args[1:])
 	case "validate":
 		attractorValidate(args[1:])
+	case "fork":
+		attractorFork(args[1:])
+	case "simulate":
+		attractorSimulate(args[1:])
+	case "fmt":
+		attractorFmt(args[1:])
 	case "ingest":
 		attractorIngest(args[1:])
 	case "serve":
@@ -833,6 +854,9 @@ func attractorValidate(args []string) {
 	var batchFiles []string
 	var batchMode bool
 	var jsonOutput bool
+	var deep bool
+	var fix bool
+	var write bool
 
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
@@ -852,6 +876,12 @@ func attractorValidate(args []string) {
 			}
 		case "--json":
 			jsonOutput = true
+		case "--deep":
+			deep = true
+		case "--fix":
+			fix = true
+		case "--write":
+			write = true
 		default:
 			// Allow positional file arguments when in batch mode context
 			// (e.g. when --batch was not yet seen but a .dot was given).
@@ -864,8 +894,17 @@ func attractorValidate(args []string) {
 		}
 	}
 
+	if write && !fix {
+		fmt.Fprintln(os.Stderr, "--write requires --fix")
+		os.Exit(1)
+	}
+	if fix && (batchMode || deep) {
+		fmt.Fprintln(os.Stderr, "--fix cannot be combined with --batch or --deep")
+		os.Exit(1)
+	}
+
 	if batchMode {
-		attractorValidateBatch(batchFiles, jsonOutput)
+		attractorValidateBatch(batchFiles, jsonOutput, deep)
 		return
 	}
 
@@ -886,14 +925,32 @@ func attractorValidate(args []string) {
 		fmt.Fprintf(os.Stderr, "WARNING: model catalog unavailable, model ID checks skipped: %v\n", catErr)
 		cat = nil
 	}
-	_, diags, err := engine.PrepareWithOptions(dotSource, engine.PrepareOptions{Catalog: cat})
+	g, diags, err := engine.PrepareWithOptions(dotSource, engine.PrepareOptions{Catalog: cat})
+	if fix && g != nil {
+		// Fixes target exactly the diagnostics that make validation fail, so
+		// run them before bailing out on errors.
+		attractorValidateFix(graphPath, dotSource, g, diags, cat, write, jsonOutput)
+		return
+	}
 	if err != nil {
+		if jsonOutput {
+			printValidateJSON(graphPath, diags, err)
+			os.Exit(1)
+		}
 		for _, d := range diags {
 			fmt.Fprintf(os.Stderr, "%s: %s (%s)\n", d.Severity, d.Message, d.Rule)
 		}
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	if deep {
+		attractorValidateDeep(graphPath, g, diags, jsonOutput)
+		return
+	}
+	if jsonOutput {
+		printValidateJSON(graphPath, diags, nil)
+		os.Exit(0)
+	}
 	fmt.Printf("ok: %s\n", filepath.Base(graphPath))
 	for _, d := range diags {
 		fmt.Printf("%s: %s (%s)\n", d.Severity, d.Message, d.Rule)
@@ -909,9 +966,42 @@ type batchFileResult struct {
 	ParseErr string                `json:"parse_error,omitempty"`
 }
 
+// newBatchFileResult splits diags by severity. prepErr is reported as the
+// parse error when no error diagnostic explains it.
+func newBatchFileResult(file string, diags []validate.Diagnostic, prepErr error) batchFileResult {
+	res := batchFileResult{
+		File:     file,
+		Errors:   []validate.Diagnostic{},
+		Warnings: []validate.Diagnostic{},
+	}
+	for _, d := range diags {
+		switch d.Severity {
+		case validate.SeverityError:
+			res.Errors = append(res.Errors, d)
+		case validate.SeverityWarning:
+			res.Warnings = append(res.Warnings, d)
+		}
+	}
+	if prepErr != nil && len(res.Errors) == 0 {
+		res.ParseErr = prepErr.Error()
+	}
+	return res
+}
+
+// printValidateJSON writes the single-file validate result in the same shape
+// as one --batch entry.
+func printValidateJSON(graphPath string, diags []validate.Diagnostic, prepErr error) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(newBatchFileResult(graphPath, diags, prepErr)); err != nil {
+		fmt.Fprintln(os.Stderr, "json encode:", err)
+		os.Exit(1)
+	}
+}
+
 // attractorValidateBatch runs validate against each file in files and emits a
 // summary.  Exit codes: 0 = all clean, 1 = any errors, 2 = warnings-only.
-func attractorValidateBatch(files []string, jsonOutput bool) {
+func attractorValidateBatch(files []string, jsonOutput bool, deep bool) {
 	if len(files) == 0 {
 		fmt.Fprintln(os.Stderr, "--batch requires at least one file path")
 		usage()
@@ -923,32 +1013,19 @@ func attractorValidateBatch(files []string, jsonOutput bool) {
 	anyWarnings := false
 
 	for _, f := range files {
-		res := batchFileResult{
-			File:     f,
-			Errors:   []validate.Diagnostic{},
-			Warnings: []validate.Diagnostic{},
-		}
 		dotSource, err := os.ReadFile(f)
 		if err != nil {
-			res.ParseErr = err.Error()
+			res := newBatchFileResult(f, nil, err)
 			anyErrors = true
 			results = append(results, res)
 			continue
 		}
-		_, diags, prepErr := engine.Prepare(dotSource)
-		// Collect diagnostics even when Prepare returns an error.
-		for _, d := range diags {
-			switch d.Severity {
-			case validate.SeverityError:
-				res.Errors = append(res.Errors, d)
-			case validate.SeverityWarning:
-				res.Warnings = append(res.Warnings, d)
-			}
-		}
-		if prepErr != nil && len(res.Errors) == 0 {
-			// Prepare returned a fatal error that produced no diagnostic; surface it.
-			res.ParseErr = prepErr.Error()
+		g, diags, prepErr := engine.Prepare(dotSource)
+		if deep && prepErr == nil {
+			diags = append(diags, validate.AnalyzeDeep(g).Diagnostics...)
 		}
+		// Collect diagnostics even when Prepare returns an error.
+		res := newBatchFileResult(f, diags, prepErr)
 		if len(res.Errors) > 0 || res.ParseErr != "" {
 			anyErrors = true
 		}
@@ -173,3 +173,27 @@ func testdataBatchFile(t *testing.T, name string) string {
 	}
 	return p
 }
+
+// TestAttractorValidate_JSONOutput verifies that --json without --batch or
+// --deep emits a single result object, for failing graphs too.
+func TestAttractorValidate_JSONOutput(t *testing.T) {
+	bin := buildKilroyBinary(t)
+	errFile := testdataBatchFile(t, "has_errors.dot")
+
+	code, out := runKilroy(t, bin, "attractor", "validate", "--graph", errFile, "--json")
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d\n%s", code, out)
+	}
+	var result struct {
+		File     string `json:"file"`
+		Errors   []any  `json:"errors"`
+		Warnings []any  `json:"warnings"`
+		ParseErr string `json:"parse_error"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("JSON parse failed: %v\nOutput:\n%s", err, out)
+	}
+	if result.File != errFile || (len(result.Errors) == 0 && result.ParseErr == "") {
+		t.Fatalf("expected errors for %s, got %+v", errFile, result)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAttractorFmt_CheckThenWrite verifies --check reports (and exits 1 for) a
+// non-canonical graph without touching it, and plain fmt rewrites it.
+func TestAttractorFmt_CheckThenWrite(t *testing.T) {
+	bin := buildKilroyBinary(t)
+	path := filepath.Join(t.TempDir(), "pipeline.dot")
+	src := "digraph G {\n    start [shape=Mdiamond]; exit [shape=Msquare]  // ends\n    start -> exit\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out := runKilroy(t, bin, "attractor", "fmt", "--check", path)
+	if code != 1 || !strings.Contains(out, "+  exit [shape=Msquare] // ends") {
+		t.Fatalf("expected diff and exit 1 from --check, got %d\n%s", code, out)
+	}
+	if b, _ := os.ReadFile(path); string(b) != src {
+		t.Fatalf("--check modified the file:\n%s", b)
+	}
+
+	code, out = runKilroy(t, bin, "attractor", "fmt", path)
+	if code != 0 {
+		t.Fatalf("fmt exit %d\n%s", code, out)
+	}
+	want := "digraph G {\n  start [shape=Mdiamond]\n  exit [shape=Msquare] // ends\n  start -> exit\n}\n"
+	if b, _ := os.ReadFile(path); string(b) != want {
+		t.Fatalf("formatted file:\n%s\nwant:\n%s", b, want)
+	}
+
+	code, out = runKilroy(t, bin, "attractor", "fmt", "--check", path)
+	if code != 0 || out != "" {
+		t.Fatalf("canonical file should pass --check silently, got %d\n%s", code, out)
+	}
+}
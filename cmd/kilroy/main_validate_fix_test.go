@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fixableGraph = `digraph G {
+  // The stylesheet names a single provider.
+  graph [model_stylesheet="#work { llm_provider: openai; llm_model: gpt-5.4; }"]
+  start
+  exit [shape=Msquare]
+  work [prompt="Do the work. Write $KILROY_STAGE_STATUS_PATH or $KILROY_STAGE_STATUS_FALLBACK_PATH."]
+  review [prompt="Review. Write $KILROY_STAGE_STATUS_PATH or $KILROY_STAGE_STATUS_FALLBACK_PATH."]
+  start -> work -> review
+  review -> exit [condition="outcome=success"]
+  review -> work
+}
+`
+
+// TestAttractorValidateFix_DiffThenWrite verifies --fix prints a diff without
+// touching the file, and --write applies it so the graph validates cleanly.
+func TestAttractorValidateFix_DiffThenWrite(t *testing.T) {
+	bin := buildKilroyBinary(t)
+	path := filepath.Join(t.TempDir(), "pipeline.dot")
+	if err := os.WriteFile(path, []byte(fixableGraph), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out := runKilroy(t, bin, "attractor", "validate", "--graph", path, "--fix")
+	if code != 0 {
+		t.Fatalf("expected exit code 0 (fixes resolve all errors), got %d\n%s", code, out)
+	}
+	for _, want := range []string{"-  start\n", "+  start [shape=Mdiamond]\n", "+  review [prompt=", "llm_provider=openai]", "re-run with --write"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out)
+		}
+	}
+	if b, _ := os.ReadFile(path); string(b) != fixableGraph {
+		t.Fatalf("--fix without --write modified the file:\n%s", b)
+	}
+
+	code, out = runKilroy(t, bin, "attractor", "validate", "--graph", path, "--fix", "--write")
+	if code != 0 {
+		t.Fatalf("expected exit code 0 on --write, got %d\n%s", code, out)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "// The stylesheet names a single provider.") {
+		t.Fatalf("comment lost on write:\n%s", b)
+	}
+
+	code, out = runKilroy(t, bin, "attractor", "validate", "--graph", path)
+	if code != 0 || strings.Contains(out, "llm_provider_required") || strings.Contains(out, "start_exit_shape") {
+		t.Fatalf("fixed graph still reports fixed rules (exit %d):\n%s", code, out)
+	}
+}
+
+func TestAttractorValidateFix_WriteRequiresFix(t *testing.T) {
+	bin := buildKilroyBinary(t)
+	code, out := runKilroy(t, bin, "attractor", "validate", "--graph", "x.dot", "--write")
+	if code != 1 || !strings.Contains(out, "--write requires --fix") {
+		t.Fatalf("expected --write requires --fix, got %d\n%s", code, out)
+	}
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+// CustomTool is a project-specific tool backed by a shell command. The
+// command receives the call's arguments as a JSON object on stdin (also in
+// KILROY_TOOL_ARGS); its stdout and stderr are the tool result, and a
+// non-zero exit marks the result as an error.
+type CustomTool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema for the arguments; calls are validated
+	// against it before the command runs. Nil accepts an empty object.
+	Parameters map[string]any
+	Command    string
+	// TimeoutMS overrides the session's default command timeout.
+	TimeoutMS int
+}
+
+func customTool(s *Session, ct CustomTool) RegisteredTool {
+	return RegisteredTool{
+		Definition: llm.ToolDefinition{
+			Name:        ct.Name,
+			Description: ct.Description,
+			Parameters:  ct.Parameters,
+		},
+		Exec: func(ctx context.Context, env ExecutionEnvironment, args map[string]any) (any, error) {
+			if args == nil {
+				args = map[string]any{}
+			}
+			b, err := json.Marshal(args)
+			if err != nil {
+				return nil, err
+			}
+			timeout := s.cfg.DefaultCommandTimeoutMS
+			if ct.TimeoutMS > 0 {
+				timeout = ct.TimeoutMS
+			}
+			if s.cfg.MaxCommandTimeoutMS > 0 && timeout > s.cfg.MaxCommandTimeoutMS {
+				timeout = s.cfg.MaxCommandTimeoutMS
+			}
+			// ExecCommand has no stdin, so the shell feeds the arguments in.
+			cmd := "printf '%s' \"$KILROY_TOOL_ARGS\" | {\n" + ct.Command + "\n}"
+			res, err := env.ExecCommand(ctx, cmd, timeout, "", map[string]string{
+				"KILROY_TOOL_NAME": ct.Name,
+				"KILROY_TOOL_ARGS": string(b),
+			})
+			return formatExecOutput(res, fmt.Sprintf("[ERROR: %s timed out after %dms. Partial output is shown above.]", ct.Name, timeout)), err
+		},
+	}
+}
+
+// registerCustomTools registers cfg.CustomTools. A custom tool may not
+// shadow a built-in one.
+func registerCustomTools(reg *ToolRegistry, s *Session) ([]llm.ToolDefinition, error) {
+	var defs []llm.ToolDefinition
+	for _, ct := range s.cfg.CustomTools {
+		if _, exists := reg.Get(ct.Name); exists {
+			return nil, fmt.Errorf("custom tool %s: a built-in tool has that name", ct.Name)
+		}
+		if strings.TrimSpace(ct.Command) == "" {
+			return nil, fmt.Errorf("custom tool %s: command is empty", ct.Name)
+		}
+		t := customTool(s, ct)
+		if err := reg.Register(t); err != nil {
+			return nil, fmt.Errorf("custom tool %s: %w", ct.Name, err)
+		}
+		defs = append(defs, t.Definition)
+	}
+	return defs, nil
+}
+
+// restrictTools limits the registry to the allowed tool names. Every name
+// must be registered, so a typo does not silently leave a node without a
+// tool it was meant to have.
+// BuiltinToolNames lists every tool a session can register on its own,
+// across all provider profiles. MCP and custom tools are not included.
+func BuiltinToolNames() []string {
+	var defs []llm.ToolDefinition
+	for _, p := range []ProviderProfile{NewOpenAIProfile(""), NewCodexAppServerProfile(""), NewAnthropicProfile(""), NewGeminiProfile("")} {
+		defs = append(defs, p.ToolDefinitions()...)
+	}
+	defs = append(defs, defRunTests(), defUpdatePlan(), defSpawnAgents(), defMergeAgentResults(),
+		defGoToDefinition(""), defFindReferences(""), defWorkspaceSymbols(""), defDiagnostics(""), defRenameSymbol(""))
+	seen := map[string]bool{}
+	var out []string
+	for _, d := range defs {
+		if !seen[d.Name] {
+			seen[d.Name] = true
+			out = append(out, d.Name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func restrictTools(reg *ToolRegistry, allowed []string) error {
+	keep := map[string]bool{}
+	var unknown []string
+	for _, name := range allowed {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := reg.Get(name); !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		keep[name] = true
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("tools: unknown tool(s) %s (available: %s)", strings.Join(unknown, ", "), strings.Join(reg.Names(), ", "))
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for name := range reg.tools {
+		if !keep[name] {
+			delete(reg.tools, name)
+		}
+	}
+	return nil
+}
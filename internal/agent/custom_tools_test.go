@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+func TestCustomTools_ArgsOnStdinAndAllowList(t *testing.T) {
+	dir := t.TempDir()
+	lookup := CustomTool{
+		Name:        "query_fixture_db",
+		Description: "Query the fixture database",
+		Parameters: map[string]any{
+			"type":                 "object",
+			"properties":           map[string]any{"table": map[string]any{"type": "string"}},
+			"required":             []string{"table"},
+			"additionalProperties": false,
+		},
+		Command: `cat; echo; echo "tool=$KILROY_TOOL_NAME"`,
+	}
+	sess, err := NewSession(llm.NewClient(), NewOpenAIProfile("gpt-5.4"), NewLocalExecutionEnvironment(dir), SessionConfig{
+		CustomTools:  []CustomTool{lookup},
+		AllowedTools: []string{"read_file", "query_fixture_db"},
+	})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer sess.Close()
+
+	var names []string
+	for _, d := range sess.toolDefinitions() {
+		names = append(names, d.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"query_fixture_db", "read_file"}) {
+		t.Fatalf("offered tools = %v", names)
+	}
+
+	call := func(name, args string) ToolExecResult {
+		return sess.reg.ExecuteCall(context.Background(), sess.env, llm.ToolCallData{ID: "c1", Name: name, Arguments: json.RawMessage(args)})
+	}
+	res := call("query_fixture_db", `{"table":"users"}`)
+	if res.IsError || !strings.Contains(res.Output, `{"table":"users"}`) || !strings.Contains(res.Output, "tool=query_fixture_db") {
+		t.Fatalf("result = %+v", res)
+	}
+	if res = call("query_fixture_db", `{"rows":1}`); !res.IsError || !strings.Contains(res.Output, "schema validation failed") {
+		t.Fatalf("expected schema error, got %+v", res)
+	}
+	if res = call("shell", `{"command":"true"}`); !res.IsError {
+		t.Fatalf("shell should not be callable: %+v", res)
+	}
+}
+
+func TestCustomTools_RejectsUnknownAllowedAndShadowedNames(t *testing.T) {
+	env := NewLocalExecutionEnvironment(t.TempDir())
+	_, err := NewSession(llm.NewClient(), NewOpenAIProfile("gpt-5.4"), env, SessionConfig{AllowedTools: []string{"read_file", "grpe"}})
+	if err == nil || !strings.Contains(err.Error(), "unknown tool(s) grpe") {
+		t.Fatalf("expected unknown tool error, got %v", err)
+	}
+	_, err = NewSession(llm.NewClient(), NewOpenAIProfile("gpt-5.4"), env, SessionConfig{CustomTools: []CustomTool{{Name: "shell", Command: "true"}}})
+	if err == nil || !strings.Contains(err.Error(), "built-in tool") {
+		t.Fatalf("expected shadowing error, got %v", err)
+	}
+}
+
+func TestBuiltinToolNames_CoversEveryProfileAndSessionTool(t *testing.T) {
+	names := map[string]bool{}
+	for _, n := range BuiltinToolNames() {
+		names[n] = true
+	}
+	for _, want := range []string{"read_file", "apply_patch", "edit_file", "list_dir", "run_tests", "update_plan", "spawn_agents", "rename_symbol"} {
+		if !names[want] {
+			t.Errorf("BuiltinToolNames missing %s", want)
+		}
+	}
+}
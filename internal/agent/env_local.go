@@ -50,6 +50,12 @@ func NewLocalExecutionEnvironment(rootDir string) *LocalExecutionEnvironment {
 
 func (e *LocalExecutionEnvironment) WorkingDirectory() string { return e.RootDir }
 
+// WithRootDir returns a copy of the environment rooted at dir, with the same
+// environment policy. Used for isolated subagent workspaces.
+func (e *LocalExecutionEnvironment) WithRootDir(dir string) ExecutionEnvironment {
+	return NewLocalExecutionEnvironmentWithPolicy(dir, e.BaseEnv, e.StripEnvKeys)
+}
+
 func (e *LocalExecutionEnvironment) Platform() string {
 	switch runtime.GOOS {
 	case "darwin":
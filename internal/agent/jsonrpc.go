@@ -0,0 +1,277 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonrpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *jsonrpcError   `json:"error"`
+}
+
+// jsonrpcFraming delimits messages on the wire: MCP stdio servers use one
+// JSON object per line, language servers use Content-Length headers.
+type jsonrpcFraming struct {
+	write func(w io.Writer, body []byte) error
+	read  func(r *bufio.Reader) ([]byte, error)
+}
+
+var newlineFraming = jsonrpcFraming{
+	write: func(w io.Writer, body []byte) error {
+		_, err := w.Write(append(body, '\n'))
+		return err
+	},
+	read: func(r *bufio.Reader) ([]byte, error) {
+		for {
+			line, err := r.ReadBytes('\n')
+			line = bytes.TrimSpace(line)
+			if len(line) > 0 {
+				return line, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	},
+}
+
+var contentLengthFraming = jsonrpcFraming{
+	write: func(w io.Writer, body []byte) error {
+		if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+			return err
+		}
+		_, err := w.Write(body)
+		return err
+	},
+	read: func(r *bufio.Reader) ([]byte, error) {
+		length := -1
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return nil, err
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if line == "" {
+				break
+			}
+			if k, v, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(k), "Content-Length") {
+				n, err := strconv.Atoi(strings.TrimSpace(v))
+				if err != nil {
+					return nil, fmt.Errorf("bad Content-Length %q", v)
+				}
+				length = n
+			}
+		}
+		if length < 0 {
+			return nil, errors.New("message without Content-Length")
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, err
+		}
+		return body, nil
+	},
+}
+
+// jsonrpcConn is a JSON-RPC 2.0 client connection to a server process over
+// its stdio. It matches responses to pending requests; everything the
+// server initiates goes to handle.
+type jsonrpcConn struct {
+	name    string    // "mcp server", "language server"; used in errors
+	cmd     *exec.Cmd // nil when connected over pipes (tests)
+	w       io.WriteCloser
+	framing jsonrpcFraming
+	timeout time.Duration
+	// handle serves server-to-client requests and notifications. For a
+	// request, the returned result (or error, when non-nil) is the reply.
+	handle func(method string, params json.RawMessage, isRequest bool) (any, *jsonrpcError)
+	// cancel, when set, tells the server a request was abandoned.
+	cancel func(id int64, reason string)
+
+	writeMu   sync.Mutex
+	mu        sync.Mutex
+	nextID    int64
+	pending   map[int64]chan jsonrpcResponse
+	readErr   error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newJSONRPCConn(name string, framing jsonrpcFraming, timeout time.Duration, w io.WriteCloser) *jsonrpcConn {
+	return &jsonrpcConn{
+		name:    name,
+		w:       w,
+		framing: framing,
+		timeout: timeout,
+		pending: map[int64]chan jsonrpcResponse{},
+		done:    make(chan struct{}),
+	}
+}
+
+// start runs the read loop; call it once the handlers are set.
+func (c *jsonrpcConn) start(r io.Reader) {
+	go c.readLoop(bufio.NewReader(r))
+}
+
+func (c *jsonrpcConn) write(msg map[string]any) error {
+	msg["jsonrpc"] = "2.0"
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.framing.write(c.w, b)
+}
+
+func (c *jsonrpcConn) notify(method string, params any) error {
+	msg := map[string]any{"method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	return c.write(msg)
+}
+
+// request sends a request and waits for its response.
+func (c *jsonrpcConn) request(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	c.mu.Lock()
+	if c.readErr != nil {
+		err := c.readErr
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%s exited: %w", c.name, err)
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan jsonrpcResponse, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(map[string]any{"id": id, "method": method, "params": params}); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s: %s (code %d)", method, resp.Error.Message, resp.Error.Code)
+		}
+		return resp.Result, nil
+	case <-c.done:
+		return nil, fmt.Errorf("%s: %s exited", method, c.name)
+	case <-timer.C:
+		if c.cancel != nil {
+			c.cancel(id, "timeout")
+		}
+		return nil, fmt.Errorf("%s: timed out after %s", method, c.timeout)
+	case <-ctx.Done():
+		if c.cancel != nil {
+			c.cancel(id, "cancelled")
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (c *jsonrpcConn) readLoop(r *bufio.Reader) {
+	var err error
+	for {
+		var body []byte
+		if body, err = c.framing.read(r); err != nil {
+			break
+		}
+		c.dispatch(body)
+	}
+	c.mu.Lock()
+	c.readErr = err
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *jsonrpcConn) dispatch(body []byte) {
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+		jsonrpcResponse
+	}
+	if json.Unmarshal(body, &msg) != nil {
+		return
+	}
+	hasID := len(msg.ID) > 0 && string(msg.ID) != "null"
+	if msg.Method == "" {
+		if !hasID {
+			return
+		}
+		id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		ch := c.pending[id]
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- msg.jsonrpcResponse
+		}
+		return
+	}
+	var result any
+	var rpcErr *jsonrpcError
+	if c.handle != nil {
+		result, rpcErr = c.handle(msg.Method, msg.Params, hasID)
+	} else if hasID {
+		rpcErr = &jsonrpcError{Code: -32601, Message: "method not found: " + msg.Method}
+	}
+	if !hasID {
+		return
+	}
+	reply := map[string]any{"id": msg.ID, "result": result}
+	if rpcErr != nil {
+		reply = map[string]any{"id": msg.ID, "error": rpcErr}
+	}
+	// Reply off the read loop so a server blocked writing to us cannot
+	// deadlock against our write.
+	go func() { _ = c.write(reply) }()
+}
+
+// close closes the server's input and waits for it to exit, killing it
+// after a grace period. It is safe to call more than once.
+func (c *jsonrpcConn) close() {
+	c.closeOnce.Do(func() {
+		_ = c.w.Close()
+		if c.cmd == nil || c.cmd.Process == nil {
+			return
+		}
+		waited := make(chan struct{})
+		go func() {
+			_ = c.cmd.Wait()
+			close(waited)
+		}()
+		select {
+		case <-waited:
+		case <-time.After(3 * time.Second):
+			_ = c.cmd.Process.Kill()
+			<-waited
+		}
+	})
+}
@@ -0,0 +1,417 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// LanguageServerConfig describes a language server the LSP tools may spawn.
+type LanguageServerConfig struct {
+	// Language names the server in tool arguments and output (e.g. "go").
+	Language string
+	// Command is the server executable and its arguments; the server must
+	// speak LSP over stdio (gopls, rust-analyzer, typescript-language-server --stdio).
+	Command []string
+	// Extensions routes files to this server by suffix (".go").
+	Extensions []string
+	// LanguageID is sent in textDocument/didOpen; defaults to Language.
+	LanguageID            string
+	InitializationOptions map[string]any
+	// RequestTimeout bounds each request, including initialize. Default 60s.
+	RequestTimeout time.Duration
+	// DiagnosticsWait is how long the diagnostics tool waits for a file's
+	// diagnostics to be published. Default 5s.
+	DiagnosticsWait time.Duration
+}
+
+func (c LanguageServerConfig) handles(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range c.Extensions {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspOpenDoc struct {
+	version int
+	text    string
+}
+
+// lspClient is one running language server, speaking JSON-RPC with
+// Content-Length framing over the process's stdio.
+type lspClient struct {
+	*jsonrpcConn
+	cfg  LanguageServerConfig
+	root string
+
+	mu sync.Mutex
+	// diags holds the latest published diagnostics per URI; diagGen counts
+	// publishes so callers can wait for a fresh one.
+	diags   map[string][]lspDiagnostic
+	diagGen map[string]int
+	diagCh  chan struct{}
+	docs    map[string]*lspOpenDoc
+}
+
+// startLanguageServer spawns cfg.Command in root and runs the initialize
+// handshake.
+func startLanguageServer(ctx context.Context, cfg LanguageServerConfig, root string) (*lspClient, error) {
+	if len(cfg.Command) == 0 {
+		return nil, fmt.Errorf("language server %s: command is empty", cfg.Language)
+	}
+	cmd := exec.Command(cfg.Command[0], cfg.Command[1:]...)
+	cmd.Dir = root
+	cmd.Stderr = io.Discard
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start language server %s: %w", cfg.Language, err)
+	}
+	c := newLSPClient(cfg, root, stdout, stdin)
+	c.cmd = cmd
+	if err := c.initialize(ctx); err != nil {
+		c.close()
+		return nil, fmt.Errorf("language server %s: %w", cfg.Language, err)
+	}
+	return c, nil
+}
+
+func newLSPClient(cfg LanguageServerConfig, root string, r io.Reader, w io.WriteCloser) *lspClient {
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = 60 * time.Second
+	}
+	if cfg.DiagnosticsWait <= 0 {
+		cfg.DiagnosticsWait = 5 * time.Second
+	}
+	if strings.TrimSpace(cfg.LanguageID) == "" {
+		cfg.LanguageID = cfg.Language
+	}
+	c := &lspClient{
+		jsonrpcConn: newJSONRPCConn("language server", contentLengthFraming, cfg.RequestTimeout, w),
+		cfg:         cfg,
+		root:        root,
+		diags:       map[string][]lspDiagnostic{},
+		diagGen:     map[string]int{},
+		diagCh:      make(chan struct{}),
+		docs:        map[string]*lspOpenDoc{},
+	}
+	c.handle = c.serve
+	c.start(r)
+	return c
+}
+
+func (c *lspClient) initialize(ctx context.Context) error {
+	rootURI := pathToURI(c.root)
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"rootPath":  c.root,
+		"workspaceFolders": []map[string]any{
+			{"uri": rootURI, "name": filepath.Base(c.root)},
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"didSave": false},
+				"definition":         map[string]any{"linkSupport": true},
+				"references":         map[string]any{},
+				"rename":             map[string]any{"prepareSupport": false},
+				"publishDiagnostics": map[string]any{"relatedInformation": false},
+			},
+			"workspace": map[string]any{
+				"workspaceFolders": true,
+				"configuration":    true,
+				"symbol":           map[string]any{},
+				"workspaceEdit":    map[string]any{"documentChanges": true},
+			},
+		},
+	}
+	if len(c.cfg.InitializationOptions) > 0 {
+		params["initializationOptions"] = c.cfg.InitializationOptions
+	}
+	if _, err := c.request(ctx, "initialize", params); err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	return c.notify("initialized", map[string]any{})
+}
+
+// serve records published diagnostics and answers server-to-client
+// requests. Requests servers block on get empty results so they proceed
+// with defaults.
+func (c *lspClient) serve(method string, params json.RawMessage, _ bool) (any, *jsonrpcError) {
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var p struct {
+			URI         string          `json:"uri"`
+			Diagnostics []lspDiagnostic `json:"diagnostics"`
+		}
+		if json.Unmarshal(params, &p) != nil {
+			return nil, nil
+		}
+		c.mu.Lock()
+		c.diags[p.URI] = p.Diagnostics
+		c.diagGen[p.URI]++
+		close(c.diagCh)
+		c.diagCh = make(chan struct{})
+		c.mu.Unlock()
+	case "workspace/configuration":
+		var p struct {
+			Items []any `json:"items"`
+		}
+		_ = json.Unmarshal(params, &p)
+		return make([]any, len(p.Items)), nil
+	}
+	return nil, nil
+}
+
+// syncDocument opens path in the server, or sends its current content when
+// it changed on disk since the last sync (other tools edit files directly).
+func (c *lspClient) syncDocument(path string) (string, error) {
+	uri, _, err := c.sync(path)
+	return uri, err
+}
+
+// sync is syncDocument that also reports whether anything was sent.
+func (c *lspClient) sync(path string) (uri string, sent bool, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	text := string(b)
+	uri = pathToURI(path)
+	c.mu.Lock()
+	doc := c.docs[uri]
+	if doc != nil && doc.text == text {
+		c.mu.Unlock()
+		return uri, false, nil
+	}
+	if doc == nil {
+		c.docs[uri] = &lspOpenDoc{version: 1, text: text}
+		c.mu.Unlock()
+		return uri, true, c.notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": c.cfg.LanguageID, "version": 1, "text": text},
+		})
+	}
+	doc.version++
+	doc.text = text
+	version := doc.version
+	c.mu.Unlock()
+	return uri, true, c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": version},
+		"contentChanges": []map[string]any{{"text": text}},
+	})
+}
+
+// syncOpen brings every open document up to date before a request, so
+// cross-file answers (references, renames, diagnostics) reflect edits other
+// tools made since it was opened. Documents deleted from disk are closed.
+func (c *lspClient) syncOpen() error {
+	c.mu.Lock()
+	uris := make([]string, 0, len(c.docs))
+	for uri := range c.docs {
+		uris = append(uris, uri)
+	}
+	c.mu.Unlock()
+	sort.Strings(uris)
+	for _, uri := range uris {
+		path := uriToPath(uri)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			if err := c.closeDocument(uri); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, _, err := c.sync(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeDocument tells the server uri is no longer open and forgets its
+// diagnostics.
+func (c *lspClient) closeDocument(uri string) error {
+	c.mu.Lock()
+	delete(c.docs, uri)
+	delete(c.diags, uri)
+	c.mu.Unlock()
+	return c.notify("textDocument/didClose", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+	})
+}
+
+// diagnosticsFor syncs path and waits for the server to publish diagnostics
+// for it. An unchanged file that already has published diagnostics returns
+// them at once; otherwise the first set published within DiagnosticsWait
+// (or the stale one) is returned.
+func (c *lspClient) diagnosticsFor(ctx context.Context, path string) ([]lspDiagnostic, error) {
+	c.mu.Lock()
+	uri := pathToURI(path)
+	gen := c.diagGen[uri]
+	c.mu.Unlock()
+	_, sent, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+	// Edits to other files can change this one's diagnostics too.
+	if err := c.syncOpen(); err != nil {
+		return nil, err
+	}
+	if !sent && gen > 0 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.diags[uri], nil
+	}
+	deadline := time.NewTimer(c.cfg.DiagnosticsWait)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		fresh := c.diagGen[uri] > gen
+		diags := c.diags[uri]
+		wait := c.diagCh
+		c.mu.Unlock()
+		if fresh {
+			return diags, nil
+		}
+		select {
+		case <-wait:
+		case <-deadline.C:
+			return diags, nil
+		case <-c.done:
+			return diags, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// allDiagnostics returns every published diagnostic, keyed by URI.
+func (c *lspClient) allDiagnostics() map[string][]lspDiagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := map[string][]lspDiagnostic{}
+	for uri, d := range c.diags {
+		if len(d) > 0 {
+			out[uri] = append([]lspDiagnostic{}, d...)
+		}
+	}
+	return out
+}
+
+func (c *lspClient) close() {
+	if c == nil {
+		return
+	}
+	select {
+	case <-c.done:
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, _ = c.request(ctx, "shutdown", nil)
+		cancel()
+		_ = c.notify("exit", nil)
+	}
+	c.jsonrpcConn.close()
+}
+
+func pathToURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+	return u.String()
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	p := u.Path
+	// file:///C:/x on Windows.
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+// utf16Column converts a rune offset within line to the UTF-16 offset LSP
+// positions use.
+func utf16Column(line string, runeCol int) int {
+	n, i := 0, 0
+	for _, r := range line {
+		if i >= runeCol {
+			break
+		}
+		n += len(utf16.Encode([]rune{r}))
+		i++
+	}
+	return n
+}
+
+// byteOffset converts an LSP position to a byte offset in text, clamping to
+// the end of the line or text.
+func byteOffset(text string, pos lspPosition) int {
+	off := 0
+	for line := 0; line < pos.Line; line++ {
+		nl := strings.IndexByte(text[off:], '\n')
+		if nl < 0 {
+			return len(text)
+		}
+		off += nl + 1
+	}
+	units := 0
+	for off < len(text) && text[off] != '\n' && units < pos.Character {
+		r, size := utf8.DecodeRuneInString(text[off:])
+		units += len(utf16.Encode([]rune{r}))
+		off += size
+	}
+	return off
+}
@@ -0,0 +1,296 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+// fakeLanguageServer answers a small subset of LSP for one Go file.
+type fakeLanguageServer struct {
+	w       io.Writer
+	mu      sync.Mutex
+	methods []string
+	uri     string // last opened document
+	// renameAlso, when set, is a further document every rename edits.
+	renameAlso string
+}
+
+func (f *fakeLanguageServer) send(msg map[string]any) {
+	msg["jsonrpc"] = "2.0"
+	b, _ := json.Marshal(msg)
+	fmt.Fprintf(f.w, "Content-Length: %d\r\n\r\n%s", len(b), b)
+}
+
+func (f *fakeLanguageServer) serve(r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		length := 0
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if v, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+				length, _ = strconv.Atoi(v)
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(br, body); err != nil {
+			return
+		}
+		var msg struct {
+			ID     *int64          `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		_ = json.Unmarshal(body, &msg)
+		f.mu.Lock()
+		f.methods = append(f.methods, msg.Method)
+		f.mu.Unlock()
+		var p struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			NewName string `json:"newName"`
+		}
+		_ = json.Unmarshal(msg.Params, &p)
+		uri := p.TextDocument.URI
+		if uri == "" {
+			uri = f.uri
+		}
+		f.uri = uri
+		reply := func(result any) { f.send(map[string]any{"id": *msg.ID, "result": result}) }
+		loc := func(line, char int) map[string]any {
+			return map[string]any{"uri": uri, "range": map[string]any{
+				"start": map[string]any{"line": line, "character": char},
+				"end":   map[string]any{"line": line, "character": char + 5},
+			}}
+		}
+		switch msg.Method {
+		case "initialize":
+			// Servers may ask for configuration before answering.
+			f.send(map[string]any{"id": 99, "method": "workspace/configuration", "params": map[string]any{"items": []any{map[string]any{}}}})
+			reply(map[string]any{"capabilities": map[string]any{}})
+		case "textDocument/didOpen":
+			f.send(map[string]any{"method": "textDocument/publishDiagnostics", "params": map[string]any{
+				"uri": uri,
+				"diagnostics": []any{map[string]any{
+					"range":    loc(3, 1)["range"],
+					"severity": 1, "source": "compiler", "message": "undefined: missing",
+				}},
+			}})
+		case "textDocument/didChange":
+			f.send(map[string]any{"method": "textDocument/publishDiagnostics", "params": map[string]any{"uri": uri, "diagnostics": []any{}}})
+		case "textDocument/definition":
+			reply([]any{map[string]any{"targetUri": uri, "targetSelectionRange": loc(2, 5)["range"], "targetRange": loc(2, 0)["range"]}})
+		case "textDocument/references":
+			reply([]any{loc(3, 1), loc(2, 5)})
+		case "workspace/symbol":
+			reply([]any{map[string]any{"name": "hello", "kind": 12, "containerName": "main", "location": loc(2, 5)}})
+		case "textDocument/rename":
+			changes := map[string]any{uri: []any{
+				map[string]any{"range": loc(2, 5)["range"], "newText": p.NewName},
+				map[string]any{"range": loc(3, 1)["range"], "newText": p.NewName},
+			}}
+			if f.renameAlso != "" {
+				changes[f.renameAlso] = []any{map[string]any{"range": loc(0, 0)["range"], "newText": p.NewName}}
+			}
+			reply(map[string]any{"changes": changes})
+		case "shutdown":
+			reply(nil)
+		case "exit":
+			return
+		}
+	}
+}
+
+const lspTestSource = "package main\n\nfunc hello() {}\n\thello()\n"
+
+func newLSPTestSession(t *testing.T) (*Session, *ToolRegistry, *fakeLanguageServer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(lspTestSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := &Session{
+		cfg: SessionConfig{LanguageServers: []LanguageServerConfig{{
+			Language: "go", Command: []string{"gopls"}, Extensions: []string{".go"},
+			DiagnosticsWait: 2 * time.Second,
+		}}},
+		env: NewLocalExecutionEnvironment(dir),
+	}
+	reg := NewToolRegistry()
+	defs, err := registerLanguageServerTools(reg, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 5 {
+		t.Fatalf("defs = %d", len(defs))
+	}
+	fake := &fakeLanguageServer{}
+	s.lsp.start = func(ctx context.Context, cfg LanguageServerConfig, root string) (*lspClient, error) {
+		clientR, serverW := io.Pipe()
+		serverR, clientW := io.Pipe()
+		fake.w = serverW
+		go fake.serve(serverR)
+		c := newLSPClient(cfg, root, clientR, clientW)
+		if err := c.initialize(ctx); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	t.Cleanup(s.lsp.close)
+	return s, reg, fake, dir
+}
+
+func callLSPTool(t *testing.T, reg *ToolRegistry, name, args string) ToolExecResult {
+	t.Helper()
+	return reg.ExecuteCall(context.Background(), nil, llm.ToolCallData{ID: "c1", Name: name, Arguments: json.RawMessage(args)})
+}
+
+func TestLSPTools_NavigateAndDiagnose(t *testing.T) {
+	_, reg, _, _ := newLSPTestSession(t)
+
+	res := callLSPTool(t, reg, "go_to_definition", `{"file_path":"main.go","line":4,"symbol":"hello"}`)
+	if res.IsError || strings.TrimSpace(res.Output) != "main.go:3:6: func hello() {}" {
+		t.Fatalf("definition = %+v", res)
+	}
+
+	res = callLSPTool(t, reg, "find_references", `{"file_path":"main.go","line":3,"column":6}`)
+	if res.IsError || !strings.HasPrefix(res.Output, "2 reference(s)\nmain.go:3:6: func hello() {}\nmain.go:4:2: hello()") {
+		t.Fatalf("references = %+v", res)
+	}
+
+	res = callLSPTool(t, reg, "workspace_symbols", `{"query":"hel"}`)
+	if res.IsError || strings.TrimSpace(res.Output) != "function main.hello main.go:3:6" {
+		t.Fatalf("symbols = %+v", res)
+	}
+
+	res = callLSPTool(t, reg, "diagnostics", `{"file_path":"main.go"}`)
+	if res.IsError || strings.TrimSpace(res.Output) != "main.go:4:2: error: undefined: missing [compiler]" {
+		t.Fatalf("diagnostics = %+v", res)
+	}
+	res = callLSPTool(t, reg, "diagnostics", `{}`)
+	if res.IsError || !strings.Contains(res.Output, "undefined: missing") {
+		t.Fatalf("all diagnostics = %+v", res)
+	}
+
+	res = callLSPTool(t, reg, "go_to_definition", `{"file_path":"README.md","line":1}`)
+	if !res.IsError || !strings.Contains(res.Output, "no language server configured for .md files") {
+		t.Fatalf("unrouted file = %+v", res)
+	}
+}
+
+func TestLSPTools_RenameWritesEditsAndResyncs(t *testing.T) {
+	_, reg, fake, dir := newLSPTestSession(t)
+
+	res := callLSPTool(t, reg, "rename_symbol", `{"file_path":"main.go","line":3,"symbol":"hello","new_name":"greet"}`)
+	if res.IsError || !strings.HasPrefix(res.Output, "renamed to greet: 2 edit(s) in 1 file(s)") {
+		t.Fatalf("rename = %+v", res)
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	if string(b) != "package main\n\nfunc greet() {}\n\tgreet()\n" {
+		t.Fatalf("file after rename:\n%s", b)
+	}
+
+	// The edited file is re-sent before the next request.
+	res = callLSPTool(t, reg, "diagnostics", `{"file_path":"main.go"}`)
+	if res.IsError || strings.TrimSpace(res.Output) != "no diagnostics" {
+		t.Fatalf("diagnostics after rename = %+v", res)
+	}
+	fake.mu.Lock()
+	methods := strings.Join(fake.methods, ",")
+	fake.mu.Unlock()
+	if !strings.Contains(methods, "textDocument/didOpen,textDocument/rename,textDocument/didChange") {
+		t.Fatalf("methods = %s", methods)
+	}
+}
+
+func TestLSPTools_RenameRejectsEditsOutsideWorkspace(t *testing.T) {
+	_, reg, fake, dir := newLSPTestSession(t)
+	outside := filepath.Join(t.TempDir(), "other.go")
+	if err := os.WriteFile(outside, []byte(lspTestSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake.renameAlso = pathToURI(outside)
+
+	res := callLSPTool(t, reg, "rename_symbol", `{"file_path":"main.go","line":3,"symbol":"hello","new_name":"greet"}`)
+	if !res.IsError || !strings.Contains(res.Output, "outside the workspace; nothing was changed") {
+		t.Fatalf("rename = %+v", res)
+	}
+	for _, p := range []string{filepath.Join(dir, "main.go"), outside} {
+		if b, _ := os.ReadFile(p); string(b) != lspTestSource {
+			t.Fatalf("%s was modified:\n%s", p, b)
+		}
+	}
+}
+
+func TestLSPTools_ResyncsEveryOpenDocument(t *testing.T) {
+	_, reg, fake, dir := newLSPTestSession(t)
+	other := filepath.Join(dir, "other.go")
+	if err := os.WriteFile(other, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lastMethods := func(n int) string {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		return strings.Join(fake.methods[len(fake.methods)-n:], ",")
+	}
+
+	callLSPTool(t, reg, "diagnostics", `{"file_path":"other.go"}`)
+	if res := callLSPTool(t, reg, "go_to_definition", `{"file_path":"main.go","line":4,"symbol":"hello"}`); res.IsError {
+		t.Fatalf("definition = %+v", res)
+	}
+
+	// Another tool edits other.go; the next query about main.go re-sends it.
+	if err := os.WriteFile(other, []byte("package main\n\nvar x = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	callLSPTool(t, reg, "go_to_definition", `{"file_path":"main.go","line":4,"symbol":"hello"}`)
+	if got := lastMethods(2); got != "textDocument/didChange,textDocument/definition" {
+		t.Fatalf("methods = %s", got)
+	}
+
+	// A deleted document is closed.
+	if err := os.Remove(other); err != nil {
+		t.Fatal(err)
+	}
+	callLSPTool(t, reg, "go_to_definition", `{"file_path":"main.go","line":4,"symbol":"hello"}`)
+	if got := lastMethods(2); got != "textDocument/didClose,textDocument/definition" {
+		t.Fatalf("methods = %s", got)
+	}
+}
+
+func TestApplyTextEdits_UTF16Columns(t *testing.T) {
+	// "é" is one UTF-16 unit but two bytes; "😀" is two units.
+	text := "a := \"é😀x\"\n"
+	out, err := applyTextEdits(text, []lspTextEdit{{
+		Range:   lspRange{Start: lspPosition{Line: 0, Character: 9}, End: lspPosition{Line: 0, Character: 10}},
+		NewText: "y",
+	}})
+	if err != nil || out != "a := \"é😀y\"\n" {
+		t.Fatalf("out=%q err=%v", out, err)
+	}
+	if _, err := applyTextEdits("abc", []lspTextEdit{
+		{Range: lspRange{Start: lspPosition{Character: 0}, End: lspPosition{Character: 2}}},
+		{Range: lspRange{Start: lspPosition{Character: 1}, End: lspPosition{Character: 3}}},
+	}); err == nil {
+		t.Fatal("expected overlapping edits to fail")
+	}
+}
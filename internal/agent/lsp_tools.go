@@ -0,0 +1,651 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+// lspManager owns the session's language servers. Servers start on first
+// use and live until the session closes.
+type lspManager struct {
+	root    string
+	configs []LanguageServerConfig
+	start   func(ctx context.Context, cfg LanguageServerConfig, root string) (*lspClient, error)
+
+	mu       sync.Mutex
+	clients  map[string]*lspClient
+	startErr map[string]error
+	closed   bool
+}
+
+func newLSPManager(root string, configs []LanguageServerConfig) *lspManager {
+	return &lspManager{
+		root:     root,
+		configs:  configs,
+		start:    startLanguageServer,
+		clients:  map[string]*lspClient{},
+		startErr: map[string]error{},
+	}
+}
+
+func (m *lspManager) client(ctx context.Context, cfg LanguageServerConfig) (*lspClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, fmt.Errorf("session is closed")
+	}
+	if c := m.clients[cfg.Language]; c != nil {
+		select {
+		case <-c.done:
+			// Crashed; reap it and start a fresh one below.
+			c.close()
+			delete(m.clients, cfg.Language)
+		default:
+			return c, nil
+		}
+	}
+	// A server that failed to start is not retried on every call.
+	if err := m.startErr[cfg.Language]; err != nil {
+		return nil, err
+	}
+	c, err := m.start(ctx, cfg, m.root)
+	if err != nil {
+		m.startErr[cfg.Language] = err
+		return nil, err
+	}
+	m.clients[cfg.Language] = c
+	return c, nil
+}
+
+func (m *lspManager) clientForFile(ctx context.Context, path string) (*lspClient, error) {
+	for _, cfg := range m.configs {
+		if cfg.handles(path) {
+			return m.client(ctx, cfg)
+		}
+	}
+	return nil, fmt.Errorf("no language server configured for %s files (configured: %s)", filepath.Ext(path), m.languageList())
+}
+
+func (m *lspManager) languageList() string {
+	var parts []string
+	for _, cfg := range m.configs {
+		parts = append(parts, fmt.Sprintf("%s %s", cfg.Language, strings.Join(cfg.Extensions, ",")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (m *lspManager) running() []*lspClient {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*lspClient
+	for _, cfg := range m.configs {
+		if c := m.clients[cfg.Language]; c != nil {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (m *lspManager) close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.closed = true
+	clients := m.clients
+	m.clients = map[string]*lspClient{}
+	m.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *lspClient) {
+			defer wg.Done()
+			c.close()
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (m *lspManager) resolve(path string) string {
+	p := strings.TrimSpace(path)
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(m.root, p)
+}
+
+// contains reports whether path, after resolving symlinks, is inside the
+// workspace root.
+func (m *lspManager) contains(path string) bool {
+	root, err := filepath.EvalSymlinks(m.root)
+	if err != nil {
+		root = m.root
+	}
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// display renders a path relative to the workspace when it is inside it.
+func (m *lspManager) display(path string) string {
+	if rel, err := filepath.Rel(m.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// position resolves the 1-based line plus either a 1-based column or a
+// symbol name on that line to an LSP position.
+func lspPositionArg(path string, args map[string]any) (lspPosition, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return lspPosition{}, err
+	}
+	lines := strings.Split(string(b), "\n")
+	line := 0
+	if v, ok := args["line"].(float64); ok {
+		line = int(v)
+	}
+	if line < 1 || line > len(lines) {
+		return lspPosition{}, fmt.Errorf("line %d is out of range (file has %d lines)", line, len(lines))
+	}
+	text := strings.TrimRight(lines[line-1], "\r")
+	col := 0
+	if v, ok := args["column"].(float64); ok && int(v) > 0 {
+		col = int(v) - 1
+	} else if sym := argStr(args, "symbol"); sym != "" {
+		idx := strings.Index(text, sym)
+		if idx < 0 {
+			return lspPosition{}, fmt.Errorf("symbol %q not found on line %d: %s", sym, line, strings.TrimSpace(text))
+		}
+		col = len([]rune(text[:idx]))
+	} else {
+		col = len([]rune(text)) - len([]rune(strings.TrimLeft(text, " \t")))
+	}
+	return lspPosition{Line: line - 1, Character: utf16Column(text, col)}, nil
+}
+
+// parseLocations accepts Location, Location[] and LocationLink[] results.
+func parseLocations(raw json.RawMessage) []lspLocation {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var one lspLocation
+	if json.Unmarshal(raw, &one) == nil && one.URI != "" {
+		return []lspLocation{one}
+	}
+	var items []struct {
+		lspLocation
+		TargetURI            string    `json:"targetUri"`
+		TargetSelectionRange *lspRange `json:"targetSelectionRange"`
+		TargetRange          *lspRange `json:"targetRange"`
+	}
+	if json.Unmarshal(raw, &items) != nil {
+		return nil
+	}
+	out := make([]lspLocation, 0, len(items))
+	for _, it := range items {
+		switch {
+		case it.URI != "":
+			out = append(out, it.lspLocation)
+		case it.TargetURI != "" && it.TargetSelectionRange != nil:
+			out = append(out, lspLocation{URI: it.TargetURI, Range: *it.TargetSelectionRange})
+		case it.TargetURI != "" && it.TargetRange != nil:
+			out = append(out, lspLocation{URI: it.TargetURI, Range: *it.TargetRange})
+		}
+	}
+	return out
+}
+
+// formatLocations prints one "path:line:col: source line" entry per location.
+func (m *lspManager) formatLocations(locs []lspLocation) string {
+	files := map[string][]string{}
+	var b strings.Builder
+	for _, loc := range locs {
+		path := uriToPath(loc.URI)
+		lines, ok := files[path]
+		if !ok {
+			if data, err := os.ReadFile(path); err == nil {
+				lines = strings.Split(string(data), "\n")
+			}
+			files[path] = lines
+		}
+		fmt.Fprintf(&b, "%s:%d:%d", m.display(path), loc.Range.Start.Line+1, loc.Range.Start.Character+1)
+		if loc.Range.Start.Line < len(lines) {
+			fmt.Fprintf(&b, ": %s", strings.TrimSpace(lines[loc.Range.Start.Line]))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// positionRequest runs a textDocument request at the position named by args.
+func (m *lspManager) positionRequest(ctx context.Context, method string, args map[string]any, extra map[string]any) (json.RawMessage, error) {
+	path := m.resolve(argStr(args, "file_path"))
+	c, err := m.clientForFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := lspPositionArg(path, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.syncOpen(); err != nil {
+		return nil, err
+	}
+	uri, err := c.syncDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     pos,
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return c.request(ctx, method, params)
+}
+
+func (m *lspManager) goToDefinition(ctx context.Context, args map[string]any) (string, error) {
+	raw, err := m.positionRequest(ctx, "textDocument/definition", args, nil)
+	if err != nil {
+		return "", err
+	}
+	locs := parseLocations(raw)
+	if len(locs) == 0 {
+		return "no definition found", nil
+	}
+	return m.formatLocations(locs), nil
+}
+
+func (m *lspManager) findReferences(ctx context.Context, args map[string]any) (string, error) {
+	includeDecl, _ := args["include_declaration"].(bool)
+	raw, err := m.positionRequest(ctx, "textDocument/references", args, map[string]any{
+		"context": map[string]any{"includeDeclaration": includeDecl},
+	})
+	if err != nil {
+		return "", err
+	}
+	locs := parseLocations(raw)
+	if len(locs) == 0 {
+		return "no references found", nil
+	}
+	sort.SliceStable(locs, func(i, j int) bool {
+		if locs[i].URI != locs[j].URI {
+			return locs[i].URI < locs[j].URI
+		}
+		return locs[i].Range.Start.Line < locs[j].Range.Start.Line
+	})
+	return fmt.Sprintf("%d reference(s)\n", len(locs)) + m.formatLocations(locs), nil
+}
+
+var lspSymbolKinds = map[int]string{
+	1: "file", 2: "module", 3: "namespace", 4: "package", 5: "class", 6: "method", 7: "property",
+	8: "field", 9: "constructor", 10: "enum", 11: "interface", 12: "function", 13: "variable",
+	14: "constant", 15: "string", 16: "number", 17: "boolean", 18: "array", 19: "object",
+	20: "key", 21: "null", 22: "enum_member", 23: "struct", 24: "event", 25: "operator", 26: "type_parameter",
+}
+
+func (m *lspManager) workspaceSymbols(ctx context.Context, args map[string]any) (string, error) {
+	query := argStr(args, "query")
+	lang := strings.TrimSpace(argStr(args, "language"))
+	var b strings.Builder
+	found := 0
+	var errs []string
+	for _, cfg := range m.configs {
+		if lang != "" && !strings.EqualFold(cfg.Language, lang) {
+			continue
+		}
+		c, err := m.client(ctx, cfg)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := c.syncOpen(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", cfg.Language, err))
+			continue
+		}
+		raw, err := c.request(ctx, "workspace/symbol", map[string]any{"query": query})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", cfg.Language, err))
+			continue
+		}
+		var syms []struct {
+			Name          string `json:"name"`
+			Kind          int    `json:"kind"`
+			ContainerName string `json:"containerName"`
+			Location      struct {
+				URI   string    `json:"uri"`
+				Range *lspRange `json:"range"`
+			} `json:"location"`
+		}
+		if err := json.Unmarshal(raw, &syms); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: decode workspace/symbol: %v", cfg.Language, err))
+			continue
+		}
+		for _, s := range syms {
+			found++
+			kind := lspSymbolKinds[s.Kind]
+			if kind == "" {
+				kind = "symbol"
+			}
+			name := s.Name
+			if s.ContainerName != "" {
+				name = s.ContainerName + "." + s.Name
+			}
+			loc := m.display(uriToPath(s.Location.URI))
+			if s.Location.Range != nil {
+				loc = fmt.Sprintf("%s:%d:%d", loc, s.Location.Range.Start.Line+1, s.Location.Range.Start.Character+1)
+			}
+			fmt.Fprintf(&b, "%s %s %s\n", kind, name, loc)
+		}
+	}
+	if found == 0 {
+		if len(errs) > 0 {
+			return "", fmt.Errorf("%s", strings.Join(errs, "\n"))
+		}
+		return fmt.Sprintf("no symbols matching %q", query), nil
+	}
+	for _, e := range errs {
+		fmt.Fprintf(&b, "[ERROR] %s\n", e)
+	}
+	return b.String(), nil
+}
+
+var lspSeverities = map[int]string{1: "error", 2: "warning", 3: "info", 4: "hint"}
+
+func (m *lspManager) diagnostics(ctx context.Context, args map[string]any) (string, error) {
+	byURI := map[string][]lspDiagnostic{}
+	if p := strings.TrimSpace(argStr(args, "file_path")); p != "" {
+		path := m.resolve(p)
+		c, err := m.clientForFile(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		diags, err := c.diagnosticsFor(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		byURI[pathToURI(path)] = diags
+	} else {
+		running := m.running()
+		if len(running) == 0 {
+			return "no language server is running yet; pass file_path to check a file", nil
+		}
+		for _, c := range running {
+			_ = c.syncOpen() // best effort: fresh diagnostics arrive later
+			for uri, d := range c.allDiagnostics() {
+				byURI[uri] = append(byURI[uri], d...)
+			}
+		}
+	}
+	uris := make([]string, 0, len(byURI))
+	for uri := range byURI {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	var b strings.Builder
+	count := 0
+	for _, uri := range uris {
+		diags := byURI[uri]
+		sort.SliceStable(diags, func(i, j int) bool { return diags[i].Range.Start.Line < diags[j].Range.Start.Line })
+		for _, d := range diags {
+			count++
+			sev := lspSeverities[d.Severity]
+			if sev == "" {
+				sev = "error"
+			}
+			fmt.Fprintf(&b, "%s:%d:%d: %s: %s", m.display(uriToPath(uri)), d.Range.Start.Line+1, d.Range.Start.Character+1, sev, strings.TrimSpace(d.Message))
+			if d.Source != "" {
+				fmt.Fprintf(&b, " [%s]", d.Source)
+			}
+			b.WriteByte('\n')
+		}
+	}
+	if count == 0 {
+		return "no diagnostics", nil
+	}
+	return b.String(), nil
+}
+
+// renameSymbol applies the server's rename edits through env. Every edited
+// file must be inside the workspace; otherwise nothing is written.
+func (m *lspManager) renameSymbol(ctx context.Context, env ExecutionEnvironment, args map[string]any) (string, error) {
+	newName := strings.TrimSpace(argStr(args, "new_name"))
+	if newName == "" {
+		return "", fmt.Errorf("new_name is required")
+	}
+	raw, err := m.positionRequest(ctx, "textDocument/rename", args, map[string]any{"newName": newName})
+	if err != nil {
+		return "", err
+	}
+	var we struct {
+		Changes         map[string][]lspTextEdit `json:"changes"`
+		DocumentChanges []json.RawMessage        `json:"documentChanges"`
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", fmt.Errorf("language server returned no edits for the rename")
+	}
+	if err := json.Unmarshal(raw, &we); err != nil {
+		return "", fmt.Errorf("decode rename result: %w", err)
+	}
+	edits := map[string][]lspTextEdit{}
+	for uri, e := range we.Changes {
+		edits[uri] = append(edits[uri], e...)
+	}
+	for _, dc := range we.DocumentChanges {
+		var change struct {
+			Kind         string `json:"kind"`
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Edits []lspTextEdit `json:"edits"`
+		}
+		if err := json.Unmarshal(dc, &change); err != nil {
+			return "", fmt.Errorf("decode rename result: %w", err)
+		}
+		if change.Kind != "" {
+			return "", fmt.Errorf("rename needs a %s file operation, which is not supported; nothing was changed", change.Kind)
+		}
+		edits[change.TextDocument.URI] = append(edits[change.TextDocument.URI], change.Edits...)
+	}
+	if len(edits) == 0 {
+		return "", fmt.Errorf("language server returned no edits for the rename")
+	}
+
+	// Compute every file's new content before writing any, so a bad edit
+	// leaves the tree untouched.
+	type rewrite struct {
+		path  string
+		text  string
+		count int
+	}
+	var rewrites []rewrite
+	for uri, fileEdits := range edits {
+		path := uriToPath(uri)
+		if !m.contains(path) {
+			return "", fmt.Errorf("rename would edit %s, which is outside the workspace; nothing was changed", path)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		text, err := applyTextEdits(string(b), fileEdits)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", m.display(path), err)
+		}
+		rewrites = append(rewrites, rewrite{path: path, text: text, count: len(fileEdits)})
+	}
+	sort.Slice(rewrites, func(i, j int) bool { return rewrites[i].path < rewrites[j].path })
+	total := 0
+	var b strings.Builder
+	for _, r := range rewrites {
+		if _, err := env.WriteFile(r.path, r.text); err != nil {
+			return b.String(), err
+		}
+		total += r.count
+		fmt.Fprintf(&b, "%s: %d edit(s)\n", m.display(r.path), r.count)
+	}
+	return fmt.Sprintf("renamed to %s: %d edit(s) in %d file(s)\n", newName, total, len(rewrites)) + b.String(), nil
+}
+
+// applyTextEdits applies non-overlapping LSP edits, all positioned against
+// the original text.
+func applyTextEdits(text string, edits []lspTextEdit) (string, error) {
+	type span struct {
+		start, end int
+		newText    string
+	}
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		s, en := byteOffset(text, e.Range.Start), byteOffset(text, e.Range.End)
+		if en < s {
+			return "", fmt.Errorf("edit range ends before it starts")
+		}
+		spans = append(spans, span{s, en, e.NewText})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var b strings.Builder
+	prev := 0
+	for _, sp := range spans {
+		if sp.start < prev {
+			return "", fmt.Errorf("overlapping edits")
+		}
+		b.WriteString(text[prev:sp.start])
+		b.WriteString(sp.newText)
+		prev = sp.end
+	}
+	b.WriteString(text[prev:])
+	return b.String(), nil
+}
+
+// registerLanguageServerTools registers the LSP tools when language servers
+// are configured and returns their definitions.
+func registerLanguageServerTools(reg *ToolRegistry, s *Session) ([]llm.ToolDefinition, error) {
+	if len(s.cfg.LanguageServers) == 0 {
+		return nil, nil
+	}
+	s.lsp = newLSPManager(s.env.WorkingDirectory(), s.cfg.LanguageServers)
+	langs := s.lsp.languageList()
+	tools := []RegisteredTool{
+		{Definition: defGoToDefinition(langs), Exec: func(ctx context.Context, _ ExecutionEnvironment, args map[string]any) (any, error) {
+			return s.lsp.goToDefinition(ctx, args)
+		}},
+		{Definition: defFindReferences(langs), Exec: func(ctx context.Context, _ ExecutionEnvironment, args map[string]any) (any, error) {
+			return s.lsp.findReferences(ctx, args)
+		}},
+		{Definition: defWorkspaceSymbols(langs), Exec: func(ctx context.Context, _ ExecutionEnvironment, args map[string]any) (any, error) {
+			return s.lsp.workspaceSymbols(ctx, args)
+		}},
+		{Definition: defDiagnostics(langs), Exec: func(ctx context.Context, _ ExecutionEnvironment, args map[string]any) (any, error) {
+			return s.lsp.diagnostics(ctx, args)
+		}},
+		{Definition: defRenameSymbol(langs), Exec: func(ctx context.Context, env ExecutionEnvironment, args map[string]any) (any, error) {
+			if env == nil {
+				env = s.env
+			}
+			return s.lsp.renameSymbol(ctx, env, args)
+		}},
+	}
+	defs := make([]llm.ToolDefinition, 0, len(tools))
+	for _, t := range tools {
+		if err := reg.Register(t); err != nil {
+			return nil, err
+		}
+		defs = append(defs, t.Definition)
+	}
+	return defs, nil
+}
+
+func lspPositionProperties() map[string]any {
+	return map[string]any{
+		"file_path": map[string]any{"type": "string"},
+		"line":      map[string]any{"type": "integer", "description": "1-based line number."},
+		"column":    map[string]any{"type": "integer", "description": "1-based column. Optional when symbol is given."},
+		"symbol":    map[string]any{"type": "string", "description": "Identifier on the line; used to find the column when column is omitted."},
+	}
+}
+
+func defGoToDefinition(langs string) llm.ToolDefinition {
+	return llm.ToolDefinition{
+		Name:        "go_to_definition",
+		Description: "Find where the symbol at a position is defined, using the language server. Languages: " + langs + ".",
+		Parameters: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties":           lspPositionProperties(),
+			"required":             []string{"file_path", "line"},
+		},
+	}
+}
+
+func defFindReferences(langs string) llm.ToolDefinition {
+	props := lspPositionProperties()
+	props["include_declaration"] = map[string]any{"type": "boolean"}
+	return llm.ToolDefinition{
+		Name:        "find_references",
+		Description: "List all references to the symbol at a position, using the language server. Languages: " + langs + ".",
+		Parameters: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties":           props,
+			"required":             []string{"file_path", "line"},
+		},
+	}
+}
+
+func defWorkspaceSymbols(langs string) llm.ToolDefinition {
+	return llm.ToolDefinition{
+		Name:        "workspace_symbols",
+		Description: "Search the workspace for symbols (types, functions, methods, ...) by name. Languages: " + langs + ".",
+		Parameters: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"query":    map[string]any{"type": "string"},
+				"language": map[string]any{"type": "string", "description": "Limit the search to one configured language."},
+			},
+			"required": []string{"query"},
+		},
+	}
+}
+
+func defDiagnostics(langs string) llm.ToolDefinition {
+	return llm.ToolDefinition{
+		Name:        "diagnostics",
+		Description: "Report compiler/linter diagnostics for a file from the language server, or every diagnostic published so far when file_path is omitted. Languages: " + langs + ".",
+		Parameters: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"file_path": map[string]any{"type": "string"},
+			},
+		},
+	}
+}
+
+func defRenameSymbol(langs string) llm.ToolDefinition {
+	props := lspPositionProperties()
+	props["new_name"] = map[string]any{"type": "string"}
+	return llm.ToolDefinition{
+		Name:        "rename_symbol",
+		Description: "Rename the symbol at a position across the workspace using the language server, and write the edits to disk. Languages: " + langs + ".",
+		Parameters: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties":           props,
+			"required":             []string{"file_path", "line", "new_name"},
+		},
+	}
+}
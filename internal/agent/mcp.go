@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// mcpProtocolVersion is the MCP revision requested in initialize. Servers
+// answer with the version they speak; the subset used here (tools/list,
+// tools/call) is the same in every revision.
+const mcpProtocolVersion = "2025-06-18"
+
+// MCPServerConfig describes a local MCP server whose tools are offered to
+// the session. The server is launched over stdio when the session starts
+// and stopped when it closes.
+type MCPServerConfig struct {
+	// Name namespaces the server's tools: tool "search" of server "docs" is
+	// registered as docs__search.
+	Name string
+	// Command is the server executable and its arguments.
+	Command []string
+	// Env is added to the server's environment.
+	Env map[string]string
+	// Tools, when non-empty, limits which of the server's tools are
+	// registered (server-side names).
+	Tools []string
+	// RequestTimeout bounds initialize, tools/list and each tools/call.
+	// Default 60s.
+	RequestTimeout time.Duration
+	// OutputLimit applies to all of the server's tools; ToolOutputLimits
+	// overrides it per tool (server-side names).
+	OutputLimit      ToolOutputLimit
+	ToolOutputLimits map[string]ToolOutputLimit
+}
+
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+type mcpContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+	URI      string `json:"uri"`
+	Resource *struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"resource"`
+}
+
+type mcpCallResult struct {
+	Content           []mcpContent    `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent"`
+	IsError           bool            `json:"isError"`
+}
+
+// mcpClient is one running MCP server, speaking newline-delimited JSON-RPC
+// over the process's stdio.
+type mcpClient struct {
+	*jsonrpcConn
+	cfg MCPServerConfig
+}
+
+// startMCPServer spawns cfg.Command in dir and runs the initialize
+// handshake.
+func startMCPServer(ctx context.Context, cfg MCPServerConfig, dir string) (*mcpClient, error) {
+	if len(cfg.Command) == 0 {
+		return nil, fmt.Errorf("mcp server %s: command is empty", cfg.Name)
+	}
+	cmd := exec.Command(cfg.Command[0], cfg.Command[1:]...)
+	cmd.Dir = dir
+	cmd.Stderr = io.Discard
+	if len(cfg.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range cfg.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start mcp server %s: %w", cfg.Name, err)
+	}
+	c := newMCPClient(cfg, stdout, stdin)
+	c.cmd = cmd
+	if err := c.initialize(ctx); err != nil {
+		c.close()
+		return nil, fmt.Errorf("mcp server %s: %w", cfg.Name, err)
+	}
+	return c, nil
+}
+
+func newMCPClient(cfg MCPServerConfig, r io.Reader, w io.WriteCloser) *mcpClient {
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = 60 * time.Second
+	}
+	c := &mcpClient{
+		jsonrpcConn: newJSONRPCConn("mcp server", newlineFraming, cfg.RequestTimeout, w),
+		cfg:         cfg,
+	}
+	c.handle = c.serve
+	// Tell the server to stop working on abandoned requests.
+	c.cancel = func(id int64, reason string) {
+		_ = c.notify("notifications/cancelled", map[string]any{"requestId": id, "reason": reason})
+	}
+	c.start(r)
+	return c
+}
+
+// serve answers server-to-client requests. We advertise no client
+// capabilities, so only ping is answered.
+func (c *mcpClient) serve(method string, _ json.RawMessage, _ bool) (any, *jsonrpcError) {
+	if method != "ping" {
+		return nil, &jsonrpcError{Code: -32601, Message: "method not found: " + method}
+	}
+	return map[string]any{}, nil
+}
+
+func (c *mcpClient) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "kilroy", "version": "1"},
+	}
+	if _, err := c.request(ctx, "initialize", params); err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	return c.notify("notifications/initialized", nil)
+}
+
+// listTools pages through tools/list.
+func (c *mcpClient) listTools(ctx context.Context) ([]mcpTool, error) {
+	var out []mcpTool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		raw, err := c.request(ctx, "tools/list", params)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Tools      []mcpTool `json:"tools"`
+			NextCursor string    `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, fmt.Errorf("tools/list: %w", err)
+		}
+		out = append(out, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return out, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func (c *mcpClient) callTool(ctx context.Context, name string, args map[string]any) (mcpCallResult, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	raw, err := c.request(ctx, "tools/call", map[string]any{"name": name, "arguments": args})
+	if err != nil {
+		return mcpCallResult{}, err
+	}
+	var res mcpCallResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return mcpCallResult{}, fmt.Errorf("tools/call: %w", err)
+	}
+	return res, nil
+}
+
+// renderMCPResult turns a tools/call result into tool output text.
+func renderMCPResult(res mcpCallResult) string {
+	var parts []string
+	for _, item := range res.Content {
+		switch item.Type {
+		case "text":
+			parts = append(parts, item.Text)
+		case "image", "audio":
+			parts = append(parts, fmt.Sprintf("[%s %s, %d base64 bytes]", item.Type, item.MimeType, len(item.Data)))
+		case "resource":
+			if item.Resource != nil && item.Resource.Text != "" {
+				parts = append(parts, item.Resource.Text)
+			} else if item.Resource != nil {
+				parts = append(parts, "[resource "+item.Resource.URI+"]")
+			}
+		case "resource_link":
+			parts = append(parts, "[resource_link "+item.URI+"]")
+		}
+	}
+	if len(parts) == 0 && len(res.StructuredContent) > 0 {
+		return string(res.StructuredContent)
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+// TestMCPFixture_HelperProcess is a tiny stdio MCP server used by the tests
+// below. It is only active when launched by them.
+func TestMCPFixture_HelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_MCP_FIXTURE") != "1" {
+		return
+	}
+	out := bufio.NewWriter(os.Stdout)
+	send := func(msg map[string]any) {
+		msg["jsonrpc"] = "2.0"
+		b, _ := json.Marshal(msg)
+		out.Write(append(b, '\n'))
+		out.Flush()
+	}
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Cursor    string         `json:"cursor"`
+				Name      string         `json:"name"`
+				Arguments map[string]any `json:"arguments"`
+			} `json:"params"`
+		}
+		if json.Unmarshal(sc.Bytes(), &msg) != nil || len(msg.ID) == 0 {
+			continue
+		}
+		switch msg.Method {
+		case "initialize":
+			send(map[string]any{"id": msg.ID, "result": map[string]any{
+				"protocolVersion": mcpProtocolVersion,
+				"capabilities":    map[string]any{"tools": map[string]any{}},
+				"serverInfo":      map[string]any{"name": "fixture", "version": "0"},
+			}})
+		case "tools/list":
+			// Two pages, to exercise cursor handling.
+			if msg.Params.Cursor == "" {
+				send(map[string]any{"id": msg.ID, "result": map[string]any{
+					"tools": []any{map[string]any{
+						"name":        "echo",
+						"description": "Echo the text back",
+						"inputSchema": map[string]any{
+							"type":       "object",
+							"properties": map[string]any{"text": map[string]any{"type": "string"}},
+							"required":   []string{"text"},
+						},
+					}},
+					"nextCursor": "p2",
+				}})
+				continue
+			}
+			send(map[string]any{"id": msg.ID, "result": map[string]any{"tools": []any{
+				map[string]any{"name": "fail", "inputSchema": map[string]any{"type": "object"}},
+				map[string]any{"name": "pid", "inputSchema": map[string]any{"type": "object"}},
+			}}})
+		case "tools/call":
+			var res map[string]any
+			switch msg.Params.Name {
+			case "echo":
+				res = map[string]any{"content": []any{map[string]any{"type": "text", "text": fmt.Sprint(msg.Params.Arguments["text"])}}}
+			case "fail":
+				res = map[string]any{"isError": true, "content": []any{map[string]any{"type": "text", "text": "fixture failure"}}}
+			case "pid":
+				res = map[string]any{"content": []any{map[string]any{"type": "text", "text": fmt.Sprint(os.Getpid())}}}
+			}
+			send(map[string]any{"id": msg.ID, "result": res})
+		default:
+			send(map[string]any{"id": msg.ID, "error": map[string]any{"code": -32601, "message": "unknown method"}})
+		}
+	}
+	os.Exit(0)
+}
+
+func mcpFixtureConfig(t *testing.T) MCPServerConfig {
+	t.Helper()
+	return MCPServerConfig{
+		Name:    "fixture",
+		Command: []string{os.Args[0], "-test.run=TestMCPFixture_HelperProcess"},
+		Env:     map[string]string{"GO_WANT_MCP_FIXTURE": "1"},
+	}
+}
+
+func TestMCPTools_RegisteredNamespacedAndProxied(t *testing.T) {
+	cfg := mcpFixtureConfig(t)
+	cfg.ToolOutputLimits = map[string]ToolOutputLimit{"echo": {MaxChars: 40}}
+	sess, err := NewSession(llm.NewClient(), NewOpenAIProfile("gpt-5.4"), NewLocalExecutionEnvironment(t.TempDir()), SessionConfig{
+		MCPServers: []MCPServerConfig{cfg},
+	})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer sess.Close()
+
+	var names []string
+	for _, d := range sess.toolDefinitions() {
+		if strings.HasPrefix(d.Name, "fixture__") {
+			names = append(names, d.Name)
+		}
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"fixture__echo", "fixture__fail", "fixture__pid"}) {
+		t.Fatalf("mcp tools = %v", names)
+	}
+
+	call := func(name, args string) ToolExecResult {
+		return sess.reg.ExecuteCall(context.Background(), sess.env, llm.ToolCallData{ID: "c1", Name: name, Arguments: json.RawMessage(args)})
+	}
+	if res := call("fixture__echo", `{"text":"hello"}`); res.IsError || res.Output != "hello" {
+		t.Fatalf("echo = %+v", res)
+	}
+	if res := call("fixture__echo", `{}`); !res.IsError || !strings.Contains(res.FullOutput, "schema validation failed") {
+		t.Fatalf("expected schema error, got %+v", res)
+	}
+	long := strings.Repeat("x", 500)
+	if res := call("fixture__echo", `{"text":"`+long+`"}`); res.IsError || len(res.Output) >= 500 || !strings.Contains(res.FullOutput, long) {
+		t.Fatalf("expected truncated output, got %d chars", len(res.Output))
+	}
+	if res := call("fixture__fail", `{}`); !res.IsError || res.Output != "fixture failure" {
+		t.Fatalf("fail = %+v", res)
+	}
+
+	// A crashed server is restarted on its next call.
+	first := call("fixture__pid", `{}`).Output
+	sess.mcp.mu.Lock()
+	c := sess.mcp.clients["fixture"]
+	sess.mcp.mu.Unlock()
+	c.close()
+	<-c.done
+	second := call("fixture__pid", `{}`)
+	if second.IsError || second.Output == "" || second.Output == first {
+		t.Fatalf("expected a restarted server, got %q then %+v", first, second)
+	}
+}
+
+func TestMCPTools_ToolFilterAndStartFailure(t *testing.T) {
+	cfg := mcpFixtureConfig(t)
+	cfg.Tools = []string{"echo"}
+	sess, err := NewSession(llm.NewClient(), NewOpenAIProfile("gpt-5.4"), NewLocalExecutionEnvironment(t.TempDir()), SessionConfig{
+		MCPServers: []MCPServerConfig{cfg},
+	})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	_, hasEcho := sess.reg.Get("fixture__echo")
+	_, hasFail := sess.reg.Get("fixture__fail")
+	sess.Close()
+	if !hasEcho || hasFail {
+		t.Fatalf("tool filter not applied: echo=%v fail=%v", hasEcho, hasFail)
+	}
+
+	bad := MCPServerConfig{Name: "missing", Command: []string{"/nonexistent/mcp-server"}}
+	_, err = NewSession(llm.NewClient(), NewOpenAIProfile("gpt-5.4"), NewLocalExecutionEnvironment(t.TempDir()), SessionConfig{
+		MCPServers: []MCPServerConfig{bad},
+	})
+	if err == nil || !strings.Contains(err.Error(), "mcp server missing") {
+		t.Fatalf("expected start error, got %v", err)
+	}
+}
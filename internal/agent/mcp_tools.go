@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+// mcpManager owns the session's MCP servers. Servers start with the session
+// (their tools must be known up front) and live until it closes; one that
+// crashes is restarted on its next call.
+type mcpManager struct {
+	dir   string
+	start func(ctx context.Context, cfg MCPServerConfig, dir string) (*mcpClient, error)
+
+	mu      sync.Mutex
+	clients map[string]*mcpClient
+	closed  bool
+}
+
+func newMCPManager(dir string) *mcpManager {
+	return &mcpManager{dir: dir, start: startMCPServer, clients: map[string]*mcpClient{}}
+}
+
+func (m *mcpManager) client(ctx context.Context, cfg MCPServerConfig) (*mcpClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, fmt.Errorf("session is closed")
+	}
+	if c := m.clients[cfg.Name]; c != nil {
+		select {
+		case <-c.done:
+			// Reap the dead process and release its pipes before restarting.
+			c.close()
+			delete(m.clients, cfg.Name)
+		default:
+			return c, nil
+		}
+	}
+	c, err := m.start(ctx, cfg, m.dir)
+	if err != nil {
+		return nil, err
+	}
+	m.clients[cfg.Name] = c
+	return c, nil
+}
+
+func (m *mcpManager) close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.closed = true
+	clients := m.clients
+	m.clients = map[string]*mcpClient{}
+	m.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *mcpClient) {
+			defer wg.Done()
+			c.close()
+		}(c)
+	}
+	wg.Wait()
+}
+
+// mcpToolName namespaces a server's tool: <server>__<tool>, with characters
+// tool names may not contain replaced by '_'.
+func mcpToolName(server, tool string) string {
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+				return r
+			}
+			return '_'
+		}, strings.TrimSpace(s))
+	}
+	return clean(server) + "__" + clean(tool)
+}
+
+// registerMCPTools starts the configured MCP servers and registers their
+// tools. A server that fails to start fails the session; individual tools
+// that cannot be registered are skipped with a warning.
+func registerMCPTools(reg *ToolRegistry, s *Session) ([]llm.ToolDefinition, error) {
+	if p := s.cfg.mcpParent; p != nil {
+		// The parent's tools call its own manager, which outlives the
+		// subagent: Session.Close closes subagents first.
+		var defs []llm.ToolDefinition
+		for _, def := range p.mcpDefs {
+			if t, ok := p.reg.Get(def.Name); ok && reg.Register(t) == nil {
+				defs = append(defs, def)
+			}
+		}
+		return defs, nil
+	}
+	if len(s.cfg.MCPServers) == 0 {
+		return nil, nil
+	}
+	s.mcp = newMCPManager(s.env.WorkingDirectory())
+	var defs []llm.ToolDefinition
+	for _, cfg := range s.cfg.MCPServers {
+		ctx, cancel := context.WithTimeout(context.Background(), mcpStartupTimeout(cfg))
+		c, err := s.mcp.client(ctx, cfg)
+		var tools []mcpTool
+		if err == nil {
+			tools, err = c.listTools(ctx)
+		}
+		cancel()
+		if err != nil {
+			s.mcp.close()
+			return nil, fmt.Errorf("mcp server %s: %w", cfg.Name, err)
+		}
+		allowed := map[string]bool{}
+		for _, name := range cfg.Tools {
+			allowed[strings.TrimSpace(name)] = true
+		}
+		for _, tool := range tools {
+			if len(allowed) > 0 && !allowed[tool.Name] {
+				continue
+			}
+			t := mcpRegisteredTool(s, cfg, tool)
+			if _, exists := reg.Get(t.Definition.Name); exists {
+				s.emit(EventWarning, map[string]any{"message": fmt.Sprintf("mcp server %s: tool %s skipped: %s is already registered", cfg.Name, tool.Name, t.Definition.Name)})
+				continue
+			}
+			if err := reg.Register(t); err != nil {
+				s.emit(EventWarning, map[string]any{"message": fmt.Sprintf("mcp server %s: tool %s skipped: %v", cfg.Name, tool.Name, err)})
+				continue
+			}
+			defs = append(defs, t.Definition)
+		}
+	}
+	return defs, nil
+}
+
+func mcpStartupTimeout(cfg MCPServerConfig) time.Duration {
+	if cfg.RequestTimeout > 0 {
+		return 2 * cfg.RequestTimeout
+	}
+	return 2 * time.Minute
+}
+
+func mcpRegisteredTool(s *Session, cfg MCPServerConfig, tool mcpTool) RegisteredTool {
+	desc := strings.TrimSpace(tool.Description)
+	if desc == "" {
+		desc = fmt.Sprintf("%s tool from MCP server %s.", tool.Name, cfg.Name)
+	}
+	params := tool.InputSchema
+	if params == nil {
+		params = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	limit := cfg.OutputLimit
+	if l, ok := cfg.ToolOutputLimits[tool.Name]; ok {
+		limit = l
+	}
+	if limit.MaxChars > 0 && limit.Strategy == "" {
+		limit.Strategy = TruncHeadTail
+	}
+	return RegisteredTool{
+		Definition: llm.ToolDefinition{Name: mcpToolName(cfg.Name, tool.Name), Description: desc, Parameters: params},
+		Limit:      limit,
+		Exec: func(ctx context.Context, _ ExecutionEnvironment, args map[string]any) (any, error) {
+			c, err := s.mcp.client(ctx, cfg)
+			if err != nil {
+				return nil, err
+			}
+			res, err := c.callTool(ctx, tool.Name, args)
+			if err != nil {
+				return nil, err
+			}
+			out := renderMCPResult(res)
+			if res.IsError {
+				return out, fmt.Errorf("mcp tool %s reported an error", tool.Name)
+			}
+			return out, nil
+		},
+	}
+}
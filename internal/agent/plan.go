@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+// Plan step statuses accepted by update_plan.
+const (
+	PlanPending    = "pending"
+	PlanInProgress = "in_progress"
+	PlanCompleted  = "completed"
+)
+
+// PlanItem is one step of the checklist an agent keeps with update_plan.
+type PlanItem struct {
+	Step   string `json:"step"`
+	Status string `json:"status"`
+}
+
+// FormatPlan renders a plan as a checklist:
+//
+//	[x] write the parser
+//	[~] add tests
+//	[ ] update README
+func FormatPlan(items []PlanItem) string {
+	var b strings.Builder
+	for _, it := range items {
+		mark := "[ ]"
+		switch it.Status {
+		case PlanCompleted:
+			mark = "[x]"
+		case PlanInProgress:
+			mark = "[~]"
+		}
+		b.WriteString(mark + " " + it.Step + "\n")
+	}
+	return b.String()
+}
+
+// PlanProgress counts completed steps.
+func PlanProgress(items []PlanItem) (completed, total int) {
+	for _, it := range items {
+		if it.Status == PlanCompleted {
+			completed++
+		}
+	}
+	return completed, len(items)
+}
+
+// Plan returns the session's current checklist.
+func (s *Session) Plan() []PlanItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PlanItem(nil), s.plan...)
+}
+
+// updatePlanTool replaces the session's checklist. Each call sends the whole
+// plan, so a model can reorder, split or drop steps freely.
+func updatePlanTool(s *Session) RegisteredTool {
+	return RegisteredTool{
+		Definition: defUpdatePlan(),
+		Exec: func(ctx context.Context, env ExecutionEnvironment, args map[string]any) (any, error) {
+			raw, _ := args["plan"].([]any)
+			items := make([]PlanItem, 0, len(raw))
+			inProgress := 0
+			for i, r := range raw {
+				m, _ := r.(map[string]any)
+				it := PlanItem{Step: strings.TrimSpace(argStr(m, "step")), Status: argStr(m, "status")}
+				if it.Step == "" {
+					return nil, fmt.Errorf("plan[%d].step is empty", i)
+				}
+				switch it.Status {
+				case PlanPending, PlanCompleted:
+				case PlanInProgress:
+					inProgress++
+				default:
+					return nil, fmt.Errorf("plan[%d].status %q is not one of %s, %s, %s", i, it.Status, PlanPending, PlanInProgress, PlanCompleted)
+				}
+				items = append(items, it)
+			}
+			if inProgress > 1 {
+				return nil, fmt.Errorf("at most one step may be in_progress (got %d)", inProgress)
+			}
+
+			s.mu.Lock()
+			s.plan = items
+			s.mu.Unlock()
+			data := map[string]any{"plan": items}
+			if expl := strings.TrimSpace(argStr(args, "explanation")); expl != "" {
+				data["explanation"] = expl
+			}
+			s.emit(EventPlanUpdate, data)
+
+			done, total := PlanProgress(items)
+			return fmt.Sprintf("Plan updated (%d/%d completed)\n%s", done, total, FormatPlan(items)), nil
+		},
+	}
+}
+
+func defUpdatePlan() llm.ToolDefinition {
+	return llm.ToolDefinition{
+		Name: "update_plan",
+		Description: "Record your plan as a checklist and keep it current as you work. Send the full list every time; mark at most one step in_progress. " +
+			"The plan is kept if this stage is retried, so completed steps need not be redone.",
+		Parameters: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"explanation": map[string]any{"type": "string"},
+				"plan": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type":                 "object",
+						"additionalProperties": false,
+						"properties": map[string]any{
+							"step":   map[string]any{"type": "string"},
+							"status": map[string]any{"type": "string", "enum": []string{PlanPending, PlanInProgress, PlanCompleted}},
+						},
+						"required": []string{"step", "status"},
+					},
+				},
+			},
+			"required": []string{"plan"},
+		},
+	}
+}
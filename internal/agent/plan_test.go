@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+func TestUpdatePlan_ReplacesPlanAndEmitsEvent(t *testing.T) {
+	s := &Session{
+		events: make(chan SessionEvent, 4),
+		plan:   []PlanItem{{Step: "from last attempt", Status: PlanCompleted}},
+	}
+	reg := NewToolRegistry()
+	if err := reg.Register(updatePlanTool(s)); err != nil {
+		t.Fatal(err)
+	}
+	call := func(args string) ToolExecResult {
+		return reg.ExecuteCall(context.Background(), nil, llm.ToolCallData{ID: "c1", Name: "update_plan", Arguments: json.RawMessage(args)})
+	}
+
+	res := call(`{"explanation":"split parser work","plan":[{"step":"from last attempt","status":"completed"},{"step":"add tests","status":"in_progress"},{"step":"update README","status":"pending"}]}`)
+	want := "Plan updated (1/3 completed)\n[x] from last attempt\n[~] add tests\n[ ] update README\n"
+	if res.IsError || res.Output != want {
+		t.Fatalf("result = %+v", res)
+	}
+	wantPlan := []PlanItem{
+		{Step: "from last attempt", Status: PlanCompleted},
+		{Step: "add tests", Status: PlanInProgress},
+		{Step: "update README", Status: PlanPending},
+	}
+	if got := s.Plan(); !reflect.DeepEqual(got, wantPlan) {
+		t.Fatalf("plan = %+v", got)
+	}
+	ev := <-s.events
+	if ev.Kind != EventPlanUpdate || ev.Data["explanation"] != "split parser work" || !reflect.DeepEqual(ev.Data["plan"], wantPlan) {
+		t.Fatalf("event = %+v", ev)
+	}
+
+	res = call(`{"plan":[{"step":"a","status":"in_progress"},{"step":"b","status":"in_progress"}]}`)
+	if !res.IsError || !strings.Contains(res.Output, "at most one step may be in_progress") {
+		t.Fatalf("result = %+v", res)
+	}
+	res = call(`{"plan":[{"step":"a","status":"started"}]}`)
+	if !res.IsError || !strings.Contains(res.Output, "schema validation failed") {
+		t.Fatalf("result = %+v", res)
+	}
+	// The tool itself rejects bad statuses even when called without the
+	// schema check.
+	if _, err := updatePlanTool(s).Exec(context.Background(), nil, map[string]any{"plan": []any{map[string]any{"step": "a", "status": "started"}}}); err == nil || !strings.Contains(err.Error(), `status "started"`) {
+		t.Fatalf("err = %v", err)
+	}
+	if len(s.Plan()) != 3 {
+		t.Fatalf("rejected updates must not change the plan: %+v", s.Plan())
+	}
+}
@@ -25,9 +25,43 @@ type SessionConfig struct {
 	RepeatedErrorToolCallLimit     int
 	MaxSubagentDepth               int
 
+	// MaxParallelSubagents caps how many spawn_agents subagents run at
+	// once. Default 4. It does not cap disk use: every agent's workspace
+	// is kept until the session ends so its changes can be merged.
+	MaxParallelSubagents int
+
 	// ToolOutputLimits overrides default per-tool truncation behavior.
 	ToolOutputLimits map[string]ToolOutputLimit
 
+	// LanguageServers enables the LSP tools (go_to_definition,
+	// find_references, workspace_symbols, diagnostics, rename_symbol).
+	// Servers are spawned on first use and shut down with the session.
+	LanguageServers []LanguageServerConfig
+
+	// Plan seeds the update_plan checklist, e.g. with the plan a previous
+	// attempt of the same stage left behind.
+	Plan []PlanItem
+
+	// MCPServers are local MCP servers whose tools are registered as
+	// <server>__<tool>. They start with the session and stop when it closes.
+	MCPServers []MCPServerConfig
+
+	// mcpParent, set for subagents, shares that session's MCP tools and
+	// servers instead of starting MCPServers again.
+	mcpParent *Session
+
+	// Attachments (images, documents) are added to the first user message
+	// after its text. Later inputs are text only.
+	Attachments []llm.ContentPart
+
+	// CustomTools are project-specific command-backed tools registered next
+	// to the built-in ones.
+	CustomTools []CustomTool
+
+	// AllowedTools, when non-empty, restricts the session to the named tools
+	// (built-in or custom). Unknown names fail NewSession.
+	AllowedTools []string
+
 	// UserInstructionOverride is appended to the end of the system prompt (highest priority).
 	UserInstructionOverride string
 
@@ -81,6 +115,9 @@ func (c *SessionConfig) applyDefaults() {
 	if c.MaxSubagentDepth <= 0 {
 		c.MaxSubagentDepth = 1
 	}
+	if c.MaxParallelSubagents <= 0 {
+		c.MaxParallelSubagents = 4
+	}
 	if c.EnableLoopDetection == nil {
 		v := true
 		c.EnableLoopDetection = &v
@@ -106,6 +143,14 @@ type Session struct {
 	history []Turn
 
 	reg *ToolRegistry
+	// extraTools are registered on top of the profile's tools and offered
+	// to the model alongside them.
+	extraTools []llm.ToolDefinition
+	lsp        *lspManager
+	mcp        *mcpManager
+	mcpDefs    []llm.ToolDefinition
+	plan       []PlanItem
+	usage      llm.Usage // summed over all LLM responses
 
 	steeringQueue []string
 	followups     []string
@@ -136,6 +181,7 @@ func NewSession(client *llm.Client, profile ProviderProfile, env ExecutionEnviro
 		events:    make(chan SessionEvent, 256),
 		history:   []Turn{},
 		subagents: map[string]*subagent{},
+		plan:      append([]PlanItem(nil), cfg.Plan...),
 	}
 
 	// Snapshot environment context once per session (spec).
@@ -153,6 +199,35 @@ func NewSession(client *llm.Client, profile ProviderProfile, env ExecutionEnviro
 	if err := registerCoreTools(reg, s); err != nil {
 		return nil, err
 	}
+	lspDefs, err := registerLanguageServerTools(reg, s)
+	if err != nil {
+		return nil, err
+	}
+	s.extraTools = append(s.extraTools, lspDefs...)
+	for _, t := range []RegisteredTool{runTestsTool(s), updatePlanTool(s), spawnAgentsTool(s), mergeAgentResultsTool(s)} {
+		if err := reg.Register(t); err != nil {
+			return nil, err
+		}
+		s.extraTools = append(s.extraTools, t.Definition)
+	}
+	mcpDefs, err := registerMCPTools(reg, s)
+	if err != nil {
+		return nil, err
+	}
+	s.mcpDefs = mcpDefs
+	s.extraTools = append(s.extraTools, mcpDefs...)
+	customDefs, err := registerCustomTools(reg, s)
+	if err != nil {
+		s.mcp.close()
+		return nil, err
+	}
+	s.extraTools = append(s.extraTools, customDefs...)
+	if len(cfg.AllowedTools) > 0 {
+		if err := restrictTools(reg, cfg.AllowedTools); err != nil {
+			s.mcp.close()
+			return nil, err
+		}
+	}
 	// Allow SessionConfig to override default tool output limits (spec).
 	if len(cfg.ToolOutputLimits) > 0 {
 		reg.mu.Lock()
@@ -186,6 +261,22 @@ func NewSession(client *llm.Client, profile ProviderProfile, env ExecutionEnviro
 
 func (s *Session) Events() <-chan SessionEvent { return s.events }
 
+// toolDefinitions is the profile's tools plus any session-level extras,
+// narrowed to AllowedTools when set.
+func (s *Session) toolDefinitions() []llm.ToolDefinition {
+	defs := append(s.profile.ToolDefinitions(), s.extraTools...)
+	if len(s.cfg.AllowedTools) == 0 || s.reg == nil {
+		return defs
+	}
+	out := defs[:0]
+	for _, d := range defs {
+		if _, ok := s.reg.Get(d.Name); ok {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
 // SetReasoningEffort updates the reasoning effort used for future LLM calls.
 // Takes effect on the next request (spec).
 func (s *Session) SetReasoningEffort(effort string) {
@@ -232,6 +323,9 @@ func (s *Session) Close() {
 	s.closed = true
 	s.mu.Unlock()
 
+	s.closeSubagents()
+	s.lsp.close()
+	s.mcp.close()
 	s.emit(EventSessionEnd, map[string]any{})
 	close(s.events)
 }
@@ -424,11 +518,25 @@ func (s *Session) processOneInput(ctx context.Context, input string) (string, er
 	default:
 	}
 
-	s.emit(EventUserInput, map[string]any{"text": input})
-	s.appendTurn(TurnUserInput, llm.User(input))
+	msg := llm.User(input)
+	evData := map[string]any{"text": input}
+	s.mu.Lock()
+	attachments := s.cfg.Attachments
+	s.cfg.Attachments = nil
+	s.mu.Unlock()
+	if len(attachments) > 0 {
+		msg.Content = append(msg.Content, attachments...)
+		evData["attachments"] = len(attachments)
+	}
+	s.emit(EventUserInput, evData)
+	s.appendTurn(TurnUserInput, msg)
 
 	docs, _ := LoadProjectDocs(s.env, s.profile.ProjectDocFiles()...)
 	sys := s.profile.BuildSystemPrompt(s.envInfo, docs)
+	if len(s.cfg.AllowedTools) > 0 {
+		// The profile's prompt lists its full toolset.
+		sys += "\nOnly these tools are available in this session: " + strings.Join(s.reg.Names(), ", ") + ".\n"
+	}
 	if strings.TrimSpace(s.cfg.UserInstructionOverride) != "" {
 		sys = sys + "\n\n" + strings.TrimSpace(s.cfg.UserInstructionOverride) + "\n"
 	}
@@ -473,7 +581,7 @@ func (s *Session) processOneInput(ctx context.Context, input string) (string, er
 			Model:    s.profile.Model(),
 			Provider: s.profile.ID(),
 			Messages: append([]llm.Message{llm.System(sys)}, history...),
-			Tools:    s.profile.ToolDefinitions(),
+			Tools:    s.toolDefinitions(),
 		}
 		if strings.TrimSpace(s.cfg.ReasoningEffort) != "" {
 			v := strings.TrimSpace(s.cfg.ReasoningEffort)
@@ -676,12 +784,19 @@ func (s *Session) processOneInput(ctx context.Context, input string) (string, er
 		txt := resp.Text()
 		emitAssistantTextStart()
 		s.appendTurn(TurnAssistant, resp.Message)
+		s.mu.Lock()
+		s.usage = s.usage.Add(resp.Usage)
+		s.mu.Unlock()
 		if !assistantTextDelta && strings.TrimSpace(txt) != "" {
 			s.emit(EventAssistantTextDelta, map[string]any{"delta": txt})
 		}
 		s.emit(EventAssistantTextEnd, map[string]any{
 			"text":            txt,
 			"tool_call_count": turnToolCallCount,
+			"provider":        resp.Provider,
+			"model":           resp.Model,
+			"input_tokens":    resp.Usage.InputTokens,
+			"output_tokens":   resp.Usage.OutputTokens,
 		})
 
 		if len(calls) == 0 {
@@ -905,6 +1020,43 @@ func argStr(args map[string]any, key string) string {
 	return fmt.Sprint(v)
 }
 
+// commandTimeoutMS applies a tool call's timeout_ms within the session's
+// command timeout bounds.
+func (s *Session) commandTimeoutMS(args map[string]any) int {
+	timeout := s.cfg.DefaultCommandTimeoutMS
+	if v, ok := args["timeout_ms"].(float64); ok && int(v) > 0 {
+		timeout = int(v)
+	}
+	if s.cfg.MaxCommandTimeoutMS > 0 && timeout > s.cfg.MaxCommandTimeoutMS {
+		timeout = s.cfg.MaxCommandTimeoutMS
+	}
+	return timeout
+}
+
+// formatExecOutput renders a command result as line-oriented tool output so
+// line truncation works as intended. timeoutNote is added when the command
+// timed out.
+func formatExecOutput(res ExecResult, timeoutNote string) string {
+	var b strings.Builder
+	if strings.TrimSpace(res.Stdout) != "" {
+		b.WriteString(res.Stdout)
+		if !strings.HasSuffix(res.Stdout, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	if strings.TrimSpace(res.Stderr) != "" {
+		b.WriteString(res.Stderr)
+		if !strings.HasSuffix(res.Stderr, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	if res.TimedOut && timeoutNote != "" {
+		b.WriteString(timeoutNote + "\n")
+	}
+	b.WriteString(fmt.Sprintf("exit_code=%d duration_ms=%d timed_out=%t\n", res.ExitCode, res.DurationMS, res.TimedOut))
+	return b.String()
+}
+
 func registerCoreTools(reg *ToolRegistry, s *Session) error {
 	// read_file
 	if err := reg.Register(RegisteredTool{
@@ -1013,34 +1165,9 @@ func registerCoreTools(reg *ToolRegistry, s *Session) error {
 		Definition: defShell(),
 		Exec: func(ctx context.Context, env ExecutionEnvironment, args map[string]any) (any, error) {
 			cmd := argStr(args, "command")
-			timeout := s.cfg.DefaultCommandTimeoutMS
-			if v, ok := args["timeout_ms"].(float64); ok && int(v) > 0 {
-				timeout = int(v)
-			}
-			if s.cfg.MaxCommandTimeoutMS > 0 && timeout > s.cfg.MaxCommandTimeoutMS {
-				timeout = s.cfg.MaxCommandTimeoutMS
-			}
+			timeout := s.commandTimeoutMS(args)
 			res, err := env.ExecCommand(ctx, cmd, timeout, "", nil)
-
-			// Return a line-oriented tool output so line truncation works as intended for shell output.
-			var b strings.Builder
-			if strings.TrimSpace(res.Stdout) != "" {
-				b.WriteString(res.Stdout)
-				if !strings.HasSuffix(res.Stdout, "\n") {
-					b.WriteString("\n")
-				}
-			}
-			if strings.TrimSpace(res.Stderr) != "" {
-				b.WriteString(res.Stderr)
-				if !strings.HasSuffix(res.Stderr, "\n") {
-					b.WriteString("\n")
-				}
-			}
-			if res.TimedOut {
-				b.WriteString(fmt.Sprintf("[ERROR: Command timed out after %dms. Partial output is shown above.\nYou can retry with a longer timeout by setting the timeout_ms parameter.]\n", timeout))
-			}
-			b.WriteString(fmt.Sprintf("exit_code=%d duration_ms=%d timed_out=%t\n", res.ExitCode, res.DurationMS, res.TimedOut))
-			return b.String(), err
+			return formatExecOutput(res, fmt.Sprintf("[ERROR: Command timed out after %dms. Partial output is shown above.\nYou can retry with a longer timeout by setting the timeout_ms parameter.]", timeout)), err
 		},
 	}); err != nil {
 		return err
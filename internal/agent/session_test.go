@@ -1176,6 +1176,42 @@ func TestSession_FollowUp_ProcessesAfterCompletion(t *testing.T) {
 	sess.Close()
 }
 
+func TestSession_Attachments_AddedToFirstUserMessageOnly(t *testing.T) {
+	dir := t.TempDir()
+	c := llm.NewClient()
+	f := &fakeAdapter{name: "openai"}
+	c.Register(f)
+
+	img := llm.ContentPart{Kind: llm.ContentImage, Image: &llm.ImageData{Data: []byte{1}, MediaType: "image/png"}}
+	sess, err := NewSession(c, NewOpenAIProfile("gpt-5.4"), NewLocalExecutionEnvironment(dir), SessionConfig{Attachments: []llm.ContentPart{img}})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	sess.FollowUp("again")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := sess.ProcessInput(ctx, "look"); err != nil {
+		t.Fatalf("ProcessInput: %v", err)
+	}
+	sess.Close()
+
+	var users []llm.Message
+	for _, m := range f.requests[len(f.requests)-1].Messages {
+		if m.Role == llm.RoleUser {
+			users = append(users, m)
+		}
+	}
+	if len(users) != 2 {
+		t.Fatalf("user messages: %+v", users)
+	}
+	if len(users[0].Content) != 2 || users[0].Content[0].Text != "look" || users[0].Content[1].Kind != llm.ContentImage {
+		t.Fatalf("first user message: %+v", users[0].Content)
+	}
+	if len(users[1].Content) != 1 {
+		t.Fatalf("follow-up carried attachments: %+v", users[1].Content)
+	}
+}
+
 func TestSession_LoopDetection_EmitsEventAndInjectsSteering(t *testing.T) {
 	dir := t.TempDir()
 	c := llm.NewClient()
@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Subagent isolation modes for spawn_agents.
+const (
+	IsolationAuto     = "auto"
+	IsolationWorktree = "worktree"
+	IsolationCopy     = "copy"
+)
+
+// rootableEnvironment is implemented by environments that can be re-rooted
+// at another directory, which isolated subagents need.
+type rootableEnvironment interface {
+	WithRootDir(dir string) ExecutionEnvironment
+}
+
+// subagentWorkspace is a private copy of the parent's working tree. Its
+// starting state is committed as base, so the subagent's changes are always
+// `git diff base` inside the workspace, whichever mode created it.
+type subagentWorkspace struct {
+	mode string
+	dir  string // workspace root; mirrors the repo top level (worktree) or the parent's directory (copy)
+	base string
+	env  ExecutionEnvironment
+
+	parent ExecutionEnvironment
+	// applyDir is where the workspace's patches apply in the parent, and
+	// applyPrefix is prepended to patch paths there (copy mode inside a repo).
+	applyDir    string
+	applyPrefix string
+	repoTop     string // parent repo top level; empty outside git
+}
+
+const subagentWorkspaceTimeoutMS = 300_000
+
+const diffSeparator = "--kilroy-subagent-diff--"
+
+// gitCommit commits with a fixed identity and without hooks or signing, so
+// workspace bookkeeping never depends on the user's git config.
+const gitCommit = "git -c user.name=kilroy -c user.email=kilroy@localhost -c commit.gpgsign=false commit -q --no-verify --allow-empty"
+
+func createSubagentWorkspace(ctx context.Context, parent ExecutionEnvironment, mode string) (*subagentWorkspace, error) {
+	re, ok := parent.(rootableEnvironment)
+	if !ok {
+		return nil, fmt.Errorf("execution environment does not support isolated subagent workspaces")
+	}
+	run := func(cmd, dir string) (string, error) {
+		res, err := parent.ExecCommand(ctx, cmd, subagentWorkspaceTimeoutMS, dir, nil)
+		if err != nil || res.ExitCode != 0 {
+			msg := strings.TrimSpace(res.Stderr)
+			if msg == "" && err != nil {
+				msg = err.Error()
+			}
+			return "", fmt.Errorf("%s", msg)
+		}
+		return strings.TrimSpace(res.Stdout), nil
+	}
+
+	wd := parent.WorkingDirectory()
+	top, prefix := "", ""
+	if out, err := run("git rev-parse --show-toplevel --show-prefix", wd); err == nil {
+		lines := strings.SplitN(out+"\n", "\n", 3)
+		top, prefix = strings.TrimSpace(lines[0]), strings.TrimSuffix(strings.TrimSpace(lines[1]), "/")
+	}
+	switch mode {
+	case "", IsolationAuto:
+		mode = IsolationCopy
+		if top != "" {
+			mode = IsolationWorktree
+		}
+	case IsolationWorktree:
+		if top == "" {
+			return nil, fmt.Errorf("isolation=worktree needs a git repository (use copy)")
+		}
+	case IsolationCopy:
+	default:
+		return nil, fmt.Errorf("unknown isolation %q (want auto|worktree|copy)", mode)
+	}
+
+	dir, err := os.MkdirTemp("", "kilroy-subagent-")
+	if err != nil {
+		return nil, err
+	}
+	ws := &subagentWorkspace{mode: mode, dir: dir, parent: parent, repoTop: top}
+	q := shellEscape(dir)
+	if mode == IsolationWorktree {
+		// Start from the parent's current state: tracked edits via a stash
+		// commit, untracked files copied over.
+		script := `set -e
+base=$(git stash create)
+[ -n "$base" ] || base=$(git rev-parse HEAD)
+git worktree add --detach -q ` + q + ` "$base"
+git ls-files -z --others --exclude-standard | tar --null -T - -cf - | tar -xf - -C ` + q + `
+cd ` + q + ` && git add -A && ` + gitCommit + ` -m "subagent base" && git rev-parse HEAD`
+		if ws.base, err = run(script, top); err != nil {
+			ws.remove()
+			return nil, fmt.Errorf("create subagent worktree: %w", err)
+		}
+		ws.applyDir = top
+		ws.env = re.WithRootDir(filepath.Join(dir, filepath.FromSlash(prefix)))
+	} else {
+		script := `set -e
+tar -cf - --exclude=.git . | tar -xf - -C ` + q + `
+cd ` + q + ` && git init -q && git add -A && ` + gitCommit + ` -m "subagent base" && git rev-parse HEAD`
+		if ws.base, err = run(script, wd); err != nil {
+			ws.remove()
+			return nil, fmt.Errorf("create subagent copy: %w", err)
+		}
+		ws.applyDir, ws.applyPrefix = wd, ""
+		if top != "" {
+			ws.applyDir, ws.applyPrefix = top, prefix
+		}
+		ws.env = re.WithRootDir(dir)
+	}
+	return ws, nil
+}
+
+// diff returns the workspace's changes since base, optionally limited to
+// paths (relative to the workspace root), and the changed file names.
+func (ws *subagentWorkspace) diff(ctx context.Context, paths []string) (patch string, files []string, err error) {
+	spec := ""
+	if len(paths) > 0 {
+		spec = " -- " + shellEscapeArgs(paths...)
+	}
+	// One round trip: file names, a separator, then the patch.
+	cmd := "git add -A && git diff --cached --name-only " + ws.base + spec +
+		" && echo " + diffSeparator + " && git diff --cached --binary --no-color " + ws.base + spec
+	res, err := ws.parent.ExecCommand(ctx, cmd, subagentWorkspaceTimeoutMS, ws.dir, nil)
+	if err != nil || res.ExitCode != 0 {
+		return "", nil, fmt.Errorf("git diff: %s", strings.TrimSpace(res.Stderr+" "+errString(err)))
+	}
+	names, patch, _ := strings.Cut(res.Stdout, diffSeparator+"\n")
+	for _, f := range strings.Split(names, "\n") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+	return patch, files, nil
+}
+
+// apply applies patch to the parent's working tree. git apply is atomic: on
+// conflict nothing is written.
+func (ws *subagentWorkspace) apply(ctx context.Context, patch string) error {
+	if strings.TrimSpace(patch) == "" {
+		return nil
+	}
+	f, err := os.CreateTemp("", "kilroy-subagent-*.patch")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.WriteString(patch); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	cmd := "git apply --binary --whitespace=nowarn"
+	if ws.applyPrefix != "" {
+		cmd += " --directory=" + shellEscape(ws.applyPrefix)
+	}
+	res, err := ws.parent.ExecCommand(ctx, cmd+" "+shellEscape(f.Name()), subagentWorkspaceTimeoutMS, ws.applyDir, nil)
+	if err != nil || res.ExitCode != 0 {
+		return fmt.Errorf("git apply: %s", strings.TrimSpace(res.Stderr+" "+errString(err)))
+	}
+	return nil
+}
+
+func (ws *subagentWorkspace) remove() {
+	if ws == nil {
+		return
+	}
+	if ws.mode == IsolationWorktree && ws.repoTop != "" {
+		q := shellEscape(ws.dir)
+		_, _ = ws.parent.ExecCommand(context.Background(), "git worktree remove --force "+q+" 2>/dev/null || { rm -rf "+q+"; git worktree prune; }", subagentWorkspaceTimeoutMS, ws.repoTop, nil)
+	}
+	_ = os.RemoveAll(ws.dir)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
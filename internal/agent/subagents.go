@@ -4,51 +4,117 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/danshapiro/kilroy/internal/llm"
 )
 
 type subagent struct {
-	id   string
-	sess *Session
+	id    string
+	label string
+	sess  *Session
+	ws    *subagentWorkspace // nil when sharing the parent's directory
+	// forwarded closes once all of the subagent's events reached the parent.
+	forwarded chan struct{}
 
 	mu      sync.Mutex
 	running bool
 	done    chan struct{}
 	result  string
 	err     error
+	applied bool
 }
 
 func (s *Session) spawnAgent(ctx context.Context, task string) (any, error) {
+	sub, err := s.newSubagent(ctx, "", "", false)
+	if err != nil {
+		return "", err
+	}
+	go sub.run(ctx, task)
+
+	b, _ := json.Marshal(map[string]any{"agent_id": sub.id})
+	return string(b), nil
+}
+
+// newSubagent creates a subagent session, in its own workspace when
+// isolated. Its events are forwarded to the parent's stream tagged with
+// subagent_id.
+func (s *Session) newSubagent(ctx context.Context, label, isolation string, isolated bool) (*subagent, error) {
 	s.mu.Lock()
 	depth := s.depth
 	maxDepth := s.cfg.MaxSubagentDepth
 	s.mu.Unlock()
 	if depth >= maxDepth {
-		return "", fmt.Errorf("subagent depth limit reached")
+		return nil, fmt.Errorf("subagent depth limit reached")
+	}
+
+	env := s.env
+	var ws *subagentWorkspace
+	if isolated {
+		var err error
+		if ws, err = createSubagentWorkspace(ctx, s.env, isolation); err != nil {
+			return nil, err
+		}
+		env = ws.env
 	}
 
 	subProfile := s.profile
-	subSess, err := NewSession(s.client, subProfile, s.env, s.cfg)
+	// A subagent works on its own task; the stage's plan and attachments
+	// are not its own. It calls the parent's MCP servers rather than
+	// starting another copy of each.
+	subCfg := s.cfg
+	subCfg.Plan = nil
+	subCfg.Attachments = nil
+	subCfg.mcpParent = s
+	subSess, err := NewSession(s.client, subProfile, env, subCfg)
 	if err != nil {
-		return "", err
+		ws.remove()
+		return nil, err
 	}
 	subSess.depth = depth + 1
 
 	sub := &subagent{
-		id:   subSess.id,
-		sess: subSess,
-		done: make(chan struct{}),
+		id:        subSess.id,
+		label:     label,
+		sess:      subSess,
+		ws:        ws,
+		done:      make(chan struct{}),
+		forwarded: make(chan struct{}),
 	}
 
 	s.mu.Lock()
 	s.subagents[sub.id] = sub
 	s.mu.Unlock()
 
-	go sub.run(ctx, task)
+	go s.forwardSubagentEvents(sub)
+	return sub, nil
+}
 
-	b, _ := json.Marshal(map[string]any{"agent_id": sub.id})
-	return string(b), nil
+// forwardSubagentEvents re-emits a subagent's events on the parent's stream
+// so they are logged and attributed per subagent. Text deltas are dropped;
+// ASSISTANT_TEXT_END carries the full text and token usage.
+func (s *Session) forwardSubagentEvents(sub *subagent) {
+	defer close(sub.forwarded)
+	for ev := range sub.sess.Events() {
+		if ev.Kind == EventAssistantTextStart || ev.Kind == EventAssistantTextDelta {
+			continue
+		}
+		data := make(map[string]any, len(ev.Data)+2)
+		for k, v := range ev.Data {
+			data[k] = v
+		}
+		// Events relayed from deeper levels keep their own attribution.
+		if _, ok := data["subagent_id"]; !ok {
+			data["subagent_id"] = sub.id
+			if sub.label != "" {
+				data["subagent_label"] = sub.label
+			}
+		}
+		s.emit(ev.Kind, data)
+	}
 }
 
 func (s *Session) sendInput(ctx context.Context, agentID string, input string) (any, error) {
@@ -111,10 +177,21 @@ func (s *Session) closeAgent(agentID string) (any, error) {
 	if sub == nil {
 		return "", fmt.Errorf("unknown agent_id: %s", agentID)
 	}
-	sub.sess.Close()
+	sub.close()
 	return "closed", nil
 }
 
+// closeSubagents closes every subagent and discards their workspaces.
+func (s *Session) closeSubagents() {
+	s.mu.Lock()
+	subs := s.subagents
+	s.subagents = map[string]*subagent{}
+	s.mu.Unlock()
+	for _, sub := range subs {
+		sub.close()
+	}
+}
+
 func (s *Session) getSub(agentID string) *subagent {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -137,3 +214,281 @@ func (a *subagent) run(ctx context.Context, input string) {
 	}
 	a.mu.Unlock()
 }
+
+func (a *subagent) close() {
+	a.sess.Close()
+	<-a.forwarded
+	a.ws.remove()
+}
+
+// spawnAgents runs a batch of subagents, each in its own workspace, at most
+// maxConcurrency at a time, and reports how each one finished.
+func (s *Session) spawnAgents(ctx context.Context, args map[string]any) (any, error) {
+	raw, _ := args["agents"].([]any)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("agents is empty")
+	}
+	limit := s.cfg.MaxParallelSubagents
+	if v, ok := args["max_concurrency"].(float64); ok && int(v) > 0 && int(v) < limit {
+		limit = int(v)
+	}
+	isolation := strings.TrimSpace(argStr(args, "isolation"))
+	switch isolation {
+	case "", IsolationAuto, IsolationWorktree, IsolationCopy:
+	default:
+		return nil, fmt.Errorf("unknown isolation %q (want auto|worktree|copy)", isolation)
+	}
+
+	type job struct {
+		label string
+		task  string
+		sub   *subagent
+		err   error
+	}
+	jobs := make([]job, 0, len(raw))
+	for i, r := range raw {
+		m, _ := r.(map[string]any)
+		task := strings.TrimSpace(argStr(m, "task"))
+		if task == "" {
+			return nil, fmt.Errorf("agents[%d].task is empty", i)
+		}
+		jobs = append(jobs, job{label: strings.TrimSpace(argStr(m, "label")), task: task})
+	}
+
+	// Sessions are created inside the semaphore, so at most limit run at
+	// once. Workspaces outlive their agent: merge_agent_results reads them
+	// later, so a batch holds one copy of the working tree per agent until
+	// close_agent or the end of the session.
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				j.err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			if j.sub, j.err = s.newSubagent(ctx, j.label, isolation, true); j.err != nil {
+				return
+			}
+			j.sub.run(ctx, j.task)
+		}(&jobs[i])
+	}
+	wg.Wait()
+
+	out := make([]map[string]any, 0, len(jobs))
+	for _, j := range jobs {
+		if j.sub == nil {
+			m := map[string]any{"status": "failed", "error": j.err.Error()}
+			if j.label != "" {
+				m["label"] = j.label
+			}
+			out = append(out, m)
+			continue
+		}
+		out = append(out, j.sub.summary(ctx))
+	}
+	b, _ := json.MarshalIndent(map[string]any{"agents": out}, "", "  ")
+	return string(b), nil
+}
+
+// summary reports a finished subagent: status, final text, changed files
+// and token usage.
+func (a *subagent) summary(ctx context.Context) map[string]any {
+	a.sess.mu.Lock()
+	usage := a.sess.usage
+	a.sess.mu.Unlock()
+	a.mu.Lock()
+	m := map[string]any{
+		"agent_id":      a.id,
+		"status":        "completed",
+		"result":        truncateChars(a.result, 4_000, TruncHeadTail),
+		"input_tokens":  usage.InputTokens,
+		"output_tokens": usage.OutputTokens,
+	}
+	if a.label != "" {
+		m["label"] = a.label
+	}
+	switch {
+	case a.running:
+		m["status"] = "running"
+	case a.err != nil:
+		m["status"] = "failed"
+		m["error"] = a.err.Error()
+	}
+	if a.applied {
+		m["applied"] = true
+	}
+	a.mu.Unlock()
+	if a.ws != nil {
+		if _, files, err := a.ws.diff(ctx, nil); err == nil {
+			m["files_changed"] = files
+		}
+	}
+	return m
+}
+
+// mergeAgentResults reports isolated subagents' diffs and, with apply=true,
+// applies them to the parent's working tree in the order given.
+func (s *Session) mergeAgentResults(ctx context.Context, args map[string]any) (any, error) {
+	var ids []string
+	for _, v := range anySlice(args["agent_ids"]) {
+		if id := strings.TrimSpace(fmt.Sprint(v)); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	var paths []string
+	for _, v := range anySlice(args["paths"]) {
+		if p := strings.TrimSpace(fmt.Sprint(v)); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	apply, _ := args["apply"].(bool)
+	if len(ids) == 0 {
+		s.mu.Lock()
+		for id, sub := range s.subagents {
+			if sub.ws != nil {
+				ids = append(ids, id)
+			}
+		}
+		s.mu.Unlock()
+		sort.Strings(ids)
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("no isolated subagents (start them with spawn_agents)")
+		}
+	}
+
+	var b strings.Builder
+	failed := 0
+	for _, id := range ids {
+		sub := s.getSub(id)
+		if sub == nil || sub.ws == nil {
+			return nil, fmt.Errorf("unknown isolated agent_id: %s", id)
+		}
+		sub.mu.Lock()
+		running, applied, label := sub.running, sub.applied, sub.label
+		sub.mu.Unlock()
+		if label != "" {
+			fmt.Fprintf(&b, "=== %s (%s)\n", id, label)
+		} else {
+			fmt.Fprintf(&b, "=== %s\n", id)
+		}
+		if running {
+			failed++
+			b.WriteString("still running; wait for it first\n\n")
+			continue
+		}
+		patch, files, err := sub.ws.diff(ctx, paths)
+		if err != nil {
+			failed++
+			fmt.Fprintf(&b, "diff failed: %v\n\n", err)
+			continue
+		}
+		fmt.Fprintf(&b, "files: %s\n", strings.Join(files, ", "))
+		if len(files) == 0 {
+			b.WriteString("no changes\n\n")
+			continue
+		}
+		if !apply {
+			b.WriteString(patch)
+			b.WriteString("\n")
+			continue
+		}
+		if applied {
+			failed++
+			b.WriteString("not applied: already applied\n\n")
+			continue
+		}
+		if err := sub.ws.apply(ctx, patch); err != nil {
+			failed++
+			fmt.Fprintf(&b, "not applied: %v\n\n", err)
+			continue
+		}
+		if len(paths) == 0 {
+			sub.mu.Lock()
+			sub.applied = true
+			sub.mu.Unlock()
+		}
+		fmt.Fprintf(&b, "applied %d file(s)\n\n", len(files))
+	}
+	out := strings.TrimRight(b.String(), "\n")
+	if failed > 0 {
+		return out, fmt.Errorf("%d of %d subagent result(s) could not be merged", failed, len(ids))
+	}
+	return out, nil
+}
+
+func spawnAgentsTool(s *Session) RegisteredTool {
+	return RegisteredTool{
+		Definition: defSpawnAgents(),
+		Exec: func(ctx context.Context, env ExecutionEnvironment, args map[string]any) (any, error) {
+			return s.spawnAgents(ctx, args)
+		},
+	}
+}
+
+func mergeAgentResultsTool(s *Session) RegisteredTool {
+	return RegisteredTool{
+		Definition: defMergeAgentResults(),
+		Exec: func(ctx context.Context, env ExecutionEnvironment, args map[string]any) (any, error) {
+			return s.mergeAgentResults(ctx, args)
+		},
+	}
+}
+
+func anySlice(v any) []any {
+	xs, _ := v.([]any)
+	return xs
+}
+
+func defSpawnAgents() llm.ToolDefinition {
+	return llm.ToolDefinition{
+		Name: "spawn_agents",
+		Description: "Run several sub-agents in parallel, each in its own copy of the working tree, and wait for all of them. " +
+			"Their changes are not visible here until you merge them with merge_agent_results. " +
+			"Each copy is kept until you close_agent it, so close agents you are done with.",
+		Parameters: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"agents": map[string]any{
+					"type":     "array",
+					"minItems": 1,
+					"items": map[string]any{
+						"type":                 "object",
+						"additionalProperties": false,
+						"properties": map[string]any{
+							"task":  map[string]any{"type": "string"},
+							"label": map[string]any{"type": "string"},
+						},
+						"required": []string{"task"},
+					},
+				},
+				"max_concurrency": map[string]any{"type": "integer"},
+				"isolation":       map[string]any{"type": "string", "enum": []string{IsolationAuto, IsolationWorktree, IsolationCopy}},
+			},
+			"required": []string{"agents"},
+		},
+	}
+}
+
+func defMergeAgentResults() llm.ToolDefinition {
+	return llm.ToolDefinition{
+		Name: "merge_agent_results",
+		Description: "Show the diffs made by sub-agents started with spawn_agents, or apply them to this working tree with apply=true. " +
+			"agent_ids selects agents (default: all) and paths limits the merge to some files.",
+		Parameters: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"agent_ids": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"paths":     map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"apply":     map[string]any{"type": "boolean"},
+			},
+		},
+	}
+}
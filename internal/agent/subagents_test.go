@@ -0,0 +1,245 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+// taskFileAdapter answers a session's first request with a write_file call
+// creating <task>.txt, then finishes.
+type taskFileAdapter struct{}
+
+func (taskFileAdapter) Name() string { return "openai" }
+
+func (taskFileAdapter) Complete(ctx context.Context, req llm.Request) (llm.Response, error) {
+	task := ""
+	for _, m := range req.Messages {
+		switch m.Role {
+		case llm.RoleUser:
+			task = strings.TrimSpace(m.Text())
+		case llm.RoleTool:
+			return llm.Response{Provider: "openai", Model: req.Model, Message: llm.Assistant("wrote " + task)}, nil
+		}
+	}
+	args, _ := json.Marshal(map[string]any{"file_path": task + ".txt", "content": task + "\n"})
+	call := llm.ToolCallData{ID: "w-" + task, Name: "write_file", Arguments: args, Type: "function"}
+	return llm.Response{
+		Provider: "openai",
+		Model:    req.Model,
+		Message:  llm.Message{Role: llm.RoleAssistant, Content: []llm.ContentPart{{Kind: llm.ContentToolCall, ToolCall: &call}}},
+		Usage:    llm.Usage{InputTokens: 10, OutputTokens: 5},
+	}, nil
+}
+
+func (a taskFileAdapter) Stream(ctx context.Context, req llm.Request) (llm.Stream, error) {
+	resp, err := a.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return streamFromResponse(ctx, resp), nil
+}
+
+func gitTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestSpawnAgents_IsolatedWorkspacesAndMerge(t *testing.T) {
+	dir := gitTestRepo(t)
+	// Uncommitted and untracked parent state must be visible to subagents.
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("parent notes\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := llm.NewClient()
+	c.Register(taskFileAdapter{})
+	sess, err := NewSession(c, NewOpenAIProfile("gpt-5.4"), NewLocalExecutionEnvironment(dir), SessionConfig{})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	call := func(name, args string) ToolExecResult {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		return sess.reg.ExecuteCall(ctx, sess.env, llm.ToolCallData{ID: "c", Name: name, Arguments: json.RawMessage(args)})
+	}
+
+	res := call("spawn_agents", `{"agents":[{"task":"alpha","label":"a"},{"task":"beta"}],"max_concurrency":2}`)
+	if res.IsError {
+		t.Fatalf("spawn_agents: %s", res.Output)
+	}
+	var report struct {
+		Agents []struct {
+			AgentID      string   `json:"agent_id"`
+			Label        string   `json:"label"`
+			Status       string   `json:"status"`
+			Result       string   `json:"result"`
+			FilesChanged []string `json:"files_changed"`
+			InputTokens  int      `json:"input_tokens"`
+		} `json:"agents"`
+	}
+	if err := json.Unmarshal([]byte(res.Output), &report); err != nil || len(report.Agents) != 2 {
+		t.Fatalf("report: %v\n%s", err, res.Output)
+	}
+	alpha, beta := report.Agents[0], report.Agents[1]
+	if alpha.Status != "completed" || alpha.Label != "a" || alpha.Result != "wrote alpha" ||
+		fmt.Sprint(alpha.FilesChanged) != "[alpha.txt]" || alpha.InputTokens != 10 {
+		t.Fatalf("alpha = %+v", alpha)
+	}
+	if fmt.Sprint(beta.FilesChanged) != "[beta.txt]" {
+		t.Fatalf("beta = %+v", beta)
+	}
+	ws := sess.getSub(alpha.AgentID).ws
+	if b, err := os.ReadFile(filepath.Join(ws.dir, "notes.txt")); err != nil || string(b) != "parent notes\n" {
+		t.Fatalf("workspace missing parent state: %q %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "alpha.txt")); !os.IsNotExist(err) {
+		t.Fatalf("subagent wrote into the parent directory: %v", err)
+	}
+
+	if res = call("merge_agent_results", `{}`); res.IsError || !strings.Contains(res.Output, "+alpha") || !strings.Contains(res.Output, "+beta") {
+		t.Fatalf("report merge: %+v", res)
+	}
+	if res = call("merge_agent_results", fmt.Sprintf(`{"agent_ids":[%q],"paths":["other.txt"],"apply":true}`, beta.AgentID)); res.IsError || !strings.Contains(res.Output, "no changes") {
+		t.Fatalf("path filter: %+v", res)
+	}
+	applyAlpha := fmt.Sprintf(`{"agent_ids":[%q],"apply":true}`, alpha.AgentID)
+	if res = call("merge_agent_results", applyAlpha); res.IsError {
+		t.Fatalf("apply: %s", res.Output)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "alpha.txt")); err != nil || string(b) != "alpha\n" {
+		t.Fatalf("alpha.txt after apply: %q %v", b, err)
+	}
+	if res = call("merge_agent_results", applyAlpha); !res.IsError || !strings.Contains(res.Output, "already applied") {
+		t.Fatalf("expected double apply to fail: %+v", res)
+	}
+
+	sess.Close()
+	if _, err := os.Stat(ws.dir); !os.IsNotExist(err) {
+		t.Fatalf("workspace not removed: %v", err)
+	}
+	if out, _ := exec.Command("git", "-C", dir, "worktree", "list").Output(); strings.Count(string(out), "\n") != 1 {
+		t.Fatalf("worktrees left behind:\n%s", out)
+	}
+	tagged := 0
+	for ev := range sess.Events() {
+		if ev.Kind == EventToolCallEnd && ev.Data["subagent_id"] == alpha.AgentID && ev.Data["subagent_label"] == "a" {
+			tagged++
+		}
+	}
+	if tagged != 1 {
+		t.Fatalf("expected alpha's write_file to be forwarded once, got %d", tagged)
+	}
+}
+
+func TestSpawnAgents_CopyIsolationOutsideGit(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("keep\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := llm.NewClient()
+	c.Register(taskFileAdapter{})
+	sess, err := NewSession(c, NewOpenAIProfile("gpt-5.4"), NewLocalExecutionEnvironment(dir), SessionConfig{})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer sess.Close()
+	ctx := context.Background()
+	res := sess.reg.ExecuteCall(ctx, sess.env, llm.ToolCallData{ID: "c1", Name: "spawn_agents", Arguments: json.RawMessage(`{"agents":[{"task":"gamma"}]}`)})
+	if res.IsError || !strings.Contains(res.Output, "gamma.txt") {
+		t.Fatalf("spawn_agents: %+v", res)
+	}
+	res = sess.reg.ExecuteCall(ctx, sess.env, llm.ToolCallData{ID: "c2", Name: "merge_agent_results", Arguments: json.RawMessage(`{"apply":true}`)})
+	if res.IsError {
+		t.Fatalf("apply: %s", res.Output)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "gamma.txt")); err != nil || string(b) != "gamma\n" {
+		t.Fatalf("gamma.txt after apply: %q %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); !os.IsNotExist(err) {
+		t.Fatalf("copy isolation must not turn the parent into a repo: %v", err)
+	}
+}
+
+func TestSubagent_SharesParentMCPServers(t *testing.T) {
+	sess, err := NewSession(llm.NewClient(), NewOpenAIProfile("gpt-5.4"), NewLocalExecutionEnvironment(t.TempDir()), SessionConfig{
+		MCPServers: []MCPServerConfig{mcpFixtureConfig(t)},
+	})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer sess.Close()
+	sub, err := sess.newSubagent(context.Background(), "", "", false)
+	if err != nil {
+		t.Fatalf("newSubagent: %v", err)
+	}
+	if sub.sess.mcp != nil {
+		t.Fatal("subagent started its own MCP servers")
+	}
+	call := func(s *Session) ToolExecResult {
+		return s.reg.ExecuteCall(context.Background(), s.env, llm.ToolCallData{ID: "c", Name: "fixture__pid", Arguments: json.RawMessage(`{}`)})
+	}
+	parentPID, subPID := call(sess), call(sub.sess)
+	if subPID.IsError || subPID.Output != parentPID.Output {
+		t.Fatalf("subagent pid %+v, parent pid %q", subPID, parentPID.Output)
+	}
+}
+
+// liveSubagentsAdapter records how many subagents the parent holds each time
+// a subagent makes its first request.
+type liveSubagentsAdapter struct {
+	taskFileAdapter
+	parent *Session
+	mu     sync.Mutex
+	seen   []int
+}
+
+func (a *liveSubagentsAdapter) Stream(ctx context.Context, req llm.Request) (llm.Stream, error) {
+	if len(req.Messages) > 0 && req.Messages[len(req.Messages)-1].Role == llm.RoleUser {
+		a.parent.mu.Lock()
+		n := len(a.parent.subagents)
+		a.parent.mu.Unlock()
+		a.mu.Lock()
+		a.seen = append(a.seen, n)
+		a.mu.Unlock()
+	}
+	return a.taskFileAdapter.Stream(ctx, req)
+}
+
+func TestSpawnAgents_CreatesSubagentsWithinConcurrencyCap(t *testing.T) {
+	dir := gitTestRepo(t)
+	a := &liveSubagentsAdapter{}
+	c := llm.NewClient()
+	c.Register(a)
+	sess, err := NewSession(c, NewOpenAIProfile("gpt-5.4"), NewLocalExecutionEnvironment(dir), SessionConfig{})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer sess.Close()
+	a.parent = sess
+	args := `{"agents":[{"task":"a"},{"task":"b"},{"task":"c"}],"max_concurrency":1}`
+	res := sess.reg.ExecuteCall(context.Background(), sess.env, llm.ToolCallData{ID: "c", Name: "spawn_agents", Arguments: json.RawMessage(args)})
+	if res.IsError {
+		t.Fatalf("spawn_agents: %s", res.Output)
+	}
+	if fmt.Sprint(a.seen) != "[1 2 3]" {
+		t.Fatalf("subagents alive at each start = %v, want [1 2 3]", a.seen)
+	}
+}
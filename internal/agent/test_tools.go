@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+	"github.com/danshapiro/kilroy/internal/testresults"
+)
+
+const (
+	runTestsMaxFailures  = 20
+	runTestsMessageLines = 15
+	// runTestsFallbackLines is how much raw output is shown when the
+	// results could not be parsed.
+	runTestsFallbackLines = 60
+)
+
+// runTestsTool runs a test command and answers with parsed results: counts
+// and each failing test's location and message, not the runner's raw log.
+func runTestsTool(s *Session) RegisteredTool {
+	return RegisteredTool{
+		Definition: defRunTests(),
+		Exec: func(ctx context.Context, env ExecutionEnvironment, args map[string]any) (any, error) {
+			cmd := argStr(args, "command")
+			timeout := s.commandTimeoutMS(args)
+			started := time.Now()
+			// Like shell, a non-zero exit is returned as the error alongside
+			// the output, so failing runs are flagged to the model.
+			res, err := env.ExecCommand(ctx, cmd, timeout, "", nil)
+
+			var report *testresults.Report
+			var parseErr error
+			if p := strings.TrimSpace(argStr(args, "report_path")); p != "" {
+				report, parseErr = readTestReport(env, p, argStr(args, "format"), started)
+			} else {
+				report, parseErr = testresults.Parse(argStr(args, "format"), []byte(res.Stdout))
+				if errors.Is(parseErr, testresults.ErrNoResults) {
+					report, parseErr = testresults.Parse(argStr(args, "format"), []byte(res.Stdout+"\n"+res.Stderr))
+				}
+			}
+
+			var b strings.Builder
+			if parseErr == nil {
+				b.WriteString(report.Summary(runTestsMaxFailures, runTestsMessageLines))
+				if report.Failed == 0 && res.ExitCode != 0 {
+					b.WriteString("\nNote: no test failed but the command exited non-zero; check the output with the shell tool.\n")
+				}
+			} else {
+				fmt.Fprintf(&b, "Could not parse test results: %v\n", parseErr)
+				b.WriteString("Pass a machine-readable reporter (go test -json, --junitxml, TAP) or set report_path.\n\n")
+				b.WriteString(lastLines(strings.TrimSpace(res.Stdout+"\n"+res.Stderr), runTestsFallbackLines))
+				b.WriteString("\n")
+			}
+			if res.TimedOut {
+				fmt.Fprintf(&b, "[ERROR: Command timed out after %dms; results are partial.]\n", timeout)
+			}
+			fmt.Fprintf(&b, "exit_code=%d duration_ms=%d timed_out=%t\n", res.ExitCode, res.DurationMS, res.TimedOut)
+			return b.String(), err
+		},
+	}
+}
+
+// readTestReport parses a report file the command wrote, refusing one left
+// over from an earlier run.
+func readTestReport(env ExecutionEnvironment, path, format string, since time.Time) (*testresults.Report, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(env.WorkingDirectory(), path)
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("report_path: %w", err)
+	}
+	// Coarse filesystem timestamps can land just before the start time.
+	if st.ModTime().Before(since.Add(-2 * time.Second)) {
+		return nil, fmt.Errorf("report_path %s was not updated by this run", path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("report_path: %w", err)
+	}
+	return testresults.Parse(format, b)
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(s, "\n")
+	if len(lines) <= n {
+		return s
+	}
+	return fmt.Sprintf("[... %d earlier lines omitted ...]\n", len(lines)-n) + strings.Join(lines[len(lines)-n:], "\n")
+}
+
+func defRunTests() llm.ToolDefinition {
+	return llm.ToolDefinition{
+		Name: "run_tests",
+		Description: "Run a test command and return a compact summary: pass/fail/skip counts and each failing test with its file:line and message. " +
+			"Use a machine-readable reporter: go test -json, cargo test -- -Z unstable-options --format json, TAP, or JUnit XML on stdout or at report_path.",
+		Parameters: map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"command":     map[string]any{"type": "string", "description": "Test command to run in the working directory."},
+				"report_path": map[string]any{"type": "string", "description": "Report file the command writes (e.g. JUnit XML); parsed instead of stdout."},
+				"format": map[string]any{
+					"type": "string",
+					"enum": []string{testresults.FormatAuto, testresults.FormatGoTest, testresults.FormatJUnit, testresults.FormatTAP, testresults.FormatCargo},
+				},
+				"timeout_ms": map[string]any{"type": "integer"},
+			},
+			"required": []string{"command"},
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+func callRunTests(t *testing.T, dir, args string) ToolExecResult {
+	t.Helper()
+	s := &Session{cfg: SessionConfig{DefaultCommandTimeoutMS: 10_000, MaxCommandTimeoutMS: 10_000}}
+	reg := NewToolRegistry()
+	if err := reg.Register(runTestsTool(s)); err != nil {
+		t.Fatal(err)
+	}
+	return reg.ExecuteCall(context.Background(), NewLocalExecutionEnvironment(dir), llm.ToolCallData{ID: "c1", Name: "run_tests", Arguments: json.RawMessage(args)})
+}
+
+func TestRunTests_SummarizesFailuresFromStdout(t *testing.T) {
+	dir := t.TempDir()
+	script := "printf 'ok 1 - adds\\nnot ok 2 - divides\\n  ---\\n  at: test/math.js:17:5\\n  ...\\n'; exit 1"
+	args, _ := json.Marshal(map[string]any{"command": script})
+	res := callRunTests(t, dir, string(args))
+	if !res.IsError {
+		t.Fatalf("failing run should be flagged: %+v", res)
+	}
+	want := "FAIL: 1 of 2 tests failed (tap)\n\n--- divides (test/math.js:17)\n    at: test/math.js:17:5\nexit_code=1"
+	if !strings.HasPrefix(res.Output, want) {
+		t.Fatalf("output:\n%s", res.Output)
+	}
+}
+
+func TestRunTests_ReportPathAndUnparsedFallback(t *testing.T) {
+	dir := t.TempDir()
+	xml := `<testsuite name="s"><testcase classname="c" name="one"/></testsuite>`
+	args, _ := json.Marshal(map[string]any{"command": "printf '" + xml + "' > report.xml", "report_path": "report.xml"})
+	res := callRunTests(t, dir, string(args))
+	if !strings.HasPrefix(res.Output, "PASS: 1 tests passed (junit)\nexit_code=0") {
+		t.Fatalf("output:\n%s", res.Output)
+	}
+
+	// A stale report is rejected rather than reported as this run's result.
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "report.xml"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	args, _ = json.Marshal(map[string]any{"command": "true", "report_path": "report.xml"})
+	res = callRunTests(t, dir, string(args))
+	if !strings.Contains(res.Output, "was not updated by this run") {
+		t.Fatalf("output:\n%s", res.Output)
+	}
+
+	args, _ = json.Marshal(map[string]any{"command": "echo compiling; echo 'error: oops' >&2; exit 2"})
+	res = callRunTests(t, dir, string(args))
+	if !strings.Contains(res.Output, "Could not parse test results") || !strings.Contains(res.Output, "error: oops") || !strings.Contains(res.Output, "exit_code=2") {
+		t.Fatalf("output:\n%s", res.Output)
+	}
+}
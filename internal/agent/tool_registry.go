@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -88,6 +89,26 @@ func (r *ToolRegistry) Register(t RegisteredTool) error {
 	return nil
 }
 
+// Get returns the registered tool with the given name.
+func (r *ToolRegistry) Get(name string) (RegisteredTool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Names returns the registered tool names, sorted.
+func (r *ToolRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
 func (r *ToolRegistry) Definitions() []llm.ToolDefinition {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -269,6 +290,10 @@ func defaultToolLimit(toolName string) ToolOutputLimit {
 		return ToolOutputLimit{MaxChars: 1_000, Strategy: TruncTail}
 	case "spawn_agent":
 		return ToolOutputLimit{MaxChars: 20_000, Strategy: TruncHeadTail}
+	case "merge_agent_results":
+		return ToolOutputLimit{MaxChars: 60_000, Strategy: TruncHeadTail}
+	case "find_references", "workspace_symbols", "diagnostics":
+		return ToolOutputLimit{MaxChars: 20_000, MaxLines: 200, Strategy: TruncTail}
 	default:
 		return ToolOutputLimit{MaxChars: 20_000, Strategy: TruncHeadTail}
 	}
@@ -0,0 +1,136 @@
+// Aider conversation log locator and parser.
+// Aider has no structured output; it appends a markdown chat history where
+// "####" lines are user prompts, "> " blockquotes are tool output (applied
+// edits, commands, commits) and everything else is assistant text.
+package agentlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AiderHistoryFile is the chat history file name used when Aider is pointed
+// at a stage directory via AIDER_CHAT_HISTORY_FILE.
+const AiderHistoryFile = "aider.chat.history.md"
+
+// AiderLogLocator finds Aider's default chat history in the work directory.
+type AiderLogLocator struct{}
+
+// FindLog returns workDir/.aider.chat.history.md (Aider's default) if it was written after startedAfter.
+func (l *AiderLogLocator) FindLog(workDir string, startedAfter time.Time) (string, error) {
+	path := filepath.Join(workDir, "."+AiderHistoryFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !startedAfter.IsZero() && info.ModTime().Before(startedAfter) {
+		return "", fmt.Errorf("%s not modified after %s", path, startedAfter)
+	}
+	return path, nil
+}
+
+// ParseAiderLog reads an Aider markdown chat history and returns structured events.
+func ParseAiderLog(path string) ([]AgentEvent, error) {
+	return parseLines(path, &aiderHistoryParser{})
+}
+
+// aiderHistoryParser accumulates assistant text between prompts and tool
+// output, and collects the blockquote lines that follow "Running <cmd>"
+// into one tool_result.
+type aiderHistoryParser struct {
+	text    []string
+	cmd     string
+	cmdOut  []string
+	running bool
+}
+
+func (a *aiderHistoryParser) ParseLine(line string) []AgentEvent {
+	if strings.HasPrefix(line, "# aider chat started") {
+		return a.Flush()
+	}
+	if strings.HasPrefix(line, "#### ") {
+		return a.Flush()
+	}
+	if line == ">" || strings.HasPrefix(line, "> ") {
+		// Aider ends lines with two spaces (markdown hard breaks).
+		quoted := strings.TrimRight(strings.TrimPrefix(strings.TrimPrefix(line, ">"), " "), " ")
+		return a.parseQuoted(quoted)
+	}
+	events := a.flushCommand()
+	if len(a.text) > 0 || strings.TrimSpace(line) != "" {
+		a.text = append(a.text, line)
+	}
+	return events
+}
+
+func (a *aiderHistoryParser) parseQuoted(line string) []AgentEvent {
+	switch {
+	case strings.HasPrefix(line, "Applied edit to "):
+		path := strings.TrimSpace(strings.TrimPrefix(line, "Applied edit to "))
+		events := append(a.Flush(), AgentEvent{
+			Type:    "tool_call",
+			Tool:    "edit",
+			Message: fmt.Sprintf("Edit(%s)", path),
+			Data:    map[string]any{"tool": "edit", "path": path},
+		})
+		return events
+	case strings.HasPrefix(line, "Running "):
+		cmd := strings.TrimSpace(strings.TrimPrefix(line, "Running "))
+		events := append(a.Flush(), AgentEvent{
+			Type:    "tool_call",
+			Tool:    "command",
+			Message: fmt.Sprintf("Bash(%s)", truncate(cmd, 80)),
+			Data:    map[string]any{"tool": "command", "command": cmd},
+		})
+		a.cmd, a.running = cmd, true
+		return events
+	case strings.HasPrefix(line, "Commit "):
+		fields := strings.SplitN(strings.TrimPrefix(line, "Commit "), " ", 2)
+		sha, msg := fields[0], ""
+		if len(fields) == 2 {
+			msg = fields[1]
+		}
+		return append(a.Flush(), AgentEvent{
+			Type:    "tool_call",
+			Tool:    "git_commit",
+			Message: fmt.Sprintf("Commit(%s %s)", sha, truncate(msg, 60)),
+			Data:    map[string]any{"tool": "git_commit", "sha": sha, "message": msg},
+		})
+	}
+	if a.running {
+		a.cmdOut = append(a.cmdOut, line)
+	}
+	// Other blockquotes are banners, token counts and warnings.
+	return nil
+}
+
+func (a *aiderHistoryParser) flushCommand() []AgentEvent {
+	if !a.running {
+		return nil
+	}
+	out := strings.TrimSpace(strings.Join(a.cmdOut, "\n"))
+	cmd := a.cmd
+	a.cmd, a.cmdOut, a.running = "", nil, false
+	return []AgentEvent{{
+		Type:    "tool_result",
+		Message: truncate(out, 200),
+		Data:    map[string]any{"command": cmd, "content": truncate(out, 2000)},
+	}}
+}
+
+func (a *aiderHistoryParser) Flush() []AgentEvent {
+	events := a.flushCommand()
+	text := strings.TrimSpace(strings.Join(a.text, "\n"))
+	a.text = nil
+	if text != "" {
+		events = append(events, AgentEvent{
+			Type:    "text",
+			Message: truncate(text, 200),
+			Data:    map[string]any{"text": text},
+		})
+	}
+	return events
+}
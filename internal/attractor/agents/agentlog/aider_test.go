@@ -0,0 +1,78 @@
+// Tests for the Aider markdown chat history parser.
+package agentlog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const aiderHistory = `
+# aider chat started at 2025-01-01 10:00:00
+
+> /usr/local/bin/aider --yes-always --message Fix the build  
+> Aider v0.80.0  
+> Main model: claude-sonnet with diff edit format  
+
+#### Fix the build  
+
+The import is missing. I'll add it.
+
+main.go
+` + "```go" + `
+<<<<<<< SEARCH
+package main
+=======
+package main
+
+import "fmt"
+>>>>>>> REPLACE
+` + "```" + `
+
+> Tokens: 2.1k sent, 120 received.  
+> Applied edit to main.go  
+> Running go build ./...  
+> ok  
+>  
+> Commit 1a2b3c4 fix: add missing import  
+
+Build passes now.
+`
+
+func TestParseAiderLog(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, AiderHistoryFile)
+	os.WriteFile(logPath, []byte(aiderHistory), 0o644)
+
+	events, err := ParseAiderLog(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Expected: text, tool_call(Edit), tool_call(Bash), tool_result, tool_call(commit), text
+	if len(events) != 6 {
+		t.Fatalf("expected 6 events, got %d: %+v", len(events), events)
+	}
+	if events[0].Type != "text" {
+		t.Errorf("event 0: expected text, got %s", events[0].Type)
+	}
+	if events[1].Type != "tool_call" || events[1].Message != "Edit(main.go)" {
+		t.Errorf("event 1: unexpected %s %q", events[1].Type, events[1].Message)
+	}
+	if events[2].Message != "Bash(go build ./...)" {
+		t.Errorf("event 2: unexpected message %q", events[2].Message)
+	}
+	if events[3].Type != "tool_result" || events[3].Data["content"] != "ok" {
+		t.Errorf("event 3: unexpected %s %v", events[3].Type, events[3].Data)
+	}
+	if events[4].Tool != "git_commit" || events[4].Data["sha"] != "1a2b3c4" {
+		t.Errorf("event 4: unexpected %s %v", events[4].Tool, events[4].Data)
+	}
+	if events[5].Type != "text" || events[5].Data["text"] != "Build passes now." {
+		t.Errorf("event 5: unexpected %s %v", events[5].Type, events[5].Data)
+	}
+
+	if got := ExtractResponseText("aider", []byte(aiderHistory)); got != "Build passes now." {
+		t.Errorf("ExtractResponseText = %q", got)
+	}
+}
@@ -101,6 +101,12 @@ func ParseCodexLine(raw map[string]any) []AgentEvent {
 
 // findNewestJSONL returns the most recently modified .jsonl file in a directory.
 func findNewestJSONL(dir string, after time.Time) (string, error) {
+	return findNewestFile(dir, ".jsonl", after)
+}
+
+// findNewestFile returns the most recently modified file with the given
+// suffix in a directory.
+func findNewestFile(dir, suffix string, after time.Time) (string, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return "", err
@@ -108,7 +114,7 @@ func findNewestJSONL(dir string, after time.Time) (string, error) {
 	var best string
 	var bestMod time.Time
 	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), suffix) {
 			continue
 		}
 		info, err := e.Info()
@@ -125,7 +131,7 @@ func findNewestJSONL(dir string, after time.Time) (string, error) {
 		}
 	}
 	if best == "" {
-		return "", fmt.Errorf("no JSONL files found in %s after %s", dir, after)
+		return "", fmt.Errorf("no %s files found in %s after %s", suffix, dir, after)
 	}
 	return best, nil
 }
@@ -16,6 +16,10 @@ func ExtractResponseText(tool string, data []byte) string {
 		return extractCodexResponseText(data)
 	case "opencode":
 		return extractOpenCodeResponseText(data)
+	case "gemini":
+		return extractGeminiResponseText(data)
+	case "aider":
+		return extractAiderResponseText(data)
 	default:
 		return ""
 	}
@@ -95,3 +99,25 @@ func extractOpenCodeResponseText(data []byte) string {
 	}
 	return strings.Join(texts, "\n\n")
 }
+
+func extractGeminiResponseText(data []byte) string {
+	var texts []string
+	for _, ev := range parseData(data, &geminiStreamParser{}) {
+		if text, ok := ev.Data["text"].(string); ok && ev.Type == "text" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// extractAiderResponseText returns the last assistant reply in an Aider
+// chat history; earlier replies were answered by later prompts.
+func extractAiderResponseText(data []byte) string {
+	var last string
+	for _, ev := range parseData(data, &aiderHistoryParser{}) {
+		if text, ok := ev.Data["text"].(string); ok && ev.Type == "text" {
+			last = text
+		}
+	}
+	return last
+}
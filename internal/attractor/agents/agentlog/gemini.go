@@ -0,0 +1,256 @@
+// Gemini CLI conversation log locator and parser.
+// Parses gemini --output-format stream-json JSONL output (init, message,
+// tool_use, tool_result, result events) and the chat session files the CLI
+// saves under ~/.gemini/tmp/<project-hash>/chats.
+package agentlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GeminiLogLocator finds Gemini CLI chat session files.
+type GeminiLogLocator struct{}
+
+// FindLog locates the newest session file saved for workDir's project.
+func (l *GeminiLogLocator) FindLog(workDir string, startedAfter time.Time) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("user home dir: %w", err)
+	}
+	abs, err := filepath.Abs(workDir)
+	if err != nil {
+		return "", err
+	}
+	// The CLI keys per-project state by the SHA-256 of the project root.
+	sum := sha256.Sum256([]byte(abs))
+	chatsDir := filepath.Join(home, ".gemini", "tmp", hex.EncodeToString(sum[:]), "chats")
+	return findNewestFile(chatsDir, ".json", startedAfter)
+}
+
+// ParseGeminiLog reads either stream-json output or a saved chat session
+// file and returns structured events.
+func ParseGeminiLog(path string) ([]AgentEvent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// A saved session is one JSON document; stream-json output has one
+	// document per line and fails to unmarshal as a whole.
+	var session struct {
+		Messages []geminiChatMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &session); err == nil && session.Messages != nil {
+		return parseGeminiChat(session.Messages), nil
+	}
+	return parseData(data, &geminiStreamParser{}), nil
+}
+
+// geminiStreamParser turns stream-json lines into events. Assistant text
+// arrives as delta chunks; they are joined into one text event, emitted when
+// the next non-text event (or end of input) closes the message.
+type geminiStreamParser struct {
+	text strings.Builder
+}
+
+func (g *geminiStreamParser) ParseLine(line string) []AgentEvent {
+	raw, ok := ParseJSONLine(line)
+	if !ok {
+		return nil
+	}
+	typ, _ := raw["type"].(string)
+	if typ == "message" {
+		if role, _ := raw["role"].(string); role != "assistant" {
+			return nil
+		}
+		content, _ := raw["content"].(string)
+		if delta, _ := raw["delta"].(bool); delta {
+			g.text.WriteString(content)
+			return nil
+		}
+		events := g.Flush()
+		return append(events, geminiTextEvent(content)...)
+	}
+	events := g.Flush()
+	return append(events, ParseGeminiLine(raw)...)
+}
+
+func (g *geminiStreamParser) Flush() []AgentEvent {
+	text := g.text.String()
+	g.text.Reset()
+	return geminiTextEvent(text)
+}
+
+func geminiTextEvent(text string) []AgentEvent {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	return []AgentEvent{{
+		Type:    "text",
+		Message: truncate(text, 200),
+		Data:    map[string]any{"text": text},
+	}}
+}
+
+// ParseGeminiLine parses a single non-message stream-json line into events.
+func ParseGeminiLine(raw map[string]any) []AgentEvent {
+	typ, _ := raw["type"].(string)
+	switch typ {
+	case "tool_use":
+		name, _ := raw["tool_name"].(string)
+		id, _ := raw["tool_id"].(string)
+		params, _ := raw["parameters"].(map[string]any)
+		return []AgentEvent{{
+			Type:    "tool_call",
+			Tool:    name,
+			Message: formatGeminiToolCall(name, params),
+			Data:    map[string]any{"tool": name, "tool_use_id": id, "args": params},
+		}}
+
+	case "tool_result":
+		id, _ := raw["tool_id"].(string)
+		status, _ := raw["status"].(string)
+		output, _ := raw["output"].(string)
+		if errObj, ok := raw["error"].(map[string]any); ok && output == "" {
+			output, _ = errObj["message"].(string)
+		}
+		return []AgentEvent{{
+			Type:    "tool_result",
+			Message: truncate(output, 200),
+			Data: map[string]any{
+				"tool_use_id": id,
+				"status":      status,
+				"content":     truncate(output, 2000),
+			},
+		}}
+	}
+	return nil
+}
+
+// geminiChatMessage is one entry of a saved chat session file.
+type geminiChatMessage struct {
+	Type      string `json:"type"` // user, gemini, info, error
+	Content   any    `json:"content"`
+	ToolCalls []struct {
+		ID     string         `json:"id"`
+		Name   string         `json:"name"`
+		Args   map[string]any `json:"args"`
+		Status string         `json:"status"`
+		Result any            `json:"result"`
+	} `json:"toolCalls"`
+	Thoughts []struct {
+		Subject     string `json:"subject"`
+		Description string `json:"description"`
+	} `json:"thoughts"`
+}
+
+func parseGeminiChat(messages []geminiChatMessage) []AgentEvent {
+	var events []AgentEvent
+	for _, m := range messages {
+		if m.Type != "gemini" {
+			continue
+		}
+		for _, th := range m.Thoughts {
+			if text := strings.TrimSpace(th.Subject + ": " + th.Description); text != ":" {
+				events = append(events, AgentEvent{Type: "thinking", Message: truncate(text, 200)})
+			}
+		}
+		events = append(events, geminiTextEvent(geminiContentText(m.Content))...)
+		for _, tc := range m.ToolCalls {
+			events = append(events, AgentEvent{
+				Type:    "tool_call",
+				Tool:    tc.Name,
+				Message: formatGeminiToolCall(tc.Name, tc.Args),
+				Data:    map[string]any{"tool": tc.Name, "tool_use_id": tc.ID, "args": tc.Args},
+			})
+			if tc.Result != nil {
+				out := geminiContentText(tc.Result)
+				events = append(events, AgentEvent{
+					Type:    "tool_result",
+					Message: truncate(out, 200),
+					Data:    map[string]any{"tool_use_id": tc.ID, "status": tc.Status, "content": truncate(out, 2000)},
+				})
+			}
+		}
+	}
+	return events
+}
+
+// geminiContentText flattens the string-or-parts content used in session
+// files. Function responses nest their output under functionResponse.
+func geminiContentText(v any) string {
+	switch c := v.(type) {
+	case string:
+		return c
+	case []any:
+		var parts []string
+		for _, item := range c {
+			if s := geminiContentText(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, "\n")
+	case map[string]any:
+		if text, ok := c["text"].(string); ok {
+			return text
+		}
+		if fr, ok := c["functionResponse"].(map[string]any); ok {
+			if resp, ok := fr["response"].(map[string]any); ok {
+				if out, ok := resp["output"].(string); ok {
+					return out
+				}
+				return jsonStr(resp)
+			}
+		}
+	}
+	return ""
+}
+
+// formatGeminiToolCall maps Gemini CLI tool names onto the summaries used
+// for other tools so run logs read the same.
+func formatGeminiToolCall(name string, args map[string]any) string {
+	str := func(key string) string { s, _ := args[key].(string); return s }
+	switch name {
+	case "read_file":
+		if p := firstNonEmpty(str("absolute_path"), str("file_path"), str("path")); p != "" {
+			return fmt.Sprintf("Read(%s)", p)
+		}
+	case "write_file":
+		if p := str("file_path"); p != "" {
+			return fmt.Sprintf("Write(%s)", p)
+		}
+	case "replace":
+		if p := str("file_path"); p != "" {
+			return fmt.Sprintf("Edit(%s)", p)
+		}
+	case "run_shell_command":
+		if cmd := str("command"); cmd != "" {
+			return fmt.Sprintf("Bash(%s)", truncate(cmd, 80))
+		}
+	case "glob":
+		if p := str("pattern"); p != "" {
+			return fmt.Sprintf("Glob(%s)", p)
+		}
+	case "search_file_content":
+		if p := str("pattern"); p != "" {
+			return fmt.Sprintf("Grep(%s)", p)
+		}
+	}
+	b, _ := json.Marshal(args)
+	return fmt.Sprintf("%s(%s)", name, truncate(string(b), 80))
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+// Tests for the Gemini CLI stream-json and chat session parsers.
+package agentlog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGeminiLog_StreamJSON(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "agent_output.jsonl")
+
+	lines := `{"type":"init","session_id":"s1","model":"gemini-2.5-pro"}
+{"type":"message","role":"user","content":"Fix the build"}
+{"type":"message","role":"assistant","content":"I'll read ","delta":true}
+{"type":"message","role":"assistant","content":"main.go first.","delta":true}
+{"type":"tool_use","tool_name":"read_file","tool_id":"t1","parameters":{"absolute_path":"/w/main.go"}}
+{"type":"tool_result","tool_id":"t1","status":"success","output":"package main"}
+{"type":"tool_use","tool_name":"run_shell_command","tool_id":"t2","parameters":{"command":"go build ./..."}}
+{"type":"tool_result","tool_id":"t2","status":"error","error":{"type":"shell","message":"exit status 1"}}
+{"type":"message","role":"assistant","content":"Done.","delta":true}
+{"type":"result","status":"success","stats":{"total_tokens":10}}
+`
+	os.WriteFile(logPath, []byte(lines), 0o644)
+
+	events, err := ParseGeminiLog(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Expected: text, tool_call(Read), tool_result, tool_call(Bash), tool_result, text
+	if len(events) != 6 {
+		t.Fatalf("expected 6 events, got %d: %+v", len(events), events)
+	}
+	if events[0].Type != "text" || events[0].Data["text"] != "I'll read main.go first." {
+		t.Errorf("event 0: expected joined text, got %s %v", events[0].Type, events[0].Data)
+	}
+	if events[1].Type != "tool_call" || events[1].Message != "Read(/w/main.go)" {
+		t.Errorf("event 1: unexpected %s %q", events[1].Type, events[1].Message)
+	}
+	if events[3].Message != "Bash(go build ./...)" {
+		t.Errorf("event 3: unexpected message %q", events[3].Message)
+	}
+	if events[4].Type != "tool_result" || events[4].Message != "exit status 1" {
+		t.Errorf("event 4: unexpected %s %q", events[4].Type, events[4].Message)
+	}
+	if events[5].Type != "text" || events[5].Data["text"] != "Done." {
+		t.Errorf("event 5: expected final text, got %s %v", events[5].Type, events[5].Data)
+	}
+
+	data, _ := os.ReadFile(logPath)
+	if got := ExtractResponseText("gemini", data); got != "I'll read main.go first.\n\nDone." {
+		t.Errorf("ExtractResponseText = %q", got)
+	}
+}
+
+func TestParseGeminiLog_ChatSession(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "session-2025-01-01T00-00-abc.json")
+
+	session := `{
+  "sessionId": "abc",
+  "messages": [
+    {"type": "user", "content": "Fix the build"},
+    {
+      "type": "gemini",
+      "content": "Editing main.go.",
+      "thoughts": [{"subject": "Plan", "description": "edit then build"}],
+      "toolCalls": [
+        {"id": "c1", "name": "replace", "args": {"file_path": "main.go"}, "status": "success",
+         "result": [{"functionResponse": {"id": "c1", "name": "replace", "response": {"output": "ok"}}}]}
+      ]
+    }
+  ]
+}`
+	os.WriteFile(logPath, []byte(session), 0o644)
+
+	events, err := ParseGeminiLog(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Expected: thinking, text, tool_call(Edit), tool_result
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d: %+v", len(events), events)
+	}
+	if events[0].Type != "thinking" || events[1].Type != "text" {
+		t.Errorf("unexpected leading events: %s, %s", events[0].Type, events[1].Type)
+	}
+	if events[2].Message != "Edit(main.go)" {
+		t.Errorf("event 2: unexpected message %q", events[2].Message)
+	}
+	if events[3].Type != "tool_result" || events[3].Message != "ok" {
+		t.Errorf("event 3: unexpected %s %q", events[3].Type, events[3].Message)
+	}
+}
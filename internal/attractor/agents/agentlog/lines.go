@@ -0,0 +1,63 @@
+// Line-at-a-time parsing shared by the tailer and the whole-file parsers.
+package agentlog
+
+import (
+	"os"
+	"strings"
+)
+
+// LineParser parses a log one raw line at a time. Unlike LineParseFunc it
+// also sees lines that are not JSON and may keep state across lines, for
+// logs where one event spans several lines (Aider's markdown history) or
+// arrives in chunks (Gemini's streamed assistant text).
+type LineParser interface {
+	ParseLine(line string) []AgentEvent
+	// Flush returns any event still being accumulated at end of input.
+	Flush() []AgentEvent
+}
+
+// NewLineParser returns a fresh line parser for a tool, or nil.
+func NewLineParser(toolName string) LineParser {
+	switch toolName {
+	case "gemini":
+		return &geminiStreamParser{}
+	case "aider":
+		return &aiderHistoryParser{}
+	}
+	if parse := LineParserForTool(toolName); parse != nil {
+		return jsonLineParser{parse: parse}
+	}
+	return nil
+}
+
+// jsonLineParser adapts a stateless JSONL LineParseFunc.
+type jsonLineParser struct {
+	parse LineParseFunc
+}
+
+func (j jsonLineParser) ParseLine(line string) []AgentEvent {
+	raw, ok := ParseJSONLine(line)
+	if !ok {
+		return nil
+	}
+	return j.parse(raw)
+}
+
+func (j jsonLineParser) Flush() []AgentEvent { return nil }
+
+// parseLines runs a line parser over a whole file.
+func parseLines(path string, p LineParser) ([]AgentEvent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseData(data, p), nil
+}
+
+func parseData(data []byte, p LineParser) []AgentEvent {
+	var events []AgentEvent
+	for _, line := range strings.Split(string(data), "\n") {
+		events = append(events, p.ParseLine(strings.TrimRight(line, "\r"))...)
+	}
+	return append(events, p.Flush()...)
+}
@@ -22,6 +22,10 @@ func ParserForTool(toolName string) ParseFunc {
 		return ParseCodexLog
 	case "opencode":
 		return ParseOpenCodeLog
+	case "gemini":
+		return ParseGeminiLog
+	case "aider":
+		return ParseAiderLog
 	default:
 		return nil
 	}
@@ -1,4 +1,4 @@
-// Tail a JSONL file and emit parsed events as they appear.
+// Tail a log file and emit parsed events as they appear.
 // Tool-agnostic: works with any CLI agent that writes structured output.
 package agentlog
 
@@ -7,6 +7,7 @@ import (
 	"context"
 	"io"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -23,9 +24,22 @@ type EventCallback func(event AgentEvent)
 // this in a goroutine before the agent runs and cancels ctx when the
 // agent finishes.
 func TailJSONL(ctx context.Context, path string, lineParse LineParseFunc, onEvent EventCallback, cfg TailConfig) {
+	TailLog(ctx, path, jsonLineParser{parse: lineParse}, onEvent, cfg)
+}
+
+// TailLog watches a log file and feeds each complete line to p, calling
+// onEvent for the events it returns. A trailing line without a newline is
+// held back until the writer finishes it. When ctx is canceled the parser
+// is flushed so a block still being accumulated is not lost.
+func TailLog(ctx context.Context, path string, p LineParser, onEvent EventCallback, cfg TailConfig) {
 	if cfg.PollInterval <= 0 {
 		cfg.PollInterval = 500 * time.Millisecond
 	}
+	defer func() {
+		for _, ev := range p.Flush() {
+			onEvent(ev)
+		}
+	}()
 
 	// Wait for the file to appear (agent may not have started writing yet).
 	var f *os.File
@@ -44,28 +58,35 @@ func TailJSONL(ctx context.Context, path string, lineParse LineParseFunc, onEven
 	defer f.Close()
 
 	reader := bufio.NewReaderSize(f, 256*1024)
+	var partial string
 	for {
 		line, err := reader.ReadString('\n')
-		if len(line) > 0 {
-			raw, ok := ParseJSONLine(line)
-			if ok {
-				for _, ev := range lineParse(raw) {
-					onEvent(ev)
-				}
+		if err == nil {
+			for _, ev := range p.ParseLine(strings.TrimRight(partial+line, "\r\n")) {
+				onEvent(ev)
 			}
+			partial = ""
+			continue
+		}
+		partial += line
+		if err != io.EOF {
+			// Read error — stop.
+			return
 		}
-		if err != nil {
-			if err == io.EOF {
-				// No more data — wait and retry.
-				select {
-				case <-ctx.Done():
-					return
-				case <-time.After(cfg.PollInterval):
+		// No more data — wait and retry.
+		select {
+		case <-ctx.Done():
+			// Drain whatever was written since the last poll.
+			rest, _ := io.ReadAll(reader)
+			if tail := strings.TrimSuffix(partial+string(rest), "\n"); tail != "" {
+				for _, line := range strings.Split(tail, "\n") {
+					for _, ev := range p.ParseLine(strings.TrimRight(line, "\r")) {
+						onEvent(ev)
+					}
 				}
-				continue
 			}
-			// Read error — stop.
 			return
+		case <-time.After(cfg.PollInterval):
 		}
 	}
 }
@@ -0,0 +1,54 @@
+// Tests for the log tailer.
+package agentlog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTailLog_HoldsPartialLinesAndFlushes(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "agent_output.jsonl")
+	f, err := os.Create(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var mu sync.Mutex
+	var events []AgentEvent
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		TailLog(ctx, logPath, NewLineParser("gemini"), func(ev AgentEvent) {
+			mu.Lock()
+			events = append(events, ev)
+			mu.Unlock()
+		}, TailConfig{PollInterval: 10 * time.Millisecond})
+	}()
+
+	// A tool_use line split across two writes must parse once, whole.
+	f.WriteString(`{"type":"message","role":"assistant","content":"Reading.","delta":true}` + "\n")
+	f.WriteString(`{"type":"tool_use","tool_name":"glob",`)
+	time.Sleep(50 * time.Millisecond)
+	f.WriteString(`"tool_id":"t1","parameters":{"pattern":"*.go"}}` + "\n")
+	// Trailing delta text is only emitted by Flush when the tailer stops.
+	f.WriteString(`{"type":"message","role":"assistant","content":"All done.","delta":true}` + "\n")
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %+v", len(events), events)
+	}
+	if events[0].Type != "text" || events[1].Message != "Glob(*.go)" || events[2].Data["text"] != "All done." {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
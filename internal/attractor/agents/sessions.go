@@ -0,0 +1,71 @@
+package agents
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/agents/tmux"
+)
+
+// SessionSocket is the tmux socket (tmux -L) that agent sessions run on.
+const SessionSocket = kilroySocket
+
+// SessionName returns the tmux session name used for a node of a run.
+func SessionName(runID, nodeID string) string {
+	return buildSessionName(runID, nodeID)
+}
+
+// RunSessions lists the live agent sessions of a run, keyed by session name.
+// Node IDs are recovered from the session name, so they are the sanitized
+// form (e.g. "a.b" becomes "a_b").
+func RunSessions(m *tmux.Manager, runID string) (map[string]string, error) {
+	names, err := m.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	prefix := buildSessionName(runID, "")
+	out := map[string]string{}
+	for _, name := range names {
+		if node := strings.TrimPrefix(name, prefix); node != name && node != "" {
+			out[name] = node
+		}
+	}
+	return out, nil
+}
+
+// ResolveSession finds the live session for a run's node. With an empty
+// nodeID it prefers preferNode (typically the run's current node), then the
+// run's only session; several candidates are an error naming them.
+func ResolveSession(m *tmux.Manager, runID, nodeID, preferNode string) (string, error) {
+	sessions, err := RunSessions(m, runID)
+	if err != nil {
+		return "", err
+	}
+	if nodeID != "" {
+		name := buildSessionName(runID, nodeID)
+		if _, ok := sessions[name]; !ok {
+			return "", fmt.Errorf("no live tmux session for node %q (expected %s on socket %q)", nodeID, name, SessionSocket)
+		}
+		return name, nil
+	}
+	if preferNode != "" {
+		if name := buildSessionName(runID, preferNode); sessions[name] != "" {
+			return name, nil
+		}
+	}
+	switch len(sessions) {
+	case 0:
+		return "", fmt.Errorf("no live tmux sessions for run %q (was it started with --tmux?)", runID)
+	case 1:
+		for name := range sessions {
+			return name, nil
+		}
+	}
+	nodes := make([]string, 0, len(sessions))
+	for _, node := range sessions {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return "", fmt.Errorf("run %q has %d live sessions; pick one with --node (%s)", runID, len(nodes), strings.Join(nodes, ", "))
+}
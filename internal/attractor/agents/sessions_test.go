@@ -0,0 +1,49 @@
+package agents
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/attractor/agents/tmux"
+)
+
+func TestResolveSession(t *testing.T) {
+	const socket = "kilroy-resolve-test"
+	mgr := tmux.NewManager(socket)
+	defer exec.Command("tmux", "-u", "-L", socket, "kill-server").Run()
+
+	if _, err := ResolveSession(mgr, "r1", "", ""); err == nil || !strings.Contains(err.Error(), "no live tmux sessions") {
+		t.Fatalf("no server: err = %v", err)
+	}
+	for _, name := range []string{SessionName("r1", "impl"), SessionName("r1", "review"), SessionName("r2", "impl")} {
+		if _, err := mgr.CreateSession(name, t.TempDir(), "sleep 30", nil); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+	}
+
+	sessions, err := RunSessions(mgr, "r1")
+	if err != nil || len(sessions) != 2 || sessions["kilroy-r1-impl"] != "impl" {
+		t.Fatalf("RunSessions = %v, %v", sessions, err)
+	}
+	if got, err := ResolveSession(mgr, "r1", "review", "impl"); err != nil || got != "kilroy-r1-review" {
+		t.Fatalf("explicit node: %q, %v", got, err)
+	}
+	if got, err := ResolveSession(mgr, "r1", "", "impl"); err != nil || got != "kilroy-r1-impl" {
+		t.Fatalf("current node: %q, %v", got, err)
+	}
+	if _, err := ResolveSession(mgr, "r1", "", "done"); err == nil || !strings.Contains(err.Error(), "impl, review") {
+		t.Fatalf("ambiguous: err = %v", err)
+	}
+	if got, err := ResolveSession(mgr, "r2", "", ""); err != nil || got != "kilroy-r2-impl" {
+		t.Fatalf("only session: %q, %v", got, err)
+	}
+	if _, err := ResolveSession(mgr, "r1", "missing", ""); err == nil {
+		t.Fatal("expected error for node without a session")
+	}
+
+	cmd := mgr.AttachCommand("kilroy-r1-impl", true)
+	if got := strings.Join(cmd.Args, " "); got != "tmux -u -L "+socket+" attach-session -r -t =kilroy-r1-impl" {
+		t.Fatalf("attach args = %s", got)
+	}
+}
@@ -0,0 +1,49 @@
+// Aider invocation template.
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/agents/agentlog"
+)
+
+// Aider returns an invocation template for Aider in one-shot --message mode.
+// Aider has no JSON output, so its markdown chat history is redirected into
+// the stage dir and parsed as the transcript.
+func Aider() Template {
+	return Template{
+		Name:       "aider",
+		Binary:     "aider",
+		LogLocator: &agentlog.AiderLogLocator{},
+		BuildArgs: func(prompt, workDir, model string) []string {
+			args := []string{"--yes-always", "--no-auto-commits", "--no-gitignore", "--no-check-update", "--no-pretty"}
+			if model != "" {
+				args = append(args, "--model", model)
+			}
+			args = append(args, "--message", prompt)
+			return args
+		},
+		BuildEnv: func() map[string]string {
+			env := map[string]string{}
+			for _, key := range []string{"ANTHROPIC_API_KEY", "OPENAI_API_KEY", "GEMINI_API_KEY", "OPENROUTER_API_KEY", "DEEPSEEK_API_KEY"} {
+				if v := os.Getenv(key); v != "" {
+					env[key] = v
+				}
+			}
+			return env
+		},
+		PrepareSession: func(stageDir string, env map[string]string) error {
+			env["AIDER_CHAT_HISTORY_FILE"] = filepath.Join(stageDir, agentlog.AiderHistoryFile)
+			env["AIDER_INPUT_HISTORY_FILE"] = filepath.Join(stageDir, "aider.input.history")
+			return nil
+		},
+		TranscriptFile:  agentlog.AiderHistoryFile,
+		PromptPrefix:    ">",
+		BusyIndicators:  []string{},
+		ProcessNames:    []string{"aider", "python", "python3"},
+		ExitsOnComplete: true,
+		StartupTimeout:  30 * time.Second,
+	}
+}
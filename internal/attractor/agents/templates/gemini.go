@@ -4,15 +4,18 @@ package templates
 import (
 	"os"
 	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/agents/agentlog"
 )
 
 // Gemini returns an invocation template for Google Gemini CLI.
 func Gemini() Template {
 	return Template{
-		Name:   "gemini",
-		Binary: "gemini",
+		Name:       "gemini",
+		Binary:     "gemini",
+		LogLocator: &agentlog.GeminiLogLocator{},
 		BuildArgs: func(prompt, workDir, model string) []string {
-			args := []string{"--auto-accept-all"}
+			args := []string{"--auto-accept-all", "--output-format", "stream-json"}
 			if model != "" {
 				args = append(args, "--model", model)
 			}
@@ -29,10 +32,11 @@ func Gemini() Template {
 			}
 			return env
 		},
-		PromptPrefix:    ">",
-		BusyIndicators:  []string{},
-		ProcessNames:    []string{"gemini"},
-		ExitsOnComplete: true,
-		StartupTimeout:  15 * time.Second,
+		PromptPrefix:     ">",
+		BusyIndicators:   []string{},
+		ProcessNames:     []string{"gemini"},
+		StructuredOutput: true,
+		ExitsOnComplete:  true,
+		StartupTimeout:   15 * time.Second,
 	}
 }
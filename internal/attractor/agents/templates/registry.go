@@ -13,6 +13,7 @@ func DefaultRegistry() *Registry {
 	r.Register(Codex())
 	r.Register(Gemini())
 	r.Register(OpenCode())
+	r.Register(Aider())
 	return r
 }
 
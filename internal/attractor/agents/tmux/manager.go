@@ -60,7 +60,7 @@ func (m *Manager) ListSessions() ([]string, error) {
 	out, err := m.run("list-sessions", "-F", "#{session_name}")
 	if err != nil {
 		// No server running = no sessions.
-		if strings.Contains(err.Error(), "no server") || strings.Contains(err.Error(), "no sessions") {
+		if strings.Contains(err.Error(), "no server") || strings.Contains(err.Error(), "no sessions") || strings.Contains(err.Error(), "error connecting") {
 			return nil, nil
 		}
 		return nil, err
@@ -98,6 +98,43 @@ func TestSendInputAndCapture(t *testing.T) {
 	}
 }
 
+func TestCaptureSince_IncludesScrolledOutput(t *testing.T) {
+	mgr := testManager()
+	s, err := mgr.CreateSession("test-since", "/tmp", "bash", nil)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer mgr.DestroySession(s.Name)
+	time.Sleep(500 * time.Millisecond)
+
+	if err := mgr.SendInput(s.Name, "echo BEFORE_MARK"); err != nil {
+		t.Fatalf("SendInput: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	mark, err := mgr.LineMark(s.Name)
+	if err != nil {
+		t.Fatalf("LineMark: %v", err)
+	}
+	// Print more than a screen so the start of the reply scrolls away.
+	if err := mgr.SendInput(s.Name, "echo FIRST_LINE; seq 1 120; echo LAST_LINE"); err != nil {
+		t.Fatalf("SendInput: %v", err)
+	}
+	time.Sleep(700 * time.Millisecond)
+
+	out, err := mgr.CaptureSince(s.Name, mark)
+	if err != nil {
+		t.Fatalf("CaptureSince: %v", err)
+	}
+	if strings.Contains(out, "BEFORE_MARK") {
+		t.Fatalf("capture includes output from before the mark:\n%s", out)
+	}
+	for _, want := range []string{"\nFIRST_LINE", "\n1\n", "LAST_LINE"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("capture missing %q:\n%s", want, out)
+		}
+	}
+}
+
 func TestWaitForExit(t *testing.T) {
 	mgr := testManager()
 	// Use sleep to avoid the immediate-exit health check failure.
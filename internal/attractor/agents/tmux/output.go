@@ -3,6 +3,7 @@ package tmux
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -20,6 +21,41 @@ func (m *Manager) CaptureOutput(session string, lines int) (string, error) {
 	return out, nil
 }
 
+// LineMark returns the absolute line of the pane's cursor: the scrollback
+// lines above the screen plus the cursor row. Pass it to CaptureSince to get
+// what the pane printed from then on.
+func (m *Manager) LineMark(session string) (int, error) {
+	out, err := m.displayMessage(session, "#{history_size} #{cursor_y}")
+	if err != nil {
+		return 0, err
+	}
+	var history, cursor int
+	if _, err := fmt.Sscanf(out, "%d %d", &history, &cursor); err != nil {
+		return 0, fmt.Errorf("parse line mark %q: %w", out, err)
+	}
+	return history + cursor, nil
+}
+
+// CaptureSince captures the pane from the line LineMark returned to the
+// bottom, including whatever has scrolled into the scrollback since. Once
+// tmux trims history past the mark (history-limit), the capture starts at
+// the oldest line it still has.
+func (m *Manager) CaptureSince(session string, mark int) (string, error) {
+	out, err := m.displayMessage(session, "#{history_size}")
+	if err != nil {
+		return "", err
+	}
+	history, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return "", fmt.Errorf("parse history_size %q: %w", out, err)
+	}
+	start := "-"
+	if mark >= 0 && mark-history >= -history {
+		start = strconv.Itoa(mark - history)
+	}
+	return m.run("capture-pane", "-p", "-t", session, "-S", start)
+}
+
 // CaptureLines captures the last N lines and returns them as a slice.
 func (m *Manager) CaptureLines(session string, lines int) ([]string, error) {
 	out, err := m.CaptureOutput(session, lines)
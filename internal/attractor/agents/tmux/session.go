@@ -3,6 +3,8 @@ package tmux
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
 	"sort"
 	"strconv"
 	"strings"
@@ -124,3 +126,26 @@ func (m *Manager) PaneExitStatus(name string) int {
 	}
 	return code
 }
+
+// AttachCommand returns a command that attaches the caller's terminal to a
+// session. Read-only clients can watch but not type.
+func (m *Manager) AttachCommand(name string, readOnly bool) *exec.Cmd {
+	args := []string{"-u"}
+	if m.socket != "" {
+		args = append(args, "-L", m.socket)
+	}
+	args = append(args, "attach-session")
+	if readOnly {
+		args = append(args, "-r")
+	}
+	args = append(args, "-t", "="+name)
+	cmd := exec.Command("tmux", args...)
+	// Attaching from inside another tmux is refused while $TMUX is set; the
+	// sessions live on a separate socket, so nesting is intended here.
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "TMUX=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	return cmd
+}
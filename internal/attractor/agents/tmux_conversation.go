@@ -0,0 +1,356 @@
+// Scripted multi-turn conversations for tmux agent nodes: after each exchange
+// the handler checks the pane output and the stage status file, then either
+// stops or sends the next follow-up prompt into the same session.
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/agents/tmux"
+	"github.com/danshapiro/kilroy/internal/attractor/cond"
+	"github.com/danshapiro/kilroy/internal/attractor/engine"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+const defaultConversationMaxTurns = 5
+
+// conversationScript drives a tmux node through several exchanges.
+type conversationScript struct {
+	MaxTurns    int                     `yaml:"max_turns"`
+	Until       string                  `yaml:"until"`
+	UntilOutput string                  `yaml:"until_output"`
+	Followups   []*conversationFollowup `yaml:"followups"`
+
+	untilRe *regexp.Regexp
+}
+
+// conversationFollowup is a prompt sent when its conditions match the last
+// exchange. When is a condition expression over the status file outcome and
+// the run context; WhenOutput is a regexp over the agent's reply.
+type conversationFollowup struct {
+	Name       string `yaml:"name"`
+	When       string `yaml:"when"`
+	WhenOutput string `yaml:"when_output"`
+	Prompt     string `yaml:"prompt"`
+	Max        int    `yaml:"max"`
+
+	re   *regexp.Regexp
+	sent int
+}
+
+// conversationTurn is one line of conversation.jsonl in the stage directory.
+type conversationTurn struct {
+	Turn          int    `json:"turn"`
+	Followup      string `json:"followup,omitempty"`
+	Prompt        string `json:"prompt"`
+	Reply         string `json:"reply"`
+	Status        string `json:"status,omitempty"`
+	FailureReason string `json:"failure_reason,omitempty"`
+	TimestampMS   int64  `json:"timestamp_ms"`
+}
+
+// conversationResult summarizes a finished conversation.
+type conversationResult struct {
+	Turns    int
+	UntilMet bool
+	Stop     string // until, no_followup, max_turns, exited, wait_error
+	WaitErr  error
+}
+
+// loadConversation reads the node's conversation configuration. It returns
+// nil when the node is a single-prompt node. A file named by
+// tmux.conversation is read first:
+//
+//	max_turns: 4
+//	until: "outcome=success"
+//	followups:
+//	  - name: rerun
+//	    when: "outcome=fail"
+//	    prompt: "The tests still fail. Run them again and fix what breaks."
+//	  - name: nudge
+//	    when_output: "(?i)should I continue"
+//	    prompt: "Yes, continue."
+//	    max: 1
+//
+// The inline attributes tmux.followup (with tmux.followup_when and
+// tmux.followup_when_output), tmux.until, tmux.until_output and
+// tmux.max_turns add to or override the file.
+func loadConversation(exec *engine.Execution, node *model.Node) (*conversationScript, error) {
+	conv := &conversationScript{}
+	configured := false
+	if path := strings.TrimSpace(node.Attr("tmux.conversation", "")); path != "" {
+		if !filepath.IsAbs(path) && exec != nil && exec.WorktreeDir != "" {
+			path = filepath.Join(exec.WorktreeDir, path)
+		}
+		if err := engine.LoadYAMLFile(path, conv); err != nil {
+			return nil, err
+		}
+		configured = true
+	}
+	if prompt := strings.TrimSpace(node.Attr("tmux.followup", "")); prompt != "" {
+		conv.Followups = append(conv.Followups, &conversationFollowup{
+			Name:       "followup",
+			When:       node.Attr("tmux.followup_when", ""),
+			WhenOutput: node.Attr("tmux.followup_when_output", ""),
+			Prompt:     prompt,
+		})
+		configured = true
+	}
+	if v := strings.TrimSpace(node.Attr("tmux.until", "")); v != "" {
+		conv.Until = v
+	}
+	if v := strings.TrimSpace(node.Attr("tmux.until_output", "")); v != "" {
+		conv.UntilOutput = v
+	}
+	if v := strings.TrimSpace(node.Attr("tmux.max_turns", "")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid tmux.max_turns %q", v)
+		}
+		conv.MaxTurns = n
+	}
+	if !configured {
+		return nil, nil
+	}
+	if conv.MaxTurns <= 0 {
+		conv.MaxTurns = defaultConversationMaxTurns
+	}
+	if len(conv.Followups) == 0 {
+		return nil, fmt.Errorf("conversation has no followups")
+	}
+	if err := checkCondition(conv.Until); err != nil {
+		return nil, fmt.Errorf("invalid until %q: %w", conv.Until, err)
+	}
+	if s := strings.TrimSpace(conv.UntilOutput); s != "" {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid until_output: %w", err)
+		}
+		conv.untilRe = re
+	}
+	for i, f := range conv.Followups {
+		if f == nil || strings.TrimSpace(f.Prompt) == "" {
+			return nil, fmt.Errorf("followup %d has no prompt", i+1)
+		}
+		if strings.TrimSpace(f.Name) == "" {
+			f.Name = fmt.Sprintf("followup_%d", i+1)
+		}
+		if err := checkCondition(f.When); err != nil {
+			return nil, fmt.Errorf("followup %q: invalid when %q: %w", f.Name, f.When, err)
+		}
+		if s := strings.TrimSpace(f.WhenOutput); s != "" {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("followup %q: invalid when_output: %w", f.Name, err)
+			}
+			f.re = re
+		}
+	}
+	return conv, nil
+}
+
+func checkCondition(expr string) error {
+	if strings.TrimSpace(expr) == "" {
+		return nil
+	}
+	_, err := cond.Evaluate(expr, runtime.Outcome{}, runtime.NewContext())
+	return err
+}
+
+// hasUntil reports whether the conversation has a success condition.
+func (c *conversationScript) hasUntil() bool {
+	return strings.TrimSpace(c.Until) != "" || c.untilRe != nil
+}
+
+// satisfied reports whether the until conditions hold for the last exchange.
+func (c *conversationScript) satisfied(out runtime.Outcome, rctx *runtime.Context, reply string) bool {
+	if !c.hasUntil() {
+		return false
+	}
+	return conditionsMatch(c.Until, c.untilRe, out, rctx, reply)
+}
+
+// next returns the first follow-up whose conditions match, or nil.
+func (c *conversationScript) next(out runtime.Outcome, rctx *runtime.Context, reply string) *conversationFollowup {
+	for _, f := range c.Followups {
+		if f.Max > 0 && f.sent >= f.Max {
+			continue
+		}
+		if conditionsMatch(f.When, f.re, out, rctx, reply) {
+			return f
+		}
+	}
+	return nil
+}
+
+func conditionsMatch(when string, re *regexp.Regexp, out runtime.Outcome, rctx *runtime.Context, reply string) bool {
+	if w := strings.TrimSpace(when); w != "" {
+		ok, err := cond.Evaluate(w, out, rctx)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	if re != nil && !re.MatchString(reply) {
+		return false
+	}
+	return true
+}
+
+// converse runs the follow-up loop after the first exchange has gone idle
+// (firstErr is that wait's result). Every exchange, including the first, is
+// recorded to conversation.jsonl, progress and CXDB.
+func (h *TmuxAgentHandler) converse(ctx context.Context, exec *engine.Execution, node *model.Node, conv *conversationScript, session, stageDir, firstPrompt, modelID string, waitCfg tmux.WaitConfig, deadline time.Time, firstErr error) conversationResult {
+	contract := engine.BuildStageStatusContract(exec.WorktreeDir)
+	transcript, _ := os.Create(filepath.Join(stageDir, "conversation.jsonl"))
+	if transcript != nil {
+		defer transcript.Close()
+	}
+
+	res := conversationResult{WaitErr: firstErr}
+	// mark is the pane line each follow-up was typed at; a reply is
+	// everything printed from there, scrollback included. The first reply
+	// is the whole pane.
+	mark := -1
+	prompt, followup := firstPrompt, ""
+	for turn := 1; ; turn++ {
+		var pane string
+		if mark < 0 {
+			pane, _ = h.Tmux.CaptureOutput(session, 0)
+		} else {
+			pane, _ = h.Tmux.CaptureSince(session, mark)
+		}
+		reply := strings.TrimSpace(pane)
+		out := readConversationStatus(contract)
+		res.Turns = turn
+
+		rec := conversationTurn{
+			Turn:          turn,
+			Followup:      followup,
+			Prompt:        prompt,
+			Reply:         reply,
+			Status:        string(out.Status),
+			FailureReason: out.FailureReason,
+			TimestampMS:   time.Now().UnixMilli(),
+		}
+		if transcript != nil {
+			if b, err := json.Marshal(rec); err == nil {
+				_, _ = transcript.Write(append(b, '\n'))
+			}
+		}
+		if exec.Engine != nil {
+			exec.Engine.CXDBPrompt(ctx, node.ID, prompt)
+			exec.Engine.CXDBAssistantMessage(ctx, node.ID, reply, modelID)
+			exec.Engine.AppendProgress(map[string]any{
+				"event":     "tmux_conversation_turn",
+				"node_id":   node.ID,
+				"turn":      turn,
+				"followup":  followup,
+				"status":    string(out.Status),
+				"reply_len": len(reply),
+			})
+		}
+
+		if res.WaitErr != nil {
+			res.Stop = "wait_error"
+			return res
+		}
+		rctx := conversationContext(exec, out)
+		if conv.satisfied(out, rctx, reply) {
+			res.UntilMet = true
+			res.Stop = "until"
+			return res
+		}
+		if h.Tmux.CheckHealth(session) != tmux.Healthy {
+			res.Stop = "exited"
+			return res
+		}
+		if turn >= conv.MaxTurns {
+			res.Stop = "max_turns"
+			return res
+		}
+		f := conv.next(out, rctx, reply)
+		if f == nil {
+			res.Stop = "no_followup"
+			return res
+		}
+		f.sent++
+		prompt, followup = strings.TrimSpace(f.Prompt), f.Name
+
+		// The agent reports each exchange afresh; a stale status file would
+		// otherwise satisfy (or fail) the next turn's conditions.
+		for _, p := range contract.Fallbacks {
+			_ = os.Remove(p.Path)
+		}
+		if m, err := h.Tmux.LineMark(session); err == nil {
+			mark = m
+		}
+		if err := h.Tmux.SendInput(session, prompt); err != nil {
+			res.WaitErr = fmt.Errorf("send followup %q: %w", f.Name, err)
+			continue
+		}
+		res.WaitErr = h.Tmux.WaitForIdle(ctx, session, waitCfg, time.Until(deadline))
+	}
+}
+
+// conversationContext is the run context with the status file's context
+// updates applied, so conditions can test keys the agent just reported.
+func conversationContext(exec *engine.Execution, out runtime.Outcome) *runtime.Context {
+	var rctx *runtime.Context
+	if exec != nil && exec.Context != nil {
+		rctx = exec.Context.Clone()
+	} else {
+		rctx = runtime.NewContext()
+	}
+	if len(out.ContextUpdates) > 0 {
+		rctx.ApplyUpdates(out.ContextUpdates)
+	}
+	return rctx
+}
+
+// readConversationStatus decodes the first status file the agent wrote. A
+// missing or unreadable file yields an empty outcome.
+func readConversationStatus(contract engine.StageStatusContract) runtime.Outcome {
+	for _, p := range contract.Fallbacks {
+		b, err := os.ReadFile(p.Path)
+		if err != nil {
+			continue
+		}
+		if out, err := runtime.DecodeOutcomeJSON(b); err == nil {
+			return out
+		}
+	}
+	return runtime.Outcome{}
+}
+
+// conversationOutcome maps a finished conversation to the node outcome. A
+// conversation with an until condition fails when it ends without meeting it.
+func conversationOutcome(conv *conversationScript, res conversationResult, nodeID, toolName, output string) runtime.Outcome {
+	updates := map[string]any{
+		"last_stage":    nodeID,
+		"last_response": engine.Truncate(output, 200),
+		"tmux.turns":    res.Turns,
+	}
+	if conv.hasUntil() && !res.UntilMet {
+		updates["failure_class"] = "deterministic"
+		return runtime.Outcome{
+			Status:         runtime.StatusFail,
+			FailureReason:  fmt.Sprintf("conversation ended after %d turn(s) (%s) without meeting until", res.Turns, res.Stop),
+			Meta:           map[string]any{"failure_class": "deterministic", "turns": res.Turns, "stop": res.Stop},
+			ContextUpdates: updates,
+		}
+	}
+	return runtime.Outcome{
+		Status:         runtime.StatusSuccess,
+		Notes:          fmt.Sprintf("agent completed %d turn(s) via tmux (%s, %s)", res.Turns, toolName, res.Stop),
+		ContextUpdates: updates,
+	}
+}
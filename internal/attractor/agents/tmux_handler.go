@@ -75,6 +75,33 @@ func (h *TmuxAgentHandler) Execute(ctx context.Context, exec *engine.Execution,
 	}
 	sessionName := buildSessionName(runID, node.ID)
 
+	// Multi-turn mode: the session stays open and follow-ups are sent until
+	// the conversation's until condition holds.
+	conv, err := loadConversation(exec, node)
+	if err != nil {
+		return runtime.Outcome{
+			Status:         runtime.StatusFail,
+			FailureReason:  fmt.Sprintf("tmux conversation: %v", err),
+			Meta:           map[string]any{"failure_class": "deterministic"},
+			ContextUpdates: map[string]any{"failure_class": "deterministic"},
+		}, nil
+	}
+	if conv != nil && tmpl.ExitsOnComplete {
+		return runtime.Outcome{
+			Status:         runtime.StatusFail,
+			FailureReason:  fmt.Sprintf("tmux conversation needs an interactive tool; %q exits after one prompt", toolName),
+			Meta:           map[string]any{"failure_class": "deterministic"},
+			ContextUpdates: map[string]any{"failure_class": "deterministic"},
+		}, nil
+	}
+	if conv != nil {
+		// Clear stale status files from prior stages so the first turn's
+		// conditions only see what this agent reports.
+		for _, p := range engine.BuildStageStatusContract(exec.WorktreeDir).Fallbacks {
+			_ = os.Remove(p.Path)
+		}
+	}
+
 	// Build environment variables.
 	env := buildTmuxAgentEnv(tmpl, exec, node.ID)
 
@@ -138,19 +165,41 @@ func (h *TmuxAgentHandler) Execute(ctx context.Context, exec *engine.Execution,
 		h.handleStartupDialog(session.Name, dialog, tmpl.StartupTimeout)
 	}
 
-	// Start real-time log tailer if structured output is enabled.
+	// A supervising manager loop steers interactive tools by typing into the
+	// pane; tools that run one prompt and exit take no input.
+	if exec.Engine != nil {
+		var deliver func(string) error
+		if !tmpl.ExitsOnComplete {
+			deliver = func(msg string) error { return h.Tmux.SendInput(sessionName, msg) }
+		}
+		defer exec.Engine.AttachSteering(node.ID, deliver)()
+	}
+
+	// Start real-time log tailer if the tool writes a log we can follow:
+	// redirected structured output, or a transcript file in the stage dir.
 	// Emits agent events to RunLog as they appear, rather than waiting
-	// for completion. Works for any CLI tool that writes JSONL.
+	// for completion.
+	transcriptPath := ""
+	switch {
+	case tmpl.StructuredOutput:
+		transcriptPath = agentOutputPath
+	case tmpl.TranscriptFile != "":
+		transcriptPath = filepath.Join(stageDir, tmpl.TranscriptFile)
+	}
 	var tailCancel context.CancelFunc
-	if tmpl.StructuredOutput && exec.Engine != nil && exec.Engine.RunLog != nil {
-		lineParser := agentlog.LineParserForTool(tmpl.Name)
-		if lineParser != nil {
+	if transcriptPath != "" && exec.Engine != nil && exec.Engine.RunLog != nil {
+		if lineParser := agentlog.NewLineParser(tmpl.Name); lineParser != nil {
 			tailCtx, cancel := context.WithCancel(ctx)
-			tailCancel = cancel
-			go agentlog.TailJSONL(tailCtx, agentOutputPath, lineParser, func(ev agentlog.AgentEvent) {
-				exec.Engine.RunLog.Info("agent", node.ID, ev.Type, ev.Message, ev.Data)
-				exec.Engine.TickStallWatchdog()
-			}, agentlog.TailConfig{PollInterval: 500 * time.Millisecond})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				agentlog.TailLog(tailCtx, transcriptPath, lineParser, func(ev agentlog.AgentEvent) {
+					exec.Engine.RunLog.Info("agent", node.ID, ev.Type, ev.Message, ev.Data)
+					exec.Engine.TickStallWatchdog()
+				}, agentlog.TailConfig{PollInterval: 500 * time.Millisecond})
+			}()
+			// Wait for the tailer to flush buffered events before moving on.
+			tailCancel = func() { cancel(); <-done }
 		}
 	}
 
@@ -162,15 +211,22 @@ func (h *TmuxAgentHandler) Execute(ctx context.Context, exec *engine.Execution,
 
 	// Wait for completion.
 	var waitErr error
+	var convResult conversationResult
 	if tmpl.ExitsOnComplete {
 		waitErr = h.Tmux.WaitForExit(ctx, sessionName, timeout)
 	} else {
-		waitErr = h.Tmux.WaitForIdle(ctx, sessionName, tmux.WaitConfig{
+		deadline := time.Now().Add(timeout)
+		waitCfg := tmux.WaitConfig{
 			PromptPrefix:    tmpl.PromptPrefix,
 			BusyIndicators:  tmpl.BusyIndicators,
 			ConsecutiveIdle: 2,
 			PollInterval:    200 * time.Millisecond,
-		}, timeout)
+		}
+		waitErr = h.Tmux.WaitForIdle(ctx, sessionName, waitCfg, timeout)
+		if conv != nil {
+			convResult = h.converse(ctx, exec, node, conv, sessionName, stageDir, prompt, modelID, waitCfg, deadline, waitErr)
+			waitErr = convResult.WaitErr
+		}
 	}
 
 	// Stop the real-time log tailer — give it a moment to drain remaining lines.
@@ -185,10 +241,10 @@ func (h *TmuxAgentHandler) Execute(ctx context.Context, exec *engine.Execution,
 	exitCode := h.Tmux.PaneExitStatus(sessionName)
 
 	// When structured output was redirected to a file, the pane is empty.
-	// Extract the response text from the JSONL and also save the raw JSONL.
-	if tmpl.StructuredOutput {
-		if jsonlData, err := os.ReadFile(agentOutputPath); err == nil {
-			responseText := agentlog.ExtractResponseText(tmpl.Name, jsonlData)
+	// Extract the response text from the JSONL (or the tool's transcript).
+	if transcriptPath != "" {
+		if data, err := os.ReadFile(transcriptPath); err == nil {
+			responseText := agentlog.ExtractResponseText(tmpl.Name, data)
 			if responseText != "" {
 				output = responseText
 			}
@@ -216,6 +272,7 @@ func (h *TmuxAgentHandler) Execute(ctx context.Context, exec *engine.Execution,
 			"exit_code":  exitCode,
 			"output_len": len(output),
 			"wait_error": fmt.Sprint(waitErr),
+			"turns":      convResult.Turns,
 		})
 	}
 
@@ -246,6 +303,10 @@ func (h *TmuxAgentHandler) Execute(ctx context.Context, exec *engine.Execution,
 		}, nil
 	}
 
+	if conv != nil {
+		return conversationOutcome(conv, convResult, node.ID, toolName, output), nil
+	}
+
 	return runtime.Outcome{
 		Status: runtime.StatusSuccess,
 		Notes:  fmt.Sprintf("agent completed via tmux (%s)", toolName),
@@ -257,11 +318,14 @@ func (h *TmuxAgentHandler) Execute(ctx context.Context, exec *engine.Execution,
 }
 
 // emitAgentLogEvents parses the agent's structured output and emits events to RunLog.
-// Reads from the known agent_output.jsonl in the stage dir first, falls back to
-// the template's LogLocator for non-structured-output modes.
+// Reads from the known agent_output.jsonl or the template's transcript file in
+// the stage dir first, falls back to the template's LogLocator otherwise.
 func (h *TmuxAgentHandler) emitAgentLogEvents(exec *engine.Execution, nodeID string, tmpl *templates.Template, stageDir string, startedAfter time.Time) {
 	// Primary: read from known output file.
 	logPath := filepath.Join(stageDir, "agent_output.jsonl")
+	if _, err := os.Stat(logPath); err != nil && tmpl.TranscriptFile != "" {
+		logPath = filepath.Join(stageDir, tmpl.TranscriptFile)
+	}
 	if _, err := os.Stat(logPath); err != nil {
 		// Fallback: use LogLocator to find tool-specific log files.
 		if tmpl.LogLocator != nil {
@@ -243,3 +243,149 @@ func TestTmuxAgentHandler_FakeAgent_WorksInWorkDir(t *testing.T) {
 		t.Fatalf("file content = %q, want 'hello from agent'", string(data))
 	}
 }
+
+// writeInteractiveFakeAgent creates a REPL-style agent that reports failing
+// tests until it has been prompted passAfter more times, then reports success.
+func writeInteractiveFakeAgent(t *testing.T, dir string, passAfter int) string {
+	t.Helper()
+	script := filepath.Join(dir, "fake-repl")
+	content := fmt.Sprintf(`#!/bin/bash
+n=0
+report() {
+  if [ $n -ge %d ]; then
+    echo '{"status":"success"}' > "$KILROY_STAGE_STATUS_PATH"; echo "TESTS PASS"
+  else
+    echo '{"status":"fail","failure_reason":"1 test failing"}' > "$KILROY_STAGE_STATUS_PATH"; echo "TESTS FAIL"
+  fi
+}
+report
+while true; do
+  printf '> '
+  read line || exit 0
+  n=$((n+1))
+  echo "got: $line"
+  report
+done
+`, passAfter)
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatalf("write fake agent: %v", err)
+	}
+	return script
+}
+
+func TestTmuxAgentHandler_Conversation_FollowupsUntilGreen(t *testing.T) {
+	cases := []struct {
+		name       string
+		maxTurns   string
+		wantStatus runtime.StageStatus
+		wantTurns  int
+	}{
+		{name: "until_met", maxTurns: "5", wantStatus: runtime.StatusSuccess, wantTurns: 3},
+		{name: "max_turns", maxTurns: "2", wantStatus: runtime.StatusFail, wantTurns: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := fakeAgentTemplate(writeInteractiveFakeAgent(t, t.TempDir(), 2))
+			tmpl.ExitsOnComplete = false
+			tmpl.PromptPrefix = ">"
+			reg := templates.DefaultRegistry()
+			reg.Register(tmpl)
+
+			mgr := tmux.NewManager(testSocket)
+			defer exec.Command("tmux", "-u", "-L", testSocket, "kill-server").Run()
+			handler := &TmuxAgentHandler{Tmux: mgr, Templates: reg, Timeout: 30 * time.Second}
+
+			logsRoot := t.TempDir()
+			node := model.NewNode("convo")
+			node.Attrs["agent_tool"] = "fake"
+			node.Attrs["prompt"] = "make the tests pass"
+			node.Attrs["tmux.followup"] = "run the tests again"
+			node.Attrs["tmux.followup_when"] = "outcome=fail"
+			node.Attrs["tmux.until"] = "outcome=success"
+			node.Attrs["tmux.max_turns"] = tc.maxTurns
+
+			execCtx := &engine.Execution{
+				Graph:       model.NewGraph("test"),
+				Context:     runtime.NewContext(),
+				LogsRoot:    logsRoot,
+				WorktreeDir: t.TempDir(),
+				Engine:      &engine.Engine{Options: engine.RunOptions{RunID: "test-run-convo-" + tc.name}},
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+
+			outcome, err := handler.Execute(ctx, execCtx, node)
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if outcome.Status != tc.wantStatus {
+				t.Fatalf("status = %q, want %q (reason: %s)", outcome.Status, tc.wantStatus, outcome.FailureReason)
+			}
+			if got := outcome.ContextUpdates["tmux.turns"]; got != tc.wantTurns {
+				t.Fatalf("turns = %v, want %d", got, tc.wantTurns)
+			}
+
+			data, err := os.ReadFile(filepath.Join(logsRoot, "convo", "conversation.jsonl"))
+			if err != nil {
+				t.Fatalf("read conversation.jsonl: %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != tc.wantTurns {
+				t.Fatalf("conversation.jsonl has %d turns, want %d:\n%s", len(lines), tc.wantTurns, data)
+			}
+			if !strings.Contains(lines[1], `"followup":"followup"`) || !strings.Contains(lines[1], "got: run the tests again") {
+				t.Fatalf("turn 2 = %s", lines[1])
+			}
+			if tc.wantStatus == runtime.StatusSuccess && !strings.Contains(lines[2], `"status":"success"`) {
+				t.Fatalf("last turn = %s", lines[2])
+			}
+		})
+	}
+}
+
+func TestLoadConversation_FileAndValidation(t *testing.T) {
+	wt := t.TempDir()
+	yml := `max_turns: 3
+until_output: "TESTS PASS"
+followups:
+  - when: "outcome=fail"
+    prompt: "run the tests again"
+  - name: nudge
+    when_output: "continue\\?"
+    prompt: "yes"
+    max: 1
+`
+	if err := os.WriteFile(filepath.Join(wt, "convo.yaml"), []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	execCtx := &engine.Execution{WorktreeDir: wt}
+
+	node := model.NewNode("n")
+	if conv, err := loadConversation(execCtx, node); conv != nil || err != nil {
+		t.Fatalf("plain node: conv=%v err=%v", conv, err)
+	}
+
+	node.Attrs["tmux.conversation"] = "convo.yaml"
+	conv, err := loadConversation(execCtx, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.MaxTurns != 3 || len(conv.Followups) != 2 || conv.Followups[0].Name != "followup_1" || !conv.hasUntil() {
+		t.Fatalf("conv = %+v", conv)
+	}
+	fail := runtime.Outcome{Status: runtime.StatusFail}
+	if f := conv.next(fail, runtime.NewContext(), "1 failing"); f == nil || f.Name != "followup_1" {
+		t.Fatalf("next(fail) = %+v", f)
+	}
+	if f := conv.next(runtime.Outcome{}, runtime.NewContext(), "shall I continue?"); f == nil || f.Name != "nudge" {
+		t.Fatalf("next(output) = %+v", f)
+	}
+	if !conv.satisfied(runtime.Outcome{}, runtime.NewContext(), "TESTS PASS") {
+		t.Fatal("until_output did not match")
+	}
+
+	node.Attrs["tmux.until_output"] = "("
+	if _, err := loadConversation(execCtx, node); err == nil {
+		t.Fatal("expected invalid until_output to be rejected")
+	}
+}
@@ -5,14 +5,47 @@ import "fmt"
 // stripComments removes // and /* */ comments from DOT source, while preserving comment-like
 // sequences inside double-quoted strings.
 func stripComments(src []byte) ([]byte, error) {
+	spans, err := commentSpans(src)
+	if err != nil {
+		return nil, err
+	}
 	out := make([]byte, 0, len(src))
+	last := 0
+	for _, sp := range spans {
+		out = append(out, src[last:sp.Start]...)
+		last = sp.End
+	}
+	return append(out, src[last:]...), nil
+}
+
+// blankComments replaces comment bytes with spaces (keeping newlines) so token
+// offsets in the result line up with the original source.
+func blankComments(src []byte) ([]byte, []Span, error) {
+	spans, err := commentSpans(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := append([]byte(nil), src...)
+	for _, sp := range spans {
+		for i := sp.Start; i < sp.End; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+	return out, spans, nil
+}
+
+// commentSpans returns the byte ranges of // and /* */ comments in src. Line
+// comment spans stop before the newline.
+func commentSpans(src []byte) ([]Span, error) {
+	var spans []Span
 	inString := false
 	escaped := false
 
 	for i := 0; i < len(src); {
 		ch := src[i]
 		if inString {
-			out = append(out, ch)
 			i++
 			if escaped {
 				escaped = false
@@ -31,7 +64,6 @@ func stripComments(src []byte) ([]byte, error) {
 		// Not in string: detect comment starts.
 		if ch == '"' {
 			inString = true
-			out = append(out, ch)
 			i++
 			continue
 		}
@@ -40,14 +72,17 @@ func stripComments(src []byte) ([]byte, error) {
 			next := src[i+1]
 			if next == '/' {
 				// Line comment: skip until newline (but keep the newline).
+				start := i
 				i += 2
 				for i < len(src) && src[i] != '\n' {
 					i++
 				}
+				spans = append(spans, Span{Start: start, End: i})
 				continue
 			}
 			if next == '*' {
 				// Block comment: skip until closing */.
+				start := i
 				i += 2
 				for i+1 < len(src) && !(src[i] == '*' && src[i+1] == '/') {
 					i++
@@ -56,15 +91,15 @@ func stripComments(src []byte) ([]byte, error) {
 					return nil, fmt.Errorf("dot: unterminated block comment")
 				}
 				i += 2
+				spans = append(spans, Span{Start: start, End: i})
 				continue
 			}
 		}
 
-		out = append(out, ch)
 		i++
 	}
 	if inString {
 		return nil, fmt.Errorf("dot: unterminated string (while stripping comments)")
 	}
-	return out, nil
+	return spans, nil
 }
@@ -0,0 +1,760 @@
+package dot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+)
+
+// Span is a half-open byte range [Start, End) in DOT source.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Attr is a key/value pair to write into a DOT attribute block.
+type Attr struct {
+	Key   string
+	Value string
+}
+
+// Document is an editable view of DOT source. Edits splice only the bytes
+// they change, so comments, statement order and formatting elsewhere survive
+// a rewrite. Every edit re-indexes the document from the new source.
+type Document struct {
+	src       []byte
+	name      string
+	stmts     []*docStmt
+	comments  []Span
+	rootOpen  int // offset of the graph's '{'
+	rootClose int // offset of the graph's '}'
+}
+
+type stmtKind int
+
+const (
+	stmtNode stmtKind = iota
+	stmtEdge
+	stmtGraphAttrs   // graph [ ... ]
+	stmtNodeDefaults // node [ ... ]
+	stmtEdgeDefaults // edge [ ... ]
+	stmtGraphAttr    // key = value
+	stmtSubgraphOpen
+	stmtSubgraphClose
+)
+
+type docAttr struct {
+	key      string
+	keyStart int
+	value    string
+	span     Span // value bytes, including quotes when quoted
+	quoted   bool
+}
+
+type docStmt struct {
+	kind     stmtKind
+	ids      []string
+	idSpans  []Span
+	attrs    []docAttr
+	block    Span // [ ... ] including brackets; valid when hasBlock
+	hasBlock bool
+	span     Span
+	depth    int
+}
+
+// ParseDocument indexes DOT source for editing. The source must also be
+// accepted by Parse; the same error is returned when it is not.
+func ParseDocument(src []byte) (*Document, error) {
+	if _, err := Parse(src); err != nil {
+		return nil, err
+	}
+	blank, comments, err := blankComments(src)
+	if err != nil {
+		return nil, err
+	}
+	lx := newLexer(blank)
+	var toks []token
+	for {
+		tok, err := lx.next()
+		if err != nil {
+			return nil, err
+		}
+		toks = append(toks, tok)
+		if tok.typ == tokenEOF {
+			break
+		}
+	}
+	w := &docWalker{toks: toks}
+	// digraph <Identifier> {
+	w.i = 3
+	if err := w.body(0); err != nil {
+		return nil, err
+	}
+	return &Document{
+		src:       append([]byte(nil), src...),
+		name:      toks[1].lit,
+		stmts:     w.stmts,
+		comments:  comments,
+		rootOpen:  toks[2].pos,
+		rootClose: w.toks[w.i].pos,
+	}, nil
+}
+
+// docWalker mirrors parser.parseStatements over a token slice, recording
+// statement spans instead of building a graph. Syntax has already been
+// checked by Parse, so the walker only has to be correct for valid input.
+type docWalker struct {
+	toks  []token
+	i     int
+	stmts []*docStmt
+}
+
+func (w *docWalker) peek() token { return w.toks[w.i] }
+
+func (w *docWalker) isSym(lit string) bool {
+	t := w.toks[w.i]
+	return t.typ == tokenSymbol && t.lit == lit
+}
+
+func (w *docWalker) body(depth int) error {
+	for {
+		tok := w.peek()
+		if tok.typ == tokenEOF {
+			return fmt.Errorf("dot document: unexpected EOF")
+		}
+		if w.isSym("}") {
+			return nil
+		}
+		if w.isSym(";") {
+			w.i++
+			continue
+		}
+		w.i++
+		st := &docStmt{depth: depth, span: Span{Start: tok.pos}}
+		switch tok.lit {
+		case "graph", "node", "edge":
+			st.kind = map[string]stmtKind{"graph": stmtGraphAttrs, "node": stmtNodeDefaults, "edge": stmtEdgeDefaults}[tok.lit]
+			w.attrBlock(st)
+		case "subgraph":
+			st.kind = stmtSubgraphOpen
+			if w.peek().typ == tokenIdent {
+				st.ids = []string{w.peek().lit}
+				st.idSpans = []Span{{w.peek().pos, w.peek().end}}
+				w.i++
+			}
+			st.span.End = w.peek().end // '{'
+			w.i++
+			w.stmts = append(w.stmts, st)
+			if err := w.body(depth + 1); err != nil {
+				return err
+			}
+			closeTok := w.peek()
+			w.i++
+			w.stmts = append(w.stmts, &docStmt{kind: stmtSubgraphClose, depth: depth, span: Span{closeTok.pos, closeTok.end}})
+			continue
+		default:
+			st.ids = []string{tok.lit}
+			st.idSpans = []Span{{tok.pos, tok.end}}
+			st.span.End = tok.end
+			switch {
+			case w.isSym("="):
+				st.kind = stmtGraphAttr
+				w.i++
+				a := docAttr{key: tok.lit, keyStart: tok.pos}
+				w.value(&a, true)
+				st.attrs = []docAttr{a}
+				st.span.End = a.span.End
+			case w.isSym("->"):
+				st.kind = stmtEdge
+				for w.isSym("->") {
+					w.i++
+					to := w.peek()
+					w.i++
+					st.ids = append(st.ids, to.lit)
+					st.idSpans = append(st.idSpans, Span{to.pos, to.end})
+					st.span.End = to.end
+				}
+				if w.isSym("[") {
+					w.attrBlock(st)
+				}
+			default:
+				st.kind = stmtNode
+				if w.isSym("[") {
+					w.attrBlock(st)
+				}
+			}
+		}
+		w.stmts = append(w.stmts, st)
+	}
+}
+
+func (w *docWalker) attrBlock(st *docStmt) {
+	open := w.peek()
+	w.i++
+	for !w.isSym("]") {
+		keyStart := w.peek().pos
+		key := w.peek().lit
+		w.i++
+		for w.isSym(".") {
+			w.i++
+			key += "." + w.peek().lit
+			w.i++
+		}
+		w.i++ // '='
+		a := docAttr{key: key, keyStart: keyStart}
+		w.value(&a, false)
+		st.attrs = append(st.attrs, a)
+		if w.isSym(",") {
+			w.i++
+		}
+	}
+	closeTok := w.peek()
+	w.i++
+	st.hasBlock = true
+	st.block = Span{open.pos, closeTok.end}
+	st.span.End = closeTok.end
+}
+
+// value consumes one attribute value. Unquoted values inside attr blocks can
+// span several tokens (claude-opus-4-6); top-level values are one token or a
+// negative number.
+func (w *docWalker) value(a *docAttr, topLevel bool) {
+	first := w.peek()
+	if first.typ == tokenString {
+		w.i++
+		a.value, a.quoted, a.span = first.lit, true, Span{first.pos, first.end}
+		return
+	}
+	var sb strings.Builder
+	end := first.end
+	for {
+		t := w.peek()
+		if t.typ == tokenEOF || (t.typ == tokenSymbol && (t.lit == "," || t.lit == "]" || t.lit == "}" || t.lit == ";")) {
+			break
+		}
+		if topLevel && sb.Len() > 0 && t.typ != tokenIdent {
+			break
+		}
+		sb.WriteString(t.lit)
+		end = t.end
+		w.i++
+		if topLevel && t.typ == tokenIdent {
+			break
+		}
+	}
+	a.value, a.span = sb.String(), Span{first.pos, end}
+}
+
+// Bytes returns the current source.
+func (d *Document) Bytes() []byte {
+	return append([]byte(nil), d.src...)
+}
+
+// HasNode reports whether id is declared by a node statement or used in an
+// edge.
+func (d *Document) HasNode(id string) bool {
+	for _, st := range d.stmts {
+		if st.kind != stmtNode && st.kind != stmtEdge {
+			continue
+		}
+		for _, have := range st.ids {
+			if have == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasEdge reports whether an edge statement (or chain) contains from -> to.
+func (d *Document) HasEdge(from, to string) bool {
+	st, _ := d.findEdge(from, to)
+	return st != nil
+}
+
+// NodeAttr returns the value of key as written on id's node statements
+// (later statements win, as in Parse). Defaults and stylesheet values are not
+// considered.
+func (d *Document) NodeAttr(id, key string) (string, bool) {
+	val, ok := "", false
+	for _, st := range d.stmts {
+		if st.kind != stmtNode || st.ids[0] != id {
+			continue
+		}
+		for _, a := range st.attrs {
+			if a.key == key {
+				val, ok = a.value, true
+			}
+		}
+	}
+	return val, ok
+}
+
+// SetNodeAttr sets key on node id. An existing value is replaced in place;
+// otherwise the attribute is added to the node's first statement, and a new
+// node statement is appended when the node only appears in edges.
+func (d *Document) SetNodeAttr(id, key, value string) error {
+	var decl *docStmt
+	for _, st := range d.stmts {
+		if st.kind != stmtNode || st.ids[0] != id {
+			continue
+		}
+		if decl == nil {
+			decl = st
+		}
+		for _, a := range st.attrs {
+			if a.key == key {
+				decl = st
+			}
+		}
+	}
+	if decl == nil {
+		if !d.HasNode(id) {
+			return fmt.Errorf("dot document: node %q not found", id)
+		}
+		return d.AddNode(id, Attr{Key: key, Value: value})
+	}
+	return d.setStmtAttr(decl, key, value)
+}
+
+// AppendNodeAttr appends suffix to an existing attribute value on node id,
+// editing just before the closing quote so the rest of the value keeps its
+// original formatting.
+func (d *Document) AppendNodeAttr(id, key, suffix string) error {
+	var target *docStmt
+	var attr docAttr
+	for _, st := range d.stmts {
+		if st.kind != stmtNode || st.ids[0] != id {
+			continue
+		}
+		for _, a := range st.attrs {
+			if a.key == key {
+				target, attr = st, a
+			}
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("dot document: node %q has no %s attribute", id, key)
+	}
+	if !attr.quoted {
+		return d.setStmtAttr(target, key, attr.value+suffix)
+	}
+	raw := d.src[attr.span.Start:attr.span.End]
+	enc := quoteValue(suffix)
+	enc = enc[1 : len(enc)-1]
+	if strings.Contains(string(raw), "\n") {
+		// The value already spans lines; keep inserted newlines literal too.
+		enc = strings.ReplaceAll(enc, `\n`, "\n")
+	}
+	return d.splice(docEdit{at: Span{attr.span.End - 1, attr.span.End - 1}, text: enc})
+}
+
+// SetEdgeAttr sets key on the edge from -> to. When the edge is part of a
+// chain (a -> b -> c), the chain is split so only that edge changes.
+func (d *Document) SetEdgeAttr(from, to, key, value string) error {
+	st, idx := d.findEdge(from, to)
+	if st == nil {
+		return fmt.Errorf("dot document: edge %s -> %s not found", from, to)
+	}
+	if len(st.ids) > 2 {
+		if err := d.splitChain(st, idx); err != nil {
+			return err
+		}
+		st, _ = d.findEdge(from, to)
+	}
+	return d.setStmtAttr(st, key, value)
+}
+
+// AddNode appends a node statement before the graph's closing brace.
+func (d *Document) AddNode(id string, attrs ...Attr) error {
+	return d.appendStmt(id + formatAttrBlock(attrs))
+}
+
+// AddEdge appends an edge statement before the graph's closing brace.
+func (d *Document) AddEdge(from, to string, attrs ...Attr) error {
+	return d.appendStmt(from + " -> " + to + formatAttrBlock(attrs))
+}
+
+// DeleteNodeAttr removes key from every statement of node id. Removing an
+// attribute that is not set is not an error.
+func (d *Document) DeleteNodeAttr(id, key string) error {
+	var edits []docEdit
+	for _, st := range d.stmts {
+		if st.kind == stmtNode && st.ids[0] == id {
+			edits = append(edits, d.deleteStmtAttr(st, key)...)
+		}
+	}
+	if len(edits) == 0 {
+		if !d.HasNode(id) {
+			return fmt.Errorf("dot document: node %q not found", id)
+		}
+		return nil
+	}
+	return d.splice(edits...)
+}
+
+// DeleteEdgeAttr removes key from the edge from -> to, splitting a chain
+// first so the other hops keep it.
+func (d *Document) DeleteEdgeAttr(from, to, key string) error {
+	st, idx := d.findEdge(from, to)
+	if st == nil {
+		return fmt.Errorf("dot document: edge %s -> %s not found", from, to)
+	}
+	edits := d.deleteStmtAttr(st, key)
+	if len(edits) == 0 {
+		return nil
+	}
+	if len(st.ids) > 2 {
+		if err := d.splitChain(st, idx); err != nil {
+			return err
+		}
+		st, _ = d.findEdge(from, to)
+		edits = d.deleteStmtAttr(st, key)
+	}
+	return d.splice(edits...)
+}
+
+// RemoveEdge removes the edge from -> to. The rest of a chain is kept as
+// separate statements.
+func (d *Document) RemoveEdge(from, to string) error {
+	st, idx := d.findEdge(from, to)
+	if st == nil {
+		return fmt.Errorf("dot document: edge %s -> %s not found", from, to)
+	}
+	return d.splice(d.rewriteChain(st, [][]string{st.ids[:idx+1], st.ids[idx+1:]}))
+}
+
+// RemoveNode removes node id: its node statements and every edge hop that
+// touches it. Attribute values that name the node (retry_target) are left
+// for validation to report.
+func (d *Document) RemoveNode(id string) error {
+	var edits []docEdit
+	for _, st := range d.stmts {
+		switch st.kind {
+		case stmtNode:
+			if st.ids[0] == id {
+				edits = append(edits, docEdit{at: d.stmtLine(st)})
+			}
+		case stmtEdge:
+			var pieces [][]string
+			start, touched := 0, false
+			for i, have := range st.ids {
+				if have == id {
+					pieces = append(pieces, st.ids[start:i])
+					start, touched = i+1, true
+				}
+			}
+			if touched {
+				pieces = append(pieces, st.ids[start:])
+				edits = append(edits, d.rewriteChain(st, pieces))
+			}
+		}
+	}
+	if len(edits) == 0 {
+		return fmt.Errorf("dot document: node %q not found", id)
+	}
+	return d.splice(edits...)
+}
+
+// rewriteChain replaces an edge statement with the given sub-chains (each
+// keeping the original attribute block); pieces with fewer than two nodes are
+// dropped, and the whole line goes when none remain.
+func (d *Document) rewriteChain(st *docStmt, pieces [][]string) docEdit {
+	block := ""
+	if st.hasBlock {
+		block = " " + string(d.src[st.block.Start:st.block.End])
+	}
+	indent := lineIndent(d.src, st.span.Start)
+	var parts []string
+	for _, p := range pieces {
+		if len(p) >= 2 {
+			parts = append(parts, strings.Join(p, " -> ")+block)
+		}
+	}
+	if len(parts) == 0 {
+		return docEdit{at: d.stmtLine(st)}
+	}
+	return docEdit{at: st.span, text: strings.Join(parts, "\n"+indent)}
+}
+
+// stmtLine is the span to delete to remove st: the statement, a trailing
+// ';', and the whole line when the statement is alone on it.
+func (d *Document) stmtLine(st *docStmt) Span {
+	start, end := st.span.Start, st.span.End
+	for end < len(d.src) && (d.src[end] == ' ' || d.src[end] == '\t') {
+		end++
+	}
+	if end < len(d.src) && d.src[end] == ';' {
+		end++
+	}
+	lineStart := start
+	for lineStart > 0 && (d.src[lineStart-1] == ' ' || d.src[lineStart-1] == '\t') {
+		lineStart--
+	}
+	lineEnd := end
+	for lineEnd < len(d.src) && (d.src[lineEnd] == ' ' || d.src[lineEnd] == '\t') {
+		lineEnd++
+	}
+	if (lineStart == 0 || d.src[lineStart-1] == '\n') && lineEnd < len(d.src) && d.src[lineEnd] == '\n' {
+		return Span{lineStart, lineEnd + 1}
+	}
+	return Span{start, end}
+}
+
+// deleteStmtAttr returns the edit removing key (and its separating comma)
+// from st, or the whole [ ... ] block when key is its only attribute.
+func (d *Document) deleteStmtAttr(st *docStmt, key string) []docEdit {
+	for i, a := range st.attrs {
+		if a.key != key {
+			continue
+		}
+		if len(st.attrs) == 1 {
+			start := st.block.Start
+			for start > 0 && (d.src[start-1] == ' ' || d.src[start-1] == '\t') {
+				start--
+			}
+			return []docEdit{{at: Span{start, st.block.End}}}
+		}
+		if i+1 < len(st.attrs) {
+			return []docEdit{{at: Span{a.keyStart, st.attrs[i+1].keyStart}}}
+		}
+		return []docEdit{{at: Span{st.attrs[i-1].span.End, a.span.End}}}
+	}
+	return nil
+}
+
+// nodeRefAttrs is model.NodeRefAttrs as a set.
+var nodeRefAttrs = func() map[string]bool {
+	m := map[string]bool{}
+	for _, k := range model.NodeRefAttrs {
+		m[k] = true
+	}
+	return m
+}()
+
+// RenameNode renames a node everywhere it is referenced: node and edge
+// statements plus the values of model.NodeRefAttrs.
+func (d *Document) RenameNode(oldID, newID string) error {
+	if !validNodeID(newID) {
+		return fmt.Errorf("dot document: %q is not a valid node identifier", newID)
+	}
+	if d.HasNode(newID) {
+		return fmt.Errorf("dot document: node %q already exists", newID)
+	}
+	var edits []docEdit
+	for _, st := range d.stmts {
+		if st.kind == stmtNode || st.kind == stmtEdge {
+			for i, id := range st.ids {
+				if id == oldID {
+					edits = append(edits, docEdit{at: st.idSpans[i], text: newID})
+				}
+			}
+		}
+		for _, a := range st.attrs {
+			if nodeRefAttrs[a.key] && a.value == oldID {
+				text := newID
+				if a.quoted {
+					text = quoteValue(newID)
+				}
+				edits = append(edits, docEdit{at: a.span, text: text})
+			}
+		}
+	}
+	if len(edits) == 0 {
+		return fmt.Errorf("dot document: node %q not found", oldID)
+	}
+	return d.splice(edits...)
+}
+
+func (d *Document) findEdge(from, to string) (*docStmt, int) {
+	for _, st := range d.stmts {
+		if st.kind != stmtEdge {
+			continue
+		}
+		for i := 0; i+1 < len(st.ids); i++ {
+			if st.ids[i] == from && st.ids[i+1] == to {
+				return st, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+// splitChain rewrites a -> b -> c [attrs] so the hop starting at idx is its
+// own statement; each piece keeps the original attribute block.
+func (d *Document) splitChain(st *docStmt, idx int) error {
+	block := ""
+	if st.hasBlock {
+		block = " " + string(d.src[st.block.Start:st.block.End])
+	}
+	var pieces [][]string
+	if idx > 0 {
+		pieces = append(pieces, st.ids[:idx+1])
+	}
+	pieces = append(pieces, st.ids[idx:idx+2])
+	if idx+2 < len(st.ids) {
+		pieces = append(pieces, st.ids[idx+1:])
+	}
+	indent := lineIndent(d.src, st.span.Start)
+	var parts []string
+	for _, p := range pieces {
+		parts = append(parts, strings.Join(p, " -> ")+block)
+	}
+	return d.splice(docEdit{at: st.span, text: strings.Join(parts, "\n"+indent)})
+}
+
+func (d *Document) setStmtAttr(st *docStmt, key, value string) error {
+	for _, a := range st.attrs {
+		if a.key != key {
+			continue
+		}
+		text := formatValue(value)
+		if a.quoted {
+			text = quoteValue(value)
+		}
+		return d.splice(docEdit{at: a.span, text: text})
+	}
+	kv := key + "=" + formatValue(value)
+	switch {
+	case st.hasBlock && len(st.attrs) > 0:
+		last := st.attrs[len(st.attrs)-1].span.End
+		return d.splice(docEdit{at: Span{last, last}, text: ", " + kv})
+	case st.hasBlock:
+		return d.splice(docEdit{at: Span{st.block.Start + 1, st.block.Start + 1}, text: kv})
+	default:
+		end := st.idSpans[len(st.idSpans)-1].End
+		return d.splice(docEdit{at: Span{end, end}, text: " [" + kv + "]"})
+	}
+}
+
+// appendStmt inserts one statement line before the root closing brace,
+// indented like the last top-level statement.
+func (d *Document) appendStmt(text string) error {
+	indent := "  "
+	for i := len(d.stmts) - 1; i >= 0; i-- {
+		if d.stmts[i].depth == 0 {
+			indent = lineIndent(d.src, d.stmts[i].span.Start)
+			break
+		}
+	}
+	lineStart := d.rootClose
+	for lineStart > 0 && (d.src[lineStart-1] == ' ' || d.src[lineStart-1] == '\t') {
+		lineStart--
+	}
+	if lineStart == 0 || d.src[lineStart-1] == '\n' {
+		return d.splice(docEdit{at: Span{lineStart, lineStart}, text: indent + text + "\n"})
+	}
+	return d.splice(docEdit{at: Span{d.rootClose, d.rootClose}, text: "\n" + indent + text + "\n"})
+}
+
+type docEdit struct {
+	at   Span
+	text string
+}
+
+// splice applies non-overlapping edits and re-indexes the document.
+func (d *Document) splice(edits ...docEdit) error {
+	for i := 1; i < len(edits); i++ {
+		for j := i; j > 0 && edits[j].at.Start < edits[j-1].at.Start; j-- {
+			edits[j], edits[j-1] = edits[j-1], edits[j]
+		}
+	}
+	var out []byte
+	last := 0
+	for _, e := range edits {
+		if e.at.Start < last {
+			return fmt.Errorf("dot document: overlapping edits")
+		}
+		out = append(out, d.src[last:e.at.Start]...)
+		out = append(out, e.text...)
+		last = e.at.End
+	}
+	out = append(out, d.src[last:]...)
+	next, err := ParseDocument(out)
+	if err != nil {
+		return fmt.Errorf("dot document: edit produced invalid DOT: %w", err)
+	}
+	*d = *next
+	return nil
+}
+
+func lineIndent(src []byte, pos int) string {
+	start := pos
+	for start > 0 && src[start-1] != '\n' {
+		start--
+	}
+	end := start
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	return string(src[start:end])
+}
+
+var (
+	bareValueRE = regexp.MustCompile(`^(?:[A-Za-z_][A-Za-z0-9_]*|[0-9]+(?:\.[0-9]+)?[A-Za-z]*)$`)
+	nodeIDRE    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+func validNodeID(id string) bool {
+	switch strings.ToLower(id) {
+	case "graph", "digraph", "subgraph", "node", "edge":
+		return false
+	}
+	return nodeIDRE.MatchString(id)
+}
+
+// formatValue writes a value bare when the lexer reads it back unchanged and
+// quoted otherwise.
+func formatValue(v string) string {
+	if bareValueRE.MatchString(v) {
+		return v
+	}
+	return quoteValue(v)
+}
+
+func quoteValue(v string) string {
+	return quoteString(v, false)
+}
+
+// quoteString quotes v for the DOT lexer. Backslashes are only doubled where
+// the lexer would otherwise decode them (before " \ n t, or at the end), so
+// regexes and paths in prompts keep their original spelling.
+func quoteString(v string, rawNewlines bool) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; c {
+		case '\\':
+			if i+1 == len(v) || strings.IndexByte(`"\\nt`, v[i+1]) >= 0 {
+				sb.WriteString(`\\`)
+			} else {
+				sb.WriteByte(c)
+			}
+		case '"':
+			sb.WriteString(`\"`)
+		case '\n':
+			if rawNewlines {
+				sb.WriteByte(c)
+			} else {
+				sb.WriteString(`\n`)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func formatAttrBlock(attrs []Attr) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(attrs))
+	for _, a := range attrs {
+		parts = append(parts, a.Key+"="+formatValue(a.Value))
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,186 @@
+package dot
+
+import (
+	"strings"
+	"testing"
+)
+
+const docSrc = `// header comment
+digraph G {
+  graph [goal="ship it"] // trailing comment
+  /* block
+     comment */
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  graph_node [shape=box, prompt="Do the thing.
+Second line."]
+  work [shape=box]
+  start -> graph_node -> work -> exit
+  work -> graph_node [condition="outcome=fail"] // retry
+}
+`
+
+func mustDoc(t *testing.T, src string) *Document {
+	t.Helper()
+	doc, err := ParseDocument([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseDocument: %v", err)
+	}
+	return doc
+}
+
+func TestDocument_SetNodeAttrPreservesComments(t *testing.T) {
+	doc := mustDoc(t, docSrc)
+	if err := doc.SetNodeAttr("exit", "shape", "doublecircle"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetNodeAttr("work", "retry_target", "graph_node"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetNodeAttr("start", "label", "Begin here"); err != nil {
+		t.Fatal(err)
+	}
+	out := string(doc.Bytes())
+	for _, want := range []string{
+		"// header comment",
+		"// trailing comment",
+		"/* block\n     comment */",
+		"exit  [shape=doublecircle]",
+		"work [shape=box, retry_target=graph_node]",
+		`start [shape=Mdiamond, label="Begin here"]`,
+		"// retry",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	g, err := Parse(doc.Bytes())
+	if err != nil {
+		t.Fatalf("re-parse: %v", err)
+	}
+	if g.Nodes["start"].Attrs["label"] != "Begin here" {
+		t.Fatalf("label not applied: %v", g.Nodes["start"].Attrs)
+	}
+}
+
+func TestDocument_AppendNodeAttrKeepsRawNewlines(t *testing.T) {
+	doc := mustDoc(t, docSrc)
+	if err := doc.AppendNodeAttr("graph_node", "prompt", "\nThird line."); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(doc.Bytes()), "Second line.\nThird line.\"]") {
+		t.Fatalf("append did not keep raw newline style:\n%s", doc.Bytes())
+	}
+}
+
+func TestDocument_SetEdgeAttrSplitsChain(t *testing.T) {
+	doc := mustDoc(t, docSrc)
+	if err := doc.SetEdgeAttr("work", "exit", "condition", "outcome=success"); err != nil {
+		t.Fatal(err)
+	}
+	out := string(doc.Bytes())
+	if !strings.Contains(out, "start -> graph_node -> work\n  work -> exit [condition=\"outcome=success\"]") {
+		t.Fatalf("unexpected chain split:\n%s", out)
+	}
+	g, err := Parse(doc.Bytes())
+	if err != nil {
+		t.Fatalf("re-parse: %v", err)
+	}
+	if len(g.Edges) != 4 {
+		t.Fatalf("edges: got %d want 4", len(g.Edges))
+	}
+}
+
+func TestDocument_RenameAndAppend(t *testing.T) {
+	doc := mustDoc(t, `digraph G {
+  start [shape=Mdiamond]
+  exit [shape=Msquare]
+  node_a [shape=box]
+  gate [shape=box, goal_gate=true, retry_target="node_a"]
+  start -> node_a -> gate -> exit
+}`)
+	if err := doc.RenameNode("node_a", "implement"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.AddEdge("gate", "implement", Attr{Key: "condition", Value: "outcome=fail"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph G {
+  start [shape=Mdiamond]
+  exit [shape=Msquare]
+  implement [shape=box]
+  gate [shape=box, goal_gate=true, retry_target="implement"]
+  start -> implement -> gate -> exit
+  gate -> implement [condition="outcome=fail"]
+}`
+	if got := string(doc.Bytes()); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if err := doc.RenameNode("gate", "exit"); err == nil {
+		t.Fatal("expected collision error")
+	}
+}
+
+func TestDocument_RemoveNodeAndEdgesAndAttrs(t *testing.T) {
+	doc := mustDoc(t, docSrc)
+	if err := doc.RemoveNode("graph_node"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.AddEdge("start", "work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.DeleteNodeAttr("exit", "shape"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetNodeAttr("work", "label", "Work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.DeleteNodeAttr("work", "shape"); err != nil {
+		t.Fatal(err)
+	}
+	want := `// header comment
+digraph G {
+  graph [goal="ship it"] // trailing comment
+  /* block
+     comment */
+  start [shape=Mdiamond]
+  exit
+  work [label=Work]
+  work -> exit
+  // retry
+  start -> work
+}
+`
+	if got := string(doc.Bytes()); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := doc.RemoveEdge("work", "exit"); err != nil {
+		t.Fatal(err)
+	}
+	if doc.HasEdge("work", "exit") || !doc.HasEdge("start", "work") {
+		t.Fatalf("RemoveEdge:\n%s", doc.Bytes())
+	}
+	if err := doc.RemoveEdge("work", "exit"); err == nil {
+		t.Fatal("expected error removing a missing edge")
+	}
+	if err := doc.RemoveNode("nope"); err == nil {
+		t.Fatal("expected error removing a missing node")
+	}
+}
+
+func TestDocument_DeleteEdgeAttrSplitsChain(t *testing.T) {
+	doc := mustDoc(t, `digraph G {
+  a -> b -> c [weight=2, label=x]
+}`)
+	if err := doc.DeleteEdgeAttr("b", "c", "weight"); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph G {
+  a -> b [weight=2, label=x]
+  b -> c [label=x]
+}`
+	if got := string(doc.Bytes()); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,245 @@
+package dot
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxFormatLineWidth is the width above which an attribute block is broken
+// into one attribute per line.
+const maxFormatLineWidth = 100
+
+// Format returns DOT source in canonical layout:
+//
+//   - one statement per line, indented two spaces per nesting level;
+//   - edge chains (a -> b -> c) expanded to one edge per line, each keeping
+//     the chain's attribute block;
+//   - values bare when they are identifiers or numbers and quoted otherwise;
+//   - multi-line values written with literal newlines, and their attribute
+//     blocks broken one attribute per line (as are blocks too wide for one);
+//   - semicolons dropped and runs of blank lines collapsed to one.
+//
+// Comments, attribute order and subgraph structure are preserved, and the
+// result parses to the same graph as src. Format is idempotent.
+func Format(src []byte) ([]byte, error) {
+	doc, err := ParseDocument(src)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Format(), nil
+}
+
+// fmtItem is a statement or comment in output order.
+type fmtItem struct {
+	stmt    *docStmt
+	comment Span
+	// inside is set for comments that sit within a statement's span; they
+	// are hoisted to lead the statement.
+	inside bool
+	key    int // sort offset
+}
+
+// Format renders the document in canonical layout; see Format.
+func (d *Document) Format() []byte {
+	var items []fmtItem
+	for _, st := range d.stmts {
+		items = append(items, fmtItem{stmt: st, key: st.span.Start})
+	}
+	var header, trailer []Span
+	for _, c := range d.comments {
+		switch {
+		case c.End <= d.rootOpen:
+			header = append(header, c)
+			continue
+		case c.Start > d.rootClose:
+			trailer = append(trailer, c)
+			continue
+		}
+		it := fmtItem{comment: c, key: c.Start}
+		for _, st := range d.stmts {
+			if c.Start > st.span.Start && c.End <= st.span.End {
+				it.inside, it.key = true, st.span.Start
+				break
+			}
+		}
+		items = append(items, it)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].key != items[j].key {
+			return items[i].key < items[j].key
+		}
+		// Hoisted comments lead their statement.
+		return items[i].stmt == nil && items[j].stmt != nil
+	})
+
+	var out fmtWriter
+	prevEnd := -1
+	for _, c := range header {
+		if prevEnd >= 0 && blankLineBetween(d.src, prevEnd, c.Start) {
+			out.blank()
+		}
+		out.line(0, d.commentText(c))
+		prevEnd = c.End
+	}
+	if len(header) > 0 && blankLineBetween(d.src, prevEnd, d.rootOpen) {
+		out.blank()
+	}
+	out.line(0, "digraph "+d.name+" {")
+
+	depth := 1
+	prevEnd = d.rootOpen + 1
+	prevWasStmt := true // the opening brace line accepts trailing comments
+	firstInBlock := true
+	for _, it := range items {
+		if it.stmt != nil && it.stmt.kind == stmtSubgraphClose {
+			depth = it.stmt.depth + 1
+			out.line(depth, "}")
+			prevEnd, prevWasStmt, firstInBlock = it.stmt.span.End, true, false
+			continue
+		}
+		if it.stmt == nil {
+			text := d.commentText(it.comment)
+			if !it.inside && prevWasStmt && !strings.Contains(string(d.src[prevEnd:it.comment.Start]), "\n") {
+				out.trailing(text)
+				prevEnd, prevWasStmt = it.comment.End, false
+				continue
+			}
+			if !firstInBlock && !it.inside && blankLineBetween(d.src, prevEnd, it.comment.Start) {
+				out.blank()
+			}
+			out.line(depth, text)
+			if !it.inside {
+				prevEnd = it.comment.End
+			}
+			prevWasStmt, firstInBlock = false, false
+			continue
+		}
+		st := it.stmt
+		if !firstInBlock && prevEnd < st.span.Start && blankLineBetween(d.src, prevEnd, st.span.Start) {
+			out.blank()
+		}
+		for _, l := range d.renderStmt(st, depth) {
+			out.line(depth, l)
+		}
+		prevEnd, prevWasStmt, firstInBlock = st.span.End, true, false
+		if st.kind == stmtSubgraphOpen {
+			depth++
+			firstInBlock = true
+		}
+	}
+	out.line(0, "}")
+
+	prevEnd = d.rootClose + 1
+	for _, c := range trailer {
+		text := d.commentText(c)
+		switch {
+		case !strings.Contains(string(d.src[prevEnd:c.Start]), "\n") && prevEnd == d.rootClose+1:
+			out.trailing(text)
+		default:
+			if blankLineBetween(d.src, prevEnd, c.Start) {
+				out.blank()
+			}
+			out.line(0, text)
+		}
+		prevEnd = c.End
+	}
+	return out.bytes()
+}
+
+func (d *Document) commentText(c Span) string {
+	return strings.TrimRight(string(d.src[c.Start:c.End]), " \t\r")
+}
+
+// renderStmt returns the output lines for one statement, without indentation
+// on the first line of each.
+func (d *Document) renderStmt(st *docStmt, depth int) []string {
+	indent := strings.Repeat("  ", depth)
+	switch st.kind {
+	case stmtGraphAttrs:
+		return []string{renderAttrBlock("graph", st.attrs, indent, true)}
+	case stmtNodeDefaults:
+		return []string{renderAttrBlock("node", st.attrs, indent, true)}
+	case stmtEdgeDefaults:
+		return []string{renderAttrBlock("edge", st.attrs, indent, true)}
+	case stmtGraphAttr:
+		return []string{st.attrs[0].key + "=" + canonicalValue(st.attrs[0].value)}
+	case stmtSubgraphOpen:
+		if len(st.ids) > 0 {
+			return []string{"subgraph " + st.ids[0] + " {"}
+		}
+		return []string{"subgraph {"}
+	case stmtEdge:
+		lines := make([]string, 0, len(st.ids)-1)
+		for i := 0; i+1 < len(st.ids); i++ {
+			lines = append(lines, renderAttrBlock(st.ids[i]+" -> "+st.ids[i+1], st.attrs, indent, false))
+		}
+		return lines
+	default:
+		return []string{renderAttrBlock(st.ids[0], st.attrs, indent, false)}
+	}
+}
+
+func renderAttrBlock(head string, attrs []docAttr, indent string, keepEmpty bool) string {
+	if len(attrs) == 0 {
+		if keepEmpty {
+			return head + " []"
+		}
+		return head
+	}
+	parts := make([]string, 0, len(attrs))
+	multiline := false
+	for _, a := range attrs {
+		v := canonicalValue(a.value)
+		multiline = multiline || strings.Contains(v, "\n")
+		parts = append(parts, a.key+"="+v)
+	}
+	one := head + " [" + strings.Join(parts, ", ") + "]"
+	if !multiline && len(indent)+len(one) <= maxFormatLineWidth {
+		return one
+	}
+	inner := indent + "  "
+	return head + " [\n" + inner + strings.Join(parts, ",\n"+inner) + "\n" + indent + "]"
+}
+
+// canonicalValue is formatValue with literal newlines, so multi-line prompts
+// read (and diff) line by line.
+func canonicalValue(v string) string {
+	if bareValueRE.MatchString(v) {
+		return v
+	}
+	return quoteString(v, true)
+}
+
+func blankLineBetween(src []byte, from, to int) bool {
+	if from < 0 || to <= from {
+		return false
+	}
+	return strings.Count(string(src[from:to]), "\n") >= 2
+}
+
+// fmtWriter accumulates output lines.
+type fmtWriter struct {
+	lines []string
+}
+
+func (w *fmtWriter) line(depth int, text string) {
+	w.lines = append(w.lines, strings.Repeat("  ", depth)+text)
+}
+
+func (w *fmtWriter) blank() {
+	if n := len(w.lines); n > 0 && w.lines[n-1] != "" {
+		w.lines = append(w.lines, "")
+	}
+}
+
+func (w *fmtWriter) trailing(text string) {
+	if n := len(w.lines); n > 0 {
+		w.lines[n-1] += " " + text
+		return
+	}
+	w.lines = append(w.lines, text)
+}
+
+func (w *fmtWriter) bytes() []byte {
+	return []byte(strings.Join(w.lines, "\n") + "\n")
+}
@@ -0,0 +1,123 @@
+package dot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFormat_CanonicalLayout(t *testing.T) {
+	src := `// Pipeline header.
+
+digraph   Demo{
+    graph [goal="Ship \"it\"", rankdir="LR"];  // graph-wide
+    node [shape="box"]
+
+
+    start [shape=Mdiamond] ; exit [shape=Msquare]
+    work [llm_model=claude-opus-4-6, prompt="Line one.\nLine two uses \d+ regex."]
+    subgraph cluster_review {
+        label = "Review Phase"
+        /* reviewers */
+        review
+    }
+    start -> work -> review -> exit [weight=2]
+    review -> work [
+        // loop back on failure
+        condition="outcome=fail"
+    ]
+}
+`
+	want := `// Pipeline header.
+
+digraph Demo {
+  graph [goal="Ship \"it\"", rankdir=LR] // graph-wide
+  node [shape=box]
+
+  start [shape=Mdiamond]
+  exit [shape=Msquare]
+  work [
+    llm_model="claude-opus-4-6",
+    prompt="Line one.
+Line two uses \d+ regex."
+  ]
+  subgraph cluster_review {
+    label="Review Phase"
+    /* reviewers */
+    review
+  }
+  start -> work [weight=2]
+  work -> review [weight=2]
+  review -> exit [weight=2]
+  // loop back on failure
+  review -> work [condition="outcome=fail"]
+}
+`
+	got, err := Format([]byte(src))
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	assertSameGraph(t, []byte(src), got)
+}
+
+// TestFormat_RoundTripRepoGraphs formats every graph shipped in the repo and
+// checks the result parses to the same graph and is already canonical.
+func TestFormat_RoundTripRepoGraphs(t *testing.T) {
+	var files []string
+	for _, pattern := range []string{
+		"../../../workflows/*/*.dot",
+		"../../../demo/*/*.dot",
+		"../../../research/*.dot",
+		"../../../skills/*/*.dot",
+		"../../../cmd/kilroy/testdata/batch/*.dot",
+	} {
+		m, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, m...)
+	}
+	if len(files) == 0 {
+		t.Skip("no graphs found")
+	}
+	for _, f := range files {
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Parse(src); err != nil {
+			continue
+		}
+		out, err := Format(src)
+		if err != nil {
+			t.Fatalf("%s: Format: %v", f, err)
+		}
+		assertSameGraph(t, src, out)
+		again, err := Format(out)
+		if err != nil {
+			t.Fatalf("%s: Format(formatted): %v", f, err)
+		}
+		if string(again) != string(out) {
+			t.Fatalf("%s: Format is not idempotent:\n%s", f, again)
+		}
+	}
+}
+
+func assertSameGraph(t *testing.T, a, b []byte) {
+	t.Helper()
+	ga, err := Parse(a)
+	if err != nil {
+		t.Fatalf("parse original: %v", err)
+	}
+	gb, err := Parse(b)
+	if err != nil {
+		t.Fatalf("parse formatted: %v\n%s", err, b)
+	}
+	if !reflect.DeepEqual(ga, gb) {
+		t.Fatalf("formatted graph differs from original:\n%s", b)
+	}
+}
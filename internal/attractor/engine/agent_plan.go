@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/agent"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+// Agent plans: the checklist an API agent_loop session keeps with its
+// update_plan tool is written to {stage}/plan.json and to the run context
+// under agent.plan.<node_id>. The context copy is checkpointed, so the next
+// attempt of the stage (a retry, a loop.begin iteration or a retry_target
+// revisit) is seeded with it and sees it in its prompt instead of
+// re-planning from scratch.
+
+const agentPlanFileName = "plan.json"
+
+func agentPlanContextKey(nodeID string) string {
+	return "agent.plan." + nodeID
+}
+
+// agentPlanFromContext returns the plan recorded for nodeID. Values restored
+// from a checkpoint are generic JSON, so they are decoded by round trip.
+func agentPlanFromContext(ctx *runtime.Context, nodeID string) []agent.PlanItem {
+	if ctx == nil {
+		return nil
+	}
+	raw, ok := ctx.Get(agentPlanContextKey(nodeID))
+	if !ok || raw == nil {
+		return nil
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var items []agent.PlanItem
+	if json.Unmarshal(b, &items) != nil {
+		return nil
+	}
+	return items
+}
+
+// storeAgentPlan writes plan.json and the context copy.
+func storeAgentPlan(execCtx *Execution, nodeID, stageDir string, items []agent.PlanItem) {
+	if execCtx == nil {
+		return
+	}
+	if err := writeJSON(filepath.Join(stageDir, agentPlanFileName), items); err != nil {
+		WarnEngine(execCtx, fmt.Sprintf("write %s: %v", agentPlanFileName, err))
+	}
+	if execCtx.Context != nil {
+		execCtx.Context.Set(agentPlanContextKey(nodeID), items)
+	}
+}
+
+// recordAgentPlanEvent handles a PLAN_UPDATE session event: it stores the
+// plan and reports it to CXDB and the progress stream.
+func recordAgentPlanEvent(ctx context.Context, execCtx *Execution, nodeID, stageDir string, ev agent.SessionEvent) {
+	// A subagent's plan is its own, not the stage's.
+	if _, sub := ev.Data["subagent_id"]; sub {
+		return
+	}
+	items, ok := ev.Data["plan"].([]agent.PlanItem)
+	if !ok {
+		return
+	}
+	explanation, _ := ev.Data["explanation"].(string)
+	storeAgentPlan(execCtx, nodeID, stageDir, items)
+	if execCtx == nil || execCtx.Engine == nil {
+		return
+	}
+	done, total := agent.PlanProgress(items)
+	execCtx.Engine.appendProgress(map[string]any{
+		"event":     "agent_plan_updated",
+		"node_id":   nodeID,
+		"completed": done,
+		"total":     total,
+	})
+	execCtx.Engine.cxdbPlanUpdated(ctx, nodeID, items, explanation)
+}
+
+// BuildAgentPlanPromptPreamble hands the plan of an earlier attempt of node
+// to the next one.
+func BuildAgentPlanPromptPreamble(exec *Execution, node *model.Node) string {
+	if exec == nil || node == nil {
+		return ""
+	}
+	items := agentPlanFromContext(exec.Context, node.ID)
+	if len(items) == 0 {
+		return ""
+	}
+	done, total := agent.PlanProgress(items)
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Plan from a previous attempt of this stage (%d/%d steps completed):\n", done, total))
+	b.WriteString(agent.FormatPlan(items))
+	b.WriteString("- Continue from this plan rather than re-planning; re-check completed steps only if something suggests they regressed.\n")
+	b.WriteString("- Keep it current with the update_plan tool when it is available.\n")
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/agent"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+func TestRecordAgentPlanEvent_StoresPlanForNextAttempt(t *testing.T) {
+	srv := newCXDBTestServer(t)
+	eng := newTestEngineWithCXDB(t, srv)
+	eng.LogsRoot = t.TempDir()
+	stageDir := filepath.Join(eng.LogsRoot, "implement")
+	if err := os.MkdirAll(stageDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	execCtx := &Execution{Context: runtime.NewContext(), LogsRoot: eng.LogsRoot, Engine: eng}
+
+	plan := []agent.PlanItem{
+		{Step: "write parser", Status: agent.PlanCompleted},
+		{Step: "add tests", Status: agent.PlanInProgress},
+	}
+	recordAgentPlanEvent(context.Background(), execCtx, "implement", stageDir, agent.SessionEvent{
+		Kind:      agent.EventPlanUpdate,
+		Timestamp: time.Now(),
+		Data:      map[string]any{"plan": plan, "explanation": "parser done"},
+	})
+
+	// Plans of spawned subagents are not the stage's plan.
+	recordAgentPlanEvent(context.Background(), execCtx, "implement", stageDir, agent.SessionEvent{
+		Kind:      agent.EventPlanUpdate,
+		Timestamp: time.Now(),
+		Data:      map[string]any{"plan": []agent.PlanItem{{Step: "subtask", Status: agent.PlanPending}}, "subagent_id": "sub-1"},
+	})
+
+	b, err := os.ReadFile(filepath.Join(stageDir, agentPlanFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk []agent.PlanItem
+	if err := json.Unmarshal(b, &onDisk); err != nil || !reflect.DeepEqual(onDisk, plan) {
+		t.Fatalf("plan.json = %s (%v)", b, err)
+	}
+
+	// A checkpoint round trip turns the context value into generic JSON.
+	raw, _ := json.Marshal(execCtx.Context.SnapshotValues())
+	var restored map[string]any
+	_ = json.Unmarshal(raw, &restored)
+	next := runtime.NewContext()
+	next.ApplyUpdates(restored)
+	if got := agentPlanFromContext(next, "implement"); !reflect.DeepEqual(got, plan) {
+		t.Fatalf("restored plan = %+v", got)
+	}
+
+	preamble := BuildAgentPlanPromptPreamble(&Execution{Context: next}, &model.Node{ID: "implement"})
+	if !strings.HasPrefix(preamble, "Plan from a previous attempt of this stage (1/2 steps completed):\n[x] write parser\n[~] add tests\n") {
+		t.Fatalf("preamble:\n%s", preamble)
+	}
+	if BuildAgentPlanPromptPreamble(&Execution{Context: next}, &model.Node{ID: "review"}) != "" {
+		t.Fatal("plans are per node")
+	}
+
+	var found bool
+	for _, turn := range srv.Turns(srv.ContextIDs()[0]) {
+		if turn["type_id"] != "com.kilroy.attractor.PlanUpdated" {
+			continue
+		}
+		payload, _ := turn["payload"].(map[string]any)
+		found = payload["node_id"] == "implement" && payload["explanation"] == "parser done" &&
+			strings.Contains(payload["plan_json"].(string), `"step":"add tests"`)
+	}
+	if !found {
+		t.Fatal("expected PlanUpdated turn in CXDB")
+	}
+	events := mustReadProgressEvents(t, filepath.Join(eng.LogsRoot, "progress.ndjson"))
+	last := events[len(events)-1]
+	if last["event"] != "agent_plan_updated" || last["node_id"] != "implement" {
+		t.Fatalf("progress event = %+v", last)
+	}
+}
@@ -185,6 +185,15 @@ func (r *AgentRouter) runAPI(ctx context.Context, execCtx *Execution, node *mode
 		return "", &runtime.Outcome{Status: runtime.StatusFail, FailureReason: err.Error()}, nil
 	}
 
+	atts, err := executionAttachments(execCtx, node)
+	if err != nil {
+		return "", &runtime.Outcome{Status: runtime.StatusFail, FailureReason: err.Error()}, nil
+	}
+	attachParts, err := attachmentContentParts(atts)
+	if err != nil {
+		return "", &runtime.Outcome{Status: runtime.StatusFail, FailureReason: err.Error()}, nil
+	}
+
 	reasoning := strings.TrimSpace(node.Attr("reasoning_effort", ""))
 	var reasoningPtr *string
 	if reasoning != "" {
@@ -200,11 +209,20 @@ func (r *AgentRouter) runAPI(ctx context.Context, execCtx *Execution, node *mode
 
 	switch mode {
 	case "one_shot":
+		if out := unenforcedToolsOutcome(node, "agent_mode=one_shot"); out != nil {
+			return "", out, nil
+		}
+		if execCtx.Engine != nil {
+			// A single request cannot take steering messages.
+			defer execCtx.Engine.steering.attachSession(node.ID, nil)()
+		}
+		userMsg := llm.User(prompt)
+		userMsg.Content = append(userMsg.Content, attachParts...)
 		text, used, err := r.withFailoverText(ctx, execCtx, node, client, provider, modelID, func(prov string, mid string) (string, error) {
 			req := llm.Request{
 				Provider:        prov,
 				Model:           mid,
-				Messages:        []llm.Message{llm.User(prompt)},
+				Messages:        []llm.Message{userMsg},
 				ReasoningEffort: reasoningPtr,
 				MaxTokens:       maxTokensPtr,
 			}
@@ -308,7 +326,7 @@ func (r *AgentRouter) runAPI(ctx context.Context, execCtx *Execution, node *mode
 			}
 
 			if emitter != nil {
-				emitter.emitTurnEnd(len(resp.Text()), toolCallCount)
+				emitter.emitTurnEnd(len(resp.Text()), toolCallCount, resp.Model, resp.Usage)
 			}
 
 			return resp.Text(), nil
@@ -371,6 +389,9 @@ func (r *AgentRouter) runAPI(ctx context.Context, execCtx *Execution, node *mode
 			if v := parseInt(node.Attr("max_agent_turns", ""), 0); v > 0 {
 				sessCfg.MaxTurns = v
 			}
+			if v := parseInt(node.Attr("max_parallel_subagents", ""), 0); v > 0 {
+				sessCfg.MaxParallelSubagents = v
+			}
 			if maxTokensPtr != nil {
 				sessCfg.MaxTokens = maxTokensPtr
 			}
@@ -381,6 +402,20 @@ func (r *AgentRouter) runAPI(ctx context.Context, execCtx *Execution, node *mode
 			if maxCommandTimeoutMS > 0 {
 				sessCfg.MaxCommandTimeoutMS = maxCommandTimeoutMS
 			}
+			if execCtx != nil && execCtx.Engine != nil {
+				sessCfg.LanguageServers = agentLanguageServers(execCtx.Engine.RunConfig)
+				sessCfg.CustomTools = execCtx.Engine.agentTools
+				mcpServers, err := agentMCPServers(execCtx.Engine.RunConfig, node)
+				if err != nil {
+					return "", err
+				}
+				sessCfg.MCPServers = mcpServers
+			}
+			if execCtx != nil {
+				sessCfg.Plan = agentPlanFromContext(execCtx.Context, node.ID)
+			}
+			sessCfg.AllowedTools = nodeToolAllowList(node)
+			sessCfg.Attachments = attachParts
 			// Give lots of room for transient LLM errors before failing the stage.
 			policy := attractorLLMRetryPolicy(execCtx, node.ID, prov, mid)
 			sessCfg.LLMRetryPolicy = &policy
@@ -393,6 +428,13 @@ func (r *AgentRouter) runAPI(ctx context.Context, execCtx *Execution, node *mode
 			if err != nil {
 				return "", err
 			}
+			if execCtx != nil && execCtx.Engine != nil {
+				// Let a supervising manager loop steer this session.
+				defer execCtx.Engine.steering.attachSession(node.ID, func(msg string) error {
+					sess.Steer(msg)
+					return nil
+				})()
+			}
 
 			eventsPath := filepath.Join(stageDir, "events.ndjson")
 			eventsJSONPath := filepath.Join(stageDir, "events.json")
@@ -435,6 +477,9 @@ func (r *AgentRouter) runAPI(ctx context.Context, execCtx *Execution, node *mode
 					if execCtx != nil && execCtx.Engine != nil {
 						executeToolHookForEvent(ctx, execCtx, node, ev, stageDir)
 					}
+					if ev.Kind == agent.EventPlanUpdate {
+						recordAgentPlanEvent(ctx, execCtx, node.ID, stageDir, ev)
+					}
 					// Forward LLM-level events as streaming progress.
 					if emitter != nil {
 						emitStreamProgress(emitter, ev)
@@ -497,6 +542,11 @@ func (r *AgentRouter) runAPI(ctx context.Context, execCtx *Execution, node *mode
 			text, runErr := sess.ProcessInput(ctx, prompt)
 			sess.Close()
 			<-done
+			// Events are best-effort; store the final plan so a dropped
+			// PLAN_UPDATE cannot lose it.
+			if plan := sess.Plan(); len(plan) > 0 {
+				storeAgentPlan(execCtx, node.ID, stageDir, plan)
+			}
 			close(heartbeatStop)
 			<-heartbeatDone
 			eventsMu.Lock()
@@ -1083,6 +1133,13 @@ func profileForProvider(provider string, modelID string) (agent.ProviderProfile,
 }
 
 func (r *AgentRouter) runCLI(ctx context.Context, execCtx *Execution, node *model.Node, provider string, modelID string, prompt string) (string, *runtime.Outcome, error) {
+	if out := unenforcedToolsOutcome(node, "the cli backend"); out != nil {
+		return "", out, nil
+	}
+	if execCtx.Engine != nil {
+		// The CLI runs with its prompt on the command line and no input.
+		defer execCtx.Engine.steering.attachSession(node.ID, nil)()
+	}
 	stageDir := filepath.Join(execCtx.LogsRoot, node.ID)
 	contract := BuildStageStatusContract(execCtx.WorktreeDir)
 	stageEnv := map[string]string{}
@@ -1187,6 +1244,24 @@ func (r *AgentRouter) runCLI(ctx context.Context, execCtx *Execution, node *mode
 		}
 	}
 
+	atts, err := executionAttachments(execCtx, node)
+	if err != nil {
+		return "", &runtime.Outcome{Status: runtime.StatusFail, FailureReason: err.Error()}, nil
+	}
+	var images, attached []string
+	for _, a := range atts {
+		attached = append(attached, a.Rel)
+		if a.Kind == attachImage {
+			images = append(images, a.Path)
+		}
+	}
+	// Codex takes images natively; everything else is listed in the prompt
+	// for the CLI agent to read from disk.
+	if codexSemantics && len(images) > 0 {
+		args = append(args, "--image", strings.Join(images, ","))
+	}
+	prompt += attachmentPromptNote(atts, codexSemantics)
+
 	actualArgs := args
 	recordedArgs := args
 	promptMode := "stdin"
@@ -1206,6 +1281,9 @@ func (r *AgentRouter) runCLI(ctx context.Context, execCtx *Execution, node *mode
 		"prompt_mode":  promptMode,
 		"prompt_bytes": len(prompt),
 	}
+	if len(attached) > 0 {
+		inv["attachments"] = attached
+	}
 	// Metaspec: capture how env was populated so the invocation is replayable.
 	if codexSemantics {
 		inv["env_mode"] = "isolated"
@@ -1317,6 +1395,16 @@ func (r *AgentRouter) runCLI(ctx context.Context, execCtx *Execution, node *mode
 			}
 			return nil, -1, 0, err
 		}
+		if execCtx != nil && execCtx.Engine != nil {
+			execCtx.Engine.appendProgress(map[string]any{
+				"event":      "cli_invocation_start",
+				"node_id":    node.ID,
+				"provider":   providerKey,
+				"model":      modelID,
+				"executable": filepath.Base(exe),
+				"pid":        cmd.Process.Pid,
+			})
+		}
 
 		// Emit periodic heartbeat events so operators monitoring detached runs
 		// have visibility into long-running agent nodes.
@@ -1391,6 +1479,19 @@ func (r *AgentRouter) runCLI(ctx context.Context, execCtx *Execution, node *mode
 		if cmd.ProcessState != nil {
 			exitCode = cmd.ProcessState.ExitCode()
 		}
+		if execCtx != nil && execCtx.Engine != nil {
+			ev := map[string]any{
+				"event":       "cli_invocation_end",
+				"node_id":     node.ID,
+				"provider":    providerKey,
+				"exit_code":   exitCode,
+				"duration_ms": dur.Milliseconds(),
+			}
+			if idleTimedOut {
+				ev["failure_trigger"] = "idle_timeout"
+			}
+			execCtx.Engine.appendProgress(ev)
+		}
 		if idleTimedOut {
 			inv["failure_trigger"] = "idle_timeout"
 			inv["idle_timeout_seconds"] = int(idleTimeout.Seconds())
@@ -2119,7 +2220,11 @@ func emitStreamProgress(emitter *streamProgressEmitter, ev agent.SessionEvent) {
 		if tc, ok := ev.Data["tool_call_count"].(int); ok {
 			toolCount = tc
 		}
-		emitter.emitTurnEnd(len(text), toolCount)
+		model, _ := ev.Data["model"].(string)
+		var usage llm.Usage
+		usage.InputTokens, _ = ev.Data["input_tokens"].(int)
+		usage.OutputTokens, _ = ev.Data["output_tokens"].(int)
+		emitter.emitTurnEnd(len(text), toolCount, model, usage)
 	}
 }
 
@@ -2131,23 +2236,35 @@ func emitCXDBToolTurns(ctx context.Context, eng *Engine, nodeID string, ev agent
 		return
 	}
 	runID := eng.Options.RunID
+	// Turns from spawned subagents carry their agent id, so usage and tool
+	// activity can be attributed per subagent.
+	subagentID, _ := ev.Data["subagent_id"].(string)
+	withSubagent := func(payload map[string]any) map[string]any {
+		if subagentID != "" {
+			payload["subagent_id"] = subagentID
+		}
+		return payload
+	}
 	switch ev.Kind {
 	case agent.EventAssistantTextEnd:
 		text := strings.TrimSpace(fmt.Sprint(ev.Data["text"]))
+		model, _ := ev.Data["model"].(string)
+		inTok, _ := ev.Data["input_tokens"].(int)
+		outTok, _ := ev.Data["output_tokens"].(int)
 		// Keep a queryable assistant turn even when the model turn is tool-only.
 		if text == "" {
 			text = "[tool_use]"
 		}
-		if _, _, err := eng.CXDB.Append(ctx, "com.kilroy.attractor.AssistantMessage", 1, map[string]any{
+		if _, _, err := eng.CXDB.Append(ctx, "com.kilroy.attractor.AssistantMessage", 1, withSubagent(map[string]any{
 			"run_id":         runID,
 			"node_id":        nodeID,
 			"text":           Truncate(text, 8_000),
-			"model":          "",
-			"input_tokens":   uint64(0),
-			"output_tokens":  uint64(0),
+			"model":          model,
+			"input_tokens":   uint64(inTok),
+			"output_tokens":  uint64(outTok),
 			"tool_use_count": uint32(0),
 			"timestamp_ms":   nowMS(),
-		}); err != nil {
+		})); err != nil {
 			eng.Warn(fmt.Sprintf("cxdb append AssistantMessage failed (node=%s): %v", nodeID, err))
 		}
 	case agent.EventToolCallStart:
@@ -2157,13 +2274,13 @@ func emitCXDBToolTurns(ctx context.Context, eng *Engine, nodeID string, ev agent
 		if toolName == "" || callID == "" {
 			return
 		}
-		if _, _, err := eng.CXDB.Append(ctx, "com.kilroy.attractor.ToolCall", 1, map[string]any{
+		if _, _, err := eng.CXDB.Append(ctx, "com.kilroy.attractor.ToolCall", 1, withSubagent(map[string]any{
 			"run_id":         runID,
 			"node_id":        nodeID,
 			"tool_name":      toolName,
 			"call_id":        callID,
 			"arguments_json": argsJSON,
-		}); err != nil {
+		})); err != nil {
 			eng.Warn(fmt.Sprintf("cxdb append ToolCall failed (node=%s tool=%s call_id=%s): %v", nodeID, toolName, callID, err))
 		}
 	case agent.EventToolCallEnd:
@@ -2174,14 +2291,14 @@ func emitCXDBToolTurns(ctx context.Context, eng *Engine, nodeID string, ev agent
 		}
 		isErr, _ := ev.Data["is_error"].(bool)
 		fullOutput := fmt.Sprint(ev.Data["full_output"])
-		if _, _, err := eng.CXDB.Append(ctx, "com.kilroy.attractor.ToolResult", 1, map[string]any{
+		if _, _, err := eng.CXDB.Append(ctx, "com.kilroy.attractor.ToolResult", 1, withSubagent(map[string]any{
 			"run_id":    runID,
 			"node_id":   nodeID,
 			"tool_name": toolName,
 			"call_id":   callID,
 			"output":    Truncate(fullOutput, 8_000),
 			"is_error":  isErr,
-		}); err != nil {
+		})); err != nil {
 			eng.Warn(fmt.Sprintf("cxdb append ToolResult failed (node=%s tool=%s call_id=%s): %v", nodeID, toolName, callID, err))
 		}
 	}
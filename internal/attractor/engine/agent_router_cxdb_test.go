@@ -116,3 +116,39 @@ func TestEmitCXDBToolTurns_AssistantTextFallbackForToolOnlyTurn(t *testing.T) {
 		t.Fatalf("fallback text: got %q", payload["text"])
 	}
 }
+
+func TestEmitCXDBToolTurns_AttributesSubagentTurns(t *testing.T) {
+	srv := newCXDBTestServer(t)
+	eng := newTestEngineWithCXDB(t, srv)
+	ctx := context.Background()
+
+	emitCXDBToolTurns(ctx, eng, "node_api", agent.SessionEvent{
+		Kind:      agent.EventAssistantTextEnd,
+		Timestamp: time.Now(),
+		Data:      map[string]any{"text": "parent", "input_tokens": 100, "output_tokens": 10},
+	})
+	emitCXDBToolTurns(ctx, eng, "node_api", agent.SessionEvent{
+		Kind:      agent.EventAssistantTextEnd,
+		Timestamp: time.Now(),
+		Data:      map[string]any{"text": "child", "input_tokens": 40, "output_tokens": 4, "subagent_id": "sub-1"},
+	})
+	emitCXDBToolTurns(ctx, eng, "node_api", agent.SessionEvent{
+		Kind:      agent.EventToolCallEnd,
+		Timestamp: time.Now(),
+		Data:      map[string]any{"tool_name": "write_file", "call_id": "c1", "full_output": "ok", "subagent_id": "sub-1"},
+	})
+
+	turns := srv.Turns(eng.CXDB.ContextID)
+	if len(turns) != 3 {
+		t.Fatalf("expected 3 turns, got %d", len(turns))
+	}
+	if _, ok := turns[0]["payload"].(map[string]any)["subagent_id"]; ok {
+		t.Fatal("parent turn must not carry subagent_id")
+	}
+	for i, turn := range turns[1:] {
+		payload := turn["payload"].(map[string]any)
+		if payload["subagent_id"] != "sub-1" {
+			t.Fatalf("turn[%d] subagent_id: got %v", i+1, payload["subagent_id"])
+		}
+	}
+}
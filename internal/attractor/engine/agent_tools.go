@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/agent"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+// agentToolsGraphAttr names a file (relative to the worktree) declaring
+// custom tools for API agent_loop sessions.
+const agentToolsGraphAttr = "agent_tools"
+
+// AgentToolsFile is the format of the graph's agent_tools file.
+type AgentToolsFile struct {
+	Tools []AgentToolDecl `json:"tools" yaml:"tools"`
+}
+
+// AgentToolDecl declares one command-backed tool. The command runs in the
+// worktree with the call's arguments as JSON on stdin.
+type AgentToolDecl struct {
+	Name        string         `json:"name" yaml:"name"`
+	Description string         `json:"description,omitempty" yaml:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Command     string         `json:"command" yaml:"command"`
+	TimeoutMS   int            `json:"timeout_ms,omitempty" yaml:"timeout_ms,omitempty"`
+}
+
+// LoadAgentToolsFile reads an agent_tools file.
+func LoadAgentToolsFile(path string) ([]agent.CustomTool, error) {
+	var doc AgentToolsFile
+	if err := LoadYAMLFile(path, &doc); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	out := make([]agent.CustomTool, 0, len(doc.Tools))
+	for i, t := range doc.Tools {
+		name := strings.TrimSpace(t.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%s: tools[%d]: name is required", path, i)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s: tool %s is declared twice", path, name)
+		}
+		seen[name] = true
+		if strings.TrimSpace(t.Command) == "" {
+			return nil, fmt.Errorf("%s: tool %s: command is required", path, name)
+		}
+		out = append(out, agent.CustomTool{
+			Name:        name,
+			Description: strings.TrimSpace(t.Description),
+			Parameters:  t.Parameters,
+			Command:     t.Command,
+			TimeoutMS:   t.TimeoutMS,
+		})
+	}
+	return out, nil
+}
+
+// initAgentTools loads the graph's agent_tools file. Called at run start
+// (after the worktree exists) and on resume.
+func (e *Engine) initAgentTools() error {
+	e.agentTools = nil
+	if e.Graph == nil {
+		return nil
+	}
+	p := strings.TrimSpace(e.Graph.Attrs[agentToolsGraphAttr])
+	if p == "" {
+		return nil
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(e.WorktreeDir, p)
+	}
+	tools, err := LoadAgentToolsFile(p)
+	if err != nil {
+		return fmt.Errorf("graph %s: %w", agentToolsGraphAttr, err)
+	}
+	e.agentTools = tools
+	return nil
+}
+
+// nodeToolAllowList parses a node's tools="read_file,grep,glob" attribute.
+func nodeToolAllowList(node *model.Node) []string {
+	if node == nil {
+		return nil
+	}
+	var out []string
+	for _, name := range strings.Split(node.Attr("tools", ""), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// unenforcedToolsOutcome fails a stage that sets tools= on a path that
+// cannot apply it (CLI backends, one_shot), rather than running it with
+// every tool available. It returns nil when the node sets no tools.
+func unenforcedToolsOutcome(node *model.Node, path string) *runtime.Outcome {
+	if len(nodeToolAllowList(node)) == 0 {
+		return nil
+	}
+	return &runtime.Outcome{
+		Status:        runtime.StatusFail,
+		FailureReason: fmt.Sprintf("node %s sets tools, which %s does not enforce; only agent_loop on the api backend does", node.ID, path),
+	}
+}
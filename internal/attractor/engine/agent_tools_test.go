@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+func TestInitAgentTools_LoadsGraphToolsFile(t *testing.T) {
+	wt := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(wt, "tools"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	doc := `tools:
+  - name: run_migration
+    description: Apply pending database migrations
+    command: ./scripts/migrate.sh
+    timeout_ms: 120000
+    parameters:
+      type: object
+      properties:
+        target: {type: string}
+      required: [target]
+`
+	if err := os.WriteFile(filepath.Join(wt, "tools", "agent_tools.yaml"), []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g := model.NewGraph("G")
+	g.Attrs[agentToolsGraphAttr] = "tools/agent_tools.yaml"
+	e := &Engine{Graph: g, WorktreeDir: wt}
+	if err := e.initAgentTools(); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.agentTools) != 1 {
+		t.Fatalf("tools = %+v", e.agentTools)
+	}
+	got := e.agentTools[0]
+	if got.Name != "run_migration" || got.Command != "./scripts/migrate.sh" || got.TimeoutMS != 120000 {
+		t.Fatalf("tool = %+v", got)
+	}
+	if req, _ := got.Parameters["required"].([]any); len(req) != 1 || req[0] != "target" {
+		t.Fatalf("parameters = %+v", got.Parameters)
+	}
+
+	if err := os.WriteFile(filepath.Join(wt, "tools", "agent_tools.yaml"), []byte("tools:\n  - name: x\n    cmd: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.initAgentTools(); err == nil || !strings.Contains(err.Error(), "agent_tools") {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
+func TestNodeToolAllowList(t *testing.T) {
+	n := model.NewNode("review")
+	n.Attrs["tools"] = " read_file, grep ,,glob "
+	if got := nodeToolAllowList(n); !reflect.DeepEqual(got, []string{"read_file", "grep", "glob"}) {
+		t.Fatalf("allow list = %v", got)
+	}
+	if got := nodeToolAllowList(model.NewNode("impl")); got != nil {
+		t.Fatalf("expected no allow list, got %v", got)
+	}
+}
+
+func TestRunCLI_FailsStageThatSetsTools(t *testing.T) {
+	n := model.NewNode("review")
+	n.Attrs["tools"] = "read_file"
+	_, out, err := (&AgentRouter{}).runCLI(context.Background(), nil, n, "anthropic", "claude-sonnet-4-5", "review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == nil || out.Status != runtime.StatusFail || !strings.Contains(out.FailureReason, "cli backend") {
+		t.Fatalf("expected failed stage naming the cli backend, got %+v", out)
+	}
+}
@@ -3,6 +3,8 @@ package engine
 import (
 	"sync"
 	"time"
+
+	"github.com/danshapiro/kilroy/internal/llm"
 )
 
 // streamProgressEmitter batches LLM text deltas and flushes them
@@ -78,20 +80,29 @@ func (e *streamProgressEmitter) emitToolCallEnd(toolName, callID string, isError
 	})
 }
 
-// emitTurnEnd flushes any pending delta and emits a turn-end event.
-func (e *streamProgressEmitter) emitTurnEnd(textLen int, toolCallCount int) {
+// emitTurnEnd flushes any pending delta and emits a turn-end event. Model and
+// token usage are included when the provider reported them.
+func (e *streamProgressEmitter) emitTurnEnd(textLen int, toolCallCount int, model string, usage llm.Usage) {
 	if e.eng == nil {
 		return
 	}
 	e.flushDelta()
-	e.eng.appendProgress(map[string]any{
+	ev := map[string]any{
 		"event":           "llm_turn_end",
 		"node_id":         e.nodeID,
 		"run_id":          e.runID,
 		"backend":         "api",
 		"text_length":     textLen,
 		"tool_call_count": toolCallCount,
-	})
+	}
+	if model != "" {
+		ev["model"] = model
+	}
+	if usage.InputTokens > 0 || usage.OutputTokens > 0 {
+		ev["input_tokens"] = usage.InputTokens
+		ev["output_tokens"] = usage.OutputTokens
+	}
+	e.eng.appendProgress(ev)
 }
 
 // close stops the timer and flushes remaining data.
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/danshapiro/kilroy/internal/agent"
+	"github.com/danshapiro/kilroy/internal/llm"
 )
 
 func TestThrottledEmitter_ShouldBatchTextDeltasWithinFlushInterval(t *testing.T) {
@@ -77,7 +78,7 @@ func TestThrottledEmitter_ShouldForceFlushOnTurnEnd(t *testing.T) {
 	em.interval = 5 * time.Second // Long interval to prove force-flush works.
 
 	em.appendDelta("partial response")
-	em.emitTurnEnd(16, 0)
+	em.emitTurnEnd(16, 0, "", llm.Usage{})
 
 	mu.Lock()
 	defer mu.Unlock()
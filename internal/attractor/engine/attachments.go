@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+// maxAttachmentBytes caps a single attach= file; providers reject much
+// larger inline payloads anyway.
+const maxAttachmentBytes = 20 << 20
+
+// Attachment kinds.
+const (
+	attachImage    = "image"
+	attachDocument = "document"
+	attachText     = "text"
+)
+
+// stageAttachment is one file resolved from a node's attach attribute.
+type stageAttachment struct {
+	Path      string // absolute
+	Rel       string // relative to the root it was found under
+	Kind      string
+	MediaType string
+}
+
+// nodeAttachPatterns parses a node's comma-separated attach attribute, e.g.
+// attach="designs/*.png,spec.pdf".
+func nodeAttachPatterns(node *model.Node) []string {
+	if node == nil {
+		return nil
+	}
+	var out []string
+	for _, p := range strings.Split(node.Attr("attach", ""), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// attachmentKind classifies a file by extension: images the providers accept
+// natively, PDFs, and text. It returns "" when the extension says nothing
+// useful; such files are attached as text if their content is UTF-8.
+func attachmentKind(path string) (kind, mediaType string) {
+	mt := llm.InferMimeTypeFromPath(path)
+	switch {
+	case mt == "image/png" || mt == "image/jpeg" || mt == "image/gif" || mt == "image/webp":
+		return attachImage, mt
+	case mt == "application/pdf":
+		return attachDocument, mt
+	case strings.HasPrefix(mt, "text/") || mt == "image/svg+xml" ||
+		mt == "application/json" || mt == "application/xml" || mt == "application/yaml" || mt == "application/toml":
+		return attachText, mt
+	}
+	return "", mt
+}
+
+// attachNeedsVision reports whether any pattern names images or PDFs, which
+// the model must be able to read natively.
+func attachNeedsVision(patterns []string) bool {
+	for _, p := range patterns {
+		if k, _ := attachmentKind(p); k == attachImage || k == attachDocument {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveStageAttachments resolves node's attach patterns against the
+// worktree and then the run's input snapshot; a pattern uses the first root
+// with matches. A pattern matching nothing is an error.
+func resolveStageAttachments(worktreeDir, logsRoot string, node *model.Node) ([]stageAttachment, error) {
+	patterns := nodeAttachPatterns(node)
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	roots := []string{worktreeDir}
+	if strings.TrimSpace(logsRoot) != "" {
+		roots = append(roots, inputSnapshotFilesRoot(logsRoot))
+	}
+	seen := map[string]bool{}
+	var out []stageAttachment
+	for _, pattern := range patterns {
+		found := false
+		for _, root := range roots {
+			if strings.TrimSpace(root) == "" {
+				continue
+			}
+			hits, err := expandSeedPattern(pattern, []string{root})
+			if err != nil {
+				return nil, fmt.Errorf("attach: %w", err)
+			}
+			for _, hit := range hits {
+				found = true
+				if seen[hit] {
+					continue
+				}
+				seen[hit] = true
+				a, err := newStageAttachment(hit, root)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, a)
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("attach: %q matched no files in the worktree or run inputs", pattern)
+		}
+	}
+	return out, nil
+}
+
+func newStageAttachment(path, root string) (stageAttachment, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = path
+	}
+	a := stageAttachment{Path: path, Rel: filepath.ToSlash(rel)}
+	info, err := os.Stat(path)
+	if err != nil {
+		return a, fmt.Errorf("attach: %w", err)
+	}
+	if info.Size() > maxAttachmentBytes {
+		return a, fmt.Errorf("attach: %s is %d bytes (limit %d)", a.Rel, info.Size(), maxAttachmentBytes)
+	}
+	a.Kind, a.MediaType = attachmentKind(path)
+	if a.Kind == "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return a, fmt.Errorf("attach: %w", err)
+		}
+		if !utf8.Valid(b) || bytes.IndexByte(b, 0) >= 0 {
+			return a, fmt.Errorf("attach: %s: unsupported file type (want png, jpeg, gif, webp, pdf or text)", a.Rel)
+		}
+		a.Kind, a.MediaType = attachText, "text/plain"
+	}
+	return a, nil
+}
+
+// attachmentContentParts loads attachments as message content for API
+// backends: images and PDFs natively, text files inline.
+func attachmentContentParts(atts []stageAttachment) ([]llm.ContentPart, error) {
+	var parts []llm.ContentPart
+	for _, a := range atts {
+		b, err := os.ReadFile(a.Path)
+		if err != nil {
+			return nil, fmt.Errorf("attach: %w", err)
+		}
+		switch a.Kind {
+		case attachImage:
+			parts = append(parts, llm.ContentPart{Kind: llm.ContentImage, Image: &llm.ImageData{Data: b, MediaType: a.MediaType}})
+		case attachDocument:
+			parts = append(parts, llm.ContentPart{Kind: llm.ContentDocument, Document: &llm.DocumentData{Data: b, MediaType: a.MediaType, FileName: filepath.Base(a.Path)}})
+		default:
+			parts = append(parts, llm.ContentPart{Kind: llm.ContentText, Text: fmt.Sprintf("Attached file %s:\n\n%s", a.Rel, b)})
+		}
+	}
+	return parts, nil
+}
+
+// attachmentPromptNote lists attachments by absolute path for CLI backends
+// that read files themselves. Images passed natively (skipImages) are left out.
+func attachmentPromptNote(atts []stageAttachment, skipImages bool) string {
+	var b strings.Builder
+	for _, a := range atts {
+		if skipImages && a.Kind == attachImage {
+			continue
+		}
+		fmt.Fprintf(&b, "- %s\n", a.Path)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "\n\nAttached files (read them before starting):\n" + b.String()
+}
+
+// attachmentDigest returns the SHA-256 of an attachment's content, for
+// cache keys.
+func attachmentDigest(a stageAttachment) (string, error) {
+	b, err := os.ReadFile(a.Path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// executionAttachments resolves node's attachments for a stage run. The
+// input snapshot lives under the engine's logs root, not a branch's.
+func executionAttachments(execCtx *Execution, node *model.Node) ([]stageAttachment, error) {
+	if execCtx == nil {
+		return nil, nil
+	}
+	logsRoot := execCtx.LogsRoot
+	if execCtx.Engine != nil && strings.TrimSpace(execCtx.Engine.LogsRoot) != "" {
+		logsRoot = execCtx.Engine.LogsRoot
+	}
+	return resolveStageAttachments(execCtx.WorktreeDir, logsRoot, node)
+}
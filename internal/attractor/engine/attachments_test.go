@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/modeldb"
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+func writeAttachFile(t *testing.T, path string, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveStageAttachments_WorktreeThenRunInputs(t *testing.T) {
+	worktree, logsRoot := t.TempDir(), t.TempDir()
+	writeAttachFile(t, filepath.Join(worktree, "designs", "a.png"), "\x89PNG")
+	writeAttachFile(t, filepath.Join(worktree, "designs", "b.png"), "\x89PNG")
+	writeAttachFile(t, filepath.Join(worktree, "notes.md"), "# notes\n")
+	inputs := inputSnapshotFilesRoot(logsRoot)
+	writeAttachFile(t, filepath.Join(inputs, "spec.pdf"), "%PDF-1.4")
+	writeAttachFile(t, filepath.Join(inputs, "notes.md"), "stale copy\n")
+
+	n := model.NewNode("impl")
+	n.Attrs["attach"] = "designs/*.png, spec.pdf,notes.md"
+	atts, err := resolveStageAttachments(worktree, logsRoot, n)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	var got []string
+	for _, a := range atts {
+		got = append(got, a.Rel+":"+a.Kind)
+	}
+	if strings.Join(got, " ") != "designs/a.png:image designs/b.png:image spec.pdf:document notes.md:text" {
+		t.Fatalf("attachments = %v", got)
+	}
+	if atts[3].Path != filepath.Join(worktree, "notes.md") {
+		t.Fatalf("worktree copy should win: %s", atts[3].Path)
+	}
+
+	parts, err := attachmentContentParts(atts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts[0].Kind != llm.ContentImage || parts[0].Image.MediaType != "image/png" ||
+		parts[2].Kind != llm.ContentDocument || parts[2].Document.FileName != "spec.pdf" ||
+		parts[3].Kind != llm.ContentText || !strings.Contains(parts[3].Text, "# notes") {
+		t.Fatalf("parts = %+v", parts)
+	}
+	if note := attachmentPromptNote(atts, true); strings.Contains(note, "a.png") || !strings.Contains(note, filepath.Join(inputs, "spec.pdf")) {
+		t.Fatalf("prompt note = %q", note)
+	}
+}
+
+func TestResolveStageAttachments_Errors(t *testing.T) {
+	worktree := t.TempDir()
+	writeAttachFile(t, filepath.Join(worktree, "blob.bin"), "\x00\x01\x02")
+	n := model.NewNode("impl")
+	for attach, want := range map[string]string{
+		"missing/*.png": "matched no files",
+		"blob.bin":      "unsupported file type",
+	} {
+		n.Attrs["attach"] = attach
+		if _, err := resolveStageAttachments(worktree, "", n); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: got %v", attach, err)
+		}
+	}
+}
+
+func TestValidateAttachmentVision(t *testing.T) {
+	catalog := &modeldb.Catalog{Models: map[string]modeldb.ModelEntry{
+		"openai/gpt-vision": {Provider: "openai", SupportsVision: true},
+		"openai/gpt-text":   {Provider: "openai"},
+	}}
+	runtimes := map[string]ProviderRuntime{"openai": {Key: "openai", Backend: BackendAPI}}
+	g := model.NewGraph("g")
+	addNode := func(id, modelID, attach string) {
+		n := model.NewNode(id)
+		n.Attrs["llm_provider"] = "openai"
+		n.Attrs["llm_model"] = modelID
+		n.Attrs["attach"] = attach
+		if err := g.AddNode(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addNode("look", "gpt-vision", "designs/*.png")
+	addNode("read", "gpt-text", "notes.md")
+
+	checks, err := validateAttachmentVision(g, runtimes, catalog, RunOptions{})
+	if err != nil || len(checks) != 1 || checks[0].Status != preflightStatusPass {
+		t.Fatalf("checks = %+v, err = %v", checks, err)
+	}
+
+	addNode("spec", "gpt-text", "spec.pdf")
+	checks, err = validateAttachmentVision(g, runtimes, catalog, RunOptions{})
+	if err == nil || !strings.Contains(err.Error(), "does not support vision") || checks[len(checks)-1].Status != preflightStatusFail {
+		t.Fatalf("checks = %+v, err = %v", checks, err)
+	}
+}
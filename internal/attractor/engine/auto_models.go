@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/modeldb"
+	"github.com/danshapiro/kilroy/internal/attractor/validate"
+)
+
+// autoModelSpecAttr keeps a node's original auto(...) selector once llm_model
+// has been replaced by the model it resolved to.
+const autoModelSpecAttr = "llm_model_auto"
+
+// AutoModelSelection records how an auto(...) llm_model was resolved. The
+// run manifest keeps one per node under "model_selection" so resume and fork
+// run the same models even if the catalog has changed since.
+type AutoModelSelection struct {
+	Spec     string `json:"spec"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// resolveAutoModels replaces auto(...) llm_model values with concrete model
+// IDs. A recorded selection for the node wins if its selector is unchanged;
+// otherwise the model is chosen from catalog within the node's llm_provider.
+// With no catalog, unrecorded selectors are left as they are. Malformed
+// selectors are also left for the auto_model_syntax lint to report.
+func resolveAutoModels(g *model.Graph, catalog *modeldb.Catalog, recorded map[string]AutoModelSelection) []validate.Diagnostic {
+	if g == nil {
+		return nil
+	}
+	var diags []validate.Diagnostic
+	for _, id := range sortedNodeIDs(g) {
+		n := g.Nodes[id]
+		spec := strings.TrimSpace(n.Attrs["llm_model"])
+		if !modeldb.IsAutoModelSpec(spec) {
+			continue
+		}
+		if rec, ok := recorded[id]; ok && rec.Spec == spec && strings.TrimSpace(rec.Model) != "" {
+			n.Attrs["llm_model"] = rec.Model
+			n.Attrs[autoModelSpecAttr] = spec
+			continue
+		}
+		if catalog == nil {
+			continue
+		}
+		req, err := modeldb.ParseAutoModelSpec(spec)
+		if err != nil {
+			continue
+		}
+		modelID, _, err := modeldb.SelectModel(catalog, n.Attr("llm_provider", ""), req)
+		if err != nil {
+			diags = append(diags, validate.Diagnostic{
+				Rule:     "auto_model_unresolved",
+				Severity: validate.SeverityError,
+				Message:  fmt.Sprintf("llm_model %s: %v", spec, err),
+				NodeID:   id,
+				Fix:      "relax the auto(...) requirements or name a model explicitly",
+			})
+			continue
+		}
+		n.Attrs["llm_model"] = modelID
+		n.Attrs[autoModelSpecAttr] = spec
+	}
+	return diags
+}
+
+// autoModelSelections returns the resolved auto(...) selectors of g, keyed by
+// node ID.
+func autoModelSelections(g *model.Graph) map[string]AutoModelSelection {
+	if g == nil {
+		return nil
+	}
+	out := map[string]AutoModelSelection{}
+	for id, n := range g.Nodes {
+		if n == nil {
+			continue
+		}
+		spec := strings.TrimSpace(n.Attrs[autoModelSpecAttr])
+		if spec == "" {
+			continue
+		}
+		out[id] = AutoModelSelection{
+			Spec:     spec,
+			Provider: n.Attr("llm_provider", ""),
+			Model:    n.Attr("llm_model", ""),
+		}
+	}
+	return out
+}
+
+// unresolvedAutoModels returns an error naming the nodes whose llm_model is
+// still an auto(...) selector.
+func unresolvedAutoModels(g *model.Graph) error {
+	if g == nil {
+		return nil
+	}
+	var ids []string
+	for _, id := range sortedNodeIDs(g) {
+		if modeldb.IsAutoModelSpec(g.Nodes[id].Attrs["llm_model"]) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return fmt.Errorf("llm_model auto(...) could not be resolved without a model catalog (nodes: %s)", strings.Join(ids, ", "))
+}
+
+func sortedNodeIDs(g *model.Graph) []string {
+	ids := make([]string, 0, len(g.Nodes))
+	for id, n := range g.Nodes {
+		if n != nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/attractor/modeldb"
+)
+
+func TestPrepare_ResolvesAutoModelSelectors(t *testing.T) {
+	price := func(perMTok float64) *float64 { v := perMTok / 1e6; return &v }
+	cat := &modeldb.Catalog{
+		CoveredProviders: map[string]bool{"anthropic": true},
+		Models: map[string]modeldb.ModelEntry{
+			"anthropic/claude-opus-4.1":  {Provider: "anthropic", ContextWindow: 200000, SupportsTools: true, SupportsReasoning: true, InputCostPerToken: price(15), Created: 100},
+			"anthropic/claude-opus-4.6":  {Provider: "anthropic", ContextWindow: 1000000, SupportsTools: true, SupportsReasoning: true, InputCostPerToken: price(5), Created: 300},
+			"anthropic/claude-haiku-4.5": {Provider: "anthropic", ContextWindow: 200000, SupportsTools: true, InputCostPerToken: price(1), Created: 200},
+		},
+	}
+	dotSrc := []byte(`
+digraph G {
+  graph [model_stylesheet="* { llm_provider: anthropic; llm_model: auto(tools, reasoning, max_cost_per_mtok=5); } .cheap { llm_model: auto(tools, prefer=cheapest); }"]
+  start [shape=Mdiamond]
+  plan [shape=box, prompt="plan"]
+  lint [shape=box, class="cheap", prompt="lint"]
+  exit [shape=Msquare]
+  start -> plan -> lint
+  lint -> exit [condition="outcome=success"]
+}
+`)
+	g, _, err := PrepareWithOptions(dotSrc, PrepareOptions{Catalog: cat})
+	if err != nil {
+		t.Fatalf("PrepareWithOptions: %v", err)
+	}
+	if got := g.Nodes["plan"].Attr("llm_model", ""); got != "claude-opus-4.6" {
+		t.Fatalf("plan llm_model = %q", got)
+	}
+	if got := g.Nodes["lint"].Attr("llm_model", ""); got != "claude-haiku-4.5" {
+		t.Fatalf("lint llm_model = %q", got)
+	}
+	sel := autoModelSelections(g)
+	if sel["plan"] != (AutoModelSelection{Spec: "auto(tools, reasoning, max_cost_per_mtok=5)", Provider: "anthropic", Model: "claude-opus-4.6"}) {
+		t.Fatalf("selections = %+v", sel)
+	}
+
+	// Resume replays the manifest's choice even when the catalog would now
+	// pick something else, and without a catalog at all.
+	recorded := map[string]AutoModelSelection{"plan": {Spec: sel["plan"].Spec, Provider: "anthropic", Model: "claude-opus-4.1"}}
+	g, _, err = PrepareWithOptions(dotSrc, PrepareOptions{ModelSelections: recorded})
+	if err != nil {
+		t.Fatalf("PrepareWithOptions: %v", err)
+	}
+	if got := g.Nodes["plan"].Attr("llm_model", ""); got != "claude-opus-4.1" {
+		t.Fatalf("replayed llm_model = %q", got)
+	}
+	if err := unresolvedAutoModels(g); err == nil || !strings.Contains(err.Error(), "lint") {
+		t.Fatalf("expected lint to be unresolved without a catalog, got %v", err)
+	}
+}
+
+func TestPrepare_AutoModelWithoutMatchFails(t *testing.T) {
+	cat := &modeldb.Catalog{
+		CoveredProviders: map[string]bool{"openai": true},
+		Models:           map[string]modeldb.ModelEntry{"openai/gpt-5.4": {Provider: "openai", ContextWindow: 400000}},
+	}
+	dotSrc := []byte(`
+digraph G {
+  start [shape=Mdiamond]
+  a [shape=box, prompt="do it", llm_provider=openai, llm_model="auto(min_context=1m)"]
+  exit [shape=Msquare]
+  start -> a
+  a -> exit [condition="outcome=success"]
+}
+`)
+	_, diags, err := PrepareWithOptions(dotSrc, PrepareOptions{Catalog: cat})
+	if err == nil || !strings.Contains(err.Error(), "auto_model_unresolved") {
+		t.Fatalf("expected auto_model_unresolved error, got %v", err)
+	}
+	if len(diags) == 0 || diags[0].NodeID != "a" {
+		t.Fatalf("diags = %+v", diags)
+	}
+}
@@ -56,7 +56,7 @@ func findConcurrentJoinForSplit(g *model.Graph, splitNode *model.Node) *model.No
 		if n == nil {
 			continue
 		}
-		if shapeToType(n.Shape()) != "concurrent.join" {
+		if model.ShapeType(n.Shape()) != "concurrent.join" {
 			continue
 		}
 		joinID := strings.TrimSpace(n.Attr("concurrent_id", ""))
@@ -210,7 +210,7 @@ func (e *Engine) runBranchUntilJoin(ctx context.Context, startNode, stopAtNode s
 		// Reject nested concurrent regions and loops inside a concurrent
 		// region to keep the semantics simple for now. These are graph
 		// validation errors but we also guard at runtime.
-		handlerType := shapeToType(node.Shape())
+		handlerType := model.ShapeType(node.Shape())
 		if handlerType == "concurrent.split" || handlerType == "concurrent.join" {
 			res.Err = fmt.Errorf("branch: nested concurrent regions not supported (node %q)", current)
 			res.FailedNode = current
@@ -226,6 +226,8 @@ func (e *Engine) runBranchUntilJoin(ctx context.Context, startNode, stopAtNode s
 		e.cxdbStageStarted(ctx, node)
 		attempt := nodeRetries[node.ID] + 1
 		nodeDBID := e.rundbRecordNodeStart(node.ID, attempt, resolvedHandlerTypeName(e, node.ID))
+		// A branch is identified by the node it starts at.
+		e.rundbRecordNodeBranch(nodeDBID, startNode)
 		out, err := e.executeWithRetry(ctx, node, nodeRetries)
 		if err != nil {
 			res.Err = err
@@ -250,7 +252,7 @@ func (e *Engine) runBranchUntilJoin(ctx context.Context, startNode, stopAtNode s
 
 		// Edge selection. Branches use the same next-hop logic as the main
 		// loop but only follow a single edge at a time — no nested splits.
-		failureClass := classifyFailureClass(out)
+		failureClass, _ := e.classifyFailure(node.ID, out)
 		nextHop, edgeErr := resolveNextHop(e.Graph, node.ID, out, e.Context, failureClass, e.appendProgress)
 		if edgeErr != nil {
 			res.Err = edgeErr
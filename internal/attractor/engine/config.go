@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -58,6 +59,21 @@ type PreflightConfig struct {
 	PromptProbes PromptProbeConfig `json:"prompt_probes,omitempty" yaml:"prompt_probes,omitempty"`
 }
 
+// TelemetryConfig controls OpenTelemetry export of run traces and metrics.
+type TelemetryConfig struct {
+	OTLP OTLPConfig `json:"otlp,omitempty" yaml:"otlp,omitempty"`
+}
+
+// OTLPConfig selects an OTLP/JSON destination. Endpoint is an OTLP/HTTP base
+// URL (e.g. http://localhost:4318); File appends requests as JSON lines. When
+// both are empty, OTEL_EXPORTER_OTLP_ENDPOINT is used if set.
+type OTLPConfig struct {
+	Endpoint    string            `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	File        string            `json:"file,omitempty" yaml:"file,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	ServiceName string            `json:"service_name,omitempty" yaml:"service_name,omitempty"`
+}
+
 type InputMaterializationConfig struct {
 	Enabled          *bool                           `json:"enabled,omitempty" yaml:"enabled,omitempty"`
 	Include          []string                        `json:"include,omitempty" yaml:"include,omitempty"`
@@ -99,6 +115,12 @@ type RunConfigFile struct {
 				URL     string   `json:"url" yaml:"url"`
 			} `json:"ui" yaml:"ui"`
 		} `json:"autostart" yaml:"autostart"`
+		// Embedded serves the built-in CXDB store in-process on the configured
+		// addresses when nothing is listening there (no Docker needed).
+		Embedded struct {
+			Enabled bool   `json:"enabled" yaml:"enabled"`
+			DataDir string `json:"data_dir" yaml:"data_dir"`
+		} `json:"embedded" yaml:"embedded"`
 	} `json:"cxdb" yaml:"cxdb"`
 
 	LLM struct {
@@ -131,6 +153,12 @@ type RunConfigFile struct {
 	RuntimePolicy RuntimePolicyConfig `json:"runtime_policy,omitempty" yaml:"runtime_policy,omitempty"`
 	Preflight     PreflightConfig     `json:"preflight,omitempty" yaml:"preflight,omitempty"`
 	Inputs        InputConfig         `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+	Telemetry     TelemetryConfig     `json:"telemetry,omitempty" yaml:"telemetry,omitempty"`
+
+	FailureClassification FailureClassificationConfig `json:"failure_classification,omitempty" yaml:"failure_classification,omitempty"`
+
+	LanguageServers map[string]LanguageServerConfig `json:"language_servers,omitempty" yaml:"language_servers,omitempty"`
+	MCPServers      map[string]MCPServerRunConfig   `json:"mcp_servers,omitempty" yaml:"mcp_servers,omitempty"`
 }
 
 func LoadRunConfigFile(path string) (*RunConfigFile, error) {
@@ -185,10 +213,23 @@ func decodeJSONStrict(b []byte, cfg *RunConfigFile) error {
 	return nil
 }
 
-func decodeYAMLStrict(b []byte, cfg *RunConfigFile) error {
+// LoadYAMLFile decodes the YAML file at path into v strictly: unknown fields
+// and extra documents are errors. An empty file leaves v unchanged.
+func LoadYAMLFile(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := decodeYAMLStrict(b, v); err != nil && err != io.EOF {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func decodeYAMLStrict(b []byte, v any) error {
 	dec := yaml.NewDecoder(bytes.NewReader(b))
 	dec.KnownFields(true)
-	if err := dec.Decode(cfg); err != nil {
+	if err := dec.Decode(v); err != nil {
 		return err
 	}
 	for {
@@ -262,6 +303,7 @@ func applyConfigDefaults(cfg *RunConfigFile) {
 	cfg.CXDB.Autostart.Command = trimNonEmpty(cfg.CXDB.Autostart.Command)
 	cfg.CXDB.Autostart.UI.Command = trimNonEmpty(cfg.CXDB.Autostart.UI.Command)
 	cfg.CXDB.Autostart.UI.URL = strings.TrimSpace(cfg.CXDB.Autostart.UI.URL)
+	cfg.CXDB.Embedded.DataDir = strings.TrimSpace(cfg.CXDB.Embedded.DataDir)
 
 	// Runtime policy defaults are explicit to preserve stable operator behavior.
 	if cfg.RuntimePolicy.StageTimeoutMS == nil {
@@ -319,6 +361,23 @@ func validateConfig(cfg *RunConfigFile) error {
 	if cfg.CXDB.Autostart.Enabled && len(cfg.CXDB.Autostart.Command) == 0 {
 		return fmt.Errorf("cxdb.autostart.command is required when cxdb.autostart.enabled=true")
 	}
+	if cfg.CXDB.Embedded.Enabled && cfg.CXDB.Autostart.Enabled {
+		return fmt.Errorf("cxdb.embedded.enabled and cxdb.autostart.enabled are mutually exclusive")
+	}
+	if ep := strings.TrimSpace(cfg.Telemetry.OTLP.Endpoint); ep != "" {
+		if u, err := url.Parse(ep); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid telemetry.otlp.endpoint: %q (want http(s)://host:port)", ep)
+		}
+	}
+	if _, err := NewFailureClassifier("failure_classification", cfg.FailureClassification.Rules); err != nil {
+		return err
+	}
+	if err := validateLanguageServers(cfg.LanguageServers); err != nil {
+		return err
+	}
+	if err := validateMCPServers(cfg.MCPServers); err != nil {
+		return err
+	}
 	// Model catalog is optional: when no path is configured the engine falls
 	// back to the embedded catalog at bootstrap time.
 	if strings.TrimSpace(cfg.ModelDB.OpenRouterModelInfoPath) != "" {
@@ -1060,3 +1060,34 @@ preflight:
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestLoadYAMLFile_StrictAndEmpty(t *testing.T) {
+	dir := t.TempDir()
+	var doc struct {
+		Rules []string `yaml:"rules"`
+	}
+
+	empty := filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(empty, []byte("# nothing yet\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadYAMLFile(empty, &doc); err != nil || doc.Rules != nil {
+		t.Fatalf("empty file: doc=%+v err=%v", doc, err)
+	}
+
+	typo := filepath.Join(dir, "typo.yaml")
+	if err := os.WriteFile(typo, []byte("rulez: [a]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadYAMLFile(typo, &doc); err == nil || !strings.Contains(err.Error(), "parse "+typo) || !strings.Contains(err.Error(), "rulez") {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+
+	multi := filepath.Join(dir, "multi.yaml")
+	if err := os.WriteFile(multi, []byte("rules: [a]\n---\nrules: [b]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadYAMLFile(multi, &doc); err == nil {
+		t.Fatal("expected multiple documents to be rejected")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -34,6 +35,7 @@ type CXDBStartupInfo struct {
 
 	managedMu sync.Mutex
 	managed   []*startedProcess
+	embedded  *cxdb.LocalServer
 }
 
 type startedProcess struct {
@@ -93,6 +95,8 @@ func (i *CXDBStartupInfo) shutdownManagedProcesses() error {
 	}
 	i.managedMu.Lock()
 	procs := append([]*startedProcess{}, i.managed...)
+	embedded := i.embedded
+	i.embedded = nil
 	i.managedMu.Unlock()
 	errs := make([]string, 0)
 	for _, proc := range procs {
@@ -100,6 +104,9 @@ func (i *CXDBStartupInfo) shutdownManagedProcesses() error {
 			errs = append(errs, err.Error())
 		}
 	}
+	if err := embedded.Close(); err != nil {
+		errs = append(errs, err.Error())
+	}
 	if len(errs) == 0 {
 		return nil
 	}
@@ -133,9 +140,12 @@ func ensureCXDBReady(ctx context.Context, cfg *RunConfigFile, logsRoot string, r
 		startCXDBUI(ctx, cfg, logsRoot, runID, info)
 		return client, bin, info, nil
 	}
+	if cfg.CXDB.Embedded.Enabled {
+		return startEmbeddedCXDB(ctx, cfg, logsRoot, runID, client, info, connect)
+	}
 	if !cfg.CXDB.Autostart.Enabled {
 		return nil, nil, nil, fmt.Errorf(
-			"cxdb is not reachable (http=%s binary=%s): %w; either start CXDB manually or set cxdb.autostart.enabled=true with cxdb.autostart.command",
+			"cxdb is not reachable (http=%s binary=%s): %w; either start CXDB manually (or `kilroy cxdb serve`), set cxdb.embedded.enabled=true, or set cxdb.autostart.enabled=true with cxdb.autostart.command",
 			cfg.CXDB.HTTPBaseURL,
 			cfg.CXDB.BinaryAddr,
 			err,
@@ -216,6 +226,43 @@ func ensureCXDBReady(ctx context.Context, cfg *RunConfigFile, logsRoot string, r
 	)
 }
 
+// startEmbeddedCXDB serves the built-in store on the configured addresses for
+// the lifetime of the run. Using the configured (not ephemeral) addresses keeps
+// the manifest's http_base_url valid for `resume --cxdb` once the same data dir
+// is served again with `kilroy cxdb serve`.
+func startEmbeddedCXDB(ctx context.Context, cfg *RunConfigFile, logsRoot string, runID string, client *cxdb.Client, info *CXDBStartupInfo, connect func() (*cxdb.BinaryClient, error)) (*cxdb.Client, *cxdb.BinaryClient, *CXDBStartupInfo, error) {
+	u, err := url.Parse(cfg.CXDB.HTTPBaseURL)
+	if err != nil || u.Host == "" {
+		return nil, nil, nil, fmt.Errorf("cxdb embedded: invalid cxdb.http_base_url %q", cfg.CXDB.HTTPBaseURL)
+	}
+	dataDir := cfg.CXDB.Embedded.DataDir
+	if dataDir == "" {
+		dataDir = DefaultCXDBDataDir()
+	}
+	ls, err := cxdb.StartLocal(dataDir, u.Host, cfg.CXDB.BinaryAddr)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("cxdb embedded: %w", err)
+	}
+	bin, err := connect()
+	if err != nil {
+		_ = ls.Close()
+		return nil, nil, nil, fmt.Errorf("cxdb embedded: %w", err)
+	}
+	info.managedMu.Lock()
+	info.embedded = ls
+	info.managedMu.Unlock()
+	info.Warnings = append(info.Warnings, fmt.Sprintf("CXDB embedded store serving %s (http=%s binary=%s)", dataDir, ls.HTTPBaseURL, ls.BinaryAddr))
+	startCXDBUI(ctx, cfg, logsRoot, runID, info)
+	return client, bin, info, nil
+}
+
+// DefaultCXDBDataDir is where the embedded CXDB store keeps its data:
+// ${XDG_STATE_HOME:-$HOME/.local/state}/kilroy/cxdb-local. It is kept apart
+// from the Docker CXDB data dir used by scripts/start-cxdb.sh.
+func DefaultCXDBDataDir() string {
+	return filepath.Join(filepath.Dir(filepath.Dir(DefaultRunsBaseDir())), "cxdb-local")
+}
+
 func startCXDBUI(ctx context.Context, cfg *RunConfigFile, logsRoot string, runID string, info *CXDBStartupInfo) {
 	if cfg == nil || info == nil {
 		return
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+	"github.com/danshapiro/kilroy/internal/cxdb"
+)
+
+func freeLocalAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return addr
+}
+
+func TestRunWithConfig_EmbeddedCXDBRecordsTurnsReadableAfterRestart(t *testing.T) {
+	repo := initTestRepo(t)
+	pinned := writePinnedCatalog(t)
+	cfg := minimalToolGraphConfig(repo, pinned)
+	dataDir := filepath.Join(t.TempDir(), "cxdb")
+	cfg.CXDB.HTTPBaseURL = "http://" + freeLocalAddr(t)
+	cfg.CXDB.BinaryAddr = freeLocalAddr(t)
+	cfg.CXDB.Embedded.Enabled = true
+	cfg.CXDB.Embedded.DataDir = dataDir
+
+	dot := []byte(`digraph embedded {
+  start [shape=Mdiamond]
+  build [shape=parallelogram, tool_command="echo built > out.txt"]
+  gate [shape=diamond]
+  done [shape=Msquare]
+  start -> build -> gate
+  gate -> done
+}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	res, err := RunWithConfig(ctx, dot, cfg, RunOptions{RunID: "embedded-cxdb", LogsRoot: t.TempDir()})
+	if err != nil {
+		t.Fatalf("RunWithConfig: %v", err)
+	}
+	if res.FinalStatus != runtime.FinalSuccess {
+		t.Fatalf("final status = %q", res.FinalStatus)
+	}
+	m, err := loadManifest(filepath.Join(res.LogsRoot, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CXDB.ContextID == "" {
+		t.Fatal("manifest has no cxdb context")
+	}
+
+	// The in-process server is gone once the run returns; serving the same
+	// data dir again (as `kilroy cxdb serve` would) exposes the trajectory.
+	ls, err := cxdb.StartLocal(dataDir, "127.0.0.1:0", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+	turns, err := cxdb.New(ls.HTTPBaseURL).ListTurns(ctx, m.CXDB.ContextID, cxdb.ListTurnsOptions{})
+	if err != nil {
+		t.Fatalf("ListTurns: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, turn := range turns {
+		seen[turn.TypeID] = true
+		if turn.TypeID == "com.kilroy.attractor.RunStarted" && turn.Payload["logs_root"] != res.LogsRoot {
+			t.Fatalf("RunStarted logs_root = %v, want %s", turn.Payload["logs_root"], res.LogsRoot)
+		}
+	}
+	for _, want := range []string{"com.kilroy.attractor.RunStarted", "com.kilroy.attractor.CheckpointSaved", "com.kilroy.attractor.RunCompleted"} {
+		if !seen[want] {
+			t.Errorf("missing %s turn (got %v)", want, seen)
+		}
+	}
+}
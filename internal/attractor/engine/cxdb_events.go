@@ -2,10 +2,14 @@ package engine
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/danshapiro/kilroy/internal/agent"
+	"github.com/danshapiro/kilroy/internal/attractor/agents/agentlog"
 	"github.com/danshapiro/kilroy/internal/attractor/model"
 	"github.com/danshapiro/kilroy/internal/attractor/runtime"
 )
@@ -69,6 +73,26 @@ func (e *Engine) CXDBPrompt(ctx context.Context, nodeID, text string) {
 	e.cxdbPrompt(ctx, nodeID, text)
 }
 
+// CXDBAssistantMessage emits an assistant turn to CXDB. Exported for handler
+// packages that drive their own conversations (e.g. tmux follow-ups).
+func (e *Engine) CXDBAssistantMessage(ctx context.Context, nodeID, text, model string) {
+	if e == nil || e.CXDB == nil {
+		return
+	}
+	if _, _, err := e.CXDB.Append(ctx, "com.kilroy.attractor.AssistantMessage", 1, map[string]any{
+		"run_id":         e.Options.RunID,
+		"node_id":        nodeID,
+		"text":           Truncate(text, 8_000),
+		"model":          model,
+		"input_tokens":   uint64(0),
+		"output_tokens":  uint64(0),
+		"tool_use_count": uint32(0),
+		"timestamp_ms":   nowMS(),
+	}); err != nil {
+		e.Warn(fmt.Sprintf("cxdb append AssistantMessage failed (node=%s): %v", nodeID, err))
+	}
+}
+
 func (e *Engine) cxdbStageStarted(ctx context.Context, node *model.Node) {
 	if e == nil || e.CXDB == nil || node == nil {
 		return
@@ -89,7 +113,7 @@ func (e *Engine) cxdbStageFinished(ctx context.Context, node *model.Node, out ru
 		"run_id":             e.Options.RunID,
 		"node_id":            node.ID,
 		"timestamp_ms":       nowMS(),
-		"status":             string(out.Status),
+		"status":             reportedStageStatus(out),
 		"preferred_label":    out.PreferredLabel,
 		"failure_reason":     out.FailureReason,
 		"notes":              out.Notes,
@@ -133,6 +157,8 @@ func (e *Engine) cxdbStageFinished(ctx context.Context, node *model.Node, out ru
 		"api_response.json",
 		"cli_invocation.json",
 		"cli_timing.json",
+		"conversation.jsonl",
+		agentlog.AiderHistoryFile,
 		toolInvocationFileName,
 		toolTimingFileName,
 	} {
@@ -189,7 +215,7 @@ func resolvedHandlerType(n *model.Node) string {
 	if t := strings.TrimSpace(n.TypeOverride()); t != "" {
 		return t
 	}
-	return shapeToType(n.Shape())
+	return model.ShapeType(n.Shape())
 }
 
 // cxdbStageFailed emits a StageFailed event (spec §9.6).
@@ -338,6 +364,41 @@ func (e *Engine) cxdbInterviewTimeout(ctx context.Context, nodeID string, questi
 	})
 }
 
+func (e *Engine) cxdbManagerIntervention(ctx context.Context, managerNodeID string, iv *steerIntervention, target string, delivery steerDelivery, contextKeys []string) {
+	if e == nil || e.CXDB == nil || iv == nil {
+		return
+	}
+	_, _, _ = e.CXDB.Append(ctx, "com.kilroy.attractor.ManagerIntervention", 1, map[string]any{
+		"run_id":          e.Options.RunID,
+		"node_id":         managerNodeID,
+		"timestamp_ms":    nowMS(),
+		"source":          iv.Source,
+		"reason":          iv.Reason,
+		"message":         iv.Message,
+		"target_node_id":  target,
+		"delivery":        string(delivery),
+		"context_keys":    contextKeys,
+		"reroute_node_id": iv.Reroute,
+	})
+}
+
+func (e *Engine) cxdbPlanUpdated(ctx context.Context, nodeID string, items []agent.PlanItem, explanation string) {
+	if e == nil || e.CXDB == nil {
+		return
+	}
+	planJSON, _ := json.Marshal(items)
+	done, total := agent.PlanProgress(items)
+	_, _, _ = e.CXDB.Append(ctx, "com.kilroy.attractor.PlanUpdated", 1, map[string]any{
+		"run_id":       e.Options.RunID,
+		"node_id":      nodeID,
+		"timestamp_ms": nowMS(),
+		"plan_json":    string(planJSON),
+		"completed":    uint32(done),
+		"total":        uint32(total),
+		"explanation":  explanation,
+	})
+}
+
 func (e *Engine) cxdbRunFailed(ctx context.Context, nodeID string, sha string, reason string) (string, error) {
 	if e == nil || e.CXDB == nil {
 		return "", nil
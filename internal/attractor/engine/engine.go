@@ -15,6 +15,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/danshapiro/kilroy/internal/agent"
 	"github.com/danshapiro/kilroy/internal/attractor/cond"
 	"github.com/danshapiro/kilroy/internal/attractor/dot"
 	"github.com/danshapiro/kilroy/internal/attractor/model"
@@ -130,6 +131,10 @@ type RunOptions struct {
 
 	// CLI arguments used to launch this run. Captured from os.Args.
 	Invocation []string
+
+	// When true, retries proceed immediately instead of sleeping for the
+	// configured backoff delay. Used by the graph simulator.
+	DisableRetryBackoff bool
 }
 
 func (o *RunOptions) applyDefaults() error {
@@ -286,6 +291,22 @@ type Engine struct {
 	lastProgressAt time.Time
 	progressSink   func(map[string]any)
 
+	// steering is set on child pipelines supervised by a manager loop with
+	// the steer action (see manager_steer.go).
+	steering *steeringHub
+
+	// telemetry exports the run as OTLP spans and metrics when configured
+	// (see telemetry.go). Parallel branches share the parent's.
+	telemetry *runTelemetry
+
+	// failureClassifier holds the run's failure_classification rules (see
+	// failure_classification.go). Nil means built-in classification only.
+	failureClassifier *FailureClassifier
+
+	// agentTools are the custom tools from the graph's agent_tools file (see
+	// agent_tools.go), offered to API agent_loop sessions.
+	agentTools []agent.CustomTool
+
 	// Fidelity/session resolution state.
 	incomingEdge          *model.Edge // edge used to reach the current node (nil for start)
 	forceNextFidelity     string      // non-empty => override resolved fidelity for the next LLM node
@@ -364,8 +385,13 @@ type PrepareOptions struct {
 	KnownTypes []string
 	// Catalog is an optional modeldb catalog. When non-nil, model ID catalog
 	// checks (stylesheet_unknown_model, stylesheet_noncanonical_model_id) are
-	// enabled. When nil, those checks are silently skipped.
+	// enabled. When nil, those checks are silently skipped. It also resolves
+	// llm_model auto(...) selectors.
 	Catalog *modeldb.Catalog
+	// ModelSelections are auto(...) resolutions recorded by an earlier
+	// prepare of the same graph (the run manifest's model_selection); they
+	// take precedence over Catalog.
+	ModelSelections map[string]AutoModelSelection
 }
 
 // Prepare parses/transforms/validates a graph.
@@ -411,6 +437,7 @@ func PrepareWithOptions(dotSource []byte, opts PrepareOptions) (*model.Graph, []
 		}
 		_ = style.ApplyStylesheet(g, rules)
 	}
+	autoDiags := resolveAutoModels(g, opts.Catalog, opts.ModelSelections)
 	_ = (goalExpansionTransform{}).Apply(g)
 
 	// Custom transforms run after built-ins, in registration order.
@@ -429,7 +456,7 @@ func PrepareWithOptions(dotSource []byte, opts PrepareOptions) (*model.Graph, []
 	if len(opts.KnownTypes) > 0 {
 		extraRules = append(extraRules, validate.NewTypeKnownRule(opts.KnownTypes))
 	}
-	diags := validate.ValidateWithOptions(g, validate.ValidateOptions{Catalog: opts.Catalog}, extraRules...)
+	diags := append(autoDiags, validate.ValidateWithOptions(g, validate.ValidateOptions{Catalog: opts.Catalog}, extraRules...)...)
 	var errs []string
 	for _, d := range diags {
 		if d.Severity == validate.SeverityError {
@@ -470,6 +497,8 @@ func Run(ctx context.Context, dotSource []byte, opts RunOptions) (*Result, error
 func (e *Engine) run(ctx context.Context) (res *Result, err error) {
 	runCtx, cancelRun := context.WithCancelCause(ctx)
 	defer cancelRun(nil)
+	// Registered first so it runs last, after the terminal progress event.
+	defer e.stopTelemetry()
 
 	var releaseOwnership func()
 	defer func() {
@@ -510,6 +539,7 @@ func (e *Engine) run(ctx context.Context) (res *Result, err error) {
 		e.RunLog = rl
 		defer e.RunLog.Close()
 	}
+	e.startTelemetry()
 	releaseOwnership, err = acquireRunOwnership(e.LogsRoot, e.Options.RunID)
 	if err != nil {
 		return nil, err
@@ -548,6 +578,12 @@ func (e *Engine) run(ctx context.Context) (res *Result, err error) {
 			return nil, fmt.Errorf("materialize package: %w", err)
 		}
 	}
+	if err := e.initFailureClassifier(); err != nil {
+		return nil, err
+	}
+	if err := e.initAgentTools(); err != nil {
+		return nil, err
+	}
 	// Create .kilroy/ convention directory and write INPUT.md.
 	if err := initKilroyDir(e.WorktreeDir); err != nil {
 		e.Warn("create .kilroy/ directory: " + err.Error())
@@ -783,11 +819,16 @@ func (e *Engine) runLoop(ctx context.Context, current string, completed []string
 			startAttempt = iter + 1
 		}
 		nodeDBID := e.rundbRecordNodeStart(node.ID, startAttempt, resolvedHandlerTypeName(e, node.ID))
-		out, err := e.executeWithRetry(ctx, node, nodeRetries)
-		if err != nil {
-			return nil, err
+		cacheKey, cacheTreeSHA := e.nodeCacheKey(node)
+		out, cached := e.restoreCachedNode(node, cacheKey)
+		if !cached {
+			var err error
+			out, err = e.executeWithRetry(ctx, node, nodeRetries)
+			if err != nil {
+				return nil, err
+			}
+			e.rundbRecordProviderIfAgent(node.ID, nodeRetries[node.ID]+1)
 		}
-		e.rundbRecordProviderIfAgent(node.ID, nodeRetries[node.ID]+1)
 		e.cxdbStageFinished(ctx, node, out)
 		e.rundbRecordNodeComplete(nodeDBID, out)
 		e.rundbCaptureNodeArtifacts(nodeDBID, node.ID)
@@ -809,7 +850,7 @@ func (e *Engine) runLoop(ctx context.Context, current string, completed []string
 				"keys": contextUpdateKeys(out.ContextUpdates),
 			})
 		}
-		failureClass := classifyFailureClass(out)
+		failureClass, _ := e.classifyFailure(node.ID, out)
 		e.Context.Set("failure_class", failureClass)
 		e.updateFailureDossierContext(node, out, failureClass, nodeRetries)
 
@@ -869,13 +910,14 @@ func (e *Engine) runLoop(ctx context.Context, current string, completed []string
 
 		// Record git diff for this node if SHAs differ.
 		e.recordNodeDiff(node.ID, nodeRetries[node.ID]+1, beforeSHA, sha)
+		e.recordNodeCache(node, cacheKey, cacheTreeSHA, sha, out)
 
 		// Concurrent primitive: when the just-completed node is a
 		// concurrent.split, dispatch all outgoing edges as concurrent
 		// branches in the shared workspace and resume at the paired join.
 		// Evaluated before the loop check so concurrent regions can't be
 		// confused with loop back-edges.
-		if shapeToType(node.Shape()) == "concurrent.split" {
+		if model.ShapeType(node.Shape()) == "concurrent.split" {
 			joinID, concErr := e.runConcurrentRegion(ctx, node, &completed, nodeRetries, nodeOutcomes)
 			if concErr != nil {
 				failedTurnID, _ := e.cxdbRunFailed(ctx, node.ID, sha, concErr.Error())
@@ -922,7 +964,7 @@ func (e *Engine) runLoop(ctx context.Context, current string, completed []string
 
 		// Kilroy v1: explicit parallel nodes control the next hop via context.
 		isExplicitParallel := false
-		if t := strings.TrimSpace(node.TypeOverride()); t == "parallel" || (t == "" && shapeToType(node.Shape()) == "parallel") {
+		if t := strings.TrimSpace(node.TypeOverride()); t == "parallel" || (t == "" && model.ShapeType(node.Shape()) == "parallel") {
 			isExplicitParallel = true
 			join := strings.TrimSpace(e.Context.GetString("parallel.join_node", ""))
 			if join == "" {
@@ -1421,14 +1463,22 @@ func (e *Engine) executeWithRetry(ctx context.Context, node *model.Node, retries
 			"status":      string(out.Status),
 			"duration_ms": dur.Milliseconds(),
 		})
-		e.appendProgress(map[string]any{
+		endEv := map[string]any{
 			"event":          "stage_attempt_end",
 			"node_id":        node.ID,
 			"attempt":        1,
 			"max":            1,
 			"status":         string(out.Status),
 			"failure_reason": out.FailureReason,
-		})
+			"handler":        handlerType,
+		}
+		if fc, rule := e.classifyFailure(node.ID, out); fc != "" {
+			endEv["failure_class"] = fc
+			if rule != "" {
+				endEv["failure_rule"] = rule
+			}
+		}
+		e.appendProgress(endEv)
 		return out, nil
 	}
 
@@ -1492,14 +1542,22 @@ func (e *Engine) executeWithRetry(ctx context.Context, node *model.Node, retries
 			"duration_ms": attemptDur.Milliseconds(),
 			"attempt":     attempt,
 		})
-		e.appendProgress(map[string]any{
+		endEv := map[string]any{
 			"event":          "stage_attempt_end",
 			"node_id":        node.ID,
 			"attempt":        attempt,
 			"max":            maxAttempts,
 			"status":         string(out.Status),
 			"failure_reason": out.FailureReason,
-		})
+			"handler":        handlerType,
+		}
+		if fc, rule := e.classifyFailure(node.ID, out); fc != "" {
+			endEv["failure_class"] = fc
+			if rule != "" {
+				endEv["failure_rule"] = rule
+			}
+		}
+		e.appendProgress(endEv)
 		if ctx.Err() != nil {
 			co := canceledOutcomeForRetry(ctx, out)
 			fo, _ := co.Canonicalize()
@@ -1533,15 +1591,16 @@ func (e *Engine) executeWithRetry(ctx context.Context, node *model.Node, retries
 			return out, nil
 		}
 
-		failureClass := classifyFailureClass(out)
+		failureClass, _ := e.classifyFailure(node.ID, out)
 		// Spec §9.6: emit StageFailed CXDB event on failure.
 		willRetry := false // updated below if retry is possible
 		canRetry := false
 		if attempt < maxAttempts {
-			// Tool command nodes (shape=parallelogram) always retry when
+			// Tool and test command nodes (shape=parallelogram) always retry when
 			// max_retries is set — the user explicitly opted in. LLM/API
 			// nodes use failure classification to gate retries.
-			isToolNode := strings.TrimSpace(node.Attr("tool_command", "")) != ""
+			toolCmd, _ := toolNodeCommand(node)
+			isToolNode := toolCmd != ""
 			if isToolNode {
 				canRetry = out.Status == runtime.StatusFail || out.Status == runtime.StatusRetry
 			} else if shouldRetryOutcome(out, failureClass) {
@@ -1603,6 +1662,9 @@ func (e *Engine) executeWithRetry(ctx context.Context, node *model.Node, retries
 			// Spec §5.1: update built-in context key internal.retry_count.<node_id> on each retry.
 			e.Context.Set(fmt.Sprintf("internal.retry_count.%s", node.ID), retries[node.ID])
 			delay := backoffDelayForNode(e.Options.RunID, e.Graph, node, attempt)
+			if e.Options.DisableRetryBackoff {
+				delay = 0
+			}
 			// Spec §9.6: emit StageRetrying CXDB event.
 			e.cxdbStageRetrying(ctx, node, attempt+1, delay.Milliseconds())
 			e.appendProgress(map[string]any{
@@ -1826,7 +1888,7 @@ func (e *Engine) checkpoint(nodeID string, out runtime.Outcome, completed []stri
 	// region consolidates into a single commit at the concurrent.join node.
 	if e.concurrentDepth > 0 && e.Graph != nil {
 		if n := e.Graph.Nodes[nodeID]; n != nil {
-			t := shapeToType(n.Shape())
+			t := model.ShapeType(n.Shape())
 			if t != "concurrent.join" && t != "concurrent.split" {
 				// Keep the last known SHA so checkpoint metadata is consistent.
 				return e.lastCheckpointSHA, nil
@@ -1880,9 +1942,18 @@ func (e *Engine) checkpoint(nodeID string, out runtime.Outcome, completed []stri
 		Version: artifactPolicyResolvedVersion,
 		Policy:  normalizeResolvedArtifactPolicy(e.ArtifactPolicy),
 	}
+	if e.CXDB != nil && strings.TrimSpace(e.CXDB.HeadTurnID) != "" {
+		cp.Extra["cxdb_head_turn_id"] = e.CXDB.HeadTurnID
+	}
 	if err := cp.Save(filepath.Join(e.LogsRoot, "checkpoint.json")); err != nil {
 		return "", err
 	}
+	e.telemetry.checkpoint(nodeID, sha)
+	// Keep a per-node copy so a later run can fork from this point; the
+	// run-level checkpoint.json only holds the latest node.
+	if err := cp.Save(filepath.Join(e.LogsRoot, nodeID, "checkpoint.json")); err != nil {
+		e.Warn(fmt.Sprintf("save node checkpoint %s: %v", nodeID, err))
+	}
 	return sha, nil
 }
 
@@ -1932,6 +2003,9 @@ func (e *Engine) writeManifest(baseSHA string) error {
 	if ws := e.warningsCopy(); len(ws) > 0 {
 		manifest["warnings"] = ws
 	}
+	if sel := autoModelSelections(e.Graph); len(sel) > 0 {
+		manifest["model_selection"] = sel
+	}
 	if len(e.Options.ForceModels) > 0 {
 		manifest["force_models"] = copyStringStringMap(e.Options.ForceModels)
 	}
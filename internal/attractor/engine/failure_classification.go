@@ -0,0 +1,349 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+// failureClassificationGraphAttr names a rules file (relative to the
+// worktree) that extends the run config's failure_classification rules.
+const failureClassificationGraphAttr = "failure_classification"
+
+// failureRuleStderrTailBytes bounds how much of stderr.log rules see.
+const failureRuleStderrTailBytes = 16 * 1024
+
+var customFailureClassRE = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// FailureClassificationConfig is the failure_classification section of
+// run.yaml (and the format of the graph-level rules file).
+type FailureClassificationConfig struct {
+	Rules []FailureClassificationRule `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+// FailureClassificationRule maps a failure to a class. All conditions that
+// are set must hold; match/contains are tested against the fields listed in
+// In (failure_reason and stderr by default). Class may be a built-in class
+// (transient_infra, deterministic, budget_exhausted, ...) or a custom name,
+// which retries and cycle detection treat as deterministic but edges can
+// route on via context.failure_class.
+type FailureClassificationRule struct {
+	Name      string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Class     string   `json:"class" yaml:"class"`
+	Match     string   `json:"match,omitempty" yaml:"match,omitempty"`
+	Contains  string   `json:"contains,omitempty" yaml:"contains,omitempty"`
+	In        []string `json:"in,omitempty" yaml:"in,omitempty"`
+	ExitCodes []int    `json:"exit_codes,omitempty" yaml:"exit_codes,omitempty"`
+	Nodes     []string `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+}
+
+// FailureEvidence is what rules are matched against.
+type FailureEvidence struct {
+	NodeID   string
+	Reason   string
+	Stderr   string
+	ExitCode *int
+}
+
+// FailureClassifier applies ordered classification rules; the first
+// matching rule wins.
+type FailureClassifier struct {
+	rules []compiledFailureRule
+}
+
+type compiledFailureRule struct {
+	FailureClassificationRule
+	re       *regexp.Regexp
+	contains string
+	inReason bool
+	inStderr bool
+	nodes    map[string]bool
+	exits    map[int]bool
+}
+
+// NewFailureClassifier validates and compiles rules. source prefixes error
+// messages (e.g. "failure_classification" or a file path).
+func NewFailureClassifier(source string, rules []FailureClassificationRule) (*FailureClassifier, error) {
+	c := &FailureClassifier{}
+	if err := c.add(source, rules); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *FailureClassifier) add(source string, rules []FailureClassificationRule) error {
+	for i, r := range rules {
+		if strings.TrimSpace(r.Name) == "" {
+			r.Name = fmt.Sprintf("rule_%d", len(c.rules)+1)
+		}
+		cls, err := failureRuleClass(r.Class)
+		if err != nil {
+			return fmt.Errorf("%s: rule %d (%s): %w", source, i+1, r.Name, err)
+		}
+		r.Class = cls
+		cr := compiledFailureRule{FailureClassificationRule: r}
+		if strings.TrimSpace(r.Match) == "" && r.Contains == "" && len(r.ExitCodes) == 0 {
+			return fmt.Errorf("%s: rule %d (%s) needs match, contains or exit_codes", source, i+1, r.Name)
+		}
+		if m := strings.TrimSpace(r.Match); m != "" {
+			re, err := regexp.Compile(m)
+			if err != nil {
+				return fmt.Errorf("%s: rule %d (%s): invalid match: %w", source, i+1, r.Name, err)
+			}
+			cr.re = re
+		}
+		cr.contains = strings.ToLower(r.Contains)
+		if len(r.In) == 0 {
+			cr.inReason, cr.inStderr = true, true
+		}
+		for _, f := range r.In {
+			switch strings.ToLower(strings.TrimSpace(f)) {
+			case "failure_reason", "reason":
+				cr.inReason = true
+			case "stderr":
+				cr.inStderr = true
+			default:
+				return fmt.Errorf("%s: rule %d (%s): invalid in %q (want failure_reason|stderr)", source, i+1, r.Name, f)
+			}
+		}
+		if len(r.Nodes) > 0 {
+			cr.nodes = map[string]bool{}
+			for _, n := range r.Nodes {
+				cr.nodes[strings.TrimSpace(n)] = true
+			}
+		}
+		if len(r.ExitCodes) > 0 {
+			cr.exits = map[int]bool{}
+			for _, code := range r.ExitCodes {
+				cr.exits[code] = true
+			}
+		}
+		c.rules = append(c.rules, cr)
+	}
+	return nil
+}
+
+// failureRuleClass canonicalizes built-in class aliases and validates
+// custom class names.
+func failureRuleClass(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("class is required")
+	}
+	if cls, ok := canonicalFailureClass(raw); ok {
+		return cls, nil
+	}
+	if !customFailureClassRE.MatchString(raw) {
+		return "", fmt.Errorf("invalid class %q (want a built-in class or [a-z][a-z0-9_]*)", raw)
+	}
+	return raw, nil
+}
+
+// Len returns the number of rules.
+func (c *FailureClassifier) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.rules)
+}
+
+// Match returns the class and rule name of the first rule that matches, or
+// empty strings when none does.
+func (c *FailureClassifier) Match(ev FailureEvidence) (class, rule string) {
+	if c == nil {
+		return "", ""
+	}
+	for _, r := range c.rules {
+		if r.matches(ev) {
+			return r.Class, r.Name
+		}
+	}
+	return "", ""
+}
+
+func (r compiledFailureRule) matches(ev FailureEvidence) bool {
+	if r.nodes != nil && !r.nodes[ev.NodeID] {
+		return false
+	}
+	if r.exits != nil && (ev.ExitCode == nil || !r.exits[*ev.ExitCode]) {
+		return false
+	}
+	if r.re == nil && r.contains == "" {
+		return true
+	}
+	var texts []string
+	if r.inReason {
+		texts = append(texts, ev.Reason)
+	}
+	if r.inStderr && ev.Stderr != "" {
+		texts = append(texts, ev.Stderr)
+	}
+	for _, t := range texts {
+		if r.re != nil && !r.re.MatchString(t) {
+			continue
+		}
+		if r.contains != "" && !strings.Contains(strings.ToLower(t), r.contains) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Classify applies the rules to a failed outcome and falls back to the
+// built-in classification when none match.
+func (c *FailureClassifier) Classify(ev FailureEvidence, out runtime.Outcome) (class, rule string) {
+	if !isFailureLoopRestartOutcome(out) {
+		return "", ""
+	}
+	if ev.Reason == "" {
+		ev.Reason = out.FailureReason
+	}
+	if ev.ExitCode == nil {
+		ev.ExitCode = outcomeExitCode(out)
+	}
+	if cls, name := c.Match(ev); cls != "" {
+		return cls, name
+	}
+	return classifyFailureClass(out), ""
+}
+
+// LoadFailureClassificationFile reads a rules file:
+//
+//	rules:
+//	  - name: crates_registry
+//	    class: transient_infra
+//	    match: 'index\.crates\.io|download of config\.json failed'
+//	  - name: flaky_db
+//	    class: flaky_db            # custom; route with context.failure_class=flaky_db
+//	    contains: "deadlock detected"
+//	    in: [stderr]
+//	    nodes: [integration_tests]
+//	  - class: transient_infra
+//	    exit_codes: [137]
+func LoadFailureClassificationFile(path string) ([]FailureClassificationRule, error) {
+	var doc FailureClassificationConfig
+	if err := LoadYAMLFile(path, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Rules, nil
+}
+
+// BuildFailureClassifier combines run config rules with the rules file named
+// by the graph's failure_classification attribute (resolved against
+// baseDir), run config rules first.
+func BuildFailureClassifier(cfg *RunConfigFile, graphRulesPath, baseDir string) (*FailureClassifier, error) {
+	c := &FailureClassifier{}
+	if cfg != nil {
+		if err := c.add("failure_classification", cfg.FailureClassification.Rules); err != nil {
+			return nil, err
+		}
+	}
+	if p := strings.TrimSpace(graphRulesPath); p != "" {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(baseDir, p)
+		}
+		rules, err := LoadFailureClassificationFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("graph %s: %w", failureClassificationGraphAttr, err)
+		}
+		if err := c.add(p, rules); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// initFailureClassifier resolves the run's classification rules. Called at
+// run start (after the worktree exists) and on resume.
+func (e *Engine) initFailureClassifier() error {
+	var graphRules string
+	if e.Graph != nil {
+		graphRules = e.Graph.Attrs[failureClassificationGraphAttr]
+	}
+	c, err := BuildFailureClassifier(e.RunConfig, graphRules, e.WorktreeDir)
+	if err != nil {
+		return err
+	}
+	e.failureClassifier = c
+	return nil
+}
+
+// classifyFailure is classifyFailureClass with the run's rules applied
+// first. Rules see the stage's stderr.log tail and exit code.
+func (e *Engine) classifyFailure(nodeID string, out runtime.Outcome) (class, rule string) {
+	if e == nil || e.failureClassifier.Len() == 0 {
+		return classifyFailureClass(out), ""
+	}
+	if !isFailureLoopRestartOutcome(out) {
+		return "", ""
+	}
+	stageDir := filepath.Join(e.LogsRoot, nodeID)
+	code := outcomeExitCode(out)
+	if code == nil {
+		code = stageExitCode(stageDir)
+	}
+	return e.failureClassifier.Classify(FailureEvidence{
+		NodeID:   nodeID,
+		Stderr:   readFileTail(filepath.Join(stageDir, toolStderrFileName), failureRuleStderrTailBytes),
+		ExitCode: code,
+	}, out)
+}
+
+// outcomeExitCode reads an exit_code a handler recorded in outcome meta.
+func outcomeExitCode(out runtime.Outcome) *int {
+	if out.Meta == nil {
+		return nil
+	}
+	switch v := out.Meta["exit_code"].(type) {
+	case int:
+		return &v
+	case int64:
+		n := int(v)
+		return &n
+	case float64:
+		n := int(v)
+		return &n
+	}
+	return nil
+}
+
+// stageExitCode reads the exit code from a stage's tool_timing.json.
+func stageExitCode(stageDir string) *int {
+	b, err := os.ReadFile(filepath.Join(stageDir, toolTimingFileName))
+	if err != nil {
+		return nil
+	}
+	var timing struct {
+		ExitCode *int `json:"exit_code"`
+	}
+	if json.Unmarshal(b, &timing) != nil {
+		return nil
+	}
+	return timing.ExitCode
+}
+
+// readFileTail returns up to max bytes from the end of a file.
+func readFileTail(path string, max int64) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	off := info.Size() - max
+	if off < 0 {
+		off = 0
+	}
+	buf := make([]byte, info.Size()-off)
+	n, _ := f.ReadAt(buf, off)
+	return string(buf[:n])
+}
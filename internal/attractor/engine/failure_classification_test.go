@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+func TestFailureClassifier_RulesOrderAndFallback(t *testing.T) {
+	c, err := NewFailureClassifier("failure_classification", []FailureClassificationRule{
+		{Name: "crates", Class: "transient", Match: `index\.crates\.io`},
+		{Name: "oom", Class: "transient_infra", ExitCodes: []int{137}},
+		{Name: "flaky_db", Class: "flaky_db", Contains: "Deadlock Detected", In: []string{"stderr"}, Nodes: []string{"itest"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := func(reason string) runtime.Outcome {
+		return runtime.Outcome{Status: runtime.StatusFail, FailureReason: reason}
+	}
+	code := 137
+	cases := []struct {
+		name      string
+		ev        FailureEvidence
+		out       runtime.Outcome
+		wantClass string
+		wantRule  string
+	}{
+		{"regex on reason, alias canonicalized", FailureEvidence{NodeID: "build"}, fail("fetch https://index.crates.io failed"), failureClassTransientInfra, "crates"},
+		{"exit code", FailureEvidence{NodeID: "build", ExitCode: &code}, fail("killed"), failureClassTransientInfra, "oom"},
+		{"custom class from stderr", FailureEvidence{NodeID: "itest", Stderr: "ERROR: deadlock detected"}, fail("exit 1"), "flaky_db", "flaky_db"},
+		{"node filter", FailureEvidence{NodeID: "other", Stderr: "ERROR: deadlock detected"}, fail("exit 1"), failureClassDeterministic, ""},
+		{"stderr-only rule ignores reason", FailureEvidence{NodeID: "itest"}, fail("deadlock detected"), failureClassDeterministic, ""},
+		{"built-in fallback", FailureEvidence{NodeID: "build"}, fail("connection refused"), failureClassTransientInfra, ""},
+		{"rules override handler hint", FailureEvidence{NodeID: "build"}, runtime.Outcome{Status: runtime.StatusFail, FailureReason: "index.crates.io", Meta: map[string]any{"failure_class": "deterministic"}}, failureClassTransientInfra, "crates"},
+		{"success is not classified", FailureEvidence{NodeID: "build"}, runtime.Outcome{Status: runtime.StatusSuccess, FailureReason: "index.crates.io"}, "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cls, rule := c.Classify(tc.ev, tc.out)
+			if cls != tc.wantClass || rule != tc.wantRule {
+				t.Fatalf("Classify = (%q, %q), want (%q, %q)", cls, rule, tc.wantClass, tc.wantRule)
+			}
+		})
+	}
+	// Custom classes behave as deterministic for retry and cycle policy.
+	if shouldRetryOutcome(fail("x"), "flaky_db") {
+		t.Fatal("custom class should not be retryable")
+	}
+}
+
+func TestFailureClassifier_ValidationErrors(t *testing.T) {
+	cases := []struct {
+		rule FailureClassificationRule
+		want string
+	}{
+		{FailureClassificationRule{Match: "x"}, "class is required"},
+		{FailureClassificationRule{Class: "Bad Class", Match: "x"}, "invalid class"},
+		{FailureClassificationRule{Class: "transient_infra"}, "needs match, contains or exit_codes"},
+		{FailureClassificationRule{Class: "transient_infra", Match: "("}, "invalid match"},
+		{FailureClassificationRule{Class: "transient_infra", Match: "x", In: []string{"stdout"}}, "invalid in"},
+	}
+	for _, tc := range cases {
+		_, err := NewFailureClassifier("failure_classification", []FailureClassificationRule{tc.rule})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("rule %+v: err=%v, want %q", tc.rule, err, tc.want)
+		}
+	}
+
+	cfgPath := filepath.Join(t.TempDir(), "run.yaml")
+	_ = os.WriteFile(cfgPath, []byte(`version: 1
+repo:
+  path: /tmp/repo
+failure_classification:
+  rules:
+    - class: transient_infra
+      match: "("
+`), 0o644)
+	if _, err := LoadRunConfigFile(cfgPath); err == nil || !strings.Contains(err.Error(), "invalid match") {
+		t.Fatalf("LoadRunConfigFile err=%v, want invalid match", err)
+	}
+}
+
+func TestRun_GraphFailureClassification_RoutesOnCustomClassAndReplays(t *testing.T) {
+	repo := t.TempDir()
+	runCmd(t, repo, "git", "init")
+	runCmd(t, repo, "git", "config", "user.name", "tester")
+	runCmd(t, repo, "git", "config", "user.email", "tester@example.com")
+	_ = os.WriteFile(filepath.Join(repo, "failure_rules.yaml"), []byte(`rules:
+  - name: flaky_db
+    class: flaky_db
+    contains: "deadlock detected"
+    in: [stderr]
+`), 0o644)
+	runCmd(t, repo, "git", "add", "-A")
+	runCmd(t, repo, "git", "commit", "-m", "init")
+
+	dot := []byte(`
+digraph G {
+  graph [goal="test", failure_classification="failure_rules.yaml"]
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+
+  itest [shape=parallelogram, max_retries=0, tool_command="echo 'ERROR: deadlock detected' >&2; exit 1"]
+  reset_db [shape=parallelogram, tool_command="echo reset > reset.txt"]
+  give_up [shape=parallelogram, tool_command="echo done > give_up.txt"]
+
+  start -> itest
+  itest -> reset_db [condition="context.failure_class=flaky_db"]
+  itest -> exit [condition="outcome=success"]
+  itest -> give_up
+  reset_db -> exit [condition="outcome=success"]
+  give_up -> exit [condition="outcome=success"]
+}
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	res, err := runForTest(t, ctx, dot, RunOptions{RepoPath: repo, RunID: "failure-rules", LogsRoot: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(res.LogsRoot, "reset_db", "status.json")); err != nil {
+		t.Fatalf("expected reset_db to run after flaky_db classification: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(res.LogsRoot, "give_up")); err == nil {
+		t.Fatal("give_up should not run when the custom class edge matches")
+	}
+
+	results, err := ReclassifyRunFailures(res.LogsRoot, &FailureClassifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 failed attempt, got %+v", results)
+	}
+	r := results[0]
+	if r.NodeID != "itest" || r.RecordedClass != "flaky_db" || r.RecordedRule != "flaky_db" {
+		t.Fatalf("unexpected recorded classification: %+v", r)
+	}
+	// Without the rule the failure falls back to the built-in class.
+	if r.Class != failureClassDeterministic || r.Rule != "" || !r.Changed {
+		t.Fatalf("unexpected replay without rules: %+v", r)
+	}
+
+	c, err := BuildFailureClassifier(nil, "failure_rules.yaml", repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withRules, err := ReclassifyRunFailures(res.LogsRoot, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withRules[0].Class != "flaky_db" || withRules[0].Changed {
+		t.Fatalf("unexpected replay with rules: %+v", withRules[0])
+	}
+}
+
+func TestReclassifyRunFailures_OldRunsWithoutRecordedClass(t *testing.T) {
+	logsRoot := t.TempDir()
+	progress := `{"event":"stage_attempt_end","node_id":"a","attempt":1,"status":"fail","failure_reason":"boom"}
+{"event":"stage_attempt_end","node_id":"b","attempt":1,"status":"fail","failure_reason":"boom"}
+`
+	if err := os.WriteFile(filepath.Join(logsRoot, "progress.ndjson"), []byte(progress), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(logsRoot, "a"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logsRoot, "a", "status.json"), []byte(`{"status":"fail","failure_reason":"boom","failure_class":"transient_infra"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ReclassifyRunFailures(logsRoot, &FailureClassifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results = %+v", results)
+	}
+	if r := results[0]; r.RecordedClass != failureClassTransientInfra || r.Class != failureClassTransientInfra || r.Changed {
+		t.Fatalf("status.json class not used: %+v", r)
+	}
+	if r := results[1]; r.RecordedClass != recordedClassUnknown || r.Changed {
+		t.Fatalf("missing class should be unknown and unchanged: %+v", r)
+	}
+}
@@ -143,7 +143,7 @@ func (e *Engine) buildFailureDossier(node *model.Node, out runtime.Outcome, fail
 		failedNode = strings.TrimSpace(node.ID)
 		t := strings.TrimSpace(node.TypeOverride())
 		if t == "" {
-			t = shapeToType(node.Shape())
+			t = model.ShapeType(node.Shape())
 		}
 		handlerType = t
 		stageDir = filepath.Join(e.LogsRoot, node.ID)
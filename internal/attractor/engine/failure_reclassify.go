@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/dot"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+// FailureReclassification is one historical failed attempt classified under
+// the current rules next to the class it was given when it ran.
+type FailureReclassification struct {
+	NodeID        string `json:"node_id"`
+	Attempt       int    `json:"attempt"`
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason"`
+	RecordedClass string `json:"recorded_class"`
+	RecordedRule  string `json:"recorded_rule,omitempty"`
+	Class         string `json:"class"`
+	Rule          string `json:"rule,omitempty"`
+	Changed       bool   `json:"changed"`
+}
+
+// LoadRunFailureClassifier builds the classifier to replay a run with: the
+// failure_classification rules of configPath (the run's own run_config.json
+// when empty) plus the rules file named by the run's graph.dot, resolved
+// against the config's repo path.
+func LoadRunFailureClassifier(logsRoot, configPath string) (*FailureClassifier, error) {
+	var cfg *RunConfigFile
+	if strings.TrimSpace(configPath) == "" {
+		snap := filepath.Join(logsRoot, "run_config.json")
+		if _, err := os.Stat(snap); err == nil {
+			configPath = snap
+		}
+	}
+	if configPath != "" {
+		loaded, err := LoadRunConfigFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("load config %s: %w", configPath, err)
+		}
+		cfg = loaded
+	}
+	var graphRules, baseDir string
+	if b, err := os.ReadFile(filepath.Join(logsRoot, "graph.dot")); err == nil {
+		if g, err := dot.Parse(b); err == nil {
+			graphRules = g.Attrs[failureClassificationGraphAttr]
+		}
+	}
+	if cfg != nil {
+		baseDir = cfg.Repo.Path
+	}
+	return BuildFailureClassifier(cfg, graphRules, baseDir)
+}
+
+// ReclassifyRunFailures replays every failed stage attempt recorded in a
+// run's progress.ndjson through c. Rules see the attempt's archived
+// stderr.log and exit code when the stage directory still has them.
+func ReclassifyRunFailures(logsRoot string, c *FailureClassifier) ([]FailureReclassification, error) {
+	f, err := os.Open(filepath.Join(logsRoot, "progress.ndjson"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []FailureReclassification
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var ev map[string]any
+		if json.Unmarshal(sc.Bytes(), &ev) != nil {
+			continue
+		}
+		if eventFieldString(ev, "event") != "stage_attempt_end" {
+			continue
+		}
+		nodeID := eventFieldString(ev, "node_id")
+		outcome := runtime.Outcome{
+			Status:        runtime.StageStatus(eventFieldString(ev, "status")),
+			FailureReason: eventFieldString(ev, "failure_reason"),
+		}
+		if !isFailureLoopRestartOutcome(outcome) {
+			continue
+		}
+		r := FailureReclassification{
+			NodeID:        nodeID,
+			Attempt:       parseInt(fmt.Sprint(ev["attempt"]), 1),
+			Status:        string(outcome.Status),
+			FailureReason: outcome.FailureReason,
+			RecordedClass: eventFieldString(ev, "failure_class"),
+			RecordedRule:  eventFieldString(ev, "failure_rule"),
+		}
+		dir := filepath.Join(logsRoot, nodeID)
+		if attemptDir := filepath.Join(dir, fmt.Sprintf("attempt_%d", r.Attempt)); dirExists(attemptDir) {
+			dir = attemptDir
+		}
+		// Runs from before failure_class was logged with the event still
+		// have it in the stage's status.json.
+		if r.RecordedClass == "" {
+			r.RecordedClass = statusFileFailureClass(dir)
+		}
+		// A class a handler reported is still the fallback; one that an
+		// earlier rule produced is not, so removed rules stop applying.
+		if r.RecordedRule == "" && r.RecordedClass != "" {
+			outcome.Meta = map[string]any{"failure_class": r.RecordedClass}
+		}
+		r.Class, r.Rule = c.Classify(FailureEvidence{
+			NodeID:   nodeID,
+			Stderr:   readFileTail(filepath.Join(dir, toolStderrFileName), failureRuleStderrTailBytes),
+			ExitCode: stageExitCode(dir),
+		}, outcome)
+		if r.RecordedClass == "" {
+			// Nothing to compare against; do not count it as changed.
+			r.RecordedClass = recordedClassUnknown
+		} else {
+			r.Changed = r.Class != r.RecordedClass || r.Rule != r.RecordedRule
+		}
+		out = append(out, r)
+	}
+	return out, sc.Err()
+}
+
+// recordedClassUnknown is reported as RecordedClass for attempts whose
+// original class was never recorded.
+const recordedClassUnknown = "unknown"
+
+// statusFileFailureClass returns the failure_class in dir/status.json, or ""
+// when there is none.
+func statusFileFailureClass(dir string) string {
+	b, err := os.ReadFile(filepath.Join(dir, "status.json"))
+	if err != nil {
+		return ""
+	}
+	out, err := runtime.DecodeOutcomeJSON(b)
+	if err != nil {
+		return ""
+	}
+	return readFailureClassHint(out)
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
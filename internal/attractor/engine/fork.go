@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+// ForkOptions configures Fork.
+type ForkOptions struct {
+	// ParentLogsRoot is the logs root of the run being forked.
+	ParentLogsRoot string
+	// NodeID is the completed node to fork at. The child run starts from that
+	// node's checkpoint and continues routing from its recorded outcome.
+	NodeID string
+
+	// DotSource replaces the parent's graph when set. It must still contain
+	// NodeID.
+	DotSource []byte
+	// Config replaces the parent's snapshotted run config when set.
+	Config *RunConfigFile
+
+	RunID      string // defaults to a new run ID
+	LogsRoot   string // defaults to the standard location for RunID
+	GitOps     GitOps
+	RunDB      RunDBWriter
+	Invocation []string
+}
+
+// forkOrigin tells resumeFromLogsRoot that its logs root is a new fork.
+type forkOrigin struct {
+	ParentRunID string
+	NodeID      string
+	CXDBTurnID  string
+	Invocation  []string
+}
+
+// Fork starts a new run from a completed node of a previous run. The child
+// gets its own run branch at the node's checkpoint commit, the context and
+// completed-node state recorded at that checkpoint, and (when the parent used
+// CXDB) a CXDB context forked at the node's turn. Stages before the fork point
+// are copied from the parent so routing and goal gates see their outcomes.
+func Fork(ctx context.Context, opts ForkOptions) (*Result, error) {
+	parentRoot := strings.TrimSpace(opts.ParentLogsRoot)
+	nodeID := strings.TrimSpace(opts.NodeID)
+	if parentRoot == "" || nodeID == "" {
+		return nil, fmt.Errorf("fork: parent logs root and node id are required")
+	}
+	parentRoot, err := filepath.Abs(parentRoot)
+	if err != nil {
+		return nil, err
+	}
+	cp, err := loadForkCheckpoint(parentRoot, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	parentManifestPath := filepath.Join(parentRoot, "manifest.json")
+	pm, err := loadManifest(parentManifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("fork: %w", err)
+	}
+
+	dotSource := opts.DotSource
+	if len(dotSource) == 0 {
+		if dotSource, err = os.ReadFile(filepath.Join(parentRoot, "graph.dot")); err != nil {
+			return nil, fmt.Errorf("fork: read parent graph: %w", err)
+		}
+	}
+	g, _, err := PrepareWithOptions(dotSource, PrepareOptions{ModelSelections: pm.ModelSelection})
+	if err != nil {
+		return nil, fmt.Errorf("fork: %w", err)
+	}
+	if g.Nodes[nodeID] == nil {
+		return nil, fmt.Errorf("fork: node %q is not in the graph", nodeID)
+	}
+
+	cfg := opts.Config
+	if cfg == nil {
+		cfgPath := strings.TrimSpace(pm.RunConfigPath)
+		if cfgPath == "" {
+			cfgPath = filepath.Join(parentRoot, "run_config.json")
+		}
+		if _, statErr := os.Stat(cfgPath); statErr == nil {
+			if cfg, err = LoadRunConfigFile(cfgPath); err != nil {
+				return nil, fmt.Errorf("fork: load parent run config: %w", err)
+			}
+		}
+	}
+
+	runID := strings.TrimSpace(opts.RunID)
+	if runID == "" {
+		if runID, err = NewRunID(); err != nil {
+			return nil, err
+		}
+	}
+	logsRoot := strings.TrimSpace(opts.LogsRoot)
+	if logsRoot == "" {
+		logsRoot = defaultLogsRoot(runID)
+	}
+	if logsRoot, err = filepath.Abs(logsRoot); err != nil {
+		return nil, err
+	}
+	if entries, readErr := os.ReadDir(logsRoot); readErr == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("fork: logs root %s already exists and is not empty", logsRoot)
+	}
+	if err := os.MkdirAll(logsRoot, 0o755); err != nil {
+		return nil, err
+	}
+
+	// Materialize a logs root that resume can pick up: graph, config, model
+	// catalog snapshot, the fork-point checkpoint and the completed stages.
+	if err := os.WriteFile(filepath.Join(logsRoot, "graph.dot"), dotSource, 0o644); err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		if err := writeJSON(filepath.Join(logsRoot, "run_config.json"), cfg); err != nil {
+			return nil, err
+		}
+	}
+	catalogPath := firstExistingPath(
+		strings.TrimSpace(pm.ModelDB.OpenRouterModelInfoPath),
+		filepath.Join(parentRoot, "modeldb", "openrouter_models.json"),
+	)
+	childCatalogPath := ""
+	if catalogPath != "" {
+		childCatalogPath = filepath.Join(logsRoot, "modeldb", "openrouter_models.json")
+		if err := os.MkdirAll(filepath.Dir(childCatalogPath), 0o755); err != nil {
+			return nil, err
+		}
+		if err := copyFileContents(catalogPath, childCatalogPath); err != nil {
+			return nil, fmt.Errorf("fork: copy model catalog: %w", err)
+		}
+	}
+	for _, id := range cp.CompletedNodes {
+		src := filepath.Join(parentRoot, id)
+		if st, statErr := os.Stat(src); statErr != nil || !st.IsDir() {
+			continue
+		}
+		if err := copyDirContents(src, filepath.Join(logsRoot, id)); err != nil {
+			return nil, fmt.Errorf("fork: copy stage %s: %w", id, err)
+		}
+	}
+
+	turnID := strings.TrimSpace(anyToStringValue(cp.Extra["cxdb_head_turn_id"]))
+	cp.Timestamp = time.Now().UTC()
+	cp.Extra["base_logs_root"] = logsRoot
+	cp.Extra["restart_count"] = 0
+	delete(cp.Extra, "restart_failure_signatures")
+	if err := cp.Save(filepath.Join(logsRoot, "checkpoint.json")); err != nil {
+		return nil, err
+	}
+
+	prefix := deriveRunBranchPrefix(pm, cfg)
+	if prefix == "" {
+		prefix = "attractor/run"
+	}
+	if err := writeForkManifest(parentManifestPath, logsRoot, forkManifestFields{
+		RunID:          runID,
+		RunBranch:      buildRunBranch(prefix, runID),
+		CatalogPath:    childCatalogPath,
+		ParentRunID:    pm.RunID,
+		ParentLogsRoot: parentRoot,
+		NodeID:         nodeID,
+		GitCommitSHA:   cp.GitCommitSHA,
+		KeepCXDB:       turnID != "",
+	}); err != nil {
+		return nil, err
+	}
+
+	gitOps := opts.GitOps
+	if gitOps == nil && AutoDetectGitOps != nil && strings.TrimSpace(pm.RepoPath) != "" {
+		gitOps = AutoDetectGitOps(pm.RepoPath)
+	}
+	return resumeFromLogsRoot(ctx, logsRoot, ResumeOverrides{
+		GitOps: gitOps,
+		RunDB:  opts.RunDB,
+		fork: &forkOrigin{
+			ParentRunID: pm.RunID,
+			NodeID:      nodeID,
+			CXDBTurnID:  turnID,
+			Invocation:  opts.Invocation,
+		},
+	})
+}
+
+// loadForkCheckpoint returns the checkpoint recorded when nodeID completed.
+// Runs record one per node; for older runs only the last node can be forked.
+func loadForkCheckpoint(logsRoot, nodeID string) (*runtime.Checkpoint, error) {
+	cp, err := runtime.LoadCheckpoint(filepath.Join(logsRoot, nodeID, "checkpoint.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		last, lastErr := runtime.LoadCheckpoint(filepath.Join(logsRoot, "checkpoint.json"))
+		if lastErr == nil && last.CurrentNode == nodeID {
+			cp, err = last, nil
+		} else {
+			return nil, fmt.Errorf("fork: no checkpoint for node %q in %s (has it completed?)", nodeID, logsRoot)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fork: load checkpoint for %q: %w", nodeID, err)
+	}
+	if strings.TrimSpace(cp.GitCommitSHA) == "" {
+		return nil, fmt.Errorf("fork: checkpoint for node %q has no git commit", nodeID)
+	}
+	if cp.Extra == nil {
+		cp.Extra = map[string]any{}
+	}
+	return cp, nil
+}
+
+type forkManifestFields struct {
+	RunID          string
+	RunBranch      string
+	CatalogPath    string
+	ParentRunID    string
+	ParentLogsRoot string
+	NodeID         string
+	GitCommitSHA   string
+	// KeepCXDB keeps the parent's CXDB endpoint and context so resume can
+	// fork it; without a fork-point turn the child runs without CXDB.
+	KeepCXDB bool
+}
+
+// writeForkManifest writes the child's manifest.json: the parent's manifest
+// with run identity and paths replaced, plus a forked_from record.
+func writeForkManifest(parentManifestPath, logsRoot string, f forkManifestFields) error {
+	b, err := os.ReadFile(parentManifestPath)
+	if err != nil {
+		return err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("fork: decode parent manifest: %w", err)
+	}
+	m["run_id"] = f.RunID
+	m["run_branch"] = f.RunBranch
+	m["logs_root"] = logsRoot
+	m["worktree"] = filepath.Join(logsRoot, "worktree")
+	m["graph_dot"] = filepath.Join(logsRoot, "graph.dot")
+	m["started_at"] = time.Now().UTC().Format(time.RFC3339Nano)
+	m["base_sha"] = f.GitCommitSHA
+	m["run_config_path"] = ""
+	if _, err := os.Stat(filepath.Join(logsRoot, "run_config.json")); err == nil {
+		m["run_config_path"] = filepath.Join(logsRoot, "run_config.json")
+	}
+	if mdb, ok := m["modeldb"].(map[string]any); ok {
+		mdb["openrouter_model_info_path"] = f.CatalogPath
+	}
+	if !f.KeepCXDB {
+		delete(m, "cxdb")
+	}
+	m["forked_from"] = map[string]any{
+		"run_id":         f.ParentRunID,
+		"logs_root":      f.ParentLogsRoot,
+		"node_id":        f.NodeID,
+		"git_commit_sha": f.GitCommitSHA,
+	}
+	return writeJSON(filepath.Join(logsRoot, "manifest.json"), m)
+}
+
+// updateManifestCXDB points a manifest at a (newly forked) CXDB context.
+func updateManifestCXDB(logsRoot, contextID, headTurnID string) error {
+	path := filepath.Join(logsRoot, "manifest.json")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	c, _ := m["cxdb"].(map[string]any)
+	if c == nil {
+		c = map[string]any{}
+	}
+	c["context_id"] = contextID
+	c["head_turn_id"] = headTurnID
+	m["cxdb"] = c
+	return writeJSON(path, m)
+}
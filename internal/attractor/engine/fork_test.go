@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+// TestFork_BranchesNewRunFromCompletedNode forks a finished run at a middle
+// node. The child must branch at that node's checkpoint commit, rerun only the
+// nodes after it, and be linked to the parent in the run database.
+func TestFork_BranchesNewRunFromCompletedNode(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	repo := initTestRepo(t)
+	pinned := writePinnedCatalog(t)
+	counter := filepath.Join(t.TempDir(), "executions.txt")
+
+	rdb, err := rundb.Open(filepath.Join(t.TempDir(), "runs.db"))
+	if err != nil {
+		t.Fatalf("Open rundb: %v", err)
+	}
+	defer rdb.Close()
+
+	dot := []byte(`digraph fork_test {
+  graph [goal="Exercise run forking"]
+  start [shape=Mdiamond]
+  build [shape=parallelogram, tool_command="echo build >> ` + counter + ` && echo built > build.txt"]
+  verify [shape=parallelogram, tool_command="echo verify >> ` + counter + ` && echo verified > verify.txt"]
+  gate [shape=diamond]
+  done [shape=Msquare]
+  start -> build -> verify -> gate
+  gate -> done
+}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	parentLogs := t.TempDir()
+	parent, err := RunWithConfig(ctx, dot, minimalToolGraphConfig(repo, pinned), RunOptions{
+		RunID:       "fork-parent",
+		LogsRoot:    parentLogs,
+		DisableCXDB: true,
+		RunDB:       rdb,
+	})
+	if err != nil {
+		t.Fatalf("parent RunWithConfig: %v", err)
+	}
+	if parent.FinalStatus != runtime.FinalSuccess {
+		t.Fatalf("parent: expected success, got %q", parent.FinalStatus)
+	}
+	buildCP, err := runtime.LoadCheckpoint(filepath.Join(parentLogs, "build", "checkpoint.json"))
+	if err != nil {
+		t.Fatalf("per-node checkpoint for build: %v", err)
+	}
+
+	childLogs := filepath.Join(t.TempDir(), "child")
+	child, err := Fork(ctx, ForkOptions{
+		ParentLogsRoot: parentLogs,
+		NodeID:         "build",
+		RunID:          "fork-child",
+		LogsRoot:       childLogs,
+		RunDB:          rdb,
+	})
+	if err != nil {
+		t.Fatalf("Fork: %v", err)
+	}
+	if child.FinalStatus != runtime.FinalSuccess {
+		t.Fatalf("child: expected success, got %q", child.FinalStatus)
+	}
+	if child.RunBranch == parent.RunBranch || !strings.HasSuffix(child.RunBranch, "fork-child") {
+		t.Fatalf("child run branch = %q (parent %q)", child.RunBranch, parent.RunBranch)
+	}
+
+	// The child branch descends from build's checkpoint, not from the parent's tip.
+	base := strings.TrimSpace(runCmdOut(t, repo, "git", "merge-base", child.RunBranch, parent.RunBranch))
+	if base != buildCP.GitCommitSHA {
+		t.Fatalf("merge-base = %s, want build checkpoint %s", base, buildCP.GitCommitSHA)
+	}
+
+	b, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(b)); strings.Join(got, ",") != "build,verify,verify" {
+		t.Fatalf("executions = %v, want build once and verify twice", got)
+	}
+	if _, err := os.Stat(filepath.Join(childLogs, "build", "status.json")); err != nil {
+		t.Fatalf("expected parent stage copied into child logs: %v", err)
+	}
+
+	run, err := rdb.GetRun("fork-child")
+	if err != nil {
+		t.Fatalf("GetRun child: %v", err)
+	}
+	if run.ParentRunID != "fork-parent" || run.ForkNodeID != "build" {
+		t.Fatalf("fork link = (%q, %q), want (fork-parent, build)", run.ParentRunID, run.ForkNodeID)
+	}
+}
+
+func TestFork_RejectsNodeWithoutCheckpoint(t *testing.T) {
+	logs := t.TempDir()
+	if err := os.WriteFile(filepath.Join(logs, "manifest.json"), []byte(`{"run_id":"r1"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Fork(context.Background(), ForkOptions{ParentLogsRoot: logs, NodeID: "build"})
+	if err == nil || !strings.Contains(err.Error(), "no checkpoint") {
+		t.Fatalf("expected missing checkpoint error, got %v", err)
+	}
+}
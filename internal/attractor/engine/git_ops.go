@@ -64,4 +64,13 @@ type GitOps interface {
 	// DiffStat returns the number of files changed, insertions, and deletions
 	// between two commits. Used for recording per-node diff statistics.
 	DiffStat(dir, fromSHA, toSHA string) (filesChanged, insertions, deletions int, err error)
+
+	// TreeSHA returns the content (tree) identifier of HEAD in a directory.
+	// Unlike HeadSHA it is equal across runs whose workspaces hold the same
+	// files, which makes it usable as a node cache key input.
+	TreeSHA(dir string) (string, error)
+
+	// RestoreTree makes the workspace content match the given commit without
+	// moving HEAD, so the restored files can be checkpointed on top.
+	RestoreTree(worktreeDir, sha string) error
 }
@@ -105,3 +105,11 @@ func (g *testGitOps) RemoveWorktree(repoPath, worktreeDir string) error {
 func (g *testGitOps) DiffStat(dir, fromSHA, toSHA string) (int, int, int, error) {
 	return gitutil.DiffStat(dir, fromSHA, toSHA)
 }
+
+func (g *testGitOps) TreeSHA(dir string) (string, error) {
+	return gitutil.TreeSHA(dir, "HEAD")
+}
+
+func (g *testGitOps) RestoreTree(worktreeDir, sha string) error {
+	return gitutil.ReadTree(worktreeDir, sha)
+}
@@ -131,46 +131,13 @@ func (r *HandlerRegistry) Resolve(n *model.Node) Handler {
 			return h
 		}
 	}
-	handlerType := shapeToType(n.Shape())
+	handlerType := model.ShapeType(n.Shape())
 	if h, ok := r.handlers[handlerType]; ok {
 		return h
 	}
 	return r.defaultHandler
 }
 
-func shapeToType(shape string) string {
-	switch shape {
-	case "Mdiamond", "circle":
-		return "start"
-	case "Msquare", "doublecircle":
-		return "exit"
-	case "box":
-		return "agent"
-	case "hexagon":
-		return "wait.human"
-	case "diamond":
-		return "conditional"
-	case "component":
-		return "parallel"
-	case "tripleoctagon":
-		return "parallel.fan_in"
-	case "parallelogram":
-		return "tool"
-	case "house":
-		return "stack.manager_loop"
-	case "trapezium":
-		return "loop.begin"
-	case "invtrapezium":
-		return "loop.end"
-	case "pentagon":
-		return "concurrent.split"
-	case "cylinder":
-		return "concurrent.join"
-	default:
-		return "agent"
-	}
-}
-
 type StartHandler struct{}
 
 func (h *StartHandler) Execute(ctx context.Context, exec *Execution, node *model.Node) (runtime.Outcome, error) {
@@ -594,6 +561,13 @@ func (h *CodergenHandler) Execute(ctx context.Context, exec *Execution, node *mo
 			}
 		}
 	}
+	if preamble := strings.TrimSpace(BuildAgentPlanPromptPreamble(exec, node)); preamble != "" {
+		if strings.TrimSpace(promptText) == "" {
+			promptText = preamble
+		} else {
+			promptText = preamble + "\n\n" + strings.TrimSpace(promptText)
+		}
+	}
 	if preamble := strings.TrimSpace(BuildManualBoxFanInPromptPreamble(exec, node)); preamble != "" {
 		if strings.TrimSpace(promptText) == "" {
 			promptText = preamble
@@ -883,12 +857,16 @@ var toolActionableLineHints = []string{
 
 func (h *ToolHandler) Execute(ctx context.Context, execCtx *Execution, node *model.Node) (runtime.Outcome, error) {
 	stageDir := filepath.Join(execCtx.LogsRoot, node.ID)
-	cmdStr := strings.TrimSpace(node.Attr("tool_command", ""))
+	cmdStr, testMode := toolNodeCommand(node)
 	if cmdStr == "" {
-		return runtime.Outcome{Status: runtime.StatusFail, FailureReason: "no tool_command specified"}, nil
+		return runtime.Outcome{Status: runtime.StatusFail, FailureReason: "no tool_command or test_command specified"}, nil
 	}
 	if toolCommandAbsPathRE.MatchString(cmdStr) {
-		WarnEngine(execCtx, fmt.Sprintf("tool_command for node %q contains 'cd /…' which overrides worktree CWD %q", node.ID, execCtx.WorktreeDir))
+		attr := "tool_command"
+		if testMode {
+			attr = "test_command"
+		}
+		WarnEngine(execCtx, fmt.Sprintf("%s for node %q contains 'cd /…' which overrides worktree CWD %q", attr, node.ID, execCtx.WorktreeDir))
 	}
 	timeout := parseDuration(node.Attr("timeout", ""), 0)
 	if timeout <= 0 {
@@ -1032,9 +1010,20 @@ func (h *ToolHandler) Execute(ctx context.Context, execCtx *Execution, node *mod
 
 	combined := append(append([]byte{}, stdoutBytes...), stderrBytes...)
 	combinedStr := string(combined)
-	if runErr != nil {
-		rawExitStatus := strings.TrimSpace(runErr.Error())
+	var tests testCommandResults
+	if testMode {
+		tests = collectTestResults(execCtx, node, stageDir, stdoutBytes, stderrBytes, exitCode, commandStart)
+	}
+	if runErr != nil || tests.failed() {
+		// A runner can report failures yet exit 0 (e.g. some TAP harnesses).
+		rawExitStatus := fmt.Sprintf("exit status %d", exitCode)
+		if runErr != nil {
+			rawExitStatus = strings.TrimSpace(runErr.Error())
+		}
 		failureReason := rawExitStatus
+		if tests.failed() {
+			failureReason = tests.failureReason()
+		}
 		if isBrowserVerifyNode {
 			if line := firstActionableToolOutputLine(stderrBytes); line != "" {
 				failureReason = line
@@ -1060,13 +1049,19 @@ func (h *ToolHandler) Execute(ctx context.Context, execCtx *Execution, node *mod
 				execCtx.Engine.Warn(fmt.Sprintf("cxdb append ToolResult failed (node=%s call_id=%s): %v", node.ID, callID, err))
 			}
 		}
+		updates := map[string]any{
+			"tool.output":      Truncate(combinedStr, 8_000),
+			"tool.exit_status": rawExitStatus,
+		}
+		if testMode {
+			for k, v := range tests.contextUpdates() {
+				updates[k] = v
+			}
+		}
 		return runtime.Outcome{
-			Status:        runtime.StatusFail,
-			FailureReason: failureReason,
-			ContextUpdates: map[string]any{
-				"tool.output":      Truncate(combinedStr, 8_000),
-				"tool.exit_status": rawExitStatus,
-			},
+			Status:         runtime.StatusFail,
+			FailureReason:  failureReason,
+			ContextUpdates: updates,
 		}, nil
 	}
 	if execCtx != nil && execCtx.Engine != nil && execCtx.Engine.CXDB != nil {
@@ -1081,12 +1076,18 @@ func (h *ToolHandler) Execute(ctx context.Context, execCtx *Execution, node *mod
 			execCtx.Engine.Warn(fmt.Sprintf("cxdb append ToolResult failed (node=%s call_id=%s): %v", node.ID, callID, err))
 		}
 	}
+	updates := map[string]any{
+		"tool.output": Truncate(combinedStr, 8_000),
+	}
+	if testMode {
+		for k, v := range tests.contextUpdates() {
+			updates[k] = v
+		}
+	}
 	return runtime.Outcome{
-		Status: runtime.StatusSuccess,
-		ContextUpdates: map[string]any{
-			"tool.output": Truncate(combinedStr, 8_000),
-		},
-		Notes: "tool completed",
+		Status:         runtime.StatusSuccess,
+		ContextUpdates: updates,
+		Notes:          "tool completed",
 	}, nil
 }
 
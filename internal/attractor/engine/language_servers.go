@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/agent"
+)
+
+// LanguageServerConfig is one entry of run.yaml's language_servers map,
+// keyed by language. API agent_loop sessions get LSP tools backed by these
+// servers.
+//
+//	language_servers:
+//	  go:
+//	    command: [gopls]
+//	  rust:
+//	    command: [rust-analyzer]
+//	  typescript:
+//	    command: [typescript-language-server, --stdio]
+type LanguageServerConfig struct {
+	Command               []string       `json:"command" yaml:"command"`
+	Extensions            []string       `json:"extensions,omitempty" yaml:"extensions,omitempty"`
+	LanguageID            string         `json:"language_id,omitempty" yaml:"language_id,omitempty"`
+	InitializationOptions map[string]any `json:"initialization_options,omitempty" yaml:"initialization_options,omitempty"`
+	RequestTimeoutMS      int            `json:"request_timeout_ms,omitempty" yaml:"request_timeout_ms,omitempty"`
+	DiagnosticsWaitMS     int            `json:"diagnostics_wait_ms,omitempty" yaml:"diagnostics_wait_ms,omitempty"`
+}
+
+// defaultLanguageServerExtensions covers languages whose file suffixes can
+// be assumed when extensions is omitted.
+var defaultLanguageServerExtensions = map[string][]string{
+	"go":         {".go"},
+	"rust":       {".rs"},
+	"typescript": {".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"},
+	"javascript": {".js", ".jsx", ".mjs", ".cjs"},
+	"python":     {".py"},
+	"c":          {".c", ".h"},
+	"cpp":        {".cc", ".cpp", ".cxx", ".hpp", ".hh", ".h"},
+	"java":       {".java"},
+	"ruby":       {".rb"},
+}
+
+func validateLanguageServers(servers map[string]LanguageServerConfig) error {
+	for lang, ls := range servers {
+		if strings.TrimSpace(lang) == "" {
+			return fmt.Errorf("language_servers: language name is empty")
+		}
+		if len(trimNonEmpty(ls.Command)) == 0 {
+			return fmt.Errorf("language_servers.%s.command is required", lang)
+		}
+		if len(ls.Extensions) == 0 {
+			if _, ok := defaultLanguageServerExtensions[strings.ToLower(lang)]; !ok {
+				return fmt.Errorf("language_servers.%s.extensions is required (no defaults for %q)", lang, lang)
+			}
+		}
+		for _, ext := range ls.Extensions {
+			if !strings.HasPrefix(strings.TrimSpace(ext), ".") {
+				return fmt.Errorf("language_servers.%s.extensions: %q must start with '.'", lang, ext)
+			}
+		}
+		if ls.RequestTimeoutMS < 0 || ls.DiagnosticsWaitMS < 0 {
+			return fmt.Errorf("language_servers.%s: timeouts must be >= 0", lang)
+		}
+	}
+	return nil
+}
+
+// agentLanguageServers converts the run config's language servers for an
+// agent session, in a stable order so extension routing is deterministic.
+func agentLanguageServers(cfg *RunConfigFile) []agent.LanguageServerConfig {
+	if cfg == nil || len(cfg.LanguageServers) == 0 {
+		return nil
+	}
+	langs := make([]string, 0, len(cfg.LanguageServers))
+	for lang := range cfg.LanguageServers {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	out := make([]agent.LanguageServerConfig, 0, len(langs))
+	for _, lang := range langs {
+		ls := cfg.LanguageServers[lang]
+		exts := trimNonEmpty(ls.Extensions)
+		if len(exts) == 0 {
+			exts = defaultLanguageServerExtensions[strings.ToLower(lang)]
+		}
+		out = append(out, agent.LanguageServerConfig{
+			Language:              lang,
+			Command:               trimNonEmpty(ls.Command),
+			Extensions:            exts,
+			LanguageID:            strings.TrimSpace(ls.LanguageID),
+			InitializationOptions: ls.InitializationOptions,
+			RequestTimeout:        time.Duration(ls.RequestTimeoutMS) * time.Millisecond,
+			DiagnosticsWait:       time.Duration(ls.DiagnosticsWaitMS) * time.Millisecond,
+		})
+	}
+	return out
+}
@@ -138,7 +138,7 @@ func (e *Engine) handleLoopIteration(node *model.Node, out runtime.Outcome) (boo
 	if node == nil {
 		return false, "", ""
 	}
-	handlerType := shapeToType(node.Shape())
+	handlerType := model.ShapeType(node.Shape())
 
 	var spec *LoopSpec
 	var jumpTo string
@@ -236,7 +236,7 @@ func findLoopBeginForEnd(g *model.Graph, endNode *model.Node) *model.Node {
 		if n == nil {
 			continue
 		}
-		if shapeToType(n.Shape()) != "loop.begin" {
+		if model.ShapeType(n.Shape()) != "loop.begin" {
 			continue
 		}
 		nLoopID := strings.TrimSpace(n.Attr("loop_id", ""))
@@ -162,20 +162,31 @@ func normalizedFailureClass(raw string) string {
 	switch strings.ToLower(strings.TrimSpace(raw)) {
 	case "", "<nil>":
 		return ""
+	}
+	if cls, ok := canonicalFailureClass(raw); ok {
+		return cls
+	}
+	return failureClassDeterministic
+}
+
+// canonicalFailureClass maps a built-in failure class or one of its aliases
+// to its canonical name; ok is false for anything else.
+func canonicalFailureClass(raw string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
 	case "transient", "transient_infra", "transient-infra", "infra_transient", "transient infra", "infrastructure_transient", "retryable", "toolchain_workspace_io", "toolchain-workspace-io", "toolchain_or_dependency_registry_unavailable", "toolchain-dependency-registry-unavailable":
-		return failureClassTransientInfra
+		return failureClassTransientInfra, true
 	case "canceled", "cancelled":
-		return failureClassCanceled
+		return failureClassCanceled, true
 	case "deterministic", "non_transient", "non-transient", "permanent", "logic", "product":
-		return failureClassDeterministic
+		return failureClassDeterministic, true
 	case "budget_exhausted", "budget-exhausted", "budget exhausted", "budget":
-		return failureClassBudgetExhausted
+		return failureClassBudgetExhausted, true
 	case "compilation_loop", "compilation-loop", "compilation loop", "compile_loop", "compile-loop":
-		return failureClassCompilationLoop
+		return failureClassCompilationLoop, true
 	case "structural", "structure", "scope_violation", "write_scope_violation":
-		return failureClassStructural
+		return failureClassStructural, true
 	default:
-		return failureClassDeterministic
+		return "", false
 	}
 }
 
@@ -21,8 +21,9 @@ type childResult struct {
 
 // Execute implements the ManagerLoopHandler per spec §4.11.
 // It runs an observe/wait loop that monitors a child pipeline and evaluates
-// stop conditions each cycle. The steer action is logged but not yet
-// implemented (deferred to v2).
+// stop conditions each cycle. With the steer action it also evaluates the
+// child's progress against steer rules and/or an LLM supervisor and
+// intervenes in the running child (see manager_steer.go).
 func (h *ManagerLoopHandler) Execute(ctx context.Context, exec *Execution, node *model.Node) (runtime.Outcome, error) {
 	if exec == nil || exec.Engine == nil || exec.Graph == nil {
 		return runtime.Outcome{Status: runtime.StatusFail, FailureReason: "manager loop missing execution context"}, nil
@@ -58,6 +59,24 @@ func (h *ManagerLoopHandler) Execute(ctx context.Context, exec *Execution, node
 		}, nil
 	}
 
+	var sup *childSupervisor
+	var steering *managerSteering
+	if actions["steer"] {
+		var err error
+		sup = newChildSupervisor()
+		steering, err = newManagerSteering(exec, node, sup)
+		if err != nil {
+			return runtime.Outcome{
+				Status:        runtime.StatusFail,
+				FailureReason: fmt.Sprintf("manager_loop steer configuration: %v", err),
+			}, nil
+		}
+		if steering == nil {
+			exec.Engine.Warn(fmt.Sprintf("manager_loop node %s: 'steer' action has no manager.steer_rules or manager.llm_model; not steering", node.ID))
+			sup = nil
+		}
+	}
+
 	var childCancel context.CancelFunc
 	childDone := make(chan childResult, 1)
 
@@ -65,7 +84,7 @@ func (h *ManagerLoopHandler) Execute(ctx context.Context, exec *Execution, node
 		var childCtx context.Context
 		childCtx, childCancel = context.WithCancel(ctx)
 		go func() {
-			result := runChildPipeline(childCtx, exec, childDotfile, node.ID)
+			result := runChildPipeline(childCtx, exec, childDotfile, node.ID, sup)
 			childDone <- result
 		}()
 	}
@@ -76,11 +95,6 @@ func (h *ManagerLoopHandler) Execute(ctx context.Context, exec *Execution, node
 		}
 	}()
 
-	// Log steer action as deferred to v2.
-	if actions["steer"] {
-		exec.Engine.Warn(fmt.Sprintf("manager_loop node %s: 'steer' action is not yet implemented (v2)", node.ID))
-	}
-
 	// Observation loop per spec §4.11 pseudocode.
 	for cycle := 1; cycle <= maxCycles; cycle++ {
 		if err := ctx.Err(); err != nil {
@@ -99,6 +113,9 @@ func (h *ManagerLoopHandler) Execute(ctx context.Context, exec *Execution, node
 			select {
 			case result := <-childDone:
 				childDone = nil // prevent re-reading from closed channel semantics
+				if steering != nil {
+					steering.reportDropped(exec)
+				}
 				if result.Outcome.Status == runtime.StatusSuccess || result.Outcome.Status == runtime.StatusPartialSuccess {
 					return runtime.Outcome{
 						Status: runtime.StatusSuccess,
@@ -122,6 +139,11 @@ func (h *ManagerLoopHandler) Execute(ctx context.Context, exec *Execution, node
 			}
 		}
 
+		// Steer: evaluate the running child and intervene if warranted.
+		if steering != nil && childDone != nil {
+			steering.cycle(ctx, exec, cycle)
+		}
+
 		// Evaluate stop condition (spec §4.11 pseudocode line: IF stop_condition is not empty).
 		if stopCondition != "" {
 			ok, err := cond.Evaluate(stopCondition, runtime.Outcome{Status: runtime.StatusSuccess}, exec.Context)
@@ -181,7 +203,7 @@ func parseManagerActions(s string) map[string]bool {
 // runChildPipeline loads and executes a child DOT pipeline, returning the result.
 // This reuses the sub-pipeline execution infrastructure (Prepare, runSubgraphUntil)
 // already built for parallel branches.
-func runChildPipeline(ctx context.Context, exec *Execution, childDotfile string, managerNodeID string) childResult {
+func runChildPipeline(ctx context.Context, exec *Execution, childDotfile string, managerNodeID string, sup *childSupervisor) childResult {
 	// Resolve child dotfile path relative to the active run worktree (not the
 	// source repo). Earlier stages may generate or modify child dotfiles in the
 	// worktree, so reading from Options.RepoPath would see stale/missing content.
@@ -246,6 +268,23 @@ func runChildPipeline(ctx context.Context, exec *Execution, childDotfile string,
 		ModelCatalogSource: exec.Engine.ModelCatalogSource,
 		ModelCatalogPath:   exec.Engine.ModelCatalogPath,
 	}
+	// Steering records interventions in the child's CXDB context and run
+	// log; without it the child keeps its previous, sink-less setup.
+	if sup != nil {
+		if exec.Engine.CXDB != nil {
+			fork, err := exec.Engine.CXDB.ForkFromHead(ctx)
+			if err != nil {
+				exec.Engine.Warn(fmt.Sprintf("manager_loop node %s: fork cxdb context for child: %v", managerNodeID, err))
+			} else {
+				childEng.CXDB = fork
+			}
+		}
+		if rl, err := NewRunLog(childLogsRoot, exec.Engine.Options.RunID); err == nil {
+			childEng.RunLog = rl
+			defer rl.Close()
+		}
+		sup.bind(childEng)
+	}
 
 	res, err := runSubgraphUntil(ctx, childEng, startID, exitID)
 	if err != nil {
@@ -0,0 +1,689 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/cond"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+	"github.com/danshapiro/kilroy/internal/llm"
+)
+
+// steerDelivery says what happened to a steering message.
+type steerDelivery string
+
+const (
+	steerDelivered    steerDelivery = "delivered"
+	steerQueued       steerDelivery = "queued"
+	steerNotDelivered steerDelivery = "not_delivered"
+)
+
+// steerMessage is a message waiting for the session of the stage it was sent
+// to. An empty nodeID means the next stage to start.
+type steerMessage struct {
+	nodeID string
+	text   string
+}
+
+// steeringHub is the channel a supervising manager loop uses to reach into a
+// running child pipeline. It follows which child stage is running; stages
+// whose session can take input (API agent_loop, interactive tmux) attach a
+// deliverer while they run. The subgraph loop consults it for a pending
+// reroute after each stage.
+type steeringHub struct {
+	mu      sync.Mutex
+	nodeID  string
+	deliver func(string) error
+	noInput bool
+	pending []steerMessage
+	dropped []steerMessage
+	reroute string
+}
+
+// stageStarted records that nodeID is running. Messages queued between
+// stages are for it.
+func (h *steeringHub) stageStarted(nodeID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nodeID, h.deliver, h.noInput = nodeID, nil, false
+	for i := range h.pending {
+		if h.pending[i].nodeID == "" {
+			h.pending[i].nodeID = nodeID
+		}
+	}
+}
+
+// stageFinished drops the messages still queued for nodeID; the stage they
+// were meant for is over.
+func (h *steeringHub) stageFinished(nodeID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keep := h.pending[:0]
+	for _, m := range h.pending {
+		if m.nodeID == nodeID {
+			h.dropped = append(h.dropped, m)
+		} else {
+			keep = append(keep, m)
+		}
+	}
+	h.pending = keep
+	if h.nodeID == nodeID {
+		h.nodeID, h.deliver, h.noInput = "", nil, false
+	}
+}
+
+// attachSession registers how to reach the session running nodeID and
+// delivers the messages queued for it. A nil deliver marks a stage whose
+// backend cannot take input: its queued messages are dropped and later
+// steers report not_delivered. The returned func detaches the session and
+// must be called when it ends.
+func (h *steeringHub) attachSession(nodeID string, deliver func(string) error) func() {
+	if h == nil {
+		return func() {}
+	}
+	h.mu.Lock()
+	h.nodeID, h.deliver, h.noInput = nodeID, deliver, deliver == nil
+	var flush []steerMessage
+	keep := h.pending[:0]
+	for _, m := range h.pending {
+		if m.nodeID == nodeID || m.nodeID == "" {
+			flush = append(flush, m)
+		} else {
+			keep = append(keep, m)
+		}
+	}
+	h.pending = keep
+	if deliver == nil {
+		h.dropped = append(h.dropped, flush...)
+		flush = nil
+	}
+	h.mu.Unlock()
+	for _, m := range flush {
+		if err := deliver(m.text); err != nil {
+			h.mu.Lock()
+			h.dropped = append(h.dropped, m)
+			h.mu.Unlock()
+		}
+	}
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.nodeID == nodeID {
+			h.deliver, h.noInput = nil, true
+		}
+	}
+}
+
+// steer hands msg to the running stage's session. It returns the stage the
+// message was for and whether it was delivered, queued until that stage's
+// session attaches (or, between stages, for the next stage), or could not
+// be delivered.
+func (h *steeringHub) steer(msg string) (string, steerDelivery, error) {
+	h.mu.Lock()
+	nodeID, deliver := h.nodeID, h.deliver
+	if deliver == nil {
+		defer h.mu.Unlock()
+		if h.noInput {
+			return nodeID, steerNotDelivered, nil
+		}
+		h.pending = append(h.pending, steerMessage{nodeID: nodeID, text: msg})
+		return nodeID, steerQueued, nil
+	}
+	h.mu.Unlock()
+	if err := deliver(msg); err != nil {
+		return nodeID, steerNotDelivered, err
+	}
+	return nodeID, steerDelivered, nil
+}
+
+// takeDropped returns and clears the queued messages whose stage ended, or
+// whose session could not take them.
+func (h *steeringHub) takeDropped() []steerMessage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := h.dropped
+	h.dropped = nil
+	return out
+}
+
+// AttachSteering lets a handler in another package take messages from a
+// supervising manager loop while the session running nodeID is open. A nil
+// deliver marks a session that cannot take input. The returned func must be
+// called when the session ends; both are no-ops when nothing steers the run.
+func (e *Engine) AttachSteering(nodeID string, deliver func(string) error) func() {
+	if e == nil {
+		return func() {}
+	}
+	return e.steering.attachSession(nodeID, deliver)
+}
+
+func (h *steeringHub) requestReroute(nodeID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reroute = nodeID
+}
+
+// takeReroute returns and clears the pending reroute target.
+func (h *steeringHub) takeReroute() string {
+	if h == nil {
+		return ""
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	target := h.reroute
+	h.reroute = ""
+	return target
+}
+
+// childObservation is what the manager knows about its child pipeline at the
+// start of a cycle. It is also the payload shown to an LLM supervisor.
+type childObservation struct {
+	ActiveNode        string   `json:"active_node,omitempty"`
+	Attempt           int      `json:"attempt,omitempty"`
+	LastNode          string   `json:"last_node,omitempty"`
+	LastStatus        string   `json:"last_status,omitempty"`
+	LastFailureReason string   `json:"last_failure_reason,omitempty"`
+	FailureClass      string   `json:"failure_class,omitempty"`
+	StagesFinished    int      `json:"stages_finished"`
+	Failures          int      `json:"failures"`
+	IdleSeconds       int64    `json:"idle_seconds"`
+	RecentEvents      []string `json:"recent_events,omitempty"`
+
+	idle time.Duration
+}
+
+// key identifies the child's position: rules and the LLM supervisor act at
+// most once per stage attempt, however many cycles it spans.
+func (o childObservation) key() string {
+	return fmt.Sprintf("%d|%s|%d", o.StagesFinished, o.ActiveNode, o.Attempt)
+}
+
+const childObservationRecentEvents = 10
+
+// childSupervisor follows a child engine's progress stream and applies
+// interventions to it.
+type childSupervisor struct {
+	hub *steeringHub
+
+	mu          sync.Mutex
+	eng         *Engine
+	obs         childObservation
+	lastEventAt time.Time
+}
+
+func newChildSupervisor() *childSupervisor {
+	return &childSupervisor{hub: &steeringHub{}}
+}
+
+// bind attaches the supervisor to a child engine before it starts running.
+func (s *childSupervisor) bind(eng *Engine) {
+	s.mu.Lock()
+	s.eng = eng
+	s.lastEventAt = time.Now()
+	s.mu.Unlock()
+	eng.steering = s.hub
+	eng.progressSink = s.observe
+}
+
+func (s *childSupervisor) observe(ev map[string]any) {
+	name := eventFieldString(ev, "event")
+	if name == "" {
+		return
+	}
+	nodeID := eventFieldString(ev, "node_id")
+	switch name {
+	case "stage_attempt_start":
+		s.hub.stageStarted(nodeID)
+	case "stage_attempt_end":
+		s.hub.stageFinished(nodeID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch name {
+	case "stage_heartbeat":
+		// Liveness only; a stage that heartbeats without doing anything is
+		// exactly the stall the supervisor should notice.
+		return
+	case "stage_attempt_start":
+		s.obs.ActiveNode = nodeID
+		s.obs.Attempt = parseInt(fmt.Sprint(ev["attempt"]), 1)
+	case "stage_attempt_end":
+		s.obs.ActiveNode = ""
+		s.obs.Attempt = 0
+		s.obs.StagesFinished++
+		s.obs.LastNode = nodeID
+		s.obs.LastStatus = eventFieldString(ev, "status")
+		s.obs.LastFailureReason = eventFieldString(ev, "failure_reason")
+		if s.obs.LastStatus == string(runtime.StatusFail) || s.obs.LastStatus == string(runtime.StatusRetry) {
+			s.obs.Failures++
+		}
+	}
+	entry := name
+	if nodeID != "" {
+		entry += " " + nodeID
+	}
+	s.obs.RecentEvents = append(s.obs.RecentEvents, entry)
+	if n := len(s.obs.RecentEvents); n > childObservationRecentEvents {
+		s.obs.RecentEvents = append([]string(nil), s.obs.RecentEvents[n-childObservationRecentEvents:]...)
+	}
+	s.lastEventAt = time.Now()
+}
+
+// snapshot returns the current observation and the bound child engine (nil
+// until the child has been prepared).
+func (s *childSupervisor) snapshot() (childObservation, *Engine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obs := s.obs
+	obs.RecentEvents = append([]string(nil), s.obs.RecentEvents...)
+	if !s.lastEventAt.IsZero() {
+		obs.idle = time.Since(s.lastEventAt)
+		obs.IdleSeconds = int64(obs.idle / time.Second)
+	}
+	if s.eng != nil && s.eng.Context != nil {
+		obs.FailureClass = s.eng.Context.GetString("failure_class", "")
+	}
+	return obs, s.eng
+}
+
+// steerRule is one entry of a manager.steer_rules file.
+type steerRule struct {
+	Name       string         `yaml:"name"`
+	When       string         `yaml:"when"`
+	StalledFor string         `yaml:"stalled_for"`
+	Message    string         `yaml:"message"`
+	SetContext map[string]any `yaml:"set_context"`
+	Reroute    string         `yaml:"reroute"`
+	Max        int            `yaml:"max"`
+
+	stall   time.Duration
+	fired   int
+	lastKey string
+}
+
+// loadSteerRules reads and validates a steer rules file:
+//
+//	rules:
+//	  - name: unstick
+//	    when: "outcome=fail && context.failure_class=deterministic"
+//	    stalled_for: 10m
+//	    message: "Stop retrying the same fix; re-read the failing test first."
+//	    set_context: {hint: "check fixtures"}
+//	    reroute: plan
+//	    max: 1
+func loadSteerRules(path string) ([]*steerRule, error) {
+	var doc struct {
+		Rules []*steerRule `yaml:"rules"`
+	}
+	if err := LoadYAMLFile(path, &doc); err != nil {
+		return nil, err
+	}
+	for i, r := range doc.Rules {
+		if r == nil {
+			return nil, fmt.Errorf("%s: rule %d is empty", path, i+1)
+		}
+		if strings.TrimSpace(r.Name) == "" {
+			r.Name = fmt.Sprintf("rule_%d", i+1)
+		}
+		if strings.TrimSpace(r.When) == "" && strings.TrimSpace(r.StalledFor) == "" {
+			return nil, fmt.Errorf("%s: rule %q needs when or stalled_for", path, r.Name)
+		}
+		if strings.TrimSpace(r.Message) == "" && len(r.SetContext) == 0 && strings.TrimSpace(r.Reroute) == "" {
+			return nil, fmt.Errorf("%s: rule %q has no message, set_context or reroute", path, r.Name)
+		}
+		if w := strings.TrimSpace(r.When); w != "" {
+			if _, err := cond.Evaluate(w, runtime.Outcome{}, runtime.NewContext()); err != nil {
+				return nil, fmt.Errorf("%s: rule %q: invalid when %q: %w", path, r.Name, w, err)
+			}
+		}
+		if s := strings.TrimSpace(r.StalledFor); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("%s: rule %q: invalid stalled_for %q", path, r.Name, s)
+			}
+			r.stall = d
+		}
+	}
+	return doc.Rules, nil
+}
+
+// matches reports whether the rule applies to the child's current state. The
+// when clause sees the child's last stage outcome and the child's context.
+func (r *steerRule) matches(obs childObservation, childCtx *runtime.Context) bool {
+	if r.Max > 0 && r.fired >= r.Max {
+		return false
+	}
+	if r.lastKey == obs.key() {
+		return false
+	}
+	if r.stall > 0 && obs.idle < r.stall {
+		return false
+	}
+	if w := strings.TrimSpace(r.When); w != "" {
+		out := runtime.Outcome{Status: runtime.StageStatus(obs.LastStatus), FailureReason: obs.LastFailureReason}
+		ok, err := cond.Evaluate(w, out, childCtx)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// steerIntervention is one action taken against the child pipeline.
+type steerIntervention struct {
+	Source     string
+	Reason     string
+	Message    string
+	SetContext map[string]any
+	Reroute    string
+}
+
+// steerAdvisor decides whether to intervene given the child's state.
+// A nil intervention means "leave the child alone".
+type steerAdvisor interface {
+	Advise(ctx context.Context, instructions string, obs childObservation) (*steerIntervention, error)
+}
+
+// managerSteering holds the steer configuration of one manager loop node.
+type managerSteering struct {
+	node             *model.Node
+	sup              *childSupervisor
+	rules            []*steerRule
+	advisor          steerAdvisor
+	instructions     string
+	maxInterventions int
+	cooldown         time.Duration
+
+	count       int
+	lastAt      time.Time
+	lastAdvised string
+}
+
+// newManagerSteering reads the node's steer configuration. It returns nil when
+// no rules or LLM supervisor are configured.
+func newManagerSteering(exec *Execution, node *model.Node, sup *childSupervisor) (*managerSteering, error) {
+	ms := &managerSteering{
+		node:             node,
+		sup:              sup,
+		instructions:     strings.TrimSpace(node.Prompt()),
+		maxInterventions: parseInt(node.Attr("manager.max_interventions", "3"), 3),
+		cooldown:         parseDuration(node.Attr("manager.steer_cooldown", "0s"), 0),
+	}
+	if rulesPath := strings.TrimSpace(node.Attr("manager.steer_rules", "")); rulesPath != "" {
+		if !filepath.IsAbs(rulesPath) && exec.WorktreeDir != "" {
+			rulesPath = filepath.Join(exec.WorktreeDir, rulesPath)
+		}
+		rules, err := loadSteerRules(rulesPath)
+		if err != nil {
+			return nil, fmt.Errorf("manager.steer_rules: %w", err)
+		}
+		ms.rules = rules
+	}
+	// The supervisor has its own attributes: llm_model/llm_provider are
+	// inherited from model_stylesheet rules and graph defaults, and would turn
+	// it on for every steer node in a graph with a global model.
+	if modelID := strings.TrimSpace(node.Attr("manager.llm_model", "")); modelID != "" {
+		router, ok := exec.Engine.AgentBackend.(*AgentRouter)
+		if !ok {
+			return nil, fmt.Errorf("manager.llm_model requires the API agent backend")
+		}
+		client, err := router.ensureAPIClient()
+		if err != nil {
+			return nil, fmt.Errorf("manager llm supervisor: %w", err)
+		}
+		ms.advisor = &llmSteerAdvisor{
+			client:   client,
+			provider: normalizeProviderKey(node.Attr("manager.llm_provider", "")),
+			model:    modelID,
+		}
+	}
+	if len(ms.rules) == 0 && ms.advisor == nil {
+		return nil, nil
+	}
+	return ms, nil
+}
+
+// cycle evaluates the child once and applies at most one intervention.
+func (ms *managerSteering) cycle(ctx context.Context, exec *Execution, cycle int) {
+	if ms == nil {
+		return
+	}
+	ms.reportDropped(exec)
+	if ms.count >= ms.maxInterventions {
+		return
+	}
+	if ms.cooldown > 0 && !ms.lastAt.IsZero() && time.Since(ms.lastAt) < ms.cooldown {
+		return
+	}
+	obs, child := ms.sup.snapshot()
+	if child == nil {
+		return
+	}
+	var iv *steerIntervention
+	for _, r := range ms.rules {
+		if r.matches(obs, child.Context) {
+			r.fired++
+			r.lastKey = obs.key()
+			iv = &steerIntervention{
+				Source:     "rule:" + r.Name,
+				Reason:     strings.TrimSpace(r.When),
+				Message:    strings.TrimSpace(r.Message),
+				SetContext: r.SetContext,
+				Reroute:    strings.TrimSpace(r.Reroute),
+			}
+			if iv.Reason == "" {
+				iv.Reason = "stalled for " + r.StalledFor
+			}
+			break
+		}
+	}
+	if iv == nil && ms.advisor != nil && ms.lastAdvised != obs.key() {
+		ms.lastAdvised = obs.key()
+		advice, err := ms.advisor.Advise(ctx, ms.instructions, obs)
+		if err != nil {
+			exec.Engine.Warn(fmt.Sprintf("manager_loop node %s: llm supervisor: %v", ms.node.ID, err))
+			return
+		}
+		iv = advice
+	}
+	if iv == nil {
+		return
+	}
+	ms.count++
+	ms.lastAt = time.Now()
+	ms.apply(ctx, exec, child, cycle, iv)
+}
+
+// apply performs an intervention and records it in the child's progress log,
+// run log and CXDB context, and in the manager's own progress stream.
+func (ms *managerSteering) apply(ctx context.Context, exec *Execution, child *Engine, cycle int, iv *steerIntervention) {
+	target, delivery := "", steerDelivery("")
+	if iv.Message != "" {
+		var err error
+		target, delivery, err = ms.sup.hub.steer(iv.Message)
+		if err != nil {
+			exec.Engine.Warn(fmt.Sprintf("manager_loop node %s: steer message to %s: %v", ms.node.ID, target, err))
+		}
+	}
+	contextKeys := make([]string, 0, len(iv.SetContext))
+	if len(iv.SetContext) > 0 {
+		child.Context.ApplyUpdates(iv.SetContext)
+		for k := range iv.SetContext {
+			contextKeys = append(contextKeys, k)
+		}
+		sort.Strings(contextKeys)
+	}
+	if iv.Reroute != "" {
+		if child.Graph.Nodes[iv.Reroute] == nil {
+			exec.Engine.Warn(fmt.Sprintf("manager_loop node %s: reroute target %q is not in the child graph", ms.node.ID, iv.Reroute))
+			iv.Reroute = ""
+		} else {
+			ms.sup.hub.requestReroute(iv.Reroute)
+		}
+	}
+
+	ev := map[string]any{
+		"event":           "manager_intervention",
+		"manager_node_id": ms.node.ID,
+		"cycle":           cycle,
+		"intervention":    ms.count,
+		"max":             ms.maxInterventions,
+		"source":          iv.Source,
+		"reason":          iv.Reason,
+	}
+	if iv.Message != "" {
+		ev["message"] = iv.Message
+		ev["target_node_id"] = target
+		ev["delivery"] = string(delivery)
+	}
+	if len(contextKeys) > 0 {
+		ev["context_keys"] = contextKeys
+	}
+	if iv.Reroute != "" {
+		ev["reroute"] = iv.Reroute
+	}
+	child.appendProgress(copyMap(ev))
+	child.RunLog.Info("manager", target, "manager.intervention",
+		fmt.Sprintf("Manager %s intervention %d/%d (%s)", ms.node.ID, ms.count, ms.maxInterventions, iv.Source), copyMap(ev))
+	child.cxdbManagerIntervention(ctx, ms.node.ID, iv, target, delivery, contextKeys)
+
+	parentEv := copyMap(ev)
+	parentEv["event"] = "manager_steer"
+	parentEv["node_id"] = ms.node.ID
+	delete(parentEv, "manager_node_id")
+	exec.Engine.appendProgress(parentEv)
+}
+
+// reportDropped records the steering messages that never reached a session
+// in the child's and the manager's progress.
+func (ms *managerSteering) reportDropped(exec *Execution) {
+	dropped := ms.sup.hub.takeDropped()
+	if len(dropped) == 0 {
+		return
+	}
+	_, child := ms.sup.snapshot()
+	for _, m := range dropped {
+		ev := map[string]any{
+			"event":           "manager_steer_dropped",
+			"manager_node_id": ms.node.ID,
+			"target_node_id":  m.nodeID,
+			"message":         m.text,
+			"delivery":        string(steerNotDelivered),
+		}
+		if child != nil {
+			child.appendProgress(copyMap(ev))
+		}
+		parentEv := copyMap(ev)
+		parentEv["node_id"] = ms.node.ID
+		delete(parentEv, "manager_node_id")
+		exec.Engine.appendProgress(parentEv)
+	}
+}
+
+// llmSteerAdvisor asks a model whether and how to intervene.
+type llmSteerAdvisor struct {
+	client   *llm.Client
+	provider string
+	model    string
+}
+
+func (a *llmSteerAdvisor) Advise(ctx context.Context, instructions string, obs childObservation) (*steerIntervention, error) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action":  map[string]any{"type": "string", "enum": []string{"none", "intervene"}},
+			"reason":  map[string]any{"type": "string"},
+			"message": map[string]any{"type": "string"},
+			"set_context": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"key":   map[string]any{"type": "string"},
+						"value": map[string]any{"type": "string"},
+					},
+					"required":             []string{"key", "value"},
+					"additionalProperties": false,
+				},
+			},
+			"reroute": map[string]any{"type": "string"},
+		},
+		"required":             []string{"action", "reason", "message", "set_context", "reroute"},
+		"additionalProperties": false,
+	}
+	obsJSON, err := json.MarshalIndent(obs, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var user strings.Builder
+	if instructions != "" {
+		user.WriteString(instructions)
+		user.WriteString("\n\n")
+	}
+	user.WriteString("Current state of the supervised pipeline:\n")
+	user.Write(obsJSON)
+	resp, err := a.client.Complete(ctx, llm.Request{
+		Provider: a.provider,
+		Model:    a.model,
+		Messages: []llm.Message{
+			llm.System("You supervise an automated software pipeline. Intervene only when the pipeline is stuck, looping, or heading the wrong way. " +
+				"To intervene, set action=intervene and give a message for the working agent, context keys to set, and/or a node id to reroute to (empty strings/arrays for unused fields). " +
+				"Otherwise set action=none. Return strictly valid JSON matching the schema."),
+			llm.User(user.String()),
+		},
+		ResponseFormat: &llm.ResponseFormat{
+			Type:       "json_schema",
+			Strict:     true,
+			JSONSchema: schema,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseSteerDecision(resp.Text())
+}
+
+// parseSteerDecision turns an LLM supervisor reply into an intervention.
+func parseSteerDecision(raw string) (*steerIntervention, error) {
+	var d struct {
+		Action     string `json:"action"`
+		Reason     string `json:"reason"`
+		Message    string `json:"message"`
+		SetContext []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"set_context"`
+		Reroute string `json:"reroute"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &d); err != nil {
+		return nil, fmt.Errorf("decode supervisor decision: %w", err)
+	}
+	if !strings.EqualFold(strings.TrimSpace(d.Action), "intervene") {
+		return nil, nil
+	}
+	iv := &steerIntervention{
+		Source:  "llm",
+		Reason:  strings.TrimSpace(d.Reason),
+		Message: strings.TrimSpace(d.Message),
+		Reroute: strings.TrimSpace(d.Reroute),
+	}
+	for _, kv := range d.SetContext {
+		if k := strings.TrimSpace(kv.Key); k != "" {
+			if iv.SetContext == nil {
+				iv.SetContext = map[string]any{}
+			}
+			iv.SetContext[k] = kv.Value
+		}
+	}
+	if iv.Message == "" && len(iv.SetContext) == 0 && iv.Reroute == "" {
+		return nil, nil
+	}
+	return iv, nil
+}
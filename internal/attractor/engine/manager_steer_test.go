@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+func TestSteeringHub_QueuesForTheRunningStage(t *testing.T) {
+	h := &steeringHub{}
+	if to, d, _ := h.steer("first"); to != "" || d != steerQueued {
+		t.Fatalf("steer between stages = %q %s, want queued for the next stage", to, d)
+	}
+	h.stageStarted("impl")
+	var got []string
+	detach := h.attachSession("impl", func(msg string) error { got = append(got, msg); return nil })
+	if strings.Join(got, ",") != "first" {
+		t.Fatalf("queued messages on attach = %v", got)
+	}
+	if to, d, _ := h.steer("second"); to != "impl" || d != steerDelivered {
+		t.Fatalf("steer = %q %s, want delivered to impl", to, d)
+	}
+	detach()
+	if to, d, _ := h.steer("third"); to != "impl" || d != steerNotDelivered || len(got) != 2 {
+		t.Fatalf("steer after detach = %q %s (%v), want not delivered", to, d, got)
+	}
+
+	var nilHub *steeringHub
+	nilHub.attachSession("x", func(string) error { return nil })()
+	if nilHub.takeReroute() != "" {
+		t.Fatal("nil hub reroute")
+	}
+}
+
+func TestSteeringHub_DropsMessagesWhenTheirStageFinishes(t *testing.T) {
+	h := &steeringHub{}
+	h.stageStarted("impl")
+	if _, d, _ := h.steer("for impl"); d != steerQueued {
+		t.Fatalf("steer before attach = %s, want queued", d)
+	}
+	h.stageFinished("impl")
+	h.stageStarted("review")
+	var got []string
+	h.attachSession("review", func(msg string) error { got = append(got, msg); return nil })
+	if len(got) != 0 {
+		t.Fatalf("review received impl's message: %v", got)
+	}
+	if dropped := h.takeDropped(); len(dropped) != 1 || dropped[0].nodeID != "impl" || dropped[0].text != "for impl" {
+		t.Fatalf("dropped = %+v", dropped)
+	}
+}
+
+func TestSteeringHub_ReportsStagesThatCannotTakeInput(t *testing.T) {
+	h := &steeringHub{}
+	h.stageStarted("impl")
+	h.steer("early")
+	detach := h.attachSession("impl", nil)
+	if to, d, _ := h.steer("late"); to != "impl" || d != steerNotDelivered {
+		t.Fatalf("steer to cli stage = %q %s, want not delivered", to, d)
+	}
+	detach()
+	if dropped := h.takeDropped(); len(dropped) != 1 || dropped[0].text != "early" {
+		t.Fatalf("dropped = %+v", dropped)
+	}
+
+	h.stageStarted("tmux")
+	h.attachSession("tmux", func(string) error { return fmt.Errorf("session gone") })
+	if _, d, err := h.steer("hello"); d != steerNotDelivered || err == nil {
+		t.Fatalf("failed send = %s %v, want not delivered with error", d, err)
+	}
+}
+
+func TestLoadSteerRules_Validates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(body string) string {
+		p := filepath.Join(dir, "rules.yaml")
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	rules, err := loadSteerRules(write(`
+rules:
+  - when: "outcome=fail"
+    message: "look again"
+  - name: stuck
+    stalled_for: 5m
+    reroute: plan
+`))
+	if err != nil {
+		t.Fatalf("loadSteerRules: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Name != "rule_1" || rules[1].stall != 5*time.Minute {
+		t.Fatalf("rules = %+v", rules)
+	}
+
+	for body, want := range map[string]string{
+		"rules:\n  - message: hi\n":                        "needs when or stalled_for",
+		"rules:\n  - when: outcome=fail\n":                 "has no message",
+		"rules:\n  - stalled_for: soon\n    message: hi\n": "invalid stalled_for",
+	} {
+		if _, err := loadSteerRules(write(body)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: err = %v, want %q", body, err, want)
+		}
+	}
+}
+
+func TestNewManagerSteering_IgnoresInheritedLLMModel(t *testing.T) {
+	node := model.NewNode("manage")
+	node.Attrs["llm_provider"] = "anthropic"
+	node.Attrs["llm_model"] = "claude-sonnet-4-5" // e.g. from a stylesheet * rule
+	// No engine: enabling the LLM supervisor here would dereference it.
+	ms, err := newManagerSteering(&Execution{}, node, nil)
+	if err != nil || ms != nil {
+		t.Fatalf("steering = %+v, %v; want none", ms, err)
+	}
+}
+
+func TestSteerRule_FiresOncePerStageAttempt(t *testing.T) {
+	r := &steerRule{Name: "r", When: "outcome=fail", Message: "m"}
+	ctx := runtime.NewContext()
+	obs := childObservation{StagesFinished: 1, LastStatus: "fail"}
+	if !r.matches(obs, ctx) {
+		t.Fatal("expected match on failed stage")
+	}
+	r.lastKey = obs.key()
+	if r.matches(obs, ctx) {
+		t.Fatal("rule fired twice for the same stage attempt")
+	}
+	obs.StagesFinished = 2
+	if !r.matches(obs, ctx) {
+		t.Fatal("expected match after another failed stage")
+	}
+	obs.LastStatus = "success"
+	if r.matches(obs, ctx) {
+		t.Fatal("matched a successful stage")
+	}
+}
+
+func TestParseSteerDecision(t *testing.T) {
+	iv, err := parseSteerDecision(`{"action":"intervene","reason":"looping","message":"try the other API","set_context":[{"key":"hint","value":"v2"}],"reroute":""}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iv == nil || iv.Source != "llm" || iv.Message != "try the other API" || iv.SetContext["hint"] != "v2" {
+		t.Fatalf("intervention = %+v", iv)
+	}
+	if iv, err := parseSteerDecision(`{"action":"none","reason":"fine","message":"","set_context":[],"reroute":""}`); err != nil || iv != nil {
+		t.Fatalf("action none = %+v, %v", iv, err)
+	}
+}
+
+// TestManagerLoop_SteerRuleIntervenesInStalledChild runs a manager node over a
+// child pipeline whose middle stage stalls. A stalled_for rule must set child
+// context, queue a message, and reroute the child to a repair node, and the
+// intervention must be recorded in the child's progress log.
+func TestManagerLoop_SteerRuleIntervenesInStalledChild(t *testing.T) {
+	repo := initTestRepo(t)
+	pinned := writePinnedCatalog(t)
+	child := `digraph child {
+  start [shape=Mdiamond]
+  pause [shape=parallelogram, tool_command="sleep 3"]
+  gate [shape=diamond]
+  repair [shape=parallelogram, tool_command="echo repaired > repaired.txt"]
+  after [shape=diamond]
+  done [shape=Msquare]
+  start -> pause -> gate
+  gate -> repair [condition="context.steered=yes"]
+  gate -> done
+  repair -> after
+  after -> done
+}`
+	rules := `rules:
+  - name: unstick
+    stalled_for: 200ms
+    message: "You seem stuck."
+    set_context: {steered: "yes"}
+    reroute: repair
+`
+	for name, body := range map[string]string{"child.dot": child, "steer.yaml": rules} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runCmd(t, repo, "git", "add", "-A")
+	runCmd(t, repo, "git", "commit", "-m", "child graph")
+
+	dot := []byte(`digraph parent {
+  graph [goal="Supervise a child", stack.child_dotfile="child.dot"]
+  start [shape=Mdiamond]
+  manage [shape=house, manager.actions="observe,steer,wait", manager.poll_interval="1s", manager.max_cycles="30", manager.steer_rules="steer.yaml", manager.max_interventions="1"]
+  gate [shape=diamond]
+  done [shape=Msquare]
+  start -> manage -> gate
+  gate -> done
+}`)
+	logsRoot := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	res, err := RunWithConfig(ctx, dot, minimalToolGraphConfig(repo, pinned), RunOptions{
+		RunID:       "steer-run",
+		LogsRoot:    logsRoot,
+		DisableCXDB: true,
+	})
+	if err != nil {
+		t.Fatalf("RunWithConfig: %v", err)
+	}
+	if res.FinalStatus != runtime.FinalSuccess {
+		t.Fatalf("expected success, got %q", res.FinalStatus)
+	}
+	if _, err := os.Stat(filepath.Join(res.WorktreeDir, "repaired.txt")); err != nil {
+		t.Fatalf("reroute to repair did not happen: %v", err)
+	}
+
+	progress, err := os.ReadFile(filepath.Join(logsRoot, "manage", "child", "progress.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(progress), `"event":"manager_intervention"`); n != 1 {
+		t.Fatalf("child progress has %d manager_intervention events, want 1", n)
+	}
+	if !strings.Contains(string(progress), `"event":"manager_reroute"`) {
+		t.Fatal("child progress missing manager_reroute")
+	}
+	runLog, err := os.ReadFile(filepath.Join(logsRoot, "manage", "child", "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(runLog), "manager.intervention") {
+		t.Fatal("child run.log missing manager.intervention")
+	}
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/agent"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+)
+
+// MCPServerRunConfig is one entry of run.yaml's mcp_servers map, keyed by
+// server name. API agent_loop sessions launch these over stdio and offer
+// their tools as <name>__<tool>.
+//
+//	mcp_servers:
+//	  docs:
+//	    command: [npx, -y, "@acme/docs-mcp"]
+//	    env: {DOCS_INDEX: ./docs}
+//	    tools: [search, fetch_page]
+//	    max_output_chars: 20000
+//	    tool_output_limits:
+//	      fetch_page: {max_chars: 60000, strategy: head_tail}
+//
+// A node's mcp_servers="docs,db" attribute picks a subset; "none" disables
+// MCP for that node. Without the attribute every configured server is used.
+type MCPServerRunConfig struct {
+	Command          []string                  `json:"command" yaml:"command"`
+	Env              map[string]string         `json:"env,omitempty" yaml:"env,omitempty"`
+	Tools            []string                  `json:"tools,omitempty" yaml:"tools,omitempty"`
+	RequestTimeoutMS int                       `json:"request_timeout_ms,omitempty" yaml:"request_timeout_ms,omitempty"`
+	MaxOutputChars   int                       `json:"max_output_chars,omitempty" yaml:"max_output_chars,omitempty"`
+	ToolOutputLimits map[string]MCPOutputLimit `json:"tool_output_limits,omitempty" yaml:"tool_output_limits,omitempty"`
+}
+
+// MCPOutputLimit caps one MCP tool's output. Strategy is head_tail (default)
+// or tail.
+type MCPOutputLimit struct {
+	MaxChars int    `json:"max_chars" yaml:"max_chars"`
+	Strategy string `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+}
+
+func validateMCPServers(servers map[string]MCPServerRunConfig) error {
+	for name, s := range servers {
+		if strings.TrimSpace(name) == "" || strings.Contains(name, ",") || name == "none" {
+			return fmt.Errorf("mcp_servers: invalid server name %q", name)
+		}
+		if len(trimNonEmpty(s.Command)) == 0 {
+			return fmt.Errorf("mcp_servers.%s.command is required", name)
+		}
+		if s.RequestTimeoutMS < 0 || s.MaxOutputChars < 0 {
+			return fmt.Errorf("mcp_servers.%s: request_timeout_ms and max_output_chars must be >= 0", name)
+		}
+		for tool, l := range s.ToolOutputLimits {
+			if l.MaxChars <= 0 {
+				return fmt.Errorf("mcp_servers.%s.tool_output_limits.%s.max_chars must be > 0", name, tool)
+			}
+			switch agent.TruncationStrategy(strings.TrimSpace(l.Strategy)) {
+			case "", agent.TruncHeadTail, agent.TruncTail:
+			default:
+				return fmt.Errorf("mcp_servers.%s.tool_output_limits.%s.strategy: %q (want head_tail|tail)", name, tool, l.Strategy)
+			}
+		}
+	}
+	return nil
+}
+
+// agentMCPServers converts the run config's MCP servers for an agent session
+// on node, honouring the node's mcp_servers selection.
+func agentMCPServers(cfg *RunConfigFile, node *model.Node) ([]agent.MCPServerConfig, error) {
+	var names []string
+	if node != nil {
+		if sel := strings.TrimSpace(node.Attr("mcp_servers", "")); sel != "" {
+			if sel == "none" {
+				return nil, nil
+			}
+			for _, name := range strings.Split(sel, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	var servers map[string]MCPServerRunConfig
+	if cfg != nil {
+		servers = cfg.MCPServers
+	}
+	if names == nil {
+		for name := range servers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	out := make([]agent.MCPServerConfig, 0, len(names))
+	for _, name := range names {
+		s, ok := servers[name]
+		if !ok {
+			return nil, fmt.Errorf("node %s: mcp_servers: %q is not configured in run config mcp_servers", node.ID, name)
+		}
+		mc := agent.MCPServerConfig{
+			Name:           name,
+			Command:        trimNonEmpty(s.Command),
+			Env:            s.Env,
+			Tools:          trimNonEmpty(s.Tools),
+			RequestTimeout: time.Duration(s.RequestTimeoutMS) * time.Millisecond,
+			OutputLimit:    agent.ToolOutputLimit{MaxChars: s.MaxOutputChars},
+		}
+		if len(s.ToolOutputLimits) > 0 {
+			mc.ToolOutputLimits = map[string]agent.ToolOutputLimit{}
+			for tool, l := range s.ToolOutputLimits {
+				mc.ToolOutputLimits[tool] = agent.ToolOutputLimit{MaxChars: l.MaxChars, Strategy: agent.TruncationStrategy(strings.TrimSpace(l.Strategy))}
+			}
+		}
+		out = append(out, mc)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
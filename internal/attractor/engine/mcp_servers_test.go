@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/agent"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+)
+
+func TestAgentMCPServers_NodeSelection(t *testing.T) {
+	cfg := &RunConfigFile{MCPServers: map[string]MCPServerRunConfig{
+		"docs": {
+			Command:          []string{"docs-mcp", " "},
+			Tools:            []string{"search"},
+			RequestTimeoutMS: 5000,
+			MaxOutputChars:   1000,
+			ToolOutputLimits: map[string]MCPOutputLimit{"fetch": {MaxChars: 50, Strategy: "tail"}},
+		},
+		"db": {Command: []string{"db-mcp"}},
+	}}
+	if err := validateMCPServers(cfg.MCPServers); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := agentMCPServers(cfg, model.NewNode("impl"))
+	if err != nil || len(all) != 2 || all[0].Name != "db" || all[1].Name != "docs" {
+		t.Fatalf("all = %+v, %v", all, err)
+	}
+	docs := all[1]
+	if len(docs.Command) != 1 || docs.RequestTimeout != 5*time.Second || docs.OutputLimit.MaxChars != 1000 ||
+		docs.ToolOutputLimits["fetch"] != (agent.ToolOutputLimit{MaxChars: 50, Strategy: agent.TruncTail}) {
+		t.Fatalf("docs = %+v", docs)
+	}
+
+	n := model.NewNode("review")
+	n.Attrs["mcp_servers"] = "docs"
+	if got, _ := agentMCPServers(cfg, n); len(got) != 1 || got[0].Name != "docs" {
+		t.Fatalf("selected = %+v", got)
+	}
+	n.Attrs["mcp_servers"] = "none"
+	if got, _ := agentMCPServers(cfg, n); got != nil {
+		t.Fatalf("none = %+v", got)
+	}
+	n.Attrs["mcp_servers"] = "docs,wiki"
+	if _, err := agentMCPServers(cfg, n); err == nil || !strings.Contains(err.Error(), `"wiki" is not configured`) {
+		t.Fatalf("expected unknown server error, got %v", err)
+	}
+}
+
+func TestValidateMCPServers_Rejects(t *testing.T) {
+	cases := map[string]map[string]MCPServerRunConfig{
+		"command is required": {"docs": {}},
+		"strategy":            {"docs": {Command: []string{"x"}, ToolOutputLimits: map[string]MCPOutputLimit{"a": {MaxChars: 1, Strategy: "middle"}}}},
+		"invalid server name": {"none": {Command: []string{"x"}}},
+	}
+	for want, servers := range cases {
+		if err := validateMCPServers(servers); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: got %v", want, err)
+		}
+	}
+}
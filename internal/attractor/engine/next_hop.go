@@ -165,7 +165,7 @@ func isFanInFailureLike(g *model.Graph, from string, status runtime.StageStatus)
 	}
 	t := strings.TrimSpace(n.TypeOverride())
 	if t == "" {
-		t = shapeToType(n.Shape())
+		t = model.ShapeType(n.Shape())
 	}
 	return t == "parallel.fan_in"
 }
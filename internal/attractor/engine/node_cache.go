@@ -0,0 +1,257 @@
+// Node result caching: opt-in memoization of agent and tool nodes.
+//
+// A node with cache=true (or any agent/tool node when the graph sets
+// default_cache=true) is keyed on a hash of its resolved inputs: node ID,
+// handler type, prompt, tool_command or test_command (with test_report and
+// test_format), provider/model and reasoning effort, the tools allow-list,
+// the resolved MCP server configs, the content of attach files, the context
+// keys named in cache_keys, and the workspace tree SHA. When a previous run
+// recorded an outcome for the same key, the engine restores that outcome and
+// the files of the previous run's checkpoint commit instead of executing the
+// node. Hits are reported with status "cached" in progress events, CXDB and
+// rundb. rundb prunes old entries on every write (see rundb.NodeCacheMaxAge).
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+// nodeCacheKeyVersion is mixed into every cache key so a change in what the
+// key covers invalidates old entries.
+const nodeCacheKeyVersion = "kilroy-node-cache-v6"
+
+// cachedStageStatus is the status reported to CXDB and rundb for a node whose
+// outcome was restored from the cache. Routing still uses the restored status.
+const cachedStageStatus = "cached"
+
+// Outcome.Meta keys set on restored outcomes.
+const (
+	metaCacheSourceRunID = "kilroy.cache_source_run_id"
+	metaCacheSourceSHA   = "kilroy.cache_source_sha"
+)
+
+// nodeCacheEnabled reports whether node opts into result caching. The node's
+// cache attribute wins over the graph's default_cache. Only agent and tool
+// nodes are cacheable; control-flow nodes always execute.
+func nodeCacheEnabled(g *model.Graph, node *model.Node) bool {
+	if g == nil || node == nil {
+		return false
+	}
+	t := strings.TrimSpace(node.TypeOverride())
+	if t == "" {
+		t = model.ShapeType(node.Shape())
+	}
+	if t != "agent" && t != "tool" {
+		return false
+	}
+	return parseBool(node.Attr("cache", ""), parseBool(g.Attrs["default_cache"], false))
+}
+
+// nodeCacheStore returns the run database as a NodeCacheStore when caching is
+// possible for this engine (a git workspace and a database that supports it).
+func (e *Engine) nodeCacheStore() NodeCacheStore {
+	if e == nil || e.RunDB == nil || e.GitOps == nil {
+		return nil
+	}
+	store, _ := e.RunDB.(NodeCacheStore)
+	return store
+}
+
+// nodeCacheKey returns the cache key for node and the workspace tree SHA it
+// covers, or empty strings when the node is not cacheable in this run.
+func (e *Engine) nodeCacheKey(node *model.Node) (key, treeSHA string) {
+	if !nodeCacheEnabled(e.Graph, node) || e.nodeCacheStore() == nil || e.concurrentDepth > 0 {
+		return "", ""
+	}
+	treeSHA, err := e.GitOps.TreeSHA(e.WorktreeDir)
+	if err != nil || strings.TrimSpace(treeSHA) == "" {
+		e.Warn(fmt.Sprintf("node cache: %s: resolve workspace tree: %v", node.ID, err))
+		return "", ""
+	}
+
+	provider := normalizeProviderKey(node.Attr("llm_provider", ""))
+	modelID := strings.TrimSpace(node.Attr("llm_model", node.Attr("model", "")))
+	if forced, ok := forceModelForProvider(e.Options.ForceModels, provider); ok {
+		modelID = forced
+	}
+
+	h := sha256.New()
+	field := func(name, value string) {
+		fmt.Fprintf(h, "%s=%d:%s\n", name, len(value), value)
+	}
+	field("version", nodeCacheKeyVersion)
+	field("node", node.ID)
+	field("handler", resolvedHandlerTypeName(e, node.ID))
+	field("prompt", node.Prompt())
+	field("tool_command", node.Attr("tool_command", ""))
+	field("test_command", node.Attr("test_command", ""))
+	field("test_report", node.Attr("test_report", ""))
+	field("test_format", node.Attr("test_format", ""))
+	field("provider", provider)
+	field("model", modelID)
+	field("reasoning_effort", node.Attr("reasoning_effort", ""))
+	field("tools", node.Attr("tools", ""))
+	// Hash the resolved servers, not the attribute: editing a server's
+	// command or limits in the run config must miss the cache too.
+	mcpServers, err := agentMCPServers(e.RunConfig, node)
+	if err != nil {
+		e.Warn(fmt.Sprintf("node cache: %s: %v", node.ID, err))
+		return "", ""
+	}
+	mcpJSON, _ := json.Marshal(mcpServers)
+	field("mcp_servers", string(mcpJSON))
+	// Attachments may come from the run inputs, which the tree SHA misses.
+	atts, err := resolveStageAttachments(e.WorktreeDir, e.LogsRoot, node)
+	if err != nil {
+		e.Warn(fmt.Sprintf("node cache: %s: %v", node.ID, err))
+		return "", ""
+	}
+	for _, a := range atts {
+		sum, err := attachmentDigest(a)
+		if err != nil {
+			e.Warn(fmt.Sprintf("node cache: %s: %v", node.ID, err))
+			return "", ""
+		}
+		field("attach."+a.Rel, sum)
+	}
+	for _, k := range nodeCacheContextKeys(node) {
+		v, _ := e.Context.Get(k)
+		b, _ := json.Marshal(v)
+		field("context."+k, string(b))
+	}
+	field("tree", treeSHA)
+	return hex.EncodeToString(h.Sum(nil)), treeSHA
+}
+
+// nodeCacheContextKeys parses the node's comma-separated cache_keys attribute
+// into a sorted, de-duplicated list.
+func nodeCacheContextKeys(node *model.Node) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, k := range strings.Split(node.Attr("cache_keys", ""), ",") {
+		k = strings.TrimSpace(k)
+		if k == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// restoreCachedNode looks up key and, on a hit, restores the recorded outcome
+// and the source checkpoint's files into the workspace. It reports false (and
+// the node executes normally) on a miss or when the hit cannot be restored.
+func (e *Engine) restoreCachedNode(node *model.Node, key string) (runtime.Outcome, bool) {
+	store := e.nodeCacheStore()
+	if store == nil || key == "" {
+		return runtime.Outcome{}, false
+	}
+	srcRunID, srcSHA, raw, err := store.LookupNodeCache(key, e.Options.RunID)
+	if err != nil {
+		e.Warn(fmt.Sprintf("node cache: %s: lookup: %v", node.ID, err))
+		return runtime.Outcome{}, false
+	}
+	if srcRunID == "" {
+		return runtime.Outcome{}, false
+	}
+	var out runtime.Outcome
+	if err := json.Unmarshal(raw, &out); err != nil {
+		e.Warn(fmt.Sprintf("node cache: %s: decode outcome from run %s: %v", node.ID, srcRunID, err))
+		return runtime.Outcome{}, false
+	}
+	out, err = out.Canonicalize()
+	if err != nil {
+		e.Warn(fmt.Sprintf("node cache: %s: invalid outcome from run %s: %v", node.ID, srcRunID, err))
+		return runtime.Outcome{}, false
+	}
+	if err := e.GitOps.RestoreTree(e.WorktreeDir, srcSHA); err != nil {
+		e.Warn(fmt.Sprintf("node cache: %s: restore files from %s: %v", node.ID, srcSHA, err))
+		return runtime.Outcome{}, false
+	}
+
+	// The source run's commit is not this run's HEAD; let checkpoint commit
+	// the restored files normally.
+	delete(out.Meta, "kilroy.git_checkpoint_sha")
+	if out.Meta == nil {
+		out.Meta = map[string]any{}
+	}
+	out.Meta[metaCacheSourceRunID] = srcRunID
+	out.Meta[metaCacheSourceSHA] = srcSHA
+
+	stageDir := filepath.Join(e.LogsRoot, node.ID)
+	if err := os.MkdirAll(stageDir, 0o755); err == nil {
+		_ = writeJSON(filepath.Join(stageDir, "status.json"), out)
+	}
+	e.appendProgress(map[string]any{
+		"event":         "stage_cached",
+		"node_id":       node.ID,
+		"status":        string(out.Status),
+		"source_run_id": srcRunID,
+		"source_sha":    srcSHA,
+		"cache_key":     key,
+	})
+	e.RunLog.Info("engine", node.ID, "stage.cached", fmt.Sprintf("Restored cached result from run %s", srcRunID), map[string]any{
+		"status":     string(out.Status),
+		"source_sha": srcSHA,
+	})
+	return out, true
+}
+
+// recordNodeCache indexes a completed node's outcome under key so later runs
+// can reuse it. Only successful outcomes with a checkpoint commit are cached.
+func (e *Engine) recordNodeCache(node *model.Node, key, treeSHA, sha string, out runtime.Outcome) {
+	store := e.nodeCacheStore()
+	if store == nil || key == "" || strings.TrimSpace(sha) == "" {
+		return
+	}
+	switch out.Status {
+	case runtime.StatusSuccess, runtime.StatusPartialSuccess, runtime.StatusDegradedSuccess:
+	default:
+		return
+	}
+	rec := out
+	rec.Meta = nil
+	for k, v := range out.Meta {
+		if strings.HasPrefix(k, "kilroy.") {
+			continue
+		}
+		if rec.Meta == nil {
+			rec.Meta = map[string]any{}
+		}
+		rec.Meta[k] = v
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		e.Warn(fmt.Sprintf("node cache: %s: encode outcome: %v", node.ID, err))
+		return
+	}
+	if err := store.RecordNodeCache(key, e.Options.RunID, node.ID, treeSHA, sha, b); err != nil {
+		e.Warn(fmt.Sprintf("node cache: %s: record: %v", node.ID, err))
+	}
+}
+
+// isCachedOutcome reports whether out was restored from the node cache.
+func isCachedOutcome(out runtime.Outcome) bool {
+	_, ok := out.Meta[metaCacheSourceRunID]
+	return ok
+}
+
+// reportedStageStatus is the status written to CXDB and rundb for out.
+func reportedStageStatus(out runtime.Outcome) string {
+	if isCachedOutcome(out) {
+		return cachedStageStatus
+	}
+	return string(out.Status)
+}
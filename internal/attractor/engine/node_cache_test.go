@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+// TestNodeCache_SecondRunRestoresCachedNode runs the same graph twice against
+// one repo and run database. The cached node must execute only once, its file
+// changes must still land in the second run, and the hit must be recorded as
+// "cached".
+func TestNodeCache_SecondRunRestoresCachedNode(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	repo := initTestRepo(t)
+	pinned := writePinnedCatalog(t)
+	counter := filepath.Join(t.TempDir(), "executions.txt")
+
+	rdb, err := rundb.Open(filepath.Join(t.TempDir(), "runs.db"))
+	if err != nil {
+		t.Fatalf("Open rundb: %v", err)
+	}
+	defer rdb.Close()
+
+	dot := []byte(`digraph cache_test {
+  graph [goal="Exercise node caching"]
+  start [shape=Mdiamond]
+  build [shape=parallelogram, cache=true, tool_command="echo run >> ` + counter + ` && echo built > artifact.txt"]
+  verify [shape=parallelogram, tool_command="test -f artifact.txt"]
+  gate [shape=diamond]
+  done [shape=Msquare]
+  start -> build -> verify -> gate
+  gate -> done
+}`)
+	run := func(runID string) *Result {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		res, err := RunWithConfig(ctx, dot, minimalToolGraphConfig(repo, pinned), RunOptions{
+			RunID:       runID,
+			LogsRoot:    t.TempDir(),
+			DisableCXDB: true,
+			RunDB:       rdb,
+		})
+		if err != nil {
+			t.Fatalf("%s: RunWithConfig: %v", runID, err)
+		}
+		if res.FinalStatus != runtime.FinalSuccess {
+			t.Fatalf("%s: expected success, got %q", runID, res.FinalStatus)
+		}
+		return res
+	}
+
+	run("cache-run-1")
+	res := run("cache-run-2")
+
+	b, _ := os.ReadFile(counter)
+	if n := strings.Count(string(b), "run"); n != 1 {
+		t.Fatalf("cached node executed %d times, want 1", n)
+	}
+	if got := runCmdOut(t, repo, "git", "show", res.FinalCommitSHA+":artifact.txt"); strings.TrimSpace(got) != "built" {
+		t.Fatalf("artifact.txt in second run = %q, want restored content", got)
+	}
+
+	nodes, err := rdb.GetNodeExecutions("cache-run-2")
+	if err != nil {
+		t.Fatalf("GetNodeExecutions: %v", err)
+	}
+	statuses := map[string]string{}
+	for _, n := range nodes {
+		statuses[n.NodeID] = n.Status
+	}
+	if statuses["build"] != "cached" || statuses["verify"] != "success" {
+		t.Fatalf("node statuses = %v, want build=cached verify=success", statuses)
+	}
+	progress, _ := os.ReadFile(filepath.Join(res.LogsRoot, "progress.ndjson"))
+	if !strings.Contains(string(progress), `"event":"stage_cached"`) {
+		t.Fatalf("progress.ndjson has no stage_cached event:\n%s", progress)
+	}
+}
+
+// TestNodeCacheKey_TracksResolvedMCPServers checks that the key follows the
+// MCP server config the session would get, not just the node's selection.
+func TestNodeCacheKey_TracksResolvedMCPServers(t *testing.T) {
+	rdb, err := rundb.Open(filepath.Join(t.TempDir(), "runs.db"))
+	if err != nil {
+		t.Fatalf("Open rundb: %v", err)
+	}
+	defer rdb.Close()
+
+	g := model.NewGraph("g")
+	n := model.NewNode("impl")
+	n.Attrs["shape"] = "box"
+	n.Attrs["cache"] = "true"
+	if err := g.AddNode(n); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &RunConfigFile{MCPServers: map[string]MCPServerRunConfig{
+		"docs": {Command: []string{"docs-mcp", "--v1"}},
+	}}
+	e := &Engine{
+		Graph:       g,
+		RunDB:       rdb,
+		GitOps:      &testGitOps{},
+		WorktreeDir: initTestRepo(t),
+		LogsRoot:    t.TempDir(),
+		Context:     runtime.NewContext(),
+		RunConfig:   cfg,
+	}
+
+	before, _ := e.nodeCacheKey(n)
+	if before == "" {
+		t.Fatal("expected a cache key")
+	}
+	cfg.MCPServers["docs"] = MCPServerRunConfig{Command: []string{"docs-mcp", "--v2"}}
+	if after, _ := e.nodeCacheKey(n); after == "" || after == before {
+		t.Fatalf("key did not change with the server command: %q -> %q", before, after)
+	}
+
+	n.Attrs["mcp_servers"] = "wiki"
+	if key, _ := e.nodeCacheKey(n); key != "" {
+		t.Fatalf("unresolvable mcp_servers should disable caching, got %q", key)
+	}
+}
@@ -57,7 +57,7 @@ func classifyJoinMergeMode(g *model.Graph, joinID string) string {
 	}
 	t := strings.TrimSpace(joinNode.TypeOverride())
 	if t == "" {
-		t = shapeToType(joinNode.Shape())
+		t = model.ShapeType(joinNode.Shape())
 	}
 	if t == "parallel.fan_in" {
 		return parallelMergeModeFanIn
@@ -439,6 +439,9 @@ func (h *ParallelHandler) runBranch(ctx context.Context, exec *Execution, parall
 		InputInferenceCache:        copyInferredReferenceCache(exec.Engine.InputInferenceCache),
 		InputSourceTargetMap:       copyStringStringMap(exec.Engine.InputSourceTargetMap),
 	}
+	branchEng.telemetry = exec.Engine.telemetry
+	branchEng.failureClassifier = exec.Engine.failureClassifier
+	branchEng.agentTools = exec.Engine.agentTools
 	if exec.Engine.CXDB != nil {
 		if fork, err := exec.Engine.CXDB.ForkFromHead(ctx); err == nil {
 			branchEng.CXDB = fork
@@ -1152,7 +1155,7 @@ func bfsFanInDistances(g *model.Graph, start string) map[string]int {
 		queue = queue[1:]
 
 		n := g.Nodes[it.id]
-		if n != nil && shapeToType(n.Shape()) == "parallel.fan_in" {
+		if n != nil && model.ShapeType(n.Shape()) == "parallel.fan_in" {
 			// Record the first (shortest) distance.
 			if _, exists := out[it.id]; !exists {
 				out[it.id] = it.dist
@@ -54,6 +54,7 @@ func (e *Engine) appendProgressImpl(ev map[string]any, updateStallTimer bool) {
 		ev["run_id"] = e.Options.RunID
 	}
 	sinkEvent := copyMap(ev)
+	e.telemetry.observe(ev)
 	if logsRoot == "" {
 		if sink != nil {
 			sink(sinkEvent)
@@ -12,6 +12,7 @@ import (
 	"time"
 
 
+	"github.com/danshapiro/kilroy/internal/attractor/model"
 	"github.com/danshapiro/kilroy/internal/attractor/modeldb"
 	"github.com/danshapiro/kilroy/internal/attractor/runtime"
 	"github.com/danshapiro/kilroy/internal/cxdb"
@@ -26,6 +27,8 @@ type manifest struct {
 	RunConfigPath string            `json:"run_config_path"`
 	ForceModels   map[string]string `json:"force_models"`
 
+	ModelSelection map[string]AutoModelSelection `json:"model_selection"`
+
 	ModelDB struct {
 		OpenRouterModelInfoPath   string `json:"openrouter_model_info_path"`
 		OpenRouterModelInfoSHA256 string `json:"openrouter_model_info_sha256"`
@@ -44,6 +47,11 @@ type ResumeOverrides struct {
 	CXDBHTTPBaseURL string
 	CXDBContextID   string
 	GitOps          GitOps
+	RunDB           RunDBWriter
+
+	// fork is set when the logs root was materialized by Fork; the run is
+	// new, so it is registered (rundb, CXDB) rather than re-attached.
+	fork *forkOrigin
 }
 
 // Resume continues an existing run from {logs_root}/checkpoint.json.
@@ -73,6 +81,12 @@ func resumeFromLogsRoot(ctx context.Context, logsRoot string, ov ResumeOverrides
 		eng           *Engine
 		releaseLock   func()
 	)
+	// Registered first so it runs last, after the terminal progress event.
+	defer func() {
+		if eng != nil {
+			eng.stopTelemetry()
+		}
+	}()
 	defer func() {
 		if err != nil && !isRunOwnershipConflict(err) {
 			if eng != nil {
@@ -117,7 +131,7 @@ func resumeFromLogsRoot(ctx context.Context, logsRoot string, ov ResumeOverrides
 	if err != nil {
 		return nil, err
 	}
-	g, _, err := Prepare(dotSource)
+	g, _, err := PrepareWithOptions(dotSource, PrepareOptions{ModelSelections: m.ModelSelection})
 	if err != nil {
 		return nil, err
 	}
@@ -159,6 +173,14 @@ func resumeFromLogsRoot(ctx context.Context, logsRoot string, ov ResumeOverrides
 			return nil, err
 		}
 		catalog = cat
+		// Nodes the manifest has no selection for (a fork onto an edited
+		// graph) resolve against the run's snapshot.
+		if diags := resolveAutoModels(g, catalog, nil); len(diags) > 0 {
+			return nil, fmt.Errorf("resume: %s: %s", diags[0].NodeID, diags[0].Message)
+		}
+		if err := unresolvedAutoModels(g); err != nil {
+			return nil, fmt.Errorf("resume: %w", err)
+		}
 		backend, err = newResumeAgentBackend(cfg, catalog)
 		if err != nil {
 			return nil, err
@@ -208,11 +230,24 @@ func resumeFromLogsRoot(ctx context.Context, logsRoot string, ov ResumeOverrides
 			if _, err := cxdbClient.PublishRegistryBundle(ctx, bundleID, bundle); err != nil {
 				return nil, err
 			}
-			ci, err := cxdbClient.GetContext(ctx, contextID)
-			if err != nil {
-				return nil, err
+			if ov.fork != nil {
+				// Branch the parent's context at the fork node's turn so the
+				// child shares history up to that point and diverges after it.
+				parent := NewCXDBSink(cxdbClient, bin, m.RunID, contextID, ov.fork.CXDBTurnID, bundleID)
+				sink, err = parent.ForkFromHead(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("fork: cxdb context: %w", err)
+				}
+				if err := updateManifestCXDB(logsRoot, sink.ContextID, sink.HeadTurnID); err != nil {
+					return nil, err
+				}
+			} else {
+				ci, err := cxdbClient.GetContext(ctx, contextID)
+				if err != nil {
+					return nil, err
+				}
+				sink = NewCXDBSink(cxdbClient, bin, m.RunID, contextID, ci.HeadTurnID, bundleID)
 			}
-			sink = NewCXDBSink(cxdbClient, bin, m.RunID, contextID, ci.HeadTurnID, bundleID)
 		}
 	}
 
@@ -226,6 +261,10 @@ func resumeFromLogsRoot(ctx context.Context, logsRoot string, ov ResumeOverrides
 		RequireClean:    resolveRequireClean(cfg),
 		ForceModels:     normalizeForceModels(copyStringStringMap(m.ForceModels)),
 		GitOps:          ov.GitOps,
+		RunDB:           ov.RunDB,
+	}
+	if ov.fork != nil {
+		opts.Invocation = ov.fork.Invocation
 	}
 	if err := opts.applyDefaults(); err != nil {
 		return nil, err
@@ -277,6 +316,13 @@ func resumeFromLogsRoot(ctx context.Context, logsRoot string, ov ResumeOverrides
 	eng.loopFailureSignatures = restoreLoopFailureSignatures(cp)
 	eng.baseSHA = cp.GitCommitSHA
 	eng.lastCheckpointSHA = cp.GitCommitSHA
+	eng.startTelemetry()
+	if err := eng.initFailureClassifier(); err != nil {
+		return nil, err
+	}
+	if err := eng.initAgentTools(); err != nil {
+		return nil, err
+	}
 	if cp != nil && cp.Extra != nil {
 		// Metaspec/attractor-spec: if the previous hop used `full` fidelity, degrade to
 		// summary:high for the first resumed node unless exact session restore is supported.
@@ -300,6 +346,20 @@ func resumeFromLogsRoot(ctx context.Context, logsRoot string, ov ResumeOverrides
 		}
 	}
 
+	if ov.fork != nil {
+		eng.rundbRecordRunStart()
+		eng.rundbRecordRunFork(ov.fork.ParentRunID, ov.fork.NodeID)
+		if err := eng.cxdbRunStarted(ctx, cp.GitCommitSHA); err != nil {
+			return nil, err
+		}
+		eng.appendProgress(map[string]any{
+			"event":          "run_forked",
+			"parent_run_id":  ov.fork.ParentRunID,
+			"node_id":        ov.fork.NodeID,
+			"git_commit_sha": cp.GitCommitSHA,
+		})
+	}
+
 	// Re-run setup commands (e.g., npm install) since the recreated worktree
 	// loses untracked artifacts produced by the original setup.
 	if err := eng.executeSetupCommands(ctx); err != nil {
@@ -345,7 +405,7 @@ func resumeFromLogsRoot(ctx context.Context, logsRoot string, ov ResumeOverrides
 	if lastNode := eng.Graph.Nodes[lastNodeID]; lastNode != nil {
 		t := strings.TrimSpace(lastNode.TypeOverride())
 		if t == "" {
-			t = shapeToType(lastNode.Shape())
+			t = model.ShapeType(lastNode.Shape())
 		}
 		if t == "parallel" {
 			join := strings.TrimSpace(eng.Context.GetString("parallel.join_node", ""))
@@ -405,7 +465,8 @@ func resumeFromLogsRoot(ctx context.Context, logsRoot string, ov ResumeOverrides
 		}
 	}
 
-	nextHop, err := resolveNextHop(eng.Graph, lastNodeID, lastOutcome, eng.Context, classifyFailureClass(lastOutcome), eng.appendProgress)
+	lastFailureClass, _ := eng.classifyFailure(lastNodeID, lastOutcome)
+	nextHop, err := resolveNextHop(eng.Graph, lastNodeID, lastOutcome, eng.Context, lastFailureClass, eng.appendProgress)
 	if err != nil {
 		return nil, err
 	}
@@ -415,7 +476,7 @@ func resumeFromLogsRoot(ctx context.Context, logsRoot string, ov ResumeOverrides
 			// Skip for fan-in nodes with deterministic failures — resolveNextHop
 			// already considered retry_target and intentionally blocked it.
 			fanInDeterministic := isFanInFailureLike(eng.Graph, lastNodeID, lastOutcome.Status) &&
-				normalizedFailureClassOrDefault(classifyFailureClass(lastOutcome)) == failureClassDeterministic
+				normalizedFailureClassOrDefault(lastFailureClass) == failureClassDeterministic
 			retryTarget := resolveRetryTarget(eng.Graph, lastNodeID)
 			if retryTarget != "" && !fanInDeterministic {
 				eng.appendProgress(map[string]any{
@@ -13,4 +13,21 @@ type RunDBWriter interface {
 	RecordProviderSelection(runID, nodeID string, attempt int, provider, model, backend string) error
 	RecordNodeDiff(runID, nodeID string, attempt int, beforeSHA, afterSHA string, filesChanged, insertions, deletions int) error
 	RecordNodeArtifact(nodeExecID int64, name, contentType string, content []byte, truncated bool) error
+	RecordRunFork(runID, parentRunID, nodeID string) error
+}
+
+// NodeCacheStore indexes node results for opt-in memoization (cache=true).
+// It is optional: the engine type-asserts RunDB against it and runs every
+// node normally when the database does not provide it.
+type NodeCacheStore interface {
+	RecordNodeCache(cacheKey, runID, nodeID, treeSHA, afterSHA string, outcomeJSON []byte) error
+	// LookupNodeCache returns the newest entry for cacheKey written by a run
+	// other than excludeRunID. runID is empty on a miss.
+	LookupNodeCache(cacheKey, excludeRunID string) (runID, afterSHA string, outcomeJSON []byte, err error)
+}
+
+// NodeBranchRecorder tags node executions with the concurrent branch they
+// ran in. Like NodeCacheStore it is optional.
+type NodeBranchRecorder interface {
+	RecordNodeBranch(id int64, branchID string) error
 }
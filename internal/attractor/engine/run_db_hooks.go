@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/danshapiro/kilroy/internal/attractor/agents/agentlog"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
 	"github.com/danshapiro/kilroy/internal/attractor/runtime"
 )
 
@@ -52,6 +54,15 @@ func (e *Engine) rundbRecordRunComplete(status runtime.FinalStatus, failureReaso
 	}
 }
 
+func (e *Engine) rundbRecordRunFork(parentRunID, nodeID string) {
+	if e == nil || e.RunDB == nil {
+		return
+	}
+	if err := e.RunDB.RecordRunFork(e.Options.RunID, parentRunID, nodeID); err != nil {
+		e.Warn("rundb: record run fork: " + err.Error())
+	}
+}
+
 func (e *Engine) rundbRecordNodeStart(nodeID string, attempt int, handlerType string) int64 {
 	if e == nil || e.RunDB == nil {
 		return 0
@@ -64,6 +75,21 @@ func (e *Engine) rundbRecordNodeStart(nodeID string, attempt int, handlerType st
 	return id
 }
 
+// rundbRecordNodeBranch tags execution dbID with the concurrent branch that
+// ran it, when the database supports it.
+func (e *Engine) rundbRecordNodeBranch(dbID int64, branchID string) {
+	if e == nil || e.RunDB == nil || dbID == 0 {
+		return
+	}
+	rec, ok := e.RunDB.(NodeBranchRecorder)
+	if !ok {
+		return
+	}
+	if err := rec.RecordNodeBranch(dbID, branchID); err != nil {
+		e.Warn("rundb: record node branch: " + err.Error())
+	}
+}
+
 func (e *Engine) rundbRecordNodeComplete(dbID int64, out runtime.Outcome) {
 	if e == nil || e.RunDB == nil || dbID == 0 {
 		return
@@ -75,7 +101,7 @@ func (e *Engine) rundbRecordNodeComplete(dbID int64, out runtime.Outcome) {
 		}
 	}
 	if err := e.RunDB.RecordNodeComplete(
-		dbID, string(out.Status), out.FailureReason, failureClass,
+		dbID, reportedStageStatus(out), out.FailureReason, failureClass,
 		out.PreferredLabel, out.Notes, out.ContextUpdates,
 	); err != nil {
 		e.Warn("rundb: record node complete: " + err.Error())
@@ -99,8 +125,11 @@ var artifactCaptureList = []struct {
 	{"tool_timing.json", "application/json"},
 	{"tool_invocation.json", "application/json"},
 	{"tmux_command.txt", "text/plain"},
+	{"conversation.jsonl", "application/x-ndjson"},
+	{agentlog.AiderHistoryFile, "text/markdown"},
 	{"inputs_manifest.json", "application/json"},
 	{"provider_used.json", "application/json"},
+	{"plan.json", "application/json"},
 	{"panic.txt", "text/plain"},
 }
 
@@ -341,5 +370,5 @@ func resolvedHandlerTypeName(e *Engine, nodeID string) string {
 	if t := strings.TrimSpace(node.TypeOverride()); t != "" {
 		return t
 	}
-	return shapeToType(node.Shape())
+	return model.ShapeType(node.Shape())
 }
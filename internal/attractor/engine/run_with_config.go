@@ -154,6 +154,9 @@ func bootstrapRunWithConfig(ctx context.Context, dotSource []byte, cfg *RunConfi
 	if err != nil {
 		return nil, err
 	}
+	if err := unresolvedAutoModels(g); err != nil {
+		return nil, err
+	}
 
 	// Ensure backend is specified for each provider used by the graph.
 	// Use the handler registry to identify nodes that require an LLM provider
@@ -347,6 +350,11 @@ func bootstrapRunWithConfig(ctx context.Context, dotSource []byte, cfg *RunConfi
 		modelCatalogPath = ""
 	}
 	catalogChecks, catalogErr := validateProviderModelPairs(g, runtimes, catalog, opts)
+	if catalogErr == nil {
+		var visionChecks []providerPreflightCheck
+		visionChecks, catalogErr = validateAttachmentVision(g, runtimes, catalog, opts)
+		catalogChecks = append(catalogChecks, visionChecks...)
+	}
 	if catalogErr != nil {
 		report := &providerPreflightReport{
 			GeneratedAt:         time.Now().UTC().Format(time.RFC3339Nano),
@@ -495,6 +503,61 @@ func validateProviderModelPairs(g *model.Graph, runtimes map[string]ProviderRunt
 	return checks, nil
 }
 
+// validateAttachmentVision fails when a node attaches images or PDFs but
+// its model lacks supports_vision in the catalog. Models missing from the
+// catalog only warn.
+func validateAttachmentVision(g *model.Graph, runtimes map[string]ProviderRuntime, catalog *modeldb.Catalog, opts RunOptions) ([]providerPreflightCheck, error) {
+	if g == nil || catalog == nil {
+		return nil, nil
+	}
+	var checks []providerPreflightCheck
+	for _, id := range g.AllNodeIDs() {
+		n := g.Nodes[id]
+		patterns := nodeAttachPatterns(n)
+		if !attachNeedsVision(patterns) {
+			continue
+		}
+		provider := normalizeProviderKey(n.Attr("llm_provider", ""))
+		modelID := modelIDForNode(n)
+		if forcedID, forced := forceModelForProvider(opts.ForceModels, provider); forced {
+			modelID = forcedID
+		}
+		if _, ok := runtimes[provider]; !ok || modelID == "" {
+			continue
+		}
+		details := map[string]any{"node": n.ID, "model": modelID, "attach": strings.Join(patterns, ",")}
+		_, entry, found := modeldb.FindProviderModel(catalog, provider, modelID)
+		switch {
+		case !found:
+			checks = append(checks, providerPreflightCheck{
+				Name:     "attachment_vision",
+				Provider: provider,
+				Status:   preflightStatusWarn,
+				Message:  fmt.Sprintf("node %s attaches images/PDFs but model %s is not in the catalog; vision support unverified", n.ID, modelID),
+				Details:  details,
+			})
+		case !entry.SupportsVision:
+			checks = append(checks, providerPreflightCheck{
+				Name:     "attachment_vision",
+				Provider: provider,
+				Status:   preflightStatusFail,
+				Message:  fmt.Sprintf("node %s attaches images/PDFs but model %s does not support vision", n.ID, modelID),
+				Details:  details,
+			})
+			return checks, fmt.Errorf("preflight: node %s attaches images/PDFs but model %s does not support vision", n.ID, modelID)
+		default:
+			checks = append(checks, providerPreflightCheck{
+				Name:     "attachment_vision",
+				Provider: provider,
+				Status:   preflightStatusPass,
+				Message:  fmt.Sprintf("node %s: model %s supports vision", n.ID, modelID),
+				Details:  details,
+			})
+		}
+	}
+	return checks, nil
+}
+
 func loadCatalogForRun(path string) (*modeldb.Catalog, error) {
 	return modeldb.LoadCatalogFromOpenRouterJSON(path)
 }
@@ -59,6 +59,7 @@ func looksLikeRustStage(node *model.Node) bool {
 		node.ID,
 		node.Attr("prompt", ""),
 		node.Attr("tool_command", ""),
+		node.Attr("test_command", ""),
 	}, "\n"))
 	for _, hint := range []string{"cargo", "rust", "wasm", "wasm32", "cargo.toml"} {
 		if strings.Contains(text, hint) {
@@ -88,7 +89,7 @@ func resolveRustSandboxPreflightManifests(node *model.Node, worktreeDir string)
 		}
 	}
 
-	for _, text := range []string{node.Attr("prompt", ""), node.Attr("tool_command", "")} {
+	for _, text := range []string{node.Attr("prompt", ""), node.Attr("tool_command", ""), node.Attr("test_command", "")} {
 		matches := rustSandboxPathHintRE.FindAllStringSubmatch(text, -1)
 		for _, match := range matches {
 			if len(match) < 2 {
@@ -0,0 +1,487 @@
+// Graph simulation: walk a pipeline's control flow with scripted outcomes.
+// No LLMs, tools, git, or CXDB are involved — every work node returns the
+// outcome the script dictates, while routing, retries, loops, goal gates and
+// fan-out are handled by the real engine so the simulated path matches what a
+// live run would take.
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+// defaultSimulationMaxSteps caps handler executions so a script that never
+// satisfies an exit condition cannot spin forever.
+const defaultSimulationMaxSteps = 1000
+
+// SimulationScript declares, per node and per execution, the outcome the
+// simulator returns. Entries are consumed in order on each execution of the
+// node (retries included); the last entry repeats once the list is exhausted.
+type SimulationScript struct {
+	// Default is returned for work nodes that have no entry in Nodes.
+	// When nil, such nodes succeed.
+	Default *SimulatedOutcome `json:"default,omitempty" yaml:"default,omitempty"`
+
+	Nodes map[string][]SimulatedOutcome `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+
+	// Expect holds optional assertions checked after the walk completes.
+	Expect *SimulationExpectations `json:"expect,omitempty" yaml:"expect,omitempty"`
+
+	// MaxSteps overrides the handler-execution cap (default 1000).
+	MaxSteps int `json:"max_steps,omitempty" yaml:"max_steps,omitempty"`
+}
+
+// SimulatedOutcome is one scripted node result.
+type SimulatedOutcome struct {
+	Status           string         `json:"status,omitempty" yaml:"status,omitempty"`
+	PreferredLabel   string         `json:"preferred_label,omitempty" yaml:"preferred_label,omitempty"`
+	SuggestedNextIDs []string       `json:"suggested_next_ids,omitempty" yaml:"suggested_next_ids,omitempty"`
+	ContextUpdates   map[string]any `json:"context_updates,omitempty" yaml:"context_updates,omitempty"`
+	FailureReason    string         `json:"failure_reason,omitempty" yaml:"failure_reason,omitempty"`
+	FailureClass     string         `json:"failure_class,omitempty" yaml:"failure_class,omitempty"`
+	// Files are written (relative to the simulated workspace) before the
+	// outcome is returned, so loop_until_file style conditions can fire.
+	Files map[string]string `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+// SimulationExpectations are regression assertions for routing logic.
+type SimulationExpectations struct {
+	// Path is the exact node visit sequence (retries of a visit collapsed).
+	Path []string `json:"path,omitempty" yaml:"path,omitempty"`
+	// FinalStatus is "success" or "fail".
+	FinalStatus string `json:"final_status,omitempty" yaml:"final_status,omitempty"`
+	// Visits maps node ID to the exact number of visits.
+	Visits map[string]int `json:"visits,omitempty" yaml:"visits,omitempty"`
+	// Retries maps node ID to the exact number of retry attempts.
+	Retries map[string]int `json:"retries,omitempty" yaml:"retries,omitempty"`
+}
+
+// SimulateOptions tunes a simulation run.
+type SimulateOptions struct {
+	// GraphDir resolves prompt_file attributes, as for real runs.
+	GraphDir string
+	// Inputs are injected into context exactly like --input for real runs.
+	Inputs map[string]any
+}
+
+// SimulatedExecution is one handler invocation observed during simulation.
+type SimulatedExecution struct {
+	NodeID   string `json:"node_id"`
+	Attempt  int    `json:"attempt"`
+	Status   string `json:"status"`
+	Scripted bool   `json:"scripted"`
+}
+
+// SimulatedTransition is one edge decision observed during simulation.
+type SimulatedTransition struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Method string `json:"method,omitempty"`
+}
+
+// SimulationReport summarizes a simulated walk of the graph.
+type SimulationReport struct {
+	Graph          string                `json:"graph"`
+	Path           []string              `json:"path"`
+	Executions     []SimulatedExecution  `json:"executions"`
+	Transitions    []SimulatedTransition `json:"transitions,omitempty"`
+	Visits         map[string]int        `json:"visits"`
+	Retries        map[string]int        `json:"retries,omitempty"`
+	LoopIterations map[string]int        `json:"loop_iterations,omitempty"`
+	LoopRestarts   int                   `json:"loop_restarts,omitempty"`
+	FinalStatus    string                `json:"final_status"`
+	FailureReason  string                `json:"failure_reason,omitempty"`
+	// ExpectationFailures lists every assertion from the script that did not hold.
+	ExpectationFailures []string `json:"expectation_failures,omitempty"`
+}
+
+// Passed reports whether every scripted expectation held.
+func (r *SimulationReport) Passed() bool {
+	return r != nil && len(r.ExpectationFailures) == 0
+}
+
+// LoadSimulationScript reads a YAML (or .json, which YAML accepts)
+// simulation script. Unknown fields are rejected so typos in node keys
+// surface immediately.
+func LoadSimulationScript(path string) (*SimulationScript, error) {
+	var script SimulationScript
+	if err := LoadYAMLFile(path, &script); err != nil {
+		return nil, err
+	}
+	return &script, nil
+}
+
+// validateAgainst reports script entries that reference unknown nodes or
+// carry unparseable statuses.
+func (s *SimulationScript) validateAgainst(g *model.Graph) error {
+	if s == nil {
+		return nil
+	}
+	var problems []string
+	check := func(where string, o SimulatedOutcome) {
+		if strings.TrimSpace(o.Status) == "" {
+			return
+		}
+		if _, err := runtime.ParseStageStatus(o.Status); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", where, err))
+		}
+	}
+	if s.Default != nil {
+		check("default", *s.Default)
+	}
+	ids := make([]string, 0, len(s.Nodes))
+	for id := range s.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if g.Nodes[id] == nil {
+			problems = append(problems, fmt.Sprintf("nodes.%s: node not found in graph", id))
+			continue
+		}
+		for i, o := range s.Nodes[id] {
+			check(fmt.Sprintf("nodes.%s[%d]", id, i), o)
+		}
+	}
+	if s.Expect != nil {
+		for _, id := range s.Expect.Path {
+			if g.Nodes[id] == nil {
+				problems = append(problems, fmt.Sprintf("expect.path: node %q not found in graph", id))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid simulation script: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Simulate walks the graph with the real engine, substituting scripted
+// outcomes for every work node. Git, CXDB, the run database and providers are
+// all disabled; logs are written to a scratch directory that is removed
+// afterwards.
+func Simulate(ctx context.Context, dotSource []byte, script *SimulationScript, opts SimulateOptions) (*SimulationReport, error) {
+	if script == nil {
+		script = &SimulationScript{}
+	}
+	core := NewCoreRegistry()
+	g, _, err := PrepareWithOptions(dotSource, PrepareOptions{
+		GraphDir:   opts.GraphDir,
+		KnownTypes: append(core.KnownTypes(), "agent", "wait.human", "stack.manager_loop"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := script.validateAgainst(g); err != nil {
+		return nil, err
+	}
+
+	scratch, err := os.MkdirTemp("", "kilroy-simulate-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(scratch) }()
+
+	simCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	rec := newSimulationRecorder(script, cancel)
+	reg := &HandlerRegistry{handlers: map[string]Handler{}}
+	for t, h := range core.handlers {
+		reg.Register(t, &simulatedHandler{rec: rec, inner: h})
+	}
+	scripted := &simulatedHandler{rec: rec}
+	for _, t := range []string{"agent", "tool", "wait.human", "stack.manager_loop"} {
+		reg.Register(t, scripted)
+	}
+	reg.SetDefault(scripted)
+
+	runOpts := RunOptions{
+		RunID:               "simulate",
+		LogsRoot:            filepath.Join(scratch, "logs"),
+		WorktreeDir:         filepath.Join(scratch, "workspace"),
+		DisableCXDB:         true,
+		DisableRetryBackoff: true,
+		SkipPreflight:       true,
+		Inputs:              opts.Inputs,
+		GraphDir:            opts.GraphDir,
+		ProgressSink:        rec.observe,
+	}
+	if err := runOpts.applyDefaults(); err != nil {
+		return nil, err
+	}
+	eng := newBaseEngine(g, dotSource, runOpts)
+	eng.Registry = reg
+	eng.AgentBackend = &SimulatedAgentBackend{}
+
+	res, runErr := eng.run(simCtx)
+	report := rec.report(g.Name)
+	switch {
+	case runErr != nil:
+		report.FinalStatus = string(runtime.FinalFail)
+		report.FailureReason = runErr.Error()
+		if cause := context.Cause(simCtx); cause != nil && cause != context.Canceled {
+			report.FailureReason = cause.Error()
+		}
+	case res != nil:
+		report.FinalStatus = string(res.FinalStatus)
+	}
+	if script.Expect != nil {
+		report.ExpectationFailures = checkSimulationExpectations(script.Expect, report)
+	}
+	return report, nil
+}
+
+// simulationRecorder hands out scripted outcomes and observes engine progress
+// to reconstruct the path, retries and loop iterations.
+type simulationRecorder struct {
+	script   *SimulationScript
+	maxSteps int
+	cancel   context.CancelCauseFunc
+
+	mu             sync.Mutex
+	calls          map[string]int
+	pendingAttempt map[string]int
+	steps          int
+	path           []string
+	executions     []SimulatedExecution
+	transitions    []SimulatedTransition
+	loopIterations map[string]int
+	loopRestarts   int
+}
+
+func newSimulationRecorder(script *SimulationScript, cancel context.CancelCauseFunc) *simulationRecorder {
+	maxSteps := script.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultSimulationMaxSteps
+	}
+	return &simulationRecorder{
+		script:         script,
+		maxSteps:       maxSteps,
+		cancel:         cancel,
+		calls:          map[string]int{},
+		pendingAttempt: map[string]int{},
+		loopIterations: map[string]int{},
+	}
+}
+
+// observe is installed as the engine progress sink. Attempt numbers arrive via
+// stage_attempt_start immediately before the handler executes; branch events
+// are unwrapped so parallel branches are tracked the same way.
+func (r *simulationRecorder) observe(ev map[string]any) {
+	name := eventFieldString(ev, "event")
+	nodeKey, attemptKey, fromKey, toKey := "node_id", "attempt", "from_node", "to_node"
+	if name == "branch_progress" {
+		name = eventFieldString(ev, "branch_event")
+		nodeKey, attemptKey, fromKey, toKey = "branch_node_id", "branch_attempt", "branch_from_node", "branch_to_node"
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch name {
+	case "stage_attempt_start":
+		if id := eventFieldString(ev, nodeKey); id != "" {
+			if n, ok := anyToNonNegativeInt(ev[attemptKey]); ok {
+				r.pendingAttempt[id] = n
+			}
+		}
+	case "edge_selected":
+		r.transitions = append(r.transitions, SimulatedTransition{
+			From:   eventFieldString(ev, fromKey),
+			To:     eventFieldString(ev, toKey),
+			Method: eventFieldString(ev, "selection_method"),
+		})
+	case "loop_iteration":
+		if id := eventFieldString(ev, "loop_id"); id != "" {
+			if n, ok := anyToNonNegativeInt(ev["iteration"]); ok && n > r.loopIterations[id] {
+				r.loopIterations[id] = n
+			}
+		}
+	case "loop_restart":
+		r.loopRestarts++
+	}
+}
+
+// next records the execution and returns the scripted outcome for the node,
+// or ok=false when the node has no script entry.
+func (r *simulationRecorder) next(nodeID string) (SimulatedOutcome, int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps++
+	if r.steps > r.maxSteps {
+		r.cancel(fmt.Errorf("simulation aborted: exceeded %d node executions (set max_steps to raise)", r.maxSteps))
+	}
+	attempt := r.pendingAttempt[nodeID]
+	delete(r.pendingAttempt, nodeID)
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt == 1 {
+		r.path = append(r.path, nodeID)
+	}
+	entries := r.script.Nodes[nodeID]
+	if len(entries) == 0 {
+		return SimulatedOutcome{}, attempt, false
+	}
+	idx := r.calls[nodeID]
+	r.calls[nodeID]++
+	if idx >= len(entries) {
+		idx = len(entries) - 1
+	}
+	return entries[idx], attempt, true
+}
+
+func (r *simulationRecorder) recordExecution(nodeID string, attempt int, status runtime.StageStatus, scripted bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.executions = append(r.executions, SimulatedExecution{
+		NodeID:   nodeID,
+		Attempt:  attempt,
+		Status:   string(status),
+		Scripted: scripted,
+	})
+}
+
+func (r *simulationRecorder) report(graphName string) *SimulationReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := &SimulationReport{
+		Graph:       graphName,
+		Path:        append([]string{}, r.path...),
+		Executions:  append([]SimulatedExecution{}, r.executions...),
+		Transitions: append([]SimulatedTransition{}, r.transitions...),
+		Visits:      map[string]int{},
+		Retries:     map[string]int{},
+	}
+	for _, id := range r.path {
+		rep.Visits[id]++
+	}
+	for _, ex := range r.executions {
+		if ex.Attempt > 1 {
+			rep.Retries[ex.NodeID]++
+		}
+	}
+	if len(r.loopIterations) > 0 {
+		rep.LoopIterations = map[string]int{}
+		for k, v := range r.loopIterations {
+			rep.LoopIterations[k] = v
+		}
+	}
+	rep.LoopRestarts = r.loopRestarts
+	return rep
+}
+
+// simulatedHandler returns scripted outcomes. When wrapping a routing handler
+// (inner != nil) it defers to the real implementation unless the script names
+// the node explicitly, so conditionals, fan-out and loops behave as in a run.
+type simulatedHandler struct {
+	rec   *simulationRecorder
+	inner Handler
+}
+
+// SkipRetry forwards the wrapped handler's retry semantics.
+func (h *simulatedHandler) SkipRetry() bool {
+	if se, ok := h.inner.(SingleExecutionHandler); ok {
+		return se.SkipRetry()
+	}
+	return false
+}
+
+func (h *simulatedHandler) Execute(ctx context.Context, exec *Execution, node *model.Node) (runtime.Outcome, error) {
+	if err := ctx.Err(); err != nil {
+		return runtime.Outcome{}, err
+	}
+	scripted, attempt, ok := h.rec.next(node.ID)
+	if !ok {
+		if h.inner != nil {
+			out, err := h.inner.Execute(ctx, exec, node)
+			h.rec.recordExecution(node.ID, attempt, out.Status, false)
+			return out, err
+		}
+		if h.rec.script.Default != nil {
+			scripted = *h.rec.script.Default
+		}
+	}
+	out, err := scripted.toOutcome(node.ID)
+	if err != nil {
+		return runtime.Outcome{}, err
+	}
+	for rel, content := range scripted.Files {
+		path := filepath.Join(exec.WorktreeDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return runtime.Outcome{}, err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return runtime.Outcome{}, err
+		}
+	}
+	h.rec.recordExecution(node.ID, attempt, out.Status, true)
+	return out, nil
+}
+
+func (o SimulatedOutcome) toOutcome(nodeID string) (runtime.Outcome, error) {
+	status := runtime.StatusSuccess
+	if strings.TrimSpace(o.Status) != "" {
+		st, err := runtime.ParseStageStatus(o.Status)
+		if err != nil {
+			return runtime.Outcome{}, err
+		}
+		status = st
+	}
+	out := runtime.Outcome{
+		Status:           status,
+		PreferredLabel:   o.PreferredLabel,
+		SuggestedNextIDs: append([]string{}, o.SuggestedNextIDs...),
+		ContextUpdates:   map[string]any{},
+		FailureReason:    o.FailureReason,
+		Notes:            "simulated outcome",
+	}
+	for k, v := range o.ContextUpdates {
+		out.ContextUpdates[k] = v
+	}
+	if (status == runtime.StatusFail || status == runtime.StatusRetry) && strings.TrimSpace(out.FailureReason) == "" {
+		out.FailureReason = fmt.Sprintf("simulated %s for %s", status, nodeID)
+	}
+	if cls := strings.TrimSpace(o.FailureClass); cls != "" {
+		out.Meta = map[string]any{"failure_class": cls}
+	}
+	return out, nil
+}
+
+func checkSimulationExpectations(exp *SimulationExpectations, rep *SimulationReport) []string {
+	var failures []string
+	if len(exp.Path) > 0 && strings.Join(exp.Path, " -> ") != strings.Join(rep.Path, " -> ") {
+		failures = append(failures, fmt.Sprintf("path: expected %s, got %s",
+			strings.Join(exp.Path, " -> "), strings.Join(rep.Path, " -> ")))
+	}
+	if want := strings.TrimSpace(exp.FinalStatus); want != "" && !strings.EqualFold(want, rep.FinalStatus) {
+		failures = append(failures, fmt.Sprintf("final_status: expected %s, got %s", want, rep.FinalStatus))
+	}
+	for _, id := range sortedIntMapKeys(exp.Visits) {
+		if got := rep.Visits[id]; got != exp.Visits[id] {
+			failures = append(failures, fmt.Sprintf("visits.%s: expected %d, got %d", id, exp.Visits[id], got))
+		}
+	}
+	for _, id := range sortedIntMapKeys(exp.Retries) {
+		if got := rep.Retries[id]; got != exp.Retries[id] {
+			failures = append(failures, fmt.Sprintf("retries.%s: expected %d, got %d", id, exp.Retries[id], got))
+		}
+	}
+	return failures
+}
+
+func sortedIntMapKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -112,7 +112,7 @@ func runSubgraphUntil(ctx context.Context, eng *Engine, startNodeID, stopNodeID
 		eng.Context.Set("outcome", string(out.Status))
 		eng.Context.Set("preferred_label", out.PreferredLabel)
 		eng.Context.Set("failure_reason", out.FailureReason)
-		failureClass := classifyFailureClass(out)
+		failureClass, _ := eng.classifyFailure(node.ID, out)
 		eng.Context.Set("failure_class", failureClass)
 		eng.updateFailureDossierContext(node, out, failureClass, nodeRetries)
 
@@ -183,6 +183,17 @@ func runSubgraphUntil(ctx context.Context, eng *Engine, startNodeID, stopNodeID
 			return canceledReturn(node.ID, lastOutcome, err)
 		}
 
+		// A supervising manager loop may redirect the child after a stage.
+		if target := eng.steering.takeReroute(); target != "" {
+			eng.appendProgress(map[string]any{
+				"event":   "manager_reroute",
+				"node_id": node.ID,
+				"target":  target,
+			})
+			current = target
+			continue
+		}
+
 		next, err := selectNextEdge(eng.Graph, node.ID, out, eng.Context, eng.appendProgress)
 		if err != nil {
 			return parallelBranchResult{}, err
@@ -0,0 +1,629 @@
+package engine
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/otlp"
+	"github.com/oklog/ulid/v2"
+)
+
+// runTelemetry turns the engine's progress events into an OTLP span tree:
+//
+//	run → node attempt → LLM request / tool call / CLI invocation
+//
+// It observes the same event stream that feeds progress.ndjson, so API, CLI
+// and tmux backends are traced without backend-specific hooks. Finished spans
+// are exported on a background goroutine; export is best-effort and never
+// blocks or fails the run.
+type runTelemetry struct {
+	exporter otlp.Exporter
+	resource map[string]any
+	traceID  otlp.TraceID
+
+	mu      sync.Mutex
+	start   time.Time
+	run     *otlp.Span
+	nodes   map[string]*telemetryNode
+	tools   map[string]*otlp.Span
+	metrics map[string]map[string]*otlp.Point
+	closed  bool
+	stopped bool
+
+	queue   chan func(context.Context) error
+	done    chan struct{}
+	errMu   sync.Mutex
+	errs    int
+	lastErr error
+}
+
+// telemetryNode is the per-node span state between events.
+type telemetryNode struct {
+	attempt *otlp.Span
+	// ended holds the last finished attempt until its edge decision and
+	// checkpoint SHA have been attached.
+	ended    *otlp.Span
+	mark     time.Time
+	provider string
+	model    string
+	backend  string
+	cli      *otlp.Span
+}
+
+const telemetryQueueSize = 256
+
+// newRunTelemetry returns nil when no OTLP destination is configured.
+func newRunTelemetry(cfg *RunConfigFile, runID string) (*runTelemetry, error) {
+	var oc OTLPConfig
+	if cfg != nil {
+		oc = cfg.Telemetry.OTLP
+	}
+	endpoint := strings.TrimSpace(oc.Endpoint)
+	file := strings.TrimSpace(oc.File)
+	if endpoint == "" && file == "" {
+		endpoint = strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	}
+	var exp otlp.Exporter
+	switch {
+	case file != "":
+		fe, err := otlp.NewFileExporter(file)
+		if err != nil {
+			return nil, fmt.Errorf("telemetry.otlp.file: %w", err)
+		}
+		exp = fe
+	case endpoint != "":
+		exp = otlp.NewHTTPExporter(endpoint, oc.Headers)
+	default:
+		return nil, nil
+	}
+	service := strings.TrimSpace(oc.ServiceName)
+	if service == "" {
+		service = strings.TrimSpace(os.Getenv("OTEL_SERVICE_NAME"))
+	}
+	if service == "" {
+		service = "kilroy"
+	}
+	t := &runTelemetry{
+		exporter: exp,
+		resource: map[string]any{
+			"service.name":  service,
+			"kilroy.run_id": runID,
+		},
+		traceID: telemetryTraceID(runID),
+		nodes:   map[string]*telemetryNode{},
+		tools:   map[string]*otlp.Span{},
+		metrics: map[string]map[string]*otlp.Point{},
+		queue:   make(chan func(context.Context) error, telemetryQueueSize),
+		done:    make(chan struct{}),
+	}
+	go t.worker()
+	return t, nil
+}
+
+// telemetryTraceID derives the trace ID from the run ID so every process
+// that touches a run (including resumes) reports into the same trace.
+func telemetryTraceID(runID string) otlp.TraceID {
+	var id otlp.TraceID
+	if u, err := ulid.ParseStrict(runID); err == nil {
+		copy(id[:], u[:])
+		return id
+	}
+	sum := sha256.Sum256([]byte(runID))
+	copy(id[:], sum[:16])
+	return id
+}
+
+func newSpanID() otlp.SpanID {
+	var id otlp.SpanID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+// startTelemetry opens the run span. Resumed runs start a fresh run span in
+// the same trace.
+func (e *Engine) startTelemetry() {
+	if e == nil || e.telemetry != nil {
+		return
+	}
+	t, err := newRunTelemetry(e.RunConfig, e.Options.RunID)
+	if err != nil {
+		e.Warn(fmt.Sprintf("telemetry disabled: %v", err))
+		return
+	}
+	if t == nil {
+		return
+	}
+	graph := ""
+	if e.Graph != nil {
+		graph = e.Graph.Name
+	}
+	now := time.Now()
+	t.mu.Lock()
+	t.start = now
+	t.run = &otlp.Span{
+		TraceID: t.traceID,
+		SpanID:  newSpanID(),
+		Name:    "attractor.run " + graph,
+		Kind:    otlp.SpanKindInternal,
+		Start:   now,
+		Attrs: map[string]any{
+			"kilroy.run_id":       e.Options.RunID,
+			"kilroy.graph":        graph,
+			"kilroy.run_branch":   e.RunBranch,
+			"kilroy.git.base_sha": e.baseSHA,
+		},
+	}
+	t.mu.Unlock()
+	e.telemetry = t
+}
+
+// stopTelemetry ends any open spans, exports run metrics and drains the
+// export queue.
+func (e *Engine) stopTelemetry() {
+	if e == nil || e.telemetry == nil {
+		return
+	}
+	t := e.telemetry
+	t.finish(time.Now())
+	if n, err := t.close(10 * time.Second); err != nil {
+		e.Warn(fmt.Sprintf("telemetry export failed %d time(s): %v", n, err))
+	}
+}
+
+// observe maps one progress event onto the span tree.
+func (t *runTelemetry) observe(ev map[string]any) {
+	if t == nil || ev == nil {
+		return
+	}
+	name := eventFieldString(ev, "event")
+	nodeID := eventFieldString(ev, "node_id")
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed || t.run == nil {
+		return
+	}
+	switch name {
+	case "stage_attempt_start":
+		n := t.node(nodeID)
+		t.flushNode(n)
+		t.endSpan(n.attempt, now)
+		n.attempt = &otlp.Span{
+			TraceID:  t.traceID,
+			SpanID:   newSpanID(),
+			ParentID: t.run.SpanID,
+			Name:     "node " + nodeID,
+			Kind:     otlp.SpanKindInternal,
+			Start:    now,
+			Attrs: map[string]any{
+				"kilroy.node_id":      nodeID,
+				"kilroy.attempt":      ev["attempt"],
+				"kilroy.max_attempts": ev["max"],
+			},
+		}
+		n.mark = now
+	case "stage_attempt_end":
+		n := t.node(nodeID)
+		if n.attempt == nil {
+			return
+		}
+		status := eventFieldString(ev, "status")
+		n.attempt.Attrs["kilroy.status"] = status
+		n.attempt.Attrs["kilroy.failure_reason"] = eventFieldString(ev, "failure_reason")
+		n.attempt.Attrs["kilroy.failure_class"] = eventFieldString(ev, "failure_class")
+		if status == "fail" || status == "retry" {
+			n.attempt.Error = true
+			n.attempt.ErrorMessage = eventFieldString(ev, "failure_reason")
+		}
+		t.closeChildren(n, now)
+		n.attempt.End = now
+		n.ended = n.attempt
+		n.attempt = nil
+		t.count("kilroy.node.attempts", map[string]any{"kilroy.node_id": nodeID, "kilroy.status": status}, 1)
+	case "stage_cached":
+		n := t.node(nodeID)
+		t.flushNode(n)
+		n.ended = &otlp.Span{
+			TraceID:  t.traceID,
+			SpanID:   newSpanID(),
+			ParentID: t.run.SpanID,
+			Name:     "node " + nodeID,
+			Kind:     otlp.SpanKindInternal,
+			Start:    now,
+			End:      now,
+			Attrs: map[string]any{
+				"kilroy.node_id":          nodeID,
+				"kilroy.status":           eventFieldString(ev, "status"),
+				"kilroy.cached":           true,
+				"kilroy.cache.source":     eventFieldString(ev, "source_run_id"),
+				"kilroy.cache.source_sha": eventFieldString(ev, "source_sha"),
+			},
+		}
+	case "provider_selected":
+		n := t.node(nodeID)
+		n.provider = eventFieldString(ev, "provider")
+		n.model = eventFieldString(ev, "model")
+		n.backend = eventFieldString(ev, "backend")
+		if n.attempt != nil {
+			n.attempt.Attrs["gen_ai.system"] = n.provider
+			n.attempt.Attrs["gen_ai.request.model"] = n.model
+			n.attempt.Attrs["kilroy.backend"] = n.backend
+		}
+	case "llm_turn_end":
+		n := t.node(nodeID)
+		model := eventFieldString(ev, "model")
+		if model == "" {
+			model = n.model
+		}
+		in, out := intFromEvent(ev["input_tokens"]), intFromEvent(ev["output_tokens"])
+		t.push(otlp.Span{
+			TraceID:  t.traceID,
+			SpanID:   newSpanID(),
+			ParentID: t.parentFor(n),
+			Name:     "llm " + model,
+			Kind:     otlp.SpanKindClient,
+			Start:    n.markOr(now),
+			End:      now,
+			Attrs: map[string]any{
+				"kilroy.node_id":             nodeID,
+				"gen_ai.system":              n.provider,
+				"gen_ai.request.model":       n.model,
+				"gen_ai.response.model":      model,
+				"gen_ai.usage.input_tokens":  in,
+				"gen_ai.usage.output_tokens": out,
+				"kilroy.tool_call_count":     ev["tool_call_count"],
+			},
+		})
+		n.mark = now
+		if n.attempt != nil {
+			n.attempt.Attrs["gen_ai.usage.input_tokens"] = intFromEvent(n.attempt.Attrs["gen_ai.usage.input_tokens"]) + in
+			n.attempt.Attrs["gen_ai.usage.output_tokens"] = intFromEvent(n.attempt.Attrs["gen_ai.usage.output_tokens"]) + out
+		}
+		if in > 0 {
+			t.count("kilroy.llm.tokens", map[string]any{"gen_ai.request.model": model, "gen_ai.token.type": "input"}, int64(in))
+		}
+		if out > 0 {
+			t.count("kilroy.llm.tokens", map[string]any{"gen_ai.request.model": model, "gen_ai.token.type": "output"}, int64(out))
+		}
+	case "llm_tool_call_start":
+		n := t.node(nodeID)
+		tool := eventFieldString(ev, "tool_name")
+		callID := eventFieldString(ev, "call_id")
+		t.tools[nodeID+"\x00"+callID] = &otlp.Span{
+			TraceID:  t.traceID,
+			SpanID:   newSpanID(),
+			ParentID: t.parentFor(n),
+			Name:     "tool " + tool,
+			Kind:     otlp.SpanKindInternal,
+			Start:    now,
+			Attrs: map[string]any{
+				"kilroy.node_id":      nodeID,
+				"gen_ai.tool.name":    tool,
+				"gen_ai.tool.call.id": callID,
+			},
+		}
+	case "llm_tool_call_end":
+		key := nodeID + "\x00" + eventFieldString(ev, "call_id")
+		sp := t.tools[key]
+		if sp == nil {
+			return
+		}
+		delete(t.tools, key)
+		if isErr, _ := ev["is_error"].(bool); isErr {
+			sp.Error = true
+			sp.ErrorMessage = "tool call failed"
+		}
+		sp.End = now
+		t.push(*sp)
+		t.node(nodeID).mark = now
+		t.count("kilroy.tool.calls", map[string]any{"gen_ai.tool.name": sp.Attrs["gen_ai.tool.name"]}, 1)
+	case "cli_invocation_start", "tmux_session_start":
+		n := t.node(nodeID)
+		t.endSpan(n.cli, now)
+		label := eventFieldString(ev, "executable")
+		if name == "tmux_session_start" {
+			label = "tmux " + eventFieldString(ev, "tool")
+		}
+		n.cli = &otlp.Span{
+			TraceID:  t.traceID,
+			SpanID:   newSpanID(),
+			ParentID: t.parentFor(n),
+			Name:     "cli " + strings.TrimSpace(label),
+			Kind:     otlp.SpanKindClient,
+			Start:    now,
+			Attrs: map[string]any{
+				"kilroy.node_id":       nodeID,
+				"gen_ai.system":        firstNonEmpty(eventFieldString(ev, "provider"), n.provider),
+				"gen_ai.request.model": firstNonEmpty(eventFieldString(ev, "model"), n.model),
+				"kilroy.backend":       firstNonEmpty(n.backend, "cli"),
+				"process.pid":          ev["pid"],
+				"kilroy.tmux.session":  eventFieldString(ev, "session"),
+			},
+		}
+	case "cli_invocation_end", "tmux_session_complete":
+		n := t.node(nodeID)
+		if n.cli == nil {
+			return
+		}
+		code := intFromEvent(ev["exit_code"])
+		n.cli.Attrs["process.exit_code"] = code
+		if code != 0 {
+			n.cli.Error = true
+			n.cli.ErrorMessage = fmt.Sprintf("exit code %d", code)
+		}
+		if trig := eventFieldString(ev, "failure_trigger"); trig != "" {
+			n.cli.Attrs["kilroy.failure_trigger"] = trig
+		}
+		n.cli.End = now
+		t.push(*n.cli)
+		t.count("kilroy.cli.invocations", map[string]any{"gen_ai.system": n.cli.Attrs["gen_ai.system"]}, 1)
+		n.cli = nil
+	case "edge_selected":
+		n := t.node(eventFieldString(ev, "from_node"))
+		sp := n.ended
+		if sp == nil {
+			sp = n.attempt
+		}
+		if sp == nil {
+			return
+		}
+		sp.Attrs["kilroy.edge.to"] = eventFieldString(ev, "to_node")
+		sp.Attrs["kilroy.edge.label"] = eventFieldString(ev, "label")
+		sp.Attrs["kilroy.edge.condition"] = eventFieldString(ev, "condition")
+		sp.Attrs["kilroy.edge.reason"] = eventFieldString(ev, "selection_method")
+		t.flushNode(n)
+	case "run_completed", "run_failed":
+		t.run.Attrs["kilroy.status"] = eventFieldString(ev, "status")
+		if reason := eventFieldString(ev, "reason"); reason != "" {
+			t.run.Error = true
+			t.run.ErrorMessage = reason
+		}
+	}
+}
+
+// checkpoint records the git commit a node produced on its finished attempt
+// span and as the run's latest SHA.
+func (t *runTelemetry) checkpoint(nodeID, sha string) {
+	if t == nil || strings.TrimSpace(sha) == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed || t.run == nil {
+		return
+	}
+	n := t.node(nodeID)
+	if sp := n.ended; sp != nil {
+		sp.Attrs["kilroy.git.sha"] = sha
+	} else if sp := n.attempt; sp != nil {
+		sp.Attrs["kilroy.git.sha"] = sha
+	}
+	t.run.Attrs["kilroy.git.sha"] = sha
+}
+
+// finish ends every open span and queues the run span and metrics.
+func (t *runTelemetry) finish(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed || t.run == nil {
+		return
+	}
+	ids := make([]string, 0, len(t.nodes))
+	for id := range t.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		n := t.nodes[id]
+		t.closeChildren(n, now)
+		t.endSpan(n.attempt, now)
+		n.attempt = nil
+		t.flushNode(n)
+	}
+	for key, sp := range t.tools {
+		t.endSpan(sp, now)
+		delete(t.tools, key)
+	}
+	t.run.End = now
+	t.push(*t.run)
+
+	metrics := make([]otlp.Metric, 0, len(t.metrics))
+	names := make([]string, 0, len(t.metrics))
+	for name := range t.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m := otlp.Metric{Name: name, Unit: telemetryMetricUnits[name], Description: telemetryMetricDescriptions[name]}
+		keys := make([]string, 0, len(t.metrics[name]))
+		for k := range t.metrics[name] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := *t.metrics[name][k]
+			p.Start, p.Time = t.start, now
+			m.Points = append(m.Points, p)
+		}
+		metrics = append(metrics, m)
+	}
+	if len(metrics) > 0 {
+		resource := t.resource
+		t.enqueue(func(ctx context.Context) error {
+			b, err := otlp.EncodeMetrics(resource, metrics)
+			if err != nil {
+				return err
+			}
+			return t.exporter.ExportMetrics(ctx, b)
+		})
+	}
+	t.closed = true
+}
+
+var telemetryMetricUnits = map[string]string{
+	"kilroy.node.attempts":   "{attempt}",
+	"kilroy.llm.tokens":      "{token}",
+	"kilroy.tool.calls":      "{call}",
+	"kilroy.cli.invocations": "{invocation}",
+}
+
+var telemetryMetricDescriptions = map[string]string{
+	"kilroy.node.attempts":   "Node attempts by outcome status",
+	"kilroy.llm.tokens":      "LLM tokens reported by providers",
+	"kilroy.tool.calls":      "Agent tool calls",
+	"kilroy.cli.invocations": "Provider CLI and tmux invocations",
+}
+
+func (t *runTelemetry) node(id string) *telemetryNode {
+	n := t.nodes[id]
+	if n == nil {
+		n = &telemetryNode{}
+		t.nodes[id] = n
+	}
+	return n
+}
+
+func (t *runTelemetry) parentFor(n *telemetryNode) otlp.SpanID {
+	if n.attempt != nil {
+		return n.attempt.SpanID
+	}
+	return t.run.SpanID
+}
+
+func (n *telemetryNode) markOr(now time.Time) time.Time {
+	if n.mark.IsZero() {
+		return now
+	}
+	return n.mark
+}
+
+// closeChildren ends a node's open CLI and tool spans when its attempt ends.
+func (t *runTelemetry) closeChildren(n *telemetryNode, now time.Time) {
+	if n.cli != nil {
+		t.endSpan(n.cli, now)
+		n.cli = nil
+	}
+	if n.attempt == nil {
+		return
+	}
+	for key, sp := range t.tools {
+		if sp.ParentID == n.attempt.SpanID {
+			t.endSpan(sp, now)
+			delete(t.tools, key)
+		}
+	}
+}
+
+func (t *runTelemetry) endSpan(sp *otlp.Span, now time.Time) {
+	if sp == nil {
+		return
+	}
+	if sp.End.IsZero() {
+		sp.End = now
+	}
+	t.push(*sp)
+}
+
+func (t *runTelemetry) flushNode(n *telemetryNode) {
+	if n.ended != nil {
+		t.push(*n.ended)
+		n.ended = nil
+	}
+}
+
+func (t *runTelemetry) count(name string, attrs map[string]any, v int64) {
+	series := t.metrics[name]
+	if series == nil {
+		series = map[string]*otlp.Point{}
+		t.metrics[name] = series
+	}
+	key := fmt.Sprint(attrs)
+	p := series[key]
+	if p == nil {
+		p = &otlp.Point{Attrs: attrs}
+		series[key] = p
+	}
+	p.Value += v
+}
+
+// push queues one finished span for export. Callers hold t.mu.
+func (t *runTelemetry) push(sp otlp.Span) {
+	resource := t.resource
+	t.enqueue(func(ctx context.Context) error {
+		b, err := otlp.EncodeTraces(resource, []otlp.Span{sp})
+		if err != nil {
+			return err
+		}
+		return t.exporter.ExportTraces(ctx, b)
+	})
+}
+
+// enqueue drops the export when the queue is full rather than stalling the run.
+func (t *runTelemetry) enqueue(job func(context.Context) error) {
+	select {
+	case t.queue <- job:
+	default:
+		t.recordErr(fmt.Errorf("export queue full, span dropped"))
+	}
+}
+
+func (t *runTelemetry) worker() {
+	defer close(t.done)
+	for job := range t.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := job(ctx); err != nil {
+			t.recordErr(err)
+		}
+		cancel()
+	}
+}
+
+func (t *runTelemetry) recordErr(err error) {
+	t.errMu.Lock()
+	t.errs++
+	t.lastErr = err
+	t.errMu.Unlock()
+}
+
+// close drains the queue (bounded by timeout) and closes the exporter. It
+// returns the number of failed exports and the last error.
+func (t *runTelemetry) close(timeout time.Duration) (int, error) {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return 0, nil
+	}
+	t.stopped = true
+	t.closed = true
+	close(t.queue)
+	t.mu.Unlock()
+	select {
+	case <-t.done:
+		_ = t.exporter.Close()
+	case <-time.After(timeout):
+		t.recordErr(fmt.Errorf("export did not finish within %s", timeout))
+	}
+	t.errMu.Lock()
+	defer t.errMu.Unlock()
+	return t.errs, t.lastErr
+}
+
+func intFromEvent(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return 0
+}
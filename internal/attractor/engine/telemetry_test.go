@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+type exportedSpan struct {
+	SpanID   string
+	ParentID string
+	Name     string
+	Attrs    map[string]string
+	Error    bool
+}
+
+// readExportedSpans parses an OTLP JSON-lines file into spans keyed by name.
+func readExportedSpans(t *testing.T, path string) (map[string][]exportedSpan, []string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	spans := map[string][]exportedSpan{}
+	var metrics []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 1<<20), 1<<20)
+	for sc.Scan() {
+		var doc struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						SpanID       string `json:"spanId"`
+						ParentSpanID string `json:"parentSpanId"`
+						Name         string `json:"name"`
+						Attributes   []struct {
+							Key   string         `json:"key"`
+							Value map[string]any `json:"value"`
+						} `json:"attributes"`
+						Status struct {
+							Code int `json:"code"`
+						} `json:"status"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+			ResourceMetrics []struct {
+				ScopeMetrics []struct {
+					Metrics []struct {
+						Name string `json:"name"`
+					} `json:"metrics"`
+				} `json:"scopeMetrics"`
+			} `json:"resourceMetrics"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &doc); err != nil {
+			t.Fatalf("bad OTLP line: %v", err)
+		}
+		for _, rs := range doc.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, sp := range ss.Spans {
+					es := exportedSpan{SpanID: sp.SpanID, ParentID: sp.ParentSpanID, Name: sp.Name, Attrs: map[string]string{}, Error: sp.Status.Code == 2}
+					for _, a := range sp.Attributes {
+						for _, v := range a.Value {
+							es.Attrs[a.Key] = fmtAny(v)
+						}
+					}
+					spans[sp.Name] = append(spans[sp.Name], es)
+				}
+			}
+		}
+		for _, rm := range doc.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					metrics = append(metrics, m.Name)
+				}
+			}
+		}
+	}
+	return spans, metrics
+}
+
+func fmtAny(v any) string {
+	b, _ := json.Marshal(v)
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		return s
+	}
+	return string(b)
+}
+
+func TestRunTelemetry_BuildsSpanTreeFromProgressEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "otlp.jsonl")
+	cfg := &RunConfigFile{}
+	cfg.Telemetry.OTLP.File = path
+	eng := &Engine{Options: RunOptions{RunID: "01J0000000000000000000000A"}, RunConfig: cfg}
+	eng.startTelemetry()
+	if eng.telemetry == nil {
+		t.Fatal("telemetry not started")
+	}
+
+	for _, ev := range []map[string]any{
+		{"event": "stage_attempt_start", "node_id": "impl", "attempt": 1, "max": 2},
+		{"event": "provider_selected", "node_id": "impl", "provider": "openai", "model": "gpt-5", "backend": "api"},
+		{"event": "llm_tool_call_start", "node_id": "impl", "tool_name": "shell", "call_id": "c1"},
+		{"event": "llm_tool_call_end", "node_id": "impl", "tool_name": "shell", "call_id": "c1", "is_error": true},
+		{"event": "llm_turn_end", "node_id": "impl", "model": "gpt-5-2025", "input_tokens": 120, "output_tokens": 30, "tool_call_count": 0},
+		{"event": "stage_attempt_end", "node_id": "impl", "status": "fail", "failure_reason": "tests failed", "failure_class": "deterministic"},
+		{"event": "stage_attempt_start", "node_id": "impl", "attempt": 2, "max": 2},
+		{"event": "cli_invocation_start", "node_id": "impl", "provider": "anthropic", "model": "claude", "executable": "claude", "pid": 7},
+		{"event": "cli_invocation_end", "node_id": "impl", "exit_code": 0},
+		{"event": "stage_attempt_end", "node_id": "impl", "status": "success"},
+		{"event": "edge_selected", "from_node": "impl", "to_node": "review", "condition": "outcome=success", "selection_method": "condition"},
+		{"event": "run_completed", "status": "success"},
+	} {
+		eng.appendProgress(ev)
+		if ev["event"] == "stage_attempt_end" && ev["status"] == "success" {
+			eng.telemetry.checkpoint("impl", "abc123")
+		}
+	}
+	eng.stopTelemetry()
+
+	spans, metrics := readExportedSpans(t, path)
+	run := spans["attractor.run "]
+	if len(run) != 1 || run[0].ParentID != "" || run[0].Attrs["kilroy.git.sha"] != "abc123" {
+		t.Fatalf("run span = %+v", run)
+	}
+	attempts := spans["node impl"]
+	if len(attempts) != 2 {
+		t.Fatalf("attempt spans = %+v", attempts)
+	}
+	first, second := attempts[0], attempts[1]
+	if first.ParentID != run[0].SpanID || !first.Error || first.Attrs["kilroy.failure_class"] != "deterministic" || first.Attrs["gen_ai.system"] != "openai" || first.Attrs["gen_ai.usage.input_tokens"] != "120" {
+		t.Fatalf("first attempt = %+v", first)
+	}
+	if second.Attrs["kilroy.edge.to"] != "review" || second.Attrs["kilroy.edge.reason"] != "condition" || second.Attrs["kilroy.git.sha"] != "abc123" {
+		t.Fatalf("second attempt = %+v", second)
+	}
+	tool := spans["tool shell"]
+	if len(tool) != 1 || tool[0].ParentID != first.SpanID || !tool[0].Error {
+		t.Fatalf("tool span = %+v", tool)
+	}
+	llmSpan := spans["llm gpt-5-2025"]
+	if len(llmSpan) != 1 || llmSpan[0].ParentID != first.SpanID || llmSpan[0].Attrs["gen_ai.usage.output_tokens"] != "30" || llmSpan[0].Attrs["gen_ai.request.model"] != "gpt-5" {
+		t.Fatalf("llm span = %+v", llmSpan)
+	}
+	cli := spans["cli claude"]
+	if len(cli) != 1 || cli[0].ParentID != second.SpanID || cli[0].Attrs["gen_ai.system"] != "anthropic" || cli[0].Attrs["process.exit_code"] != "0" {
+		t.Fatalf("cli span = %+v", cli)
+	}
+	want := map[string]bool{"kilroy.cli.invocations": true, "kilroy.llm.tokens": true, "kilroy.node.attempts": true, "kilroy.tool.calls": true}
+	for _, m := range metrics {
+		delete(want, m)
+	}
+	if len(want) != 0 {
+		t.Fatalf("missing metrics %v (got %v)", want, metrics)
+	}
+}
+
+func TestRunWithConfig_ExportsOTLPTraceToFile(t *testing.T) {
+	repo := initTestRepo(t)
+	pinned := writePinnedCatalog(t)
+	cfg := minimalToolGraphConfig(repo, pinned)
+	otlpPath := filepath.Join(t.TempDir(), "trace.jsonl")
+	cfg.Telemetry.OTLP.File = otlpPath
+
+	dot := []byte(`digraph traced {
+  start [shape=Mdiamond]
+  build [shape=parallelogram, tool_command="echo built > out.txt"]
+  gate [shape=diamond]
+  done [shape=Msquare]
+  start -> build -> gate
+  gate -> done
+}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	res, err := RunWithConfig(ctx, dot, cfg, RunOptions{RunID: "otlp-run", LogsRoot: t.TempDir(), DisableCXDB: true})
+	if err != nil {
+		t.Fatalf("RunWithConfig: %v", err)
+	}
+	if res.FinalStatus != runtime.FinalSuccess {
+		t.Fatalf("final status = %q", res.FinalStatus)
+	}
+
+	spans, _ := readExportedSpans(t, otlpPath)
+	run := spans["attractor.run traced"]
+	if len(run) != 1 || run[0].Attrs["kilroy.status"] != "success" || run[0].Attrs["kilroy.git.sha"] != res.FinalCommitSHA {
+		t.Fatalf("run span = %+v (final sha %s)", run, res.FinalCommitSHA)
+	}
+	build := spans["node build"]
+	if len(build) != 1 || build[0].ParentID != run[0].SpanID || build[0].Attrs["kilroy.status"] != "success" || build[0].Attrs["kilroy.edge.to"] != "gate" || build[0].Attrs["kilroy.git.sha"] == "" {
+		t.Fatalf("build span = %+v", build)
+	}
+}
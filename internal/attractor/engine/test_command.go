@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/testresults"
+)
+
+const testResultsFileName = "test_results.json"
+
+// testResultsSummaryLimit bounds the failures listed in tests.summary and
+// failure reasons; test_results.json keeps all of them.
+const testResultsSummaryLimit = 10
+
+// toolNodeCommand returns the shell command of a tool node and whether it
+// is a test_command, whose output is parsed as test results.
+func toolNodeCommand(node *model.Node) (string, bool) {
+	if cmd := strings.TrimSpace(node.Attr("tool_command", "")); cmd != "" {
+		return cmd, false
+	}
+	if cmd := strings.TrimSpace(node.Attr("test_command", "")); cmd != "" {
+		return cmd, true
+	}
+	return "", false
+}
+
+// testCommandResults is the parsed outcome of a test_command run.
+type testCommandResults struct {
+	report   *testresults.Report
+	parseErr error
+}
+
+// collectTestResults parses a test_command's results from the files named
+// by test_report (a path or glob relative to the worktree; files not written
+// by this run are ignored) or else from its output, then writes
+// test_results.json to the stage dir.
+func collectTestResults(execCtx *Execution, node *model.Node, stageDir string, stdout, stderr []byte, exitCode int, started time.Time) testCommandResults {
+	format := node.Attr("test_format", "")
+	var res testCommandResults
+	if pattern := strings.TrimSpace(node.Attr("test_report", "")); pattern != "" {
+		res.report, res.parseErr = readTestReportFiles(execCtx.WorktreeDir, pattern, format, started)
+	} else {
+		res.report, res.parseErr = testresults.Parse(format, stdout)
+		if errors.Is(res.parseErr, testresults.ErrNoResults) {
+			res.report, res.parseErr = testresults.Parse(format, append(append([]byte{}, stdout...), stderr...))
+		}
+	}
+
+	doc := map[string]any{"exit_code": exitCode}
+	if res.parseErr != nil {
+		WarnEngine(execCtx, fmt.Sprintf("test_command for node %q: %v", node.ID, res.parseErr))
+		doc["parse_error"] = res.parseErr.Error()
+	} else {
+		doc["format"] = res.report.Format
+		doc["total"] = res.report.Total
+		doc["passed"] = res.report.Passed
+		doc["failed"] = res.report.Failed
+		doc["skipped"] = res.report.Skipped
+		doc["failures"] = res.report.Failures
+	}
+	if err := writeJSON(filepath.Join(stageDir, testResultsFileName), doc); err != nil {
+		WarnEngine(execCtx, fmt.Sprintf("write %s: %v", testResultsFileName, err))
+	}
+	return res
+}
+
+func readTestReportFiles(worktree, pattern, format string, since time.Time) (*testresults.Report, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(worktree, pattern)
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("test_report: %w", err)
+	}
+	sort.Strings(paths)
+	var merged *testresults.Report
+	for _, p := range paths {
+		// Coarse filesystem timestamps can land just before the start time.
+		if st, err := os.Stat(p); err != nil || st.ModTime().Before(since.Add(-2*time.Second)) {
+			continue
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("test_report: %w", err)
+		}
+		r, err := testresults.Parse(format, b)
+		if errors.Is(err, testresults.ErrNoResults) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("test_report %s: %w", p, err)
+		}
+		if merged == nil {
+			merged = &testresults.Report{}
+		}
+		merged.Merge(r)
+	}
+	if merged == nil {
+		return nil, fmt.Errorf("test_report %q: no report written by this run", pattern)
+	}
+	return merged, nil
+}
+
+func (r testCommandResults) failed() bool {
+	return r.report != nil && r.report.Failed > 0
+}
+
+// contextUpdates sets tests.* for edge conditions such as
+// context.tests.failed=0. Unparsed results clear the keys so a loop never
+// routes on a previous iteration's counts.
+func (r testCommandResults) contextUpdates() map[string]any {
+	if r.report == nil {
+		return map[string]any{"tests.total": "", "tests.passed": "", "tests.failed": "", "tests.skipped": "", "tests.summary": ""}
+	}
+	return map[string]any{
+		"tests.total":   r.report.Total,
+		"tests.passed":  r.report.Passed,
+		"tests.failed":  r.report.Failed,
+		"tests.skipped": r.report.Skipped,
+		"tests.summary": Truncate(r.report.Summary(testResultsSummaryLimit, 8), 8_000),
+	}
+}
+
+// failureReason names the failing tests, e.g.
+// "2 of 57 tests failed: pkg.TestA (a_test.go:12), pkg.TestB".
+func (r testCommandResults) failureReason() string {
+	names := make([]string, 0, testResultsSummaryLimit)
+	for i, f := range r.report.Failures {
+		if i == testResultsSummaryLimit {
+			names = append(names, fmt.Sprintf("and %d more", len(r.report.Failures)-i))
+			break
+		}
+		name := f.Name
+		if f.Suite != "" {
+			name = f.Suite + "." + name
+		}
+		if loc := f.Location(); loc != "" {
+			name += " (" + loc + ")"
+		}
+		names = append(names, name)
+	}
+	return r.report.Headline() + ": " + strings.Join(names, ", ")
+}
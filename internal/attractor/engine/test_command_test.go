@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+func TestToolHandler_TestCommand_ParsesResultsIntoContext(t *testing.T) {
+	tap := `printf 'ok 1 - adds\nnot ok 2 - divides\n  ---\n  at: test/math.js:17:5\n  ...\nok 3 - later # SKIP\n'; exit 1`
+	out, logsRoot, _, nodeID := runToolHandler(t, "unit_tests", "Unit Tests", "", map[string]string{"test_command": tap})
+	if out.Status != runtime.StatusFail {
+		t.Fatalf("status: got %q want %q", out.Status, runtime.StatusFail)
+	}
+	if out.FailureReason != "1 of 3 tests failed, 1 skipped: divides (test/math.js:17)" {
+		t.Fatalf("failure reason: %q", out.FailureReason)
+	}
+	for k, want := range map[string]any{"tests.total": 3, "tests.passed": 1, "tests.failed": 1, "tests.skipped": 1} {
+		if got := out.ContextUpdates[k]; got != want {
+			t.Fatalf("%s = %v, want %v", k, got, want)
+		}
+	}
+	if !strings.Contains(out.ContextUpdates["tests.summary"].(string), "--- divides (test/math.js:17)") {
+		t.Fatalf("tests.summary = %q", out.ContextUpdates["tests.summary"])
+	}
+
+	b, err := os.ReadFile(filepath.Join(logsRoot, nodeID, testResultsFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Format   string `json:"format"`
+		ExitCode int    `json:"exit_code"`
+		Failures []struct {
+			Name string `json:"name"`
+			File string `json:"file"`
+			Line int    `json:"line"`
+		} `json:"failures"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Format != "tap" || doc.ExitCode != 1 || len(doc.Failures) != 1 || doc.Failures[0].File != "test/math.js" || doc.Failures[0].Line != 17 {
+		t.Fatalf("test_results.json = %s", b)
+	}
+}
+
+func TestToolHandler_TestCommand_ReportFileAndZeroExitFailures(t *testing.T) {
+	// The runner exits 0 but the report records a failure.
+	junit := `<testsuite name="s"><testcase classname="c" name="ok"/><testcase classname="c" name="bad" file="c.py" line="4"><failure message="nope"/></testcase></testsuite>`
+	cmd := "mkdir -p reports && printf '" + junit + "' > reports/a.xml"
+	out, _, _, _ := runToolHandler(t, "unit_tests", "Unit Tests", "", map[string]string{"test_command": cmd, "test_report": "reports/*.xml"})
+	if out.Status != runtime.StatusFail || out.FailureReason != "1 of 2 tests failed: c.bad (c.py:4)" {
+		t.Fatalf("outcome = %+v", out)
+	}
+	if out.ContextUpdates["tool.exit_status"] != "exit status 0" || out.ContextUpdates["tests.failed"] != 1 {
+		t.Fatalf("context = %+v", out.ContextUpdates)
+	}
+}
+
+func TestToolHandler_TestCommand_UnparsedOutputClearsCounts(t *testing.T) {
+	out, _, _, _ := runToolHandler(t, "unit_tests", "Unit Tests", "", map[string]string{"test_command": "echo all good"})
+	if out.Status != runtime.StatusSuccess {
+		t.Fatalf("status: got %q want %q", out.Status, runtime.StatusSuccess)
+	}
+	if v, ok := out.ContextUpdates["tests.failed"]; !ok || v != "" {
+		t.Fatalf("tests.failed = %#v, %v", v, ok)
+	}
+}
@@ -0,0 +1,360 @@
+// Package estimate projects the LLM cost of a graph run before it starts.
+package estimate
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/modeldb"
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+	"github.com/danshapiro/kilroy/internal/attractor/validate"
+)
+
+// Heuristic token budgets per node execution, used when no prior run of the
+// graph recorded token usage for the node. agent_loop re-sends the growing
+// transcript every turn, so it dwarfs one_shot.
+var (
+	oneShotInputTokens  = Range{Low: 500, Expected: 2_000, High: 8_000}
+	oneShotOutputTokens = Range{Low: 300, Expected: 1_500, High: 8_000}
+	agentInputTokens    = Range{Low: 20_000, Expected: 150_000, High: 600_000}
+	agentOutputTokens   = Range{Low: 2_000, Expected: 10_000, High: 40_000}
+)
+
+const (
+	// estimateRetryRate is the share of extra attempts expected on nodes that
+	// can retry when history has nothing better.
+	estimateRetryRate = 0.15
+	// DefaultUnboundedVisits caps visits of nodes on a cycle with no
+	// max_node_visits when computing the high estimate.
+	DefaultUnboundedVisits = 10
+)
+
+// Range is a low/expected/high triple.
+type Range struct {
+	Low      float64 `json:"low"`
+	Expected float64 `json:"expected"`
+	High     float64 `json:"high"`
+}
+
+func (r Range) scale(by Range) Range {
+	return Range{Low: r.Low * by.Low, Expected: r.Expected * by.Expected, High: r.High * by.High}
+}
+
+func (r *Range) add(o Range) {
+	r.Low += o.Low
+	r.Expected += o.Expected
+	r.High += o.High
+}
+
+// NodeEstimate is the projected cost of one LLM node.
+type NodeEstimate struct {
+	NodeID   string `json:"node_id"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Mode     string `json:"mode"`
+	// CatalogModel is the model's catalog ID; empty when it has no entry.
+	CatalogModel  string `json:"catalog_model,omitempty"`
+	ContextWindow int    `json:"context_window,omitempty"`
+	Priced        bool   `json:"priced"`
+	// InputTokens and OutputTokens are per execution.
+	InputTokens  Range  `json:"input_tokens"`
+	OutputTokens Range  `json:"output_tokens"`
+	TokenSource  string `json:"token_source"` // history | heuristic
+	// Executions is how many times the node runs (visits x attempts).
+	Executions Range  `json:"executions"`
+	Bound      string `json:"bound"` // what caps the high execution count
+	// MaxVisits and MaxAttempts are the structural multipliers behind the
+	// high execution count.
+	MaxVisits   int   `json:"max_visits"`
+	MaxAttempts int   `json:"max_attempts"`
+	HistoryRuns int   `json:"history_runs"`
+	CostUSD     Range `json:"cost_usd"`
+}
+
+// RunEstimate is the projected cost and duration of a graph run.
+type RunEstimate struct {
+	GraphName   string         `json:"graph_name"`
+	HistoryRuns int            `json:"history_runs"`
+	Nodes       []NodeEstimate `json:"nodes"`
+	CostUSD     Range          `json:"cost_usd"`
+	// DurationMS comes from prior runs' wall clock; zero without history.
+	DurationMS Range    `json:"duration_ms"`
+	Warnings   []string `json:"warnings"`
+}
+
+// History is what one prior run of the graph says about its nodes.
+type History struct {
+	RunID      string
+	Status     string
+	DurationMS int64
+	// Executions counts node executions (retries and loop visits included).
+	Executions map[string]int
+	// InputTokens and OutputTokens total LLM usage per node; only nodes
+	// with recorded usage are present.
+	InputTokens  map[string]int64
+	OutputTokens map[string]int64
+}
+
+// Options controls Build.
+type Options struct {
+	Catalog         *modeldb.Catalog
+	History         []History
+	UnboundedVisits int // default DefaultUnboundedVisits
+}
+
+// Build projects per-node tokens and a USD range for running g.
+// g must be prepared (stylesheet applied) so llm_provider/llm_model are
+// resolved per node. Token ranges come from history when prior runs
+// recorded usage for a node, else from heuristics by agent_mode;
+// execution counts take low/expected from history when present and the
+// high from loop and retry ceilings.
+func Build(g *model.Graph, opts Options) *RunEstimate {
+	est := &RunEstimate{Nodes: []NodeEstimate{}, Warnings: []string{}, HistoryRuns: len(opts.History)}
+	if g == nil {
+		return est
+	}
+	est.GraphName = g.Name
+	if opts.UnboundedVisits <= 0 {
+		opts.UnboundedVisits = DefaultUnboundedVisits
+	}
+
+	bounds := map[string]validate.VisitBound{}
+	for _, vb := range validate.AnalyzeDeep(g).Visits {
+		bounds[vb.NodeID] = vb
+	}
+	var unpriced []string
+	for _, id := range sortedNodeIDs(g) {
+		n := g.Nodes[id]
+		vb, reachable := bounds[id]
+		if !reachable || !isLLMNode(n) {
+			continue
+		}
+		ne := NodeEstimate{
+			NodeID:      id,
+			Provider:    strings.TrimSpace(n.Attr("llm_provider", "")),
+			Model:       strings.TrimSpace(n.Attr("llm_model", "")),
+			Mode:        nodeAgentMode(n),
+			MaxAttempts: vb.Attempts,
+			MaxVisits:   vb.Visits,
+			Bound:       vb.Bound,
+		}
+		if vb.Unbounded {
+			ne.MaxVisits, ne.Bound = opts.UnboundedVisits, "unbounded"
+			est.Warnings = append(est.Warnings, fmt.Sprintf("node %s is on a cycle with no max_node_visits; high estimate assumes %d visits", id, opts.UnboundedVisits))
+		}
+		ne.Executions = heuristicExecutions(vb, ne.MaxVisits)
+		ne.InputTokens, ne.OutputTokens, ne.TokenSource = heuristicTokens(n, ne.Mode)
+		applyHistory(&ne, opts.History)
+
+		var inCost, outCost float64
+		if catID, entry, ok := modeldb.FindProviderModel(opts.Catalog, ne.Provider, ne.Model); ok {
+			ne.CatalogModel, ne.ContextWindow = catID, entry.ContextWindow
+			if entry.InputCostPerToken != nil && entry.OutputCostPerToken != nil {
+				ne.Priced = true
+				inCost, outCost = *entry.InputCostPerToken, *entry.OutputCostPerToken
+			}
+		}
+		if !ne.Priced {
+			unpriced = append(unpriced, id)
+		}
+		perExec := Range{
+			Low:      ne.InputTokens.Low*inCost + ne.OutputTokens.Low*outCost,
+			Expected: ne.InputTokens.Expected*inCost + ne.OutputTokens.Expected*outCost,
+			High:     ne.InputTokens.High*inCost + ne.OutputTokens.High*outCost,
+		}
+		ne.CostUSD = perExec.scale(ne.Executions)
+		est.CostUSD.add(ne.CostUSD)
+		est.Nodes = append(est.Nodes, ne)
+	}
+	if len(unpriced) > 0 {
+		est.Warnings = append(est.Warnings, "no catalog pricing for nodes "+strings.Join(unpriced, ", ")+"; they are counted as $0")
+	}
+
+	var durations []float64
+	for _, h := range opts.History {
+		if h.DurationMS > 0 {
+			durations = append(durations, float64(h.DurationMS))
+		}
+	}
+	est.DurationMS = spread(durations)
+	return est
+}
+
+// heuristicExecutions: low is one execution, expected assumes loops run about
+// half their ceiling plus a few retries, high is the structural ceiling.
+func heuristicExecutions(vb validate.VisitBound, maxVisits int) Range {
+	expectedVisits := 1.0
+	switch {
+	case vb.Unbounded || vb.Bound == "max_node_visits":
+		expectedVisits = math.Min(2, float64(maxVisits))
+	case maxVisits > 1:
+		expectedVisits = float64(maxVisits+1) / 2
+	}
+	retry := 1.0
+	if vb.Attempts > 1 {
+		retry += estimateRetryRate
+	}
+	return Range{Low: 1, Expected: expectedVisits * retry, High: float64(maxVisits * vb.Attempts)}
+}
+
+func heuristicTokens(n *model.Node, mode string) (in, out Range, source string) {
+	in, out = agentInputTokens, agentOutputTokens
+	if mode == "one_shot" {
+		in, out = oneShotInputTokens, oneShotOutputTokens
+	}
+	// The prompt is sent at least once per execution.
+	promptTokens := float64(len(n.Prompt()) / 4)
+	in.Low += promptTokens
+	in.Expected += promptTokens
+	in.High += promptTokens
+	if maxOut, err := strconv.Atoi(strings.TrimSpace(n.Attr("max_tokens", ""))); err == nil && maxOut > 0 && mode == "one_shot" {
+		out.High = math.Min(out.High, float64(maxOut))
+		out.Expected = math.Min(out.Expected, out.High)
+	}
+	return in, out, "heuristic"
+}
+
+// applyHistory replaces heuristic token ranges and low/expected executions
+// with what prior runs observed.
+func applyHistory(ne *NodeEstimate, history []History) {
+	var execs, ins, outs []float64
+	for _, h := range history {
+		n := h.Executions[ne.NodeID]
+		execs = append(execs, float64(n))
+		if in, ok := h.InputTokens[ne.NodeID]; ok && n > 0 {
+			ins = append(ins, float64(in)/float64(n))
+			outs = append(outs, float64(h.OutputTokens[ne.NodeID])/float64(n))
+		}
+	}
+	ne.HistoryRuns = len(execs)
+	if len(execs) > 0 {
+		r := spread(execs)
+		ne.Executions.Low, ne.Executions.Expected = r.Low, r.Expected
+		if r.High > ne.Executions.High {
+			ne.Executions.High = r.High
+		}
+	}
+	if len(ins) > 0 {
+		ne.InputTokens, ne.OutputTokens, ne.TokenSource = spread(ins), spread(outs), "history"
+	}
+}
+
+// spread returns min/mean/max of vals.
+func spread(vals []float64) Range {
+	if len(vals) == 0 {
+		return Range{}
+	}
+	r := Range{Low: vals[0], High: vals[0]}
+	var sum float64
+	for _, v := range vals {
+		sum += v
+		r.Low = math.Min(r.Low, v)
+		r.High = math.Max(r.High, v)
+	}
+	r.Expected = sum / float64(len(vals))
+	return r
+}
+
+// isLLMNode reports whether n runs the agent handler.
+func isLLMNode(n *model.Node) bool {
+	if n == nil {
+		return false
+	}
+	if t := strings.TrimSpace(n.TypeOverride()); t != "" {
+		return t == "agent"
+	}
+	return model.ShapeType(n.Shape()) == "agent"
+}
+
+func nodeAgentMode(n *model.Node) string {
+	if m := strings.ToLower(strings.TrimSpace(n.Attr("agent_mode", ""))); m != "" {
+		return m
+	}
+	return "agent_loop"
+}
+
+func sortedNodeIDs(g *model.Graph) []string {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// LoadHistory collects finished runs of graphName (newest first, up
+// to limit) from the run database. Token usage is read from each run's
+// progress.ndjson (llm_turn_end events) when its logs root still exists.
+func LoadHistory(db *rundb.DB, graphName string, limit int) ([]History, error) {
+	if db == nil || strings.TrimSpace(graphName) == "" {
+		return nil, nil
+	}
+	runs, err := db.ListRuns(rundb.ListFilter{GraphName: graphName})
+	if err != nil {
+		return nil, err
+	}
+	var out []History
+	for _, r := range runs {
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		// ListRuns matches graph names by substring.
+		if r.GraphName != graphName || r.Status == "running" {
+			continue
+		}
+		execs, err := db.GetNodeExecutions(r.RunID)
+		if err != nil {
+			return nil, err
+		}
+		h := History{RunID: r.RunID, Status: r.Status, Executions: map[string]int{}}
+		if r.DurationMS != nil {
+			h.DurationMS = *r.DurationMS
+		}
+		for _, ex := range execs {
+			h.Executions[ex.NodeID]++
+		}
+		h.InputTokens, h.OutputTokens = readProgressTokenUsage(r.LogsRoot)
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+// readProgressTokenUsage totals llm_turn_end token counts per node.
+func readProgressTokenUsage(logsRoot string) (in, out map[string]int64) {
+	in, out = map[string]int64{}, map[string]int64{}
+	if strings.TrimSpace(logsRoot) == "" {
+		return in, out
+	}
+	f, err := os.Open(filepath.Join(logsRoot, "progress.ndjson"))
+	if err != nil {
+		return in, out
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var ev struct {
+			Event        string `json:"event"`
+			NodeID       string `json:"node_id"`
+			InputTokens  int64  `json:"input_tokens"`
+			OutputTokens int64  `json:"output_tokens"`
+		}
+		if json.Unmarshal(sc.Bytes(), &ev) != nil || ev.Event != "llm_turn_end" || ev.NodeID == "" {
+			continue
+		}
+		if ev.InputTokens == 0 && ev.OutputTokens == 0 {
+			continue
+		}
+		in[ev.NodeID] += ev.InputTokens
+		out[ev.NodeID] += ev.OutputTokens
+	}
+	return in, out
+}
@@ -0,0 +1,129 @@
+package estimate
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/attractor/dot"
+	"github.com/danshapiro/kilroy/internal/attractor/modeldb"
+)
+
+func estimateCatalog() *modeldb.Catalog {
+	in, out := 1e-6, 10e-6
+	return &modeldb.Catalog{Models: map[string]modeldb.ModelEntry{
+		"openai/gpt-test": {Provider: "openai", ContextWindow: 200000, InputCostPerToken: &in, OutputCostPerToken: &out},
+	}}
+}
+
+const estimateDot = `digraph g {
+  graph [max_node_visits=4]
+  start [shape=Mdiamond]
+  plan [shape=box, llm_provider=openai, llm_model=gpt-test, agent_mode=one_shot, max_retries=0, max_tokens=1000, prompt="plan it"]
+  impl [shape=box, llm_provider=openai, llm_model=gpt-test, max_retries=1]
+  check [shape=parallelogram, tool_command="true"]
+  review [shape=box, llm_provider=acme, llm_model=mystery]
+  exit [shape=Msquare]
+  start -> plan -> impl -> check
+  check -> review [condition="outcome=success"]
+  check -> impl
+  review -> exit
+}`
+
+func almost(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestBuild_HeuristicRangesAndMultipliers(t *testing.T) {
+	g, err := dot.Parse([]byte(estimateDot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	est := Build(g, Options{Catalog: estimateCatalog()})
+	if len(est.Nodes) != 3 {
+		t.Fatalf("nodes = %+v", est.Nodes)
+	}
+	byID := map[string]NodeEstimate{}
+	for _, n := range est.Nodes {
+		byID[n.NodeID] = n
+	}
+
+	plan := byID["plan"]
+	if !plan.Priced || plan.TokenSource != "heuristic" || plan.Mode != "one_shot" {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if plan.OutputTokens.High != 1000 || plan.Executions != (Range{Low: 1, Expected: 1, High: 1}) {
+		t.Fatalf("plan output=%+v executions=%+v", plan.OutputTokens, plan.Executions)
+	}
+
+	impl := byID["impl"]
+	if impl.Mode != "agent_loop" || impl.Bound != "max_node_visits" || impl.MaxVisits != 3 || impl.MaxAttempts != 2 {
+		t.Fatalf("impl = %+v", impl)
+	}
+	if impl.Executions.High != 6 || !almost(impl.Executions.Expected, 2*(1+estimateRetryRate)) {
+		t.Fatalf("impl executions = %+v", impl.Executions)
+	}
+	wantHigh := (agentInputTokens.High*1e-6 + agentOutputTokens.High*10e-6) * 6
+	if !almost(impl.CostUSD.High, wantHigh) {
+		t.Fatalf("impl high cost = %v, want %v", impl.CostUSD.High, wantHigh)
+	}
+
+	if review := byID["review"]; review.Priced || review.CostUSD.High != 0 {
+		t.Fatalf("review = %+v", review)
+	}
+	if len(est.Warnings) != 1 || !strings.Contains(est.Warnings[0], "review") {
+		t.Fatalf("warnings = %v", est.Warnings)
+	}
+	if !almost(est.CostUSD.High, plan.CostUSD.High+impl.CostUSD.High) || est.CostUSD.Low > est.CostUSD.Expected || est.CostUSD.Expected > est.CostUSD.High {
+		t.Fatalf("total = %+v", est.CostUSD)
+	}
+}
+
+func TestBuild_HistoryOverridesTokensAndExecutions(t *testing.T) {
+	g, err := dot.Parse([]byte(estimateDot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := []History{
+		{RunID: "r1", DurationMS: 60000, Executions: map[string]int{"impl": 2},
+			InputTokens: map[string]int64{"impl": 40000}, OutputTokens: map[string]int64{"impl": 4000}},
+		{RunID: "r2", DurationMS: 120000, Executions: map[string]int{"impl": 1},
+			InputTokens: map[string]int64{"impl": 30000}, OutputTokens: map[string]int64{"impl": 1000}},
+	}
+	est := Build(g, Options{Catalog: estimateCatalog(), History: history})
+	var impl NodeEstimate
+	for _, n := range est.Nodes {
+		if n.NodeID == "impl" {
+			impl = n
+		}
+	}
+	if impl.TokenSource != "history" || impl.HistoryRuns != 2 {
+		t.Fatalf("impl = %+v", impl)
+	}
+	if impl.InputTokens != (Range{Low: 20000, Expected: 25000, High: 30000}) {
+		t.Fatalf("impl input = %+v", impl.InputTokens)
+	}
+	if impl.Executions != (Range{Low: 1, Expected: 1.5, High: 6}) {
+		t.Fatalf("impl executions = %+v", impl.Executions)
+	}
+	if est.DurationMS != (Range{Low: 60000, Expected: 90000, High: 120000}) {
+		t.Fatalf("duration = %+v", est.DurationMS)
+	}
+}
+
+func TestReadProgressTokenUsage_SumsTurnsPerNode(t *testing.T) {
+	root := t.TempDir()
+	lines := []string{
+		`{"event":"llm_turn_end","node_id":"impl","input_tokens":100,"output_tokens":10}`,
+		`{"event":"stage_attempt_end","node_id":"impl"}`,
+		`{"event":"llm_turn_end","node_id":"impl","input_tokens":200,"output_tokens":20}`,
+		`{"event":"llm_turn_end","node_id":"plan","input_tokens":5,"output_tokens":1}`,
+	}
+	if err := os.WriteFile(filepath.Join(root, "progress.ndjson"), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in, out := readProgressTokenUsage(root)
+	if in["impl"] != 300 || out["impl"] != 30 || in["plan"] != 5 {
+		t.Fatalf("in=%v out=%v", in, out)
+	}
+}
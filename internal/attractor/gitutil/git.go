@@ -60,6 +60,22 @@ func HeadSHA(dir string) (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
+// TreeSHA returns the tree object identifier for rev (e.g. "HEAD").
+func TreeSHA(dir, rev string) (string, error) {
+	out, _, err := runGit(dir, "rev-parse", rev+"^{tree}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ReadTree replaces the index and tracked working-tree files with the tree of
+// sha, leaving HEAD where it is so the change can be committed on top.
+func ReadTree(worktreeDir, sha string) error {
+	_, _, err := runGit(worktreeDir, "read-tree", "-u", "--reset", sha)
+	return err
+}
+
 func StatusPorcelain(dir string) (string, error) {
 	out, _, err := runGit(dir, "status", "--porcelain")
 	if err != nil {
@@ -89,3 +89,51 @@ func TestDiffNameOnly_NoChanges(t *testing.T) {
 		t.Errorf("DiffNameOnly with no changes = %v, want []", files)
 	}
 }
+
+func TestReadTree_RestoresCommitContentOnCurrentHead(t *testing.T) {
+	dir := initTestRepo(t)
+	baseSHA, err := HeadSHA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseTree, err := TreeSHA(dir, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Commit a change (add one file, delete another) and rewind to base.
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "initial.txt")); err != nil {
+		t.Fatal(err)
+	}
+	changedSHA, err := CommitAllowEmpty(dir, "change")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changedTree, _ := TreeSHA(dir, changedSHA)
+	if err := ResetHard(dir, baseSHA); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReadTree(dir, changedSHA); err != nil {
+		t.Fatalf("ReadTree: %v", err)
+	}
+	if head, _ := HeadSHA(dir); head != baseSHA {
+		t.Fatalf("HEAD moved to %s", head)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "initial.txt")); !os.IsNotExist(err) {
+		t.Fatalf("initial.txt should be removed, stat err=%v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "new.txt")); err != nil || string(b) != "new" {
+		t.Fatalf("new.txt = %q, %v", b, err)
+	}
+	restoredSHA, err := CommitAllowEmpty(dir, "restored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree, _ := TreeSHA(dir, restoredSHA); tree != changedTree || tree == baseTree {
+		t.Fatalf("restored tree %s, want %s", tree, changedTree)
+	}
+}
@@ -119,6 +119,45 @@ func (n *Node) TypeOverride() string {
 	return n.Attr("type", "")
 }
 
+// NodeRefAttrs are the node and graph attributes whose values name another
+// node, so they must follow a rename and point at an existing node.
+var NodeRefAttrs = []string{"retry_target", "fallback_retry_target"}
+
+// ShapeType maps a node shape to its handler type. Unknown shapes are agent
+// nodes.
+func ShapeType(shape string) string {
+	switch shape {
+	case "Mdiamond", "circle":
+		return "start"
+	case "Msquare", "doublecircle":
+		return "exit"
+	case "box":
+		return "agent"
+	case "hexagon":
+		return "wait.human"
+	case "diamond":
+		return "conditional"
+	case "component":
+		return "parallel"
+	case "tripleoctagon":
+		return "parallel.fan_in"
+	case "parallelogram":
+		return "tool"
+	case "house":
+		return "stack.manager_loop"
+	case "trapezium":
+		return "loop.begin"
+	case "invtrapezium":
+		return "loop.end"
+	case "pentagon":
+		return "concurrent.split"
+	case "cylinder":
+		return "concurrent.join"
+	default:
+		return "agent"
+	}
+}
+
 func (n *Node) Label() string {
 	lbl := n.Attr("label", "")
 	if lbl == "" {
@@ -27,3 +27,17 @@ func TestNode_Prompt_EmptyWhenNeitherSet(t *testing.T) {
 		t.Errorf("Prompt() = %q, want empty", got)
 	}
 }
+
+func TestShapeType(t *testing.T) {
+	for shape, want := range map[string]string{
+		"box":           "agent",
+		"parallelogram": "tool",
+		"house":         "stack.manager_loop",
+		"cylinder":      "concurrent.join",
+		"ellipse":       "agent",
+	} {
+		if got := ShapeType(shape); got != want {
+			t.Errorf("ShapeType(%q) = %q, want %q", shape, got, want)
+		}
+	}
+}
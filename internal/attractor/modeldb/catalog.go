@@ -38,6 +38,9 @@ type ModelEntry struct {
 
 	InputCostPerToken  *float64
 	OutputCostPerToken *float64
+
+	// Created is the catalog's release timestamp (unix seconds), 0 when unknown.
+	Created int64
 }
 
 // CatalogCoversProvider returns true when the catalog was loaded from a source
@@ -55,13 +58,20 @@ func CatalogCoversProvider(c *Catalog, provider string) bool {
 // provider/model pair. It accepts either canonical model IDs
 // ("openai/gpt-5.4") or provider-relative IDs ("gpt-5.4").
 func CatalogHasProviderModel(c *Catalog, provider, modelID string) bool {
+	_, _, ok := FindProviderModel(c, provider, modelID)
+	return ok
+}
+
+// FindProviderModel returns the catalog ID and entry for a provider/model
+// pair, matched the same way as CatalogHasProviderModel.
+func FindProviderModel(c *Catalog, provider, modelID string) (string, ModelEntry, bool) {
 	if c == nil || c.Models == nil {
-		return false
+		return "", ModelEntry{}, false
 	}
 	provider = modelmeta.NormalizeProvider(provider)
 	modelID = strings.TrimSpace(modelID)
 	if provider == "" || modelID == "" {
-		return false
+		return "", ModelEntry{}, false
 	}
 	inCanonical := canonicalModelID(provider, modelID)
 	inRelative := providerRelativeModelID(provider, modelID)
@@ -74,10 +84,10 @@ func CatalogHasProviderModel(c *Catalog, provider, modelID string) bool {
 			continue
 		}
 		if strings.EqualFold(canonicalModelID(provider, id), inCanonical) {
-			return true
+			return id, entry, true
 		}
 		if strings.EqualFold(providerRelativeModelID(provider, id), inRelative) {
-			return true
+			return id, entry, true
 		}
 	}
 	// Anthropic OpenRouter catalog uses dots in version numbers (claude-sonnet-4.5)
@@ -95,11 +105,11 @@ func CatalogHasProviderModel(c *Catalog, provider, modelID string) bool {
 			}
 			normEntry := versionDotRe.ReplaceAllString(providerRelativeModelID(provider, id), "${1}-${2}")
 			if strings.EqualFold(normEntry, normQuery) {
-				return true
+				return id, entry, true
 			}
 		}
 	}
-	return false
+	return "", ModelEntry{}, false
 }
 
 // ModelLookupStatus describes the result of looking up a model ID in the catalog.
@@ -62,6 +62,7 @@ func loadCatalogFromBytes(b []byte, source string) (*Catalog, error) {
 			SupportsVision:     modelmeta.ContainsFold(m.Architecture.InputModalities, "image") || modelmeta.ContainsFold(m.Architecture.OutputModalities, "image"),
 			InputCostPerToken:  modelmeta.ParseFloatStringPtr(m.Pricing.Prompt),
 			OutputCostPerToken: modelmeta.ParseFloatStringPtr(m.Pricing.Completion),
+			Created:            m.Created,
 		}
 	}
 
@@ -82,6 +83,7 @@ type openRouterPayload struct {
 
 type openRouterModel struct {
 	ID                  string   `json:"id"`
+	Created             int64    `json:"created"`
 	ContextLength       int      `json:"context_length"`
 	SupportedParameters []string `json:"supported_parameters"`
 	Architecture        struct {
@@ -0,0 +1,186 @@
+package modeldb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/modelmeta"
+)
+
+// Model preferences for auto(...) selectors.
+const (
+	PreferNewest   = "newest"
+	PreferCheapest = "cheapest"
+)
+
+// ModelRequirements is a parsed auto(...) model selector, e.g.
+//
+//	auto(min_context=400k, tools, reasoning, max_cost_per_mtok=5)
+type ModelRequirements struct {
+	MinContext int
+	Tools      bool
+	Vision     bool
+	Reasoning  bool
+
+	// MaxCostPerMTok caps the input price in USD per million tokens; 0 means
+	// no cap.
+	MaxCostPerMTok float64
+
+	// Prefer orders the models that qualify: PreferNewest (default) or
+	// PreferCheapest.
+	Prefer string
+}
+
+// IsAutoModelSpec reports whether an llm_model value is an auto(...) selector
+// rather than a model ID.
+func IsAutoModelSpec(v string) bool {
+	v = strings.TrimSpace(v)
+	return strings.HasPrefix(v, "auto(") && strings.HasSuffix(v, ")")
+}
+
+// ParseAutoModelSpec parses an auto(...) selector. Terms are comma-separated:
+// bare capability flags (tools, vision, reasoning) or key=value pairs
+// (min_context, max_cost_per_mtok, prefer). min_context accepts k/m suffixes.
+func ParseAutoModelSpec(v string) (ModelRequirements, error) {
+	v = strings.TrimSpace(v)
+	if !IsAutoModelSpec(v) {
+		return ModelRequirements{}, fmt.Errorf("not an auto(...) model selector: %q", v)
+	}
+	req := ModelRequirements{Prefer: PreferNewest}
+	body := strings.TrimSuffix(strings.TrimPrefix(v, "auto("), ")")
+	for _, term := range strings.Split(body, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		key, val, hasVal := strings.Cut(term, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+		switch {
+		case key == "tools" && !hasVal:
+			req.Tools = true
+		case key == "vision" && !hasVal:
+			req.Vision = true
+		case key == "reasoning" && !hasVal:
+			req.Reasoning = true
+		case key == "min_context" && hasVal:
+			n, err := parseTokenCount(val)
+			if err != nil {
+				return ModelRequirements{}, fmt.Errorf("auto(...): min_context: %w", err)
+			}
+			req.MinContext = n
+		case key == "max_cost_per_mtok" && hasVal:
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil || f <= 0 {
+				return ModelRequirements{}, fmt.Errorf("auto(...): max_cost_per_mtok must be a positive number, got %q", val)
+			}
+			req.MaxCostPerMTok = f
+		case key == "prefer" && hasVal:
+			switch strings.ToLower(val) {
+			case PreferNewest, PreferCheapest:
+				req.Prefer = strings.ToLower(val)
+			default:
+				return ModelRequirements{}, fmt.Errorf("auto(...): prefer must be %s or %s, got %q", PreferNewest, PreferCheapest, val)
+			}
+		default:
+			return ModelRequirements{}, fmt.Errorf("auto(...): unknown term %q", term)
+		}
+	}
+	return req, nil
+}
+
+func parseTokenCount(v string) (int, error) {
+	s := strings.ToLower(strings.TrimSpace(v))
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(s, "k"):
+		mult, s = 1e3, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		mult, s = 1e6, strings.TrimSuffix(s, "m")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("expected a token count like 200000 or 400k, got %q", v)
+	}
+	return int(f * mult), nil
+}
+
+// Matches reports whether a catalog entry meets the requirements.
+func (r ModelRequirements) Matches(e ModelEntry) bool {
+	if r.MinContext > 0 && e.ContextWindow < r.MinContext {
+		return false
+	}
+	if (r.Tools && !e.SupportsTools) || (r.Vision && !e.SupportsVision) || (r.Reasoning && !e.SupportsReasoning) {
+		return false
+	}
+	if r.MaxCostPerMTok > 0 && (e.InputCostPerToken == nil || *e.InputCostPerToken*1e6 > r.MaxCostPerMTok) {
+		return false
+	}
+	return true
+}
+
+// SelectModel picks the provider's catalog model that best meets req and
+// returns its provider-relative ID (the form llm_model takes) and entry.
+// Catalog variants such as ":free" or ":thinking" are not native model IDs
+// and are never chosen.
+func SelectModel(c *Catalog, provider string, req ModelRequirements) (string, ModelEntry, error) {
+	provider = modelmeta.NormalizeProvider(provider)
+	if provider == "" {
+		return "", ModelEntry{}, fmt.Errorf("auto(...) needs llm_provider to choose a model")
+	}
+	if !CatalogCoversProvider(c, provider) {
+		return "", ModelEntry{}, fmt.Errorf("model catalog has no models for provider %q", provider)
+	}
+	type candidate struct {
+		id    string
+		entry ModelEntry
+	}
+	var cands []candidate
+	for id, entry := range c.Models {
+		ep := modelmeta.NormalizeProvider(entry.Provider)
+		if ep == "" {
+			ep = inferProviderFromModelID(id)
+		}
+		if ep != provider || strings.Contains(id, ":") {
+			continue
+		}
+		if entry.Mode != "" && entry.Mode != "chat" {
+			continue
+		}
+		if req.Matches(entry) {
+			cands = append(cands, candidate{id: id, entry: entry})
+		}
+	}
+	if len(cands) == 0 {
+		return "", ModelEntry{}, fmt.Errorf("no %s model in the catalog meets the requirements", provider)
+	}
+	cost := func(e ModelEntry) float64 {
+		if e.InputCostPerToken == nil {
+			return 1 // unknown prices sort last
+		}
+		return *e.InputCostPerToken
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		a, b := cands[i].entry, cands[j].entry
+		if req.Prefer == PreferCheapest {
+			if cost(a) != cost(b) {
+				return cost(a) < cost(b)
+			}
+			if a.Created != b.Created {
+				return a.Created > b.Created
+			}
+		} else {
+			if a.Created != b.Created {
+				return a.Created > b.Created
+			}
+			if cost(a) != cost(b) {
+				return cost(a) < cost(b)
+			}
+		}
+		return cands[i].id < cands[j].id
+	})
+	best := cands[0]
+	return providerRelativeModelID(provider, best.id), best.entry, nil
+}
@@ -0,0 +1,54 @@
+package modeldb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAutoModelSpec(t *testing.T) {
+	req, err := ParseAutoModelSpec("auto(min_context=400k, tools, reasoning, max_cost_per_mtok=5)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ModelRequirements{MinContext: 400000, Tools: true, Reasoning: true, MaxCostPerMTok: 5, Prefer: PreferNewest}
+	if req != want {
+		t.Fatalf("req = %+v, want %+v", req, want)
+	}
+	if req, err = ParseAutoModelSpec("auto(min_context=1m, vision, prefer=cheapest)"); err != nil || req.MinContext != 1000000 || !req.Vision || req.Prefer != PreferCheapest {
+		t.Fatalf("req = %+v (%v)", req, err)
+	}
+	for _, bad := range []string{"auto(fast)", "auto(min_context=lots)", "auto(max_cost_per_mtok=-1)", "auto(prefer=smartest)", "gpt-5"} {
+		if _, err := ParseAutoModelSpec(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestSelectModel_NewestOrCheapestMatch(t *testing.T) {
+	price := func(perMTok float64) *float64 { v := perMTok / 1e6; return &v }
+	c := &Catalog{
+		CoveredProviders: map[string]bool{"anthropic": true, "openai": true},
+		Models: map[string]ModelEntry{
+			"anthropic/claude-opus-4.1":            {Provider: "anthropic", ContextWindow: 200000, SupportsTools: true, SupportsReasoning: true, InputCostPerToken: price(15), Created: 100},
+			"anthropic/claude-opus-4.6":            {Provider: "anthropic", ContextWindow: 1000000, SupportsTools: true, SupportsReasoning: true, InputCostPerToken: price(5), Created: 300},
+			"anthropic/claude-haiku-4.5":           {Provider: "anthropic", ContextWindow: 200000, SupportsTools: true, SupportsReasoning: true, InputCostPerToken: price(1), Created: 200},
+			"anthropic/claude-3.7-sonnet:thinking": {Provider: "anthropic", ContextWindow: 1000000, SupportsTools: true, SupportsReasoning: true, InputCostPerToken: price(1), Created: 400},
+			"openai/gpt-5.4":                       {Provider: "openai", ContextWindow: 400000, SupportsTools: true, SupportsReasoning: true, InputCostPerToken: price(2), Created: 500},
+		},
+	}
+
+	id, _, err := SelectModel(c, "anthropic", ModelRequirements{Tools: true, Reasoning: true, MaxCostPerMTok: 5})
+	if err != nil || id != "claude-opus-4.6" {
+		t.Fatalf("newest = %q (%v)", id, err)
+	}
+	id, _, err = SelectModel(c, "anthropic", ModelRequirements{Tools: true, Prefer: PreferCheapest})
+	if err != nil || id != "claude-haiku-4.5" {
+		t.Fatalf("cheapest = %q (%v)", id, err)
+	}
+	if _, _, err = SelectModel(c, "anthropic", ModelRequirements{MinContext: 2000000}); err == nil || !strings.Contains(err.Error(), "no anthropic model") {
+		t.Fatalf("expected no-match error, got %v", err)
+	}
+	if _, _, err = SelectModel(c, "google", ModelRequirements{}); err == nil {
+		t.Fatal("expected error for a provider the catalog does not cover")
+	}
+}
@@ -0,0 +1,16 @@
+-- Node result cache index for opt-in memoization (cache=true).
+-- Each row maps a hash of a node's resolved inputs to the outcome it produced
+-- and the checkpoint commit holding its file changes, so a later run with the
+-- same inputs can restore the result instead of executing the node.
+
+CREATE TABLE IF NOT EXISTS node_cache (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    cache_key     TEXT NOT NULL,
+    run_id        TEXT NOT NULL REFERENCES runs(run_id) ON DELETE CASCADE,
+    node_id       TEXT NOT NULL,
+    tree_sha      TEXT NOT NULL,
+    after_sha     TEXT NOT NULL,
+    outcome_json  TEXT NOT NULL,
+    recorded_at   TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+CREATE INDEX IF NOT EXISTS idx_node_cache_key ON node_cache(cache_key);
@@ -0,0 +1,4 @@
+-- Link forked runs to the run and node they were forked from.
+ALTER TABLE runs ADD COLUMN parent_run_id TEXT;
+ALTER TABLE runs ADD COLUMN fork_node_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_runs_parent ON runs(parent_run_id);
@@ -0,0 +1,3 @@
+-- Concurrent branch a node execution ran in ('' outside concurrent regions),
+-- so timelines can keep each branch on its own lane.
+ALTER TABLE node_executions ADD COLUMN branch_id TEXT NOT NULL DEFAULT '';
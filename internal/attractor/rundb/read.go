@@ -32,6 +32,8 @@ type RunSummary struct {
 	NodeCount     int               `json:"node_count"`
 	Invocation    []string          `json:"invocation,omitempty"`
 	Config        map[string]any    `json:"config,omitempty"`
+	ParentRunID   string            `json:"parent_run_id,omitempty"`
+	ForkNodeID    string            `json:"fork_node_id,omitempty"`
 }
 
 // LatestRun returns the most recently started run.
@@ -73,11 +75,12 @@ func (d *DB) GetRun(runID string) (*RunSummary, error) {
 
 // ListFilter specifies filtering criteria for run listing.
 type ListFilter struct {
-	Status    string            // filter by status
-	Labels    map[string]string // filter by label key=value
-	GraphName string            // filter by graph name pattern
-	Sort      string            // "newest" (default), "oldest", "longest"
-	Limit     int               // max results (0 = no limit)
+	Status      string            // filter by status
+	Labels      map[string]string // filter by label key=value
+	GraphName   string            // filter by graph name pattern
+	ParentRunID string            // filter to runs forked from this run
+	Sort        string            // "newest" (default), "oldest", "longest"
+	Limit       int               // max results (0 = no limit)
 }
 
 // ListRuns returns runs matching the filter, newest first.
@@ -93,6 +96,10 @@ func (d *DB) ListRuns(f ListFilter) ([]RunSummary, error) {
 		where = append(where, "graph_name LIKE ?")
 		args = append(args, "%"+f.GraphName+"%")
 	}
+	if f.ParentRunID != "" {
+		where = append(where, "parent_run_id = ?")
+		args = append(args, f.ParentRunID)
+	}
 	for k, v := range f.Labels {
 		where = append(where, "json_extract(labels_json, ?) = ?")
 		args = append(args, "$."+k, v)
@@ -210,6 +217,7 @@ type NodeExecutionSummary struct {
 	FailureReason string     `json:"failure_reason,omitempty"`
 	FailureClass  string     `json:"failure_class,omitempty"`
 	Notes         string     `json:"notes,omitempty"`
+	BranchID      string     `json:"branch_id,omitempty"`
 }
 
 // GetNodeAttempts returns all attempts for a specific node in a run, ordered
@@ -217,7 +225,7 @@ type NodeExecutionSummary struct {
 // for loop/retry history.
 func (d *DB) GetNodeAttempts(runID, nodeID string) ([]NodeExecutionSummary, error) {
 	rows, err := d.db.Query(`SELECT node_id, attempt, handler_type, status,
-		started_at, completed_at, duration_ms, failure_reason, failure_class, notes
+		started_at, completed_at, duration_ms, failure_reason, failure_class, notes, branch_id
 		FROM node_executions WHERE run_id = ? AND node_id = ?
 		ORDER BY id ASC`, runID, nodeID)
 	if err != nil {
@@ -231,7 +239,7 @@ func (d *DB) GetNodeAttempts(runID, nodeID string) ([]NodeExecutionSummary, erro
 		var completedAt, failureReason, failureClass, notes sql.NullString
 		var durationMS sql.NullInt64
 		if err := rows.Scan(&n.NodeID, &n.Attempt, &n.HandlerType, &n.Status,
-			&startedAt, &completedAt, &durationMS, &failureReason, &failureClass, &notes); err != nil {
+			&startedAt, &completedAt, &durationMS, &failureReason, &failureClass, &notes, &n.BranchID); err != nil {
 			return nil, err
 		}
 		n.StartedAt, _ = time.Parse(time.RFC3339Nano, startedAt)
@@ -253,7 +261,7 @@ func (d *DB) GetNodeAttempts(runID, nodeID string) ([]NodeExecutionSummary, erro
 // GetNodeExecutions returns all node executions for a run.
 func (d *DB) GetNodeExecutions(runID string) ([]NodeExecutionSummary, error) {
 	rows, err := d.db.Query(`SELECT node_id, attempt, handler_type, status,
-		started_at, completed_at, duration_ms, failure_reason, failure_class, notes
+		started_at, completed_at, duration_ms, failure_reason, failure_class, notes, branch_id
 		FROM node_executions WHERE run_id = ? ORDER BY id ASC`, runID)
 	if err != nil {
 		return nil, err
@@ -267,7 +275,7 @@ func (d *DB) GetNodeExecutions(runID string) ([]NodeExecutionSummary, error) {
 		var completedAt, failureReason, failureClass, notes sql.NullString
 		var durationMS sql.NullInt64
 		if err := rows.Scan(&n.NodeID, &n.Attempt, &n.HandlerType, &n.Status,
-			&startedAt, &completedAt, &durationMS, &failureReason, &failureClass, &notes); err != nil {
+			&startedAt, &completedAt, &durationMS, &failureReason, &failureClass, &notes, &n.BranchID); err != nil {
 			return nil, err
 		}
 		n.StartedAt, _ = time.Parse(time.RFC3339Nano, startedAt)
@@ -515,6 +523,22 @@ func (d *DB) GetDotSource(runID string) string {
 	return src
 }
 
+// LookupNodeCache satisfies engine.NodeCacheStore. It returns the most recent
+// cache entry for cacheKey recorded by a run other than excludeRunID; runID is
+// empty when there is none.
+func (d *DB) LookupNodeCache(cacheKey, excludeRunID string) (runID, afterSHA string, outcomeJSON []byte, err error) {
+	var outcome string
+	err = d.db.QueryRow(`SELECT run_id, after_sha, outcome_json FROM node_cache
+		WHERE cache_key = ? AND run_id != ? ORDER BY id DESC LIMIT 1`, cacheKey, excludeRunID).Scan(&runID, &afterSHA, &outcome)
+	if err == sql.ErrNoRows {
+		return "", "", nil, nil
+	}
+	if err != nil {
+		return "", "", nil, err
+	}
+	return runID, afterSHA, []byte(outcome), nil
+}
+
 // ReconcileStaleRuns marks runs stuck in "running" status as "interrupted"
 // if they were started more than maxAge ago. Called on server startup.
 func (d *DB) ReconcileStaleRuns(maxAge time.Duration) (int, error) {
@@ -534,7 +558,7 @@ func (d *DB) queryRuns(clause string, args []any) ([]RunSummary, error) {
 	q := `SELECT r.run_id, r.graph_name, r.goal, r.status, r.logs_root,
 		r.worktree_dir, r.run_branch, r.repo_path, r.started_at, r.completed_at,
 		r.duration_ms, r.final_sha, r.failure_reason, r.labels_json, r.inputs_json,
-		r.warnings_json, r.invocation_json, r.config_json,
+		r.warnings_json, r.invocation_json, r.config_json, r.parent_run_id, r.fork_node_id,
 		(SELECT COUNT(*) FROM node_executions ne WHERE ne.run_id = r.run_id) as node_count
 		FROM runs r ` + clause
 
@@ -548,12 +572,12 @@ func (d *DB) queryRuns(clause string, args []any) ([]RunSummary, error) {
 	for rows.Next() {
 		var s RunSummary
 		var startedAt string
-		var completedAt, finalSHA, failureReason, labelsJSON, inputsJSON, warningsJSON, invocationJSON, configJSON sql.NullString
+		var completedAt, finalSHA, failureReason, labelsJSON, inputsJSON, warningsJSON, invocationJSON, configJSON, parentRunID, forkNodeID sql.NullString
 		var durationMS sql.NullInt64
 		if err := rows.Scan(&s.RunID, &s.GraphName, &s.Goal, &s.Status, &s.LogsRoot,
 			&s.WorktreeDir, &s.RunBranch, &s.RepoPath, &startedAt, &completedAt,
 			&durationMS, &finalSHA, &failureReason, &labelsJSON, &inputsJSON,
-			&warningsJSON, &invocationJSON, &configJSON, &s.NodeCount); err != nil {
+			&warningsJSON, &invocationJSON, &configJSON, &parentRunID, &forkNodeID, &s.NodeCount); err != nil {
 			return nil, err
 		}
 		s.StartedAt, _ = time.Parse(time.RFC3339Nano, startedAt)
@@ -566,6 +590,8 @@ func (d *DB) queryRuns(clause string, args []any) ([]RunSummary, error) {
 		}
 		s.FinalSHA = finalSHA.String
 		s.FailureReason = failureReason.String
+		s.ParentRunID = parentRunID.String
+		s.ForkNodeID = forkNodeID.String
 		if labelsJSON.Valid {
 			_ = json.Unmarshal([]byte(labelsJSON.String), &s.Labels)
 		}
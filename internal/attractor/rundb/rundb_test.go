@@ -2,6 +2,7 @@
 package rundb
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -318,6 +319,116 @@ func TestCascadeDelete_NodesDeletedWithRun(t *testing.T) {
 	}
 }
 
+func TestNodeCache_RecordAndLookup(t *testing.T) {
+	db := openTestDB(t)
+	_ = db.InsertRun(RunRecord{RunID: "r1", Status: "success", StartedAt: time.Now()})
+	_ = db.InsertRun(RunRecord{RunID: "r2", Status: "success", StartedAt: time.Now()})
+
+	runID, _, _, err := db.LookupNodeCache("k1", "")
+	if err != nil || runID != "" {
+		t.Fatalf("expected miss, got run %q err %v", runID, err)
+	}
+	if err := db.RecordNodeCache("k1", "r1", "a", "tree1", "sha1", []byte(`{"status":"success"}`)); err != nil {
+		t.Fatalf("RecordNodeCache: %v", err)
+	}
+	if err := db.RecordNodeCache("k1", "r2", "a", "tree1", "sha2", []byte(`{"status":"partial_success"}`)); err != nil {
+		t.Fatalf("RecordNodeCache: %v", err)
+	}
+	runID, afterSHA, outcome, err := db.LookupNodeCache("k1", "")
+	if err != nil {
+		t.Fatalf("LookupNodeCache: %v", err)
+	}
+	if runID != "r2" || afterSHA != "sha2" || string(outcome) != `{"status":"partial_success"}` {
+		t.Fatalf("expected latest entry, got %q %q %s", runID, afterSHA, outcome)
+	}
+	if runID, _, _, _ := db.LookupNodeCache("k1", "r2"); runID != "r1" {
+		t.Fatalf("expected r2 to be excluded, got %q", runID)
+	}
+
+	// Entries go away with their run.
+	if _, err := db.SQL().Exec("DELETE FROM runs WHERE run_id = 'r2'"); err != nil {
+		t.Fatalf("delete run: %v", err)
+	}
+	if runID, _, _, _ := db.LookupNodeCache("k1", ""); runID != "r1" {
+		t.Fatalf("expected fallback to r1 after delete, got %q", runID)
+	}
+}
+
+func TestNodeCache_Retention(t *testing.T) {
+	db := openTestDB(t)
+	_ = db.InsertRun(RunRecord{RunID: "g1", GraphName: "build", Status: "success", StartedAt: time.Now()})
+	_ = db.InsertRun(RunRecord{RunID: "g2", GraphName: "deploy", Status: "success", StartedAt: time.Now()})
+	_ = db.RecordNodeCache("other-graph", "g2", "a", "t", "s", []byte(`{}`))
+	_ = db.RecordNodeCache("other-node", "g1", "b", "t", "s", []byte(`{}`))
+	for i := 0; i < NodeCacheKeepPerNode+5; i++ {
+		if err := db.RecordNodeCache(fmt.Sprintf("k%d", i), "g1", "a", "t", "s", []byte(`{}`)); err != nil {
+			t.Fatalf("RecordNodeCache: %v", err)
+		}
+	}
+	count := func(where string) int {
+		var n int
+		_ = db.SQL().QueryRow("SELECT COUNT(*) FROM node_cache WHERE " + where).Scan(&n)
+		return n
+	}
+	if n := count("run_id = 'g1' AND node_id = 'a'"); n != NodeCacheKeepPerNode {
+		t.Fatalf("kept %d entries for build/a, want %d", n, NodeCacheKeepPerNode)
+	}
+	if runID, _, _, _ := db.LookupNodeCache("k0", ""); runID != "" {
+		t.Fatalf("oldest entry should be pruned, got %q", runID)
+	}
+	if count("cache_key IN ('other-graph', 'other-node')") != 2 {
+		t.Fatal("entries for other graphs or nodes must be kept")
+	}
+
+	// Entries past the max age go on the next write.
+	if _, err := db.SQL().Exec(`UPDATE node_cache SET recorded_at = '2000-01-01T00:00:00.000Z' WHERE cache_key = 'other-graph'`); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.RecordNodeCache("fresh", "g1", "b", "t", "s", []byte(`{}`))
+	if count("cache_key = 'other-graph'") != 0 {
+		t.Fatal("expired entry not pruned")
+	}
+}
+
+func TestRecordNodeBranch(t *testing.T) {
+	db := openTestDB(t)
+	_ = db.InsertRun(RunRecord{RunID: "r1", Status: "running", StartedAt: time.Now()})
+	_, _ = db.InsertNodeStart("r1", "split", 1, "concurrent.split")
+	id, _ := db.InsertNodeStart("r1", "a", 1, "codergen")
+	if err := db.RecordNodeBranch(id, "a"); err != nil {
+		t.Fatalf("RecordNodeBranch: %v", err)
+	}
+	nodes, err := db.GetNodeExecutions("r1")
+	if err != nil || len(nodes) != 2 {
+		t.Fatalf("nodes = %+v, err %v", nodes, err)
+	}
+	if nodes[0].BranchID != "" || nodes[1].BranchID != "a" {
+		t.Fatalf("branch ids = %q, %q", nodes[0].BranchID, nodes[1].BranchID)
+	}
+}
+
+func TestRecordRunFork_LinksChildToParent(t *testing.T) {
+	db := openTestDB(t)
+	_ = db.InsertRun(RunRecord{RunID: "parent", Status: "success", StartedAt: time.Now()})
+	_ = db.InsertRun(RunRecord{RunID: "child", Status: "running", StartedAt: time.Now()})
+	_ = db.InsertRun(RunRecord{RunID: "other", Status: "running", StartedAt: time.Now()})
+
+	if err := db.RecordRunFork("child", "parent", "implement"); err != nil {
+		t.Fatalf("RecordRunFork: %v", err)
+	}
+	run, _ := db.GetRun("child")
+	if run.ParentRunID != "parent" || run.ForkNodeID != "implement" {
+		t.Fatalf("fork link = %q@%q, want parent@implement", run.ParentRunID, run.ForkNodeID)
+	}
+	forks, err := db.ListRuns(ListFilter{ParentRunID: "parent"})
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	if len(forks) != 1 || forks[0].RunID != "child" {
+		t.Fatalf("forks of parent = %v, want [child]", forks)
+	}
+}
+
 func init() {
 	// Suppress unused import warning.
 	_ = os.Stat
@@ -46,6 +46,13 @@ func (d *DB) RecordNodeStart(runID, nodeID string, attempt int, handlerType stri
 	return d.InsertNodeStart(runID, nodeID, attempt, handlerType)
 }
 
+// RecordNodeBranch satisfies engine.NodeBranchRecorder. It tags a node
+// execution with the concurrent branch it ran in.
+func (d *DB) RecordNodeBranch(id int64, branchID string) error {
+	_, err := d.db.Exec(`UPDATE node_executions SET branch_id = ? WHERE id = ?`, branchID, id)
+	return err
+}
+
 // RecordNodeComplete satisfies engine.RunDBWriter. Delegates to CompleteNode.
 func (d *DB) RecordNodeComplete(id int64, status, failureReason, failureClass, preferredLabel, notes string, contextUpdates map[string]any) error {
 	return d.CompleteNode(id, status, failureReason, failureClass, preferredLabel, notes, contextUpdates)
@@ -193,3 +200,45 @@ func (d *DB) RecordNodeDiff(runID, nodeID string, attempt int, beforeSHA, afterS
 		runID, nodeID, attempt, beforeSHA, afterSHA, filesChanged, insertions, deletions)
 	return err
 }
+
+// Node cache retention: entries older than NodeCacheMaxAge are dropped, and
+// each node of a graph keeps only its NodeCacheKeepPerNode newest entries.
+const (
+	NodeCacheMaxAge      = 30 * 24 * time.Hour
+	NodeCacheKeepPerNode = 20
+)
+
+// RecordNodeCache satisfies engine.NodeCacheStore. It indexes a node's
+// outcome and checkpoint commit under the hash of its resolved inputs, then
+// applies the retention limits.
+func (d *DB) RecordNodeCache(cacheKey, runID, nodeID, treeSHA, afterSHA string, outcomeJSON []byte) error {
+	if _, err := d.db.Exec(`INSERT INTO node_cache
+		(cache_key, run_id, node_id, tree_sha, after_sha, outcome_json)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		cacheKey, runID, nodeID, treeSHA, afterSHA, string(outcomeJSON)); err != nil {
+		return err
+	}
+	return d.pruneNodeCache(runID, nodeID)
+}
+
+// pruneNodeCache enforces node cache retention for nodeID in runID's graph.
+func (d *DB) pruneNodeCache(runID, nodeID string) error {
+	cutoff := time.Now().Add(-NodeCacheMaxAge).UTC().Format("2006-01-02T15:04:05.000Z")
+	if _, err := d.db.Exec(`DELETE FROM node_cache WHERE recorded_at < ?`, cutoff); err != nil {
+		return err
+	}
+	_, err := d.db.Exec(`DELETE FROM node_cache WHERE id IN (
+		SELECT c.id FROM node_cache c JOIN runs r ON r.run_id = c.run_id
+		WHERE c.node_id = ? AND r.graph_name = (SELECT graph_name FROM runs WHERE run_id = ?)
+		ORDER BY c.id DESC LIMIT -1 OFFSET ?)`,
+		nodeID, runID, NodeCacheKeepPerNode)
+	return err
+}
+
+// RecordRunFork satisfies engine.RunDBWriter. It links a forked run to the
+// run and node it was forked from.
+func (d *DB) RecordRunFork(runID, parentRunID, nodeID string) error {
+	_, err := d.db.Exec(`UPDATE runs SET parent_run_id = ?, fork_node_id = ? WHERE run_id = ?`,
+		parentRunID, nodeID, runID)
+	return err
+}
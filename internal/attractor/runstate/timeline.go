@@ -0,0 +1,392 @@
+package runstate
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/danshapiro/kilroy/internal/attractor/dot"
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+)
+
+// MinIdleGap is the shortest stretch with no node executing that the
+// timeline reports as an idle gap.
+const MinIdleGap = time.Second
+
+// TimelineSpan is one node attempt placed on the run's wall clock.
+type TimelineSpan struct {
+	NodeID        string    `json:"node_id"`
+	Attempt       int       `json:"attempt"`
+	Branch        string    `json:"branch,omitempty"` // concurrent branch ID
+	HandlerType   string    `json:"handler_type"`
+	Status        string    `json:"status"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	OffsetMS      int64     `json:"offset_ms"`
+	DurationMS    int64     `json:"duration_ms"`
+	Lane          int       `json:"lane"`
+	Running       bool      `json:"running,omitempty"`
+	HumanWait     bool      `json:"human_wait,omitempty"`
+	Critical      bool      `json:"critical,omitempty"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+}
+
+// TimelineGap is a stretch of wall-clock time where no node was executing.
+type TimelineGap struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	OffsetMS   int64     `json:"offset_ms"`
+	DurationMS int64     `json:"duration_ms"`
+	After      string    `json:"after,omitempty"`  // node that finished before the gap
+	Before     string    `json:"before,omitempty"` // node that started after the gap
+}
+
+// Timeline lays out a run's node attempts on its wall clock.
+type Timeline struct {
+	RunID          string         `json:"run_id"`
+	GraphName      string         `json:"graph_name,omitempty"`
+	Status         string         `json:"status"`
+	StartedAt      time.Time      `json:"started_at"`
+	EndedAt        time.Time      `json:"ended_at"`
+	WallMS         int64          `json:"wall_ms"`
+	BusyMS         int64          `json:"busy_ms"`
+	IdleMS         int64          `json:"idle_ms"`
+	HumanWaitMS    int64          `json:"human_wait_ms"`
+	CriticalPathMS int64          `json:"critical_path_ms"`
+	Lanes          int            `json:"lanes"`
+	Retries        int            `json:"retries"`
+	Spans          []TimelineSpan `json:"spans"`
+	CriticalPath   []int          `json:"critical_path"` // indexes into Spans, in execution order
+	IdleGaps       []TimelineGap  `json:"idle_gaps"`
+}
+
+// BuildTimeline derives the timeline for run from its node executions.
+// dotSource (may be empty) supplies graph edges so the critical path follows
+// real dependencies; without it the path is inferred from timing alone.
+// Attempts still in flight are drawn up to now.
+func BuildTimeline(run *rundb.RunSummary, execs []rundb.NodeExecutionSummary, dotSource string, now time.Time) *Timeline {
+	tl := &Timeline{
+		RunID:        run.RunID,
+		GraphName:    run.GraphName,
+		Status:       run.Status,
+		StartedAt:    run.StartedAt,
+		EndedAt:      now,
+		Spans:        []TimelineSpan{},
+		CriticalPath: []int{},
+		IdleGaps:     []TimelineGap{},
+	}
+	if run.CompletedAt != nil {
+		tl.EndedAt = *run.CompletedAt
+	}
+
+	for _, ex := range execs {
+		sp := TimelineSpan{
+			NodeID:        ex.NodeID,
+			Attempt:       ex.Attempt,
+			Branch:        ex.BranchID,
+			HandlerType:   ex.HandlerType,
+			Status:        ex.Status,
+			Start:         ex.StartedAt,
+			HumanWait:     ex.HandlerType == "wait.human",
+			FailureReason: ex.FailureReason,
+		}
+		switch {
+		case ex.CompletedAt != nil:
+			sp.End = *ex.CompletedAt
+		case ex.DurationMS != nil:
+			sp.End = ex.StartedAt.Add(time.Duration(*ex.DurationMS) * time.Millisecond)
+		default:
+			sp.End = tl.EndedAt
+			sp.Running = run.Status == "running"
+		}
+		if sp.End.Before(sp.Start) {
+			sp.End = sp.Start
+		}
+		if ex.Attempt > 1 {
+			tl.Retries++
+		}
+		tl.Spans = append(tl.Spans, sp)
+	}
+	sort.SliceStable(tl.Spans, func(i, j int) bool { return tl.Spans[i].Start.Before(tl.Spans[j].Start) })
+	if len(tl.Spans) > 0 && tl.Spans[0].Start.Before(tl.StartedAt) {
+		tl.StartedAt = tl.Spans[0].Start
+	}
+	for i := range tl.Spans {
+		if tl.Spans[i].End.After(tl.EndedAt) {
+			tl.EndedAt = tl.Spans[i].End
+		}
+	}
+	tl.WallMS = tl.EndedAt.Sub(tl.StartedAt).Milliseconds()
+
+	// Lanes: each attempt takes the lowest lane free at its start, so
+	// concurrent and parallel work fans out into extra lanes. A concurrent
+	// branch holds its lane until its last attempt ends, so the branch
+	// reads as one row even when siblings start between its nodes.
+	branchEnds := map[string]time.Time{}
+	for _, sp := range tl.Spans {
+		if sp.Branch != "" && sp.End.After(branchEnds[sp.Branch]) {
+			branchEnds[sp.Branch] = sp.End
+		}
+	}
+	branchLanes := map[string]int{}
+	var laneEnds []time.Time
+	for i := range tl.Spans {
+		sp := &tl.Spans[i]
+		sp.OffsetMS = sp.Start.Sub(tl.StartedAt).Milliseconds()
+		sp.DurationMS = sp.End.Sub(sp.Start).Milliseconds()
+		if sp.HumanWait {
+			tl.HumanWaitMS += sp.DurationMS
+		}
+		if lane, ok := branchLanes[sp.Branch]; ok && sp.Branch != "" {
+			sp.Lane = lane
+			continue
+		}
+		lane := -1
+		for l, end := range laneEnds {
+			if !end.After(sp.Start) {
+				lane = l
+				break
+			}
+		}
+		if lane < 0 {
+			lane = len(laneEnds)
+			laneEnds = append(laneEnds, time.Time{})
+		}
+		laneEnds[lane] = sp.End
+		if sp.Branch != "" {
+			branchLanes[sp.Branch] = lane
+			laneEnds[lane] = branchEnds[sp.Branch]
+		}
+		sp.Lane = lane
+	}
+	tl.Lanes = len(laneEnds)
+
+	tl.buildIdleGaps()
+	tl.buildCriticalPath(graphPredecessors(dotSource))
+	return tl
+}
+
+// buildIdleGaps walks the union of attempt intervals and records the
+// uncovered stretches (including before the first and after the last attempt).
+func (tl *Timeline) buildIdleGaps() {
+	cursor := tl.StartedAt
+	after := ""
+	addGap := func(end time.Time, before string) {
+		d := end.Sub(cursor)
+		if d < MinIdleGap {
+			return
+		}
+		tl.IdleGaps = append(tl.IdleGaps, TimelineGap{
+			Start:      cursor,
+			End:        end,
+			OffsetMS:   cursor.Sub(tl.StartedAt).Milliseconds(),
+			DurationMS: d.Milliseconds(),
+			After:      after,
+			Before:     before,
+		})
+		tl.IdleMS += d.Milliseconds()
+	}
+	for _, sp := range tl.Spans {
+		if sp.Start.After(cursor) {
+			addGap(sp.Start, sp.NodeID)
+			tl.BusyMS += sp.End.Sub(sp.Start).Milliseconds()
+			cursor, after = sp.End, sp.NodeID
+			continue
+		}
+		if sp.End.After(cursor) {
+			tl.BusyMS += sp.End.Sub(cursor).Milliseconds()
+			cursor, after = sp.End, sp.NodeID
+		}
+	}
+	if tl.Status != "running" {
+		addGap(tl.EndedAt, "")
+	}
+}
+
+// buildCriticalPath walks back from the attempt that finished last. Each step
+// picks the latest-finishing attempt that ended before the current one
+// started, preferring graph predecessors and earlier attempts of the same
+// node over unrelated work that merely happened to finish later.
+func (tl *Timeline) buildCriticalPath(preds map[string]map[string]bool) {
+	if len(tl.Spans) == 0 {
+		return
+	}
+	cur := 0
+	for i, sp := range tl.Spans {
+		if !sp.End.Before(tl.Spans[cur].End) {
+			cur = i
+		}
+	}
+	var path []int
+	for cur >= 0 {
+		path = append(path, cur)
+		start := tl.Spans[cur].Start
+		node := tl.Spans[cur].NodeID
+		best, bestRelated := -1, -1
+		for i, sp := range tl.Spans {
+			if i == cur || sp.End.After(start) || !sp.Start.Before(start) {
+				continue
+			}
+			if best < 0 || sp.End.After(tl.Spans[best].End) {
+				best = i
+			}
+			if sp.NodeID == node || preds[node][sp.NodeID] {
+				if bestRelated < 0 || sp.End.After(tl.Spans[bestRelated].End) {
+					bestRelated = i
+				}
+			}
+		}
+		if bestRelated >= 0 {
+			best = bestRelated
+		}
+		cur = best
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		idx := path[i]
+		tl.Spans[idx].Critical = true
+		tl.CriticalPath = append(tl.CriticalPath, idx)
+		tl.CriticalPathMS += tl.Spans[idx].DurationMS
+	}
+}
+
+func graphPredecessors(dotSource string) map[string]map[string]bool {
+	preds := map[string]map[string]bool{}
+	if strings.TrimSpace(dotSource) == "" {
+		return preds
+	}
+	g, err := dot.Parse([]byte(dotSource))
+	if err != nil {
+		return preds
+	}
+	for _, e := range g.Edges {
+		if preds[e.To] == nil {
+			preds[e.To] = map[string]bool{}
+		}
+		preds[e.To][e.From] = true
+	}
+	return preds
+}
+
+// WriteText renders the timeline as a text Gantt chart with bars scaled to
+// width columns. Critical-path attempts are drawn with '#', other attempts
+// with '=', human waits with '~'; idle gaps are listed between rows.
+func (tl *Timeline) WriteText(w io.Writer, width int) {
+	if width < 10 {
+		width = 10
+	}
+	fmt.Fprintf(w, "run %s (%s)  wall %s  busy %s  idle %s  human wait %s\n",
+		tl.RunID, tl.Status, FormatMS(tl.WallMS), FormatMS(tl.BusyMS), FormatMS(tl.IdleMS), FormatMS(tl.HumanWaitMS))
+	fmt.Fprintf(w, "critical path %s over %d attempt(s)  lanes %d  retries %d\n",
+		FormatMS(tl.CriticalPathMS), len(tl.CriticalPath), tl.Lanes, tl.Retries)
+	if len(tl.Spans) == 0 {
+		fmt.Fprintln(w, "(no node executions recorded)")
+		return
+	}
+
+	nameWidth := 4
+	for _, sp := range tl.Spans {
+		if n := utf8.RuneCountInString(spanLabel(sp)); n > nameWidth {
+			nameWidth = n
+		}
+	}
+	if nameWidth > 32 {
+		nameWidth = 32
+	}
+	col := func(ms int64) int {
+		if tl.WallMS <= 0 {
+			return 0
+		}
+		c := int(ms * int64(width) / tl.WallMS)
+		if c > width {
+			c = width
+		}
+		return c
+	}
+
+	axis := "0" + strings.Repeat(" ", width)
+	end := FormatMS(tl.WallMS)
+	if len(end) < width {
+		axis = axis[:width-len(end)] + end
+	} else {
+		axis = axis[:width]
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  %-*s %4s %8s %8s  %-9s |%s|\n", nameWidth, "node", "lane", "start", "dur", "status", axis)
+	gi := 0
+	for _, sp := range tl.Spans {
+		for gi < len(tl.IdleGaps) && tl.IdleGaps[gi].OffsetMS <= sp.OffsetMS {
+			g := tl.IdleGaps[gi]
+			fmt.Fprintf(w, "  %-*s %4s %8s %8s  %-9s |%s|\n", nameWidth, "(idle)", "", "+"+FormatMS(g.OffsetMS), FormatMS(g.DurationMS), "", bar(col(g.OffsetMS), col(g.OffsetMS+g.DurationMS), width, '.'))
+			gi++
+		}
+		ch := '='
+		switch {
+		case sp.HumanWait:
+			ch = '~'
+		case sp.Critical:
+			ch = '#'
+		}
+		mark := " "
+		if sp.Critical {
+			mark = "*"
+		}
+		status := sp.Status
+		if sp.Running {
+			status = "running"
+		}
+		label := truncateRunes(spanLabel(sp), nameWidth)
+		fmt.Fprintf(w, "%s %-*s %4d %8s %8s  %-9s |%s|\n", mark, nameWidth, label, sp.Lane, "+"+FormatMS(sp.OffsetMS), FormatMS(sp.DurationMS), status, bar(col(sp.OffsetMS), col(sp.OffsetMS+sp.DurationMS), width, ch))
+	}
+	for ; gi < len(tl.IdleGaps); gi++ {
+		g := tl.IdleGaps[gi]
+		fmt.Fprintf(w, "  %-*s %4s %8s %8s  %-9s |%s|\n", nameWidth, "(idle)", "", "+"+FormatMS(g.OffsetMS), FormatMS(g.DurationMS), "", bar(col(g.OffsetMS), col(g.OffsetMS+g.DurationMS), width, '.'))
+	}
+}
+
+func spanLabel(sp TimelineSpan) string {
+	if sp.Attempt > 1 {
+		return fmt.Sprintf("%s#%d", sp.NodeID, sp.Attempt)
+	}
+	return sp.NodeID
+}
+
+// truncateRunes shortens s to at most n runes, ending in "…" when cut.
+// fmt pads %-*s by runes too, so labels stay aligned.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
+
+func bar(from, to, width int, ch rune) string {
+	if to <= from {
+		to = from + 1
+	}
+	if to > width {
+		to = width
+		if from >= width {
+			from = width - 1
+		}
+	}
+	return strings.Repeat(" ", from) + strings.Repeat(string(ch), to-from) + strings.Repeat(" ", width-to)
+}
+
+// FormatMS renders a millisecond duration compactly (850ms, 12s, 4m05s, 1h02m).
+func FormatMS(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", ms)
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
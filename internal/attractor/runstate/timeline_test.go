@@ -0,0 +1,165 @@
+package runstate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+)
+
+func timelineExec(node string, attempt int, handler, status string, start time.Time, from, to time.Duration) rundb.NodeExecutionSummary {
+	end := start.Add(to)
+	return rundb.NodeExecutionSummary{
+		NodeID:      node,
+		Attempt:     attempt,
+		HandlerType: handler,
+		Status:      status,
+		StartedAt:   start.Add(from),
+		CompletedAt: &end,
+	}
+}
+
+func TestBuildTimeline_LanesGapsHumanWaitAndCriticalPath(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	done := t0.Add(60 * time.Second)
+	run := &rundb.RunSummary{RunID: "r1", Status: "success", StartedAt: t0, CompletedAt: &done}
+	execs := []rundb.NodeExecutionSummary{
+		timelineExec("start", 1, "start", "success", t0, 0, time.Second),
+		timelineExec("impl", 1, "codergen", "fail", t0, time.Second, 10*time.Second),
+		timelineExec("impl", 2, "codergen", "success", t0, 10*time.Second, 20*time.Second),
+		// lint runs concurrently with the second impl attempt and finishes later,
+		// but it does not feed approve in the graph.
+		timelineExec("lint", 1, "tool", "success", t0, 12*time.Second, 25*time.Second),
+		// 5s idle before the human gate.
+		timelineExec("approve", 1, "wait.human", "success", t0, 30*time.Second, 50*time.Second),
+		timelineExec("exit", 1, "exit", "success", t0, 50*time.Second, 51*time.Second),
+	}
+	dot := `digraph g { start -> impl -> approve -> exit; start -> lint }`
+
+	tl := BuildTimeline(run, execs, dot, done)
+	if tl.WallMS != 60000 || tl.Lanes != 2 || tl.Retries != 1 {
+		t.Fatalf("wall=%d lanes=%d retries=%d", tl.WallMS, tl.Lanes, tl.Retries)
+	}
+	if tl.HumanWaitMS != 20000 {
+		t.Fatalf("human wait = %d", tl.HumanWaitMS)
+	}
+	var lintLane int
+	for _, sp := range tl.Spans {
+		if sp.NodeID == "lint" {
+			lintLane = sp.Lane
+		}
+	}
+	if lintLane != 1 {
+		t.Fatalf("lint lane = %d, want 1", lintLane)
+	}
+
+	if len(tl.IdleGaps) != 2 {
+		t.Fatalf("idle gaps = %+v", tl.IdleGaps)
+	}
+	if g := tl.IdleGaps[0]; g.OffsetMS != 25000 || g.DurationMS != 5000 || g.After != "lint" || g.Before != "approve" {
+		t.Fatalf("first gap = %+v", g)
+	}
+	if g := tl.IdleGaps[1]; g.OffsetMS != 51000 || g.DurationMS != 9000 {
+		t.Fatalf("trailing gap = %+v", g)
+	}
+	if tl.IdleMS != 14000 || tl.BusyMS != 46000 {
+		t.Fatalf("idle=%d busy=%d", tl.IdleMS, tl.BusyMS)
+	}
+
+	var path []string
+	for _, idx := range tl.CriticalPath {
+		path = append(path, spanLabel(tl.Spans[idx]))
+	}
+	if got := strings.Join(path, ","); got != "start,impl,impl#2,approve,exit" {
+		t.Fatalf("critical path = %s", got)
+	}
+	if tl.CriticalPathMS != 1000+9000+10000+20000+1000 {
+		t.Fatalf("critical path ms = %d", tl.CriticalPathMS)
+	}
+
+	var buf bytes.Buffer
+	tl.WriteText(&buf, 60)
+	out := buf.String()
+	for _, want := range []string{"wall 1m00s", "idle 14s", "human wait 20s", "lanes 2", "* impl#2", "(idle)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("text rendering missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestBuildTimeline_RunningAttemptExtendsToNow(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	run := &rundb.RunSummary{RunID: "r2", Status: "running", StartedAt: t0}
+	execs := []rundb.NodeExecutionSummary{{NodeID: "impl", Attempt: 1, HandlerType: "codergen", StartedAt: t0.Add(2 * time.Second)}}
+
+	tl := BuildTimeline(run, execs, "", t0.Add(10*time.Second))
+	if len(tl.Spans) != 1 || !tl.Spans[0].Running || tl.Spans[0].DurationMS != 8000 {
+		t.Fatalf("spans = %+v", tl.Spans)
+	}
+	if len(tl.IdleGaps) != 1 || tl.IdleGaps[0].DurationMS != 2000 {
+		t.Fatalf("idle gaps = %+v", tl.IdleGaps)
+	}
+	if len(tl.CriticalPath) != 1 {
+		t.Fatalf("critical path = %v", tl.CriticalPath)
+	}
+}
+
+func TestBuildTimeline_ConcurrentBranchKeepsItsLane(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	done := t0.Add(10 * time.Second)
+	run := &rundb.RunSummary{RunID: "r3", Status: "success", StartedAt: t0, CompletedAt: &done}
+	branch := func(ex rundb.NodeExecutionSummary, id string) rundb.NodeExecutionSummary {
+		ex.BranchID = id
+		return ex
+	}
+	execs := []rundb.NodeExecutionSummary{
+		branch(timelineExec("a1", 1, "codergen", "success", t0, 0, 3*time.Second), "a1"),
+		branch(timelineExec("b1", 1, "codergen", "success", t0, time.Second, 4*time.Second), "b1"),
+		branch(timelineExec("c1", 1, "codergen", "success", t0, 4*time.Second, 9*time.Second), "c1"),
+		// Greedy placement would move a2 to lane 1 behind b1.
+		branch(timelineExec("a2", 1, "codergen", "success", t0, 5*time.Second, 10*time.Second), "a1"),
+	}
+
+	tl := BuildTimeline(run, execs, "", done)
+	lanes := map[string]int{}
+	for _, sp := range tl.Spans {
+		lanes[sp.NodeID] = sp.Lane
+	}
+	if lanes["a1"] != 0 || lanes["a2"] != 0 || lanes["b1"] != 1 || lanes["c1"] != 1 || tl.Lanes != 2 {
+		t.Fatalf("lanes = %v (%d)", lanes, tl.Lanes)
+	}
+}
+
+func TestTimelineWriteText_TruncatesLabelsByRune(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	done := t0.Add(2 * time.Second)
+	run := &rundb.RunSummary{RunID: "r4", Status: "success", StartedAt: t0, CompletedAt: &done}
+	long := strings.Repeat("é", 40)
+	execs := []rundb.NodeExecutionSummary{
+		timelineExec(long, 1, "codergen", "success", t0, 0, time.Second),
+		timelineExec("exit", 1, "exit", "success", t0, time.Second, 2*time.Second),
+	}
+
+	var buf bytes.Buffer
+	BuildTimeline(run, execs, "", done).WriteText(&buf, 20)
+	want := strings.Repeat("é", 31) + "… "
+	if !strings.Contains(buf.String(), want) {
+		t.Fatalf("missing rune-truncated label in:\n%s", buf.String())
+	}
+	// Every row puts the bar at the same rune column.
+	col := -1
+	for _, line := range strings.Split(buf.String(), "\n") {
+		i := strings.Index(line, "|")
+		if i < 0 {
+			continue
+		}
+		c := utf8.RuneCountInString(line[:i])
+		if col >= 0 && c != col {
+			t.Fatalf("misaligned row %q", line)
+		}
+		col = c
+	}
+}
@@ -0,0 +1,932 @@
+// Whole-graph path analysis for `validate --deep`.
+//
+// The built-in lint rules look at one node or edge at a time. AnalyzeDeep
+// instead treats every node's possible outcomes (success/fail/partial_success,
+// custom outcome values and preferred labels referenced by its outgoing edges)
+// as branch points, mirrors the engine's edge-selection order, and explores the
+// resulting state space from start. Context-dependent condition clauses are
+// treated as unknown, so an edge guarded by context.* is assumed able to fire
+// or not fire.
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+)
+
+// DeepAnalysis is the result of AnalyzeDeep.
+type DeepAnalysis struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Visits      []VisitBound `json:"visits"`
+}
+
+// VisitBound is the worst-case number of times a node can run in one run
+// iteration (loop_restart resets the count).
+type VisitBound struct {
+	NodeID string `json:"node_id"`
+	// Visits is how many times the node can be entered; 0 when Unbounded.
+	Visits int `json:"visits"`
+	// Attempts is the per-visit attempt ceiling (max_retries + 1).
+	Attempts int `json:"attempts"`
+	// Executions is Visits * Attempts; 0 when Unbounded.
+	Executions int  `json:"executions"`
+	Unbounded  bool `json:"unbounded,omitempty"`
+	// Bound names what limits the count: "acyclic", "loop_max", "loop_count",
+	// "max_node_visits", or "" when Unbounded.
+	Bound string `json:"bound,omitempty"`
+}
+
+// deepOutcome is one abstract outcome a node can produce.
+type deepOutcome struct {
+	Status    string
+	Label     string
+	Suggested string
+}
+
+func (o deepOutcome) String() string {
+	if o.Label == "" {
+		return "outcome=" + o.Status
+	}
+	return fmt.Sprintf("outcome=%s, preferred_label=%s", o.Status, o.Label)
+}
+
+// deepRoute is where a node goes after producing one abstract outcome.
+type deepRoute struct {
+	Outcome deepOutcome
+	Edges   []*model.Edge
+	Targets []string
+	// RunFails is set when the outcome can end the run without reaching exit
+	// (fail with no matching edge and no retry_target).
+	RunFails bool
+	// Stops is set when the node has no outgoing edges at all and the outcome
+	// is not a failure: the engine completes the run at a non-exit node.
+	Stops bool
+}
+
+type deepGraph struct {
+	g         *model.Graph
+	start     string
+	exits     map[string]bool
+	routes    map[string][]deepRoute
+	loopJumps map[string]deepLoopJump
+}
+
+// deepLoopJump is the implicit back-jump a loop end (or single-node loop)
+// takes instead of edge selection while its termination condition is unmet.
+type deepLoopJump struct {
+	LoopID string
+	Begin  string
+	End    string
+	// Limit is the iteration ceiling (loop_max, else loop_count); 0 when the
+	// loop only terminates on a runtime condition.
+	Limit     int
+	LimitAttr string
+}
+
+// AnalyzeDeep explores every path through the graph and reports cycles that
+// cannot be left, nodes from which exit is unreachable under some outcome,
+// outcomes with no route, and edges that can never fire. It also estimates
+// worst-case visit counts per node from loop_max/loop_count, max_node_visits
+// and max_retries.
+//
+// Goal-gate bounces at exit are not modeled: exit is treated as terminal.
+func AnalyzeDeep(g *model.Graph) *DeepAnalysis {
+	res := &DeepAnalysis{Diagnostics: []Diagnostic{}, Visits: []VisitBound{}}
+	if g == nil {
+		return res
+	}
+	start := findStartNodeID(g)
+	if start == "" {
+		// start_node already reports this; nothing to explore from.
+		return res
+	}
+	dg := buildDeepGraph(g, start)
+	reachable := dg.reachableFromStart()
+	canExit := dg.canReachExit()
+	sccs := dg.cyclicComponents(reachable)
+
+	res.Diagnostics = append(res.Diagnostics, dg.lintClosedCycles(sccs, canExit)...)
+	res.Diagnostics = append(res.Diagnostics, dg.lintExitUnreachable(reachable, canExit, sccs)...)
+	res.Diagnostics = append(res.Diagnostics, dg.lintUnroutedOutcomes(reachable)...)
+	res.Diagnostics = append(res.Diagnostics, dg.lintDeadEdges(reachable)...)
+	visits, diags := dg.visitBounds(reachable, sccs)
+	res.Visits = visits
+	res.Diagnostics = append(res.Diagnostics, diags...)
+	return res
+}
+
+func buildDeepGraph(g *model.Graph, start string) *deepGraph {
+	dg := &deepGraph{
+		g:         g,
+		start:     start,
+		exits:     map[string]bool{},
+		routes:    map[string][]deepRoute{},
+		loopJumps: map[string]deepLoopJump{},
+	}
+	for _, id := range findAllExitNodeIDs(g) {
+		dg.exits[id] = true
+	}
+	for _, id := range sortedNodeIDs(g) {
+		n := g.Nodes[id]
+		if n == nil || dg.exits[id] {
+			continue
+		}
+		if jump, ok := deepLoopJumpFor(g, n); ok {
+			dg.loopJumps[id] = jump
+		}
+		for _, o := range deepPossibleOutcomes(g, n) {
+			dg.routes[id] = append(dg.routes[id], deepRouteFor(g, n, o))
+		}
+	}
+	return dg
+}
+
+// deepNodeType is the node's type= override, or else the handler type its
+// shape maps to.
+func deepNodeType(n *model.Node) string {
+	if t := strings.TrimSpace(n.TypeOverride()); t != "" {
+		return t
+	}
+	return model.ShapeType(n.Shape())
+}
+
+// deepPossibleOutcomes enumerates the abstract outcomes a node can hand to
+// edge selection. retry never reaches routing (retries exhaust into fail), so
+// it is not produced.
+func deepPossibleOutcomes(g *model.Graph, n *model.Node) []deepOutcome {
+	var out []deepOutcome
+	switch deepNodeType(n) {
+	case "start", "loop.begin", "loop.end", "concurrent.split", "concurrent.join", "parallel":
+		return []deepOutcome{{Status: string(runtime.StatusSuccess)}}
+	case "wait.human":
+		// Mirrors WaitHumanHandler: one option per edge (label, else target
+		// ID), plus fail when the interaction is skipped or times out.
+		for _, e := range g.Outgoing(n.ID) {
+			if e == nil {
+				continue
+			}
+			label := strings.TrimSpace(e.Label())
+			if label == "" {
+				label = e.To
+			}
+			out = append(out, deepOutcome{Status: string(runtime.StatusSuccess), Label: label, Suggested: e.To})
+		}
+		return append(out, deepOutcome{Status: string(runtime.StatusFail)})
+	}
+
+	statuses := []string{
+		string(runtime.StatusSuccess),
+		string(runtime.StatusPartialSuccess),
+		string(runtime.StatusFail),
+		string(runtime.StatusSkipped),
+	}
+	labels := map[string]bool{}
+	for _, e := range g.Outgoing(n.ID) {
+		if e == nil {
+			continue
+		}
+		if strings.TrimSpace(e.Label()) != "" {
+			labels[e.Label()] = true
+		}
+		for _, c := range splitDeepClauses(e.Condition()) {
+			if c.key == "outcome" && !c.bare {
+				if st, err := runtime.ParseStageStatus(c.value); err != nil || !st.IsCanonical() {
+					statuses = appendUnique(statuses, c.value)
+				}
+			}
+			if c.key == "preferred_label" && !c.bare && c.value != "" {
+				labels[c.value] = true
+			}
+		}
+	}
+	for _, st := range statuses {
+		out = append(out, deepOutcome{Status: st})
+	}
+	for _, l := range sortedKeys(labels) {
+		out = append(out, deepOutcome{Status: string(runtime.StatusSuccess), Label: l})
+	}
+	return out
+}
+
+type deepClause struct {
+	key   string
+	value string
+	neq   bool
+	bare  bool
+}
+
+func splitDeepClauses(condExpr string) []deepClause {
+	var out []deepClause
+	for _, raw := range strings.Split(condExpr, "&&") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		switch {
+		case strings.Contains(raw, "!="):
+			parts := strings.SplitN(raw, "!=", 2)
+			out = append(out, deepClause{key: strings.TrimSpace(parts[0]), value: strings.TrimSpace(parts[1]), neq: true})
+		case strings.Contains(raw, "="):
+			parts := strings.SplitN(raw, "=", 2)
+			out = append(out, deepClause{key: strings.TrimSpace(parts[0]), value: strings.TrimSpace(parts[1])})
+		default:
+			out = append(out, deepClause{key: raw, bare: true})
+		}
+	}
+	return out
+}
+
+type tristate int
+
+const (
+	triFalse tristate = iota
+	triUnknown
+	triTrue
+)
+
+// evalDeepCondition evaluates a condition against an abstract outcome.
+// Clauses on outcome and preferred_label are decided; everything else reads
+// the run context and is unknown.
+func evalDeepCondition(condExpr string, o deepOutcome) tristate {
+	result := triTrue
+	for _, c := range splitDeepClauses(condExpr) {
+		var got string
+		switch c.key {
+		case "outcome":
+			got = o.Status
+		case "preferred_label":
+			got = o.Label
+		default:
+			result = triUnknown
+			continue
+		}
+		if c.bare {
+			if got == "" || got == "false" || got == "0" || got == "no" {
+				return triFalse
+			}
+			continue
+		}
+		want := c.value
+		if c.key == "outcome" {
+			if st, err := runtime.ParseStageStatus(want); err == nil {
+				want = string(st)
+			}
+		}
+		if (got == want) == c.neq {
+			return triFalse
+		}
+	}
+	return result
+}
+
+// deepRouteFor mirrors engine resolveNextHop/selectAllEligibleEdges for one
+// abstract outcome. Because context clauses are unknown, a route can include
+// both the conditional matches and whatever the later selection steps pick.
+func deepRouteFor(g *model.Graph, n *model.Node, o deepOutcome) deepRoute {
+	r := deepRoute{Outcome: o}
+	var edges []*model.Edge
+	for _, e := range g.Outgoing(n.ID) {
+		if e != nil {
+			edges = append(edges, e)
+		}
+	}
+	sort.SliceStable(edges, func(i, j int) bool { return edges[i].Order < edges[j].Order })
+	failed := o.Status == string(runtime.StatusFail)
+
+	if deepNodeType(n) == "concurrent.split" || deepNodeType(n) == "parallel" {
+		r.Edges = edges
+		r.Targets = edgeTargets(edges)
+		return r
+	}
+
+	if deepNodeType(n) == "parallel.fan_in" && failed {
+		// Fan-in failures follow matching conditional edges only, then the
+		// retry_target chain (which deterministic failures skip).
+		for _, e := range edges {
+			if c := strings.TrimSpace(e.Condition()); c != "" && evalDeepCondition(c, o) != triFalse {
+				r.Edges = append(r.Edges, e)
+			}
+		}
+		if t := deepRetryTarget(g, n.ID); t != "" {
+			r.Targets = appendUnique(edgeTargets(r.Edges), t)
+		} else {
+			r.Targets = edgeTargets(r.Edges)
+		}
+		r.RunFails = true
+		return r
+	}
+
+	if len(edges) == 0 {
+		if failed {
+			if t := deepRetryTarget(g, n.ID); t != "" {
+				r.Targets = []string{t}
+			} else {
+				r.RunFails = true
+			}
+		} else {
+			r.Stops = true
+		}
+		return r
+	}
+
+	var definite, possible []*model.Edge
+	for _, e := range edges {
+		c := strings.TrimSpace(e.Condition())
+		if c == "" {
+			continue
+		}
+		switch evalDeepCondition(c, o) {
+		case triTrue:
+			definite = append(definite, e)
+		case triUnknown:
+			possible = append(possible, e)
+		}
+	}
+	r.Edges = append(r.Edges, definite...)
+	r.Edges = append(r.Edges, possible...)
+	if len(definite) == 0 {
+		r.Edges = appendEdgesUnique(r.Edges, deepFallthroughEdges(edges, o)...)
+	}
+	r.Targets = edgeTargets(r.Edges)
+	return r
+}
+
+// deepFallthroughEdges mirrors selection steps 2-5 (preferred label,
+// suggested next ID, unconditional edges, all-conditional fallback).
+func deepFallthroughEdges(edges []*model.Edge, o deepOutcome) []*model.Edge {
+	if o.Label != "" {
+		want := normalizeDeepLabel(o.Label)
+		for _, e := range edges {
+			if normalizeDeepLabel(e.Label()) == want {
+				return []*model.Edge{e}
+			}
+		}
+	}
+	if o.Suggested != "" {
+		for _, e := range edges {
+			if e.To == o.Suggested {
+				return []*model.Edge{e}
+			}
+		}
+	}
+	var uncond []*model.Edge
+	for _, e := range edges {
+		if strings.TrimSpace(e.Condition()) == "" {
+			uncond = append(uncond, e)
+		}
+	}
+	if len(uncond) > 0 {
+		return uncond
+	}
+	best := append([]*model.Edge{}, edges...)
+	sort.SliceStable(best, func(i, j int) bool {
+		wi, _ := strconv.Atoi(best[i].Attr("weight", "0"))
+		wj, _ := strconv.Atoi(best[j].Attr("weight", "0"))
+		if wi != wj {
+			return wi > wj
+		}
+		if best[i].To != best[j].To {
+			return best[i].To < best[j].To
+		}
+		return best[i].Order < best[j].Order
+	})
+	return best[:1]
+}
+
+// normalizeDeepLabel mirrors engine normalizeLabel.
+func normalizeDeepLabel(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) >= 4 && s[0] == '[' && s[2] == ']' && s[3] == ' ' {
+		return strings.TrimSpace(s[4:])
+	}
+	if len(s) >= 3 && s[1] == ')' && s[2] == ' ' {
+		return strings.TrimSpace(s[3:])
+	}
+	if len(s) >= 4 && s[1] == ' ' && s[2] == '-' && s[3] == ' ' {
+		return strings.TrimSpace(s[4:])
+	}
+	return s
+}
+
+// deepRetryTarget mirrors engine resolveRetryTarget.
+func deepRetryTarget(g *model.Graph, nodeID string) string {
+	n := g.Nodes[nodeID]
+	if n == nil {
+		return ""
+	}
+	for _, t := range []string{
+		n.Attr("retry_target", ""),
+		n.Attr("fallback_retry_target", ""),
+		g.Attrs["retry_target"],
+		g.Attrs["fallback_retry_target"],
+	} {
+		if t = strings.TrimSpace(t); t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// deepLoopJumpFor mirrors engine handleLoopIteration: loop.end nodes jump to
+// their paired loop.begin, other nodes with loop_* termination attributes
+// jump to themselves.
+func deepLoopJumpFor(g *model.Graph, n *model.Node) (deepLoopJump, bool) {
+	typ := deepNodeType(n)
+	if typ == "loop.begin" {
+		return deepLoopJump{}, false
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(n.Attr("loop_count", "")))
+	max, _ := strconv.Atoi(strings.TrimSpace(n.Attr("loop_max", "")))
+	untilFile := strings.TrimSpace(n.Attr("loop_until_file", "")) != "" ||
+		strings.TrimSpace(n.Attr("loop_until_file_contains", "")) != ""
+	whileFail := strings.EqualFold(strings.TrimSpace(n.Attr("loop_while_outcome", "")), "fail")
+	if count <= 0 && !untilFile && !whileFail {
+		// No termination condition: the engine does not loop.
+		return deepLoopJump{}, false
+	}
+	loopID := strings.TrimSpace(n.Attr("loop_id", ""))
+	if loopID == "" {
+		loopID = n.ID
+	}
+	jump := deepLoopJump{LoopID: loopID, Begin: n.ID, End: n.ID}
+	if typ == "loop.end" {
+		jump.Begin = ""
+		for _, id := range sortedNodeIDs(g) {
+			b := g.Nodes[id]
+			if b == nil || deepNodeType(b) != "loop.begin" {
+				continue
+			}
+			bid := strings.TrimSpace(b.Attr("loop_id", ""))
+			if bid == "" {
+				bid = id
+			}
+			if bid == loopID {
+				jump.Begin = id
+				break
+			}
+		}
+		if jump.Begin == "" {
+			return deepLoopJump{}, false
+		}
+	}
+	switch {
+	case max > 0:
+		jump.Limit, jump.LimitAttr = max, "loop_max"
+	case count > 0:
+		jump.Limit, jump.LimitAttr = count, "loop_count"
+	}
+	return jump, true
+}
+
+// successors returns every node reachable in one step from id, including the
+// loop back-jump when includeLoops is set.
+func (dg *deepGraph) successors(id string, includeLoops bool) []string {
+	var out []string
+	for _, r := range dg.routes[id] {
+		for _, t := range r.Targets {
+			out = appendUnique(out, t)
+		}
+	}
+	if j, ok := dg.loopJumps[id]; ok && includeLoops {
+		out = appendUnique(out, j.Begin)
+	}
+	return out
+}
+
+func (dg *deepGraph) reachableFromStart() map[string]bool {
+	seen := map[string]bool{dg.start: true}
+	queue := []string{dg.start}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range dg.successors(cur, true) {
+			if _, ok := dg.g.Nodes[next]; ok && !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return seen
+}
+
+// canReachExit returns the set of nodes from which some sequence of outcomes
+// reaches an exit node.
+func (dg *deepGraph) canReachExit() map[string]bool {
+	preds := map[string][]string{}
+	for id := range dg.g.Nodes {
+		for _, next := range dg.successors(id, true) {
+			preds[next] = append(preds[next], id)
+		}
+	}
+	ok := map[string]bool{}
+	var queue []string
+	for id := range dg.exits {
+		ok[id] = true
+		queue = append(queue, id)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, p := range preds[cur] {
+			if !ok[p] {
+				ok[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	return ok
+}
+
+// cyclicComponents returns the strongly connected components of the reachable
+// subgraph that contain a cycle. Loop back-jumps with an iteration limit are
+// left out (visitBounds multiplies by the limit); unbounded ones count as
+// ordinary cycle edges.
+func (dg *deepGraph) cyclicComponents(reachable map[string]bool) [][]string {
+	succ := func(id string) []string {
+		var out []string
+		for _, t := range dg.successors(id, false) {
+			if reachable[t] {
+				out = append(out, t)
+			}
+		}
+		if j, ok := dg.loopJumps[id]; ok && j.Limit == 0 {
+			out = appendUnique(out, j.Begin)
+		}
+		return out
+	}
+
+	// Tarjan's algorithm over a deterministic node order.
+	index := 0
+	indices := map[string]int{}
+	lowlink := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var comps [][]string
+	var strong func(v string)
+	strong = func(v string) {
+		indices[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range succ(v) {
+			if _, seen := indices[w]; !seen {
+				strong(w)
+				lowlink[v] = minInt(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = minInt(lowlink[v], indices[w])
+			}
+		}
+		if lowlink[v] != indices[v] {
+			return
+		}
+		var comp []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			comp = append(comp, w)
+			if w == v {
+				break
+			}
+		}
+		selfLoop := false
+		if len(comp) == 1 {
+			for _, w := range succ(v) {
+				if w == v {
+					selfLoop = true
+				}
+			}
+		}
+		if len(comp) > 1 || selfLoop {
+			sort.Strings(comp)
+			comps = append(comps, comp)
+		}
+	}
+	for _, id := range sortedKeys(reachable) {
+		if _, seen := indices[id]; !seen {
+			strong(id)
+		}
+	}
+	sort.Slice(comps, func(i, j int) bool { return comps[i][0] < comps[j][0] })
+	return comps
+}
+
+// lintClosedCycles reports reachable cycles that no outcome can leave toward
+// exit. A loop_max or max_node_visits ceiling turns the spin into a run
+// failure, so those cycles are reported as warnings instead of errors.
+func (dg *deepGraph) lintClosedCycles(sccs [][]string, canExit map[string]bool) []Diagnostic {
+	var diags []Diagnostic
+	for _, comp := range sccs {
+		if canExit[comp[0]] {
+			continue
+		}
+		sev := SeverityError
+		msg := fmt.Sprintf("cycle [%s] has no outcome that leads to exit; the run can only spin or fail inside it", strings.Join(comp, " → "))
+		if parseDeepInt(dg.g.Attrs["max_node_visits"]) > 0 {
+			sev = SeverityWarning
+			msg += " (max_node_visits will abort it)"
+		}
+		diags = append(diags, Diagnostic{
+			Rule:     "deep_closed_cycle",
+			Severity: sev,
+			NodeID:   comp[0],
+			Message:  msg,
+			Fix:      "add an edge out of the cycle (e.g. condition=\"outcome=success\") that eventually reaches exit",
+		})
+	}
+	return diags
+}
+
+// lintExitUnreachable reports reachable nodes where some outcome leads only to
+// nodes that cannot reach exit. Nodes inside closed cycles are already
+// covered by deep_closed_cycle.
+func (dg *deepGraph) lintExitUnreachable(reachable, canExit map[string]bool, sccs [][]string) []Diagnostic {
+	inClosed := map[string]bool{}
+	for _, comp := range sccs {
+		if !canExit[comp[0]] {
+			for _, id := range comp {
+				inClosed[id] = true
+			}
+		}
+	}
+	var diags []Diagnostic
+	for _, id := range sortedKeys(reachable) {
+		if dg.exits[id] || inClosed[id] {
+			continue
+		}
+		if !canExit[id] {
+			diags = append(diags, Diagnostic{
+				Rule:     "deep_exit_unreachable",
+				Severity: SeverityError,
+				NodeID:   id,
+				Message:  "exit is unreachable from this node under every outcome",
+			})
+			continue
+		}
+		var stranded []string
+		for _, r := range dg.routes[id] {
+			if len(r.Targets) == 0 {
+				continue // run ends here; reported by deep_unrouted_outcome
+			}
+			anyExit := false
+			for _, t := range r.Targets {
+				if canExit[t] {
+					anyExit = true
+					break
+				}
+			}
+			if !anyExit {
+				stranded = append(stranded, r.Outcome.String())
+			}
+		}
+		if len(stranded) > 0 {
+			diags = append(diags, Diagnostic{
+				Rule:     "deep_exit_unreachable",
+				Severity: SeverityWarning,
+				NodeID:   id,
+				Message:  fmt.Sprintf("exit is unreachable after %s", strings.Join(stranded, "; ")),
+			})
+		}
+	}
+	return diags
+}
+
+// lintUnroutedOutcomes reports outcomes that end the run at a non-exit node:
+// a fail with no matching edge and no retry_target, or any outcome at a node
+// with no outgoing edges.
+func (dg *deepGraph) lintUnroutedOutcomes(reachable map[string]bool) []Diagnostic {
+	var diags []Diagnostic
+	for _, id := range sortedKeys(reachable) {
+		if dg.exits[id] {
+			continue
+		}
+		for _, r := range dg.routes[id] {
+			switch {
+			case r.Stops:
+				diags = append(diags, Diagnostic{
+					Rule:     "deep_unrouted_outcome",
+					Severity: SeverityWarning,
+					NodeID:   id,
+					Message:  fmt.Sprintf("%s ends the run at a non-exit node (no outgoing edges)", r.Outcome),
+					Fix:      "add an edge from this node toward exit",
+				})
+			case r.RunFails && len(r.Targets) == 0:
+				diags = append(diags, Diagnostic{
+					Rule:     "deep_unrouted_outcome",
+					Severity: SeverityWarning,
+					NodeID:   id,
+					Message:  fmt.Sprintf("%s has no matching edge and no retry_target; the run fails here", r.Outcome),
+					Fix:      "add a condition=\"outcome=fail\" edge or a retry_target",
+				})
+			}
+			if r.Stops {
+				// Every outcome stops the same way; one diagnostic is enough.
+				break
+			}
+		}
+	}
+	return diags
+}
+
+// lintDeadEdges reports edges from reachable nodes that no outcome selects.
+func (dg *deepGraph) lintDeadEdges(reachable map[string]bool) []Diagnostic {
+	fires := map[*model.Edge]bool{}
+	for id := range reachable {
+		for _, r := range dg.routes[id] {
+			for _, e := range r.Edges {
+				fires[e] = true
+			}
+		}
+	}
+	var diags []Diagnostic
+	for _, e := range dg.g.Edges {
+		if e == nil || !reachable[e.From] || fires[e] {
+			continue
+		}
+		msg := "unconditional edge is always shadowed by a higher-priority edge"
+		if c := strings.TrimSpace(e.Condition()); c != "" {
+			msg = fmt.Sprintf("edge condition %q can never be selected by any outcome this node produces", c)
+		}
+		diags = append(diags, Diagnostic{
+			Rule:     "deep_dead_edge",
+			Severity: SeverityWarning,
+			EdgeFrom: e.From,
+			EdgeTo:   e.To,
+			Message:  msg,
+		})
+	}
+	return diags
+}
+
+// visitBounds estimates worst-case visits per reachable node. Nodes outside
+// any edge cycle run once per enclosing loop iteration; nodes on an edge
+// cycle are bounded only by max_node_visits.
+func (dg *deepGraph) visitBounds(reachable map[string]bool, sccs [][]string) ([]VisitBound, []Diagnostic) {
+	onCycle := map[string]bool{}
+	var diags []Diagnostic
+	visitLimit := parseDeepInt(dg.g.Attrs["max_node_visits"])
+	for _, comp := range sccs {
+		for _, id := range comp {
+			onCycle[id] = true
+		}
+		if visitLimit <= 0 {
+			diags = append(diags, Diagnostic{
+				Rule:     "deep_unbounded_cycle",
+				Severity: SeverityInfo,
+				NodeID:   comp[0],
+				Message:  fmt.Sprintf("cycle [%s] has no visit ceiling; worst-case cost is unbounded", strings.Join(comp, " → ")),
+				Fix:      "set graph max_node_visits to cap revisits",
+			})
+		}
+	}
+
+	// Multiply by the iteration limit of every bounded loop whose body
+	// contains the node.
+	multiplier := map[string]int{}
+	boundBy := map[string]string{}
+	for _, endID := range sortedKeys(dg.loopJumps) {
+		j := dg.loopJumps[endID]
+		if j.Limit <= 0 {
+			continue
+		}
+		body := map[string]bool{j.Begin: true, j.End: true}
+		if j.Begin != j.End {
+			for id := range nodesBetween(dg.g, j.Begin, j.End) {
+				body[id] = true
+			}
+		}
+		for id := range body {
+			if multiplier[id] == 0 {
+				multiplier[id] = 1
+			}
+			multiplier[id] *= j.Limit
+			boundBy[id] = j.LimitAttr
+		}
+	}
+
+	var out []VisitBound
+	for _, id := range sortedKeys(reachable) {
+		n := dg.g.Nodes[id]
+		if n == nil {
+			continue
+		}
+		vb := VisitBound{NodeID: id, Attempts: deepMaxAttempts(dg.g, n)}
+		switch {
+		case onCycle[id] && visitLimit > 0:
+			// The engine aborts when a node reaches the limit, before running it.
+			vb.Visits, vb.Bound = visitLimit-1, "max_node_visits"
+		case onCycle[id]:
+			vb.Unbounded = true
+		case multiplier[id] > 0:
+			vb.Visits, vb.Bound = multiplier[id], boundBy[id]
+		default:
+			vb.Visits, vb.Bound = 1, "acyclic"
+		}
+		if !vb.Unbounded && visitLimit > 0 && vb.Visits > visitLimit-1 {
+			vb.Visits, vb.Bound = visitLimit-1, "max_node_visits"
+		}
+		if !vb.Unbounded {
+			vb.Executions = vb.Visits * vb.Attempts
+		}
+		out = append(out, vb)
+	}
+	return out, diags
+}
+
+// deepMaxAttempts mirrors the engine's retry precedence: node max_retries,
+// graph default_max_retry, then 3. Handlers that never retry get 1.
+func deepMaxAttempts(g *model.Graph, n *model.Node) int {
+	switch deepNodeType(n) {
+	case "start", "exit", "conditional", "loop.begin", "loop.end", "concurrent.split", "concurrent.join":
+		return 1
+	}
+	retries := -1
+	if v := strings.TrimSpace(n.Attr("max_retries", "")); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			retries = parsed
+		}
+	}
+	if retries < 0 {
+		if v := strings.TrimSpace(g.Attrs["default_max_retry"]); v != "" {
+			if parsed, err := strconv.Atoi(v); err == nil {
+				retries = parsed
+			}
+		}
+	}
+	if retries < 0 {
+		retries = 3
+	}
+	return retries + 1
+}
+
+func parseDeepInt(s string) int {
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+func edgeTargets(edges []*model.Edge) []string {
+	var out []string
+	for _, e := range edges {
+		out = appendUnique(out, e.To)
+	}
+	return out
+}
+
+func appendEdgesUnique(dst []*model.Edge, edges ...*model.Edge) []*model.Edge {
+	for _, e := range edges {
+		dup := false
+		for _, have := range dst {
+			if have == e {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			dst = append(dst, e)
+		}
+	}
+	return dst
+}
+
+func appendUnique(dst []string, v string) []string {
+	for _, have := range dst {
+		if have == v {
+			return dst
+		}
+	}
+	return append(dst, v)
+}
+
+func sortedNodeIDs(g *model.Graph) []string {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,169 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/attractor/dot"
+)
+
+func TestAnalyzeDeep_ClosedCycleAndExitUnreachable(t *testing.T) {
+	g, err := dot.Parse([]byte(`
+digraph G {
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  a [shape=box, llm_provider=openai, llm_model=gpt-5.4, prompt="a"]
+  b [shape=box, llm_provider=openai, llm_model=gpt-5.4, prompt="b"]
+  c [shape=box, llm_provider=openai, llm_model=gpt-5.4, prompt="c"]
+  start -> a
+  a -> exit [condition="outcome=success"]
+  a -> b [condition="outcome=fail"]
+  a -> exit
+  b -> c
+  c -> b
+}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	res := AnalyzeDeep(g)
+	assertHasRule(t, res.Diagnostics, "deep_closed_cycle", SeverityError)
+	found := false
+	for _, d := range res.Diagnostics {
+		if d.Rule == "deep_exit_unreachable" && d.NodeID == "a" {
+			found = true
+			if d.Severity != SeverityWarning || !strings.Contains(d.Message, "outcome=fail") {
+				t.Fatalf("unexpected exit_unreachable diagnostic for a: %+v", d)
+			}
+		}
+		if d.Rule == "deep_exit_unreachable" && (d.NodeID == "b" || d.NodeID == "c") {
+			t.Fatalf("nodes inside a closed cycle should be covered by deep_closed_cycle: %+v", d)
+		}
+	}
+	if !found {
+		t.Fatalf("expected deep_exit_unreachable for a; got %+v", res.Diagnostics)
+	}
+}
+
+func TestAnalyzeDeep_DeadEdges(t *testing.T) {
+	g, err := dot.Parse([]byte(`
+digraph G {
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  a [shape=box, llm_provider=openai, llm_model=gpt-5.4, prompt="a"]
+  gate [shape=hexagon, label="Gate"]
+  start -> a
+  a -> gate [condition="outcome=success"]
+  a -> a [condition="outcome=retry"]
+  a -> gate
+  gate -> exit [label="Approve"]
+  gate -> exit [condition="preferred_label=Nope"]
+}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	res := AnalyzeDeep(g)
+	dead := map[string]bool{}
+	for _, d := range res.Diagnostics {
+		if d.Rule == "deep_dead_edge" {
+			dead[d.EdgeFrom+"->"+d.EdgeTo] = true
+		}
+	}
+	if !dead["a->a"] {
+		t.Fatalf("outcome=retry edge should be dead (retries exhaust into fail): %+v", res.Diagnostics)
+	}
+	if !dead["gate->exit"] {
+		t.Fatalf("edge requiring a label the gate never offers should be dead: %+v", res.Diagnostics)
+	}
+	if dead["a->gate"] {
+		t.Fatalf("live edges reported dead: %v", dead)
+	}
+}
+
+func TestAnalyzeDeep_UnroutedFailWithoutRetryTarget(t *testing.T) {
+	g, err := dot.Parse([]byte(`
+digraph G {
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  a [shape=box, llm_provider=openai, llm_model=gpt-5.4, prompt="a"]
+  start -> a
+  a -> exit [condition="outcome=success"]
+  a -> exit [condition="outcome=partial_success"]
+  a -> exit [condition="outcome=skipped"]
+}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	// All-conditional nodes fall back to the best edge, so fail still routes.
+	for _, d := range AnalyzeDeep(g).Diagnostics {
+		if d.Rule == "deep_unrouted_outcome" {
+			t.Fatalf("unexpected unrouted outcome: %+v", d)
+		}
+	}
+
+	g2, err := dot.Parse([]byte(`
+digraph G {
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  a [shape=parallelogram, tool_command="true"]
+  b [shape=parallelogram, tool_command="true"]
+  start -> a
+  a -> exit [condition="outcome=success"]
+  a -> b [condition="outcome=fail"]
+}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	res := AnalyzeDeep(g2)
+	assertHasRule(t, res.Diagnostics, "deep_unrouted_outcome", SeverityWarning)
+}
+
+func TestAnalyzeDeep_VisitBounds(t *testing.T) {
+	g, err := dot.Parse([]byte(`
+digraph G {
+  graph [default_max_retry=2]
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  poll [shape=parallelogram, tool_command="true", loop_count=3, loop_max=5, max_retries=0]
+  fix  [shape=box, llm_provider=openai, llm_model=gpt-5.4, prompt="fix"]
+  check [shape=diamond]
+  start -> poll
+  poll -> fix [condition="outcome=success"]
+  poll -> exit
+  fix -> check
+  check -> exit [condition="outcome=success"]
+  check -> fix
+}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	res := AnalyzeDeep(g)
+	byID := map[string]VisitBound{}
+	for _, v := range res.Visits {
+		byID[v.NodeID] = v
+	}
+	if v := byID["poll"]; v.Visits != 5 || v.Attempts != 1 || v.Bound != "loop_max" {
+		t.Fatalf("poll bound: %+v", v)
+	}
+	if v := byID["fix"]; !v.Unbounded || v.Attempts != 3 {
+		t.Fatalf("fix bound: %+v", v)
+	}
+	assertHasRule(t, res.Diagnostics, "deep_unbounded_cycle", SeverityInfo)
+
+	g.Attrs["max_node_visits"] = "4"
+	res = AnalyzeDeep(g)
+	for _, v := range res.Visits {
+		if v.NodeID == "fix" && (v.Unbounded || v.Visits != 3 || v.Executions != 9 || v.Bound != "max_node_visits") {
+			t.Fatalf("fix bound with max_node_visits: %+v", v)
+		}
+	}
+	for _, d := range res.Diagnostics {
+		if d.Rule == "deep_unbounded_cycle" {
+			t.Fatalf("max_node_visits should bound the cycle: %+v", d)
+		}
+	}
+}
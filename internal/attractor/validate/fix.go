@@ -0,0 +1,290 @@
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/danshapiro/kilroy/internal/attractor/dot"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/runtime"
+	"github.com/danshapiro/kilroy/internal/attractor/style"
+)
+
+// FixFunc rewrites doc to resolve one diagnostic. g is the prepared graph the
+// diagnostic was computed from (stylesheet applied, prompt files expanded);
+// doc reflects any fixes already applied in this pass, so fixers check it
+// before editing. A fixer returns a one-line description of what it changed,
+// or "" when there is nothing safe to do.
+type FixFunc func(doc *dot.Document, g *model.Graph, d Diagnostic) (string, error)
+
+// ruleFixes maps rule names to their fixers. Only rewrites whose intent is
+// unambiguous belong here; anything needing author judgement stays a hint in
+// Diagnostic.Fix.
+var ruleFixes = map[string]FixFunc{
+	"start_exit_shape":                    fixStartExitShape,
+	"goal_gate_has_retry":                 fixGoalGateRetryTarget,
+	"goal_gate_missing_node_retry_target": fixGoalGateRetryTarget,
+	"goal_gate_fail_edge":                 fixGoalGateFailEdge,
+	"reserved_keyword_node_id":            fixReservedKeywordNodeID,
+	"llm_provider_required":               fixLLMProviderFromStylesheet,
+	"status_contract_in_prompt":           fixStatusContractInPrompt,
+	"status_fallback_in_prompt":           fixStatusFallbackInPrompt,
+}
+
+// fixLast lists rules whose fixes must run after all others. Renames change
+// the node IDs other fixers look up.
+var fixLast = map[string]bool{
+	"reserved_keyword_node_id": true,
+}
+
+// HasFix reports whether rule has an automatic fix.
+func HasFix(rule string) bool {
+	_, ok := ruleFixes[rule]
+	return ok
+}
+
+// AppliedFix records one rewrite made by ApplyFixes.
+type AppliedFix struct {
+	Diagnostic  Diagnostic `json:"diagnostic"`
+	Description string     `json:"description"`
+}
+
+// FixResult is the outcome of ApplyFixes. Source is the rewritten DOT; it
+// equals the input when nothing was applied.
+type FixResult struct {
+	Source  []byte       `json:"-"`
+	Applied []AppliedFix `json:"applied"`
+	// Skipped lists fixable diagnostics whose fixer declined (for example,
+	// when there is no unambiguous value to write).
+	Skipped []Diagnostic `json:"skipped,omitempty"`
+}
+
+// ApplyFixes applies the automatic fixes for diags to src. Edits are spliced
+// into the original text, so comments and formatting outside the touched
+// statements are preserved.
+func ApplyFixes(src []byte, g *model.Graph, diags []Diagnostic) (*FixResult, error) {
+	doc, err := dot.ParseDocument(src)
+	if err != nil {
+		return nil, err
+	}
+	ordered := make([]Diagnostic, 0, len(diags))
+	for _, d := range diags {
+		if HasFix(d.Rule) {
+			ordered = append(ordered, d)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return !fixLast[ordered[i].Rule] && fixLast[ordered[j].Rule]
+	})
+
+	res := &FixResult{}
+	for _, d := range ordered {
+		desc, err := ruleFixes[d.Rule](doc, g, d)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.Rule, err)
+		}
+		if desc == "" {
+			res.Skipped = append(res.Skipped, d)
+			continue
+		}
+		res.Applied = append(res.Applied, AppliedFix{Diagnostic: d, Description: desc})
+	}
+	res.Source = doc.Bytes()
+	return res, nil
+}
+
+func fixStartExitShape(doc *dot.Document, g *model.Graph, d Diagnostic) (string, error) {
+	want := "Msquare"
+	if strings.EqualFold(d.NodeID, "start") {
+		want = "Mdiamond"
+	}
+	if cur, ok := doc.NodeAttr(d.NodeID, "shape"); ok && cur == want {
+		return "", nil
+	}
+	if err := doc.SetNodeAttr(d.NodeID, "shape", want); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("set shape=%s on %s", want, d.NodeID), nil
+}
+
+// goalGateRetryTarget picks the recovery node for a goal gate: a postmortem
+// node when the graph has one, otherwise the graph-level retry target.
+func goalGateRetryTarget(g *model.Graph, gateID string) string {
+	candidates := []string{"postmortem", g.Attrs["retry_target"], g.Attrs["fallback_retry_target"]}
+	for _, c := range candidates {
+		c = strings.TrimSpace(c)
+		if c == "" || c == gateID {
+			continue
+		}
+		if _, ok := g.Nodes[c]; ok {
+			return c
+		}
+	}
+	return ""
+}
+
+func fixGoalGateRetryTarget(doc *dot.Document, g *model.Graph, d Diagnostic) (string, error) {
+	if _, ok := doc.NodeAttr(d.NodeID, "retry_target"); ok {
+		return "", nil
+	}
+	target := goalGateRetryTarget(g, d.NodeID)
+	if target == "" {
+		return "", nil
+	}
+	if err := doc.SetNodeAttr(d.NodeID, "retry_target", target); err != nil {
+		return "", err
+	}
+	desc := fmt.Sprintf("set retry_target=%s on %s", target, d.NodeID)
+	added, err := addGoalGateFailEdge(doc, g, d.NodeID, target)
+	if err != nil {
+		return "", err
+	}
+	if added {
+		desc += fmt.Sprintf(" and route outcome=fail to %s", target)
+	}
+	return desc, nil
+}
+
+func fixGoalGateFailEdge(doc *dot.Document, g *model.Graph, d Diagnostic) (string, error) {
+	target, ok := doc.NodeAttr(d.NodeID, "retry_target")
+	if !ok {
+		target = g.Nodes[d.NodeID].Attr("retry_target", "")
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", nil
+	}
+	added, err := addGoalGateFailEdge(doc, g, d.NodeID, target)
+	if err != nil || !added {
+		return "", err
+	}
+	return fmt.Sprintf("add %s -> %s [condition=\"outcome=fail\"]", d.NodeID, target), nil
+}
+
+// addGoalGateFailEdge adds gate -> target [condition="outcome=fail"] unless an
+// outgoing edge already routes fail traffic.
+func addGoalGateFailEdge(doc *dot.Document, g *model.Graph, gateID, target string) (bool, error) {
+	if goalGateRoutesFail(g, gateID, target) || doc.HasEdge(gateID, target) {
+		return false, nil
+	}
+	if err := doc.AddEdge(gateID, target, dot.Attr{Key: "condition", Value: "outcome=fail"}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func fixReservedKeywordNodeID(doc *dot.Document, g *model.Graph, d Diagnostic) (string, error) {
+	newID := d.NodeID + "_node"
+	if _, taken := g.Nodes[newID]; taken || doc.HasNode(newID) {
+		return "", nil
+	}
+	if !doc.HasNode(d.NodeID) {
+		return "", nil
+	}
+	if err := doc.RenameNode(d.NodeID, newID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("rename node %s to %s", d.NodeID, newID), nil
+}
+
+// fixLLMProviderFromStylesheet copies the provider onto the node when the
+// model_stylesheet names exactly one provider, so there is only one answer
+// the author could have meant.
+func fixLLMProviderFromStylesheet(doc *dot.Document, g *model.Graph, d Diagnostic) (string, error) {
+	if shape, ok := doc.NodeAttr(d.NodeID, "shape"); ok && shape != "box" {
+		// Another fix in this pass (e.g. start_exit_shape) took the node out
+		// of the agent rules.
+		return "", nil
+	}
+	rules, err := style.ParseStylesheet(g.Attrs["model_stylesheet"])
+	if err != nil {
+		return "", nil
+	}
+	providers := map[string]bool{}
+	for _, r := range rules {
+		if p := strings.TrimSpace(r.Decls["llm_provider"]); p != "" {
+			providers[p] = true
+		}
+	}
+	if len(providers) != 1 {
+		return "", nil
+	}
+	var provider string
+	for p := range providers {
+		provider = p
+	}
+	if err := doc.SetNodeAttr(d.NodeID, "llm_provider", provider); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("set llm_provider=%s on %s (the only provider in model_stylesheet)", provider, d.NodeID), nil
+}
+
+const (
+	statusContractSentence = `When finished, write {"status":"success"} (or "fail" with failure_reason) to $KILROY_STAGE_STATUS_PATH, or to $KILROY_STAGE_STATUS_FALLBACK_PATH if that write fails.`
+	statusFallbackSentence = `If writing $KILROY_STAGE_STATUS_PATH fails, write the same status JSON to $KILROY_STAGE_STATUS_FALLBACK_PATH.`
+)
+
+func fixStatusContractInPrompt(doc *dot.Document, g *model.Graph, d Diagnostic) (string, error) {
+	return appendPromptSentence(doc, d.NodeID, statusContractSentenceFor(g, d.NodeID), "KILROY_STAGE_STATUS_PATH")
+}
+
+// statusContractSentenceFor returns statusContractSentence, or, when the
+// node's outgoing edges route on outcomes other than success and fail (retry
+// or custom values), a sentence listing those outcomes so the agent can
+// write them.
+func statusContractSentenceFor(g *model.Graph, id string) string {
+	var extra []string
+	for _, e := range g.Outgoing(id) {
+		if e == nil {
+			continue
+		}
+		for _, c := range splitDeepClauses(e.Condition()) {
+			if c.key != "outcome" || c.bare || c.value == "" {
+				continue
+			}
+			v := c.value
+			if st, err := runtime.ParseStageStatus(v); err == nil {
+				v = string(st)
+			}
+			if v != string(runtime.StatusSuccess) && v != string(runtime.StatusFail) {
+				extra = appendUnique(extra, v)
+			}
+		}
+	}
+	if len(extra) == 0 {
+		return statusContractSentence
+	}
+	var quoted []string
+	for _, v := range append(append([]string{string(runtime.StatusSuccess)}, extra...), string(runtime.StatusFail)) {
+		quoted = append(quoted, fmt.Sprintf("%q", v))
+	}
+	return fmt.Sprintf(`When finished, write {"status":"<outcome>"} to $KILROY_STAGE_STATUS_PATH, or to $KILROY_STAGE_STATUS_FALLBACK_PATH if that write fails. <outcome> is one of %s; add failure_reason for "fail" or "retry".`, strings.Join(quoted, ", "))
+}
+
+func fixStatusFallbackInPrompt(doc *dot.Document, g *model.Graph, d Diagnostic) (string, error) {
+	return appendPromptSentence(doc, d.NodeID, statusFallbackSentence, "KILROY_STAGE_STATUS_FALLBACK_PATH")
+}
+
+// appendPromptSentence appends sentence to the node's inline prompt. Prompts
+// loaded from prompt_file are left alone; the DOT file does not contain them.
+func appendPromptSentence(doc *dot.Document, id, sentence, marker string) (string, error) {
+	if shape, ok := doc.NodeAttr(id, "shape"); ok && shape != "box" {
+		return "", nil
+	}
+	key := "prompt"
+	prompt, ok := doc.NodeAttr(id, key)
+	if !ok {
+		key = "llm_prompt"
+		if prompt, ok = doc.NodeAttr(id, key); !ok {
+			return "", nil
+		}
+	}
+	if strings.Contains(prompt, marker) {
+		return "", nil
+	}
+	if err := doc.AppendNodeAttr(id, key, "\n\n"+sentence); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("append status-file instructions to %s's %s", id, key), nil
+}
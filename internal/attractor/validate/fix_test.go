@@ -0,0 +1,148 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/attractor/dot"
+	"github.com/danshapiro/kilroy/internal/attractor/model"
+	"github.com/danshapiro/kilroy/internal/attractor/style"
+)
+
+func TestApplyFixes_RewritesMechanicalIssues(t *testing.T) {
+	src := `digraph G {
+  // Providers come from the stylesheet.
+  graph [model_stylesheet="#impl { llm_provider: openai; llm_model: gpt-5.4; }"]
+  start
+  exit
+  impl [prompt="Implement the plan."]
+  check [prompt="Check it. Write $KILROY_STAGE_STATUS_PATH."]
+  gate [shape=box, goal_gate=true, llm_provider=openai, prompt="Review. Write $KILROY_STAGE_STATUS_PATH or $KILROY_STAGE_STATUS_FALLBACK_PATH."]
+  postmortem [prompt="Analyze. Write $KILROY_STAGE_STATUS_PATH or $KILROY_STAGE_STATUS_FALLBACK_PATH."]
+  start -> impl -> check -> gate
+  gate -> exit [condition="outcome=success"]
+  postmortem -> impl
+}
+`
+	g := prepared(t, src)
+	diags := Validate(g)
+	res, err := ApplyFixes([]byte(src), g, diags)
+	if err != nil {
+		t.Fatalf("ApplyFixes: %v", err)
+	}
+	out := string(res.Source)
+	for _, want := range []string{
+		"// Providers come from the stylesheet.",
+		"start [shape=Mdiamond]",
+		"exit [shape=Msquare]",
+		"llm_provider=openai",
+		`retry_target=postmortem`,
+		`gate -> postmortem [condition="outcome=fail"]`,
+		`Implement the plan.\n\nWhen finished, write {\"status\":\"success\"}`,
+		`Check it. Write $KILROY_STAGE_STATUS_PATH.\n\nIf writing $KILROY_STAGE_STATUS_PATH fails`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in fixed source:\n%s", want, out)
+		}
+	}
+
+	// Re-validating the fixed graph clears every fixed rule.
+	g2 := prepared(t, out)
+	for _, d := range Validate(g2) {
+		switch d.Rule {
+		case "start_exit_shape", "goal_gate_missing_node_retry_target", "goal_gate_fail_edge",
+			"status_contract_in_prompt", "status_fallback_in_prompt":
+			t.Fatalf("rule still reported after fix: %+v", d)
+		case "llm_provider_required":
+			t.Fatalf("llm_provider still missing after fix: %+v", d)
+		}
+	}
+
+	// A second pass is a no-op.
+	res2, err := ApplyFixes([]byte(out), g2, Validate(g2))
+	if err != nil {
+		t.Fatalf("second ApplyFixes: %v", err)
+	}
+	if string(res2.Source) != out || len(res2.Applied) != 0 {
+		t.Fatalf("second pass changed the graph: %+v", res2.Applied)
+	}
+}
+
+func TestApplyFixes_SkipsAmbiguousFixes(t *testing.T) {
+	src := `digraph G {
+  graph [model_stylesheet=".impl { llm_provider: openai; } .review { llm_provider: anthropic; }"]
+  start [shape=Mdiamond]
+  exit [shape=Msquare]
+  a [prompt="Write $KILROY_STAGE_STATUS_PATH or $KILROY_STAGE_STATUS_FALLBACK_PATH."]
+  start -> a
+  a -> exit [condition="outcome=success"]
+}
+`
+	g := prepared(t, src)
+	res, err := ApplyFixes([]byte(src), g, Validate(g))
+	if err != nil {
+		t.Fatalf("ApplyFixes: %v", err)
+	}
+	if len(res.Applied) != 0 || len(res.Skipped) == 0 || string(res.Source) != src {
+		t.Fatalf("expected the two-provider stylesheet to be left alone: %+v", res)
+	}
+}
+
+func TestApplyFixes_StatusContractNamesRoutedOutcomes(t *testing.T) {
+	src := `digraph G {
+  start [shape=Mdiamond]
+  exit [shape=Msquare]
+  triage [llm_provider=openai, prompt="Triage the bug."]
+  start -> triage
+  triage -> exit [condition="outcome=success"]
+  triage -> triage [condition="outcome=retry"]
+  triage -> exit [condition="outcome=port"]
+}
+`
+	g := prepared(t, src)
+	res, err := ApplyFixes([]byte(src), g, Validate(g))
+	if err != nil {
+		t.Fatalf("ApplyFixes: %v", err)
+	}
+	want := `<outcome> is one of \"success\", \"retry\", \"port\", \"fail\"`
+	if out := string(res.Source); !strings.Contains(out, want) {
+		t.Fatalf("missing %q in fixed source:\n%s", want, out)
+	}
+}
+
+func TestApplyFixes_RenamesReservedKeywordNode(t *testing.T) {
+	src := `digraph G {
+  start [shape=Mdiamond]
+  exit [shape=Msquare]
+  strict [shape=parallelogram, tool_command="true"]
+  fix [shape=parallelogram, tool_command="true", retry_target=strict]
+  start -> strict -> fix
+  fix -> exit [condition="outcome=success"]
+}
+`
+	g := prepared(t, src)
+	res, err := ApplyFixes([]byte(src), g, Validate(g))
+	if err != nil {
+		t.Fatalf("ApplyFixes: %v", err)
+	}
+	out := string(res.Source)
+	if !strings.Contains(out, "start -> strict_node -> fix") || !strings.Contains(out, "retry_target=strict_node") {
+		t.Fatalf("rename not applied everywhere:\n%s", out)
+	}
+}
+
+func prepared(t *testing.T, src string) *model.Graph {
+	t.Helper()
+	g, err := dot.Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	rules, err := style.ParseStylesheet(g.Attrs["model_stylesheet"])
+	if err != nil {
+		t.Fatalf("stylesheet: %v", err)
+	}
+	if err := style.ApplyStylesheet(g, rules); err != nil {
+		t.Fatalf("apply stylesheet: %v", err)
+	}
+	return g
+}
@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/danshapiro/kilroy/internal/agent"
 	"github.com/danshapiro/kilroy/internal/attractor/cond"
 	"github.com/danshapiro/kilroy/internal/attractor/model"
 	"github.com/danshapiro/kilroy/internal/attractor/modeldb"
@@ -61,6 +62,7 @@ func ValidateWithOptions(g *model.Graph, opts ValidateOptions, extraRules ...Lin
 
 	diags = append(diags, lintStartNode(g)...)
 	diags = append(diags, lintExitNode(g)...)
+	diags = append(diags, lintStartExitShape(g)...)
 	diags = append(diags, lintEdgeTargetsExist(g)...)
 	diags = append(diags, lintStartNoIncoming(g)...)
 	diags = append(diags, lintExitNoOutgoing(g)...)
@@ -68,9 +70,11 @@ func ValidateWithOptions(g *model.Graph, opts ValidateOptions, extraRules ...Lin
 	diags = append(diags, lintConditionSyntax(g)...)
 	diags = append(diags, lintStylesheetSyntax(g)...)
 	diags = append(diags, lintStylesheetModelIDs(g, opts.Catalog)...)
+	diags = append(diags, lintAutoModelSyntax(g)...)
 	diags = append(diags, lintRetryTargetsExist(g)...)
 	diags = append(diags, lintGoalGateHasRetry(g)...)
 	diags = append(diags, lintGoalGateMissingNodeRetryTarget(g)...)
+	diags = append(diags, lintGoalGateFailEdge(g)...)
 	diags = append(diags, lintGoalGateExitStatusContract(g)...)
 	diags = append(diags, lintGoalGatePromptStatusHint(g)...)
 	diags = append(diags, lintFidelityValid(g)...)
@@ -79,6 +83,7 @@ func ValidateWithOptions(g *model.Graph, opts ValidateOptions, extraRules ...Lin
 	diags = append(diags, lintPromptOnConditionalNodes(g)...)
 	diags = append(diags, lintPromptFileConflict(g)...)
 	diags = append(diags, lintToolCommandRequired(g)...)
+	diags = append(diags, lintToolTestCommandConflict(g)...)
 	diags = append(diags, lintValidateScriptFailureContract(g)...)
 	diags = append(diags, lintLLMProviderPresent(g)...)
 	diags = append(diags, lintLoopRestartFailureClassGuard(g)...)
@@ -92,6 +97,7 @@ func ValidateWithOptions(g *model.Graph, opts ValidateOptions, extraRules ...Lin
 	diags = append(diags, lintCustomOutcomeCoverage(g)...)
 	diags = append(diags, lintReservedKeywordNodeID(g)...)
 	diags = append(diags, lintToolCommandAbsPath(g)...)
+	diags = append(diags, lintToolsAllowList(g)...)
 	diags = append(diags, lintConcurrentSplitMinBranches(g)...)
 	diags = append(diags, lintConcurrentSplitHasJoin(g)...)
 	diags = append(diags, lintNoNestedConcurrentRegions(g)...)
@@ -162,6 +168,40 @@ func lintExitNode(g *model.Graph) []Diagnostic {
 	return nil
 }
 
+// lintStartExitShape warns when a node is only recognised as start or exit by
+// its ID. Such nodes default to shape=box, which makes the agent-node rules
+// (prompt, llm_provider, status contract) fire on them and hides their role
+// from anyone reading the rendered graph.
+func lintStartExitShape(g *model.Graph) []Diagnostic {
+	var diags []Diagnostic
+	for _, id := range g.AllNodeIDs() {
+		n := g.Nodes[id]
+		if n == nil {
+			continue
+		}
+		shape := n.Shape()
+		switch {
+		case strings.EqualFold(id, "start") && shape != "Mdiamond" && shape != "circle":
+			diags = append(diags, Diagnostic{
+				Rule:     "start_exit_shape",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("start node %q has shape=%s; it is only recognised by its ID", id, shape),
+				NodeID:   id,
+				Fix:      "set shape=Mdiamond on the start node",
+			})
+		case (strings.EqualFold(id, "exit") || strings.EqualFold(id, "end")) && shape != "Msquare" && shape != "doublecircle":
+			diags = append(diags, Diagnostic{
+				Rule:     "start_exit_shape",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("exit node %q has shape=%s; it is only recognised by its ID", id, shape),
+				NodeID:   id,
+				Fix:      "set shape=Msquare on the exit node",
+			})
+		}
+	}
+	return diags
+}
+
 func lintEdgeTargetsExist(g *model.Graph) []Diagnostic {
 	var diags []Diagnostic
 	for _, e := range g.Edges {
@@ -494,6 +534,9 @@ func lintStylesheetModelIDs(g *model.Graph, catalog *modeldb.Catalog) []Diagnost
 			continue
 		}
 		modelID = strings.TrimSpace(modelID)
+		if modeldb.IsAutoModelSpec(modelID) {
+			continue // selectors are checked by lintAutoModelSyntax
+		}
 		provider := strings.TrimSpace(r.Decls["llm_provider"])
 
 		key := provider + "|" + modelID
@@ -530,6 +573,32 @@ func lintStylesheetModelIDs(g *model.Graph, catalog *modeldb.Catalog) []Diagnost
 	return diags
 }
 
+// lintAutoModelSyntax reports llm_model auto(...) selectors that do not
+// parse. Well-formed selectors are resolved against the model catalog at
+// prepare time, so they normally no longer appear here.
+func lintAutoModelSyntax(g *model.Graph) []Diagnostic {
+	var diags []Diagnostic
+	for id, n := range g.Nodes {
+		if n == nil {
+			continue
+		}
+		spec := strings.TrimSpace(n.Attr("llm_model", ""))
+		if !modeldb.IsAutoModelSpec(spec) {
+			continue
+		}
+		if _, err := modeldb.ParseAutoModelSpec(spec); err != nil {
+			diags = append(diags, Diagnostic{
+				Rule:     "auto_model_syntax",
+				Severity: SeverityError,
+				Message:  err.Error(),
+				NodeID:   id,
+				Fix:      "use terms like auto(min_context=200k, tools, reasoning, max_cost_per_mtok=5, prefer=cheapest)",
+			})
+		}
+	}
+	return diags
+}
+
 func lintRetryTargetsExist(g *model.Graph) []Diagnostic {
 	var diags []Diagnostic
 	for id, n := range g.Nodes {
@@ -605,6 +674,54 @@ func lintGoalGateMissingNodeRetryTarget(g *model.Graph) []Diagnostic {
 	return diags
 }
 
+// lintGoalGateFailEdge warns when a goal_gate node declares a retry_target but
+// none of its outgoing edges routes outcome=fail. The rejection is then only
+// noticed when the run reaches exit, after every downstream node has run
+// against work the gate already refused. An unconditional fallback edge or any
+// edge to the retry_target counts as routing the failure.
+func lintGoalGateFailEdge(g *model.Graph) []Diagnostic {
+	var diags []Diagnostic
+	for id, n := range g.Nodes {
+		if n == nil || !strings.EqualFold(n.Attr("goal_gate", "false"), "true") {
+			continue
+		}
+		target := strings.TrimSpace(n.Attr("retry_target", ""))
+		if target == "" {
+			continue
+		}
+		if _, ok := g.Nodes[target]; !ok {
+			continue
+		}
+		if goalGateRoutesFail(g, id, target) {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			Rule:     "goal_gate_fail_edge",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("goal_gate node has retry_target=%s but no outgoing edge routes outcome=fail", target),
+			NodeID:   id,
+			Fix:      fmt.Sprintf("add %s -> %s [condition=\"outcome=fail\"]", id, target),
+		})
+	}
+	return diags
+}
+
+// goalGateRoutesFail reports whether a fail outcome at gateID leaves through
+// one of its edges: an outcome=fail style condition, an unconditional edge,
+// or an edge to target.
+func goalGateRoutesFail(g *model.Graph, gateID, target string) bool {
+	for _, e := range g.Outgoing(gateID) {
+		if e == nil {
+			continue
+		}
+		cond := strings.TrimSpace(e.Condition())
+		if cond == "" || e.To == target || conditionRoutesFailOutcome(cond) {
+			return true
+		}
+	}
+	return false
+}
+
 func lintGoalGateExitStatusContract(g *model.Graph) []Diagnostic {
 	exitIDs := findAllExitNodeIDs(g)
 	if len(exitIDs) == 0 {
@@ -878,12 +995,12 @@ func lintToolCommandRequired(g *model.Graph) []Diagnostic {
 		if !nodeResolvesToTool(n) {
 			continue
 		}
-		if strings.TrimSpace(n.Attr("tool_command", "")) != "" {
+		if strings.TrimSpace(n.Attr("tool_command", "")) != "" || strings.TrimSpace(n.Attr("test_command", "")) != "" {
 			continue
 		}
 
 		msg := "tool node missing tool_command attribute"
-		fix := "set tool_command=\"...\""
+		fix := "set tool_command=\"...\" (or test_command=\"...\" for a test run)"
 		if strings.TrimSpace(n.Attr("command", "")) != "" {
 			msg = "tool node uses command attribute; expected tool_command"
 			fix = "rename command=... to tool_command=..."
@@ -900,35 +1017,63 @@ func lintToolCommandRequired(g *model.Graph) []Diagnostic {
 	return diags
 }
 
-// lintValidateScriptFailureContract checks that any tool_command delegating to
-// a runtime-authored validate script (sh scripts/validate-*.sh) also includes a
-// KILROY_VALIDATE_FAILURE fallback so postmortem receives an actionable repair
-// signal when the script is missing or exits before printing its own output.
-func lintValidateScriptFailureContract(g *model.Graph) []Diagnostic {
-	validateScriptRe := regexp.MustCompile(`\bsh\s+scripts/validate-[^\s"']+\.sh\b`)
+// lintToolTestCommandConflict rejects nodes that set both tool_command and
+// test_command: the engine runs tool_command and would silently skip the
+// test run.
+func lintToolTestCommandConflict(g *model.Graph) []Diagnostic {
 	var diags []Diagnostic
 	for id, n := range g.Nodes {
-		if n == nil || !nodeResolvesToTool(n) {
-			continue
-		}
-		cmd := n.Attr("tool_command", "")
-		if !validateScriptRe.MatchString(cmd) {
+		if n == nil {
 			continue
 		}
-		if strings.Contains(cmd, "KILROY_VALIDATE_FAILURE") {
+		if strings.TrimSpace(n.Attr("tool_command", "")) == "" || strings.TrimSpace(n.Attr("test_command", "")) == "" {
 			continue
 		}
 		diags = append(diags, Diagnostic{
-			Rule:     "validate_script_failure_contract",
-			Severity: SeverityWarning,
-			Message:  fmt.Sprintf("tool_command calls sh scripts/validate-*.sh but has no KILROY_VALIDATE_FAILURE fallback; postmortem cannot identify a missing or crashing script"),
+			Rule:     "tool_test_command_conflict",
+			Severity: SeverityError,
+			Message:  "node sets both tool_command and test_command; only tool_command would run",
 			NodeID:   id,
-			Fix:      `append || { echo "KILROY_VALIDATE_FAILURE: <stage> script missing or failed — postmortem must write scripts/validate-<stage>.sh"; exit 1; } to tool_command`,
+			Fix:      "keep test_command for a test run (its output is parsed as test results), or tool_command otherwise",
 		})
 	}
 	return diags
 }
 
+// toolCommandAttrs are the attributes holding a tool node's shell command.
+var toolCommandAttrs = []string{"tool_command", "test_command"}
+
+// lintValidateScriptFailureContract checks that any tool_command or
+// test_command delegating to a runtime-authored validate script
+// (sh scripts/validate-*.sh) also includes a KILROY_VALIDATE_FAILURE fallback so postmortem receives an actionable repair
+// signal when the script is missing or exits before printing its own output.
+func lintValidateScriptFailureContract(g *model.Graph) []Diagnostic {
+	validateScriptRe := regexp.MustCompile(`\bsh\s+scripts/validate-[^\s"']+\.sh\b`)
+	var diags []Diagnostic
+	for id, n := range g.Nodes {
+		if n == nil || !nodeResolvesToTool(n) {
+			continue
+		}
+		for _, attr := range toolCommandAttrs {
+			cmd := n.Attr(attr, "")
+			if !validateScriptRe.MatchString(cmd) {
+				continue
+			}
+			if strings.Contains(cmd, "KILROY_VALIDATE_FAILURE") {
+				continue
+			}
+			diags = append(diags, Diagnostic{
+				Rule:     "validate_script_failure_contract",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("%s calls sh scripts/validate-*.sh but has no KILROY_VALIDATE_FAILURE fallback; postmortem cannot identify a missing or crashing script", attr),
+				NodeID:   id,
+				Fix:      `append || { echo "KILROY_VALIDATE_FAILURE: <stage> script missing or failed — postmortem must write scripts/validate-<stage>.sh"; exit 1; } to ` + attr,
+			})
+		}
+	}
+	return diags
+}
+
 func nodeResolvesToTool(n *model.Node) bool {
 	typeOverride := strings.TrimSpace(n.Attr("type", ""))
 	if typeOverride != "" {
@@ -1320,17 +1465,80 @@ func lintToolCommandAbsPath(g *model.Graph) []Diagnostic {
 		if !nodeResolvesToTool(n) {
 			continue
 		}
-		cmd := strings.TrimSpace(n.Attr("tool_command", ""))
-		if cmd == "" {
+		for _, attr := range toolCommandAttrs {
+			cmd := strings.TrimSpace(n.Attr(attr, ""))
+			if cmd == "" {
+				continue
+			}
+			if toolCommandAbsPathPattern.MatchString(cmd) {
+				diags = append(diags, Diagnostic{
+					Rule:     "tool_command_abs_path",
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("%s contains 'cd /…' which overrides the engine worktree CWD", attr),
+					NodeID:   id,
+					Fix:      "remove the 'cd /…' prefix; the engine sets the working directory to the worktree automatically",
+				})
+			}
+		}
+	}
+	return diags
+}
+
+// lintToolsAllowList checks tools="..." on nodes. Only API agent_loop
+// sessions enforce the list, so it is an error anywhere else the graph can
+// see (the backend is chosen by run config; the engine fails CLI stages
+// that set it). Names must be built-in tools, MCP tools (<server>__<tool>),
+// or, when the graph declares agent_tools, custom tools.
+func lintToolsAllowList(g *model.Graph) []Diagnostic {
+	builtin := map[string]bool{}
+	for _, name := range agent.BuiltinToolNames() {
+		builtin[name] = true
+	}
+	hasCustom := strings.TrimSpace(g.Attrs["agent_tools"]) != ""
+	var diags []Diagnostic
+	for id, n := range g.Nodes {
+		if n == nil {
+			continue
+		}
+		raw := strings.TrimSpace(n.Attr("tools", ""))
+		if raw == "" {
 			continue
 		}
-		if toolCommandAbsPathPattern.MatchString(cmd) {
+		if n.Shape() != "box" {
 			diags = append(diags, Diagnostic{
-				Rule:     "tool_command_abs_path",
-				Severity: SeverityWarning,
-				Message:  fmt.Sprintf("tool_command contains 'cd /…' which overrides the engine worktree CWD"),
+				Rule:     "tools_allow_list",
+				Severity: SeverityError,
+				Message:  "tools is set on a node that does not run an agent session, so it has no effect",
+				NodeID:   id,
+				Fix:      "remove tools, or move it to the shape=box node whose tools it should limit",
+			})
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(n.Attr("agent_mode", "")), "one_shot") {
+			diags = append(diags, Diagnostic{
+				Rule:     "tools_allow_list",
+				Severity: SeverityError,
+				Message:  "tools is set on an agent_mode=one_shot node, which calls no tools; only agent_loop sessions enforce it",
+				NodeID:   id,
+				Fix:      "remove tools, or use agent_mode=agent_loop",
+			})
+			continue
+		}
+		var unknown []string
+		for _, name := range strings.Split(raw, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || builtin[name] || strings.Contains(name, "__") || hasCustom {
+				continue
+			}
+			unknown = append(unknown, name)
+		}
+		if len(unknown) > 0 {
+			diags = append(diags, Diagnostic{
+				Rule:     "tools_allow_list",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("tools names unknown tool(s) %s", strings.Join(unknown, ", ")),
 				NodeID:   id,
-				Fix:      "remove the 'cd /…' prefix; the engine sets the working directory to the worktree automatically",
+				Fix:      "use built-in tool names (" + strings.Join(agent.BuiltinToolNames(), ", ") + "), MCP tools as <server>__<tool>, or declare custom tools in the graph's agent_tools file",
 			})
 		}
 	}
@@ -1,6 +1,7 @@
 package validate
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -101,6 +102,30 @@ digraph G {
 	assertHasRule(t, diags, "condition_syntax", SeverityError)
 }
 
+func TestValidate_AutoModelSyntax(t *testing.T) {
+	g, err := dot.Parse([]byte(`
+digraph G {
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  a [shape=box, llm_provider=openai, llm_model="auto(tools, fast)"]
+  b [shape=box, llm_provider=openai, llm_model="auto(tools, min_context=200k)"]
+  start -> a -> b -> exit
+}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var got []Diagnostic
+	for _, d := range Validate(g) {
+		if d.Rule == "auto_model_syntax" {
+			got = append(got, d)
+		}
+	}
+	if len(got) != 1 || got[0].NodeID != "a" || got[0].Severity != SeverityError {
+		t.Fatalf("auto_model_syntax diags = %+v", got)
+	}
+}
+
 func TestValidate_LLMProviderRequired_Metaspec(t *testing.T) {
 	g, err := dot.Parse([]byte(`
 digraph G {
@@ -138,6 +163,38 @@ digraph G {
 	assertNoRule(t, diags, "tool_command_required")
 }
 
+func TestValidate_ToolCommandRequired_ParallelogramWithTestCommand_NoError(t *testing.T) {
+	g, err := dot.Parse([]byte(`
+digraph G {
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  t [shape=parallelogram, test_command="go test -json ./..."]
+  start -> t -> exit
+}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	diags := Validate(g)
+	assertNoRule(t, diags, "tool_command_required")
+}
+
+func TestValidate_ToolTestCommandConflict_Error(t *testing.T) {
+	g, err := dot.Parse([]byte(`
+digraph G {
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  t [shape=parallelogram, tool_command="make build", test_command="go test -json ./..."]
+  start -> t -> exit
+}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	diags := Validate(g)
+	assertHasRule(t, diags, "tool_test_command_conflict", SeverityError)
+}
+
 func TestValidate_ToolCommandRequired_ParallelogramWithCommandOnly_Error(t *testing.T) {
 	g, err := dot.Parse([]byte(`
 digraph G {
@@ -790,6 +847,22 @@ digraph G {
 	assertNoRule(t, diags, "tool_command_abs_path")
 }
 
+func TestValidate_ToolCommandAbsPath_WarnsOnTestCommand(t *testing.T) {
+	g, err := dot.Parse([]byte(`
+digraph G {
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  t [shape=parallelogram, test_command="cd /src && go test ./..."]
+  start -> t -> exit
+}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	diags := Validate(g)
+	assertHasRule(t, diags, "tool_command_abs_path", SeverityWarning)
+}
+
 // --- Tests for V7.2: type_known lint rule ---
 
 func TestValidate_TypeKnownRule_RecognizedType_NoWarning(t *testing.T) {
@@ -1655,6 +1728,41 @@ digraph G {
 	assertNoRule(t, diags, "goal_gate_missing_node_retry_target")
 }
 
+func TestValidate_GoalGateFailEdge_UnconditionalFallbackRoutesFail(t *testing.T) {
+	// Shape of skills/create-dotfile/reference_template.dot: success goes to
+	// exit, everything else falls through to postmortem.
+	src := `
+digraph G {
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  implement [shape=box, prompt="x"]
+  postmortem [shape=box, prompt="x"]
+  review_consensus [shape=box, goal_gate=true, retry_target="postmortem", prompt="x"]
+  start -> review_consensus
+  review_consensus -> exit [condition="outcome=success"]
+  %s
+  postmortem -> implement -> review_consensus
+}
+`
+	for _, edge := range []string{
+		`review_consensus -> postmortem`,
+		`review_consensus -> postmortem [condition="context.failure_class=deterministic"]`,
+		`review_consensus -> implement`,
+	} {
+		g, err := dot.Parse([]byte(fmt.Sprintf(src, edge)))
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		assertNoRule(t, lintGoalGateFailEdge(g), "goal_gate_fail_edge")
+	}
+
+	g, err := dot.Parse([]byte(fmt.Sprintf(src, `review_consensus -> implement [condition="outcome=partial_success"]`)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	assertHasRule(t, lintGoalGateFailEdge(g), "goal_gate_fail_edge", SeverityWarning)
+}
+
 // --- Tests for reserved_keyword_node_id lint rule ---
 
 func TestValidate_ReservedKeywordNodeID_WarnsOnIfKeyword(t *testing.T) {
@@ -1715,6 +1823,22 @@ digraph G {
 	assertHasRule(t, diags, "validate_script_failure_contract", SeverityWarning)
 }
 
+func TestValidate_ValidateScriptFailureContract_TestCommandMissingFallback_Warns(t *testing.T) {
+	g, err := dot.Parse([]byte(`
+digraph G {
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  v [shape=parallelogram, test_command="sh scripts/validate-test.sh"]
+  start -> v -> exit
+}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	diags := Validate(g)
+	assertHasRule(t, diags, "validate_script_failure_contract", SeverityWarning)
+}
+
 func TestValidate_ValidateScriptFailureContract_WithFallback_NoWarn(t *testing.T) {
 	g, err := dot.Parse([]byte(`
 digraph G {
@@ -1883,3 +2007,38 @@ digraph G {
 	diags := Validate(g)
 	assertNoRule(t, diags, "custom_outcome_coverage")
 }
+
+// --- Tests for tools_allow_list lint rule ---
+
+func TestValidate_ToolsAllowList(t *testing.T) {
+	cases := []struct {
+		name, graphAttrs, node string
+		wantErr                bool
+	}{
+		{"builtin and mcp names", "", `a [shape=box, llm_provider=openai, tools="read_file, grep, github__search"]`, false},
+		{"unknown name", "", `a [shape=box, llm_provider=openai, tools="read_file,grpe"]`, true},
+		{"custom tools declared", `agent_tools="tools.yaml"`, `a [shape=box, llm_provider=openai, tools="query_fixture_db"]`, false},
+		{"one_shot", "", `a [shape=box, llm_provider=openai, agent_mode=one_shot, tools="read_file"]`, true},
+		{"tool node", "", `a [shape=parallelogram, tool_command="true", tools="read_file"]`, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := dot.Parse([]byte(`digraph G {
+  graph [` + tc.graphAttrs + `]
+  start [shape=Mdiamond]
+  exit  [shape=Msquare]
+  ` + tc.node + `
+  start -> a -> exit
+}`))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			diags := Validate(g)
+			if tc.wantErr {
+				assertHasRule(t, diags, "tools_allow_list", SeverityError)
+			} else {
+				assertNoRule(t, diags, "tools_allow_list")
+			}
+		})
+	}
+}
@@ -111,3 +111,11 @@ func (g *GitHook) RemoveWorktree(repoPath, worktreeDir string) error {
 func (g *GitHook) DiffStat(dir, fromSHA, toSHA string) (filesChanged, insertions, deletions int, err error) {
 	return gitutil.DiffStat(dir, fromSHA, toSHA)
 }
+
+func (g *GitHook) TreeSHA(dir string) (string, error) {
+	return gitutil.TreeSHA(dir, "HEAD")
+}
+
+func (g *GitHook) RestoreTree(worktreeDir, sha string) error {
+	return gitutil.ReadTree(worktreeDir, sha)
+}
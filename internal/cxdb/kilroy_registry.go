@@ -73,6 +73,7 @@ func KilroyAttractorRegistryBundle() (bundleID string, bundle RegistryBundle, sh
 				"3": field("tool_name", "string"),
 				"4": field("call_id", "string"),
 				"5": field("arguments_json", "string", opt()),
+				"6": field("subagent_id", "string", opt()),
 			}),
 			"com.kilroy.attractor.ToolResult": typeDef(map[string]any{
 				"1": field("run_id", "string"),
@@ -81,6 +82,7 @@ func KilroyAttractorRegistryBundle() (bundleID string, bundle RegistryBundle, sh
 				"4": field("call_id", "string"),
 				"5": field("output", "string", opt()),
 				"6": field("is_error", "bool", opt()),
+				"7": field("subagent_id", "string", opt()),
 			}),
 			"com.kilroy.attractor.Blob": typeDef(map[string]any{
 				"1": field("bytes", "bytes"),
@@ -118,6 +120,7 @@ func KilroyAttractorRegistryBundle() (bundleID string, bundle RegistryBundle, sh
 				"6": fieldSemantic("output_tokens", "u64", "count", opt()),
 				"7": field("tool_use_count", "u32", opt()),
 				"8": fieldSemantic("timestamp_ms", "u64", "unix_ms"),
+				"9": field("subagent_id", "string", opt()),
 			}),
 			"com.kilroy.attractor.Prompt": typeDef(map[string]any{
 				"1": field("run_id", "string"),
@@ -204,6 +207,29 @@ func KilroyAttractorRegistryBundle() (bundleID string, bundle RegistryBundle, sh
 				"4": field("question_text", "string", opt()),
 				"5": fieldSemantic("duration_ms", "u64", "duration_ms", opt()),
 			}),
+			// Manager-loop steering of a supervised child pipeline.
+			"com.kilroy.attractor.ManagerIntervention": typeDef(map[string]any{
+				"1":  field("run_id", "string"),
+				"2":  field("node_id", "string"),
+				"3":  fieldSemantic("timestamp_ms", "u64", "unix_ms"),
+				"4":  field("source", "string"),
+				"5":  field("reason", "string", opt()),
+				"6":  field("message", "string", opt()),
+				"7":  field("target_node_id", "string", opt()),
+				"8":  fieldArray("context_keys", "string", opt()),
+				"9":  field("reroute_node_id", "string", opt()),
+				"10": field("delivery", "string", opt()),
+			}),
+			// Checklist an API agent keeps with its update_plan tool.
+			"com.kilroy.attractor.PlanUpdated": typeDef(map[string]any{
+				"1": field("run_id", "string"),
+				"2": field("node_id", "string"),
+				"3": fieldSemantic("timestamp_ms", "u64", "unix_ms"),
+				"4": field("plan_json", "string"),
+				"5": field("completed", "u32"),
+				"6": field("total", "u32"),
+				"7": field("explanation", "string", opt()),
+			}),
 		},
 		Enums: map[string]any{},
 	}
@@ -16,6 +16,8 @@ func TestKilroyAttractorRegistryBundle_IncludesRequiredTypes(t *testing.T) {
 
 	required := []string{
 		"com.kilroy.attractor.RunStarted",
+		"com.kilroy.attractor.ManagerIntervention",
+		"com.kilroy.attractor.PlanUpdated",
 		"com.kilroy.attractor.RunCompleted",
 		"com.kilroy.attractor.RunFailed",
 		"com.kilroy.attractor.StageStarted",
@@ -29,12 +29,46 @@ func EncodeTurnPayload(typeID string, typeVersion int, data map[string]any) ([]b
 	return msgpack.Marshal(out)
 }
 
+// decodeTurnPayload is the inverse of EncodeTurnPayload: it maps numeric field
+// tags back to field names using the given registry types (Kilroy's bundle when
+// nil). Tags without a registered name are kept as-is.
+func decodeTurnPayload(types map[string]any, typeID string, typeVersion int, payload []byte) (map[string]any, error) {
+	var raw map[string]any
+	if len(payload) > 0 {
+		if err := msgpack.Unmarshal(payload, &raw); err != nil {
+			return nil, err
+		}
+	}
+	var fieldTags map[string]string
+	if types == nil {
+		fieldTags = registryFieldTags(typeID, typeVersion)
+	} else {
+		fieldTags = bundleFieldTags(types, typeID, typeVersion)
+	}
+	names := make(map[string]string, len(fieldTags))
+	for name, tag := range fieldTags {
+		names[tag] = name
+	}
+	out := make(map[string]any, len(raw))
+	for k, v := range raw {
+		if name, ok := names[k]; ok {
+			k = name
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
 func registryFieldTags(typeID string, typeVersion int) map[string]string {
 	_, bundle, _, err := KilroyAttractorRegistryBundle()
 	if err != nil {
 		return map[string]string{}
 	}
-	typeSpec, ok := bundle.Types[typeID].(map[string]any)
+	return bundleFieldTags(bundle.Types, typeID, typeVersion)
+}
+
+func bundleFieldTags(types map[string]any, typeID string, typeVersion int) map[string]string {
+	typeSpec, ok := types[typeID].(map[string]any)
 	if !ok {
 		return map[string]string{}
 	}
@@ -0,0 +1,492 @@
+package cxdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeebo/blake3"
+)
+
+const serverTag = "kilroy-cxdb"
+
+// Server exposes a Store over the CXDB HTTP and binary protocols. Only the
+// operations Kilroy's clients use are implemented.
+type Server struct {
+	Store *Store
+
+	sessions atomic.Uint64
+}
+
+// NewServer returns a server for store.
+func NewServer(store *Store) *Server {
+	return &Server{Store: store}
+}
+
+// Handler returns the HTTP API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	health := func(w http.ResponseWriter, r *http.Request) {
+		writeStoreJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+	}
+	mux.HandleFunc("GET /health", health)
+	mux.HandleFunc("GET /healthz", health)
+	mux.HandleFunc("POST /v1/contexts/create", s.handleCreateContext)
+	mux.HandleFunc("POST /v1/contexts/fork", s.handleCreateContext)
+	mux.HandleFunc("GET /v1/contexts", s.handleListContexts)
+	mux.HandleFunc("GET /v1/contexts/{id}", s.handleGetContext)
+	mux.HandleFunc("POST /v1/contexts/{id}/append", s.handleAppend)
+	mux.HandleFunc("GET /v1/contexts/{id}/turns", s.handleListTurns)
+	mux.HandleFunc("PUT /v1/registry/bundles/{id}", s.handlePublishBundle)
+	return mux
+}
+
+func (s *Server) handleCreateContext(w http.ResponseWriter, r *http.Request) {
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeStoreError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	base, err := parseStoreID(anyToString(req["base_turn_id"]))
+	if err != nil {
+		writeStoreError(w, http.StatusBadRequest, "invalid base_turn_id")
+		return
+	}
+	ci, err := s.Store.CreateContext(base)
+	if err != nil {
+		writeStoreErr(w, err)
+		return
+	}
+	writeStoreJSON(w, http.StatusOK, contextInfoJSON(ci))
+}
+
+func (s *Server) handleListContexts(w http.ResponseWriter, r *http.Request) {
+	out := []map[string]any{}
+	for _, ci := range s.Store.Contexts() {
+		out = append(out, contextInfoJSON(ci))
+	}
+	writeStoreJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleGetContext(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathStoreID(w, r)
+	if !ok {
+		return
+	}
+	ci, err := s.Store.Head(id)
+	if err != nil {
+		writeStoreErr(w, err)
+		return
+	}
+	writeStoreJSON(w, http.StatusOK, contextInfoJSON(ci))
+}
+
+func (s *Server) handleAppend(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathStoreID(w, r)
+	if !ok {
+		return
+	}
+	var req AppendTurnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeStoreError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if strings.TrimSpace(req.TypeID) == "" || req.TypeVersion <= 0 {
+		writeStoreError(w, http.StatusBadRequest, "type_id and type_version are required")
+		return
+	}
+	parent, err := parseStoreID(req.ParentTurnID)
+	if err != nil {
+		writeStoreError(w, http.StatusBadRequest, "invalid parent_turn_id")
+		return
+	}
+	payload, err := EncodeTurnPayload(req.TypeID, req.TypeVersion, req.Data)
+	if err != nil {
+		writeStoreError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ack, err := s.Store.Append(id, parent, req.TypeID, uint32(req.TypeVersion), payload, req.IdempotencyKey)
+	if err != nil {
+		writeStoreErr(w, err)
+		return
+	}
+	writeStoreJSON(w, http.StatusOK, map[string]any{
+		"context_id":   strconv.FormatUint(ack.ContextID, 10),
+		"turn_id":      strconv.FormatUint(ack.NewTurnID, 10),
+		"depth":        ack.NewDepth,
+		"content_hash": fmt.Sprintf("%x", ack.ContentHash),
+	})
+}
+
+func (s *Server) handleListTurns(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathStoreID(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	limit := 0
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeStoreError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	before, err := parseStoreID(q.Get("before_turn_id"))
+	if err != nil {
+		writeStoreError(w, http.StatusBadRequest, "invalid before_turn_id")
+		return
+	}
+	turns, ci, err := s.Store.Turns(id, before, limit)
+	if err != nil {
+		writeStoreErr(w, err)
+		return
+	}
+	out := make([]map[string]any, 0, len(turns))
+	for _, t := range turns {
+		data, err := s.Store.typedData(t)
+		if err != nil {
+			writeStoreError(w, http.StatusInternalServerError, fmt.Sprintf("turn %d: %v", t.TurnID, err))
+			return
+		}
+		out = append(out, map[string]any{
+			"turn_id":        strconv.FormatUint(t.TurnID, 10),
+			"parent_turn_id": strconv.FormatUint(t.ParentTurnID, 10),
+			"depth":          t.Depth,
+			"declared_type":  map[string]any{"type_id": t.TypeID, "type_version": t.TypeVersion},
+			"payload_hash":   t.Hash,
+			"data":           data,
+		})
+	}
+	writeStoreJSON(w, http.StatusOK, map[string]any{"meta": contextInfoJSON(ci), "turns": out})
+}
+
+func (s *Server) handlePublishBundle(w http.ResponseWriter, r *http.Request) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, int64(maxFrameSize)))
+	if err != nil {
+		writeStoreError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	created, err := s.Store.PublishBundle(r.PathValue("id"), raw)
+	if err != nil {
+		writeStoreError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func contextInfoJSON(ci BinaryContextInfo) map[string]any {
+	return map[string]any{
+		"context_id":   strconv.FormatUint(ci.ContextID, 10),
+		"head_turn_id": strconv.FormatUint(ci.HeadTurnID, 10),
+		"head_depth":   ci.HeadDepth,
+	}
+}
+
+func pathStoreID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeStoreError(w, http.StatusBadRequest, "invalid context id")
+		return 0, false
+	}
+	return id, true
+}
+
+// parseStoreID parses a decimal turn or context ID; empty means 0.
+func parseStoreID(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func writeStoreJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeStoreError(w http.ResponseWriter, status int, msg string) {
+	var env ErrorEnvelope
+	env.Error.Code = http.StatusText(status)
+	env.Error.Message = msg
+	writeStoreJSON(w, status, env)
+}
+
+func writeStoreErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, errStoreNotFound) {
+		writeStoreError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeStoreError(w, http.StatusInternalServerError, err.Error())
+}
+
+// ServeBinary accepts binary-protocol connections on ln until it is closed.
+func (s *Server) ServeBinary(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveBinaryConn(conn)
+	}
+}
+
+func (s *Server) serveBinaryConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		h, payload, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		respType, resp, err := s.handleFrame(h.MsgType, payload)
+		if err != nil {
+			respType, resp = msgTypeError, binaryErrorPayload(err)
+		}
+		if err := writeFrame(conn, respType, 0, h.ReqID, resp); err != nil {
+			return
+		}
+	}
+}
+
+// Binary error codes, loosely following HTTP status semantics.
+const (
+	binaryErrBadRequest  uint32 = 400
+	binaryErrNotFound    uint32 = 404
+	binaryErrUnsupported uint32 = 501
+	binaryErrInternal    uint32 = 500
+)
+
+func binaryErrorPayload(err error) []byte {
+	code := binaryErrInternal
+	var be *BinaryError
+	switch {
+	case errors.As(err, &be):
+		code = be.Code
+	case errors.Is(err, errStoreNotFound):
+		code = binaryErrNotFound
+	}
+	detail := err.Error()
+	if be != nil {
+		detail = be.Detail
+	}
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, code)
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(detail)))
+	buf.WriteString(detail)
+	return buf.Bytes()
+}
+
+func (s *Server) handleFrame(msgType uint16, p []byte) (uint16, []byte, error) {
+	rd := &frameReader{b: p}
+	switch msgType {
+	case msgTypeHello:
+		_ = rd.u32() // client protocol version
+		_ = rd.bytes(rd.u32())
+		if rd.err != nil {
+			return 0, nil, rd.err
+		}
+		var buf bytes.Buffer
+		_ = binary.Write(&buf, binary.LittleEndian, binaryProtocolVersion)
+		_ = binary.Write(&buf, binary.LittleEndian, s.sessions.Add(1))
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(len(serverTag)))
+		buf.WriteString(serverTag)
+		return msgTypeHello, buf.Bytes(), nil
+	case msgTypeCtxCreate, msgTypeCtxFork, msgTypeGetHead:
+		id := rd.u64()
+		if rd.err != nil {
+			return 0, nil, rd.err
+		}
+		var ci BinaryContextInfo
+		var err error
+		if msgType == msgTypeGetHead {
+			ci, err = s.Store.Head(id)
+		} else {
+			ci, err = s.Store.CreateContext(id)
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		var buf [20]byte
+		binary.LittleEndian.PutUint64(buf[0:8], ci.ContextID)
+		binary.LittleEndian.PutUint64(buf[8:16], ci.HeadTurnID)
+		binary.LittleEndian.PutUint32(buf[16:20], ci.HeadDepth)
+		return msgType, buf[:], nil
+	case msgTypeAppend:
+		ctxID := rd.u64()
+		parent := rd.u64()
+		typeID := string(rd.bytes(rd.u32()))
+		typeVersion := rd.u32()
+		encoding := rd.u32()
+		compression := rd.u32()
+		_ = rd.u32() // uncompressed length
+		hash := rd.bytes(32)
+		payload := rd.bytes(rd.u32())
+		var idemKey string
+		if rd.remaining() >= 4 {
+			idemKey = string(rd.bytes(rd.u32()))
+		}
+		if rd.err != nil {
+			return 0, nil, rd.err
+		}
+		if encoding != 1 || compression != 0 {
+			return 0, nil, &BinaryError{Code: binaryErrUnsupported, Detail: fmt.Sprintf("unsupported encoding=%d compression=%d", encoding, compression)}
+		}
+		if sum := blake3.Sum256(payload); !bytes.Equal(hash, sum[:]) {
+			return 0, nil, &BinaryError{Code: binaryErrBadRequest, Detail: "payload hash mismatch"}
+		}
+		ack, err := s.Store.Append(ctxID, parent, typeID, typeVersion, payload, idemKey)
+		if err != nil {
+			return 0, nil, err
+		}
+		var buf [52]byte
+		binary.LittleEndian.PutUint64(buf[0:8], ack.ContextID)
+		binary.LittleEndian.PutUint64(buf[8:16], ack.NewTurnID)
+		binary.LittleEndian.PutUint32(buf[16:20], ack.NewDepth)
+		copy(buf[20:], ack.ContentHash[:])
+		return msgTypeAppend, buf[:], nil
+	case msgTypePutBlob:
+		var hash [32]byte
+		copy(hash[:], rd.bytes(32))
+		raw := rd.bytes(rd.u32())
+		if rd.err != nil {
+			return 0, nil, rd.err
+		}
+		wasNew, err := s.Store.PutBlob(hash, raw)
+		if err != nil {
+			return 0, nil, &BinaryError{Code: binaryErrBadRequest, Detail: err.Error()}
+		}
+		resp := append(hash[:], 0)
+		if wasNew {
+			resp[32] = 1
+		}
+		return msgTypePutBlob, resp, nil
+	}
+	return 0, nil, &BinaryError{Code: binaryErrUnsupported, Detail: fmt.Sprintf("unsupported message type %d", msgType)}
+}
+
+// frameReader decodes little-endian request fields, remembering the first
+// short read so handlers can check once at the end.
+type frameReader struct {
+	b   []byte
+	err error
+}
+
+func (r *frameReader) remaining() int { return len(r.b) }
+
+func (r *frameReader) bytes(n uint32) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(n) > uint64(len(r.b)) {
+		r.err = &BinaryError{Code: binaryErrBadRequest, Detail: "short frame payload"}
+		return nil
+	}
+	out := r.b[:n]
+	r.b = r.b[n:]
+	return out
+}
+
+func (r *frameReader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *frameReader) u64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// LocalServer is a Store served on local HTTP and binary listeners, for
+// `kilroy cxdb serve` and for runs that start CXDB in-process.
+type LocalServer struct {
+	HTTPBaseURL string
+	BinaryAddr  string
+
+	store    *Store
+	httpSrv  *http.Server
+	binLn    net.Listener
+	done     sync.WaitGroup
+	closeErr error
+	once     sync.Once
+}
+
+// StartLocal opens the store in dataDir and starts serving it. Addresses may
+// use port 0; the bound addresses are reported in the result.
+func StartLocal(dataDir, httpAddr, binaryAddr string) (*LocalServer, error) {
+	store, err := OpenStore(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	httpLn, err := net.Listen("tcp", httpAddr)
+	if err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("cxdb http listen %s: %w", httpAddr, err)
+	}
+	binLn, err := net.Listen("tcp", binaryAddr)
+	if err != nil {
+		_ = httpLn.Close()
+		_ = store.Close()
+		return nil, fmt.Errorf("cxdb binary listen %s: %w", binaryAddr, err)
+	}
+	srv := NewServer(store)
+	ls := &LocalServer{
+		HTTPBaseURL: "http://" + httpLn.Addr().String(),
+		BinaryAddr:  binLn.Addr().String(),
+		store:       store,
+		httpSrv:     &http.Server{Handler: srv.Handler(), ReadHeaderTimeout: 10 * time.Second},
+		binLn:       binLn,
+	}
+	ls.done.Add(2)
+	go func() {
+		defer ls.done.Done()
+		_ = ls.httpSrv.Serve(httpLn)
+	}()
+	go func() {
+		defer ls.done.Done()
+		_ = srv.ServeBinary(binLn)
+	}()
+	return ls, nil
+}
+
+// Close stops both listeners and flushes the store.
+func (l *LocalServer) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = l.binLn.Close()
+		_ = l.httpSrv.Shutdown(ctx)
+		l.done.Wait()
+		l.closeErr = l.store.Close()
+	})
+	return l.closeErr
+}
@@ -0,0 +1,163 @@
+package cxdb
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zeebo/blake3"
+)
+
+func startTestLocal(t *testing.T, dir string) *LocalServer {
+	t.Helper()
+	ls, err := StartLocal(dir, "127.0.0.1:0", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("StartLocal: %v", err)
+	}
+	t.Cleanup(func() { _ = ls.Close() })
+	return ls
+}
+
+func TestLocalServer_BinaryAppendReadableOverHTTP(t *testing.T) {
+	dir := t.TempDir()
+	ls := startTestLocal(t, dir)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := New(ls.HTTPBaseURL)
+	if err := client.Health(ctx); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	bundleID, bundle, _, err := KilroyAttractorRegistryBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err := client.PublishRegistryBundle(ctx, bundleID, bundle); err != nil || status != 201 {
+		t.Fatalf("PublishRegistryBundle: status=%d err=%v", status, err)
+	}
+
+	bin, err := DialBinary(ctx, ls.BinaryAddr, "kilroy/test")
+	if err != nil {
+		t.Fatalf("DialBinary: %v", err)
+	}
+	defer bin.Close()
+	if bin.ServerTag != serverTag {
+		t.Fatalf("server tag = %q", bin.ServerTag)
+	}
+	ci, err := bin.CreateContext(ctx, 0)
+	if err != nil {
+		t.Fatalf("CreateContext: %v", err)
+	}
+	payload, err := EncodeTurnPayload("com.kilroy.attractor.RunStarted", 1, map[string]any{
+		"run_id":    "r1",
+		"logs_root": "/tmp/logs/r1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := bin.AppendTurn(ctx, ci.ContextID, 0, "com.kilroy.attractor.RunStarted", 1, payload)
+	if err != nil {
+		t.Fatalf("AppendTurn: %v", err)
+	}
+	again, err := bin.AppendTurn(ctx, ci.ContextID, 0, "com.kilroy.attractor.RunStarted", 1, payload)
+	if err != nil || again.NewTurnID != first.NewTurnID {
+		t.Fatalf("idempotent retry: ack=%+v err=%v (first %+v)", again, err, first)
+	}
+	second, err := client.AppendTurn(ctx, "1", AppendTurnRequest{
+		TypeID:      "com.kilroy.attractor.CheckpointSaved",
+		TypeVersion: 1,
+		Data:        map[string]any{"run_id": "r1", "node_id": "impl", "checkpoint_path": "/tmp/logs/r1/checkpoint.json"},
+	})
+	if err != nil {
+		t.Fatalf("HTTP AppendTurn: %v", err)
+	}
+	if second.Depth != 2 || second.ContentHash == "" {
+		t.Fatalf("HTTP append = %+v", second)
+	}
+
+	raw := []byte("artifact body")
+	if wasNew, err := bin.PutBlob(ctx, blake3.Sum256(raw), uint32(len(raw)), bytes.NewReader(raw)); err != nil || !wasNew {
+		t.Fatalf("PutBlob: new=%v err=%v", wasNew, err)
+	}
+	if wasNew, err := bin.PutBlob(ctx, blake3.Sum256(raw), uint32(len(raw)), bytes.NewReader(raw)); err != nil || wasNew {
+		t.Fatalf("PutBlob again: new=%v err=%v", wasNew, err)
+	}
+	if _, err := bin.PutBlob(ctx, [32]byte{1}, uint32(len(raw)), bytes.NewReader(raw)); err == nil {
+		t.Fatal("PutBlob accepted a wrong hash")
+	}
+
+	forked, err := bin.ForkContext(ctx, first.NewTurnID)
+	if err != nil || forked.HeadTurnID != first.NewTurnID || forked.HeadDepth != 1 {
+		t.Fatalf("ForkContext: %+v err=%v", forked, err)
+	}
+
+	turns, err := client.ListTurns(ctx, "1", ListTurnsOptions{})
+	if err != nil {
+		t.Fatalf("ListTurns: %v", err)
+	}
+	if len(turns) != 2 {
+		t.Fatalf("turns = %+v", turns)
+	}
+	if turns[0].TypeID != "com.kilroy.attractor.RunStarted" || turns[0].Payload["logs_root"] != "/tmp/logs/r1" {
+		t.Fatalf("first turn = %+v", turns[0])
+	}
+	if turns[1].Payload["checkpoint_path"] != "/tmp/logs/r1/checkpoint.json" || turns[1].ParentTurnID != turns[0].TurnID {
+		t.Fatalf("second turn = %+v", turns[1])
+	}
+	if _, err := client.ListTurns(ctx, "99", ListTurnsOptions{}); err == nil {
+		t.Fatal("ListTurns on unknown context should fail")
+	}
+	if _, err := bin.GetHead(ctx, 99); err == nil {
+		t.Fatal("GetHead on unknown context should fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blobs")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_ReopenReplaysJournal(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci, err := s.CreateContext(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, err := s.Append(ci.ContextID, 0, "com.kilroy.attractor.RunStarted", 1, []byte{0x80}, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a crash mid-write.
+	f, err := os.OpenFile(filepath.Join(dir, "journal.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"op":"turn","context_id":1,"tu`)
+	_ = f.Close()
+
+	s, err = OpenStore(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	head, err := s.Head(ci.ContextID)
+	if err != nil || head.HeadTurnID != ack.NewTurnID || head.HeadDepth != 1 {
+		t.Fatalf("head after reopen = %+v err=%v", head, err)
+	}
+	dup, err := s.Append(ci.ContextID, 0, "com.kilroy.attractor.RunStarted", 1, []byte{0x80}, "k1")
+	if err != nil || dup.NewTurnID != ack.NewTurnID {
+		t.Fatalf("idempotency key lost on reopen: %+v err=%v", dup, err)
+	}
+	next, err := s.Append(ci.ContextID, 0, "com.kilroy.attractor.RunStarted", 1, []byte{0x80}, "")
+	if err != nil || next.NewTurnID != ack.NewTurnID+1 || next.NewDepth != 2 {
+		t.Fatalf("append after reopen = %+v err=%v", next, err)
+	}
+}
@@ -0,0 +1,396 @@
+package cxdb
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zeebo/blake3"
+)
+
+var errStoreNotFound = errors.New("not found")
+
+// Store is a file-backed implementation of the part of CXDB that Kilroy
+// uses: contexts (head pointers into a global turn DAG), append-only turns,
+// content-addressed blobs and published registry bundles.
+//
+// Layout under the data directory:
+//
+//	journal.jsonl         one record per context creation or turn append
+//	blobs/<blake3 hex>    PUT_BLOB content
+//	registry/<id>.json    published registry bundles
+//
+// The journal is replayed on open, so a store can be reopened by a later
+// `kilroy cxdb serve` and keep serving the same context and turn IDs.
+type Store struct {
+	dir string
+
+	mu       sync.Mutex
+	journal  *os.File
+	turns    map[uint64]*storedTurn
+	contexts map[uint64]*storedContext
+	lastTurn uint64
+	lastCtx  uint64
+	bundles  map[string]map[string]any // bundle id -> types
+}
+
+type storedTurn struct {
+	TurnID       uint64 `json:"turn_id"`
+	ParentTurnID uint64 `json:"parent_turn_id"`
+	Depth        uint32 `json:"depth"`
+	TypeID       string `json:"type_id"`
+	TypeVersion  uint32 `json:"type_version"`
+	Payload      []byte `json:"payload"` // msgpack, keyed by field tag
+	Hash         string `json:"hash"`    // blake3 of Payload, hex
+}
+
+type storedContext struct {
+	id   uint64
+	head uint64
+	idem map[string]uint64 // idempotency key -> turn id
+}
+
+type journalRecord struct {
+	Op             string      `json:"op"` // "context" or "turn"
+	ContextID      uint64      `json:"context_id"`
+	HeadTurnID     uint64      `json:"head_turn_id,omitempty"`
+	Turn           *storedTurn `json:"turn,omitempty"`
+	IdempotencyKey string      `json:"idempotency_key,omitempty"`
+}
+
+// OpenStore opens (creating if needed) a store rooted at dir.
+func OpenStore(dir string) (*Store, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("cxdb store: data dir is required")
+	}
+	for _, sub := range []string{"blobs", "registry"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	s := &Store{
+		dir:      dir,
+		turns:    map[uint64]*storedTurn{},
+		contexts: map[uint64]*storedContext{},
+		bundles:  map[string]map[string]any{},
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "journal.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.journal = f
+	return s, nil
+}
+
+func (s *Store) replay() error {
+	path := filepath.Join(s.dir, "journal.jsonl")
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var good int64
+	for line := 1; ; line++ {
+		b, readErr := r.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		if len(b) == 0 {
+			return nil
+		}
+		var rec journalRecord
+		if err := json.Unmarshal(b, &rec); err != nil {
+			if readErr == io.EOF {
+				// A torn final line from a crash: drop it so appends stay parseable.
+				return os.Truncate(path, good)
+			}
+			return fmt.Errorf("cxdb store: journal line %d: %w", line, err)
+		}
+		s.apply(rec)
+		good += int64(len(b))
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// apply updates in-memory state from a journal record. Callers hold s.mu (or
+// are replaying before the store is shared).
+func (s *Store) apply(rec journalRecord) {
+	switch rec.Op {
+	case "context":
+		s.contexts[rec.ContextID] = &storedContext{id: rec.ContextID, head: rec.HeadTurnID, idem: map[string]uint64{}}
+		s.lastCtx = max(s.lastCtx, rec.ContextID)
+	case "turn":
+		if rec.Turn == nil {
+			return
+		}
+		s.turns[rec.Turn.TurnID] = rec.Turn
+		s.lastTurn = max(s.lastTurn, rec.Turn.TurnID)
+		if c := s.contexts[rec.ContextID]; c != nil {
+			c.head = rec.Turn.TurnID
+			if rec.IdempotencyKey != "" {
+				c.idem[rec.IdempotencyKey] = rec.Turn.TurnID
+			}
+		}
+	}
+}
+
+func (s *Store) write(rec journalRecord) error {
+	if s.journal == nil {
+		return fmt.Errorf("cxdb store is closed")
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.journal.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	s.apply(rec)
+	return nil
+}
+
+// Close flushes the journal to disk.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.journal == nil {
+		return nil
+	}
+	syncErr := s.journal.Sync()
+	err := s.journal.Close()
+	s.journal = nil
+	if syncErr != nil {
+		return syncErr
+	}
+	return err
+}
+
+// CreateContext starts a new context whose head is baseTurnID (0 for an empty
+// context). Forking is the same operation with a non-zero base.
+func (s *Store) CreateContext(baseTurnID uint64) (BinaryContextInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if baseTurnID != 0 && s.turns[baseTurnID] == nil {
+		return BinaryContextInfo{}, fmt.Errorf("base turn %d: %w", baseTurnID, errStoreNotFound)
+	}
+	id := s.lastCtx + 1
+	if err := s.write(journalRecord{Op: "context", ContextID: id, HeadTurnID: baseTurnID}); err != nil {
+		return BinaryContextInfo{}, err
+	}
+	return s.info(s.contexts[id]), nil
+}
+
+// Head returns the context's current head.
+func (s *Store) Head(contextID uint64) (BinaryContextInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.contexts[contextID]
+	if c == nil {
+		return BinaryContextInfo{}, fmt.Errorf("context %d: %w", contextID, errStoreNotFound)
+	}
+	return s.info(c), nil
+}
+
+// Contexts lists all contexts in creation order.
+func (s *Store) Contexts() []BinaryContextInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]BinaryContextInfo, 0, len(s.contexts))
+	for _, c := range s.contexts {
+		out = append(out, s.info(c))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ContextID < out[j].ContextID })
+	return out
+}
+
+func (s *Store) info(c *storedContext) BinaryContextInfo {
+	ci := BinaryContextInfo{ContextID: c.id, HeadTurnID: c.head}
+	if t := s.turns[c.head]; t != nil {
+		ci.HeadDepth = t.Depth
+	}
+	return ci
+}
+
+// Append adds a turn to a context. parentTurnID 0 means the context head.
+// Repeating an idempotency key returns the turn recorded the first time.
+func (s *Store) Append(contextID, parentTurnID uint64, typeID string, typeVersion uint32, payload []byte, idempotencyKey string) (AppendAck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.contexts[contextID]
+	if c == nil {
+		return AppendAck{}, fmt.Errorf("context %d: %w", contextID, errStoreNotFound)
+	}
+	if idempotencyKey != "" {
+		if id, ok := c.idem[idempotencyKey]; ok {
+			return s.ack(contextID, s.turns[id]), nil
+		}
+	}
+	if parentTurnID == 0 {
+		parentTurnID = c.head
+	}
+	var depth uint32 = 1
+	if parentTurnID != 0 {
+		parent := s.turns[parentTurnID]
+		if parent == nil {
+			return AppendAck{}, fmt.Errorf("parent turn %d: %w", parentTurnID, errStoreNotFound)
+		}
+		depth = parent.Depth + 1
+	}
+	if payload == nil {
+		payload = []byte{}
+	}
+	sum := blake3.Sum256(payload)
+	t := &storedTurn{
+		TurnID:       s.lastTurn + 1,
+		ParentTurnID: parentTurnID,
+		Depth:        depth,
+		TypeID:       typeID,
+		TypeVersion:  typeVersion,
+		Payload:      payload,
+		Hash:         hex.EncodeToString(sum[:]),
+	}
+	if err := s.write(journalRecord{Op: "turn", ContextID: contextID, Turn: t, IdempotencyKey: idempotencyKey}); err != nil {
+		return AppendAck{}, err
+	}
+	return s.ack(contextID, t), nil
+}
+
+func (s *Store) ack(contextID uint64, t *storedTurn) AppendAck {
+	out := AppendAck{ContextID: contextID, NewTurnID: t.TurnID, NewDepth: t.Depth}
+	_, _ = hex.Decode(out.ContentHash[:], []byte(t.Hash))
+	return out
+}
+
+// Turns returns up to limit turns (0 = all) ending at the context head, or
+// just before beforeTurnID when non-zero, oldest first.
+func (s *Store) Turns(contextID, beforeTurnID uint64, limit int) ([]storedTurn, BinaryContextInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.contexts[contextID]
+	if c == nil {
+		return nil, BinaryContextInfo{}, fmt.Errorf("context %d: %w", contextID, errStoreNotFound)
+	}
+	cur := c.head
+	if beforeTurnID != 0 {
+		t := s.turns[beforeTurnID]
+		if t == nil {
+			return nil, BinaryContextInfo{}, fmt.Errorf("turn %d: %w", beforeTurnID, errStoreNotFound)
+		}
+		cur = t.ParentTurnID
+	}
+	var out []storedTurn
+	for cur != 0 && (limit <= 0 || len(out) < limit) {
+		t := s.turns[cur]
+		if t == nil {
+			break
+		}
+		out = append(out, *t)
+		cur = t.ParentTurnID
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, s.info(c), nil
+}
+
+// PutBlob stores raw content under its BLAKE3 hash after verifying it.
+func (s *Store) PutBlob(hash [32]byte, raw []byte) (wasNew bool, err error) {
+	if blake3.Sum256(raw) != hash {
+		return false, fmt.Errorf("blob hash mismatch")
+	}
+	path := filepath.Join(s.dir, "blobs", hex.EncodeToString(hash[:]))
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp, path)
+}
+
+var bundleIDRe = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+
+// PublishBundle records a registry bundle. Field names from published bundles
+// are used to render the typed turn view. It reports whether the bundle was new.
+func (s *Store) PublishBundle(bundleID string, raw []byte) (created bool, err error) {
+	if !bundleIDRe.MatchString(bundleID) {
+		return false, fmt.Errorf("invalid bundle id %q", bundleID)
+	}
+	var bundle RegistryBundle
+	if err := json.Unmarshal(raw, &bundle); err != nil {
+		return false, fmt.Errorf("invalid bundle: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := filepath.Join(s.dir, "registry", bundleID+".json")
+	_, statErr := os.Stat(path)
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		return false, err
+	}
+	s.bundles[bundleID] = bundle.Types
+	return statErr != nil, nil
+}
+
+// typedData decodes a turn's msgpack payload into named fields using the
+// published bundle that declares its type, falling back to Kilroy's own.
+func (s *Store) typedData(t storedTurn) (map[string]any, error) {
+	return decodeTurnPayload(s.typesFor(t.TypeID), t.TypeID, int(t.TypeVersion), t.Payload)
+}
+
+func (s *Store) typesFor(typeID string) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.bundles) == 0 {
+		s.loadBundles()
+	}
+	ids := make([]string, 0, len(s.bundles))
+	for id := range s.bundles {
+		ids = append(ids, id)
+	}
+	// Bundle IDs embed a content hash, so order only needs to be stable.
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, ok := s.bundles[id][typeID]; ok {
+			return s.bundles[id]
+		}
+	}
+	return nil
+}
+
+func (s *Store) loadBundles() {
+	entries, _ := os.ReadDir(filepath.Join(s.dir, "registry"))
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(s.dir, "registry", e.Name()))
+		if err != nil {
+			continue
+		}
+		var bundle RegistryBundle
+		if json.Unmarshal(raw, &bundle) == nil {
+			s.bundles[id] = bundle.Types
+		}
+	}
+}
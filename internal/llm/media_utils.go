@@ -46,3 +46,34 @@ func DataURI(mimeType string, data []byte) string {
 	}
 	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
 }
+
+// DocumentBytes returns a document's inline data, reading it from disk when
+// URL is a local path. Remote URLs yield nil data; adapters that need the
+// bytes reject those.
+func DocumentBytes(d *DocumentData) (data []byte, mediaType string, err error) {
+	if d == nil {
+		return nil, "", nil
+	}
+	mediaType = strings.TrimSpace(d.MediaType)
+	data = d.Data
+	if len(data) == 0 {
+		u := strings.TrimSpace(d.URL)
+		if !IsLocalPath(u) {
+			return nil, mediaType, nil
+		}
+		path := ExpandTilde(u)
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, "", err
+		}
+		if mediaType == "" {
+			mediaType = InferMimeTypeFromPath(path)
+		}
+	}
+	if mediaType == "" {
+		mediaType = InferMimeTypeFromPath(d.FileName)
+	}
+	if mediaType == "" {
+		mediaType = "application/pdf"
+	}
+	return data, mediaType, nil
+}
@@ -872,7 +872,24 @@ func toAnthropicMessages(msgs []llm.Message) (system string, messages []map[stri
 							},
 						})
 					}
-				case llm.ContentAudio, llm.ContentDocument:
+				case llm.ContentDocument:
+					if p.Document == nil {
+						continue
+					}
+					b, mt, err := llm.DocumentBytes(p.Document)
+					if err != nil {
+						return "", nil, err
+					}
+					source := map[string]any{"type": "url", "url": strings.TrimSpace(p.Document.URL)}
+					if len(b) > 0 {
+						source = map[string]any{
+							"type":       "base64",
+							"media_type": mt,
+							"data":       base64.StdEncoding.EncodeToString(b),
+						}
+					}
+					blocks = append(blocks, map[string]any{"type": "document", "source": source})
+				case llm.ContentAudio:
 					return "", nil, &llm.ConfigurationError{Message: fmt.Sprintf("unsupported content kind for anthropic: %s", p.Kind)}
 				default:
 					// ignore
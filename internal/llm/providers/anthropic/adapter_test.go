@@ -1153,7 +1153,7 @@ func TestAdapter_Complete_ToolParameters_DefaultToEmptyObjectSchema(t *testing.T
 	}
 }
 
-func TestAdapter_Complete_RejectsAudioAndDocumentParts(t *testing.T) {
+func TestAdapter_Complete_RejectsAudioParts(t *testing.T) {
 	a := &Adapter{APIKey: "k", BaseURL: "http://example.com"}
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
@@ -1167,15 +1167,6 @@ func TestAdapter_Complete_RejectsAudioAndDocumentParts(t *testing.T) {
 	if !errors.As(err, &ce) {
 		t.Fatalf("expected ConfigurationError, got %T (%v)", err, err)
 	}
-
-	msgDoc := llm.Message{Role: llm.RoleUser, Content: []llm.ContentPart{{Kind: llm.ContentDocument, Document: &llm.DocumentData{URL: "https://example.com/a.pdf"}}}}
-	_, err = a.Complete(ctx, llm.Request{Model: "claude-test", Messages: []llm.Message{msgDoc}})
-	if err == nil {
-		t.Fatalf("expected error")
-	}
-	if !errors.As(err, &ce) {
-		t.Fatalf("expected ConfigurationError, got %T (%v)", err, err)
-	}
 }
 
 func TestAdapter_PromptCaching_AutoCacheDefaultAndDisable(t *testing.T) {
@@ -2065,3 +2056,48 @@ func writeAnthropicStreamOK(w http.ResponseWriter, text string) {
 	write("message_delta", `{"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)
 	write("message_stop", `{}`)
 }
+
+func TestAdapter_Complete_DocumentInput_DataAndFilePath(t *testing.T) {
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		_ = r.Body.Close()
+		_ = json.Unmarshal(b, &gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	pdfPath := filepath.Join(t.TempDir(), "spec.pdf")
+	_ = os.WriteFile(pdfPath, []byte("%PDF-1.4"), 0o644)
+
+	a := &Adapter{APIKey: "k", BaseURL: srv.URL, Client: srv.Client()}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg := llm.Message{Role: llm.RoleUser, Content: []llm.ContentPart{
+		{Kind: llm.ContentText, Text: "read"},
+		{Kind: llm.ContentDocument, Document: &llm.DocumentData{URL: pdfPath}},
+		{Kind: llm.ContentDocument, Document: &llm.DocumentData{Data: []byte("%PDF-1.7"), FileName: "b.pdf"}},
+	}}
+	if _, err := a.Complete(ctx, llm.Request{Model: "claude-test", Messages: []llm.Message{msg}}); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	msgs, _ := gotBody["messages"].([]any)
+	first, _ := msgs[0].(map[string]any)
+	content, _ := first["content"].([]any)
+	docs := 0
+	for _, bAny := range content {
+		bm, _ := bAny.(map[string]any)
+		if bm["type"] != "document" {
+			continue
+		}
+		src, _ := bm["source"].(map[string]any)
+		if src["type"] != "base64" || src["media_type"] != "application/pdf" || src["data"] == "" {
+			t.Fatalf("document source: %#v", src)
+		}
+		docs++
+	}
+	if docs != 2 {
+		t.Fatalf("expected 2 document blocks, got %d: %#v", docs, content)
+	}
+}
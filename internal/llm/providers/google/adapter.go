@@ -589,7 +589,30 @@ func toGeminiContents(msgs []llm.Message) (system string, contents []map[string]
 							},
 						})
 					}
-				case llm.ContentAudio, llm.ContentDocument:
+				case llm.ContentDocument:
+					if p.Document == nil {
+						continue
+					}
+					b, mt, err := llm.DocumentBytes(p.Document)
+					if err != nil {
+						return "", nil, err
+					}
+					if len(b) > 0 {
+						parts = append(parts, map[string]any{
+							"inlineData": map[string]any{
+								"mimeType": mt,
+								"data":     base64.StdEncoding.EncodeToString(b),
+							},
+						})
+					} else if u := strings.TrimSpace(p.Document.URL); u != "" {
+						parts = append(parts, map[string]any{
+							"fileData": map[string]any{
+								"mimeType": mt,
+								"fileUri":  u,
+							},
+						})
+					}
+				case llm.ContentAudio:
 					return "", nil, &llm.ConfigurationError{Message: fmt.Sprintf("unsupported content kind for google: %s", p.Kind)}
 				default:
 					// ignore
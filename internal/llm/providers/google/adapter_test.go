@@ -360,7 +360,7 @@ func TestAdapter_Complete_StripsAdditionalPropertiesFromSchemas(t *testing.T) {
 	}
 }
 
-func TestAdapter_Complete_RejectsAudioAndDocumentParts(t *testing.T) {
+func TestAdapter_Complete_RejectsAudioParts(t *testing.T) {
 	a := &Adapter{APIKey: "k", BaseURL: "http://example.com"}
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
@@ -374,15 +374,6 @@ func TestAdapter_Complete_RejectsAudioAndDocumentParts(t *testing.T) {
 	if !errors.As(err, &ce) {
 		t.Fatalf("expected ConfigurationError, got %T (%v)", err, err)
 	}
-
-	msgDoc := llm.Message{Role: llm.RoleUser, Content: []llm.ContentPart{{Kind: llm.ContentDocument, Document: &llm.DocumentData{URL: "https://example.com/a.pdf"}}}}
-	_, err = a.Complete(ctx, llm.Request{Model: "gemini-test", Messages: []llm.Message{msgDoc}})
-	if err == nil {
-		t.Fatalf("expected error")
-	}
-	if !errors.As(err, &ce) {
-		t.Fatalf("expected ConfigurationError, got %T (%v)", err, err)
-	}
 }
 
 func TestAdapter_Complete_HTTPErrorMapping_ServerErrorWithRetryAfter(t *testing.T) {
@@ -1029,3 +1020,39 @@ func TestAdapter_ProviderOptions_PassThrough(t *testing.T) {
 		t.Fatalf("x-test-opt: got %#v want %#v", got, want)
 	}
 }
+
+func TestAdapter_Complete_DocumentInput_InlineData(t *testing.T) {
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		_ = r.Body.Close()
+		_ = json.Unmarshal(b, &gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"ok"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":1,"candidatesTokenCount":1,"totalTokenCount":2}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	pdfPath := filepath.Join(t.TempDir(), "spec.pdf")
+	_ = os.WriteFile(pdfPath, []byte("%PDF-1.4"), 0o644)
+
+	a := &Adapter{APIKey: "k", BaseURL: srv.URL, Client: srv.Client()}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg := llm.Message{Role: llm.RoleUser, Content: []llm.ContentPart{
+		{Kind: llm.ContentText, Text: "read"},
+		{Kind: llm.ContentDocument, Document: &llm.DocumentData{URL: pdfPath}},
+	}}
+	if _, err := a.Complete(ctx, llm.Request{Model: "gemini-test", Messages: []llm.Message{msg}}); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	contents, _ := gotBody["contents"].([]any)
+	first, _ := contents[0].(map[string]any)
+	parts, _ := first["parts"].([]any)
+	if len(parts) != 2 {
+		t.Fatalf("parts: %#v", parts)
+	}
+	inline, _ := parts[1].(map[string]any)["inlineData"].(map[string]any)
+	if inline["mimeType"] != "application/pdf" || inline["data"] == "" {
+		t.Fatalf("inlineData: %#v", parts[1])
+	}
+}
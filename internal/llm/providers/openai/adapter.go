@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -645,7 +646,30 @@ func toResponsesInput(msgs []llm.Message) (instructions string, items []any, _ e
 							"image_url": url,
 						})
 					}
-				case llm.ContentAudio, llm.ContentDocument:
+				case llm.ContentDocument:
+					if p.Document == nil {
+						continue
+					}
+					b, mt, err := llm.DocumentBytes(p.Document)
+					if err != nil {
+						return "", nil, err
+					}
+					if m.Role != llm.RoleUser || len(b) == 0 {
+						return "", nil, &llm.ConfigurationError{Message: "openai: documents must be inline or local files in user messages"}
+					}
+					name := strings.TrimSpace(p.Document.FileName)
+					if name == "" && strings.TrimSpace(p.Document.URL) != "" {
+						name = filepath.Base(p.Document.URL)
+					}
+					if name == "" {
+						name = "document"
+					}
+					content = append(content, map[string]any{
+						"type":      "input_file",
+						"filename":  name,
+						"file_data": llm.DataURI(mt, b),
+					})
+				case llm.ContentAudio:
 					return "", nil, &llm.ConfigurationError{Message: fmt.Sprintf("unsupported content kind for openai: %s", p.Kind)}
 				default:
 					// ignore (tool calls are top-level items)
@@ -841,3 +841,40 @@ func TestAdapter_ProviderOptions_PassThrough(t *testing.T) {
 		t.Fatalf("parallel_tool_calls: %#v", gotBody["parallel_tool_calls"])
 	}
 }
+
+func TestAdapter_Complete_DocumentInput_InputFile(t *testing.T) {
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		_ = r.Body.Close()
+		_ = json.Unmarshal(b, &gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"resp_1","model":"gpt-5.4","output":[{"type":"message","content":[{"type":"output_text","text":"ok"}]}],"usage":{"input_tokens":1,"output_tokens":1,"total_tokens":2}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	pdfPath := filepath.Join(t.TempDir(), "spec.pdf")
+	_ = os.WriteFile(pdfPath, []byte("%PDF-1.4"), 0o644)
+
+	a := &Adapter{APIKey: "k", BaseURL: srv.URL, Client: srv.Client()}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg := llm.Message{Role: llm.RoleUser, Content: []llm.ContentPart{
+		{Kind: llm.ContentText, Text: "read"},
+		{Kind: llm.ContentDocument, Document: &llm.DocumentData{URL: pdfPath}},
+	}}
+	if _, err := a.Complete(ctx, llm.Request{Model: "gpt-5.4", Messages: []llm.Message{msg}}); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	input, _ := gotBody["input"].([]any)
+	item, _ := input[0].(map[string]any)
+	content, _ := item["content"].([]any)
+	if len(content) != 2 {
+		t.Fatalf("content: %#v", content)
+	}
+	file, _ := content[1].(map[string]any)
+	data, _ := file["file_data"].(string)
+	if file["type"] != "input_file" || file["filename"] != "spec.pdf" || !strings.HasPrefix(data, "data:application/pdf;base64,") {
+		t.Fatalf("input_file: %#v", file)
+	}
+}
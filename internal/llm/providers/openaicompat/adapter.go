@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -185,9 +187,13 @@ type chatCompletionsBodyOptions struct {
 
 func toChatCompletionsBody(req llm.Request, provider, optionsKey string, opts chatCompletionsBodyOptions) ([]byte, error) {
 	body := map[string]any{
-		"model":    modelmeta.ProviderRelativeModelID(provider, req.Model),
-		"messages": toChatCompletionsMessages(req.Messages),
+		"model": modelmeta.ProviderRelativeModelID(provider, req.Model),
 	}
+	msgs, err := toChatCompletionsMessages(provider, req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	body["messages"] = msgs
 	if len(req.Tools) > 0 {
 		body["tools"] = toChatCompletionsTools(req.Tools)
 	}
@@ -245,11 +251,15 @@ func parseChatCompletionsResponse(provider, model string, resp *http.Response) (
 	return out, nil
 }
 
-func toChatCompletionsMessages(msgs []llm.Message) []map[string]any {
+// toChatCompletionsMessages maps messages to chat.completions entries. A
+// message with images or documents gets an array of content parts; images
+// and documents are only accepted in user messages, and audio not at all.
+func toChatCompletionsMessages(provider string, msgs []llm.Message) ([]map[string]any, error) {
 	out := make([]map[string]any, 0, len(msgs))
 	for _, m := range msgs {
 		entry := map[string]any{"role": string(m.Role)}
 		textParts := []string{}
+		mediaParts := []map[string]any{}
 		toolCalls := []map[string]any{}
 		for _, p := range m.Content {
 			switch p.Kind {
@@ -257,6 +267,19 @@ func toChatCompletionsMessages(msgs []llm.Message) []map[string]any {
 				if strings.TrimSpace(p.Text) != "" {
 					textParts = append(textParts, p.Text)
 				}
+			case llm.ContentImage, llm.ContentDocument:
+				if m.Role != llm.RoleUser {
+					return nil, &llm.ConfigurationError{Message: fmt.Sprintf("%s: %s parts are only supported in user messages", provider, p.Kind)}
+				}
+				part, err := toChatCompletionsMediaPart(provider, p)
+				if err != nil {
+					return nil, err
+				}
+				if part != nil {
+					mediaParts = append(mediaParts, part)
+				}
+			case llm.ContentAudio:
+				return nil, &llm.ConfigurationError{Message: fmt.Sprintf("unsupported content kind for %s: %s", provider, p.Kind)}
 			case llm.ContentToolCall:
 				if p.ToolCall != nil {
 					toolCalls = append(toolCalls, map[string]any{
@@ -277,14 +300,90 @@ func toChatCompletionsMessages(msgs []llm.Message) []map[string]any {
 			}
 		}
 		if _, ok := entry["content"]; !ok {
-			entry["content"] = strings.Join(textParts, "\n")
+			if len(mediaParts) > 0 {
+				content := make([]map[string]any, 0, len(mediaParts)+1)
+				if len(textParts) > 0 {
+					content = append(content, map[string]any{"type": "text", "text": strings.Join(textParts, "\n")})
+				}
+				entry["content"] = append(content, mediaParts...)
+			} else {
+				entry["content"] = strings.Join(textParts, "\n")
+			}
 		}
 		if len(toolCalls) > 0 {
 			entry["tool_calls"] = toolCalls
 		}
 		out = append(out, entry)
 	}
-	return out
+	return out, nil
+}
+
+// toChatCompletionsMediaPart maps an image to an image_url part and a
+// document to a file part. Inline data and local files are sent as base64
+// data URLs; file parts cannot reference a remote URL.
+func toChatCompletionsMediaPart(provider string, p llm.ContentPart) (map[string]any, error) {
+	switch p.Kind {
+	case llm.ContentImage:
+		if p.Image == nil {
+			return nil, nil
+		}
+		url := strings.TrimSpace(p.Image.URL)
+		if len(p.Image.Data) > 0 {
+			mt := strings.TrimSpace(p.Image.MediaType)
+			if mt == "" {
+				mt = "image/png"
+			}
+			url = llm.DataURI(mt, p.Image.Data)
+		} else if llm.IsLocalPath(url) {
+			path := llm.ExpandTilde(url)
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			mt := strings.TrimSpace(p.Image.MediaType)
+			if mt == "" {
+				mt = llm.InferMimeTypeFromPath(path)
+			}
+			if mt == "" {
+				mt = "image/png"
+			}
+			url = llm.DataURI(mt, b)
+		}
+		if url == "" {
+			return nil, nil
+		}
+		image := map[string]any{"url": url}
+		if d := strings.TrimSpace(p.Image.Detail); d != "" {
+			image["detail"] = d
+		}
+		return map[string]any{"type": "image_url", "image_url": image}, nil
+	case llm.ContentDocument:
+		if p.Document == nil {
+			return nil, nil
+		}
+		b, mt, err := llm.DocumentBytes(p.Document)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 0 {
+			return nil, &llm.ConfigurationError{Message: fmt.Sprintf("%s: documents must be inline or local files", provider)}
+		}
+		name := strings.TrimSpace(p.Document.FileName)
+		if name == "" && strings.TrimSpace(p.Document.URL) != "" {
+			name = filepath.Base(p.Document.URL)
+		}
+		if name == "" {
+			name = "document"
+		}
+		return map[string]any{
+			"type": "file",
+			"file": map[string]any{
+				"filename":  name,
+				"file_data": llm.DataURI(mt, b),
+			},
+		}, nil
+	}
+	return nil, nil
 }
 
 func toChatCompletionsTools(tools []llm.ToolDefinition) []map[string]any {
@@ -3,6 +3,7 @@ package openaicompat
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -384,7 +385,10 @@ func TestToChatCompletionsMessages_SkipsThinkingParts(t *testing.T) {
 			{Kind: llm.ContentText, Text: "visible reply"},
 		},
 	}}
-	out := toChatCompletionsMessages(msgs)
+	out, err := toChatCompletionsMessages("kimi", msgs)
+	if err != nil {
+		t.Fatalf("toChatCompletionsMessages: %v", err)
+	}
 	if len(out) != 1 {
 		t.Fatalf("expected 1 message, got %d", len(out))
 	}
@@ -393,6 +397,53 @@ func TestToChatCompletionsMessages_SkipsThinkingParts(t *testing.T) {
 	}
 }
 
+func TestAdapter_Complete_SendsImageAndDocumentParts(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"id":"c1","model":"m","choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	a := NewAdapter(Config{Provider: "kimi", APIKey: "k", BaseURL: srv.URL, Path: "/v1/chat/completions", OptionsKey: "kimi"})
+	msg := llm.User("describe these")
+	msg.Content = append(msg.Content,
+		llm.ContentPart{Kind: llm.ContentImage, Image: &llm.ImageData{Data: []byte("png"), MediaType: "image/png", Detail: "high"}},
+		llm.ContentPart{Kind: llm.ContentDocument, Document: &llm.DocumentData{Data: []byte("%PDF"), FileName: "spec.pdf"}},
+	)
+	if _, err := a.Complete(context.Background(), llm.Request{Provider: "kimi", Model: "kimi-k2.5", Messages: []llm.Message{msg}}); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	msgs, _ := body["messages"].([]any)
+	if len(msgs) != 1 {
+		t.Fatalf("messages: %#v", body["messages"])
+	}
+	got, _ := json.Marshal(msgs[0].(map[string]any)["content"])
+	want := `[{"text":"describe these","type":"text"},` +
+		`{"image_url":{"detail":"high","url":"data:image/png;base64,cG5n"},"type":"image_url"},` +
+		`{"file":{"file_data":"data:application/pdf;base64,JVBERg==","filename":"spec.pdf"},"type":"file"}]`
+	if string(got) != want {
+		t.Fatalf("content:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestToChatCompletionsMessages_RejectsPartsTheProtocolCannotCarry(t *testing.T) {
+	cases := map[string]llm.Message{
+		"audio":           {Role: llm.RoleUser, Content: []llm.ContentPart{{Kind: llm.ContentAudio, Audio: &llm.AudioData{Data: []byte("x")}}}},
+		"assistant image": {Role: llm.RoleAssistant, Content: []llm.ContentPart{{Kind: llm.ContentImage, Image: &llm.ImageData{URL: "https://example.com/a.png"}}}},
+		"remote document": {Role: llm.RoleUser, Content: []llm.ContentPart{{Kind: llm.ContentDocument, Document: &llm.DocumentData{URL: "https://example.com/a.pdf"}}}},
+	}
+	for name, msg := range cases {
+		var cfgErr *llm.ConfigurationError
+		if _, err := toChatCompletionsMessages("kimi", []llm.Message{msg}); !errors.As(err, &cfgErr) {
+			t.Errorf("%s: expected ConfigurationError, got %v", name, err)
+		}
+	}
+}
+
 func TestAdapter_Stream_ReasoningDeltasDeepSeek(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/event-stream")
@@ -0,0 +1,117 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter sends encoded OTLP payloads somewhere.
+type Exporter interface {
+	ExportTraces(ctx context.Context, payload []byte) error
+	ExportMetrics(ctx context.Context, payload []byte) error
+	Close() error
+}
+
+// FileExporter appends one OTLP/JSON request per line, the format read by
+// the collector's otlpjsonfile receiver.
+type FileExporter struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileExporter opens (creating if needed) path for appending.
+func NewFileExporter(path string) (*FileExporter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{f: f}, nil
+}
+
+func (e *FileExporter) ExportTraces(_ context.Context, payload []byte) error {
+	return e.writeLine(payload)
+}
+
+func (e *FileExporter) ExportMetrics(_ context.Context, payload []byte) error {
+	return e.writeLine(payload)
+}
+
+func (e *FileExporter) writeLine(payload []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.f == nil {
+		return fmt.Errorf("otlp file exporter is closed")
+	}
+	_, err := e.f.Write(append(append([]byte{}, payload...), '\n'))
+	return err
+}
+
+func (e *FileExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.f == nil {
+		return nil
+	}
+	err := e.f.Close()
+	e.f = nil
+	return err
+}
+
+// HTTPExporter posts OTLP/JSON to a collector's /v1/traces and /v1/metrics.
+type HTTPExporter struct {
+	Endpoint string
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+// NewHTTPExporter targets an OTLP/HTTP base URL such as http://localhost:4318.
+func NewHTTPExporter(endpoint string, headers map[string]string) *HTTPExporter {
+	return &HTTPExporter{
+		Endpoint: strings.TrimRight(strings.TrimSpace(endpoint), "/"),
+		Headers:  headers,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (e *HTTPExporter) ExportTraces(ctx context.Context, payload []byte) error {
+	return e.post(ctx, "/v1/traces", payload)
+}
+
+func (e *HTTPExporter) ExportMetrics(ctx context.Context, payload []byte) error {
+	return e.post(ctx, "/v1/metrics", payload)
+}
+
+func (e *HTTPExporter) post(ctx context.Context, path string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp export %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (e *HTTPExporter) Close() error { return nil }
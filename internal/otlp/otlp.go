@@ -0,0 +1,210 @@
+// Package otlp encodes spans and metrics as OTLP/JSON and exports them to a
+// JSON-lines file or an OTLP/HTTP collector. It implements only the subset of
+// the protocol Kilroy emits, so runs can be traced without the OpenTelemetry SDK.
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TraceID and SpanID are raw OTLP identifiers.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsZero reports whether the span ID is unset (a root span's parent).
+func (id SpanID) IsZero() bool { return id == SpanID{} }
+
+// Span kinds (opentelemetry.proto.trace.v1.Span.SpanKind).
+const (
+	SpanKindInternal = 1
+	SpanKindClient   = 3
+)
+
+// Span is one finished span.
+type Span struct {
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID
+	Name     string
+	Kind     int
+	Start    time.Time
+	End      time.Time
+	Attrs    map[string]any
+	Events   []Event
+	// Error marks the span status as ERROR with ErrorMessage.
+	Error        bool
+	ErrorMessage string
+}
+
+// Event is a timestamped annotation on a span.
+type Event struct {
+	Name  string
+	Time  time.Time
+	Attrs map[string]any
+}
+
+// Metric is a cumulative, monotonic integer sum.
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+	Points      []Point
+}
+
+// Point is one attribute set's value of a Metric.
+type Point struct {
+	Attrs map[string]any
+	Start time.Time
+	Time  time.Time
+	Value int64
+}
+
+// Scope is the instrumentation scope reported with every export.
+const Scope = "github.com/danshapiro/kilroy/attractor"
+
+// EncodeTraces renders an ExportTraceServiceRequest.
+func EncodeTraces(resource map[string]any, spans []Span) ([]byte, error) {
+	out := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		js := map[string]any{
+			"traceId":           s.TraceID.String(),
+			"spanId":            s.SpanID.String(),
+			"name":              s.Name,
+			"kind":              s.Kind,
+			"startTimeUnixNano": unixNano(s.Start),
+			"endTimeUnixNano":   unixNano(s.End),
+			"attributes":        encodeAttrs(s.Attrs),
+		}
+		if !s.ParentID.IsZero() {
+			js["parentSpanId"] = s.ParentID.String()
+		}
+		if s.Kind == 0 {
+			js["kind"] = SpanKindInternal
+		}
+		if len(s.Events) > 0 {
+			evs := make([]map[string]any, 0, len(s.Events))
+			for _, ev := range s.Events {
+				evs = append(evs, map[string]any{
+					"name":         ev.Name,
+					"timeUnixNano": unixNano(ev.Time),
+					"attributes":   encodeAttrs(ev.Attrs),
+				})
+			}
+			js["events"] = evs
+		}
+		if s.Error {
+			js["status"] = map[string]any{"code": 2, "message": s.ErrorMessage}
+		} else {
+			js["status"] = map[string]any{"code": 1}
+		}
+		out = append(out, js)
+	}
+	return json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": encodeAttrs(resource)},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": Scope},
+				"spans": out,
+			}},
+		}},
+	})
+}
+
+// EncodeMetrics renders an ExportMetricsServiceRequest.
+func EncodeMetrics(resource map[string]any, metrics []Metric) ([]byte, error) {
+	out := make([]map[string]any, 0, len(metrics))
+	for _, m := range metrics {
+		points := make([]map[string]any, 0, len(m.Points))
+		for _, p := range m.Points {
+			points = append(points, map[string]any{
+				"attributes":        encodeAttrs(p.Attrs),
+				"startTimeUnixNano": unixNano(p.Start),
+				"timeUnixNano":      unixNano(p.Time),
+				"asInt":             strconv.FormatInt(p.Value, 10),
+			})
+		}
+		out = append(out, map[string]any{
+			"name":        m.Name,
+			"description": m.Description,
+			"unit":        m.Unit,
+			"sum": map[string]any{
+				"dataPoints":             points,
+				"aggregationTemporality": 2, // cumulative
+				"isMonotonic":            true,
+			},
+		})
+	}
+	return json.Marshal(map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource": map[string]any{"attributes": encodeAttrs(resource)},
+			"scopeMetrics": []any{map[string]any{
+				"scope":   map[string]any{"name": Scope},
+				"metrics": out,
+			}},
+		}},
+	})
+}
+
+func unixNano(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// encodeAttrs renders attributes as OTLP KeyValues in key order. Empty
+// strings and nil values are dropped.
+func encodeAttrs(attrs map[string]any) []map[string]any {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]map[string]any, 0, len(keys))
+	for _, k := range keys {
+		v, ok := encodeValue(attrs[k])
+		if !ok {
+			continue
+		}
+		out = append(out, map[string]any{"key": k, "value": v})
+	}
+	return out
+}
+
+func encodeValue(v any) (map[string]any, bool) {
+	switch t := v.(type) {
+	case nil:
+		return nil, false
+	case string:
+		if t == "" {
+			return nil, false
+		}
+		return map[string]any{"stringValue": t}, true
+	case bool:
+		return map[string]any{"boolValue": t}, true
+	case int:
+		return map[string]any{"intValue": strconv.FormatInt(int64(t), 10)}, true
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(t, 10)}, true
+	case float64:
+		return map[string]any{"doubleValue": t}, true
+	case []string:
+		vals := make([]map[string]any, 0, len(t))
+		for _, s := range t {
+			vals = append(vals, map[string]any{"stringValue": s})
+		}
+		return map[string]any{"arrayValue": map[string]any{"values": vals}}, true
+	default:
+		return map[string]any{"stringValue": fmt.Sprint(t)}, true
+	}
+}
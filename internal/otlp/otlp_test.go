@@ -0,0 +1,147 @@
+package otlp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeTraces_OTLPJSONShape(t *testing.T) {
+	start := time.Unix(1700000000, 5)
+	b, err := EncodeTraces(map[string]any{"service.name": "kilroy"}, []Span{{
+		TraceID:  TraceID{1},
+		SpanID:   SpanID{2},
+		ParentID: SpanID{3},
+		Name:     "node impl",
+		Start:    start,
+		End:      start.Add(time.Second),
+		Attrs: map[string]any{
+			"gen_ai.usage.input_tokens": 42,
+			"kilroy.cached":             true,
+			"kilroy.empty":              "",
+		},
+		Error:        true,
+		ErrorMessage: "boom",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []map[string]any `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []map[string]any `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	sp := doc.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if sp["traceId"] != "01000000000000000000000000000000" || sp["parentSpanId"] != "0300000000000000" {
+		t.Fatalf("ids = %v / %v", sp["traceId"], sp["parentSpanId"])
+	}
+	if sp["startTimeUnixNano"] != "1700000000000000005" {
+		t.Fatalf("startTimeUnixNano = %v", sp["startTimeUnixNano"])
+	}
+	if kind, _ := sp["kind"].(float64); kind != SpanKindInternal {
+		t.Fatalf("kind = %v", sp["kind"])
+	}
+	if st := sp["status"].(map[string]any); st["code"].(float64) != 2 || st["message"] != "boom" {
+		t.Fatalf("status = %v", st)
+	}
+	attrs := sp["attributes"].([]any)
+	if len(attrs) != 2 {
+		t.Fatalf("empty string attribute was not dropped: %v", attrs)
+	}
+	first := attrs[0].(map[string]any)
+	if first["key"] != "gen_ai.usage.input_tokens" || first["value"].(map[string]any)["intValue"] != "42" {
+		t.Fatalf("int attribute = %v", first)
+	}
+}
+
+func TestEncodeMetrics_CumulativeSum(t *testing.T) {
+	b, err := EncodeMetrics(nil, []Metric{{
+		Name:   "kilroy.tool.calls",
+		Unit:   "{call}",
+		Points: []Point{{Attrs: map[string]any{"gen_ai.tool.name": "shell"}, Value: 3}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	for _, want := range []string{`"resourceMetrics"`, `"aggregationTemporality":2`, `"isMonotonic":true`, `"asInt":"3"`} {
+		if !strings.Contains(s, want) {
+			t.Errorf("metrics payload missing %s: %s", want, s)
+		}
+	}
+}
+
+func TestFileExporter_WritesOneRequestPerLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "otlp.jsonl")
+	exp, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := exp.ExportTraces(ctx, []byte(`{"resourceSpans":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.ExportMetrics(ctx, []byte(`{"resourceMetrics":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.ExportTraces(ctx, []byte(`{}`)); err == nil {
+		t.Fatal("expected error after Close")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], `{"resourceMetrics"`) {
+		t.Fatalf("lines = %q", lines)
+	}
+}
+
+func TestHTTPExporter_PostsToSignalPaths(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, r.URL.Path+" "+r.Header.Get("Content-Type")+" "+r.Header.Get("X-Token")+" "+string(body))
+		if r.URL.Path == "/v1/metrics" {
+			http.Error(w, "nope", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	exp := NewHTTPExporter(srv.URL+"/", map[string]string{"X-Token": "t"})
+	ctx := context.Background()
+	if err := exp.ExportTraces(ctx, []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	err := exp.ExportMetrics(ctx, []byte(`{"b":2}`))
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("metrics err = %v, want 400", err)
+	}
+	if len(got) != 2 || got[0] != `/v1/traces application/json t {"a":1}` {
+		t.Fatalf("requests = %q", got)
+	}
+}
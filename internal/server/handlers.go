@@ -211,16 +211,19 @@ func (s *Server) handleSubmitPipeline(w http.ResponseWriter, r *http.Request) {
 			RunID:         runID,
 			AllowTestShim: req.AllowTestShim,
 			ForceModels:   req.ForceModels,
-			ProgressSink:  broadcaster.Send,
-			Interviewer:   interviewer,
-			Inputs:        req.Inputs,
-			Workspace:     workspace,
-			GraphDir:      graphDir,
-			Labels:        labels,
-			GitOps:        gitOps,
-			PackageDir:    packageDir,
-			RunDB:         rdb,
-			Registry:      newLayeredRegistry(req.Tmux),
+			ProgressSink: func(ev map[string]any) {
+				s.metrics.observe(ev)
+				broadcaster.Send(ev)
+			},
+			Interviewer: interviewer,
+			Inputs:      req.Inputs,
+			Workspace:   workspace,
+			GraphDir:    graphDir,
+			Labels:      labels,
+			GitOps:      gitOps,
+			PackageDir:  packageDir,
+			RunDB:       rdb,
+			Registry:    newLayeredRegistry(req.Tmux),
 			OnEngineReady: func(e *engine.Engine) {
 				ps.SetEngine(e)
 			},
@@ -228,6 +231,7 @@ func (s *Server) handleSubmitPipeline(w http.ResponseWriter, r *http.Request) {
 
 		res, err := engine.RunWithConfig(ctx, dotSource, cfg, overrides)
 		ps.SetResult(res, err)
+		s.metrics.forgetRun(runID)
 	}()
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,359 @@
+// Prometheus metrics for the HTTP server (GET /metrics).
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+	"github.com/danshapiro/kilroy/internal/attractor/workflows"
+)
+
+// llmLatencyBuckets are the upper bounds (seconds) of the LLM request
+// latency histogram. Agent turns range from sub-second to many minutes.
+var llmLatencyBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+// serverMetrics accumulates counters from the progress events of runs
+// started by this server. Gauges (runs by state, pending questions, SSE
+// subscribers, run health) are computed at scrape time.
+type serverMetrics struct {
+	mu             sync.Mutex
+	nodeExecutions map[[2]string]float64 // handler, outcome
+	retries        float64
+	loopRestarts   float64
+	llmLatency     map[[2]string]*histogram // provider, model
+	tokens         map[[3]string]float64    // provider, model, type
+	runs           map[string]map[string]*metricsNode
+}
+
+// metricsNode is the per-node state needed to attribute LLM requests.
+type metricsNode struct {
+	provider string
+	model    string
+	mark     time.Time
+	cliStart time.Time
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, non-cumulative
+	count  uint64
+	sum    float64
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		nodeExecutions: map[[2]string]float64{},
+		llmLatency:     map[[2]string]*histogram{},
+		tokens:         map[[3]string]float64{},
+		runs:           map[string]map[string]*metricsNode{},
+	}
+}
+
+// observe updates counters from one engine progress event.
+func (m *serverMetrics) observe(ev map[string]any) {
+	event, _ := ev["event"].(string)
+	runID, _ := ev["run_id"].(string)
+	nodeID, _ := ev["node_id"].(string)
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch event {
+	case "stage_attempt_start":
+		if metricsInt(ev["attempt"]) > 1 {
+			m.retries++
+		}
+		m.node(runID, nodeID).mark = now
+	case "stage_attempt_end":
+		handler, _ := ev["handler"].(string)
+		status, _ := ev["status"].(string)
+		m.nodeExecutions[[2]string{handler, status}]++
+	case "loop_restart":
+		m.loopRestarts++
+	case "provider_selected":
+		n := m.node(runID, nodeID)
+		n.provider, _ = ev["provider"].(string)
+		n.model, _ = ev["model"].(string)
+	case "llm_tool_call_end":
+		m.node(runID, nodeID).mark = now
+	case "llm_turn_end":
+		n := m.node(runID, nodeID)
+		model, _ := ev["model"].(string)
+		if model == "" {
+			model = n.model
+		}
+		if !n.mark.IsZero() {
+			m.observeLatency(n.provider, model, now.Sub(n.mark))
+		}
+		n.mark = now
+		if v := metricsInt(ev["input_tokens"]); v > 0 {
+			m.tokens[[3]string{n.provider, model, "input"}] += float64(v)
+		}
+		if v := metricsInt(ev["output_tokens"]); v > 0 {
+			m.tokens[[3]string{n.provider, model, "output"}] += float64(v)
+		}
+	case "cli_invocation_start", "tmux_session_start":
+		m.node(runID, nodeID).cliStart = now
+	case "cli_invocation_end", "tmux_session_complete":
+		n := m.node(runID, nodeID)
+		if !n.cliStart.IsZero() {
+			m.observeLatency(n.provider, n.model, now.Sub(n.cliStart))
+			n.cliStart = time.Time{}
+		}
+	case "run_completed", "run_failed": // a canceled run ends with run_failed
+		delete(m.runs, runID)
+	}
+}
+
+// forgetRun releases a run's per-node state. Runs that stop before the
+// engine emits a terminal event would otherwise keep it forever.
+func (m *serverMetrics) forgetRun(runID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.runs, runID)
+}
+
+// pruneRuns releases per-node state for every run not in live.
+func (m *serverMetrics) pruneRuns(live map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for runID := range m.runs {
+		if !live[runID] {
+			delete(m.runs, runID)
+		}
+	}
+}
+
+func (m *serverMetrics) node(runID, nodeID string) *metricsNode {
+	nodes := m.runs[runID]
+	if nodes == nil {
+		nodes = map[string]*metricsNode{}
+		m.runs[runID] = nodes
+	}
+	n := nodes[nodeID]
+	if n == nil {
+		n = &metricsNode{}
+		nodes[nodeID] = n
+	}
+	return n
+}
+
+func (m *serverMetrics) observeLatency(provider, model string, d time.Duration) {
+	key := [2]string{provider, model}
+	h := m.llmLatency[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(llmLatencyBuckets))}
+		m.llmLatency[key] = h
+	}
+	secs := d.Seconds()
+	for i, le := range llmLatencyBuckets {
+		if secs <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += secs
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	live := map[string]bool{}
+	for _, ps := range s.registry.Snapshot() {
+		if !ps.isDone() {
+			live[ps.RunID] = true
+		}
+	}
+	s.metrics.pruneRuns(live)
+
+	var b bytes.Buffer
+	s.writeRunGauges(&b)
+	s.metrics.write(&b)
+	s.writeRunHealth(&b)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(b.Bytes())
+}
+
+// writeRunGauges reports the live state of runs managed by this server.
+func (s *Server) writeRunGauges(b *bytes.Buffer) {
+	states := map[string]float64{"running": 0, "success": 0, "fail": 0, "canceled": 0}
+	var pending, subscribers float64
+	for _, ps := range s.registry.Snapshot() {
+		st := ps.Status()
+		states[st.State]++
+		if !ps.isDone() && ps.Interviewer != nil {
+			pending += float64(len(ps.Interviewer.Pending()))
+		}
+		if ps.Broadcaster != nil {
+			subscribers += float64(ps.Broadcaster.SubscriberCount())
+		}
+	}
+	writeHeader(b, "kilroy_runs", "gauge", "Runs managed by this server, by state.")
+	for _, state := range sortedKeys(states) {
+		writeSample(b, "kilroy_runs", states[state], "state", state)
+	}
+	writeHeader(b, "kilroy_pending_questions", "gauge", "Human-gate questions waiting for an answer.")
+	writeSample(b, "kilroy_pending_questions", pending)
+	writeHeader(b, "kilroy_sse_subscribers", "gauge", "Connected SSE event-stream clients.")
+	writeSample(b, "kilroy_sse_subscribers", subscribers)
+}
+
+func (m *serverMetrics) write(b *bytes.Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeHeader(b, "kilroy_node_executions_total", "counter", "Node attempts by handler type and outcome.")
+	keys2 := make([][2]string, 0, len(m.nodeExecutions))
+	for k := range m.nodeExecutions {
+		keys2 = append(keys2, k)
+	}
+	sortKeys2(keys2)
+	for _, k := range keys2 {
+		writeSample(b, "kilroy_node_executions_total", m.nodeExecutions[k], "handler", k[0], "outcome", k[1])
+	}
+
+	writeHeader(b, "kilroy_node_retries_total", "counter", "Node attempts after the first.")
+	writeSample(b, "kilroy_node_retries_total", m.retries)
+	writeHeader(b, "kilroy_loop_restarts_total", "counter", "loop_restart edges taken.")
+	writeSample(b, "kilroy_loop_restarts_total", m.loopRestarts)
+
+	writeHeader(b, "kilroy_llm_request_duration_seconds", "histogram", "LLM request latency (API turns and CLI invocations).")
+	keys2 = keys2[:0]
+	for k := range m.llmLatency {
+		keys2 = append(keys2, k)
+	}
+	sortKeys2(keys2)
+	for _, k := range keys2 {
+		h := m.llmLatency[k]
+		var cum uint64
+		for i, le := range llmLatencyBuckets {
+			cum += h.counts[i]
+			writeSample(b, "kilroy_llm_request_duration_seconds_bucket", float64(cum), "provider", k[0], "model", k[1], "le", strconv.FormatFloat(le, 'g', -1, 64))
+		}
+		writeSample(b, "kilroy_llm_request_duration_seconds_bucket", float64(h.count), "provider", k[0], "model", k[1], "le", "+Inf")
+		writeSample(b, "kilroy_llm_request_duration_seconds_sum", h.sum, "provider", k[0], "model", k[1])
+		writeSample(b, "kilroy_llm_request_duration_seconds_count", float64(h.count), "provider", k[0], "model", k[1])
+	}
+
+	writeHeader(b, "kilroy_llm_tokens_total", "counter", "LLM tokens reported by providers.")
+	keys3 := make([][3]string, 0, len(m.tokens))
+	for k := range m.tokens {
+		keys3 = append(keys3, k)
+	}
+	sort.Slice(keys3, func(i, j int) bool {
+		return strings.Join(keys3[i][:], "\x00") < strings.Join(keys3[j][:], "\x00")
+	})
+	for _, k := range keys3 {
+		writeSample(b, "kilroy_llm_tokens_total", m.tokens[k], "provider", k[0], "model", k[1], "type", k[2])
+	}
+}
+
+// metricsRunDB returns the server's run database handle, opening it on
+// first use. A failed open is retried on the next scrape.
+func (s *Server) metricsRunDB() (*rundb.DB, error) {
+	s.runDBMu.Lock()
+	defer s.runDBMu.Unlock()
+	if s.runDB == nil {
+		db, err := rundb.Open(rundb.DefaultPath())
+		if err != nil {
+			return nil, err
+		}
+		s.runDB = db
+	}
+	return s.runDB, nil
+}
+
+// writeRunHealth classifies running runs from the run database the same way
+// `kilroy attractor status` does (workflows.AssessRun), so stuck
+// runs can be alerted on. Runs from any process sharing the database count.
+// kilroy_rundb_up reports whether the database could be read.
+func (s *Server) writeRunHealth(b *bytes.Buffer) {
+	writeHeader(b, "kilroy_rundb_up", "gauge", "Whether the run database could be read (1) or not (0).")
+	db, err := s.metricsRunDB()
+	var assessments []workflows.RunAssessment
+	if err == nil {
+		assessments, err = workflows.AssessActiveRuns(db)
+	}
+	if err != nil {
+		s.logger.Printf("metrics: run database: %v", err)
+		writeSample(b, "kilroy_rundb_up", 0)
+		return
+	}
+	writeSample(b, "kilroy_rundb_up", 1)
+	byHealth := map[string]float64{}
+	for _, h := range []workflows.RunHealth{workflows.HealthHealthy, workflows.HealthDegraded, workflows.HealthBlocked} {
+		byHealth[string(h)] = 0
+	}
+	for _, a := range assessments {
+		byHealth[string(a.Health)]++
+	}
+	writeHeader(b, "kilroy_active_runs", "gauge", "Running runs by supervisor health (healthy, degraded, blocked).")
+	for _, h := range sortedKeys(byHealth) {
+		writeSample(b, "kilroy_active_runs", byHealth[h], "health", h)
+	}
+	writeHeader(b, "kilroy_active_run_health", "gauge", "One series per running run labelled with its supervisor health.")
+	for _, a := range assessments {
+		writeSample(b, "kilroy_active_run_health", 1, "run_id", a.RunID, "graph", a.GraphName, "health", string(a.Health))
+	}
+}
+
+func writeHeader(b *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeSample writes one sample line; labels are name/value pairs.
+func writeSample(b *bytes.Buffer, name string, v float64, labels ...string) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	b.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortKeys2(keys [][2]string) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+}
+
+func metricsInt(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/engine"
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+)
+
+func scrapeMetrics(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type %q", ct)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	return string(b)
+}
+
+func TestMetrics_CountsProgressEvents(t *testing.T) {
+	m := newServerMetrics()
+	for _, ev := range []map[string]any{
+		{"event": "stage_attempt_start", "run_id": "r1", "node_id": "impl", "attempt": 1},
+		{"event": "provider_selected", "run_id": "r1", "node_id": "impl", "provider": "openai", "model": "gpt-5"},
+		{"event": "llm_turn_end", "run_id": "r1", "node_id": "impl", "input_tokens": 100, "output_tokens": 7},
+		{"event": "stage_attempt_end", "run_id": "r1", "node_id": "impl", "handler": "codergen", "status": "fail"},
+		{"event": "stage_attempt_start", "run_id": "r1", "node_id": "impl", "attempt": 2},
+		{"event": "cli_invocation_start", "run_id": "r1", "node_id": "impl"},
+		{"event": "cli_invocation_end", "run_id": "r1", "node_id": "impl", "exit_code": 0},
+		{"event": "stage_attempt_end", "run_id": "r1", "node_id": "impl", "handler": "codergen", "status": "success"},
+		{"event": "loop_restart", "run_id": "r1"},
+		{"event": "run_completed", "run_id": "r1"},
+	} {
+		m.observe(ev)
+	}
+	var buf bytes.Buffer
+	m.write(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		`kilroy_node_executions_total{handler="codergen",outcome="fail"} 1`,
+		`kilroy_node_executions_total{handler="codergen",outcome="success"} 1`,
+		`kilroy_node_retries_total 1`,
+		`kilroy_loop_restarts_total 1`,
+		`kilroy_llm_request_duration_seconds_count{provider="openai",model="gpt-5"} 2`,
+		`kilroy_llm_request_duration_seconds_bucket{provider="openai",model="gpt-5",le="+Inf"} 2`,
+		`kilroy_llm_tokens_total{provider="openai",model="gpt-5",type="input"} 100`,
+		`kilroy_llm_tokens_total{provider="openai",model="gpt-5",type="output"} 7`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+	if len(m.runs) != 0 {
+		t.Fatalf("per-run state not released on run end: %v", m.runs)
+	}
+}
+
+func TestMetrics_PruneRunsDropsFinishedRuns(t *testing.T) {
+	m := newServerMetrics()
+	m.observe(map[string]any{"event": "stage_attempt_start", "run_id": "live", "node_id": "a", "attempt": 1})
+	m.observe(map[string]any{"event": "stage_attempt_start", "run_id": "stopped", "node_id": "a", "attempt": 1})
+	m.pruneRuns(map[string]bool{"live": true})
+	if len(m.runs) != 1 || m.runs["live"] == nil {
+		t.Fatalf("runs = %v", m.runs)
+	}
+	m.forgetRun("live")
+	if len(m.runs) != 0 {
+		t.Fatalf("runs = %v", m.runs)
+	}
+}
+
+func TestIntegration_MetricsEndpoint(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	db, err := rundb.Open(rundb.DefaultPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordRunStart("stuck-run", "g", "", "running", "", "", "", "", "", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		id, err := db.RecordNodeStart("stuck-run", "impl", attempt, "codergen")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.RecordNodeComplete(id, "fail", "boom", "deterministic", "", "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	srv, ts := newTestServer(t)
+	_, b, wi := registerTestPipeline(t, srv, "live-run")
+	go wi.Ask(engine.Question{Type: engine.QuestionSingleSelect, Text: "Approve?"})
+	_, _, unsub := b.Subscribe()
+	defer unsub()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(wi.Pending()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	out := scrapeMetrics(t, ts.URL)
+	for _, want := range []string{
+		`# TYPE kilroy_runs gauge`,
+		`kilroy_runs{state="running"} 1`,
+		`kilroy_pending_questions 1`,
+		`kilroy_sse_subscribers 1`,
+		`# TYPE kilroy_llm_request_duration_seconds histogram`,
+		`kilroy_rundb_up 1`,
+		`kilroy_active_runs{health="blocked"} 1`,
+		`kilroy_active_run_health{run_id="stuck-run",graph="g",health="blocked"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+}
+
+func TestIntegration_MetricsEndpoint_RunDBDown(t *testing.T) {
+	// A file where the state directory should be makes the open fail.
+	state := filepath.Join(t.TempDir(), "state")
+	if err := os.WriteFile(state, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_STATE_HOME", state)
+	_, ts := newTestServer(t)
+
+	out := scrapeMetrics(t, ts.URL)
+	if !strings.Contains(out, "kilroy_rundb_up 0\n") || strings.Contains(out, "kilroy_active_runs") {
+		t.Fatalf("expected kilroy_rundb_up 0 and no run health series in:\n%s", out)
+	}
+}
@@ -94,6 +94,12 @@ func (ps *PipelineState) Status() PipelineStatus {
 	return status
 }
 
+func (ps *PipelineState) isDone() bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.done
+}
+
 // ContextValues returns the current engine context values, or nil if unavailable.
 func (ps *PipelineState) ContextValues() map[string]any {
 	ps.mu.Lock()
@@ -164,6 +170,17 @@ func (r *PipelineRegistry) List() []string {
 	return ids
 }
 
+// Snapshot returns all registered pipelines.
+func (r *PipelineRegistry) Snapshot() []*PipelineState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*PipelineState, 0, len(r.pipelines))
+	for _, ps := range r.pipelines {
+		out = append(out, ps)
+	}
+	return out
+}
+
 // CancelAll cancels all running pipelines with the given reason.
 func (r *PipelineRegistry) CancelAll(reason string) {
 	r.mu.RLock()
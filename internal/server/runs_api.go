@@ -8,10 +8,12 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/danshapiro/kilroy/internal/attractor/gitutil"
 	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+	"github.com/danshapiro/kilroy/internal/attractor/runstate"
 )
 
 func (s *Server) handleListRuns(w http.ResponseWriter, r *http.Request) {
@@ -270,6 +272,30 @@ func (s *Server) handleGetNodeAttempts(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleGetRunTimeline returns node attempts laid out on the run's wall clock
+// with lanes, idle gaps, human-wait time and the critical path.
+func (s *Server) handleGetRunTimeline(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	db, err := rundb.Open(rundb.DefaultPath())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "database unavailable: "+err.Error())
+		return
+	}
+	defer db.Close()
+
+	run, err := db.GetRun(id)
+	if err != nil || run == nil {
+		writeError(w, http.StatusNotFound, "run not found: "+id)
+		return
+	}
+	execs, err := db.GetNodeExecutions(run.RunID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query executions: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, runstate.BuildTimeline(run, execs, db.GetDotSource(run.RunID), time.Now().UTC()))
+}
+
 // assignArtifactsToTurnsResult maps captured DB artifacts into the response
 // shape the UI expects (prompt, response, agent_log, stdout, stderr, status).
 func assignArtifactsToTurnsResult(result map[string]any, artifacts []rundb.NodeArtifactSummary) {
@@ -305,6 +331,11 @@ func assignArtifactsToTurnsResult(result map[string]any, artifacts []rundb.NodeA
 			if json.Unmarshal(a.Content, &inv) == nil {
 				result["tool_invocation"] = inv
 			}
+		case a.Name == "plan.json":
+			var plan []map[string]any
+			if json.Unmarshal(a.Content, &plan) == nil {
+				result["plan"] = plan
+			}
 		case strings.HasPrefix(a.Name, "tool_script:"):
 			scripts = append(scripts, map[string]any{
 				"name":         strings.TrimPrefix(a.Name, "tool_script:"),
@@ -358,6 +389,12 @@ func readFilesystemTurns(result map[string]any, stageDir string) {
 			result["timing"] = timing
 		}
 	}
+	if data, err := os.ReadFile(filepath.Join(stageDir, "plan.json")); err == nil {
+		var plan []map[string]any
+		if json.Unmarshal(data, &plan) == nil {
+			result["plan"] = plan
+		}
+	}
 	result["source"] = "filesystem"
 }
 
@@ -421,6 +458,25 @@ func (s *Server) handleGetNodeDiff(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleListWorkflows(w http.ResponseWriter, r *http.Request) {
+	workflows := listWorkflowPackages()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"workflows": workflows,
+		"count":     len(workflows),
+	})
+}
+
+type workflowInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Version     string   `json:"version"`
+	Dir         string   `json:"dir"`
+	Inputs      []any    `json:"inputs,omitempty"`
+	Outputs     []string `json:"outputs,omitempty"`
+}
+
+// listWorkflowPackages scans the known workflow package directories for
+// subdirectories containing a graph.dot.
+func listWorkflowPackages() []workflowInfo {
 	// Scan known workflow package directories.
 	searchDirs := []string{"workflows"}
 
@@ -432,16 +488,7 @@ func (s *Server) handleListWorkflows(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	type workflowInfo struct {
-		Name        string   `json:"name"`
-		Description string   `json:"description"`
-		Version     string   `json:"version"`
-		Dir         string   `json:"dir"`
-		Inputs      []any    `json:"inputs,omitempty"`
-		Outputs     []string `json:"outputs,omitempty"`
-	}
-
-	var workflows []workflowInfo
+	workflows := []workflowInfo{}
 	seen := map[string]bool{}
 
 	for _, dir := range searchDirs {
@@ -466,10 +513,10 @@ func (s *Server) handleListWorkflows(w http.ResponseWriter, r *http.Request) {
 			tomlPath := filepath.Join(pkgDir, "workflow.toml")
 			if data, err := os.ReadFile(tomlPath); err == nil {
 				var manifest struct {
-					Name        string `toml:"name"`
-					Description string `toml:"description"`
-					Version     string `toml:"version"`
-					Inputs      []any  `toml:"inputs"`
+					Name        string   `toml:"name"`
+					Description string   `toml:"description"`
+					Version     string   `toml:"version"`
+					Inputs      []any    `toml:"inputs"`
 					Outputs     []string `toml:"outputs"`
 				}
 				if err := toml.Unmarshal(data, &manifest); err == nil {
@@ -485,14 +532,17 @@ func (s *Server) handleListWorkflows(w http.ResponseWriter, r *http.Request) {
 			workflows = append(workflows, wf)
 		}
 	}
+	return workflows
+}
 
-	if workflows == nil {
-		workflows = []workflowInfo{}
+// findWorkflowPackage resolves a workflow by its listed name or directory name.
+func findWorkflowPackage(name string) (workflowInfo, bool) {
+	for _, wf := range listWorkflowPackages() {
+		if wf.Name == name || filepath.Base(wf.Dir) == name {
+			return wf, true
+		}
 	}
-	writeJSON(w, http.StatusOK, map[string]any{
-		"workflows": workflows,
-		"count":     len(workflows),
-	})
+	return workflowInfo{}, false
 }
 
 // gitDiff returns the full unified diff between two commits.
@@ -8,9 +8,12 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/danshapiro/kilroy/internal/attractor/agents"
+	"github.com/danshapiro/kilroy/internal/attractor/agents/tmux"
 	"github.com/danshapiro/kilroy/internal/attractor/rundb"
 )
 
@@ -23,10 +26,17 @@ type Config struct {
 type Server struct {
 	config   Config
 	registry *PipelineRegistry
+	metrics  *serverMetrics
 	baseCtx  context.Context
 	cancel   context.CancelFunc
 	httpSrv  *http.Server
 	logger   *log.Logger
+
+	graphEditMu sync.Mutex    // serializes read-modify-write of workflow graph.dot files
+	tmux        *tmux.Manager // agent sessions of --tmux runs, for the web terminal
+
+	runDBMu sync.Mutex
+	runDB   *rundb.DB // opened on first /metrics scrape, closed on Shutdown
 }
 
 // New creates a new Server with the given config.
@@ -35,15 +45,18 @@ func New(cfg Config) *Server {
 	s := &Server{
 		config:   cfg,
 		registry: NewPipelineRegistry(),
+		metrics:  newServerMetrics(),
 		baseCtx:  ctx,
 		cancel:   cancel,
 		logger:   log.New(os.Stderr, "[kilroy-server] ", log.LstdFlags),
+		tmux:     tmux.NewManager(agents.SessionSocket),
 	}
 
 	mux := http.NewServeMux()
 
 	// Go 1.22+ method+pattern routing.
 	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
 
 	// Embedded dashboard UI.
 	ui := uiHandler()
@@ -57,6 +70,8 @@ func New(cfg Config) *Server {
 	mux.HandleFunc("GET /runs", s.handleListRuns)
 	mux.HandleFunc("GET /runs/{id}", s.handleGetPipeline)
 	mux.HandleFunc("GET /workflows", s.handleListWorkflows)
+	mux.HandleFunc("GET /workflows/{name}/graph", s.handleGetWorkflowGraph)
+	mux.HandleFunc("PUT /workflows/{name}/graph", s.handlePutWorkflowGraph)
 	mux.HandleFunc("GET /runs/{id}/events", s.handlePipelineEvents)
 	mux.HandleFunc("POST /runs/{id}/cancel", s.handleCancelPipeline)
 	mux.HandleFunc("GET /runs/{id}/context", s.handleGetContext)
@@ -66,6 +81,8 @@ func New(cfg Config) *Server {
 	mux.HandleFunc("GET /runs/{id}/nodes/{nodeId}/attempts", s.handleGetNodeAttempts)
 	mux.HandleFunc("GET /runs/{id}/nodes/{nodeId}/diff", s.handleGetNodeDiff)
 	mux.HandleFunc("GET /runs/{id}/log", s.handleGetRunLog)
+	mux.HandleFunc("GET /runs/{id}/timeline", s.handleGetRunTimeline)
+	mux.HandleFunc("GET /runs/{id}/terminal", s.handleRunTerminal)
 	mux.HandleFunc("GET /runs/{id}/files/{path...}", s.handleBrowseFiles)
 	mux.HandleFunc("GET /runs/{id}/workspace/{path...}", s.handleBrowseWorkspace)
 	mux.HandleFunc("GET /runs/{id}/questions", s.handleGetQuestions)
@@ -119,13 +136,13 @@ func (s *Server) ListenAndServe() error {
 	return err
 }
 
-// csrfProtect rejects cross-origin POST requests. Browsers automatically set
+// csrfProtect rejects cross-origin POST and PUT requests. Browsers automatically set
 // the Origin header on cross-origin requests, so checking it blocks CSRF from
 // malicious web pages while allowing CLI/programmatic callers (which either
 // omit Origin or set it to match the server).
 func csrfProtect(next http.Handler, _ string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost {
+		if r.Method == http.MethodPost || r.Method == http.MethodPut {
 			origin := r.Header.Get("Origin")
 			if origin != "" {
 				u, err := url.Parse(origin)
@@ -158,4 +175,11 @@ func (s *Server) Shutdown() {
 
 	// Cancel the base context.
 	s.cancel()
+
+	s.runDBMu.Lock()
+	if s.runDB != nil {
+		_ = s.runDB.Close()
+		s.runDB = nil
+	}
+	s.runDBMu.Unlock()
 }
@@ -93,6 +93,13 @@ func (b *Broadcaster) Close() {
 	}
 }
 
+// SubscriberCount returns the number of connected clients.
+func (b *Broadcaster) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
 // History returns a copy of all events received so far.
 func (b *Broadcaster) History() []map[string]any {
 	b.mu.Lock()
@@ -0,0 +1,101 @@
+// Web terminal for --tmux runs: streams an agent session's pane over SSE.
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danshapiro/kilroy/internal/attractor/agents"
+	"github.com/danshapiro/kilroy/internal/attractor/runstate"
+)
+
+const terminalPollInterval = 500 * time.Millisecond
+
+type terminalScreen struct {
+	Session string `json:"session"`
+	Node    string `json:"node"`
+	Content string `json:"content"`
+}
+
+// handleRunTerminal serves GET /runs/{id}/terminal. Query parameters: node
+// (default: the run's current node, or its only live session), lines
+// (scrollback to include, default 200) and once=1 for a single JSON snapshot
+// instead of an SSE stream. The view is read-only; use `kilroy attractor
+// attach` to type into a session.
+func (s *Server) handleRunTerminal(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	logsRoot := s.resolveLogsRoot(id)
+	if logsRoot == "" {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	current := ""
+	if snap, err := runstate.LoadSnapshot(logsRoot); err == nil {
+		current = snap.CurrentNodeID
+	}
+	session, err := agents.ResolveSession(s.tmux, id, r.URL.Query().Get("node"), current)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	sessions, _ := agents.RunSessions(s.tmux, id)
+	node := sessions[session]
+
+	lines := 200
+	if v := r.URL.Query().Get("lines"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			lines = n
+		}
+	}
+
+	if q := r.URL.Query().Get("once"); q == "1" || q == "true" {
+		content, err := s.tmux.CaptureOutput(session, lines)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, terminalScreen{Session: session, Node: node, Content: content})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx := r.Context()
+	ticker := time.NewTicker(terminalPollInterval)
+	defer ticker.Stop()
+	last := ""
+	first := true
+	for {
+		content, err := s.tmux.CaptureOutput(session, lines)
+		if err != nil {
+			// The session is destroyed when the node finishes.
+			fmt.Fprintf(w, "event: end\ndata: {\"session\":%q}\n\n", session)
+			flusher.Flush()
+			return
+		}
+		if first || content != last {
+			data, _ := json.Marshal(terminalScreen{Session: session, Node: node, Content: content})
+			fmt.Fprintf(w, "event: screen\ndata: %s\n\n", data)
+			flusher.Flush()
+			last, first = content, false
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/attractor/agents"
+	"github.com/danshapiro/kilroy/internal/attractor/agents/tmux"
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+)
+
+func TestIntegration_RunTerminal(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	db, err := rundb.Open(rundb.DefaultPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordRunStart("term-run", "g", "", "running", t.TempDir(), "", "", "", "", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	const socket = "kilroy-server-term-test"
+	mgr := tmux.NewManager(socket)
+	defer exec.Command("tmux", "-u", "-L", socket, "kill-server").Run()
+	if _, err := mgr.CreateSession(agents.SessionName("term-run", "impl"), t.TempDir(), "bash -c 'echo TERMINAL_MARKER; sleep 30'", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, ts := newTestServer(t)
+	srv.tmux = mgr
+
+	resp, err := http.Get(ts.URL + "/runs/term-run/terminal?once=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var screen terminalScreen
+	_ = json.NewDecoder(resp.Body).Decode(&screen)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || screen.Node != "impl" || !strings.Contains(screen.Content, "TERMINAL_MARKER") {
+		t.Fatalf("snapshot: status=%d screen=%+v", resp.StatusCode, screen)
+	}
+
+	stream, err := http.Get(ts.URL + "/runs/term-run/terminal?node=impl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+	sc := bufio.NewScanner(stream.Body)
+	var event, data string
+	for sc.Scan() && data == "" {
+		line := sc.Text()
+		if strings.HasPrefix(line, "event: ") {
+			event = strings.TrimPrefix(line, "event: ")
+		} else if strings.HasPrefix(line, "data: ") {
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if event != "screen" || !strings.Contains(data, "TERMINAL_MARKER") {
+		t.Fatalf("first SSE event %q: %s", event, data)
+	}
+
+	missing, err := http.Get(ts.URL + "/runs/term-run/terminal?node=review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("node without session: status %d", missing.StatusCode)
+	}
+}
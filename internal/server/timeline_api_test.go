@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/danshapiro/kilroy/internal/attractor/rundb"
+	"github.com/danshapiro/kilroy/internal/attractor/runstate"
+)
+
+func TestIntegration_RunTimelineEndpoint(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	db, err := rundb.Open(rundb.DefaultPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordRunStart("timeline-run", "g", "", "running", "", "", "", "", "digraph g { impl -> review }", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i, node := range []string{"impl", "impl", "review"} {
+		id, err := db.RecordNodeStart("timeline-run", node, i%2+1, "codergen")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.RecordNodeComplete(id, "success", "", "", "", "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	_, ts := newTestServer(t)
+	resp, err := http.Get(ts.URL + "/runs/timeline/timeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	var tl runstate.Timeline
+	if err := json.NewDecoder(resp.Body).Decode(&tl); err != nil {
+		t.Fatal(err)
+	}
+	if tl.RunID != "timeline-run" || len(tl.Spans) != 3 || tl.Retries != 1 || len(tl.CriticalPath) == 0 {
+		t.Fatalf("timeline = %+v", tl)
+	}
+
+	missing, err := http.Get(ts.URL + "/runs/nope/timeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown run: status %d", missing.StatusCode)
+	}
+}
@@ -0,0 +1,306 @@
+// Graph editing API for workflow packages: GET/PUT /workflows/{name}/graph.
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/danshapiro/kilroy/internal/attractor/dot"
+	"github.com/danshapiro/kilroy/internal/attractor/engine"
+	"github.com/danshapiro/kilroy/internal/attractor/modeldb"
+	"github.com/danshapiro/kilroy/internal/attractor/validate"
+)
+
+// graphEditOp is one structural edit from the UI editor. Edits are applied
+// through dot.Document, so comments and formatting outside the touched
+// statements survive a save.
+type graphEditOp struct {
+	Op    string            `json:"op"` // add_node, remove_node, rename_node, set_node_attrs, add_edge, remove_edge, set_edge_attrs
+	ID    string            `json:"id,omitempty"`
+	NewID string            `json:"new_id,omitempty"`
+	From  string            `json:"from,omitempty"`
+	To    string            `json:"to,omitempty"`
+	Attrs map[string]string `json:"attrs,omitempty"` // an empty value removes the attribute
+}
+
+// graphPutRequest replaces the graph either with full DOT text (text mode) or
+// by applying ops to the file on disk. BaseSHA, when set, must match the
+// file's current content hash so concurrent edits are not overwritten.
+type graphPutRequest struct {
+	DOT     *string       `json:"dot,omitempty"`
+	Ops     []graphEditOp `json:"ops,omitempty"`
+	BaseSHA string        `json:"base_sha,omitempty"`
+	DryRun  bool          `json:"dry_run,omitempty"`
+}
+
+type graphNodeJSON struct {
+	ID    string            `json:"id"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+type graphEdgeJSON struct {
+	From  string            `json:"from"`
+	To    string            `json:"to"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+type graphModelJSON struct {
+	Name  string            `json:"name"`
+	Attrs map[string]string `json:"attrs"`
+	Nodes []graphNodeJSON   `json:"nodes"`
+	Edges []graphEdgeJSON   `json:"edges"`
+}
+
+type graphDocResponse struct {
+	Name        string                `json:"name"`
+	Path        string                `json:"path"`
+	SHA         string                `json:"sha"`
+	DOT         string                `json:"dot"`
+	Graph       *graphModelJSON       `json:"graph,omitempty"`
+	Diagnostics []validate.Diagnostic `json:"diagnostics"`
+	Valid       bool                  `json:"valid"`
+	Saved       bool                  `json:"saved"`
+}
+
+var (
+	editorCatalogOnce sync.Once
+	editorCatalog     *modeldb.Catalog
+)
+
+func (s *Server) handleGetWorkflowGraph(w http.ResponseWriter, r *http.Request) {
+	wf, path, ok := workflowGraphPath(w, r)
+	if !ok {
+		return
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, analyzeWorkflowGraph(wf, path, src))
+}
+
+func (s *Server) handlePutWorkflowGraph(w http.ResponseWriter, r *http.Request) {
+	wf, path, ok := workflowGraphPath(w, r)
+	if !ok {
+		return
+	}
+	var req graphPutRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if r.URL.Query().Get("dry_run") == "1" || r.URL.Query().Get("dry_run") == "true" {
+		req.DryRun = true
+	}
+	if (req.DOT == nil) == (len(req.Ops) == 0) {
+		writeError(w, http.StatusBadRequest, "exactly one of dot or ops is required")
+		return
+	}
+
+	s.graphEditMu.Lock()
+	defer s.graphEditMu.Unlock()
+	current, err := os.ReadFile(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if req.BaseSHA != "" && req.BaseSHA != graphSHA(current) {
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error":   "graph changed on disk since base_sha; reload and reapply",
+			"current": analyzeWorkflowGraph(wf, path, current),
+		})
+		return
+	}
+
+	var next []byte
+	if req.DOT != nil {
+		next = []byte(*req.DOT)
+	} else {
+		doc, err := dot.ParseDocument(current)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "graph on disk does not parse; edit it as text: "+err.Error())
+			return
+		}
+		for i, op := range req.Ops {
+			if err := applyGraphEditOp(doc, op); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("ops[%d] %s: %v", i, op.Op, err))
+				return
+			}
+		}
+		next = doc.Bytes()
+	}
+
+	resp := analyzeWorkflowGraph(wf, path, next)
+	if req.DryRun {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	if resp.Graph == nil {
+		// Lint findings are saved like any editor would; unparseable DOT is not.
+		writeJSON(w, http.StatusUnprocessableEntity, resp)
+		return
+	}
+	if err := writeFileAtomic(path, next); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp.Saved = true
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func workflowGraphPath(w http.ResponseWriter, r *http.Request) (workflowInfo, string, bool) {
+	name := r.PathValue("name")
+	wf, ok := findWorkflowPackage(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "workflow not found: "+name)
+		return workflowInfo{}, "", false
+	}
+	return wf, filepath.Join(wf.Dir, "graph.dot"), true
+}
+
+func applyGraphEditOp(doc *dot.Document, op graphEditOp) error {
+	switch op.Op {
+	case "add_node":
+		if doc.HasNode(op.ID) {
+			return fmt.Errorf("node %q already exists", op.ID)
+		}
+		if err := doc.AddNode(op.ID, sortedDotAttrs(op.Attrs)...); err != nil {
+			return err
+		}
+		if !doc.HasNode(op.ID) {
+			return fmt.Errorf("%q is not a valid node identifier", op.ID)
+		}
+		return nil
+	case "remove_node":
+		return doc.RemoveNode(op.ID)
+	case "rename_node":
+		return doc.RenameNode(op.ID, op.NewID)
+	case "set_node_attrs":
+		for _, a := range sortedDotAttrs(op.Attrs) {
+			var err error
+			if a.Value == "" {
+				err = doc.DeleteNodeAttr(op.ID, a.Key)
+			} else {
+				err = doc.SetNodeAttr(op.ID, a.Key, a.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "add_edge":
+		for _, id := range []string{op.From, op.To} {
+			if !doc.HasNode(id) {
+				return fmt.Errorf("node %q not found", id)
+			}
+		}
+		return doc.AddEdge(op.From, op.To, sortedDotAttrs(op.Attrs)...)
+	case "remove_edge":
+		return doc.RemoveEdge(op.From, op.To)
+	case "set_edge_attrs":
+		for _, a := range sortedDotAttrs(op.Attrs) {
+			var err error
+			if a.Value == "" {
+				err = doc.DeleteEdgeAttr(op.From, op.To, a.Key)
+			} else {
+				err = doc.SetEdgeAttr(op.From, op.To, a.Key, a.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown op")
+}
+
+func sortedDotAttrs(m map[string]string) []dot.Attr {
+	out := make([]dot.Attr, 0, len(m))
+	for k, v := range m {
+		out = append(out, dot.Attr{Key: k, Value: v})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// analyzeWorkflowGraph parses src for the editor model (attributes as written,
+// before stylesheet and prompt_file expansion) and validates it the same way
+// `kilroy attractor validate` does.
+func analyzeWorkflowGraph(wf workflowInfo, path string, src []byte) graphDocResponse {
+	resp := graphDocResponse{
+		Name:        wf.Name,
+		Path:        path,
+		SHA:         graphSHA(src),
+		DOT:         string(src),
+		Diagnostics: []validate.Diagnostic{},
+	}
+	g, err := dot.Parse(src)
+	if err != nil {
+		resp.Diagnostics = append(resp.Diagnostics, validate.Diagnostic{Rule: "dot_syntax", Severity: validate.SeverityError, Message: err.Error()})
+		return resp
+	}
+	model := &graphModelJSON{Name: g.Name, Attrs: g.Attrs, Nodes: []graphNodeJSON{}, Edges: []graphEdgeJSON{}}
+	for _, n := range g.Nodes {
+		model.Nodes = append(model.Nodes, graphNodeJSON{ID: n.ID, Attrs: n.Attrs})
+	}
+	sort.Slice(model.Nodes, func(i, j int) bool { return g.Nodes[model.Nodes[i].ID].Order < g.Nodes[model.Nodes[j].ID].Order })
+	for _, e := range g.Edges {
+		model.Edges = append(model.Edges, graphEdgeJSON{From: e.From, To: e.To, Attrs: e.Attrs})
+	}
+	resp.Graph = model
+
+	editorCatalogOnce.Do(func() { editorCatalog, _ = modeldb.LoadEmbeddedCatalog() })
+	_, diags, err := engine.PrepareWithOptions(src, engine.PrepareOptions{GraphDir: wf.Dir, Catalog: editorCatalog})
+	resp.Diagnostics = append(resp.Diagnostics, diags...)
+	resp.Valid = err == nil
+	if err != nil && !hasErrorDiagnostic(diags) {
+		resp.Diagnostics = append(resp.Diagnostics, validate.Diagnostic{Rule: "prepare", Severity: validate.SeverityError, Message: err.Error()})
+	}
+	return resp
+}
+
+func hasErrorDiagnostic(diags []validate.Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == validate.SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func graphSHA(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".graph-*.dot")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
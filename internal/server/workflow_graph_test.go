@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const editorTestGraph = `digraph demo {
+  // keep this comment
+  start [shape=Mdiamond]
+  build [shape=parallelogram, tool_command="echo hi"]
+  gate [shape=diamond]
+  done [shape=Msquare]
+  start -> build -> gate
+  gate -> done
+}
+`
+
+func putWorkflowGraph(t *testing.T, url string, body any) (int, graphDocResponse) {
+	t.Helper()
+	raw, _ := json.Marshal(body)
+	req, _ := http.NewRequest(http.MethodPut, url, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out graphDocResponse
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestIntegration_WorkflowGraphEditor(t *testing.T) {
+	root := t.TempDir()
+	pkg := filepath.Join(root, "workflows", "demo")
+	if err := os.MkdirAll(pkg, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	graphPath := filepath.Join(pkg, "graph.dot")
+	if err := os.WriteFile(graphPath, []byte(editorTestGraph), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+	_, ts := newTestServer(t)
+	url := ts.URL + "/workflows/demo/graph"
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got graphDocResponse
+	_ = json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || got.Graph == nil || len(got.Graph.Nodes) != 4 || len(got.Graph.Edges) != 3 {
+		t.Fatalf("GET: status=%d resp=%+v", resp.StatusCode, got)
+	}
+	if got.Graph.Nodes[0].ID != "start" || got.Graph.Nodes[1].Attrs["tool_command"] != "echo hi" {
+		t.Fatalf("nodes = %+v", got.Graph.Nodes)
+	}
+	if !got.Valid {
+		t.Fatalf("expected valid graph, diagnostics=%+v", got.Diagnostics)
+	}
+
+	// Dry run: removing the exit edge surfaces diagnostics without touching disk.
+	code, dry := putWorkflowGraph(t, url, map[string]any{
+		"ops":     []map[string]any{{"op": "remove_edge", "from": "gate", "to": "done"}},
+		"dry_run": true,
+	})
+	if code != http.StatusOK || dry.Saved || dry.Valid || len(dry.Diagnostics) == 0 {
+		t.Fatalf("dry run: status=%d resp=%+v", code, dry)
+	}
+	if b, _ := os.ReadFile(graphPath); string(b) != editorTestGraph {
+		t.Fatalf("dry run modified the file:\n%s", b)
+	}
+
+	// Save: add a check node between build and gate.
+	code, saved := putWorkflowGraph(t, url, map[string]any{
+		"base_sha": got.SHA,
+		"ops": []map[string]any{
+			{"op": "add_node", "id": "check", "attrs": map[string]string{"shape": "parallelogram", "tool_command": "true"}},
+			{"op": "remove_edge", "from": "build", "to": "gate"},
+			{"op": "add_edge", "from": "build", "to": "check"},
+			{"op": "add_edge", "from": "check", "to": "gate", "attrs": map[string]string{"label": "checked"}},
+			{"op": "set_node_attrs", "id": "build", "attrs": map[string]string{"tool_command": ""}},
+		},
+	})
+	if code != http.StatusOK || !saved.Saved {
+		t.Fatalf("save: status=%d resp=%+v", code, saved)
+	}
+	b, _ := os.ReadFile(graphPath)
+	for _, want := range []string{"// keep this comment", "check [", `check -> gate [label=checked]`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("saved graph missing %q:\n%s", want, b)
+		}
+	}
+	if strings.Contains(string(b), "echo hi") {
+		t.Errorf("empty attr value did not delete tool_command:\n%s", b)
+	}
+
+	// Stale base_sha is rejected.
+	code, _ = putWorkflowGraph(t, url, map[string]any{
+		"base_sha": got.SHA,
+		"ops":      []map[string]any{{"op": "remove_node", "id": "check"}},
+	})
+	if code != http.StatusConflict {
+		t.Fatalf("stale base_sha: status=%d", code)
+	}
+
+	// Unparseable text is reported but never written.
+	code, bad := putWorkflowGraph(t, url, map[string]any{"dot": "digraph {"})
+	if code != http.StatusUnprocessableEntity || bad.Saved || len(bad.Diagnostics) == 0 || bad.Diagnostics[0].Rule != "dot_syntax" {
+		t.Fatalf("bad dot: status=%d resp=%+v", code, bad)
+	}
+
+	code, _ = putWorkflowGraph(t, url, map[string]any{"ops": []map[string]any{{"op": "remove_node", "id": "nope"}}})
+	if code != http.StatusBadRequest {
+		t.Fatalf("unknown node: status=%d", code)
+	}
+	if resp, _ := http.Get(ts.URL + "/workflows/missing/graph"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing workflow: status=%d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,51 @@
+package testresults
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+type cargoEvent struct {
+	Type    string `json:"type"`
+	Event   string `json:"event"`
+	Name    string `json:"name"`
+	Stdout  string `json:"stdout"`
+	Message string `json:"message"`
+}
+
+// parseCargo reads libtest's JSON event stream, as printed by
+// cargo test -- -Z unstable-options --format json.
+func parseCargo(data []byte) (*Report, error) {
+	r := &Report{Format: FormatCargo}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var ev cargoEvent
+		if json.Unmarshal(line, &ev) != nil || ev.Type != "test" {
+			continue
+		}
+		switch ev.Event {
+		case "ok":
+			r.Total++
+			r.Passed++
+		case "ignored":
+			r.Total++
+			r.Skipped++
+		case "failed", "timeout":
+			r.Total++
+			r.Failed++
+			msg := ev.Stdout
+			if ev.Message != "" {
+				msg = strings.TrimSpace(ev.Message + "\n" + msg)
+			}
+			if ev.Event == "timeout" && msg == "" {
+				msg = "test timed out"
+			}
+			r.Failures = append(r.Failures, newFailure("", ev.Name, "", 0, msg))
+		}
+	}
+	return r, nil
+}
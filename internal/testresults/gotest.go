@@ -0,0 +1,176 @@
+package testresults
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+type goTestEvent struct {
+	Action      string `json:"Action"`
+	Package     string `json:"Package"`
+	ImportPath  string `json:"ImportPath"`
+	Test        string `json:"Test"`
+	Output      string `json:"Output"`
+	FailedBuild string `json:"FailedBuild"`
+}
+
+type goTestCase struct {
+	pkg, name string
+	result    string
+	output    strings.Builder
+}
+
+// parseGoTest reads `go test -json` output. Only leaf tests are counted: a
+// parent whose subtests ran is represented by them, unless it failed on its
+// own. A package that fails without any failing test (a build error, a
+// TestMain exit) counts as one failure.
+func parseGoTest(data []byte) (*Report, error) {
+	var (
+		order      []*goTestCase
+		cases      = map[string]*goTestCase{}
+		pkgOrder   []string
+		pkgResult  = map[string]string{}
+		pkgOutput  = map[string]*strings.Builder{}
+		pkgBuild   = map[string]string{}
+		buildOut   = map[string]*strings.Builder{}
+		sawPackage = map[string]bool{}
+	)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var ev goTestEvent
+		if json.Unmarshal(line, &ev) != nil || ev.Action == "" {
+			continue
+		}
+		if ev.Action == "build-output" {
+			key := strings.Fields(ev.ImportPath + " ")[0]
+			if buildOut[key] == nil {
+				buildOut[key] = &strings.Builder{}
+			}
+			buildOut[key].WriteString(ev.Output)
+			continue
+		}
+		if ev.Package == "" {
+			continue
+		}
+		if !sawPackage[ev.Package] {
+			sawPackage[ev.Package] = true
+			pkgOrder = append(pkgOrder, ev.Package)
+			pkgOutput[ev.Package] = &strings.Builder{}
+		}
+		if ev.Test == "" {
+			switch ev.Action {
+			case "output":
+				pkgOutput[ev.Package].WriteString(ev.Output)
+			case "pass", "fail", "skip":
+				pkgResult[ev.Package] = ev.Action
+				if ev.FailedBuild != "" {
+					pkgBuild[ev.Package] = ev.FailedBuild
+				}
+			}
+			continue
+		}
+		key := ev.Package + "\x00" + ev.Test
+		tc := cases[key]
+		if tc == nil {
+			tc = &goTestCase{pkg: ev.Package, name: ev.Test}
+			cases[key] = tc
+			order = append(order, tc)
+		}
+		switch ev.Action {
+		case "output":
+			if !isGoTestFrameLine(ev.Output) {
+				tc.output.WriteString(ev.Output)
+			}
+		case "pass", "fail", "skip":
+			tc.result = ev.Action
+		}
+	}
+	if len(order) == 0 && len(pkgOrder) == 0 {
+		return &Report{Format: FormatGoTest}, nil
+	}
+
+	hasSubtests := map[string]bool{}
+	failedSubtests := map[string]bool{}
+	for _, tc := range order {
+		if i := strings.LastIndex(tc.name, "/"); i > 0 {
+			for parent := tc.name[:i]; ; {
+				hasSubtests[tc.pkg+"\x00"+parent] = true
+				if tc.result == "fail" {
+					failedSubtests[tc.pkg+"\x00"+parent] = true
+				}
+				j := strings.LastIndex(parent, "/")
+				if j < 0 {
+					break
+				}
+				parent = parent[:j]
+			}
+		}
+	}
+
+	r := &Report{Format: FormatGoTest}
+	pkgFailedTests := map[string]bool{}
+	for _, tc := range order {
+		key := tc.pkg + "\x00" + tc.name
+		if hasSubtests[key] && !(tc.result == "fail" && !failedSubtests[key]) {
+			if tc.result == "fail" {
+				pkgFailedTests[tc.pkg] = true
+			}
+			continue
+		}
+		switch tc.result {
+		case "pass":
+			r.Passed++
+		case "skip":
+			r.Skipped++
+		case "fail":
+			r.Failed++
+			pkgFailedTests[tc.pkg] = true
+			r.Failures = append(r.Failures, newFailure(tc.pkg, tc.name, "", 0, tc.output.String()))
+		default:
+			// Started but never finished: the package panicked or timed
+			// out while this test was running.
+			if pkgResult[tc.pkg] == "fail" {
+				r.Failed++
+				pkgFailedTests[tc.pkg] = true
+				msg := tc.output.String() + pkgOutput[tc.pkg].String()
+				r.Failures = append(r.Failures, newFailure(tc.pkg, tc.name, "", 0, msg))
+			} else {
+				continue
+			}
+		}
+		r.Total++
+	}
+	for _, pkg := range pkgOrder {
+		if pkgResult[pkg] != "fail" || pkgFailedTests[pkg] {
+			continue
+		}
+		name := "(package)"
+		msg := pkgOutput[pkg].String()
+		if b := pkgBuild[pkg]; b != "" {
+			name = "(build failed)"
+			if out := buildOut[strings.Fields(b)[0]]; out != nil {
+				msg = out.String() + msg
+			}
+		}
+		r.Total++
+		r.Failed++
+		r.Failures = append(r.Failures, newFailure(pkg, name, "", 0, msg))
+	}
+	return r, nil
+}
+
+// isGoTestFrameLine reports go test's own progress lines, which carry no
+// information beyond the event itself.
+func isGoTestFrameLine(s string) bool {
+	t := strings.TrimSpace(s)
+	for _, p := range []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME", "--- PASS", "--- FAIL", "--- SKIP"} {
+		if strings.HasPrefix(t, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package testresults
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// junitSuite decodes both <testsuites> and <testsuite> roots; suites nest
+// arbitrarily in the wild (pytest, surefire, jest-junit, gotestsum).
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      string        `xml:"line,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *struct{}     `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func parseJUnit(data []byte) (*Report, error) {
+	// Runners sometimes print a banner before the document.
+	start := bytes.Index(data, []byte("<?xml"))
+	if start < 0 {
+		start = bytes.Index(data, []byte("<testsuite"))
+	}
+	if start < 0 {
+		return &Report{Format: FormatJUnit}, nil
+	}
+	var root junitSuite
+	if err := xml.NewDecoder(bytes.NewReader(data[start:])).Decode(&root); err != nil {
+		return nil, fmt.Errorf("parse junit xml: %w", err)
+	}
+	r := &Report{Format: FormatJUnit}
+	walkJUnit(r, root)
+	return r, nil
+}
+
+func walkJUnit(r *Report, s junitSuite) {
+	for _, c := range s.Cases {
+		r.Total++
+		p := c.Failure
+		if p == nil {
+			p = c.Error
+		}
+		switch {
+		case p != nil:
+			r.Failed++
+			suite := c.Classname
+			if suite == "" {
+				suite = s.Name
+			}
+			msg := strings.TrimSpace(p.Text)
+			if head := strings.TrimSpace(p.Message); head != "" && !strings.Contains(msg, head) {
+				msg = strings.TrimSpace(head + "\n" + msg)
+			}
+			line, _ := strconv.Atoi(strings.TrimSpace(c.Line))
+			r.Failures = append(r.Failures, newFailure(suite, c.Name, strings.TrimSpace(c.File), line, msg))
+		case c.Skipped != nil:
+			r.Skipped++
+		default:
+			r.Passed++
+		}
+	}
+	for _, child := range s.Suites {
+		walkJUnit(r, child)
+	}
+}
@@ -0,0 +1,87 @@
+package testresults
+
+import (
+	"regexp"
+	"strings"
+)
+
+var tapPointRE = regexp.MustCompile(`^(not )?ok\b\s*(\d+)?\s*(?:-\s*)?(.*)$`)
+
+// parseTAP reads TAP 12-14. Only top-level test points are counted;
+// indented subtest streams are summarized by their parent's point. Indented
+// YAML blocks and "#" diagnostics after a failing point become its message.
+func parseTAP(data []byte) (*Report, error) {
+	r := &Report{Format: FormatTAP}
+	var (
+		cur     *Failure
+		msg     strings.Builder
+		inYAML  bool
+		pending bool
+	)
+	flush := func() {
+		if pending {
+			r.Failures = append(r.Failures, newFailure("", cur.Name, "", 0, msg.String()))
+		}
+		pending, inYAML, cur = false, false, nil
+		msg.Reset()
+	}
+	for _, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		trimmed := strings.TrimSpace(raw)
+		indented := len(raw) > 0 && (raw[0] == ' ' || raw[0] == '\t')
+
+		if pending {
+			switch {
+			case indented && trimmed == "---":
+				inYAML = true
+				continue
+			case inYAML && indented && trimmed == "...":
+				inYAML = false
+				continue
+			case inYAML:
+				msg.WriteString(trimmed + "\n")
+				continue
+			case strings.HasPrefix(trimmed, "#"):
+				msg.WriteString(strings.TrimSpace(strings.TrimPrefix(trimmed, "#")) + "\n")
+				continue
+			}
+		}
+		if indented {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "Bail out!") {
+			flush()
+			r.Total++
+			r.Failed++
+			r.Failures = append(r.Failures, newFailure("", "Bail out!", "", 0, strings.TrimSpace(strings.TrimPrefix(trimmed, "Bail out!"))))
+			continue
+		}
+		m := tapPointRE.FindStringSubmatch(trimmed)
+		if m == nil {
+			continue
+		}
+		flush()
+		desc, directive := m[3], ""
+		if i := strings.Index(desc, " # "); i >= 0 {
+			desc, directive = strings.TrimSpace(desc[:i]), strings.ToUpper(strings.TrimSpace(desc[i+3:]))
+		} else if strings.HasPrefix(desc, "# ") {
+			desc, directive = "", strings.ToUpper(strings.TrimSpace(desc[2:]))
+		}
+		if desc == "" {
+			desc = "test " + m[2]
+		}
+		r.Total++
+		switch {
+		case strings.HasPrefix(directive, "SKIP"), strings.HasPrefix(directive, "TODO"):
+			// TODO tests are expected to fail and never fail the run.
+			r.Skipped++
+		case m[1] != "":
+			r.Failed++
+			cur, pending = &Failure{Name: desc}, true
+		default:
+			r.Passed++
+		}
+	}
+	flush()
+	return r, nil
+}
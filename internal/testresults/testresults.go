@@ -0,0 +1,253 @@
+// Package testresults parses the machine-readable output of common test
+// runners (go test -json, JUnit XML, TAP, cargo libtest JSON) into a single
+// report shape that agents and tool nodes can summarize compactly.
+package testresults
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format names accepted by Parse and reported in Report.Format.
+const (
+	FormatAuto   = "auto"
+	FormatGoTest = "go_test_json"
+	FormatJUnit  = "junit"
+	FormatTAP    = "tap"
+	FormatCargo  = "cargo_json"
+)
+
+// ErrNoResults is returned when the input contains no recognizable test
+// results.
+var ErrNoResults = errors.New("no test results found")
+
+// maxMessageBytes caps the output kept per failure; the head of a failure
+// message is nearly always the useful part.
+const maxMessageBytes = 4000
+
+type Failure struct {
+	Name    string `json:"name"`
+	Suite   string `json:"suite,omitempty"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Location renders file:line, or "" when unknown.
+func (f Failure) Location() string {
+	if f.File == "" {
+		return ""
+	}
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return f.File
+}
+
+type Report struct {
+	Format   string    `json:"format"`
+	Total    int       `json:"total"`
+	Passed   int       `json:"passed"`
+	Failed   int       `json:"failed"`
+	Skipped  int       `json:"skipped"`
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+// Parse reads test results in the given format. An empty format or "auto"
+// detects it from the content.
+func Parse(format string, data []byte) (*Report, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" || format == FormatAuto {
+		format = Detect(data)
+		if format == "" {
+			return nil, ErrNoResults
+		}
+	}
+	var (
+		r   *Report
+		err error
+	)
+	switch format {
+	case FormatGoTest, "go", "gotest":
+		r, err = parseGoTest(data)
+	case FormatJUnit, "xml":
+		r, err = parseJUnit(data)
+	case FormatTAP:
+		r, err = parseTAP(data)
+	case FormatCargo, "cargo", "libtest":
+		r, err = parseCargo(data)
+	default:
+		return nil, fmt.Errorf("unknown test result format %q (want auto, %s, %s, %s or %s)", format, FormatGoTest, FormatJUnit, FormatTAP, FormatCargo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Total == 0 {
+		return nil, ErrNoResults
+	}
+	return r, nil
+}
+
+var tapLineRE = regexp.MustCompile(`^(not )?ok\b`)
+
+// Detect guesses the format of data, returning "" when nothing looks like
+// test results.
+func Detect(data []byte) string {
+	if bytes.Contains(data, []byte("<testsuite")) {
+		return FormatJUnit
+	}
+	tap := false
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] == '{' {
+			var probe struct {
+				Action string `json:"Action"`
+				Type   string `json:"type"`
+				Event  string `json:"event"`
+			}
+			if json.Unmarshal(line, &probe) == nil {
+				if probe.Action != "" {
+					return FormatGoTest
+				}
+				if (probe.Type == "test" || probe.Type == "suite") && probe.Event != "" {
+					return FormatCargo
+				}
+			}
+			continue
+		}
+		if tapLineRE.Match(line) {
+			tap = true
+		}
+	}
+	if tap {
+		return FormatTAP
+	}
+	return ""
+}
+
+// Merge folds o into r, e.g. when a suite writes one JUnit file per package.
+func (r *Report) Merge(o *Report) {
+	if o == nil {
+		return
+	}
+	if r.Format == "" {
+		r.Format = o.Format
+	}
+	r.Total += o.Total
+	r.Passed += o.Passed
+	r.Failed += o.Failed
+	r.Skipped += o.Skipped
+	r.Failures = append(r.Failures, o.Failures...)
+}
+
+// Headline is a one-line result such as "2 of 57 tests failed, 3 skipped".
+func (r *Report) Headline() string {
+	var s string
+	if r.Failed > 0 {
+		s = fmt.Sprintf("%d of %d tests failed", r.Failed, r.Total)
+	} else {
+		s = fmt.Sprintf("%d tests passed", r.Passed)
+	}
+	if r.Skipped > 0 {
+		s += fmt.Sprintf(", %d skipped", r.Skipped)
+	}
+	return s
+}
+
+// Summary renders the headline followed by each failure's name, location
+// and the first messageLines lines of its output. At most maxFailures are
+// listed; zero or less lists them all.
+func (r *Report) Summary(maxFailures, messageLines int) string {
+	var b strings.Builder
+	status := "PASS"
+	if r.Failed > 0 {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&b, "%s: %s (%s)\n", status, r.Headline(), r.Format)
+	for i, f := range r.Failures {
+		if maxFailures > 0 && i == maxFailures {
+			fmt.Fprintf(&b, "\n... and %d more failing tests\n", len(r.Failures)-maxFailures)
+			break
+		}
+		b.WriteString("\n--- ")
+		if f.Suite != "" {
+			b.WriteString(f.Suite + " ")
+		}
+		b.WriteString(f.Name)
+		if loc := f.Location(); loc != "" {
+			b.WriteString(" (" + loc + ")")
+		}
+		b.WriteString("\n")
+		lines := strings.Split(strings.TrimSpace(f.Message), "\n")
+		for j, line := range lines {
+			if line == "" && len(lines) == 1 {
+				break
+			}
+			if messageLines > 0 && j == messageLines {
+				fmt.Fprintf(&b, "    [... %d more lines]\n", len(lines)-messageLines)
+				break
+			}
+			b.WriteString("    " + strings.TrimRight(line, " \t") + "\n")
+		}
+	}
+	return b.String()
+}
+
+// FailedNames lists the failing tests' names, for short failure reasons.
+func (r *Report) FailedNames() []string {
+	out := make([]string, 0, len(r.Failures))
+	for _, f := range r.Failures {
+		out = append(out, f.Name)
+	}
+	return out
+}
+
+var (
+	sourceExts = `go|rs|py|js|jsx|mjs|cjs|ts|tsx|mts|cts|java|kt|rb|c|cc|cpp|cxx|h|hpp|cs|swift|php|ex|exs|scala|t`
+	// foo_test.go:42, src/lib.rs:10:5, tests/test_x.py:12
+	fileLineRE = regexp.MustCompile(`([A-Za-z0-9_./\\-]+\.(?:` + sourceExts + `)):(\d+)`)
+	// Python tracebacks: File "tests/test_x.py", line 12
+	pyFileLineRE = regexp.MustCompile(`File "([^"]+)", line (\d+)`)
+	// Perl TAP diagnostics: at t/basic.t line 12.
+	perlFileLineRE = regexp.MustCompile(`at (\S+) line (\d+)`)
+)
+
+// findLocation returns the first source location mentioned in text.
+func findLocation(text string) (string, int) {
+	best, file, line := -1, "", 0
+	for _, re := range []*regexp.Regexp{fileLineRE, pyFileLineRE, perlFileLineRE} {
+		m := re.FindStringSubmatchIndex(text)
+		if m == nil || (best >= 0 && m[0] >= best) {
+			continue
+		}
+		n, err := strconv.Atoi(text[m[4]:m[5]])
+		if err != nil {
+			continue
+		}
+		best, file, line = m[0], text[m[2]:m[3]], n
+	}
+	return file, line
+}
+
+func capMessage(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= maxMessageBytes {
+		return s
+	}
+	return s[:maxMessageBytes] + "\n[... truncated]"
+}
+
+// newFailure builds a Failure, filling the location from the message when
+// the format did not carry one.
+func newFailure(suite, name, file string, line int, message string) Failure {
+	message = capMessage(message)
+	if file == "" {
+		file, line = findLocation(message)
+	}
+	return Failure{Name: name, Suite: suite, File: file, Line: line, Message: message}
+}
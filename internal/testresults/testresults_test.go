@@ -0,0 +1,148 @@
+package testresults
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const goTestJSON = `{"Action":"start","Package":"example.com/calc"}
+{"Action":"run","Package":"example.com/calc","Test":"TestAdd"}
+{"Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"--- PASS: TestAdd (0.00s)\n"}
+{"Action":"pass","Package":"example.com/calc","Test":"TestAdd","Elapsed":0}
+{"Action":"run","Package":"example.com/calc","Test":"TestDiv"}
+{"Action":"run","Package":"example.com/calc","Test":"TestDiv/by_zero"}
+{"Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"    calc_test.go:42: got 1, want error\n"}
+{"Action":"fail","Package":"example.com/calc","Test":"TestDiv/by_zero","Elapsed":0}
+{"Action":"run","Package":"example.com/calc","Test":"TestDiv/exact"}
+{"Action":"pass","Package":"example.com/calc","Test":"TestDiv/exact","Elapsed":0}
+{"Action":"fail","Package":"example.com/calc","Test":"TestDiv","Elapsed":0}
+{"Action":"run","Package":"example.com/calc","Test":"TestLater"}
+{"Action":"skip","Package":"example.com/calc","Test":"TestLater","Elapsed":0}
+{"Action":"fail","Package":"example.com/calc","Elapsed":0.01}
+{"ImportPath":"example.com/broken [example.com/broken.test]","Action":"build-output","Output":"# example.com/broken\n"}
+{"ImportPath":"example.com/broken [example.com/broken.test]","Action":"build-output","Output":"broken/x.go:3:2: undefined: y\n"}
+{"Action":"start","Package":"example.com/broken"}
+{"Action":"output","Package":"example.com/broken","Output":"FAIL\texample.com/broken [build failed]\n"}
+{"Action":"fail","Package":"example.com/broken","Elapsed":0,"FailedBuild":"example.com/broken [example.com/broken.test]"}
+`
+
+func TestParse_GoTestJSON_CountsLeavesAndBuildFailures(t *testing.T) {
+	r, err := Parse("", []byte("some banner\n"+goTestJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Format != FormatGoTest || r.Total != 5 || r.Passed != 2 || r.Failed != 2 || r.Skipped != 1 {
+		t.Fatalf("report = %+v", r)
+	}
+	f := r.Failures[0]
+	if f.Name != "TestDiv/by_zero" || f.Suite != "example.com/calc" || f.Location() != "calc_test.go:42" || f.Message != "calc_test.go:42: got 1, want error" {
+		t.Fatalf("failure[0] = %+v", f)
+	}
+	f = r.Failures[1]
+	if f.Name != "(build failed)" || f.Location() != "broken/x.go:3" || !strings.Contains(f.Message, "undefined: y") {
+		t.Fatalf("failure[1] = %+v", f)
+	}
+}
+
+func TestParse_JUnit_NestedSuites(t *testing.T) {
+	xml := `pytest banner
+<?xml version="1.0" encoding="utf-8"?>
+<testsuites>
+  <testsuite name="pytest">
+    <testcase classname="tests.test_api" name="test_ok" time="0.01"/>
+    <testcase classname="tests.test_api" name="test_bad" time="0.02">
+      <failure message="AssertionError: assert 1 == 2">tests/test_api.py:12: in test_bad
+    assert 1 == 2
+E   AssertionError</failure>
+    </testcase>
+    <testcase classname="tests.test_api" name="test_skip"><skipped message="later"/></testcase>
+    <testsuite name="inner">
+      <testcase classname="Inner" name="errs" file="src/inner.js" line="7"><error message="boom"/></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`
+	r, err := Parse(FormatAuto, []byte(xml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Format != FormatJUnit || r.Total != 4 || r.Passed != 1 || r.Failed != 2 || r.Skipped != 1 {
+		t.Fatalf("report = %+v", r)
+	}
+	if f := r.Failures[0]; f.Suite != "tests.test_api" || f.Location() != "tests/test_api.py:12" || !strings.HasPrefix(f.Message, "AssertionError: assert 1 == 2\ntests/test_api.py:12") {
+		t.Fatalf("failure[0] = %+v", f)
+	}
+	if f := r.Failures[1]; f.Location() != "src/inner.js:7" || f.Message != "boom" {
+		t.Fatalf("failure[1] = %+v", f)
+	}
+}
+
+func TestParse_TAP_DirectivesAndDiagnostics(t *testing.T) {
+	tap := `TAP version 13
+1..5
+ok 1 - adds
+not ok 2 - divides
+  ---
+  message: 'expected 2'
+  at: test/math.js:17:5
+  ...
+ok 3 - later # SKIP not yet
+not ok 4 - flaky # TODO fix
+# Subtest: nested
+    ok 1 - inner
+ok 5 - nested
+`
+	r, err := Parse("", []byte(tap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Format != FormatTAP || r.Total != 5 || r.Passed != 2 || r.Failed != 1 || r.Skipped != 2 {
+		t.Fatalf("report = %+v", r)
+	}
+	if f := r.Failures[0]; f.Name != "divides" || f.Location() != "test/math.js:17" {
+		t.Fatalf("failure = %+v", f)
+	}
+}
+
+func TestParse_CargoJSON(t *testing.T) {
+	out := `{ "type": "suite", "event": "started", "test_count": 3 }
+{ "type": "test", "event": "started", "name": "tests::adds" }
+{ "type": "test", "name": "tests::adds", "event": "ok" }
+{ "type": "test", "name": "tests::divides", "event": "failed", "stdout": "thread 'tests::divides' panicked at src/lib.rs:10:9:\nattempt to divide by zero\n" }
+{ "type": "test", "name": "tests::slow", "event": "ignored" }
+{ "type": "suite", "event": "failed", "passed": 1, "failed": 1, "ignored": 1 }
+`
+	r, err := Parse("", []byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Format != FormatCargo || r.Total != 3 || r.Passed != 1 || r.Failed != 1 || r.Skipped != 1 {
+		t.Fatalf("report = %+v", r)
+	}
+	if f := r.Failures[0]; f.Name != "tests::divides" || f.Location() != "src/lib.rs:10" {
+		t.Fatalf("failure = %+v", f)
+	}
+}
+
+func TestParse_NoResults(t *testing.T) {
+	if _, err := Parse("", []byte("make: *** No rule to make target 'test'.\n")); !errors.Is(err, ErrNoResults) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := Parse("nunit", []byte("x")); err == nil || !strings.Contains(err.Error(), "unknown test result format") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestReport_Summary(t *testing.T) {
+	r := &Report{Format: FormatTAP, Total: 4, Passed: 1, Failed: 3, Failures: []Failure{
+		{Name: "a", File: "a_test.go", Line: 3, Message: "one\ntwo\nthree"},
+		{Name: "b"},
+		{Name: "c"},
+	}}
+	got := r.Summary(2, 2)
+	want := "FAIL: 3 of 4 tests failed (tap)\n\n--- a (a_test.go:3)\n    one\n    two\n    [... 1 more lines]\n\n--- b\n\n... and 1 more failing tests\n"
+	if got != want {
+		t.Fatalf("summary:\n%s\nwant:\n%s", got, want)
+	}
+}